@@ -50,6 +50,14 @@ type Boost interface {
 	BoostDagstorePiecesContainingMultihash(ctx context.Context, mh multihash.Multihash) ([]cid.Cid, error)                                      //perm:read
 	BoostDagstoreListShards(ctx context.Context) ([]DagstoreShardInfo, error)                                                                   //perm:admin
 	BoostMakeDeal(context.Context, smtypes.DealParams) (*ProviderDealRejectionInfo, error)                                                      //perm:write
+	BoostRetrievalPaymentRecord(ctx context.Context, payment RetrievalPayment) error                                                            //perm:admin
+	BoostQuotaList(ctx context.Context) ([]ClientQuota, error)                                                                                  //perm:read
+	BoostQuotaGet(ctx context.Context, kind string, id string) (*ClientQuota, error)                                                            //perm:read
+	BoostQuotaSet(ctx context.Context, quota ClientQuota) error                                                                                 //perm:admin
+	BoostQuotaRemove(ctx context.Context, kind string, id string) error                                                                         //perm:admin
+	BoostTransferRateLimitList(ctx context.Context) ([]TransferRateLimit, error)                                                                //perm:read
+	BoostTransferRateLimitSet(ctx context.Context, limit TransferRateLimit) error                                                               //perm:admin
+	BoostTransferRateLimitRemove(ctx context.Context, kind string, id string) error                                                             //perm:admin
 
 	// MethodGroup: Blockstore
 	BlockstoreGet(ctx context.Context, c cid.Cid) ([]byte, error)  //perm:read
@@ -77,6 +77,22 @@ type BoostStruct struct {
 
 		BoostOfflineDealWithData func(p0 context.Context, p1 uuid.UUID, p2 string, p3 bool) (*ProviderDealRejectionInfo, error) `perm:"admin"`
 
+		BoostQuotaGet func(p0 context.Context, p1 string, p2 string) (*ClientQuota, error) `perm:"read"`
+
+		BoostQuotaList func(p0 context.Context) ([]ClientQuota, error) `perm:"read"`
+
+		BoostQuotaRemove func(p0 context.Context, p1 string, p2 string) error `perm:"admin"`
+
+		BoostQuotaSet func(p0 context.Context, p1 ClientQuota) error `perm:"admin"`
+
+		BoostRetrievalPaymentRecord func(p0 context.Context, p1 RetrievalPayment) error `perm:"admin"`
+
+		BoostTransferRateLimitList func(p0 context.Context) ([]TransferRateLimit, error) `perm:"read"`
+
+		BoostTransferRateLimitRemove func(p0 context.Context, p1 string, p2 string) error `perm:"admin"`
+
+		BoostTransferRateLimitSet func(p0 context.Context, p1 TransferRateLimit) error `perm:"admin"`
+
 		DealsConsiderOfflineRetrievalDeals func(p0 context.Context) (bool, error) `perm:"admin"`
 
 		DealsConsiderOfflineStorageDeals func(p0 context.Context) (bool, error) `perm:"admin"`
@@ -492,6 +508,94 @@ func (s *BoostStub) BoostOfflineDealWithData(p0 context.Context, p1 uuid.UUID, p
 	return nil, ErrNotSupported
 }
 
+func (s *BoostStruct) BoostQuotaGet(p0 context.Context, p1 string, p2 string) (*ClientQuota, error) {
+	if s.Internal.BoostQuotaGet == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.BoostQuotaGet(p0, p1, p2)
+}
+
+func (s *BoostStub) BoostQuotaGet(p0 context.Context, p1 string, p2 string) (*ClientQuota, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *BoostStruct) BoostQuotaList(p0 context.Context) ([]ClientQuota, error) {
+	if s.Internal.BoostQuotaList == nil {
+		return *new([]ClientQuota), ErrNotSupported
+	}
+	return s.Internal.BoostQuotaList(p0)
+}
+
+func (s *BoostStub) BoostQuotaList(p0 context.Context) ([]ClientQuota, error) {
+	return *new([]ClientQuota), ErrNotSupported
+}
+
+func (s *BoostStruct) BoostQuotaRemove(p0 context.Context, p1 string, p2 string) error {
+	if s.Internal.BoostQuotaRemove == nil {
+		return ErrNotSupported
+	}
+	return s.Internal.BoostQuotaRemove(p0, p1, p2)
+}
+
+func (s *BoostStub) BoostQuotaRemove(p0 context.Context, p1 string, p2 string) error {
+	return ErrNotSupported
+}
+
+func (s *BoostStruct) BoostQuotaSet(p0 context.Context, p1 ClientQuota) error {
+	if s.Internal.BoostQuotaSet == nil {
+		return ErrNotSupported
+	}
+	return s.Internal.BoostQuotaSet(p0, p1)
+}
+
+func (s *BoostStub) BoostQuotaSet(p0 context.Context, p1 ClientQuota) error {
+	return ErrNotSupported
+}
+
+func (s *BoostStruct) BoostRetrievalPaymentRecord(p0 context.Context, p1 RetrievalPayment) error {
+	if s.Internal.BoostRetrievalPaymentRecord == nil {
+		return ErrNotSupported
+	}
+	return s.Internal.BoostRetrievalPaymentRecord(p0, p1)
+}
+
+func (s *BoostStub) BoostRetrievalPaymentRecord(p0 context.Context, p1 RetrievalPayment) error {
+	return ErrNotSupported
+}
+
+func (s *BoostStruct) BoostTransferRateLimitList(p0 context.Context) ([]TransferRateLimit, error) {
+	if s.Internal.BoostTransferRateLimitList == nil {
+		return *new([]TransferRateLimit), ErrNotSupported
+	}
+	return s.Internal.BoostTransferRateLimitList(p0)
+}
+
+func (s *BoostStub) BoostTransferRateLimitList(p0 context.Context) ([]TransferRateLimit, error) {
+	return *new([]TransferRateLimit), ErrNotSupported
+}
+
+func (s *BoostStruct) BoostTransferRateLimitRemove(p0 context.Context, p1 string, p2 string) error {
+	if s.Internal.BoostTransferRateLimitRemove == nil {
+		return ErrNotSupported
+	}
+	return s.Internal.BoostTransferRateLimitRemove(p0, p1, p2)
+}
+
+func (s *BoostStub) BoostTransferRateLimitRemove(p0 context.Context, p1 string, p2 string) error {
+	return ErrNotSupported
+}
+
+func (s *BoostStruct) BoostTransferRateLimitSet(p0 context.Context, p1 TransferRateLimit) error {
+	if s.Internal.BoostTransferRateLimitSet == nil {
+		return ErrNotSupported
+	}
+	return s.Internal.BoostTransferRateLimitSet(p0, p1)
+}
+
+func (s *BoostStub) BoostTransferRateLimitSet(p0 context.Context, p1 TransferRateLimit) error {
+	return ErrNotSupported
+}
+
 func (s *BoostStruct) DealsConsiderOfflineRetrievalDeals(p0 context.Context) (bool, error) {
 	if s.Internal.DealsConsiderOfflineRetrievalDeals == nil {
 		return false, ErrNotSupported
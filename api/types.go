@@ -203,3 +203,72 @@ type SealingPipelineState struct {
 	MaxSealingSectors         uint64
 	MaxSealingSectorsForDeals uint64
 }
+
+// RetrievalPayment is a nitro payment voucher that was accepted as payment
+// for a retrieval
+type RetrievalPayment struct {
+	// The nitro payment channel that the payment was made on
+	ChannelID string
+	// The address of the payer (eg the signer of the nitro voucher)
+	Payer string
+	// The transport the content was retrieved over (eg "http")
+	Transport string
+	// The amount the voucher paid
+	Amount abi.TokenAmount
+	// The total amount paid on the channel, including this voucher
+	Total abi.TokenAmount
+	// The root CID of the content that was paid for
+	PayloadCID cid.Cid
+	// The piece containing the content (if known)
+	PieceCID *cid.Cid
+	// The number of bytes sent in the response that the voucher paid for
+	BytesSent uint64
+}
+
+// ClientQuota limits the storage deals that are accepted from a client
+// wallet address or peer. A limit of zero means there is no limit.
+type ClientQuota struct {
+	// The kind of quota: "client" (wallet address) or "peer" (libp2p peer ID)
+	Kind string
+	// The client address or peer ID, or "*" for the quota that applies to
+	// clients without their own quota
+	ID string
+	// The maximum number of bytes of deal data in the staging area
+	MaxBytesInFlight uint64
+	// The maximum number of deals accepted in an hour
+	MaxDealsPerHour uint64
+	// The maximum number of data transfers in progress at once
+	MaxConcurrentTransfers uint64
+	// The client's current usage of the quota (nil for the "*" quota)
+	Usage *ClientQuotaUsage
+}
+
+// ClientQuotaUsage is the amount of its quota that a client is using
+type ClientQuotaUsage struct {
+	BytesInFlight       uint64
+	DealsLastHour       uint64
+	ConcurrentTransfers uint64
+}
+
+// TransferRateLimit limits the bandwidth of inbound storage deal transfers.
+// Limits set through the API apply until boostd is restarted.
+type TransferRateLimit struct {
+	// The kind of limit: "global" (all transfers together), "host" (the
+	// transfers from each host) or "client" (the transfers for deals from
+	// each client address)
+	Kind string
+	// The host or client address, or "*" for the limit that applies to
+	// hosts or clients without their own limit. Empty for the global limit.
+	ID string
+	// The maximum bytes per second (zero means no limit)
+	BytesPerSecond uint64
+	// Whether the limit is set for this ID, as opposed to being inherited
+	// from the "*" limit. Ignored when setting a limit.
+	Explicit bool
+	// The number of active transfers that the limit applies to. Ignored
+	// when setting a limit.
+	Transfers int
+	// The average bytes per second received over the last few seconds by
+	// the transfers that the limit applies to. Ignored when setting a limit.
+	Rate uint64
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"math"
 
 	"github.com/ipfs/boxo/blockservice"
 	blockstore "github.com/ipfs/boxo/blockstore"
@@ -50,7 +51,21 @@ func (s *CarOffsetWriter) Write(ctx context.Context, w io.Writer, writeOffset ui
 		return err
 	}
 
-	return s.writeBlocks(ctx, w, headerSize, writeOffset)
+	_, err = s.writeBlocks(ctx, w, headerSize, writeOffset)
+	return err
+}
+
+// Size returns the size of the CAR file. It walks the whole DAG, so that
+// the block info cache is populated and subsequent writes from an offset
+// can skip over blocks before the offset without reading them.
+func (s *CarOffsetWriter) Size(ctx context.Context) (uint64, error) {
+	headerSize, err := car.HeaderSize(&s.header)
+	if err != nil {
+		return 0, fmt.Errorf("failed to size car header: %w", err)
+	}
+
+	// Walk the DAG without writing any blocks
+	return s.writeBlocks(ctx, io.Discard, headerSize, math.MaxUint64)
 }
 
 // writeHeader writes the header to the writer, starting from writeOffset
@@ -78,8 +93,8 @@ func (s *CarOffsetWriter) writeHeader(w io.Writer, writeOffset uint64) (uint64,
 
 // writeBlocks does a depth first search of the blocks in the blockstore,
 // starting at the root, and writes the blocks to the writer, starting from
-// writeOffset
-func (s *CarOffsetWriter) writeBlocks(ctx context.Context, w io.Writer, headerSize uint64, writeOffset uint64) error {
+// writeOffset. It returns the offset of the end of the last block.
+func (s *CarOffsetWriter) writeBlocks(ctx context.Context, w io.Writer, headerSize uint64, writeOffset uint64) (uint64, error) {
 	// The first block's offset is the size of the header
 	offset := headerSize
 
@@ -147,7 +162,10 @@ func (s *CarOffsetWriter) writeBlocks(ctx context.Context, w io.Writer, headerSi
 	}
 
 	seen := cid.NewSet()
-	return merkledag.Walk(ctx, nextCid, s.payloadCid, seen.Visit)
+	if err := merkledag.Walk(ctx, nextCid, s.payloadCid, seen.Visit); err != nil {
+		return 0, err
+	}
+	return offset, nil
 }
 
 // Write data to the writer, skipping the first skip bytes
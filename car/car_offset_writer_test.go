@@ -101,6 +101,16 @@ func TestCarOffsetWriter(t *testing.T) {
 	fullCar := fullBuff.Bytes()
 	header := carHeader(nd.Cid())
 	headerSize, err := car.HeaderSize(&header)
+	require.NoError(t, err)
+
+	// The size of the CAR should be the same whether or not the block info
+	// cache has been populated
+	carSize, err := NewCarOffsetWriter(payloadCid, bs, NewBlockInfoCache()).Size(context.Background())
+	require.NoError(t, err)
+	require.EqualValues(t, len(fullCar), carSize)
+	carSize, err = fullCarCow.Size(context.Background())
+	require.NoError(t, err)
+	require.EqualValues(t, len(fullCar), carSize)
 
 	testCases := []struct {
 		name   string
@@ -170,6 +180,15 @@ func TestCarOffsetWriter(t *testing.T) {
 		return fullCarCow
 	})
 
+	// Run tests with a CarOffsetWriter that has already been used to
+	// calculate the size of the CAR
+	runTestCases("sized car offset writer", func() *CarOffsetWriter {
+		sizedCow := NewCarOffsetWriter(payloadCid, bs, NewBlockInfoCache())
+		_, err := sizedCow.Size(context.Background())
+		require.NoError(t, err)
+		return sizedCow
+	})
+
 	// Run tests with a CarOffsetWriter that has already been used to write
 	// a CAR repeatedly
 	runTestCases("car offset writer written from offset repeatedly", func() *CarOffsetWriter {
@@ -26,6 +26,17 @@ type Node struct {
 }
 
 func Setup(cfgdir string) (*Node, error) {
+	return setup(cfgdir, "libp2p.key", "/ip4/0.0.0.0/tcp/0")
+}
+
+// SetupServe creates the node for `boost serve`. The node's host listens on
+// the given multiaddr, and has its own identity so that it doesn't clash
+// with the host of other boost commands running at the same time.
+func SetupServe(cfgdir string, listenAddr string) (*Node, error) {
+	return setup(cfgdir, "serve-libp2p.key", listenAddr)
+}
+
+func setup(cfgdir string, keyFile string, listenAddr string) (*Node, error) {
 	cfgdir, err := homedir.Expand(cfgdir)
 	if err != nil {
 		return nil, fmt.Errorf("getting homedir: %w", err)
@@ -36,13 +47,13 @@ func Setup(cfgdir string) (*Node, error) {
 		return nil, errors.New("repo dir doesn't exist. run `boost init` first.")
 	}
 
-	peerkey, err := loadOrInitPeerKey(keyPath(cfgdir))
+	peerkey, err := loadOrInitPeerKey(filepath.Join(cfgdir, keyFile))
 	if err != nil {
 		return nil, err
 	}
 
 	h, err := libp2p.New(
-		libp2p.ListenAddrStrings("/ip4/0.0.0.0/tcp/0"),
+		libp2p.ListenAddrStrings(listenAddr),
 		libp2p.Identity(peerkey),
 	)
 	if err != nil {
@@ -112,10 +123,6 @@ func setupWallet(dir string) (*wallet.LocalWallet, error) {
 	return wallet, nil
 }
 
-func keyPath(baseDir string) string {
-	return filepath.Join(baseDir, "libp2p.key")
-}
-
 func walletPath(baseDir string) string {
 	return filepath.Join(baseDir, "wallet")
 }
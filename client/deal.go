@@ -60,6 +60,24 @@ func (c *StorageClient) StorageDeal(ctx context.Context, params types.DealParams
 	}, nil
 }
 
+// StorageDealBatch sends a batch of deal proposals to the provider in a single
+// request, and returns the response for each deal in the same order
+func (c *StorageClient) StorageDealBatch(ctx context.Context, params []types.DealParams, providerID peer.ID) ([]*api.ProviderDealRejectionInfo, error) {
+	resp, err := c.dealClient.SendDealBatchProposal(ctx, providerID, params)
+	if err != nil {
+		return nil, fmt.Errorf("sending deal batch proposal: %w", err)
+	}
+
+	ris := make([]*api.ProviderDealRejectionInfo, 0, len(resp.Responses))
+	for _, r := range resp.Responses {
+		ris = append(ris, &api.ProviderDealRejectionInfo{
+			Accepted: r.Accepted,
+			Reason:   r.Message,
+		})
+	}
+	return ris, nil
+}
+
 func (c *StorageClient) DealStatus(ctx context.Context, providerID peer.ID, dealUUid uuid.UUID) (*types.DealStatusResponse, error) {
 	// Send the deal proposal to the provider
 	return c.dealClient.SendDealStatusRequest(ctx, providerID, dealUUid)
@@ -0,0 +1,311 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/storagemarket"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	sealing "github.com/filecoin-project/lotus/storage/pipeline"
+	"github.com/google/uuid"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("boost-client")
+
+// DealProposer sends deal proposals and deal status requests to storage
+// providers on behalf of the DealManager
+type DealProposer interface {
+	// SendProposal sends the deal proposal to the provider in the proposal
+	SendProposal(ctx context.Context, params types.DealParams) (*types.DealResponse, error)
+	// SignProposal signs the deal proposal with the client's wallet
+	SignProposal(ctx context.Context, proposal market.DealProposal) (*market.ClientDealProposal, error)
+	// DealStatus gets the status of the deal from the provider
+	DealStatus(ctx context.Context, deal *db.ClientDeal) (*types.DealStatusResponse, error)
+}
+
+type DealManagerConfig struct {
+	// The maximum number of times to send a proposal to each provider
+	MaxAttempts int
+	// The delay before a rejected proposal is sent to the same provider again
+	RetryDelay time.Duration
+	// The providers to propose a deal to, in order, if it is rejected by
+	// the provider it was first proposed to
+	FallbackProviders []address.Address
+	// PrepareTransfer is called before each proposal is sent, including
+	// re-proposals, so that each deal can be given its own transfer
+	// parameters (eg an auth token for downloading the deal data). It may
+	// be nil.
+	PrepareTransfer func(ctx context.Context, params *types.DealParams) error
+}
+
+// DealManager keeps track of the deals that the client proposed in the
+// client database. Rejected proposals are retried, and the status of deals
+// that are in progress is refreshed from the provider.
+type DealManager struct {
+	cfg      DealManagerConfig
+	db       *db.ClientDealsDB
+	proposer DealProposer
+}
+
+func NewDealManager(cfg DealManagerConfig, cdb *db.ClientDealsDB, proposer DealProposer) *DealManager {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 1
+	}
+	return &DealManager{cfg: cfg, db: cdb, proposer: proposer}
+}
+
+// ProposeDeal sends the deal proposal to the provider and records the deal
+// in the client database. See RecordProposal for how rejected proposals are
+// handled.
+func (m *DealManager) ProposeDeal(ctx context.Context, params types.DealParams) (*db.ClientDeal, error) {
+	if err := m.prepareTransfer(ctx, &params); err != nil {
+		return nil, err
+	}
+
+	resp := m.send(ctx, params)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return m.RecordProposal(ctx, params, resp)
+}
+
+// RecordProposal records a deal proposal that was sent to a provider, along
+// with the provider's response. If the proposal was rejected, it is sent to
+// the same provider again until MaxAttempts is reached, and then to each of
+// the fallback providers in turn. A new deal (with a new uuid) is recorded
+// for each attempt. It returns the accepted deal, or the last rejected deal
+// if no provider accepted the proposal.
+func (m *DealManager) RecordProposal(ctx context.Context, params types.DealParams, resp *types.DealResponse) (*db.ClientDeal, error) {
+	deal := newClientDeal(params, 1, nil, resp)
+	if err := m.db.Insert(ctx, deal); err != nil {
+		return nil, fmt.Errorf("recording deal %s: %w", deal.ID, err)
+	}
+	if resp.Accepted {
+		return deal, nil
+	}
+	return m.repropose(ctx, deal)
+}
+
+func (m *DealManager) repropose(ctx context.Context, deal *db.ClientDeal) (*db.ClientDeal, error) {
+	providers := []address.Address{deal.ProviderAddress}
+	for _, p := range m.cfg.FallbackProviders {
+		if p != deal.ProviderAddress {
+			providers = append(providers, p)
+		}
+	}
+
+	params := deal.Params
+	provider := 0
+	attempts := 1
+	for {
+		if attempts < m.cfg.MaxAttempts {
+			select {
+			case <-ctx.Done():
+				return deal, ctx.Err()
+			case <-time.After(m.cfg.RetryDelay):
+			}
+		} else {
+			// Move on to the next provider
+			provider++
+			if provider == len(providers) {
+				return deal, nil
+			}
+			attempts = 0
+
+			// The proposal is signed for a specific provider, so it must be
+			// re-signed for the next provider
+			prop := params.ClientDealProposal.Proposal
+			prop.Provider = providers[provider]
+			signed, err := m.proposer.SignProposal(ctx, prop)
+			if err != nil {
+				return deal, fmt.Errorf("signing deal proposal for provider %s: %w", prop.Provider, err)
+			}
+			params.ClientDealProposal = *signed
+		}
+
+		attempts++
+		params.DealUUID = uuid.New()
+		if err := m.prepareTransfer(ctx, &params); err != nil {
+			return deal, err
+		}
+		log.Infow("re-proposing rejected deal", "previous deal", deal.ID, "deal", params.DealUUID,
+			"provider", providers[provider], "reason", deal.Message)
+
+		resp := m.send(ctx, params)
+		if ctx.Err() != nil {
+			return deal, ctx.Err()
+		}
+
+		prev := deal.ID
+		deal = newClientDeal(params, deal.Attempt+1, &prev, resp)
+		if err := m.db.Insert(ctx, deal); err != nil {
+			return nil, fmt.Errorf("recording deal %s: %w", deal.ID, err)
+		}
+		if resp.Accepted {
+			return deal, nil
+		}
+	}
+}
+
+// ProposeReplica sends the deal proposal for a copy of the replication's data
+// to the provider, and records the deal in the client database. Rejected
+// proposals are not retried: the Replicator proposes the copy to the next
+// candidate provider instead.
+func (m *DealManager) ProposeReplica(ctx context.Context, params types.DealParams, replicationID uuid.UUID) (*db.ClientDeal, error) {
+	resp := m.send(ctx, params)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	deal := newClientDeal(params, 1, nil, resp)
+	deal.ReplicationID = &replicationID
+	if err := m.db.Insert(ctx, deal); err != nil {
+		return nil, fmt.Errorf("recording deal %s: %w", deal.ID, err)
+	}
+	return deal, nil
+}
+
+func (m *DealManager) prepareTransfer(ctx context.Context, params *types.DealParams) error {
+	if m.cfg.PrepareTransfer == nil {
+		return nil
+	}
+	if err := m.cfg.PrepareTransfer(ctx, params); err != nil {
+		return fmt.Errorf("preparing transfer for deal %s: %w", params.DealUUID, err)
+	}
+	return nil
+}
+
+// send sends the proposal to the provider. If the proposal can't be sent,
+// it returns a rejection with the error, so that sending is retried in the
+// same way as a rejected proposal.
+func (m *DealManager) send(ctx context.Context, params types.DealParams) *types.DealResponse {
+	resp, err := m.proposer.SendProposal(ctx, params)
+	if err != nil {
+		return &types.DealResponse{Message: fmt.Sprintf("sending deal proposal: %s", err)}
+	}
+	return resp
+}
+
+func newClientDeal(params types.DealParams, attempt int, prev *uuid.UUID, resp *types.DealResponse) *db.ClientDeal {
+	prop := params.ClientDealProposal.Proposal
+	deal := &db.ClientDeal{
+		ID:               params.DealUUID,
+		ProviderAddress:  prop.Provider,
+		ClientAddress:    prop.Client,
+		PieceCID:         prop.PieceCID,
+		PieceSize:        prop.PieceSize,
+		PayloadCID:       params.DealDataRoot,
+		IsOffline:        params.IsOffline,
+		Verified:         prop.VerifiedDeal,
+		StartEpoch:       prop.StartEpoch,
+		EndEpoch:         prop.EndEpoch,
+		Params:           params,
+		Status:           dealcheckpoints.Accepted.String(),
+		Attempt:          attempt,
+		PreviousDealUUID: prev,
+	}
+	if !resp.Accepted {
+		deal.Status = db.ClientDealStatusRejected
+		deal.Message = resp.Message
+	}
+	return deal
+}
+
+// Refresh gets the status of each deal that is in progress from its
+// provider, and updates the deal in the client database
+func (m *DealManager) Refresh(ctx context.Context) error {
+	deals, err := m.db.ListActive(ctx)
+	if err != nil {
+		return fmt.Errorf("listing active deals: %w", err)
+	}
+
+	for _, deal := range deals {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := m.refreshDeal(ctx, deal); err != nil {
+			log.Warnw("refreshing deal status", "id", deal.ID, "provider", deal.ProviderAddress, "err", err)
+		}
+	}
+	return nil
+}
+
+func (m *DealManager) refreshDeal(ctx context.Context, deal *db.ClientDeal) error {
+	resp, err := m.proposer.DealStatus(ctx, deal)
+	if err != nil {
+		return fmt.Errorf("getting deal status: %w", err)
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("provider returned deal status error: %s", resp.Error)
+	}
+	if resp.DealStatus == nil {
+		return fmt.Errorf("provider returned empty deal status")
+	}
+
+	ds := resp.DealStatus
+	deal.Status = ds.Status
+	deal.Message = ds.Error
+	deal.SealingStatus = ds.SealingStatus
+	deal.ChainDealID = ds.ChainDealID
+	deal.PublishCID = ds.PublishCid
+
+	// Once the provider has handed the deal off to the sealer, keep
+	// refreshing the deal until the sector holding the deal data is sealed
+	if deal.Status == db.ClientDealStatusComplete && deal.Message == "" {
+		switch {
+		case isSealedState(ds.SealingStatus):
+			deal.Sealed = true
+		case isRemovedState(ds.SealingStatus):
+			deal.Message = fmt.Sprintf("sealing failed: sector is in state %s", ds.SealingStatus)
+		}
+	}
+	return m.db.Update(ctx, deal)
+}
+
+// isSealedState returns true if a sector in this state holds sealed data
+func isSealedState(state string) bool {
+	switch sealing.SectorState(state) {
+	case sealing.Proving, sealing.Available, sealing.UpdateActivating, sealing.ReleaseSectorKey:
+		return true
+	}
+	return false
+}
+
+// isRemovedState returns true if a sector in this state no longer holds the
+// deal data, or the deal was not found in the sector
+func isRemovedState(state string) bool {
+	if state == storagemarket.ErrDealNotFound.Error() {
+		return true
+	}
+	switch sealing.SectorState(state) {
+	case sealing.Removing, sealing.Removed, sealing.Terminating, sealing.TerminateWait,
+		sealing.TerminateFinality, sealing.TerminateFailed:
+		return true
+	}
+	return false
+}
+
+// Run refreshes the status of deals that are in progress every interval,
+// until the context is cancelled
+func (m *DealManager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Warnw("refreshing deal statuses", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
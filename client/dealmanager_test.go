@@ -0,0 +1,225 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type mockProposer struct {
+	// The providers that accept proposals
+	accept map[address.Address]bool
+	// The providers that proposals were sent to, in order
+	sent     []address.Address
+	statuses map[uuid.UUID]*types.DealStatusResponse
+}
+
+func (p *mockProposer) SendProposal(ctx context.Context, params types.DealParams) (*types.DealResponse, error) {
+	provider := params.ClientDealProposal.Proposal.Provider
+	p.sent = append(p.sent, provider)
+	if p.accept[provider] {
+		return &types.DealResponse{Accepted: true}, nil
+	}
+	return &types.DealResponse{Message: "no space"}, nil
+}
+
+func (p *mockProposer) SignProposal(ctx context.Context, proposal market.DealProposal) (*market.ClientDealProposal, error) {
+	return &market.ClientDealProposal{
+		Proposal:        proposal,
+		ClientSignature: crypto.Signature{Type: crypto.SigTypeSecp256k1, Data: []byte("sig")},
+	}, nil
+}
+
+func (p *mockProposer) DealStatus(ctx context.Context, deal *db.ClientDeal) (*types.DealStatusResponse, error) {
+	resp, ok := p.statuses[deal.ID]
+	if !ok {
+		return nil, fmt.Errorf("deal %s not found", deal.ID)
+	}
+	return resp, nil
+}
+
+func newTestDealManager(t *testing.T, cfg DealManagerConfig, p DealProposer) (*DealManager, *db.ClientDealsDB) {
+	ctx := context.Background()
+	sqldb := db.CreateTestTmpDB(t)
+	require.NoError(t, db.CreateClientTables(ctx, sqldb))
+	cdb := db.NewClientDealsDB(sqldb)
+	return NewDealManager(cfg, cdb, p), cdb
+}
+
+func testDealParams(t *testing.T, provider address.Address) types.DealParams {
+	client, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	return types.DealParams{
+		DealUUID: uuid.New(),
+		ClientDealProposal: market.ClientDealProposal{
+			Proposal: market.DealProposal{
+				PieceCID:  testutil.GenerateCid(),
+				PieceSize: 2048,
+				Client:    client,
+				Provider:  provider,
+			},
+			ClientSignature: crypto.Signature{Type: crypto.SigTypeSecp256k1, Data: []byte("sig")},
+		},
+		DealDataRoot: testutil.GenerateCid(),
+		IsOffline:    true,
+	}
+}
+
+func TestDealManagerRepropose(t *testing.T) {
+	ctx := context.Background()
+	sp1, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+	sp2, err := address.NewIDAddress(1002)
+	require.NoError(t, err)
+	sp3, err := address.NewIDAddress(1003)
+	require.NoError(t, err)
+
+	p := &mockProposer{accept: map[address.Address]bool{sp3: true}}
+	mgr, cdb := newTestDealManager(t, DealManagerConfig{
+		MaxAttempts:       2,
+		FallbackProviders: []address.Address{sp2, sp3},
+	}, p)
+
+	params := testDealParams(t, sp1)
+	deal, err := mgr.ProposeDeal(ctx, params)
+	require.NoError(t, err)
+
+	// The proposal is sent twice to each provider until the third provider
+	// accepts it
+	require.Equal(t, []address.Address{sp1, sp1, sp2, sp2, sp3}, p.sent)
+	require.Equal(t, dealcheckpoints.Accepted.String(), deal.Status)
+	require.Equal(t, sp3, deal.ProviderAddress)
+	require.Equal(t, sp3, deal.Params.ClientDealProposal.Proposal.Provider)
+	require.Equal(t, 5, deal.Attempt)
+	require.NotEqual(t, params.DealUUID, deal.ID)
+
+	// Each attempt is recorded, linked to the previous attempt
+	deals, err := cdb.List(ctx, db.ClientDealStatusRejected)
+	require.NoError(t, err)
+	require.Len(t, deals, 4)
+	prev := deal.PreviousDealUUID
+	for i := 0; i < 4; i++ {
+		require.NotNil(t, prev)
+		d, err := cdb.ByID(ctx, *prev)
+		require.NoError(t, err)
+		require.Equal(t, db.ClientDealStatusRejected, d.Status)
+		require.Equal(t, "no space", d.Message)
+		prev = d.PreviousDealUUID
+	}
+	require.Nil(t, prev)
+
+	// If no provider accepts the proposal, the last rejected deal is returned
+	p.accept = nil
+	p.sent = nil
+	deal, err = mgr.ProposeDeal(ctx, testDealParams(t, sp1))
+	require.NoError(t, err)
+	require.Len(t, p.sent, 6)
+	require.Equal(t, db.ClientDealStatusRejected, deal.Status)
+	require.Equal(t, sp3, deal.ProviderAddress)
+}
+
+func TestDealManagerRefresh(t *testing.T) {
+	ctx := context.Background()
+	sp, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+
+	p := &mockProposer{
+		accept:   map[address.Address]bool{sp: true},
+		statuses: make(map[uuid.UUID]*types.DealStatusResponse),
+	}
+	mgr, cdb := newTestDealManager(t, DealManagerConfig{}, p)
+
+	deal, err := mgr.ProposeDeal(ctx, testDealParams(t, sp))
+	require.NoError(t, err)
+
+	publishCid := testutil.GenerateCid()
+	p.statuses[deal.ID] = &types.DealStatusResponse{
+		DealUUID: deal.ID,
+		DealStatus: &types.DealStatus{
+			Status:      dealcheckpoints.Published.String(),
+			PublishCid:  &publishCid,
+			ChainDealID: 5,
+		},
+	}
+	require.NoError(t, mgr.Refresh(ctx))
+
+	stored, err := cdb.ByID(ctx, deal.ID)
+	require.NoError(t, err)
+	require.Equal(t, dealcheckpoints.Published.String(), stored.Status)
+	require.EqualValues(t, 5, stored.ChainDealID)
+	require.Equal(t, publishCid, *stored.PublishCID)
+
+	// Once the deal is complete it is no longer refreshed
+	p.statuses[deal.ID].DealStatus.Status = dealcheckpoints.Complete.String()
+	p.statuses[deal.ID].DealStatus.Error = "sealing failed"
+	require.NoError(t, mgr.Refresh(ctx))
+	active, err := cdb.ListActive(ctx)
+	require.NoError(t, err)
+	require.Empty(t, active)
+
+	history, err := cdb.History(ctx, deal.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	require.Equal(t, "sealing failed", history[2].Message)
+}
+
+func TestDealManagerRefreshSealing(t *testing.T) {
+	ctx := context.Background()
+	sp, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+
+	p := &mockProposer{
+		accept:   map[address.Address]bool{sp: true},
+		statuses: make(map[uuid.UUID]*types.DealStatusResponse),
+	}
+	mgr, cdb := newTestDealManager(t, DealManagerConfig{}, p)
+
+	sealed, err := mgr.ProposeDeal(ctx, testDealParams(t, sp))
+	require.NoError(t, err)
+	removed, err := mgr.ProposeDeal(ctx, testDealParams(t, sp))
+	require.NoError(t, err)
+
+	setStatus := func(dealUuid uuid.UUID, sealingStatus string) {
+		p.statuses[dealUuid] = &types.DealStatusResponse{
+			DealUUID: dealUuid,
+			DealStatus: &types.DealStatus{
+				Status:        dealcheckpoints.Complete.String(),
+				SealingStatus: sealingStatus,
+			},
+		}
+	}
+
+	// Complete deals are refreshed until the sector is sealed
+	setStatus(sealed.ID, "PreCommit1")
+	setStatus(removed.ID, "PreCommit1")
+	require.NoError(t, mgr.Refresh(ctx))
+	active, err := cdb.ListActive(ctx)
+	require.NoError(t, err)
+	require.Len(t, active, 2)
+
+	setStatus(sealed.ID, "Proving")
+	setStatus(removed.ID, "Removed")
+	require.NoError(t, mgr.Refresh(ctx))
+	active, err = cdb.ListActive(ctx)
+	require.NoError(t, err)
+	require.Empty(t, active)
+
+	stored, err := cdb.ByID(ctx, sealed.ID)
+	require.NoError(t, err)
+	require.True(t, stored.Sealed)
+
+	stored, err = cdb.ByID(ctx, removed.ID)
+	require.NoError(t, err)
+	require.False(t, stored.Sealed)
+	require.Contains(t, stored.Message, "Removed")
+}
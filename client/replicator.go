@@ -0,0 +1,324 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+)
+
+// ProviderInfo is the storage ask and retrieval transports of a candidate
+// provider for a copy of a replication's data
+type ProviderInfo struct {
+	Address address.Address
+	// The storage price in attoFIL per GiB per epoch
+	Price         abi.TokenAmount
+	VerifiedPrice abi.TokenAmount
+	MinPieceSize  abi.PaddedPieceSize
+	MaxPieceSize  abi.PaddedPieceSize
+	// The names of the retrieval transports the provider supports
+	// (eg "http", "libp2p", "bitswap")
+	Transports []string
+}
+
+// ReplicationNode provides the chain and network access that the
+// Replicator needs
+type ReplicationNode interface {
+	// ChainHeight returns the height of the chain head
+	ChainHeight(ctx context.Context) (abi.ChainEpoch, error)
+	// QueryProvider gets the provider's storage ask and retrieval transports
+	QueryProvider(ctx context.Context, provider address.Address) (*ProviderInfo, error)
+	// BuildDeal creates a signed deal proposal for a copy of the
+	// replication's data with the provider, at the given price per epoch
+	BuildDeal(ctx context.Context, r *db.ClientReplication, provider address.Address, pricePerEpoch abi.TokenAmount) (*types.DealParams, error)
+}
+
+// ReplicaState is the state of a copy of a replication's data
+type ReplicaState string
+
+const (
+	// The deal is in progress, and has not yet been published
+	ReplicaProposed ReplicaState = "Proposed"
+	// The deal has been published on chain
+	ReplicaPublished ReplicaState = "Published"
+	// The deal data has been sealed into a sector
+	ReplicaSealed ReplicaState = "Sealed"
+	// The deal was rejected by the provider
+	ReplicaRejected ReplicaState = "Rejected"
+	// The deal failed, or was not published before its start epoch
+	ReplicaFailed ReplicaState = "Failed"
+	// The deal's end epoch has passed
+	ReplicaExpired ReplicaState = "Expired"
+)
+
+// Live returns true if the replica holds a copy of the data, or is on its way
+// to holding one
+func (s ReplicaState) Live() bool {
+	return s == ReplicaProposed || s == ReplicaPublished || s == ReplicaSealed
+}
+
+// Replica is a deal for a copy of a replication's data
+type Replica struct {
+	Deal  *db.ClientDeal
+	State ReplicaState
+}
+
+// ReplicationStatus is the state of each copy of a replication's data
+type ReplicationStatus struct {
+	Replication *db.ClientReplication
+	// Each deal made for the replication, oldest first
+	Replicas []Replica
+	// The reason each candidate provider that was skipped in the last
+	// round of proposals could not be used
+	Skipped map[address.Address]string
+}
+
+// Count returns the number of replicas in one of the given states
+func (s *ReplicationStatus) Count(states ...ReplicaState) int {
+	count := 0
+	for _, r := range s.Replicas {
+		for _, st := range states {
+			if r.State == st {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// Live returns the number of replicas that hold, or are on their way to
+// holding, a copy of the data
+func (s *ReplicationStatus) Live() int {
+	return s.Count(ReplicaProposed, ReplicaPublished, ReplicaSealed)
+}
+
+// Replicator stores copies of data with several storage providers. It
+// proposes deals to candidate providers until the required number of deals
+// are accepted, and proposes new deals when a copy fails or expires.
+type Replicator struct {
+	db    *db.ClientDealsDB
+	deals *DealManager
+	node  ReplicationNode
+}
+
+func NewReplicator(cdb *db.ClientDealsDB, proposer DealProposer, node ReplicationNode) *Replicator {
+	return &Replicator{
+		db:    cdb,
+		deals: NewDealManager(DealManagerConfig{}, cdb, proposer),
+		node:  node,
+	}
+}
+
+// Status gets the state of each copy of the replication's data
+func (r *Replicator) Status(ctx context.Context, rep *db.ClientReplication) (*ReplicationStatus, error) {
+	height, err := r.node.ChainHeight(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting chain height: %w", err)
+	}
+	return r.status(ctx, rep, height)
+}
+
+func (r *Replicator) status(ctx context.Context, rep *db.ClientReplication, height abi.ChainEpoch) (*ReplicationStatus, error) {
+	deals, err := r.db.ListByReplication(ctx, rep.ID)
+	if err != nil {
+		return nil, fmt.Errorf("listing deals for replication %s: %w", rep.ID, err)
+	}
+
+	st := &ReplicationStatus{
+		Replication: rep,
+		Replicas:    make([]Replica, 0, len(deals)),
+		Skipped:     make(map[address.Address]string),
+	}
+	for _, deal := range deals {
+		st.Replicas = append(st.Replicas, Replica{Deal: deal, State: replicaState(deal, height)})
+	}
+	return st, nil
+}
+
+func replicaState(deal *db.ClientDeal, height abi.ChainEpoch) ReplicaState {
+	if deal.Status == db.ClientDealStatusRejected {
+		return ReplicaRejected
+	}
+	if deal.Status == db.ClientDealStatusComplete && deal.Message != "" {
+		return ReplicaFailed
+	}
+	if height >= deal.EndEpoch {
+		return ReplicaExpired
+	}
+	if deal.Sealed {
+		return ReplicaSealed
+	}
+	cp, err := dealcheckpoints.FromString(deal.Status)
+	if err == nil && cp >= dealcheckpoints.Published {
+		return ReplicaPublished
+	}
+	// A deal that has not been published by its start epoch will be failed
+	// by the provider
+	if height >= deal.StartEpoch {
+		return ReplicaFailed
+	}
+	return ReplicaProposed
+}
+
+// Replicate proposes deals for copies of the replication's data to
+// candidate providers, until there are as many live copies as the
+// replication requires or there are no more candidates. Providers that
+// already hold a live copy are not proposed another one.
+func (r *Replicator) Replicate(ctx context.Context, rep *db.ClientReplication) (*ReplicationStatus, error) {
+	height, err := r.node.ChainHeight(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting chain height: %w", err)
+	}
+
+	st, err := r.status(ctx, rep, height)
+	if err != nil {
+		return nil, err
+	}
+	if !rep.Active {
+		return st, nil
+	}
+
+	holders := make(map[address.Address]struct{}, len(st.Replicas))
+	for _, replica := range st.Replicas {
+		if replica.State.Live() {
+			holders[replica.Deal.ProviderAddress] = struct{}{}
+		}
+	}
+
+	for _, provider := range rep.Providers {
+		if st.Live() >= rep.Copies {
+			break
+		}
+		if _, ok := holders[provider]; ok {
+			continue
+		}
+
+		deal, err := r.proposeReplica(ctx, rep, provider)
+		if err != nil {
+			if ctx.Err() != nil {
+				return st, ctx.Err()
+			}
+			log.Infow("skipping provider for replication", "replication", rep.ID, "provider", provider, "reason", err)
+			st.Skipped[provider] = err.Error()
+			continue
+		}
+
+		state := replicaState(deal, height)
+		st.Replicas = append(st.Replicas, Replica{Deal: deal, State: state})
+		if state.Live() {
+			holders[provider] = struct{}{}
+			log.Infow("proposed replica", "replication", rep.ID, "provider", provider, "deal", deal.ID)
+		} else {
+			st.Skipped[provider] = "deal proposal rejected: " + deal.Message
+		}
+	}
+
+	if live := st.Live(); live < rep.Copies {
+		log.Warnw("not enough providers accepted a replica", "replication", rep.ID, "copies", rep.Copies, "live", live)
+	}
+	return st, nil
+}
+
+// proposeReplica checks that the provider is suitable for the replication,
+// and proposes a deal for a copy of the data to the provider
+func (r *Replicator) proposeReplica(ctx context.Context, rep *db.ClientReplication, provider address.Address) (*db.ClientDeal, error) {
+	info, err := r.node.QueryProvider(ctx, provider)
+	if err != nil {
+		return nil, fmt.Errorf("querying provider: %w", err)
+	}
+
+	price, err := replicaPrice(rep, info)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := r.node.BuildDeal(ctx, rep, provider, price)
+	if err != nil {
+		return nil, fmt.Errorf("creating deal proposal: %w", err)
+	}
+
+	return r.deals.ProposeReplica(ctx, *params, rep.ID)
+}
+
+// replicaPrice checks that the provider's ask and retrieval transports meet
+// the replication's requirements, and returns the storage price per epoch
+// for the deal
+func replicaPrice(rep *db.ClientReplication, info *ProviderInfo) (abi.TokenAmount, error) {
+	if rep.PieceSize < info.MinPieceSize || (info.MaxPieceSize > 0 && rep.PieceSize > info.MaxPieceSize) {
+		return abi.TokenAmount{}, fmt.Errorf("piece size %d is outside the provider's range %d - %d",
+			rep.PieceSize, info.MinPieceSize, info.MaxPieceSize)
+	}
+
+	supported := make(map[string]struct{}, len(info.Transports))
+	for _, t := range info.Transports {
+		supported[t] = struct{}{}
+	}
+	for _, t := range rep.Transports {
+		if _, ok := supported[t]; !ok {
+			return abi.TokenAmount{}, fmt.Errorf("provider does not support retrieval transport %s", t)
+		}
+	}
+
+	pricePerGiB := info.Price
+	if rep.Verified {
+		pricePerGiB = info.VerifiedPrice
+	}
+	if pricePerGiB.Nil() {
+		pricePerGiB = big.Zero()
+	}
+	if rep.MaxPrice != nil && pricePerGiB.GreaterThan(*rep.MaxPrice) {
+		return abi.TokenAmount{}, fmt.Errorf("provider's price %s per GiB per epoch is more than the maximum price %s",
+			pricePerGiB, rep.MaxPrice)
+	}
+
+	// The ask price is per GiB, so multiply by the piece size and divide
+	// by 2^30 to get the price for the deal
+	return big.Div(big.Mul(pricePerGiB, big.NewInt(int64(rep.PieceSize))), big.NewInt(1<<30)), nil
+}
+
+// Check refreshes the status of deals that are in progress, and proposes new
+// deals for each active replication that does not have enough live copies
+func (r *Replicator) Check(ctx context.Context) error {
+	if err := r.deals.Refresh(ctx); err != nil {
+		return err
+	}
+
+	reps, err := r.db.Replications(ctx, true)
+	if err != nil {
+		return fmt.Errorf("listing replications: %w", err)
+	}
+	for _, rep := range reps {
+		if _, err := r.Replicate(ctx, rep); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Warnw("replicating", "replication", rep.ID, "err", err)
+		}
+	}
+	return nil
+}
+
+// Run checks replications every interval, until the context is cancelled
+func (r *Replicator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Check(ctx); err != nil && ctx.Err() == nil {
+			log.Warnw("checking replications", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
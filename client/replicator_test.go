@@ -0,0 +1,207 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type mockReplicationNode struct {
+	height    abi.ChainEpoch
+	providers map[address.Address]*ProviderInfo
+}
+
+func (n *mockReplicationNode) ChainHeight(ctx context.Context) (abi.ChainEpoch, error) {
+	return n.height, nil
+}
+
+func (n *mockReplicationNode) QueryProvider(ctx context.Context, provider address.Address) (*ProviderInfo, error) {
+	info, ok := n.providers[provider]
+	if !ok {
+		return nil, fmt.Errorf("provider %s is offline", provider)
+	}
+	return info, nil
+}
+
+func (n *mockReplicationNode) BuildDeal(ctx context.Context, r *db.ClientReplication, provider address.Address, pricePerEpoch abi.TokenAmount) (*types.DealParams, error) {
+	start := n.height + r.StartEpochOffset
+	return &types.DealParams{
+		DealUUID: uuid.New(),
+		ClientDealProposal: market.ClientDealProposal{
+			Proposal: market.DealProposal{
+				PieceCID:             r.PieceCID,
+				PieceSize:            r.PieceSize,
+				Client:               r.ClientAddress,
+				Provider:             provider,
+				StartEpoch:           start,
+				EndEpoch:             start + r.Duration,
+				StoragePricePerEpoch: pricePerEpoch,
+			},
+			ClientSignature: crypto.Signature{Type: crypto.SigTypeSecp256k1, Data: []byte("sig")},
+		},
+		DealDataRoot: r.PayloadCID,
+		IsOffline:    r.IsOffline,
+	}, nil
+}
+
+func TestReplicator(t *testing.T) {
+	ctx := context.Background()
+	sqldb := db.CreateTestTmpDB(t)
+	require.NoError(t, db.CreateClientTables(ctx, sqldb))
+	cdb := db.NewClientDealsDB(sqldb)
+
+	var sps []address.Address
+	for i := 0; i < 5; i++ {
+		sp, err := address.NewIDAddress(uint64(1001 + i))
+		require.NoError(t, err)
+		sps = append(sps, sp)
+	}
+	client, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	info := func(sp address.Address, price int64, transports ...string) *ProviderInfo {
+		return &ProviderInfo{
+			Address:      sp,
+			Price:        abi.NewTokenAmount(price),
+			MinPieceSize: 256,
+			MaxPieceSize: 1 << 20,
+			Transports:   transports,
+		}
+	}
+	node := &mockReplicationNode{
+		height: 100,
+		providers: map[address.Address]*ProviderInfo{
+			// sps[0] is offline
+			sps[1]: info(sps[1], 10, "http", "libp2p"),
+			// sps[2] doesn't support http retrievals
+			sps[2]: info(sps[2], 10, "libp2p"),
+			// sps[3] is too expensive
+			sps[3]: info(sps[3], 1000, "http"),
+			sps[4]: info(sps[4], 10, "http"),
+		},
+	}
+	proposer := &mockProposer{
+		accept:   map[address.Address]bool{sps[1]: true, sps[2]: true, sps[3]: true, sps[4]: true},
+		statuses: make(map[uuid.UUID]*types.DealStatusResponse),
+	}
+	r := NewReplicator(cdb, proposer, node)
+
+	maxPrice := abi.NewTokenAmount(100)
+	rep := &db.ClientReplication{
+		ID:               uuid.New(),
+		Copies:           2,
+		Active:           true,
+		ClientAddress:    client,
+		PieceCID:         testutil.GenerateCid(),
+		PieceSize:        2048,
+		PayloadCID:       testutil.GenerateCid(),
+		IsOffline:        true,
+		StartEpochOffset: 100,
+		Duration:         1000,
+		MaxPrice:         &maxPrice,
+		Providers:        sps,
+		Transports:       []string{"http"},
+	}
+	require.NoError(t, cdb.InsertReplication(ctx, rep))
+
+	// Only sps[1] and sps[4] are suitable
+	st, err := r.Replicate(ctx, rep)
+	require.NoError(t, err)
+	require.Equal(t, 2, st.Live())
+	require.Equal(t, []address.Address{sps[1], sps[4]}, proposer.sent)
+	require.Len(t, st.Skipped, 3)
+	require.Contains(t, st.Skipped[sps[0]], "offline")
+	require.Contains(t, st.Skipped[sps[2]], "retrieval transport http")
+	require.Contains(t, st.Skipped[sps[3]], "maximum price")
+
+	// The replication has enough copies, so no more deals are proposed
+	proposer.sent = nil
+	st, err = r.Replicate(ctx, rep)
+	require.NoError(t, err)
+	require.Empty(t, proposer.sent)
+	require.Equal(t, 2, st.Count(ReplicaProposed))
+
+	// One copy is published and sealed, the other fails
+	deals, err := cdb.ListByReplication(ctx, rep.ID)
+	require.NoError(t, err)
+	require.Len(t, deals, 2)
+	sealed, failed := deals[0], deals[1]
+	proposer.statuses[sealed.ID] = &types.DealStatusResponse{
+		DealUUID: sealed.ID,
+		DealStatus: &types.DealStatus{
+			Status:        dealcheckpoints.Complete.String(),
+			SealingStatus: "Proving",
+		},
+	}
+	proposer.statuses[failed.ID] = &types.DealStatusResponse{
+		DealUUID: failed.ID,
+		DealStatus: &types.DealStatus{
+			Status: dealcheckpoints.Complete.String(),
+			Error:  "data transfer failed",
+		},
+	}
+
+	// The failed copy is replaced by a copy with a provider that was
+	// skipped before
+	node.providers[sps[2]].Transports = append(node.providers[sps[2]].Transports, "http")
+	require.NoError(t, r.Check(ctx))
+	require.Equal(t, []address.Address{sps[2]}, proposer.sent)
+
+	st, err = r.Status(ctx, rep)
+	require.NoError(t, err)
+	require.Equal(t, 1, st.Count(ReplicaSealed))
+	require.Equal(t, 1, st.Count(ReplicaFailed))
+	require.Equal(t, 1, st.Count(ReplicaProposed))
+
+	// Once the copies expire, they are replaced
+	proposer.sent = nil
+	node.height = 100000
+	node.providers[sps[0]] = info(sps[0], 10, "http")
+	require.NoError(t, r.Check(ctx))
+	st, err = r.Status(ctx, rep)
+	require.NoError(t, err)
+	require.Equal(t, 2, st.Live())
+	require.Equal(t, []address.Address{sps[0], sps[1]}, proposer.sent)
+
+	// Inactive replications are not replicated
+	proposer.sent = nil
+	node.height = 1000000
+	require.NoError(t, cdb.SetReplicationActive(ctx, rep.ID, false))
+	require.NoError(t, r.Check(ctx))
+	require.Empty(t, proposer.sent)
+}
+
+func TestReplicaPrice(t *testing.T) {
+	rep := &db.ClientReplication{PieceSize: 1 << 30, Verified: true}
+	info := &ProviderInfo{
+		Price:         abi.NewTokenAmount(10),
+		VerifiedPrice: abi.NewTokenAmount(2),
+		MaxPieceSize:  1 << 35,
+	}
+
+	// Verified deals use the verified price
+	price, err := replicaPrice(rep, info)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, price.Int64())
+
+	rep.Verified = false
+	rep.PieceSize = 1 << 31
+	price, err = replicaPrice(rep, info)
+	require.NoError(t, err)
+	require.EqualValues(t, 20, price.Int64())
+
+	rep.PieceSize = 1 << 36
+	_, err = replicaPrice(rep, info)
+	require.ErrorContains(t, err, "piece size")
+}
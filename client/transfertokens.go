@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/boost/transport/httptransport"
+	"github.com/filecoin-project/boost/transport/httptransport/util"
+	types2 "github.com/filecoin-project/boost/transport/types"
+)
+
+// ServeTransfer creates an auth token that the provider uses to download
+// the CAR file at path from the CAR file server at serverURL, and fills in
+// the deal's transfer parameters with the URL and the token. The server URL
+// may be an http(s) URL, or a libp2p URL of the form
+// libp2p://<multiaddr>/p2p/<peer id>.
+func ServeTransfer(ctx context.Context, cdb *db.ClientDealsDB, serverURL string, path string, params *types.DealParams) error {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return fmt.Errorf("parsing server url '%s': %w", serverURL, err)
+	}
+	xferType := "http"
+	if u.Scheme == util.Libp2pScheme {
+		xferType = "libp2p"
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("getting CAR file info: %w", err)
+	}
+
+	token, err := httptransport.GenerateAuthToken()
+	if err != nil {
+		return err
+	}
+	err = cdb.InsertTransferToken(ctx, &db.ClientTransferToken{
+		Token:    token,
+		DealUUID: params.DealUUID,
+		Path:     path,
+	})
+	if err != nil {
+		return err
+	}
+
+	paramsBytes, err := json.Marshal(&types2.HttpRequest{
+		URL: serverURL,
+		Headers: map[string]string{
+			"Authorization": httptransport.BasicAuthHeader("", token),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("marshalling request parameters: %w", err)
+	}
+
+	params.Transfer = types.Transfer{
+		Type:   xferType,
+		Params: paramsBytes,
+		Size:   uint64(fi.Size()),
+	}
+	return nil
+}
+
+// TransferTokens looks up the CAR file to serve for a transfer token in the
+// client database, and expires tokens that are no longer needed
+type TransferTokens struct {
+	db *db.ClientDealsDB
+	// The time after which a token is expired if no deal was recorded for it
+	ttl time.Duration
+}
+
+var _ httptransport.CarFileTokens = (*TransferTokens)(nil)
+
+func NewTransferTokens(cdb *db.ClientDealsDB, ttl time.Duration) *TransferTokens {
+	return &TransferTokens{db: cdb, ttl: ttl}
+}
+
+func (t *TransferTokens) CarFilePath(ctx context.Context, authToken string) (string, error) {
+	tok, err := t.db.TransferToken(ctx, authToken)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return "", httptransport.ErrTokenNotFound
+		}
+		return "", err
+	}
+	return tok.Path, nil
+}
+
+// Expire deletes the tokens for deals that the provider has finished
+// downloading the data for (the provider reported that the deal is
+// Transferred, or a later status), and for deals that were rejected or
+// failed. Tokens for which no deal was recorded (eg because the proposal
+// could not be sent) are deleted once they are older than the ttl.
+// It returns the number of tokens that were deleted.
+func (t *TransferTokens) Expire(ctx context.Context) (int, error) {
+	tokens, err := t.db.TransferTokens(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("listing transfer tokens: %w", err)
+	}
+
+	expired := 0
+	for _, tok := range tokens {
+		deal, err := t.db.ByID(ctx, tok.DealUUID)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			return expired, fmt.Errorf("getting deal %s: %w", tok.DealUUID, err)
+		}
+
+		if deal == nil {
+			if time.Since(tok.CreatedAt) < t.ttl {
+				continue
+			}
+		} else if deal.Status == dealcheckpoints.Accepted.String() {
+			// The provider is still waiting for, or downloading, the data
+			continue
+		}
+
+		if err := t.db.DeleteTransferToken(ctx, tok.Token); err != nil {
+			return expired, err
+		}
+		log.Infow("expired transfer token", "deal", tok.DealUUID, "path", tok.Path)
+		expired++
+	}
+	return expired, nil
+}
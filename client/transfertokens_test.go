@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/boost/transport/httptransport"
+	types2 "github.com/filecoin-project/boost/transport/types"
+	"github.com/filecoin-project/go-address"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferTokens(t *testing.T) {
+	ctx := context.Background()
+	sp1, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+	sp2, err := address.NewIDAddress(1002)
+	require.NoError(t, err)
+
+	carPath := filepath.Join(t.TempDir(), "data.car")
+	require.NoError(t, os.WriteFile(carPath, []byte("car data"), 0644))
+
+	p := &mockProposer{
+		accept:   map[address.Address]bool{sp2: true},
+		statuses: make(map[uuid.UUID]*types.DealStatusResponse),
+	}
+	var cdb *db.ClientDealsDB
+	mgr, cdb := newTestDealManager(t, DealManagerConfig{
+		FallbackProviders: []address.Address{sp2},
+		PrepareTransfer: func(ctx context.Context, params *types.DealParams) error {
+			return ServeTransfer(ctx, cdb, "http://localhost:7777", carPath, params)
+		},
+	}, p)
+
+	// The deal is rejected by the first provider and accepted by the second
+	params := testDealParams(t, sp1)
+	params.IsOffline = false
+	deal, err := mgr.ProposeDeal(ctx, params)
+	require.NoError(t, err)
+	require.Equal(t, sp2, deal.ProviderAddress)
+	require.NotNil(t, deal.PreviousDealUUID)
+	rejected, err := cdb.ByID(ctx, *deal.PreviousDealUUID)
+	require.NoError(t, err)
+
+	// Each deal gets its own token, which is filled in to the deal's
+	// transfer parameters
+	tokenForDeal := func(d *db.ClientDeal) string {
+		require.Equal(t, "http", d.Params.Transfer.Type)
+		require.EqualValues(t, len("car data"), d.Params.Transfer.Size)
+
+		var req types2.HttpRequest
+		require.NoError(t, json.Unmarshal(d.Params.Transfer.Params, &req))
+		require.Equal(t, "http://localhost:7777", req.URL)
+
+		tokens, err := cdb.TransferTokens(ctx)
+		require.NoError(t, err)
+		for _, tok := range tokens {
+			if tok.DealUUID == d.ID {
+				require.Equal(t, httptransport.BasicAuthHeader("", tok.Token), req.Headers["Authorization"])
+				return tok.Token
+			}
+		}
+		require.Fail(t, "no token for deal")
+		return ""
+	}
+	rejectedToken := tokenForDeal(rejected)
+	acceptedToken := tokenForDeal(deal)
+	require.NotEqual(t, rejectedToken, acceptedToken)
+
+	tokens := NewTransferTokens(cdb, time.Hour)
+	path, err := tokens.CarFilePath(ctx, acceptedToken)
+	require.NoError(t, err)
+	require.Equal(t, carPath, path)
+
+	// The token for the rejected deal expires, and the token for the
+	// accepted deal is kept until the data has been transferred
+	expired, err := tokens.Expire(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, expired)
+	_, err = tokens.CarFilePath(ctx, rejectedToken)
+	require.ErrorIs(t, err, httptransport.ErrTokenNotFound)
+	_, err = tokens.CarFilePath(ctx, acceptedToken)
+	require.NoError(t, err)
+
+	p.statuses[deal.ID] = &types.DealStatusResponse{
+		DealUUID:   deal.ID,
+		DealStatus: &types.DealStatus{Status: dealcheckpoints.Transferred.String()},
+	}
+	require.NoError(t, mgr.Refresh(ctx))
+	expired, err = tokens.Expire(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, expired)
+	_, err = tokens.CarFilePath(ctx, acceptedToken)
+	require.ErrorIs(t, err, httptransport.ErrTokenNotFound)
+
+	// A token with no recorded deal expires after the ttl
+	require.NoError(t, cdb.InsertTransferToken(ctx, &db.ClientTransferToken{
+		Token:     "orphan",
+		CreatedAt: time.Now().Add(-2 * time.Hour),
+		DealUUID:  uuid.New(),
+		Path:      carPath,
+	}))
+	expired, err = tokens.Expire(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, expired)
+}
@@ -5,12 +5,19 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	bcli "github.com/filecoin-project/boost/cli"
 	clinode "github.com/filecoin-project/boost/cli/node"
+	"github.com/filecoin-project/boost/client"
 	"github.com/filecoin-project/boost/cmd"
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/storagemarket/lp2pimpl"
 	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/transport/s3transport"
 	types2 "github.com/filecoin-project/boost/transport/types"
 	"github.com/filecoin-project/go-address"
 	cborutil "github.com/filecoin-project/go-cbor-util"
@@ -23,31 +30,48 @@ import (
 	"github.com/google/uuid"
 	"github.com/ipfs/go-cid"
 	inet "github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/urfave/cli/v2"
 )
 
 const DealProtocolv120 = "/fil/storage/mk/1.2.0"
+const DealBatchProtocolv100 = "/fil/storage/mk/batch/1.0.0"
 
 var dealFlags = []cli.Flag{
 	&cli.StringFlag{
-		Name:     "provider",
-		Usage:    "storage provider on-chain address",
-		Required: true,
+		Name:  "provider",
+		Usage: "storage provider on-chain address (required)",
+	},
+	&cli.StringSliceFlag{
+		Name:  "fallback-provider",
+		Usage: "storage provider to propose the deal to if it is rejected by the other providers (can be repeated)",
+	},
+	&cli.IntFlag{
+		Name:  "max-attempts",
+		Usage: "the maximum number of times to propose the deal to each storage provider if it is rejected",
+		Value: 1,
+	},
+	&cli.DurationFlag{
+		Name:  "retry-delay",
+		Usage: "the delay before a rejected deal is proposed to the same storage provider again",
+		Value: 30 * time.Second,
 	},
 	&cli.StringFlag{
-		Name:     "commp",
-		Usage:    "commp of the CAR file",
-		Required: true,
+		Name:  "commp",
+		Usage: "commp of the CAR file (required unless --manifest is set)",
 	},
 	&cli.Uint64Flag{
-		Name:     "piece-size",
-		Usage:    "size of the CAR file as a padded piece",
-		Required: true,
+		Name:  "piece-size",
+		Usage: "size of the CAR file as a padded piece (required unless --manifest is set)",
+	},
+	&cli.StringFlag{
+		Name:  "payload-cid",
+		Usage: "root CID of the CAR file (required unless --manifest is set)",
 	},
 	&cli.StringFlag{
-		Name:     "payload-cid",
-		Usage:    "root CID of the CAR file",
-		Required: true,
+		Name: "manifest",
+		Usage: "path to a JSON file with a list of deals to send to the provider in a single batch, " +
+			"eg [{\"commp\": \"baga...\", \"pieceSize\": 34359738368, \"payloadCid\": \"bafy...\", \"httpUrl\": \"https://...\", \"carSize\": 1234}]",
 	},
 	&cli.IntFlag{
 		Name:  "start-epoch-head-offset",
@@ -97,24 +121,57 @@ var dealCmd = &cli.Command{
 	Usage: "Make an online deal with Boost",
 	Flags: append([]cli.Flag{
 		&cli.StringFlag{
-			Name:     "http-url",
-			Usage:    "http url to CAR file",
-			Required: true,
+			Name:  "http-url",
+			Usage: "http url to CAR file (required unless --manifest is set)",
 		},
 		&cli.StringSliceFlag{
 			Name:  "http-headers",
 			Usage: "http headers to be passed with the request (e.g key=value)",
 		},
 		&cli.Uint64Flag{
-			Name:     "car-size",
-			Usage:    "size of the CAR file: required for online deals",
-			Required: true,
+			Name:  "car-size",
+			Usage: "size of the CAR file: required for online deals (unless --manifest is set)",
+		},
+		&cli.StringFlag{
+			Name:  "serve-car",
+			Usage: "path to a local CAR file to serve to the provider with `boost serve`, instead of --http-url",
+		},
+		&cli.StringFlag{
+			Name:  "serve-transport",
+			Usage: "the transport the provider uses to download the CAR file from `boost serve` (http or libp2p)",
+			Value: "http",
+		},
+		&cli.StringFlag{
+			Name:  "s3-bucket",
+			Usage: "the S3 bucket that the provider downloads the CAR file from, instead of --http-url",
+		},
+		&cli.StringFlag{
+			Name:  "s3-key",
+			Usage: "the key of the CAR file in the S3 bucket",
+		},
+		&cli.StringFlag{
+			Name:  "s3-endpoint",
+			Usage: "the URL of an S3-compatible server (eg http://203.0.113.1:9000); defaults to AWS",
+		},
+		&cli.StringFlag{
+			Name:  "s3-region",
+			Usage: "the region of the S3 bucket",
+			Value: "us-east-1",
+		},
+		&cli.StringFlag{
+			Name:  "s3-credentials",
+			Usage: "the name of the credentials that the provider has configured for the S3 bucket; if not set the bucket must allow anonymous reads",
 		},
 	}, dealFlags...),
 	Before: before,
 	Action: func(cctx *cli.Context) error {
 		return dealCmdAction(cctx, true)
 	},
+	Subcommands: []*cli.Command{
+		dealListCmd,
+		dealShowCmd,
+		dealRefreshCmd,
+	},
 }
 
 var offlineDealCmd = &cli.Command{
@@ -130,6 +187,12 @@ var offlineDealCmd = &cli.Command{
 func dealCmdAction(cctx *cli.Context, isOnline bool) error {
 	ctx := bcli.ReqContext(cctx)
 
+	// The provider flag is not marked as required, because that would
+	// prevent the deal subcommands from running without it
+	if !cctx.IsSet("provider") {
+		return fmt.Errorf("the --provider flag is required")
+	}
+
 	n, err := clinode.Setup(cctx.String(cmd.FlagRepo.Name))
 	if err != nil {
 		return err
@@ -153,88 +216,105 @@ func dealCmdAction(cctx *cli.Context, isOnline bool) error {
 		return err
 	}
 
-	addrInfo, err := cmd.GetAddrInfo(ctx, api, maddr)
+	var fallbackProviders []address.Address
+	for _, p := range cctx.StringSlice("fallback-provider") {
+		addr, err := address.NewFromString(p)
+		if err != nil {
+			return fmt.Errorf("parsing fallback provider address '%s': %w", p, err)
+		}
+		fallbackProviders = append(fallbackProviders, addr)
+	}
+
+	cdb, err := openClientDealsDB(ctx, cctx)
 	if err != nil {
 		return err
 	}
 
-	log.Debugw("found storage provider", "id", addrInfo.ID, "multiaddrs", addrInfo.Addrs, "addr", maddr)
-
-	if err := n.Host.Connect(ctx, *addrInfo); err != nil {
-		return fmt.Errorf("failed to connect to peer %s: %w", addrInfo.ID, err)
+	cfg := client.DealManagerConfig{
+		MaxAttempts:       cctx.Int("max-attempts"),
+		RetryDelay:        cctx.Duration("retry-delay"),
+		FallbackProviders: fallbackProviders,
 	}
 
-	x, err := n.Host.Peerstore().FirstSupportedProtocol(addrInfo.ID, DealProtocolv120)
-	if err != nil {
-		return fmt.Errorf("getting protocols for peer %s: %w", addrInfo.ID, err)
-	}
+	// If the CAR file is served by `boost serve`, each deal (including each
+	// re-proposal) gets its own auth token for downloading the file
+	url := cctx.String("http-url")
+	if isOnline && cctx.IsSet("serve-car") {
+		if cctx.IsSet("manifest") {
+			return errors.New("the --serve-car flag cannot be used with --manifest")
+		}
+		if cctx.IsSet("http-url") {
+			return errors.New("only one flag from `serve-car` or `http-url` can be specified")
+		}
 
-	if len(x) == 0 {
-		return fmt.Errorf("boost client cannot make a deal with storage provider %s because it does not support protocol version 1.2.0", maddr)
+		url, err = serveURL(cctx, cctx.String("serve-transport"))
+		if err != nil {
+			return err
+		}
+		carPath, err := filepath.Abs(cctx.String("serve-car"))
+		if err != nil {
+			return fmt.Errorf("getting absolute path of %s: %w", cctx.String("serve-car"), err)
+		}
+		cfg.PrepareTransfer = func(ctx context.Context, params *types.DealParams) error {
+			return client.ServeTransfer(ctx, cdb, url, carPath, params)
+		}
 	}
 
-	dealUuid := uuid.New()
+	// The deal manager records each deal in the client database, and
+	// re-proposes deals that are rejected
+	mgr := client.NewDealManager(cfg, cdb, newDealProposer(n, api))
 
-	commp := cctx.String("commp")
-	pieceCid, err := cid.Parse(commp)
+	addrInfo, err := cmd.GetAddrInfo(ctx, api, maddr)
 	if err != nil {
-		return fmt.Errorf("parsing commp '%s': %w", commp, err)
+		return err
 	}
 
-	pieceSize := cctx.Uint64("piece-size")
-	if pieceSize == 0 {
-		return fmt.Errorf("must provide piece-size parameter for CAR url")
+	log.Debugw("found storage provider", "id", addrInfo.ID, "multiaddrs", addrInfo.Addrs, "addr", maddr)
+
+	if err := n.Host.Connect(ctx, *addrInfo); err != nil {
+		return fmt.Errorf("failed to connect to peer %s: %w", addrInfo.ID, err)
 	}
 
-	payloadCidStr := cctx.String("payload-cid")
-	rootCid, err := cid.Parse(payloadCidStr)
+	// Get the deals to propose, either from the manifest or from the flags
+	var specs []dealSpec
+	if cctx.IsSet("manifest") {
+		specs, err = readDealManifest(cctx.String("manifest"), isOnline)
+	} else {
+		var spec *dealSpec
+		spec, err = dealSpecFromFlags(cctx, isOnline)
+		specs = []dealSpec{*spec}
+	}
 	if err != nil {
-		return fmt.Errorf("parsing payload cid %s: %w", payloadCidStr, err)
+		return err
 	}
 
-	transfer := types.Transfer{}
-	if isOnline {
+	protocol := DealProtocolv120
+	if cctx.IsSet("manifest") {
+		protocol = DealBatchProtocolv100
+	}
+	x, err := n.Host.Peerstore().FirstSupportedProtocol(addrInfo.ID, protocol)
+	if err != nil {
+		return fmt.Errorf("getting protocols for peer %s: %w", addrInfo.ID, err)
+	}
 
-		carFileSize := cctx.Uint64("car-size")
-		if carFileSize == 0 {
-			return fmt.Errorf("size of car file cannot be 0")
+	if len(x) == 0 {
+		if protocol == DealBatchProtocolv100 {
+			return fmt.Errorf("boost client cannot send a batch of deals to storage provider %s because it does not support the deal batch protocol", maddr)
 		}
+		return fmt.Errorf("boost client cannot make a deal with storage provider %s because it does not support protocol version 1.2.0", maddr)
+	}
 
-		transfer.Size = carFileSize
-		// Store the path to the CAR file as a transfer parameter
-		transferParams := &types2.HttpRequest{URL: cctx.String("http-url")}
-
-		if cctx.IsSet("http-headers") {
-			transferParams.Headers = make(map[string]string)
-
-			for _, header := range cctx.StringSlice("http-headers") {
-				sp := strings.Split(header, "=")
-				if len(sp) != 2 {
-					return fmt.Errorf("malformed http header: %s", header)
-				}
-
-				transferParams.Headers[sp[0]] = sp[1]
-			}
+	if isOnline {
+		transferTypes := make([]string, 0, len(specs))
+		for _, spec := range specs {
+			transferTypes = append(transferTypes, spec.transfer.Type)
 		}
-
-		paramsBytes, err := json.Marshal(transferParams)
-		if err != nil {
-			return fmt.Errorf("marshalling request parameters: %w", err)
+		if cctx.IsSet("serve-car") {
+			transferTypes = []string{cctx.String("serve-transport")}
 		}
-		transfer.Type = "http"
-		transfer.Params = paramsBytes
-	}
-
-	var providerCollateral abi.TokenAmount
-	if cctx.IsSet("provider-collateral") {
-		providerCollateral = abi.NewTokenAmount(cctx.Int64("provider-collateral"))
-	} else {
-		bounds, err := api.StateDealProviderCollateralBounds(ctx, abi.PaddedPieceSize(pieceSize), cctx.Bool("verified"), chain_types.EmptyTSK)
-		if err != nil {
-			return fmt.Errorf("node error getting collateral bounds: %w", err)
+		if err := checkTransferTypes(ctx, n, addrInfo.ID, transferTypes); err != nil {
+			return fmt.Errorf("storage provider %s: %w", maddr, err)
 		}
-
-		providerCollateral = big.Div(big.Mul(bounds.Min, big.NewInt(6)), big.NewInt(5)) // add 20%
 	}
 
 	tipset, err := api.ChainHead(ctx)
@@ -260,38 +340,68 @@ func dealCmdAction(cctx *cli.Context, isOnline bool) error {
 		startEpoch = head + abi.ChainEpoch(5760) // head + 2 days
 	}
 
-	// Create a deal proposal to storage provider using deal protocol v1.2.0 format
-	dealProposal, err := dealProposal(ctx, n, walletAddr, rootCid, abi.PaddedPieceSize(pieceSize), pieceCid, maddr, startEpoch, cctx.Int("duration"), cctx.Bool("verified"), providerCollateral, abi.NewTokenAmount(cctx.Int64("storage-price")))
-	if err != nil {
-		return fmt.Errorf("failed to create a deal proposal: %w", err)
+	// The minimum collateral depends on the piece size, so only look it up
+	// once for each piece size
+	collateralForSize := make(map[abi.PaddedPieceSize]abi.TokenAmount)
+	providerCollateral := func(pieceSize abi.PaddedPieceSize) (abi.TokenAmount, error) {
+		if cctx.IsSet("provider-collateral") {
+			return abi.NewTokenAmount(cctx.Int64("provider-collateral")), nil
+		}
+		if collat, ok := collateralForSize[pieceSize]; ok {
+			return collat, nil
+		}
+
+		collat, err := minProviderCollateral(ctx, api, pieceSize, cctx.Bool("verified"))
+		if err != nil {
+			return abi.TokenAmount{}, err
+		}
+		collateralForSize[pieceSize] = collat
+		return collat, nil
 	}
 
-	dealParams := types.DealParams{
-		DealUUID:           dealUuid,
-		ClientDealProposal: *dealProposal,
-		DealDataRoot:       rootCid,
-		IsOffline:          !isOnline,
-		Transfer:           transfer,
-		RemoveUnsealedCopy: cctx.Bool("remove-unsealed-copy"),
-		SkipIPNIAnnounce:   cctx.Bool("skip-ipni-announce"),
+	deals := make([]types.DealParams, 0, len(specs))
+	for _, spec := range specs {
+		collat, err := providerCollateral(spec.pieceSize)
+		if err != nil {
+			return err
+		}
+
+		// Create a deal proposal to storage provider using deal protocol v1.2.0 format
+		dealProposal, err := dealProposal(ctx, n, walletAddr, spec.rootCid, spec.pieceSize, spec.pieceCid, maddr, startEpoch, cctx.Int("duration"), cctx.Bool("verified"), collat, abi.NewTokenAmount(cctx.Int64("storage-price")))
+		if err != nil {
+			return fmt.Errorf("failed to create a deal proposal: %w", err)
+		}
+
+		deals = append(deals, types.DealParams{
+			DealUUID:           uuid.New(),
+			ClientDealProposal: *dealProposal,
+			DealDataRoot:       spec.rootCid,
+			IsOffline:          !isOnline,
+			Transfer:           spec.transfer,
+			RemoveUnsealedCopy: cctx.Bool("remove-unsealed-copy"),
+			SkipIPNIAnnounce:   cctx.Bool("skip-ipni-announce"),
+		})
+	}
+
+	if cctx.IsSet("manifest") {
+		return sendDealBatch(ctx, cctx, n, mgr, addrInfo.ID, maddr, walletAddr, deals, specs, isOnline)
 	}
 
-	log.Debugw("about to submit deal proposal", "uuid", dealUuid.String())
+	log.Debugw("about to submit deal proposal", "uuid", deals[0].DealUUID.String())
 
-	s, err := n.Host.NewStream(ctx, addrInfo.ID, DealProtocolv120)
+	deal, err := mgr.ProposeDeal(ctx, deals[0])
 	if err != nil {
-		return fmt.Errorf("failed to open stream to peer %s: %w", addrInfo.ID, err)
+		return err
 	}
-	defer s.Close()
 
-	var resp types.DealResponse
-	if err := doRpc(ctx, s, &dealParams, &resp); err != nil {
-		return fmt.Errorf("send proposal rpc: %w", err)
+	if deal.Status == db.ClientDealStatusRejected {
+		return fmt.Errorf("deal proposal rejected: %s", deal.Message)
 	}
 
-	if !resp.Accepted {
-		return fmt.Errorf("deal proposal rejected: %s", resp.Message)
-	}
+	dealUuid := deal.ID
+	dealProposal := deal.Params.ClientDealProposal
+	rootCid := deal.PayloadCID
+	maddr = deal.ProviderAddress
 
 	if cctx.Bool("json") {
 		out := map[string]interface{}{
@@ -303,9 +413,10 @@ func dealCmdAction(cctx *cli.Context, isOnline bool) error {
 			"startEpoch":         dealProposal.Proposal.StartEpoch.String(),
 			"endEpoch":           dealProposal.Proposal.EndEpoch.String(),
 			"providerCollateral": dealProposal.Proposal.ProviderCollateral.String(),
+			"attempt":            deal.Attempt,
 		}
 		if isOnline {
-			out["url"] = cctx.String("http-url")
+			out["url"] = url
 		}
 		return cmd.PrintJson(out)
 	}
@@ -317,10 +428,13 @@ func dealCmdAction(cctx *cli.Context, isOnline bool) error {
 	msg += "\n"
 	msg += fmt.Sprintf("  deal uuid: %s\n", dealUuid)
 	msg += fmt.Sprintf("  storage provider: %s\n", maddr)
+	if deal.Attempt > 1 {
+		msg += fmt.Sprintf("  attempt: %d\n", deal.Attempt)
+	}
 	msg += fmt.Sprintf("  client wallet: %s\n", walletAddr)
 	msg += fmt.Sprintf("  payload cid: %s\n", rootCid)
 	if isOnline {
-		msg += fmt.Sprintf("  url: %s\n", cctx.String("http-url"))
+		msg += fmt.Sprintf("  url: %s\n", url)
 	}
 	msg += fmt.Sprintf("  commp: %s\n", dealProposal.Proposal.PieceCID)
 	msg += fmt.Sprintf("  start epoch: %d\n", dealProposal.Proposal.StartEpoch)
@@ -331,6 +445,310 @@ func dealCmdAction(cctx *cli.Context, isOnline bool) error {
 	return nil
 }
 
+// sendDealBatch sends the deals to the provider in a single batch, records
+// them in the client database, and prints the response for each deal
+func sendDealBatch(ctx context.Context, cctx *cli.Context, n *clinode.Node, mgr *client.DealManager, id peer.ID, maddr address.Address, walletAddr address.Address, deals []types.DealParams, specs []dealSpec, isOnline bool) error {
+	log.Debugw("about to submit deal batch proposal", "count", len(deals))
+
+	s, err := n.Host.NewStream(ctx, id, DealBatchProtocolv100)
+	if err != nil {
+		return fmt.Errorf("failed to open stream to peer %s: %w", id, err)
+	}
+	defer s.Close()
+
+	var resp types.BatchDealResponse
+	if err := doRpc(ctx, s, &types.BatchDealParams{Deals: deals}, &resp); err != nil {
+		return fmt.Errorf("send batch proposal rpc: %w", err)
+	}
+
+	if len(resp.Responses) != len(deals) {
+		return fmt.Errorf("batch proposal response has %d responses for %d deals", len(resp.Responses), len(deals))
+	}
+
+	// Record each deal in the client database. Deals that were rejected are
+	// re-proposed individually.
+	recorded := make([]*db.ClientDeal, 0, len(deals))
+	rejected := 0
+	for i, dp := range deals {
+		deal, err := mgr.RecordProposal(ctx, dp, &resp.Responses[i])
+		if err != nil {
+			return err
+		}
+		if deal.Status == db.ClientDealStatusRejected {
+			rejected++
+		}
+		recorded = append(recorded, deal)
+	}
+
+	if cctx.Bool("json") {
+		out := make([]map[string]interface{}, 0, len(deals))
+		for i, deal := range recorded {
+			prop := deal.Params.ClientDealProposal.Proposal
+			accepted := deal.Status != db.ClientDealStatusRejected
+			o := map[string]interface{}{
+				"dealUuid":           deal.ID.String(),
+				"accepted":           accepted,
+				"provider":           deal.ProviderAddress.String(),
+				"clientWallet":       walletAddr.String(),
+				"payloadCid":         deal.PayloadCID.String(),
+				"commp":              prop.PieceCID.String(),
+				"startEpoch":         prop.StartEpoch.String(),
+				"endEpoch":           prop.EndEpoch.String(),
+				"providerCollateral": prop.ProviderCollateral.String(),
+				"attempt":            deal.Attempt,
+			}
+			if !accepted {
+				o["message"] = deal.Message
+			}
+			if isOnline {
+				o["url"] = specs[i].url
+			}
+			out = append(out, o)
+		}
+		if err := cmd.PrintJson(out); err != nil {
+			return err
+		}
+	} else {
+		msg := fmt.Sprintf("sent batch of %d deal proposals", len(deals))
+		if !isOnline {
+			msg += " for offline deals"
+		}
+		msg += "\n"
+		msg += fmt.Sprintf("  storage provider: %s\n", maddr)
+		msg += fmt.Sprintf("  client wallet: %s\n", walletAddr)
+		for _, deal := range recorded {
+			status := "accepted"
+			if deal.Status == db.ClientDealStatusRejected {
+				status = "rejected: " + deal.Message
+			}
+			if deal.ProviderAddress != maddr {
+				status += fmt.Sprintf(" (by %s)", deal.ProviderAddress)
+			}
+			msg += fmt.Sprintf("  %s  commp: %s  payload cid: %s  %s\n",
+				deal.ID, deal.PieceCID, deal.PayloadCID, status)
+		}
+		fmt.Println(msg)
+	}
+
+	if rejected > 0 {
+		return fmt.Errorf("%d of %d deal proposals rejected", rejected, len(deals))
+	}
+	return nil
+}
+
+// dealSpec has the parameters that are specific to each deal
+type dealSpec struct {
+	pieceCid  cid.Cid
+	pieceSize abi.PaddedPieceSize
+	rootCid   cid.Cid
+	url       string
+	transfer  types.Transfer
+}
+
+// dealManifestEntry is an entry in a deal manifest file
+type dealManifestEntry struct {
+	Commp       string            `json:"commp"`
+	PieceSize   uint64            `json:"pieceSize"`
+	PayloadCid  string            `json:"payloadCid"`
+	HttpUrl     string            `json:"httpUrl"`
+	HttpHeaders map[string]string `json:"httpHeaders"`
+	CarSize     uint64            `json:"carSize"`
+}
+
+func dealSpecFromFlags(cctx *cli.Context, isOnline bool) (*dealSpec, error) {
+	// When the CAR file is served by `boost serve`, the transfer is filled
+	// in for each deal when it is proposed
+	serve := cctx.IsSet("serve-car")
+	s3 := cctx.IsSet("s3-bucket")
+	if s3 && (serve || cctx.IsSet("http-url")) {
+		return nil, errors.New("the --s3-bucket flag cannot be used with --serve-car or --http-url")
+	}
+
+	required := []string{"commp", "piece-size", "payload-cid"}
+	if isOnline && s3 {
+		required = append(required, "s3-key", "car-size")
+	} else if isOnline && !serve {
+		required = append(required, "http-url", "car-size")
+	}
+	for _, name := range required {
+		if !cctx.IsSet(name) {
+			return nil, fmt.Errorf("the --%s flag is required unless --manifest is set", name)
+		}
+	}
+
+	headers, err := httpHeadersFromFlags(cctx)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := newDealSpec(dealManifestEntry{
+		Commp:       cctx.String("commp"),
+		PieceSize:   cctx.Uint64("piece-size"),
+		PayloadCid:  cctx.String("payload-cid"),
+		HttpUrl:     cctx.String("http-url"),
+		HttpHeaders: headers,
+		CarSize:     cctx.Uint64("car-size"),
+	}, isOnline && !serve && !s3)
+	if err != nil || !isOnline || !s3 {
+		return spec, err
+	}
+
+	// The provider downloads the CAR file from the S3 bucket
+	s3Req := &types2.S3Request{
+		Endpoint:    cctx.String("s3-endpoint"),
+		Region:      cctx.String("s3-region"),
+		Bucket:      cctx.String("s3-bucket"),
+		Key:         cctx.String("s3-key"),
+		Credentials: cctx.String("s3-credentials"),
+	}
+	objectUrl, err := s3transport.ObjectURL(s3Req)
+	if err != nil {
+		return nil, err
+	}
+	paramsBytes, err := json.Marshal(s3Req)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling s3 request parameters: %w", err)
+	}
+
+	spec.url = objectUrl.String()
+	spec.transfer = types.Transfer{
+		Type:   "s3",
+		Params: paramsBytes,
+		Size:   cctx.Uint64("car-size"),
+	}
+	return spec, nil
+}
+
+func httpHeadersFromFlags(cctx *cli.Context) (map[string]string, error) {
+	if !cctx.IsSet("http-headers") {
+		return nil, nil
+	}
+
+	headers := make(map[string]string)
+	for _, header := range cctx.StringSlice("http-headers") {
+		sp := strings.Split(header, "=")
+		if len(sp) != 2 {
+			return nil, fmt.Errorf("malformed http header: %s", header)
+		}
+
+		headers[sp[0]] = sp[1]
+	}
+	return headers, nil
+}
+
+func readDealManifest(path string, isOnline bool) ([]dealSpec, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading deal manifest: %w", err)
+	}
+
+	var entries []dealManifestEntry
+	if err := json.Unmarshal(bz, &entries); err != nil {
+		return nil, fmt.Errorf("parsing deal manifest %s: %w", path, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("deal manifest %s has no deals", path)
+	}
+
+	specs := make([]dealSpec, 0, len(entries))
+	for i, e := range entries {
+		spec, err := newDealSpec(e, isOnline)
+		if err != nil {
+			return nil, fmt.Errorf("deal manifest entry %d: %w", i, err)
+		}
+		specs = append(specs, *spec)
+	}
+	return specs, nil
+}
+
+func newDealSpec(e dealManifestEntry, isOnline bool) (*dealSpec, error) {
+	pieceCid, err := cid.Parse(e.Commp)
+	if err != nil {
+		return nil, fmt.Errorf("parsing commp '%s': %w", e.Commp, err)
+	}
+
+	if e.PieceSize == 0 {
+		return nil, fmt.Errorf("must provide piece-size parameter for CAR url")
+	}
+
+	rootCid, err := cid.Parse(e.PayloadCid)
+	if err != nil {
+		return nil, fmt.Errorf("parsing payload cid %s: %w", e.PayloadCid, err)
+	}
+
+	spec := &dealSpec{
+		pieceCid:  pieceCid,
+		pieceSize: abi.PaddedPieceSize(e.PieceSize),
+		rootCid:   rootCid,
+	}
+	if !isOnline {
+		return spec, nil
+	}
+
+	if e.CarSize == 0 {
+		return nil, fmt.Errorf("size of car file cannot be 0")
+	}
+
+	// Store the path to the CAR file as a transfer parameter
+	transferParams := &types2.HttpRequest{URL: e.HttpUrl, Headers: e.HttpHeaders}
+	paramsBytes, err := json.Marshal(transferParams)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request parameters: %w", err)
+	}
+
+	spec.url = e.HttpUrl
+	spec.transfer = types.Transfer{
+		Type:   "http",
+		Params: paramsBytes,
+		Size:   e.CarSize,
+	}
+	return spec, nil
+}
+
+// checkTransferTypes checks that the provider supports each of the transfer
+// types. Providers that don't support the transports query only support
+// http and libp2p transfers.
+func checkTransferTypes(ctx context.Context, n *clinode.Node, id peer.ID, transferTypes []string) error {
+	supported := []string{"http", "libp2p"}
+	x, err := n.Host.Peerstore().FirstSupportedProtocol(id, lp2pimpl.TransportsV10ProtocolID)
+	if err != nil {
+		return fmt.Errorf("getting protocols for peer %s: %w", id, err)
+	}
+	if len(x) > 0 {
+		dc := lp2pimpl.NewDealClient(n.Host, address.Undef, nil)
+		resp, err := dc.SendTransportsQuery(ctx, id)
+		if err != nil {
+			return fmt.Errorf("querying supported transfer types: %w", err)
+		}
+		supported = resp.TransferTypes
+	}
+
+	for _, t := range transferTypes {
+		found := false
+		for _, s := range supported {
+			if s == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("transfer type '%s' is not supported (supported types: %s)", t, strings.Join(supported, ", "))
+		}
+	}
+	return nil
+}
+
+// minProviderCollateral returns the provider collateral to propose for a deal
+// with the given piece size: the minimum collateral plus 20%
+func minProviderCollateral(ctx context.Context, api api.Gateway, pieceSize abi.PaddedPieceSize, verified bool) (abi.TokenAmount, error) {
+	bounds, err := api.StateDealProviderCollateralBounds(ctx, pieceSize, verified, chain_types.EmptyTSK)
+	if err != nil {
+		return abi.TokenAmount{}, fmt.Errorf("node error getting collateral bounds: %w", err)
+	}
+
+	return big.Div(big.Mul(bounds.Min, big.NewInt(6)), big.NewInt(5)), nil // add 20%
+}
+
 func dealProposal(ctx context.Context, n *clinode.Node, clientAddr address.Address, rootCid cid.Cid, pieceSize abi.PaddedPieceSize, pieceCid cid.Cid, minerAddr address.Address, startEpoch abi.ChainEpoch, duration int, verified bool, providerCollateral abi.TokenAmount, storagePrice abi.TokenAmount) (*market.ClientDealProposal, error) {
 	endEpoch := startEpoch + abi.ChainEpoch(duration)
 	// deal proposal expects total storage price for deal per epoch, therefore we
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bcli "github.com/filecoin-project/boost/cli"
+	clinode "github.com/filecoin-project/boost/cli/node"
+	"github.com/filecoin-project/boost/client"
+	"github.com/filecoin-project/boost/cmd"
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/storagemarket/lp2pimpl"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/go-address"
+	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	"github.com/filecoin-project/lotus/api"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	"github.com/google/uuid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/mitchellh/go-homedir"
+	"github.com/urfave/cli/v2"
+)
+
+var dealListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List the deals that were proposed by this client",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "status",
+			Usage: "only list deals with the given status (eg Rejected, Accepted, Complete)",
+		},
+	},
+	Before: before,
+	Action: func(cctx *cli.Context) error {
+		ctx := bcli.ReqContext(cctx)
+
+		cdb, err := openClientDealsDB(ctx, cctx)
+		if err != nil {
+			return err
+		}
+
+		deals, err := cdb.List(ctx, cctx.StringSlice("status")...)
+		if err != nil {
+			return fmt.Errorf("listing deals: %w", err)
+		}
+
+		if cctx.Bool("json") {
+			out := make([]map[string]interface{}, 0, len(deals))
+			for _, deal := range deals {
+				out = append(out, clientDealJson(deal))
+			}
+			return cmd.PrintJson(out)
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("Created"),
+			tablewriter.Col("Deal UUID"),
+			tablewriter.Col("Provider"),
+			tablewriter.Col("Piece CID"),
+			tablewriter.Col("Status"),
+			tablewriter.Col("Chain Deal ID"),
+			tablewriter.NewLineCol("Message"))
+		for _, deal := range deals {
+			row := map[string]interface{}{
+				"Created":   deal.CreatedAt.Format(time.RFC3339),
+				"Deal UUID": deal.ID,
+				"Provider":  deal.ProviderAddress,
+				"Piece CID": deal.PieceCID,
+				"Status":    deal.Status,
+			}
+			if deal.ChainDealID != 0 {
+				row["Chain Deal ID"] = deal.ChainDealID
+			}
+			if deal.Message != "" {
+				row["Message"] = deal.Message
+			}
+			tw.Write(row)
+		}
+		return tw.Flush(os.Stdout)
+	},
+}
+
+var dealShowCmd = &cli.Command{
+	Name:      "show",
+	Usage:     "Show a deal that was proposed by this client, and the history of its status",
+	ArgsUsage: "<deal uuid>",
+	Before:    before,
+	Action: func(cctx *cli.Context) error {
+		ctx := bcli.ReqContext(cctx)
+
+		if cctx.Args().Len() != 1 {
+			return fmt.Errorf("must specify the deal uuid")
+		}
+		dealUuid, err := uuid.Parse(cctx.Args().First())
+		if err != nil {
+			return fmt.Errorf("parsing deal uuid '%s': %w", cctx.Args().First(), err)
+		}
+
+		cdb, err := openClientDealsDB(ctx, cctx)
+		if err != nil {
+			return err
+		}
+
+		deal, err := cdb.ByID(ctx, dealUuid)
+		if err != nil {
+			return fmt.Errorf("getting deal %s: %w", dealUuid, err)
+		}
+
+		history, err := cdb.History(ctx, dealUuid)
+		if err != nil {
+			return fmt.Errorf("getting deal %s status history: %w", dealUuid, err)
+		}
+
+		if cctx.Bool("json") {
+			out := clientDealJson(deal)
+			hist := make([]map[string]interface{}, 0, len(history))
+			for _, s := range history {
+				hist = append(hist, map[string]interface{}{
+					"at":      s.CreatedAt.Format(time.RFC3339),
+					"status":  s.Status,
+					"message": s.Message,
+				})
+			}
+			out["history"] = hist
+			return cmd.PrintJson(out)
+		}
+
+		msg := fmt.Sprintf("deal %s\n", deal.ID)
+		msg += fmt.Sprintf("  created: %s\n", deal.CreatedAt.Format(time.RFC3339))
+		msg += fmt.Sprintf("  storage provider: %s\n", deal.ProviderAddress)
+		msg += fmt.Sprintf("  client wallet: %s\n", deal.ClientAddress)
+		msg += fmt.Sprintf("  payload cid: %s\n", deal.PayloadCID)
+		msg += fmt.Sprintf("  commp: %s\n", deal.PieceCID)
+		msg += fmt.Sprintf("  piece size: %d\n", deal.PieceSize)
+		msg += fmt.Sprintf("  offline: %t\n", deal.IsOffline)
+		msg += fmt.Sprintf("  verified: %t\n", deal.Verified)
+		msg += fmt.Sprintf("  start epoch: %d\n", deal.StartEpoch)
+		msg += fmt.Sprintf("  end epoch: %d\n", deal.EndEpoch)
+		msg += fmt.Sprintf("  status: %s\n", deal.Status)
+		if deal.Message != "" {
+			msg += fmt.Sprintf("  message: %s\n", deal.Message)
+		}
+		if deal.SealingStatus != "" {
+			msg += fmt.Sprintf("  sealing status: %s\n", deal.SealingStatus)
+		}
+		msg += fmt.Sprintf("  sealed: %t\n", deal.Sealed)
+		if deal.PublishCID != nil {
+			msg += fmt.Sprintf("  publish cid: %s\n", deal.PublishCID)
+		}
+		if deal.ChainDealID != 0 {
+			msg += fmt.Sprintf("  chain deal id: %d\n", deal.ChainDealID)
+		}
+		msg += fmt.Sprintf("  attempt: %d\n", deal.Attempt)
+		if deal.PreviousDealUUID != nil {
+			msg += fmt.Sprintf("  re-proposal of deal: %s\n", deal.PreviousDealUUID)
+		}
+		if deal.ReplicationID != nil {
+			msg += fmt.Sprintf("  replication: %s\n", deal.ReplicationID)
+		}
+		msg += "  status history:\n"
+		for _, s := range history {
+			msg += fmt.Sprintf("    %s  %s", s.CreatedAt.Format(time.RFC3339), s.Status)
+			if s.Message != "" {
+				msg += ": " + s.Message
+			}
+			msg += "\n"
+		}
+		fmt.Println(msg)
+		return nil
+	},
+}
+
+var dealRefreshCmd = &cli.Command{
+	Name:  "refresh",
+	Usage: "Refresh the status of the deals that are in progress from their storage providers",
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:  "interval",
+			Usage: "keep running, refreshing deal status at this interval (eg 5m)",
+		},
+	},
+	Before: before,
+	Action: func(cctx *cli.Context) error {
+		ctx := bcli.ReqContext(cctx)
+
+		n, err := clinode.Setup(cctx.String(cmd.FlagRepo.Name))
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := lcli.GetGatewayAPI(cctx)
+		if err != nil {
+			return fmt.Errorf("cant setup gateway connection: %w", err)
+		}
+		defer closer()
+
+		mgr, err := newDealManager(ctx, cctx, n, api, client.DealManagerConfig{})
+		if err != nil {
+			return err
+		}
+
+		if cctx.IsSet("interval") {
+			mgr.Run(ctx, cctx.Duration("interval"))
+			return nil
+		}
+		return mgr.Refresh(ctx)
+	},
+}
+
+func clientDealJson(deal *db.ClientDeal) map[string]interface{} {
+	out := map[string]interface{}{
+		"dealUuid":      deal.ID.String(),
+		"createdAt":     deal.CreatedAt.Format(time.RFC3339),
+		"updatedAt":     deal.UpdatedAt.Format(time.RFC3339),
+		"provider":      deal.ProviderAddress.String(),
+		"clientWallet":  deal.ClientAddress.String(),
+		"payloadCid":    deal.PayloadCID.String(),
+		"commp":         deal.PieceCID.String(),
+		"pieceSize":     deal.PieceSize,
+		"isOffline":     deal.IsOffline,
+		"verified":      deal.Verified,
+		"startEpoch":    deal.StartEpoch,
+		"endEpoch":      deal.EndEpoch,
+		"status":        deal.Status,
+		"message":       deal.Message,
+		"sealingStatus": deal.SealingStatus,
+		"sealed":        deal.Sealed,
+		"chainDealId":   deal.ChainDealID,
+		"publishCid":    nil,
+		"attempt":       deal.Attempt,
+	}
+	if deal.PublishCID != nil {
+		out["publishCid"] = deal.PublishCID.String()
+	}
+	if deal.PreviousDealUUID != nil {
+		out["previousDealUuid"] = deal.PreviousDealUUID.String()
+	}
+	if deal.ReplicationID != nil {
+		out["replicationId"] = deal.ReplicationID.String()
+	}
+	return out
+}
+
+// openClientDealsDB opens the database in the boost client repo that keeps
+// track of the deals proposed by the client
+func openClientDealsDB(ctx context.Context, cctx *cli.Context) (*db.ClientDealsDB, error) {
+	repoDir, err := homedir.Expand(cctx.String(cmd.FlagRepo.Name))
+	if err != nil {
+		return nil, fmt.Errorf("getting homedir: %w", err)
+	}
+
+	sqldb, err := db.SqlDB(filepath.Join(repoDir, db.ClientDealsDBName))
+	if err != nil {
+		return nil, fmt.Errorf("opening client deals db: %w", err)
+	}
+	if err := db.CreateClientTables(ctx, sqldb); err != nil {
+		return nil, err
+	}
+	return db.NewClientDealsDB(sqldb), nil
+}
+
+func newDealManager(ctx context.Context, cctx *cli.Context, n *clinode.Node, api api.Gateway, cfg client.DealManagerConfig) (*client.DealManager, error) {
+	cdb, err := openClientDealsDB(ctx, cctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.NewDealManager(cfg, cdb, newDealProposer(n, api)), nil
+}
+
+// dealProposer sends deal proposals and deal status requests to storage
+// providers from the boost client node
+type dealProposer struct {
+	n   *clinode.Node
+	api api.Gateway
+
+	lk    sync.Mutex
+	peers map[address.Address]peer.ID
+}
+
+var _ client.DealProposer = (*dealProposer)(nil)
+
+func newDealProposer(n *clinode.Node, api api.Gateway) *dealProposer {
+	return &dealProposer{
+		n:     n,
+		api:   api,
+		peers: make(map[address.Address]peer.ID),
+	}
+}
+
+func (p *dealProposer) SendProposal(ctx context.Context, params types.DealParams) (*types.DealResponse, error) {
+	prop := params.ClientDealProposal.Proposal
+	id, err := p.connect(ctx, prop.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	dc := lp2pimpl.NewDealClient(p.n.Host, prop.Client, clinode.DealProposalSigner{LocalWallet: p.n.Wallet})
+	return dc.SendDealProposal(ctx, id, params)
+}
+
+func (p *dealProposer) SignProposal(ctx context.Context, proposal market.DealProposal) (*market.ClientDealProposal, error) {
+	buf, err := cborutil.Dump(&proposal)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := p.n.Wallet.WalletSign(ctx, proposal.Client, buf, api.MsgMeta{Type: api.MTDealProposal})
+	if err != nil {
+		return nil, fmt.Errorf("wallet sign failed: %w", err)
+	}
+
+	return &market.ClientDealProposal{
+		Proposal:        proposal,
+		ClientSignature: *sig,
+	}, nil
+}
+
+func (p *dealProposer) DealStatus(ctx context.Context, deal *db.ClientDeal) (*types.DealStatusResponse, error) {
+	id, err := p.connect(ctx, deal.ProviderAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	dc := lp2pimpl.NewDealClient(p.n.Host, deal.ClientAddress, clinode.DealProposalSigner{LocalWallet: p.n.Wallet})
+	return dc.SendDealStatusRequest(ctx, id, deal.ID)
+}
+
+// connect connects to the storage provider and returns its peer ID
+func (p *dealProposer) connect(ctx context.Context, maddr address.Address) (peer.ID, error) {
+	p.lk.Lock()
+	id, ok := p.peers[maddr]
+	p.lk.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	addrInfo, err := cmd.GetAddrInfo(ctx, p.api, maddr)
+	if err != nil {
+		return "", fmt.Errorf("getting address of storage provider %s: %w", maddr, err)
+	}
+
+	log.Debugw("found storage provider", "id", addrInfo.ID, "multiaddrs", addrInfo.Addrs, "addr", maddr)
+
+	if err := p.n.Host.Connect(ctx, *addrInfo); err != nil {
+		return "", fmt.Errorf("failed to connect to peer %s: %w", addrInfo.ID, err)
+	}
+
+	p.lk.Lock()
+	p.peers[maddr] = addrInfo.ID
+	p.lk.Unlock()
+	return addrInfo.ID, nil
+}
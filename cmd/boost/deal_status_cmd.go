@@ -26,23 +26,34 @@ var dealStatusCmd = &cli.Command{
 			Usage:    "storage provider on-chain address",
 			Required: true,
 		},
-		&cli.StringFlag{
+		&cli.StringSliceFlag{
 			Name:     "deal-uuid",
-			Usage:    "",
+			Usage:    "the uuid of the deal (can be repeated with --watch)",
 			Required: true,
 		},
 		&cli.StringFlag{
 			Name:  "wallet",
 			Usage: "the wallet address that was used to sign the deal proposal",
 		},
+		&cli.BoolFlag{
+			Name:  "watch",
+			Usage: "print updates to the status of the deals until they complete",
+		},
 	},
 	Before: before,
 	Action: func(cctx *cli.Context) error {
 		ctx := bcli.ReqContext(cctx)
 
-		dealUUID, err := uuid.Parse(cctx.String("deal-uuid"))
-		if err != nil {
-			return err
+		var dealUUIDs []uuid.UUID
+		for _, u := range cctx.StringSlice("deal-uuid") {
+			dealUUID, err := uuid.Parse(u)
+			if err != nil {
+				return fmt.Errorf("parsing deal uuid '%s': %w", u, err)
+			}
+			dealUUIDs = append(dealUUIDs, dealUUID)
+		}
+		if len(dealUUIDs) > 1 && !cctx.Bool("watch") {
+			return fmt.Errorf("multiple deal uuids can only be specified with --watch")
 		}
 
 		n, err := clinode.Setup(cctx.String(cmd.FlagRepo.Name))
@@ -80,75 +91,103 @@ var dealStatusCmd = &cli.Command{
 		}
 
 		dc := lp2pimpl.NewDealClient(n.Host, walletAddr, node.DealProposalSigner{LocalWallet: n.Wallet})
-		resp, err := dc.SendDealStatusRequest(ctx, addrInfo.ID, dealUUID)
+		if cctx.Bool("watch") {
+			updates, err := dc.SubscribeDealStatus(ctx, addrInfo.ID, dealUUIDs)
+			if err != nil {
+				return fmt.Errorf("send deal status subscribe request failed: %w", err)
+			}
+
+			// The provider closes the stream once all the deals have
+			// completed
+			for resp := range updates {
+				resp := resp
+				if err := printDealStatus(cctx, maddr, walletAddr, &resp); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		resp, err := dc.SendDealStatusRequest(ctx, addrInfo.ID, dealUUIDs[0])
 		if err != nil {
 			return fmt.Errorf("send deal status request failed: %w", err)
 		}
 
-		var lstr string
-		if resp != nil && resp.DealStatus != nil {
-			label := resp.DealStatus.Proposal.Label
-			if label.IsString() {
-				lstr, err = label.ToString()
-				if err != nil {
-					lstr = "could not marshall deal label"
-				}
+		return printDealStatus(cctx, maddr, walletAddr, resp)
+	},
+}
+
+func printDealStatus(cctx *cli.Context, maddr address.Address, walletAddr address.Address, resp *types.DealStatusResponse) error {
+	var err error
+	var lstr string
+	if resp != nil && resp.DealStatus != nil {
+		label := resp.DealStatus.Proposal.Label
+		if label.IsString() {
+			lstr, err = label.ToString()
+			if err != nil {
+				lstr = "could not marshall deal label"
+			}
+		} else {
+			lbz, err := label.ToBytes()
+			if err != nil {
+				lstr = "could not marshall deal label"
 			} else {
-				lbz, err := label.ToBytes()
-				if err != nil {
-					lstr = "could not marshall deal label"
-				} else {
-					lstr = "bytes: " + hex.EncodeToString(lbz)
-				}
+				lstr = "bytes: " + hex.EncodeToString(lbz)
 			}
 		}
+	}
 
-		if cctx.Bool("json") {
-			out := map[string]interface{}{}
-			if resp.Error != "" {
-				out["error"] = resp.Error
-			} else {
-				out = map[string]interface{}{
-					"dealUuid":     resp.DealUUID.String(),
-					"provider":     maddr.String(),
-					"clientWallet": walletAddr.String(),
-				}
-				// resp.DealStatus should always be present if there's no error,
-				// but check just in case
-				if resp.DealStatus != nil {
-					out["label"] = lstr
-					out["chainDealId"] = resp.DealStatus.ChainDealID
-					out["status"] = resp.DealStatus.Status
-					out["sealingStatus"] = resp.DealStatus.SealingStatus
-					out["statusMessage"] = statusMessage(resp)
-					out["publishCid"] = nil
-					if resp.DealStatus.PublishCid != nil {
-						out["publishCid"] = resp.DealStatus.PublishCid.String()
-					}
+	if cctx.Bool("json") {
+		out := map[string]interface{}{}
+		if resp.Error != "" {
+			out["error"] = resp.Error
+			if cctx.Bool("watch") {
+				out["dealUuid"] = resp.DealUUID.String()
+			}
+		} else {
+			out = map[string]interface{}{
+				"dealUuid":     resp.DealUUID.String(),
+				"provider":     maddr.String(),
+				"clientWallet": walletAddr.String(),
+			}
+			// resp.DealStatus should always be present if there's no error,
+			// but check just in case
+			if resp.DealStatus != nil {
+				out["label"] = lstr
+				out["chainDealId"] = resp.DealStatus.ChainDealID
+				out["status"] = resp.DealStatus.Status
+				out["sealingStatus"] = resp.DealStatus.SealingStatus
+				out["statusMessage"] = statusMessage(resp)
+				out["publishCid"] = nil
+				if resp.DealStatus.PublishCid != nil {
+					out["publishCid"] = resp.DealStatus.PublishCid.String()
 				}
 			}
-			return cmd.PrintJson(out)
 		}
+		return cmd.PrintJson(out)
+	}
 
-		msg := "got deal status response"
-		msg += "\n"
-
-		if resp.Error != "" {
-			msg += fmt.Sprintf("  error: %s\n", resp.Error)
-			fmt.Println(msg)
+	msg := "got deal status response"
+	msg += "\n"
 
-			return nil
+	if resp.Error != "" {
+		if cctx.Bool("watch") {
+			msg += fmt.Sprintf("  deal uuid: %s\n", resp.DealUUID)
 		}
-
-		msg += fmt.Sprintf("  deal uuid: %s\n", resp.DealUUID)
-		msg += fmt.Sprintf("  deal status: %s\n", statusMessage(resp))
-		msg += fmt.Sprintf("  deal label: %s\n", lstr)
-		msg += fmt.Sprintf("  publish cid: %s\n", resp.DealStatus.PublishCid)
-		msg += fmt.Sprintf("  chain deal id: %d\n", resp.DealStatus.ChainDealID)
+		msg += fmt.Sprintf("  error: %s\n", resp.Error)
 		fmt.Println(msg)
 
 		return nil
-	},
+	}
+
+	msg += fmt.Sprintf("  deal uuid: %s\n", resp.DealUUID)
+	msg += fmt.Sprintf("  deal status: %s\n", statusMessage(resp))
+	msg += fmt.Sprintf("  deal label: %s\n", lstr)
+	msg += fmt.Sprintf("  publish cid: %s\n", resp.DealStatus.PublishCid)
+	msg += fmt.Sprintf("  chain deal id: %d\n", resp.DealStatus.ChainDealID)
+	fmt.Println(msg)
+
+	return nil
 }
 
 // statusMessage is based on dealResolver.Message
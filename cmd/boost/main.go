@@ -39,8 +39,11 @@ func main() {
 			dealStatusCmd,
 			retrieveCmd,
 			offlineDealCmd,
+			replicateCmd,
+			serveCmd,
 			providerCmd,
 			walletCmd,
+			nitroCmd,
 		},
 	}
 	app.Setup()
@@ -62,10 +65,12 @@ func main() {
 
 func before(cctx *cli.Context) error {
 	_ = logging.SetLogLevel("boost", "INFO")
+	_ = logging.SetLogLevel("boost-client", "INFO")
 
 	if cliutil.IsVeryVerbose {
 		_ = logging.SetLogLevel("boost", "DEBUG")
 		_ = logging.SetLogLevel("boost-net", "DEBUG")
+		_ = logging.SetLogLevel("boost-client", "DEBUG")
 	}
 
 	cctx.App.Metadata["json"] = cctx.Bool("json")
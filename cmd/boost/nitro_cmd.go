@@ -0,0 +1,445 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/filecoin-project/boost/cmd"
+	"github.com/filecoin-project/boostd-data/shared/cliutil"
+	"github.com/statechannels/go-nitro/node/query"
+	"github.com/statechannels/go-nitro/payments"
+	"github.com/statechannels/go-nitro/types"
+	"github.com/urfave/cli/v2"
+)
+
+// The default endpoint of the client's nitro node
+const defaultNitroEndpoint = "127.0.0.1:4005/api/v1"
+
+// The challenge duration for channels created by the client
+const nitroChallengeDuration = 100
+
+var nitroCmd = &cli.Command{
+	Name:  "nitro",
+	Usage: "Manage nitro payment channels and make paid retrievals",
+	Flags: []cli.Flag{
+		cmd.FlagNitroEndpoint(defaultNitroEndpoint),
+	},
+	Subcommands: []*cli.Command{
+		nitroAddressCmd,
+		nitroCreateLedgerCmd,
+		nitroOpenChannelCmd,
+		nitroListChannelsCmd,
+		nitroChannelCmd,
+		nitroCloseChannelCmd,
+		nitroRetrieveHttpCmd,
+	},
+}
+
+var nitroAddressCmd = &cli.Command{
+	Name:  "address",
+	Usage: "Print the address of the client's nitro node",
+	Action: func(cctx *cli.Context) error {
+		client, err := cmd.NitroClient(cctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close() //nolint:errcheck
+
+		addr, err := client.Address()
+		if err != nil {
+			return fmt.Errorf("getting nitro node address: %w", err)
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(map[string]interface{}{"address": addr})
+		}
+		fmt.Println(addr)
+		return nil
+	},
+}
+
+var nitroCreateLedgerCmd = &cli.Command{
+	Name:  "create-ledger",
+	Usage: "Create a ledger channel with a nitro node (eg a payment hub) that can fund payment channels",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "counterparty",
+			Usage:    "the address of the nitro node to create the ledger channel with",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "amount",
+			Usage:    "the amount to deposit into the ledger channel",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "counterparty-amount",
+			Usage: "the amount that the counterparty deposits into the ledger channel",
+			Value: "0",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		ctx := cliutil.ReqContext(cctx)
+
+		counterparty, err := parseNitroAddress(cctx.String("counterparty"))
+		if err != nil {
+			return err
+		}
+		amount, err := cmd.ParseNitroAmount(cctx.String("amount"))
+		if err != nil {
+			return err
+		}
+		counterpartyAmount, err := cmd.ParseNitroAmount(cctx.String("counterparty-amount"))
+		if err != nil {
+			return err
+		}
+
+		client, err := cmd.NitroClient(cctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close() //nolint:errcheck
+
+		addr, err := client.Address()
+		if err != nil {
+			return fmt.Errorf("getting nitro node address: %w", err)
+		}
+
+		outcome := cmd.NitroOutcome(addr, amount, counterparty, counterpartyAmount)
+		resp, err := client.CreateLedgerChannel(counterparty, nitroChallengeDuration, outcome)
+		if err != nil {
+			return fmt.Errorf("creating ledger channel with %s: %w", counterparty, err)
+		}
+
+		fmt.Printf("Waiting for ledger channel %s to be funded\n", resp.ChannelId)
+		select {
+		case <-client.ObjectiveCompleteChan(resp.Id):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(map[string]interface{}{"channelId": resp.ChannelId})
+		}
+		fmt.Printf("Created ledger channel %s\n", resp.ChannelId)
+		return nil
+	},
+}
+
+var nitroOpenChannelCmd = &cli.Command{
+	Name:  "open-channel",
+	Usage: "Open a payment channel to a storage provider's nitro node",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "provider-address",
+			Usage:    "the address of the storage provider's nitro node (see boostd nitro address)",
+			Required: true,
+		},
+		&cli.StringSliceFlag{
+			Name:  "intermediary",
+			Usage: "the address of a nitro node (eg a payment hub) that the payment channel is funded through",
+		},
+		&cli.StringFlag{
+			Name:     "amount",
+			Usage:    "the amount to deposit into the payment channel",
+			Required: true,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		ctx := cliutil.ReqContext(cctx)
+
+		provider, err := parseNitroAddress(cctx.String("provider-address"))
+		if err != nil {
+			return err
+		}
+		var intermediaries []types.Address
+		for _, s := range cctx.StringSlice("intermediary") {
+			addr, err := parseNitroAddress(s)
+			if err != nil {
+				return err
+			}
+			intermediaries = append(intermediaries, addr)
+		}
+		amount, err := cmd.ParseNitroAmount(cctx.String("amount"))
+		if err != nil {
+			return err
+		}
+
+		client, err := cmd.NitroClient(cctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close() //nolint:errcheck
+
+		addr, err := client.Address()
+		if err != nil {
+			return fmt.Errorf("getting nitro node address: %w", err)
+		}
+
+		outcome := cmd.NitroOutcome(addr, amount, provider, big.NewInt(0))
+		resp, err := client.CreatePaymentChannel(intermediaries, provider, nitroChallengeDuration, outcome)
+		if err != nil {
+			return fmt.Errorf("opening payment channel to %s: %w", provider, err)
+		}
+
+		fmt.Printf("Waiting for payment channel %s to open\n", resp.ChannelId)
+		select {
+		case <-client.ObjectiveCompleteChan(resp.Id):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(map[string]interface{}{"channelId": resp.ChannelId})
+		}
+		fmt.Printf("Opened payment channel %s\n", resp.ChannelId)
+		return nil
+	},
+}
+
+var nitroListChannelsCmd = &cli.Command{
+	Name:  "list-channels",
+	Usage: "List outbound payment channels, and the ledger channels that fund them",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "include payment channels that have been closed",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		client, err := cmd.NitroClient(cctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close() //nolint:errcheck
+
+		ledgers, paychs, err := cmd.ListNitroChannels(client, false, cctx.Bool("all"))
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(map[string]interface{}{
+				"ledgerChannels":  ledgers,
+				"paymentChannels": paychs,
+			})
+		}
+
+		fmt.Println("Ledger channels:")
+		cmd.PrintNitroLedgerChannels(ledgers)
+		fmt.Println()
+		fmt.Println("Outbound payment channels:")
+		cmd.PrintNitroPaymentChannels(paychs)
+		return nil
+	},
+}
+
+var nitroChannelCmd = &cli.Command{
+	Name:      "channel",
+	Usage:     "Show the status and balance of a payment channel",
+	ArgsUsage: "<channel id>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return fmt.Errorf("must specify a channel id")
+		}
+		chID, err := cmd.ParseNitroChannelID(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		client, err := cmd.NitroClient(cctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close() //nolint:errcheck
+
+		ch, err := client.GetPaymentChannel(chID)
+		if err != nil {
+			return fmt.Errorf("getting payment channel %s: %w", chID, err)
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(ch)
+		}
+		cmd.PrintNitroPaymentChannels([]query.PaymentChannelInfo{ch})
+		return nil
+	},
+}
+
+var nitroCloseChannelCmd = &cli.Command{
+	Name:      "close-channel",
+	Usage:     "Close a payment channel, returning the unspent funds to the ledger channel that funds it",
+	ArgsUsage: "<channel id>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "wait",
+			Usage: "wait for the channel to close",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return fmt.Errorf("must specify a channel id")
+		}
+		chID, err := cmd.ParseNitroChannelID(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		client, err := cmd.NitroClient(cctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close() //nolint:errcheck
+
+		objID, err := client.ClosePaymentChannel(chID)
+		if err != nil {
+			return fmt.Errorf("closing payment channel %s: %w", chID, err)
+		}
+		return cmd.WaitForNitroObjective(cctx, client, objID, fmt.Sprintf("payment channel %s", chID))
+	},
+}
+
+var nitroRetrieveHttpCmd = &cli.Command{
+	Name:      "retrieve-http",
+	Usage:     "Retrieve content from booster-http, paying with a nitro payment channel",
+	ArgsUsage: "<url>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "channel",
+			Usage:    "the id of the payment channel to pay from",
+			Required: true,
+		},
+		flagOutput,
+		&cli.StringFlag{
+			Name:  "max-price",
+			Usage: "the maximum price to pay for the content (no limit if not set)",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		ctx := cliutil.ReqContext(cctx)
+
+		if cctx.Args().Len() != 1 {
+			return fmt.Errorf("must specify a url")
+		}
+		u := cctx.Args().First()
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return fmt.Errorf("parsing url '%s': %w", u, err)
+		}
+
+		chID, err := cmd.ParseNitroChannelID(cctx.String("channel"))
+		if err != nil {
+			return err
+		}
+		var maxPrice *big.Int
+		if cctx.IsSet("max-price") {
+			maxPrice, err = cmd.ParseNitroAmount(cctx.String("max-price"))
+			if err != nil {
+				return err
+			}
+		}
+
+		output := cctx.String(flagOutput.Name)
+		if output == "" {
+			output = path.Base(parsed.Path)
+		}
+		if _, err := os.Stat(output); err == nil {
+			return fmt.Errorf("there is already a file at output path %s", output)
+		}
+
+		client, err := cmd.NitroClient(cctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close() //nolint:errcheck
+
+		// Make a request without a voucher to find out the price
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("requesting %s: %w", u, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusPaymentRequired {
+			var pr struct {
+				Price string
+				Payee string
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+				return fmt.Errorf("decoding payment required response: %w", err)
+			}
+			price, ok := new(big.Int).SetString(pr.Price, 10)
+			if !ok || !price.IsUint64() {
+				return fmt.Errorf("invalid price '%s' in payment required response", pr.Price)
+			}
+			if maxPrice != nil && price.Cmp(maxPrice) > 0 {
+				return fmt.Errorf("price %s is higher than max price %s", price, maxPrice)
+			}
+
+			// Pay for the content and make the request again
+			v, err := client.CreateVoucher(chID, price.Uint64())
+			if err != nil {
+				return fmt.Errorf("creating voucher for %s on channel %s: %w", price, chID, err)
+			}
+			fmt.Printf("Paying %s to %s\n", price, pr.Payee)
+
+			req, err = http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("X-Payment", voucherParams(v))
+			resp, err = http.DefaultClient.Do(req)
+			if err != nil {
+				return fmt.Errorf("requesting %s: %w", u, err)
+			}
+			defer resp.Body.Close()
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			return fmt.Errorf("request for %s failed with status %d: %s", u, resp.StatusCode, body)
+		}
+
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		n, err := io.Copy(f, resp.Body)
+		if err != nil {
+			return fmt.Errorf("writing output to %s: %w", output, err)
+		}
+		fmt.Printf("Saved %d bytes to %s\n", n, output)
+		return nil
+	},
+}
+
+// voucherParams encodes a voucher in the format expected by booster-http
+func voucherParams(v payments.Voucher) string {
+	sig := append(append(append([]byte{}, v.Signature.R...), v.Signature.S...), v.Signature.V)
+	params := url.Values{}
+	params.Set("channelId", v.ChannelId.String())
+	params.Set("amount", v.Amount.String())
+	params.Set("signature", hexutil.Encode(sig))
+	return params.Encode()
+}
+
+func parseNitroAddress(s string) (types.Address, error) {
+	if !common.IsHexAddress(s) {
+		return types.Address{}, fmt.Errorf("invalid nitro address '%s'", s)
+	}
+	return common.HexToAddress(s), nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"math/big"
+	"net/url"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/filecoin-project/boost/cmd"
+	"github.com/statechannels/go-nitro/crypto"
+	"github.com/statechannels/go-nitro/payments"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVoucherParams(t *testing.T) {
+	chID, err := cmd.ParseNitroChannelID("0x1234567890123456789012345678901234567890123456789012345678901234")
+	require.NoError(t, err)
+
+	sig := append(append(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)...), 27)
+	v := payments.Voucher{
+		ChannelId: chID,
+		Amount:    big.NewInt(100),
+		Signature: crypto.SplitSignature(sig),
+	}
+
+	params, err := url.ParseQuery(voucherParams(v))
+	require.NoError(t, err)
+	require.Equal(t, chID.String(), params.Get("channelId"))
+	require.Equal(t, "100", params.Get("amount"))
+	require.Equal(t, hexutil.Encode(sig), params.Get("signature"))
+
+	// A channel id must be 32 bytes
+	_, err = cmd.ParseNitroChannelID("0x1234")
+	require.Error(t, err)
+}
@@ -11,6 +11,7 @@ import (
 	clinode "github.com/filecoin-project/boost/cli/node"
 	"github.com/filecoin-project/boost/cmd"
 	"github.com/filecoin-project/boost/retrievalmarket/lp2pimpl"
+	slp2pimpl "github.com/filecoin-project/boost/storagemarket/lp2pimpl"
 	"github.com/filecoin-project/boostd-data/shared/cliutil"
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/lotus/chain/types"
@@ -34,6 +35,7 @@ var providerCmd = &cli.Command{
 		storageAskCmd,
 		retrievalAskCmd,
 		retrievalTransportsCmd,
+		storageTransportsCmd,
 	},
 }
 
@@ -394,6 +396,76 @@ var retrievalTransportsCmd = &cli.Command{
 	},
 }
 
+var storageTransportsCmd = &cli.Command{
+	Name:      "storage-transports",
+	Usage:     "Query the transfer types a storage provider supports for downloading deal data (http, libp2p, s3, etc)",
+	ArgsUsage: "[provider]",
+	Action: func(cctx *cli.Context) error {
+		ctx := bcli.ReqContext(cctx)
+
+		afmt := NewAppFmt(cctx.App)
+		if cctx.NArg() != 1 {
+			afmt.Println("Usage: storage-transports [provider]")
+			return nil
+		}
+
+		n, err := clinode.Setup(cctx.String(cmd.FlagRepo.Name))
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := lcli.GetGatewayAPI(cctx)
+		if err != nil {
+			return fmt.Errorf("cant setup gateway connection: %w", err)
+		}
+		defer closer()
+
+		maddr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		addrInfo, err := cmd.GetAddrInfo(ctx, api, maddr)
+		if err != nil {
+			return err
+		}
+
+		log.Debugw("found storage provider", "id", addrInfo.ID, "multiaddrs", addrInfo.Addrs, "addr", maddr)
+
+		if err := n.Host.Connect(ctx, *addrInfo); err != nil {
+			return fmt.Errorf("failed to connect to peer %s: %w", addrInfo.ID, err)
+		}
+
+		x, err := n.Host.Peerstore().FirstSupportedProtocol(addrInfo.ID, slp2pimpl.TransportsV10ProtocolID)
+		if err != nil {
+			return fmt.Errorf("getting protocols for peer %s: %w", addrInfo.ID, err)
+		}
+		if len(x) == 0 {
+			return fmt.Errorf("storage provider %s does not support the storage transports query", maddr)
+		}
+
+		// Send the query to the Storage Provider
+		client := slp2pimpl.NewDealClient(n.Host, address.Undef, nil)
+		resp, err := client.SendTransportsQuery(ctx, addrInfo.ID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch transfer types from peer %s: %w", addrInfo.ID, err)
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(map[string]interface{}{"transferTypes": resp.TransferTypes})
+		}
+
+		if len(resp.TransferTypes) == 0 {
+			afmt.Println("No available transfer types")
+			return nil
+		}
+		for _, t := range resp.TransferTypes {
+			afmt.Println(t)
+		}
+		return nil
+	},
+}
+
 func multiaddrToNative(proto string, ma multiaddr.Multiaddr) string {
 	switch proto {
 	case "http", "https":
@@ -0,0 +1,590 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/filecoin-project/boost-gfm/storagemarket/network"
+	bcli "github.com/filecoin-project/boost/cli"
+	clinode "github.com/filecoin-project/boost/cli/node"
+	"github.com/filecoin-project/boost/client"
+	"github.com/filecoin-project/boost/cmd"
+	"github.com/filecoin-project/boost/db"
+	rlp2pimpl "github.com/filecoin-project/boost/retrievalmarket/lp2pimpl"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	"github.com/filecoin-project/lotus/api"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	"github.com/google/uuid"
+	"github.com/urfave/cli/v2"
+)
+
+var replicateCmd = &cli.Command{
+	Name:  "replicate",
+	Usage: "Store copies of a CAR file with several storage providers",
+	Description: "Queries the storage ask and retrieval transports of each candidate provider, in order, and proposes " +
+		"deals until the required number of copies are accepted. Run `boost replicate run` to track the copies and " +
+		"replace copies that fail or expire.",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "provider",
+			Usage: "candidate storage provider on-chain address, in order of preference (can be repeated)",
+		},
+		&cli.IntFlag{
+			Name:  "copies",
+			Usage: "the number of copies to store",
+			Value: 3,
+		},
+		&cli.StringFlag{
+			Name:  "commp",
+			Usage: "commp of the CAR file",
+		},
+		&cli.Uint64Flag{
+			Name:  "piece-size",
+			Usage: "size of the CAR file as a padded piece",
+		},
+		&cli.StringFlag{
+			Name:  "payload-cid",
+			Usage: "root CID of the CAR file",
+		},
+		&cli.StringFlag{
+			Name:  "http-url",
+			Usage: "http url to CAR file (required unless --offline is set)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "http-headers",
+			Usage: "http headers to be passed with the request (e.g key=value)",
+		},
+		&cli.Uint64Flag{
+			Name:  "car-size",
+			Usage: "size of the CAR file (required unless --offline is set)",
+		},
+		&cli.BoolFlag{
+			Name:  "offline",
+			Usage: "make offline deals: the CAR file must be imported by each provider",
+		},
+		&cli.IntFlag{
+			Name:  "start-epoch-head-offset",
+			Usage: "start epoch by when each deal should be proved by the provider on-chain, after the chain head at the time the deal is proposed",
+			Value: 5760, // 2 days
+		},
+		&cli.IntFlag{
+			Name:  "duration",
+			Usage: "duration of each deal in epochs",
+			Value: 518400, // default is 2880 * 180 == 180 days
+		},
+		&cli.Int64Flag{
+			Name:  "max-storage-price",
+			Usage: "the maximum storage price in attoFIL per epoch per GiB; providers with a higher ask price are skipped",
+		},
+		&cli.StringSliceFlag{
+			Name:  "require-transport",
+			Usage: "only use providers that support this retrieval transport, eg http (can be repeated)",
+		},
+		&cli.BoolFlag{
+			Name:  "verified",
+			Usage: "whether the deal funds should come from verified client data-cap",
+			Value: true,
+		},
+		&cli.BoolFlag{
+			Name:  "remove-unsealed-copy",
+			Usage: "indicates that an unsealed copy of the sector in not required for fast retrieval",
+		},
+		&cli.BoolFlag{
+			Name:  "skip-ipni-announce",
+			Usage: "indicates that deal index should not be announced to the IPNI(Network Indexer)",
+		},
+		&cli.StringFlag{
+			Name:  "wallet",
+			Usage: "wallet address to be used to initiate the deals",
+		},
+	},
+	Before: before,
+	Action: replicateCmdAction,
+	Subcommands: []*cli.Command{
+		replicateListCmd,
+		replicateStatusCmd,
+		replicateRunCmd,
+		replicateStopCmd,
+	},
+}
+
+func replicateCmdAction(cctx *cli.Context) error {
+	ctx := bcli.ReqContext(cctx)
+
+	// The flags are not marked as required, because that would prevent the
+	// replicate subcommands from running without them
+	if !cctx.IsSet("provider") {
+		return fmt.Errorf("the --provider flag is required")
+	}
+	if cctx.Int("copies") <= 0 {
+		return fmt.Errorf("the number of copies must be at least 1")
+	}
+	isOnline := !cctx.Bool("offline")
+	required := []string{"commp", "piece-size", "payload-cid"}
+	if isOnline {
+		required = append(required, "http-url", "car-size")
+	}
+	for _, name := range required {
+		if !cctx.IsSet(name) {
+			return fmt.Errorf("the --%s flag is required", name)
+		}
+	}
+
+	var providers []address.Address
+	for _, p := range cctx.StringSlice("provider") {
+		addr, err := address.NewFromString(p)
+		if err != nil {
+			return fmt.Errorf("parsing provider address '%s': %w", p, err)
+		}
+		providers = append(providers, addr)
+	}
+	if len(providers) < cctx.Int("copies") {
+		return fmt.Errorf("%d copies requested but only %d candidate providers specified", cctx.Int("copies"), len(providers))
+	}
+
+	headers, err := httpHeadersFromFlags(cctx)
+	if err != nil {
+		return err
+	}
+	spec, err := newDealSpec(dealManifestEntry{
+		Commp:       cctx.String("commp"),
+		PieceSize:   cctx.Uint64("piece-size"),
+		PayloadCid:  cctx.String("payload-cid"),
+		HttpUrl:     cctx.String("http-url"),
+		HttpHeaders: headers,
+		CarSize:     cctx.Uint64("car-size"),
+	}, isOnline)
+	if err != nil {
+		return err
+	}
+
+	n, err := clinode.Setup(cctx.String(cmd.FlagRepo.Name))
+	if err != nil {
+		return err
+	}
+
+	api, closer, err := lcli.GetGatewayAPI(cctx)
+	if err != nil {
+		return fmt.Errorf("cant setup gateway connection: %w", err)
+	}
+	defer closer()
+
+	walletAddr, err := n.GetProvidedOrDefaultWallet(ctx, cctx.String("wallet"))
+	if err != nil {
+		return err
+	}
+
+	log.Debugw("selected wallet", "wallet", walletAddr)
+
+	rep := &db.ClientReplication{
+		ID:                 uuid.New(),
+		Copies:             cctx.Int("copies"),
+		Active:             true,
+		ClientAddress:      walletAddr,
+		PieceCID:           spec.pieceCid,
+		PieceSize:          spec.pieceSize,
+		PayloadCID:         spec.rootCid,
+		IsOffline:          !isOnline,
+		Verified:           cctx.Bool("verified"),
+		Transfer:           spec.transfer,
+		RemoveUnsealedCopy: cctx.Bool("remove-unsealed-copy"),
+		SkipIPNIAnnounce:   cctx.Bool("skip-ipni-announce"),
+		StartEpochOffset:   abi.ChainEpoch(cctx.Int("start-epoch-head-offset")),
+		Duration:           abi.ChainEpoch(cctx.Int("duration")),
+		Providers:          providers,
+		Transports:         cctx.StringSlice("require-transport"),
+	}
+	if cctx.IsSet("max-storage-price") {
+		maxPrice := abi.NewTokenAmount(cctx.Int64("max-storage-price"))
+		rep.MaxPrice = &maxPrice
+	}
+
+	r, cdb, err := newReplicator(ctx, cctx, n, api)
+	if err != nil {
+		return err
+	}
+	if err := cdb.InsertReplication(ctx, rep); err != nil {
+		return err
+	}
+
+	st, err := r.Replicate(ctx, rep)
+	if err != nil {
+		return err
+	}
+	if err := printReplicationStatus(cctx, st); err != nil {
+		return err
+	}
+
+	if live := st.Live(); live < rep.Copies {
+		return fmt.Errorf("only %d of %d copies were accepted by providers", live, rep.Copies)
+	}
+	return nil
+}
+
+var replicateListCmd = &cli.Command{
+	Name:   "list",
+	Usage:  "List replications",
+	Before: before,
+	Action: func(cctx *cli.Context) error {
+		ctx := bcli.ReqContext(cctx)
+
+		cdb, err := openClientDealsDB(ctx, cctx)
+		if err != nil {
+			return err
+		}
+
+		reps, err := cdb.Replications(ctx, false)
+		if err != nil {
+			return fmt.Errorf("listing replications: %w", err)
+		}
+
+		if cctx.Bool("json") {
+			out := make([]map[string]interface{}, 0, len(reps))
+			for _, rep := range reps {
+				out = append(out, map[string]interface{}{
+					"id":         rep.ID.String(),
+					"createdAt":  rep.CreatedAt.Format(time.RFC3339),
+					"copies":     rep.Copies,
+					"active":     rep.Active,
+					"commp":      rep.PieceCID.String(),
+					"payloadCid": rep.PayloadCID.String(),
+				})
+			}
+			return cmd.PrintJson(out)
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("Created"),
+			tablewriter.Col("ID"),
+			tablewriter.Col("Piece CID"),
+			tablewriter.Col("Copies"),
+			tablewriter.Col("Active"))
+		for _, rep := range reps {
+			tw.Write(map[string]interface{}{
+				"Created":   rep.CreatedAt.Format(time.RFC3339),
+				"ID":        rep.ID,
+				"Piece CID": rep.PieceCID,
+				"Copies":    rep.Copies,
+				"Active":    rep.Active,
+			})
+		}
+		return tw.Flush(os.Stdout)
+	},
+}
+
+var replicateStatusCmd = &cli.Command{
+	Name:      "status",
+	Usage:     "Show the state of each copy of a replication's data",
+	ArgsUsage: "<replication id>",
+	Before:    before,
+	Action: func(cctx *cli.Context) error {
+		ctx := bcli.ReqContext(cctx)
+
+		id, err := replicationIDArg(cctx)
+		if err != nil {
+			return err
+		}
+
+		n, err := clinode.Setup(cctx.String(cmd.FlagRepo.Name))
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := lcli.GetGatewayAPI(cctx)
+		if err != nil {
+			return fmt.Errorf("cant setup gateway connection: %w", err)
+		}
+		defer closer()
+
+		r, cdb, err := newReplicator(ctx, cctx, n, api)
+		if err != nil {
+			return err
+		}
+
+		rep, err := cdb.ReplicationByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("getting replication %s: %w", id, err)
+		}
+
+		st, err := r.Status(ctx, rep)
+		if err != nil {
+			return err
+		}
+		return printReplicationStatus(cctx, st)
+	},
+}
+
+var replicateRunCmd = &cli.Command{
+	Name:  "run",
+	Usage: "Refresh the status of each copy, and replace copies that fail or expire",
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:  "interval",
+			Usage: "keep running, checking replications at this interval (eg 10m)",
+		},
+	},
+	Before: before,
+	Action: func(cctx *cli.Context) error {
+		ctx := bcli.ReqContext(cctx)
+
+		n, err := clinode.Setup(cctx.String(cmd.FlagRepo.Name))
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := lcli.GetGatewayAPI(cctx)
+		if err != nil {
+			return fmt.Errorf("cant setup gateway connection: %w", err)
+		}
+		defer closer()
+
+		r, _, err := newReplicator(ctx, cctx, n, api)
+		if err != nil {
+			return err
+		}
+
+		if cctx.IsSet("interval") {
+			r.Run(ctx, cctx.Duration("interval"))
+			return nil
+		}
+		return r.Check(ctx)
+	},
+}
+
+var replicateStopCmd = &cli.Command{
+	Name:      "stop",
+	Usage:     "Stop replacing copies of a replication's data that fail or expire",
+	ArgsUsage: "<replication id>",
+	Before:    before,
+	Action: func(cctx *cli.Context) error {
+		ctx := bcli.ReqContext(cctx)
+
+		id, err := replicationIDArg(cctx)
+		if err != nil {
+			return err
+		}
+
+		cdb, err := openClientDealsDB(ctx, cctx)
+		if err != nil {
+			return err
+		}
+
+		if err := cdb.SetReplicationActive(ctx, id, false); err != nil {
+			return fmt.Errorf("stopping replication %s: %w", id, err)
+		}
+		fmt.Printf("stopped replication %s\n", id)
+		return nil
+	},
+}
+
+func replicationIDArg(cctx *cli.Context) (uuid.UUID, error) {
+	if cctx.Args().Len() != 1 {
+		return uuid.UUID{}, fmt.Errorf("must specify the replication id")
+	}
+	id, err := uuid.Parse(cctx.Args().First())
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("parsing replication id '%s': %w", cctx.Args().First(), err)
+	}
+	return id, nil
+}
+
+func printReplicationStatus(cctx *cli.Context, st *client.ReplicationStatus) error {
+	rep := st.Replication
+	skipped := make([]address.Address, 0, len(st.Skipped))
+	for p := range st.Skipped {
+		skipped = append(skipped, p)
+	}
+	sort.Slice(skipped, func(i, j int) bool { return skipped[i].String() < skipped[j].String() })
+
+	if cctx.Bool("json") {
+		replicas := make([]map[string]interface{}, 0, len(st.Replicas))
+		for _, r := range st.Replicas {
+			replicas = append(replicas, map[string]interface{}{
+				"dealUuid":    r.Deal.ID.String(),
+				"provider":    r.Deal.ProviderAddress.String(),
+				"state":       r.State,
+				"status":      r.Deal.Status,
+				"message":     r.Deal.Message,
+				"chainDealId": r.Deal.ChainDealID,
+			})
+		}
+		skippedOut := make(map[string]string, len(skipped))
+		for _, p := range skipped {
+			skippedOut[p.String()] = st.Skipped[p]
+		}
+		return cmd.PrintJson(map[string]interface{}{
+			"id":        rep.ID.String(),
+			"copies":    rep.Copies,
+			"active":    rep.Active,
+			"live":      st.Live(),
+			"published": st.Count(client.ReplicaPublished, client.ReplicaSealed),
+			"sealed":    st.Count(client.ReplicaSealed),
+			"commp":     rep.PieceCID.String(),
+			"replicas":  replicas,
+			"skipped":   skippedOut,
+		})
+	}
+
+	msg := fmt.Sprintf("replication %s\n", rep.ID)
+	msg += fmt.Sprintf("  commp: %s\n", rep.PieceCID)
+	msg += fmt.Sprintf("  payload cid: %s\n", rep.PayloadCID)
+	msg += fmt.Sprintf("  copies: %d live of %d required (%d published, %d sealed)\n", st.Live(), rep.Copies,
+		st.Count(client.ReplicaPublished, client.ReplicaSealed), st.Count(client.ReplicaSealed))
+	if !rep.Active {
+		msg += "  stopped: copies that fail or expire are not replaced\n"
+	}
+	fmt.Println(msg)
+
+	tw := tablewriter.New(
+		tablewriter.Col("Deal UUID"),
+		tablewriter.Col("Provider"),
+		tablewriter.Col("State"),
+		tablewriter.Col("Status"),
+		tablewriter.Col("Chain Deal ID"),
+		tablewriter.NewLineCol("Message"))
+	for _, r := range st.Replicas {
+		row := map[string]interface{}{
+			"Deal UUID": r.Deal.ID,
+			"Provider":  r.Deal.ProviderAddress,
+			"State":     r.State,
+			"Status":    r.Deal.Status,
+		}
+		if r.Deal.ChainDealID != 0 {
+			row["Chain Deal ID"] = r.Deal.ChainDealID
+		}
+		if r.Deal.Message != "" {
+			row["Message"] = r.Deal.Message
+		}
+		tw.Write(row)
+	}
+	if err := tw.Flush(os.Stdout); err != nil {
+		return err
+	}
+
+	if len(skipped) > 0 {
+		fmt.Println("\nskipped providers:")
+		for _, p := range skipped {
+			fmt.Printf("  %s: %s\n", p, st.Skipped[p])
+		}
+	}
+	return nil
+}
+
+func newReplicator(ctx context.Context, cctx *cli.Context, n *clinode.Node, api api.Gateway) (*client.Replicator, *db.ClientDealsDB, error) {
+	cdb, err := openClientDealsDB(ctx, cctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proposer := newDealProposer(n, api)
+	return client.NewReplicator(cdb, proposer, &replicationNode{dealProposer: proposer}), cdb, nil
+}
+
+// replicationNode queries providers and creates deal proposals for the
+// replicator from the boost client node
+type replicationNode struct {
+	*dealProposer
+}
+
+var _ client.ReplicationNode = (*replicationNode)(nil)
+
+func (r *replicationNode) ChainHeight(ctx context.Context) (abi.ChainEpoch, error) {
+	head, err := r.api.ChainHead(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return head.Height(), nil
+}
+
+func (r *replicationNode) QueryProvider(ctx context.Context, maddr address.Address) (*client.ProviderInfo, error) {
+	id, err := r.connect(ctx, maddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the provider's storage ask
+	s, err := r.n.Host.NewStream(ctx, id, AskProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream to peer %s: %w", id, err)
+	}
+	defer s.Close()
+
+	var resp network.AskResponse
+	if err := doRpc(ctx, s, &network.AskRequest{Miner: maddr}, &resp); err != nil {
+		return nil, fmt.Errorf("send ask request rpc: %w", err)
+	}
+	if resp.Ask == nil || resp.Ask.Ask == nil {
+		return nil, fmt.Errorf("provider has no storage ask")
+	}
+	ask := resp.Ask.Ask
+
+	// Get the provider's retrieval transports
+	tresp, err := rlp2pimpl.NewTransportsClient(r.n.Host).SendQuery(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transports from peer %s: %w", id, err)
+	}
+	transports := make([]string, 0, len(tresp.Protocols))
+	for _, p := range tresp.Protocols {
+		transports = append(transports, p.Name)
+	}
+
+	return &client.ProviderInfo{
+		Address:       maddr,
+		Price:         ask.Price,
+		VerifiedPrice: ask.VerifiedPrice,
+		MinPieceSize:  ask.MinPieceSize,
+		MaxPieceSize:  ask.MaxPieceSize,
+		Transports:    transports,
+	}, nil
+}
+
+func (r *replicationNode) BuildDeal(ctx context.Context, rep *db.ClientReplication, provider address.Address, pricePerEpoch abi.TokenAmount) (*types.DealParams, error) {
+	head, err := r.api.ChainHead(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get chain head: %w", err)
+	}
+
+	collat, err := minProviderCollateral(ctx, r.api, rep.PieceSize, rep.Verified)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := market.NewLabelFromString(rep.PayloadCID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	startEpoch := head.Height() + rep.StartEpochOffset
+	signed, err := r.SignProposal(ctx, market.DealProposal{
+		PieceCID:             rep.PieceCID,
+		PieceSize:            rep.PieceSize,
+		VerifiedDeal:         rep.Verified,
+		Client:               rep.ClientAddress,
+		Provider:             provider,
+		Label:                l,
+		StartEpoch:           startEpoch,
+		EndEpoch:             startEpoch + rep.Duration,
+		StoragePricePerEpoch: pricePerEpoch,
+		ProviderCollateral:   collat,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.DealParams{
+		DealUUID:           uuid.New(),
+		ClientDealProposal: *signed,
+		DealDataRoot:       rep.PayloadCID,
+		IsOffline:          rep.IsOffline,
+		Transfer:           rep.Transfer,
+		RemoveUnsealedCopy: rep.RemoveUnsealedCopy,
+		SkipIPNIAnnounce:   rep.SkipIPNIAnnounce,
+	}, nil
+}
@@ -11,6 +11,7 @@ import (
 	flatfs "github.com/ipfs/go-ds-flatfs"
 	levelds "github.com/ipfs/go-ds-leveldb"
 	"github.com/mitchellh/go-homedir"
+	nitrotypes "github.com/statechannels/go-nitro/types"
 
 	"github.com/dustin/go-humanize"
 	clinode "github.com/filecoin-project/boost/cli/node"
@@ -63,6 +64,16 @@ var flagDmPathSel = &cli.StringFlag{
 	Usage: "a rudimentary (DM-level-only) text-path selector, allowing for sub-selection within a deal",
 }
 
+var flagPayWithNitro = &cli.BoolFlag{
+	Name:  "pay-with-nitro",
+	Usage: "pay for the retrieval with vouchers from a nitro payment channel",
+}
+
+var flagNitroChannel = &cli.StringFlag{
+	Name:  "nitro-channel",
+	Usage: "the id of the nitro payment channel to pay from (see boost nitro open-channel)",
+}
+
 var retrieveCmd = &cli.Command{
 	Name:      "retrieve",
 	Usage:     "Retrieve a file by payload CID from a miner",
@@ -72,6 +83,9 @@ var retrieveCmd = &cli.Command{
 		flagOutput,
 		flagDmPathSel,
 		flagCar,
+		flagPayWithNitro,
+		flagNitroChannel,
+		cmd.FlagNitroEndpoint(defaultNitroEndpoint),
 	},
 	Action: func(cctx *cli.Context) error {
 		ctx := cliutil.ReqContext(cctx)
@@ -104,6 +118,18 @@ var retrieveCmd = &cli.Command{
 			return fmt.Errorf("failed to parse miner %s: %w", cctx.String(flagProvider.Name), err)
 		}
 
+		payWithNitro := cctx.Bool(flagPayWithNitro.Name)
+		var nitroChannel nitrotypes.Destination
+		if payWithNitro {
+			if !cctx.IsSet(flagNitroChannel.Name) {
+				return fmt.Errorf("the --%s flag is required to pay with nitro", flagNitroChannel.Name)
+			}
+			nitroChannel, err = cmd.ParseNitroChannelID(cctx.String(flagNitroChannel.Name))
+			if err != nil {
+				return err
+			}
+		}
+
 		// Get the output path of the file
 		output := cctx.String("output")
 		if output == "" {
@@ -200,14 +226,34 @@ var retrieveCmd = &cli.Command{
 		}
 
 		// Retrieve the data
-		stats, err := fc.RetrieveContentWithProgressCallback(
-			ctx,
-			miner,
-			proposal,
-			func(bytesReceived_ uint64) {
-				printProgress(bytesReceived_)
-			},
-		)
+		var stats *rc.RetrievalStats
+		if payWithNitro {
+			nitroClient, nerr := cmd.NitroClient(cctx)
+			if nerr != nil {
+				return nerr
+			}
+			defer nitroClient.Close() //nolint:errcheck
+
+			stats, err = fc.RetrieveContentWithNitro(
+				ctx,
+				miner,
+				proposal,
+				nitroClient,
+				nitroChannel,
+				func(bytesReceived_ uint64) {
+					printProgress(bytesReceived_)
+				},
+			)
+		} else {
+			stats, err = fc.RetrieveContentWithProgressCallback(
+				ctx,
+				miner,
+				proposal,
+				func(bytesReceived_ uint64) {
+					printProgress(bytesReceived_)
+				},
+			)
+		}
 		if err != nil {
 			return fmt.Errorf("Failed to retrieve content with candidate miner %s: %v", miner, err)
 		}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	bcli "github.com/filecoin-project/boost/cli"
+	clinode "github.com/filecoin-project/boost/cli/node"
+	"github.com/filecoin-project/boost/client"
+	"github.com/filecoin-project/boost/cmd"
+	"github.com/filecoin-project/boost/transport/httptransport"
+	"github.com/filecoin-project/boost/transport/httptransport/util"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/mitchellh/go-homedir"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/urfave/cli/v2"
+)
+
+// serveEndpointsFile is the file in the repo that `boost serve` writes its
+// endpoints to while it is running
+const serveEndpointsFile = "serve.json"
+
+// serveEndpoints are the URLs at which storage providers can download deal
+// data from `boost serve`
+type serveEndpoints struct {
+	HttpURL   string `json:"httpUrl,omitempty"`
+	Libp2pURL string `json:"libp2pUrl,omitempty"`
+}
+
+var serveCmd = &cli.Command{
+	Name:  "serve",
+	Usage: "Serve local CAR files to storage providers for online deals made with `boost deal --serve-car`",
+	Description: "Serves CAR files over HTTP and over libp2p. Each deal gets its own auth token, which expires " +
+		"once the storage provider reports that the data has been transferred. While the server is running it " +
+		"also refreshes the status of deals that are in progress.",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "http-listen",
+			Usage: "the address to listen on for HTTP requests",
+			Value: "0.0.0.0:7777",
+		},
+		&cli.StringFlag{
+			Name:  "http-public-url",
+			Usage: "the URL at which storage providers can reach the HTTP server (eg http://203.0.113.1:7777); defaults to the listen address",
+		},
+		&cli.StringFlag{
+			Name:  "libp2p-listen",
+			Usage: "the multiaddr to listen on for libp2p requests",
+			Value: "/ip4/0.0.0.0/tcp/7778",
+		},
+		&cli.StringFlag{
+			Name:  "libp2p-public-addr",
+			Usage: "the multiaddr at which storage providers can reach the libp2p host (eg /ip4/203.0.113.1/tcp/7778); if not set, data is only served over HTTP",
+		},
+		&cli.DurationFlag{
+			Name:  "interval",
+			Usage: "the interval at which to refresh deal status and expire auth tokens",
+			Value: time.Minute,
+		},
+		&cli.DurationFlag{
+			Name:  "token-ttl",
+			Usage: "the time after which an auth token is expired if no deal was recorded for it",
+			Value: 24 * time.Hour,
+		},
+	},
+	Before: before,
+	Action: func(cctx *cli.Context) error {
+		ctx := bcli.ReqContext(cctx)
+
+		httpURL := cctx.String("http-public-url")
+		if httpURL == "" {
+			host, _, err := net.SplitHostPort(cctx.String("http-listen"))
+			if err != nil {
+				return fmt.Errorf("parsing http listen address: %w", err)
+			}
+			if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+				return fmt.Errorf("the --http-public-url flag is required when listening on all interfaces")
+			}
+			httpURL = "http://" + cctx.String("http-listen")
+		}
+
+		n, err := clinode.SetupServe(cctx.String(cmd.FlagRepo.Name), cctx.String("libp2p-listen"))
+		if err != nil {
+			return err
+		}
+		defer n.Host.Close()
+
+		endpoints := serveEndpoints{HttpURL: httpURL}
+		if cctx.IsSet("libp2p-public-addr") {
+			maddr, err := multiaddr.NewMultiaddr(cctx.String("libp2p-public-addr"))
+			if err != nil {
+				return fmt.Errorf("parsing libp2p public address: %w", err)
+			}
+			endpoints.Libp2pURL = util.Libp2pScheme + "://" + maddr.String() + "/p2p/" + n.Host.ID().String()
+		}
+
+		api, closer, err := lcli.GetGatewayAPI(cctx)
+		if err != nil {
+			return fmt.Errorf("cant setup gateway connection: %w", err)
+		}
+		defer closer()
+
+		cdb, err := openClientDealsDB(ctx, cctx)
+		if err != nil {
+			return err
+		}
+		mgr := client.NewDealManager(client.DealManagerConfig{}, cdb, newDealProposer(n, api))
+		tokens := client.NewTransferTokens(cdb, cctx.Duration("token-ttl"))
+
+		listener, err := net.Listen("tcp", cctx.String("http-listen"))
+		if err != nil {
+			return fmt.Errorf("listening on %s: %w", cctx.String("http-listen"), err)
+		}
+
+		srv := httptransport.NewCarFileServer(n.Host, tokens)
+		if err := srv.Start(ctx, listener); err != nil {
+			return fmt.Errorf("starting car file server: %w", err)
+		}
+		defer srv.Stop() //nolint:errcheck
+
+		// Write the endpoints to the repo so that `boost deal` can find them
+		endpointsPath, err := serveEndpointsPath(cctx)
+		if err != nil {
+			return err
+		}
+		bz, err := json.Marshal(endpoints)
+		if err != nil {
+			return fmt.Errorf("marshalling serve endpoints: %w", err)
+		}
+		if err := os.WriteFile(endpointsPath, bz, 0644); err != nil {
+			return fmt.Errorf("writing serve endpoints: %w", err)
+		}
+		defer os.Remove(endpointsPath) //nolint:errcheck
+
+		fmt.Printf("serving CAR files at %s\n", endpoints.HttpURL)
+		if endpoints.Libp2pURL != "" {
+			fmt.Printf("serving CAR files at %s\n", endpoints.Libp2pURL)
+		}
+
+		ticker := time.NewTicker(cctx.Duration("interval"))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+
+			if err := mgr.Refresh(ctx); err != nil && ctx.Err() == nil {
+				log.Warnw("refreshing deal statuses", "err", err)
+			}
+			if _, err := tokens.Expire(ctx); err != nil && ctx.Err() == nil {
+				log.Warnw("expiring transfer tokens", "err", err)
+			}
+		}
+	},
+}
+
+func serveEndpointsPath(cctx *cli.Context) (string, error) {
+	repoDir, err := homedir.Expand(cctx.String(cmd.FlagRepo.Name))
+	if err != nil {
+		return "", fmt.Errorf("getting homedir: %w", err)
+	}
+	return filepath.Join(repoDir, serveEndpointsFile), nil
+}
+
+// serveURL returns the URL of the running `boost serve` for the transport
+// (http or libp2p)
+func serveURL(cctx *cli.Context, transport string) (string, error) {
+	path, err := serveEndpointsPath(cctx)
+	if err != nil {
+		return "", err
+	}
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", errors.New("could not find the boost serve endpoints: is `boost serve` running?")
+		}
+		return "", fmt.Errorf("reading serve endpoints: %w", err)
+	}
+
+	var endpoints serveEndpoints
+	if err := json.Unmarshal(bz, &endpoints); err != nil {
+		return "", fmt.Errorf("parsing serve endpoints %s: %w", path, err)
+	}
+
+	switch transport {
+	case "http":
+		return endpoints.HttpURL, nil
+	case "libp2p":
+		if endpoints.Libp2pURL == "" {
+			return "", errors.New("boost serve is not serving over libp2p: restart it with --libp2p-public-addr")
+		}
+		return endpoints.Libp2pURL, nil
+	}
+	return "", fmt.Errorf("unrecognized transport '%s': must be http or libp2p", transport)
+}
@@ -51,6 +51,9 @@ func main() {
 			dagstoreCmd,
 			piecesCmd,
 			netCmd,
+			nitroCmd,
+			quotaCmd,
+			transferLimitCmd,
 		},
 	}
 	app.Setup()
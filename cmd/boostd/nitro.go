@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/filecoin-project/boost/cmd"
+	"github.com/statechannels/go-nitro/node/query"
+	"github.com/urfave/cli/v2"
+)
+
+// The default endpoint of the nitro node used by boostd to receive payments
+// (the same as the default Nitro.Endpoint in the boost config)
+const defaultNitroEndpoint = "127.0.0.1:4007/api/v1"
+
+var nitroCmd = &cli.Command{
+	Name:  "nitro",
+	Usage: "Manage the nitro payment channels used to pay for retrievals",
+	Flags: []cli.Flag{
+		cmd.FlagNitroEndpoint(defaultNitroEndpoint),
+	},
+	Subcommands: []*cli.Command{
+		nitroAddressCmd,
+		nitroListChannelsCmd,
+		nitroChannelCmd,
+		nitroCloseChannelCmd,
+		nitroDefundCmd,
+	},
+}
+
+var nitroAddressCmd = &cli.Command{
+	Name:  "address",
+	Usage: "Print the address of the nitro node that receives payments",
+	Action: func(cctx *cli.Context) error {
+		client, err := cmd.NitroClient(cctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close() //nolint:errcheck
+
+		addr, err := client.Address()
+		if err != nil {
+			return fmt.Errorf("getting nitro node address: %w", err)
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(map[string]interface{}{"address": addr})
+		}
+		fmt.Println(addr)
+		return nil
+	},
+}
+
+var nitroListChannelsCmd = &cli.Command{
+	Name:  "list-channels",
+	Usage: "List inbound payment channels, and the ledger channels that fund them",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "include payment channels that have been closed",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		client, err := cmd.NitroClient(cctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close() //nolint:errcheck
+
+		ledgers, paychs, err := cmd.ListNitroChannels(client, true, cctx.Bool("all"))
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(map[string]interface{}{
+				"ledgerChannels":  ledgers,
+				"paymentChannels": paychs,
+			})
+		}
+
+		fmt.Println("Ledger channels:")
+		cmd.PrintNitroLedgerChannels(ledgers)
+		fmt.Println()
+		fmt.Println("Inbound payment channels:")
+		cmd.PrintNitroPaymentChannels(paychs)
+		return nil
+	},
+}
+
+var nitroChannelCmd = &cli.Command{
+	Name:      "channel",
+	Usage:     "Show the status and balance of a payment channel",
+	ArgsUsage: "<channel id>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return fmt.Errorf("must specify a channel id")
+		}
+		chID, err := cmd.ParseNitroChannelID(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		client, err := cmd.NitroClient(cctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close() //nolint:errcheck
+
+		ch, err := client.GetPaymentChannel(chID)
+		if err != nil {
+			return fmt.Errorf("getting payment channel %s: %w", chID, err)
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(ch)
+		}
+		cmd.PrintNitroPaymentChannels([]query.PaymentChannelInfo{ch})
+		return nil
+	},
+}
+
+var nitroCloseChannelCmd = &cli.Command{
+	Name:      "close-channel",
+	Usage:     "Close a payment channel, moving the payments received into the ledger channel that funds it",
+	ArgsUsage: "<channel id>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "wait",
+			Usage: "wait for the channel to close",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return fmt.Errorf("must specify a channel id")
+		}
+		chID, err := cmd.ParseNitroChannelID(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		client, err := cmd.NitroClient(cctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close() //nolint:errcheck
+
+		objID, err := client.ClosePaymentChannel(chID)
+		if err != nil {
+			return fmt.Errorf("closing payment channel %s: %w", chID, err)
+		}
+		return cmd.WaitForNitroObjective(cctx, client, objID, fmt.Sprintf("payment channel %s", chID))
+	},
+}
+
+var nitroDefundCmd = &cli.Command{
+	Name:      "defund",
+	Usage:     "Close a ledger channel, withdrawing its funds on chain",
+	ArgsUsage: "<ledger channel id>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "wait",
+			Usage: "wait for the channel to be defunded",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return fmt.Errorf("must specify a ledger channel id")
+		}
+		chID, err := cmd.ParseNitroChannelID(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		client, err := cmd.NitroClient(cctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close() //nolint:errcheck
+
+		objID, err := client.CloseLedgerChannel(chID)
+		if err != nil {
+			return fmt.Errorf("defunding ledger channel %s: %w", chID, err)
+		}
+		return cmd.WaitForNitroObjective(cctx, client, objID, fmt.Sprintf("ledger channel %s", chID))
+	},
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/filecoin-project/boost/api"
+	bcli "github.com/filecoin-project/boost/cli"
+	"github.com/filecoin-project/boost/cmd"
+	"github.com/urfave/cli/v2"
+)
+
+var quotaCmd = &cli.Command{
+	Name:  "quota",
+	Usage: "Manage the quotas on storage deals from each client address or peer",
+	Description: `A quota limits the bytes of deal data in the staging area, the number
+of deals per hour and the number of concurrent transfers for a client address
+(or a client peer ID with --peer). A limit of zero means there is no limit.
+The quota with id * applies to each client that doesn't have its own quota.`,
+	Subcommands: []*cli.Command{
+		quotaListCmd,
+		quotaGetCmd,
+		quotaSetCmd,
+		quotaRemoveCmd,
+	},
+}
+
+var quotaPeerFlag = &cli.BoolFlag{
+	Name:  "peer",
+	Usage: "the id is a client peer ID instead of a client address",
+}
+
+func quotaKind(cctx *cli.Context) string {
+	if cctx.Bool("peer") {
+		return "peer"
+	}
+	return "client"
+}
+
+var quotaListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List client quotas and their usage",
+	Action: func(cctx *cli.Context) error {
+		ctx := bcli.ReqContext(cctx)
+
+		boostApi, ncloser, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return fmt.Errorf("getting boost api: %w", err)
+		}
+		defer ncloser()
+
+		quotas, err := boostApi.BoostQuotaList(ctx)
+		if err != nil {
+			return fmt.Errorf("listing quotas: %w", err)
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(quotas)
+		}
+
+		if len(quotas) == 0 {
+			fmt.Println("No quotas have been set")
+			return nil
+		}
+		return printQuotas(quotas)
+	},
+}
+
+var quotaGetCmd = &cli.Command{
+	Name:      "get",
+	Usage:     "Show the quota that applies to a client and the client's usage",
+	ArgsUsage: "<client address or peer ID>",
+	Flags:     []cli.Flag{quotaPeerFlag},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return fmt.Errorf("must specify a client address or peer ID")
+		}
+
+		ctx := bcli.ReqContext(cctx)
+
+		boostApi, ncloser, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return fmt.Errorf("getting boost api: %w", err)
+		}
+		defer ncloser()
+
+		quota, err := boostApi.BoostQuotaGet(ctx, quotaKind(cctx), cctx.Args().First())
+		if err != nil {
+			return fmt.Errorf("getting quota: %w", err)
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(quota)
+		}
+		return printQuotas([]api.ClientQuota{*quota})
+	},
+}
+
+var quotaSetCmd = &cli.Command{
+	Name:      "set",
+	Usage:     "Set the quota for a client (replacing any existing quota)",
+	ArgsUsage: "<client address, peer ID or *>",
+	Flags: []cli.Flag{
+		quotaPeerFlag,
+		&cli.StringFlag{
+			Name:  "max-bytes-in-flight",
+			Usage: "the maximum size of deal data in the staging area (eg 1TiB)",
+			Value: "0",
+		},
+		&cli.Uint64Flag{
+			Name:  "max-deals-per-hour",
+			Usage: "the maximum number of deals accepted per hour",
+		},
+		&cli.Uint64Flag{
+			Name:  "max-transfers",
+			Usage: "the maximum number of concurrent data transfers",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return fmt.Errorf("must specify a client address, peer ID or *")
+		}
+
+		maxBytes, err := humanize.ParseBytes(cctx.String("max-bytes-in-flight"))
+		if err != nil {
+			return fmt.Errorf("parsing max-bytes-in-flight: %w", err)
+		}
+
+		ctx := bcli.ReqContext(cctx)
+
+		boostApi, ncloser, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return fmt.Errorf("getting boost api: %w", err)
+		}
+		defer ncloser()
+
+		err = boostApi.BoostQuotaSet(ctx, api.ClientQuota{
+			Kind:                   quotaKind(cctx),
+			ID:                     cctx.Args().First(),
+			MaxBytesInFlight:       maxBytes,
+			MaxDealsPerHour:        cctx.Uint64("max-deals-per-hour"),
+			MaxConcurrentTransfers: cctx.Uint64("max-transfers"),
+		})
+		if err != nil {
+			return fmt.Errorf("setting quota: %w", err)
+		}
+
+		fmt.Printf("Set %s quota for %s\n", quotaKind(cctx), cctx.Args().First())
+		return nil
+	},
+}
+
+var quotaRemoveCmd = &cli.Command{
+	Name:      "remove",
+	Usage:     "Remove the quota for a client",
+	ArgsUsage: "<client address, peer ID or *>",
+	Flags:     []cli.Flag{quotaPeerFlag},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return fmt.Errorf("must specify a client address, peer ID or *")
+		}
+
+		ctx := bcli.ReqContext(cctx)
+
+		boostApi, ncloser, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return fmt.Errorf("getting boost api: %w", err)
+		}
+		defer ncloser()
+
+		err = boostApi.BoostQuotaRemove(ctx, quotaKind(cctx), cctx.Args().First())
+		if err != nil {
+			return fmt.Errorf("removing quota: %w", err)
+		}
+
+		fmt.Printf("Removed %s quota for %s\n", quotaKind(cctx), cctx.Args().First())
+		return nil
+	},
+}
+
+func printQuotas(quotas []api.ClientQuota) error {
+	w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Kind\tID\tBytes In Flight\tDeals Last Hour\tTransfers\n")
+
+	for _, q := range quotas {
+		// The default quota applies to each client separately so it
+		// doesn't have any usage
+		bytes, deals, transfers := "-", "-", "-"
+		if q.Usage != nil {
+			bytes = humanize.IBytes(q.Usage.BytesInFlight)
+			deals = fmt.Sprintf("%d", q.Usage.DealsLastHour)
+			transfers = fmt.Sprintf("%d", q.Usage.ConcurrentTransfers)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s / %s\t%s / %s\t%s / %s\n",
+			q.Kind,
+			q.ID,
+			bytes, formatBytesLimit(q.MaxBytesInFlight),
+			deals, formatLimit(q.MaxDealsPerHour),
+			transfers, formatLimit(q.MaxConcurrentTransfers),
+		)
+	}
+
+	return w.Flush()
+}
+
+func formatLimit(limit uint64) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d", limit)
+}
+
+func formatBytesLimit(limit uint64) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	return humanize.IBytes(limit)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/filecoin-project/boost/api"
+	bcli "github.com/filecoin-project/boost/cli"
+	"github.com/filecoin-project/boost/cmd"
+	"github.com/urfave/cli/v2"
+)
+
+var transferLimitCmd = &cli.Command{
+	Name:  "transfer-limit",
+	Usage: "Manage the limits on the bandwidth of storage deal data transfers",
+	Description: `A limit caps the bytes per second received by all storage deal data
+transfers together (kind global), by the transfers from each host (kind host)
+or by the transfers for deals from each client address (kind client).
+The host or client limit with id * applies to each host or client that
+doesn't have its own limit. A limit of zero means there is no limit.
+Limits take effect immediately, but they are reset to the values in the
+config file when boostd restarts.`,
+	Subcommands: []*cli.Command{
+		transferLimitListCmd,
+		transferLimitSetCmd,
+		transferLimitRemoveCmd,
+	},
+}
+
+var transferLimitListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List transfer bandwidth limits and the rates achieved by active transfers",
+	Action: func(cctx *cli.Context) error {
+		ctx := bcli.ReqContext(cctx)
+
+		boostApi, ncloser, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return fmt.Errorf("getting boost api: %w", err)
+		}
+		defer ncloser()
+
+		limits, err := boostApi.BoostTransferRateLimitList(ctx)
+		if err != nil {
+			return fmt.Errorf("listing transfer limits: %w", err)
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(limits)
+		}
+		return printTransferLimits(limits)
+	},
+}
+
+var transferLimitSetCmd = &cli.Command{
+	Name:      "set",
+	Usage:     "Set a transfer bandwidth limit in bytes per second (eg 10MiB)",
+	ArgsUsage: "global <bytes per second> | host|client <host, client address or *> <bytes per second>",
+	Action: func(cctx *cli.Context) error {
+		kind, id, err := transferLimitArgs(cctx, 1)
+		if err != nil {
+			return err
+		}
+
+		bps, err := humanize.ParseBytes(cctx.Args().Get(cctx.Args().Len() - 1))
+		if err != nil {
+			return fmt.Errorf("parsing bytes per second: %w", err)
+		}
+
+		ctx := bcli.ReqContext(cctx)
+
+		boostApi, ncloser, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return fmt.Errorf("getting boost api: %w", err)
+		}
+		defer ncloser()
+
+		err = boostApi.BoostTransferRateLimitSet(ctx, api.TransferRateLimit{
+			Kind:           kind,
+			ID:             id,
+			BytesPerSecond: bps,
+		})
+		if err != nil {
+			return fmt.Errorf("setting transfer limit: %w", err)
+		}
+
+		fmt.Printf("Set %s transfer limit%s to %s\n", kind, formatTransferLimitID(id), formatRateLimit(bps))
+		return nil
+	},
+}
+
+var transferLimitRemoveCmd = &cli.Command{
+	Name:      "remove",
+	Usage:     "Remove a transfer bandwidth limit",
+	ArgsUsage: "global | host|client <host, client address or *>",
+	Action: func(cctx *cli.Context) error {
+		kind, id, err := transferLimitArgs(cctx, 0)
+		if err != nil {
+			return err
+		}
+
+		ctx := bcli.ReqContext(cctx)
+
+		boostApi, ncloser, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return fmt.Errorf("getting boost api: %w", err)
+		}
+		defer ncloser()
+
+		err = boostApi.BoostTransferRateLimitRemove(ctx, kind, id)
+		if err != nil {
+			return fmt.Errorf("removing transfer limit: %w", err)
+		}
+
+		fmt.Printf("Removed %s transfer limit%s\n", kind, formatTransferLimitID(id))
+		return nil
+	},
+}
+
+// transferLimitArgs parses the kind of limit and the id (for host and client
+// limits), followed by the given number of extra arguments
+func transferLimitArgs(cctx *cli.Context, extra int) (string, string, error) {
+	kind := cctx.Args().First()
+	switch kind {
+	case "global":
+		if cctx.Args().Len() != 1+extra {
+			return "", "", fmt.Errorf("wrong number of arguments for global limit: expected %d", 1+extra)
+		}
+		return kind, "", nil
+	case "host", "client":
+		if cctx.Args().Len() != 2+extra {
+			return "", "", fmt.Errorf("wrong number of arguments for %s limit: expected %d", kind, 2+extra)
+		}
+		return kind, cctx.Args().Get(1), nil
+	default:
+		return "", "", fmt.Errorf("must specify the kind of limit: global, host or client")
+	}
+}
+
+func printTransferLimits(limits []api.TransferRateLimit) error {
+	w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Kind\tID\tLimit\tTransfers\tRate\n")
+
+	for _, l := range limits {
+		limit := formatRateLimit(l.BytesPerSecond)
+		if !l.Explicit {
+			limit += " (default)"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s/s\n",
+			l.Kind,
+			l.ID,
+			limit,
+			l.Transfers,
+			humanize.IBytes(l.Rate),
+		)
+	}
+
+	return w.Flush()
+}
+
+func formatTransferLimitID(id string) string {
+	if id == "" {
+		return ""
+	}
+	return " for " + id
+}
+
+func formatRateLimit(bytesPerSecond uint64) string {
+	if bytesPerSecond == 0 {
+		return "unlimited"
+	}
+	return humanize.IBytes(bytesPerSecond) + "/s"
+}
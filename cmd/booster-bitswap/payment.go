@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/filecoin-project/boost/cmd/lib/payment"
+	"github.com/filecoin-project/go-state-types/abi"
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/statechannels/go-nitro/payments"
+)
+
+// BlockPrice is the price of sending a block to a peer
+type BlockPrice struct {
+	// The price charged for each block
+	PerBlock *big.Int
+	// The price charged for each byte of the block
+	PerByte *big.Int
+}
+
+// price returns the price of a block of the given size
+func (bp BlockPrice) price(size int) *big.Int {
+	price := new(big.Int)
+	if bp.PerBlock != nil {
+		price.Add(price, bp.PerBlock)
+	}
+	if bp.PerByte != nil {
+		price.Add(price, new(big.Int).Mul(bp.PerByte, big.NewInt(int64(size))))
+	}
+	return price
+}
+
+// paymentLedger keeps track of each peer's credit. Peers are credited when
+// they send a payment voucher, and debited when they are sent a block.
+type paymentLedger struct {
+	verifier payment.Verifier
+
+	lk       sync.Mutex
+	balances map[peer.ID]*big.Int
+}
+
+func newPaymentLedger(verifier payment.Verifier) *paymentLedger {
+	return &paymentLedger{
+		verifier: verifier,
+		balances: make(map[peer.ID]*big.Int),
+	}
+}
+
+// ReceivePayment verifies the voucher (eg redeems it with the nitro node)
+// and credits the peer with the payment. It returns the peer's new balance.
+func (l *paymentLedger) ReceivePayment(p peer.ID, v payments.Voucher) (abi.TokenAmount, error) {
+	// A voucher pays for blocks that haven't been requested yet, so there
+	// is no price to verify it against
+	res, err := l.verifier.Verify(context.Background(), payment.NitroPayment(v), nil)
+	if err != nil {
+		return abi.TokenAmount{}, fmt.Errorf("processing voucher: %w", err)
+	}
+	if res.Amount.Sign() <= 0 {
+		return abi.TokenAmount{}, fmt.Errorf("voucher did not result in a payment")
+	}
+
+	balance := l.credit(p, res.Amount)
+	log.Debugw("received bitswap payment", "peer", p, "amount", res.Amount, "balance", balance)
+	return abi.TokenAmount{Int: balance}, nil
+}
+
+// credit adds amount to the peer's balance and returns the new balance
+func (l *paymentLedger) credit(p peer.ID, amount *big.Int) *big.Int {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	balance, ok := l.balances[p]
+	if !ok {
+		balance = new(big.Int)
+		l.balances[p] = balance
+	}
+	balance.Add(balance, amount)
+	return new(big.Int).Set(balance)
+}
+
+// covers returns true if the peer's balance is at least amount
+func (l *paymentLedger) covers(p peer.ID, amount *big.Int) bool {
+	if amount.Sign() == 0 {
+		return true
+	}
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	balance, ok := l.balances[p]
+	return ok && balance.Cmp(amount) >= 0
+}
+
+// charge subtracts amount from the peer's balance. The balance may become
+// negative if the peer asked for several blocks at once, in which case no
+// more blocks are sent to the peer until it pays off the difference.
+func (l *paymentLedger) charge(p peer.ID, amount *big.Int) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	balance, ok := l.balances[p]
+	if !ok {
+		balance = new(big.Int)
+		l.balances[p] = balance
+	}
+	balance.Sub(balance, amount)
+}
+
+// balance returns the peer's balance
+func (l *paymentLedger) balance(p peer.ID) *big.Int {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	balance, ok := l.balances[p]
+	if !ok {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(balance)
+}
+
+// blockCharger is a bitswap tracer that charges peers for the blocks in each
+// message that is sent to them. Messages that only have block presences (the
+// response to a want-have) are free.
+type blockCharger struct {
+	ledger *paymentLedger
+	price  BlockPrice
+}
+
+func (bc *blockCharger) MessageReceived(peer.ID, bsmsg.BitSwapMessage) {}
+
+func (bc *blockCharger) MessageSent(p peer.ID, msg bsmsg.BitSwapMessage) {
+	for _, blk := range msg.Blocks() {
+		price := bc.price.price(len(blk.RawData()))
+		bc.ledger.charge(p, price)
+		log.Debugw("charged peer for block", "peer", p, "cid", blk.Cid(), "price", price, "balance", bc.ledger.balance(p))
+	}
+}
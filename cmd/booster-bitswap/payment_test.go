@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/filecoin-project/boost/cmd/lib/payment"
+	"github.com/filecoin-project/boost/retrievalmarket/lp2pimpl"
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/statechannels/go-nitro/crypto"
+	"github.com/statechannels/go-nitro/payments"
+	"github.com/statechannels/go-nitro/types"
+	"github.com/stretchr/testify/require"
+)
+
+// testReceiver accepts vouchers like a nitro node: the payment is the
+// difference between the voucher amount and the amount of the previous
+// voucher on the channel
+type testReceiver struct {
+	lk     sync.Mutex
+	totals map[types.Destination]*big.Int
+}
+
+func (r *testReceiver) ReceiveVoucher(v payments.Voucher) (payments.ReceiveVoucherSummary, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	prev, ok := r.totals[v.ChannelId]
+	if !ok {
+		prev = big.NewInt(0)
+	}
+	if v.Amount.Cmp(prev) <= 0 {
+		return payments.ReceiveVoucherSummary{}, errors.New("voucher amount must be greater than the previous voucher amount")
+	}
+	r.totals[v.ChannelId] = v.Amount
+	return payments.ReceiveVoucherSummary{Total: v.Amount, Delta: new(big.Int).Sub(v.Amount, prev)}, nil
+}
+
+func testVoucher(amount int64) payments.Voucher {
+	var channelID types.Destination
+	channelID[0] = 1
+	sig := append(append(bytes.Repeat([]byte{2}, 32), bytes.Repeat([]byte{3}, 32)...), 27)
+	return payments.Voucher{
+		ChannelId: channelID,
+		Amount:    big.NewInt(amount),
+		Signature: crypto.SplitSignature(sig),
+	}
+}
+
+func TestBitswapPayment(t *testing.T) {
+	ctx := context.Background()
+
+	mn := mocknet.New()
+	serverHost, err := mn.GenPeer()
+	require.NoError(t, err)
+	clientHost, err := mn.GenPeer()
+	require.NoError(t, err)
+	require.NoError(t, mn.LinkAll())
+
+	// Create a bitswap server that charges 10 per block plus 1 per byte
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	blk := blocks.NewBlock(bytes.Repeat([]byte("a"), 100))
+	require.NoError(t, bs.Put(ctx, blk))
+	ledger := newPaymentLedger(payment.NewNitroVerifier(&testReceiver{totals: make(map[types.Destination]*big.Int)}))
+	s := &BitswapServer{
+		ctx:         ctx,
+		remoteStore: bs,
+		ledger:      ledger,
+		price:       BlockPrice{PerBlock: big.NewInt(10), PerByte: big.NewInt(1)},
+	}
+
+	charger := &blockCharger{ledger: ledger, price: s.price}
+
+	// sendBlock simulates bitswap sending the block to the peer
+	sendBlock := func(p peer.ID) {
+		msg := bsmsg.New(false)
+		msg.AddBlock(blk)
+		charger.MessageSent(p, msg)
+	}
+
+	listener := lp2pimpl.NewBitswapPaymentListener(serverHost, ledger)
+	listener.Start()
+	defer listener.Stop()
+
+	// The peer has no credit so the block is refused
+	clientPeer := clientHost.ID()
+	require.False(t, s.peerCanAfford(clientPeer, blk.Cid()))
+
+	// Pay for two blocks
+	client := lp2pimpl.NewBitswapPaymentClient(clientHost)
+	resp, err := client.SendPayment(ctx, serverHost.ID(), testVoucher(220))
+	require.NoError(t, err)
+	require.True(t, resp.Accepted)
+	require.EqualValues(t, 220, resp.Balance.Int64())
+
+	// The response to a want-have only has a block presence, so the peer is
+	// not charged for it
+	require.True(t, s.peerCanAfford(clientPeer, blk.Cid()))
+	have := bsmsg.New(false)
+	have.AddHave(blk.Cid())
+	charger.MessageSent(clientPeer, have)
+	require.EqualValues(t, 220, ledger.balance(clientPeer).Int64())
+
+	// Each block that is sent is debited from the peer's balance
+	require.True(t, s.peerCanAfford(clientPeer, blk.Cid()))
+	sendBlock(clientPeer)
+	require.True(t, s.peerCanAfford(clientPeer, blk.Cid()))
+	sendBlock(clientPeer)
+	require.EqualValues(t, 0, ledger.balance(clientPeer).Int64())
+
+	// Once the balance is exhausted, blocks are refused
+	require.False(t, s.peerCanAfford(clientPeer, blk.Cid()))
+
+	// Another peer's balance is not affected by the payment
+	require.False(t, s.peerCanAfford(peer.ID("other"), blk.Cid()))
+
+	// A voucher that doesn't increase the amount paid is rejected
+	resp, err = client.SendPayment(ctx, serverHost.ID(), testVoucher(220))
+	require.NoError(t, err)
+	require.False(t, resp.Accepted)
+	require.NotEmpty(t, resp.Message)
+
+	// A partial payment doesn't pay for a block
+	resp, err = client.SendPayment(ctx, serverHost.ID(), testVoucher(320))
+	require.NoError(t, err)
+	require.True(t, resp.Accepted)
+	require.EqualValues(t, 100, resp.Balance.Int64())
+	require.False(t, s.peerCanAfford(clientPeer, blk.Cid()))
+	require.EqualValues(t, 100, ledger.balance(clientPeer).Int64())
+
+	// If more blocks are sent than the balance covers (because the peer
+	// asked for several blocks at once), the peer owes the difference
+	resp, err = client.SendPayment(ctx, serverHost.ID(), testVoucher(330))
+	require.NoError(t, err)
+	require.True(t, resp.Accepted)
+	require.True(t, s.peerCanAfford(clientPeer, blk.Cid()))
+	sendBlock(clientPeer)
+	sendBlock(clientPeer)
+	require.EqualValues(t, -110, ledger.balance(clientPeer).Int64())
+	require.False(t, s.peerCanAfford(clientPeer, blk.Cid()))
+}
+
+func TestBitswapPaymentFree(t *testing.T) {
+	// If the server is not in paid mode, all blocks are free
+	s := &BitswapServer{}
+	require.True(t, s.peerCanAfford(peer.ID("peer"), blocks.NewBlock([]byte("a")).Cid()))
+}
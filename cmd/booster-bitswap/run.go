@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"math/big"
 	"net/http"
 	_ "net/http/pprof"
 	"strings"
@@ -11,13 +12,16 @@ import (
 	bclient "github.com/filecoin-project/boost/api/client"
 	cliutil "github.com/filecoin-project/boost/cli/util"
 	"github.com/filecoin-project/boost/cmd/lib/filters"
+	"github.com/filecoin-project/boost/cmd/lib/payment"
 	"github.com/filecoin-project/boost/cmd/lib/remoteblockstore"
 	"github.com/filecoin-project/boost/metrics"
+	"github.com/filecoin-project/boost/retrievalmarket/lp2pimpl"
 	"github.com/filecoin-project/boostd-data/shared/tracing"
 	"github.com/filecoin-project/go-jsonrpc"
 	lcli "github.com/filecoin-project/lotus/cli"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/mitchellh/go-homedir"
+	nrpc "github.com/statechannels/go-nitro/rpc"
 	"github.com/urfave/cli/v2"
 )
 
@@ -102,6 +106,26 @@ var runCmd = &cli.Command{
 			Usage: "Number of threads (goroutines) sending outgoing messages. Throttles the number of concurrent send operations",
 			Value: 128,
 		},
+		&cli.BoolFlag{
+			Name:  "nitro-enabled",
+			Usage: "require peers to pay for blocks with nitro vouchers, sent over the " + string(lp2pimpl.BitswapPaymentProtocolID) + " protocol",
+			Value: false,
+		},
+		&cli.StringFlag{
+			Name:  "nitro-endpoint",
+			Usage: "the endpoint for the nitro server",
+			Value: "127.0.0.1:4007/api/v1",
+		},
+		&cli.Uint64Flag{
+			Name:  "nitro-price-per-block",
+			Usage: "the price charged to a peer for each block",
+			Value: 0,
+		},
+		&cli.Uint64Flag{
+			Name:  "nitro-price-per-byte",
+			Usage: "the price charged to a peer for each byte of each block",
+			Value: 0,
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		if cctx.Bool("pprof") {
@@ -178,15 +202,40 @@ var runCmd = &cli.Command{
 			}
 		}
 
-		// Start the bitswap server
-		log.Infof("Starting booster-bitswap node on port %d", port)
-		err = server.Start(ctx, proxyAddrInfo, &BitswapServerOptions{
+		serverOpts := &BitswapServerOptions{
 			EngineBlockstoreWorkerCount: cctx.Int("engine-blockstore-worker-count"),
 			EngineTaskWorkerCount:       cctx.Int("engine-task-worker-count"),
 			MaxOutstandingBytesPerPeer:  cctx.Int("max-outstanding-bytes-per-peer"),
 			TargetMessageSize:           cctx.Int("target-message-size"),
 			TaskWorkerCount:             cctx.Int("task-worker-count"),
-		})
+		}
+
+		// If nitro payments are enabled, connect to the nitro node that
+		// redeems the vouchers sent by peers
+		if cctx.Bool("nitro-enabled") {
+			nitroEndpoint := cctx.String("nitro-endpoint")
+			nitroClient, err := nrpc.NewHttpRpcClient(nitroEndpoint)
+			if err != nil {
+				return fmt.Errorf("connecting to nitro rpc server at %s: %w", nitroEndpoint, err)
+			}
+			defer func() {
+				if err := nitroClient.Close(); err != nil {
+					log.Warnf("closing nitro rpc client: %s", err)
+				}
+			}()
+
+			serverOpts.Payments = &BitswapPaymentOptions{
+				Verifier: payment.NewNitroVerifier(nitroClient),
+				Price: BlockPrice{
+					PerBlock: new(big.Int).SetUint64(cctx.Uint64("nitro-price-per-block")),
+					PerByte:  new(big.Int).SetUint64(cctx.Uint64("nitro-price-per-byte")),
+				},
+			}
+		}
+
+		// Start the bitswap server
+		log.Infof("Starting booster-bitswap node on port %d", port)
+		err = server.Start(ctx, proxyAddrInfo, serverOpts)
 		if err != nil {
 			return err
 		}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/filecoin-project/boost/cmd/lib/payment"
 	"github.com/filecoin-project/boost/protocolproxy"
+	"github.com/filecoin-project/boost/retrievalmarket/lp2pimpl"
 	bsnetwork "github.com/ipfs/boxo/bitswap/network"
 	"github.com/ipfs/boxo/bitswap/server"
 	blockstore "github.com/ipfs/boxo/blockstore"
@@ -27,6 +29,10 @@ type BitswapServer struct {
 	proxy       *peer.AddrInfo
 	server      *server.Server
 	host        host.Host
+	// ledger and payments are set if peers must pay for blocks
+	ledger   *paymentLedger
+	payments *lp2pimpl.BitswapPaymentListener
+	price    BlockPrice
 }
 
 type BitswapServerOptions struct {
@@ -35,6 +41,16 @@ type BitswapServerOptions struct {
 	TaskWorkerCount             int
 	TargetMessageSize           int
 	MaxOutstandingBytesPerPeer  int
+	// If set, peers must pay for blocks with nitro vouchers
+	Payments *BitswapPaymentOptions
+}
+
+type BitswapPaymentOptions struct {
+	// Verifies the payment vouchers sent by peers (eg a nitro verifier that
+	// redeems vouchers with the nitro RPC client)
+	Verifier payment.Verifier
+	// The price that peers are charged for each block
+	Price BlockPrice
 }
 
 func NewBitswapServer(
@@ -70,6 +86,16 @@ func (s *BitswapServer) Start(ctx context.Context, proxy *peer.AddrInfo, opts *B
 	if err != nil {
 		return err
 	}
+	// In paid mode, peers send payment vouchers over a separate protocol
+	// to credit their balance
+	if opts.Payments != nil {
+		s.ledger = newPaymentLedger(opts.Payments.Verifier)
+		s.price = opts.Payments.Price
+		s.payments = lp2pimpl.NewBitswapPaymentListener(host, s.ledger)
+		s.payments.Start()
+		log.Infow("bitswap payments enabled", "price per block", s.price.PerBlock, "price per byte", s.price.PerByte)
+	}
+
 	bsopts := []server.Option{
 		server.EngineBlockstoreWorkerCount(opts.EngineBlockstoreWorkerCount),
 		server.EngineTaskWorkerCount(opts.EngineTaskWorkerCount),
@@ -84,9 +110,16 @@ func (s *BitswapServer) Start(ctx context.Context, proxy *peer.AddrInfo, opts *B
 				log.Errorf("error running bitswap filter: %s", err.Error())
 				return false
 			}
-			return fulfill
+			if !fulfill {
+				return false
+			}
+			return s.peerCanAfford(p, c)
 		}),
 	}
+	if s.ledger != nil {
+		// Peers are charged for blocks as they are sent
+		bsopts = append(bsopts, server.WithTracer(&blockCharger{ledger: s.ledger, price: s.price}))
+	}
 	net := bsnetwork.NewFromIpfsHost(host, nilRouter)
 	s.server = server.New(s.ctx, net, s.remoteStore, bsopts...)
 	net.Start(s.server)
@@ -100,7 +133,40 @@ func (s *BitswapServer) Start(ctx context.Context, proxy *peer.AddrInfo, opts *B
 	return nil
 }
 
+// peerCanAfford returns false if the peer's balance is too low to pay for
+// the block. The request filter is called for want-have as well as
+// want-block entries, so the peer is not charged here: it is charged by the
+// blockCharger when the block is sent.
+func (s *BitswapServer) peerCanAfford(p peer.ID, c cid.Cid) bool {
+	if s.ledger == nil {
+		return true
+	}
+
+	var size int
+	if s.price.PerByte != nil && s.price.PerByte.Sign() > 0 {
+		var err error
+		size, err = s.remoteStore.GetSize(s.ctx, c)
+		if err != nil {
+			// Don't refuse a block that can't be found: bitswap will
+			// respond that it doesn't have the block
+			log.Debugw("getting size of block to price for peer", "peer", p, "cid", c, "err", err)
+			return true
+		}
+	}
+
+	price := s.price.price(size)
+	if !s.ledger.covers(p, price) {
+		log.Infow("refusing block: peer has insufficient balance", "peer", p, "cid", c,
+			"price", price, "balance", s.ledger.balance(p))
+		return false
+	}
+	return true
+}
+
 func (s *BitswapServer) Stop() error {
+	if s.payments != nil {
+		s.payments.Stop()
+	}
 	if s.proxy != nil {
 		s.host.ConnManager().Unprotect(s.proxy.ID, protectTag)
 	}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/boost/car"
+	"github.com/filecoin-project/boost/cmd/lib/pricing"
+	"github.com/ipfs/go-cid"
+)
+
+const carMediaType = "application/vnd.ipld.car"
+
+// The number of CAR file sizes that are cached
+const carSizeCacheSize = 1024
+
+// carRange is a CAR file that is served from a byte offset with the
+// CarOffsetWriter, so that interrupted CAR downloads can be resumed with
+// a Range request
+type carRange struct {
+	root cid.Cid
+	size uint64
+}
+
+// isCarRangeRequest returns true if the request is for a byte range of a
+// CAR file
+func isCarRangeRequest(r *http.Request, responseFormat string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return responseFormat == carMediaType && r.Header.Get("Range") != ""
+}
+
+// carRange resolves the url path to the root of the DAG that will be
+// served, and gets the size of the CAR file for the DAG.
+// Returns nil if the DAG is too large to calculate the size of the CAR, in
+// which case the range can't be served and the whole CAR is sent instead.
+func (h *gatewayHandler) carRange(ctx context.Context, urlPath string) (*carRange, error) {
+	root, err := h.resolveRoot(ctx, urlPath)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := h.carSize(ctx, root)
+	if errors.Is(err, errDagTooLarge) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting size of CAR for %s: %w", root, err)
+	}
+	return &carRange{root: root, size: size}, nil
+}
+
+// carSize returns the size of the CAR file for the DAG with the given root.
+// Calculating the size walks the DAG, so sizes are cached by root. If
+// payments are enabled, the walk is limited to the same number of blocks as
+// the walk for a quote.
+func (h *gatewayHandler) carSize(ctx context.Context, root cid.Cid) (uint64, error) {
+	if size, ok := h.carSizes.Get(root); ok {
+		return size.(uint64), nil
+	}
+
+	var maxBlocks uint64
+	if h.payments != nil {
+		maxBlocks = h.payments.opts.QuoteMaxBlocks
+	}
+	size, err := responseSize(ctx, h.bstore, root, pricing.FormatCar, maxBlocks)
+	if err != nil {
+		return 0, err
+	}
+	h.carSizes.Add(root, size)
+	return size, nil
+}
+
+// carRangeQuote calculates the payment required to serve the byte range
+// of the CAR file in the Range header. It is called when a new quote is
+// needed, so the walk of the DAG to size the CAR is subject to the quote
+// rate limit.
+func (h *gatewayHandler) carRangeQuote(ctx context.Context, urlPath string, rangeHdr string) (quote, error) {
+	cr, err := h.carRange(ctx, urlPath)
+	if err != nil {
+		return quote{}, err
+	}
+	if cr == nil {
+		// The range can't be served, so quote for the whole CAR
+		return h.quote(ctx, urlPath, carMediaType)
+	}
+
+	_, length, err := parseByteRange(rangeHdr, cr.size)
+	if err != nil {
+		return quote{}, err
+	}
+
+	pieces, err := h.piecesContaining(ctx, cr.root)
+	if err != nil {
+		return quote{}, err
+	}
+	return h.priceQuote(ctx, cr.root, pieces, pricing.FormatCar, length)
+}
+
+// serveCarRange writes the byte range of the CAR file requested in the
+// Range header
+func (h *gatewayHandler) serveCarRange(w http.ResponseWriter, r *http.Request, cr *carRange) {
+	// Requests for the same CAR share the offsets of the blocks that have
+	// been written, so that concurrent range requests don't each need to
+	// read the blocks before the start of their range
+	bic := h.bicm.Get(cr.root)
+	defer h.bicm.Unref(cr.root, nil)
+
+	cow := car.NewCarOffsetWriter(cr.root, h.bstore, bic)
+	content := car.NewCarReaderSeeker(r.Context(), cow, cr.size)
+
+	// Set the Content-Type header explicitly so that http.ServeContent doesn't
+	// try to do it implicitly
+	w.Header().Set("Content-Type", carMediaType)
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	// The CAR is identified by its root, so that a client can use If-Range
+	// to check that the CAR hasn't changed when resuming a download
+	setEtag(w, cr.root.String()+".car")
+
+	http.ServeContent(w, r, "", time.Time{}, content)
+}
+
+// errInvalidRange is returned when the Range header can't be satisfied
+var errInvalidRange = errors.New("invalid range")
+
+// parseByteRange parses a Range header with a single byte range (eg
+// "bytes=100-199", "bytes=100-" or "bytes=-100") for content of the given
+// size, and returns the offset and length of the range
+func parseByteRange(hdr string, size uint64) (uint64, uint64, error) {
+	if !strings.HasPrefix(hdr, "bytes=") {
+		return 0, 0, fmt.Errorf("%w '%s': only byte ranges are supported", errInvalidRange, hdr)
+	}
+	spec := strings.TrimPrefix(hdr, "bytes=")
+	if strings.Contains(spec, ",") {
+		return 0, 0, fmt.Errorf("%w '%s': multiple ranges are not supported", errInvalidRange, hdr)
+	}
+
+	startStr, endStr, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, fmt.Errorf("%w '%s'", errInvalidRange, hdr)
+	}
+
+	// A suffix range eg "bytes=-100" is the last 100 bytes
+	if startStr == "" {
+		suffix, err := strconv.ParseUint(endStr, 10, 64)
+		if err != nil || suffix == 0 {
+			return 0, 0, fmt.Errorf("%w '%s'", errInvalidRange, hdr)
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, suffix, nil
+	}
+
+	start, err := strconv.ParseUint(startStr, 10, 64)
+	if err != nil || start >= size {
+		return 0, 0, fmt.Errorf("%w '%s' for content of size %d", errInvalidRange, hdr, size)
+	}
+
+	// An open ended range eg "bytes=100-" is from the offset to the end
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseUint(endStr, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, fmt.Errorf("%w '%s'", errInvalidRange, hdr)
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end - start + 1, nil
+}
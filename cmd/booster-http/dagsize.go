@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/filecoin-project/boost/cmd/lib/pricing"
+	"github.com/ipfs/boxo/blockservice"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/go-cid"
+	format "github.com/ipfs/go-ipld-format"
+	"github.com/ipld/go-car"
+	"github.com/ipld/go-car/util"
+)
+
+// errDagTooLarge is returned when the DAG has more blocks than the maximum
+// number of blocks that may be walked to calculate the response size
+var errDagTooLarge = errors.New("dag is too large to walk")
+
+// responseSize calculates the size of the response for the DAG with the
+// given root, in the given format.
+// For raw blocks it is the size of the block.
+// For CAR files it is the size of the CAR header plus each block and its
+// CID / length prefix.
+// For other formats it is the sum of the size of the blocks in the DAG,
+// which is an upper bound on the size of the file data.
+// If maxBlocks is non-zero and the DAG has more than maxBlocks blocks,
+// responseSize stops walking the DAG and returns errDagTooLarge.
+func responseSize(ctx context.Context, bs blockstore.Blockstore, root cid.Cid, respFormat string, maxBlocks uint64) (uint64, error) {
+	if respFormat == pricing.FormatRaw {
+		sz, err := bs.GetSize(ctx, root)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(sz), nil
+	}
+
+	var size uint64
+	if respFormat == pricing.FormatCar {
+		hdr := car.CarHeader{Roots: []cid.Cid{root}, Version: 1}
+		headerSize, err := car.HeaderSize(&hdr)
+		if err != nil {
+			return 0, err
+		}
+		size = headerSize
+	}
+
+	var blocks uint64
+	err := walkDag(ctx, bs, root, func(nd format.Node) error {
+		blocks++
+		if maxBlocks > 0 && blocks > maxBlocks {
+			return fmt.Errorf("%w: more than %d blocks", errDagTooLarge, maxBlocks)
+		}
+		if respFormat == pricing.FormatCar {
+			size += util.LdSize(nd.Cid().Bytes(), nd.RawData())
+		} else {
+			size += uint64(len(nd.RawData()))
+		}
+		return nil
+	})
+	return size, err
+}
+
+// walkDag does a depth first traversal of the DAG with the given root,
+// calling onNode for each block. If onNode returns an error the walk stops.
+func walkDag(ctx context.Context, bs blockstore.Blockstore, root cid.Cid, onNode func(format.Node) error) error {
+	ng := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+	nextCid := func(ctx context.Context, c cid.Cid) ([]*format.Link, error) {
+		nd, err := ng.Get(ctx, c)
+		if err != nil {
+			return nil, fmt.Errorf("getting block %s: %w", c, err)
+		}
+		if err := onNode(nd); err != nil {
+			return nil, err
+		}
+		return nd.Links(), nil
+	}
+
+	seen := cid.NewSet()
+	return merkledag.Walk(ctx, nextCid, root, seen.Visit)
+}
@@ -1,31 +1,37 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"math/big"
 	"mime"
 	"net/http"
-	"net/url"
 	"strings"
 
-	"github.com/ethereum/go-ethereum/common"
+	"github.com/filecoin-project/boost/car"
+	"github.com/filecoin-project/boost/cmd/lib/pricing"
+	lru "github.com/hnlq715/golang-lru"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	ifacepath "github.com/ipfs/boxo/coreiface/path"
 	"github.com/ipfs/boxo/gateway"
-	"github.com/statechannels/go-nitro/rpc"
-	"github.com/statechannels/go-nitro/types"
-
-	"github.com/ethereum/go-ethereum/common/hexutil"
-
-	"github.com/statechannels/go-nitro/crypto"
-	"github.com/statechannels/go-nitro/payments"
+	"github.com/ipfs/go-cid"
 )
 
 type gatewayHandler struct {
 	gwh              http.Handler
+	gw               *gateway.BlocksBackend
+	bstore           blockstore.Blockstore
+	api              HttpServerApi
 	supportedFormats map[string]struct{}
-	nitroRpcClient   *rpc.RpcClient
+	payments         *paymentManager
+	// Shares the block offsets of CAR files that are being served by byte
+	// range between requests for the same CAR
+	bicm car.BlockInfoCacheManager
+	// The sizes of CAR files that have been served by byte range, by root
+	carSizes *lru.Cache
 }
 
-func newGatewayHandler(gw *gateway.BlocksBackend, supportedFormats []string, nitroRpcClient *rpc.RpcClient) http.Handler {
+func newGatewayHandler(gw *gateway.BlocksBackend, bstore blockstore.Blockstore, api HttpServerApi, supportedFormats []string, payments *paymentManager) http.Handler {
 	headers := map[string][]string{}
 	gateway.AddAccessControlHeaders(headers)
 
@@ -34,15 +40,31 @@ func newGatewayHandler(gw *gateway.BlocksBackend, supportedFormats []string, nit
 		fmtsMap[f] = struct{}{}
 	}
 
+	// lru.New only fails if the size is not positive
+	carSizes, _ := lru.New(carSizeCacheSize)
+
 	// TODO: For the integration demo, we need to allow CORS requests to the gateway.
 	return &gatewayHandler{
 		gwh:              &corsHandler{gateway.NewHandler(gateway.Config{Headers: headers, DeserializedResponses: true}, gw)},
+		gw:               gw,
+		bstore:           bstore,
+		api:              api,
 		supportedFormats: fmtsMap,
-		nitroRpcClient:   nitroRpcClient,
+		payments:         payments,
+		bicm:             car.NewRefCountBICM(),
+		carSizes:         carSizes,
 	}
 }
 
 func (h *gatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Answer CORS preflight requests before checking for payment: a browser
+	// sends a preflight request without the payment headers before it sends
+	// a request with the payment headers
+	if r.Method == http.MethodOptions {
+		h.gwh.ServeHTTP(w, r)
+		return
+	}
+
 	responseFormat, _, err := customResponseFormat(r)
 	if err != nil {
 		webError(w, fmt.Errorf("error while processing the Accept header: %w", err), http.StatusBadRequest)
@@ -57,59 +79,183 @@ func (h *gatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if h.nitroRpcClient != nil {
-		// This the payment we expect to receive for the file.
-		const expectedPayment = int64(5)
+	isCarRange := isCarRangeRequest(r, responseFormat)
 
-		params, _ := url.ParseQuery(r.URL.RawQuery)
+	var session *paymentSession
+	var receipt *paymentReceipt
+	if h.payments != nil {
+		key := r.URL.Path + "|" + responseFormat
+		getQuote := func(ctx context.Context) (quote, error) {
+			return h.quote(ctx, r.URL.Path, responseFormat)
+		}
+		// A range request is priced by the size of the range. A range
+		// request that continues a pay-as-you-go session (eg resuming an
+		// interrupted download) is charged to the session's quote instead.
+		if isCarRange && r.Header.Get(PaymentSessionHeader) == "" {
+			rangeHdr := r.Header.Get("Range")
+			key += "|" + rangeHdr
+			getQuote = func(ctx context.Context) (quote, error) {
+				return h.carRangeQuote(ctx, r.URL.Path, rangeHdr)
+			}
+		}
 
-		v, err := parseVoucher(params)
-		if err != nil {
-			webError(w, fmt.Errorf("could not parse voucher: %w", err), http.StatusBadRequest)
+		var ok bool
+		session, receipt, ok = h.payments.checkPayment(w, r, key, getQuote)
+		if !ok {
 			return
 		}
+	}
 
-		s, err := h.nitroRpcClient.ReceiveVoucher(v)
-
+	// Serve CAR range requests with the CarOffsetWriter, which can start
+	// writing the CAR from any byte offset. If payments are enabled, the
+	// size of the CAR has already been calculated (and cached) for the
+	// quote.
+	var cr *carRange
+	if isCarRange {
+		cr, err = h.carRange(r.Context(), r.URL.Path)
 		if err != nil {
-			webError(w, fmt.Errorf("error processing voucher %w", err), http.StatusBadRequest)
+			if isNotFoundError(err) {
+				webError(w, err, http.StatusNotFound)
+				return
+			}
+			webError(w, err, http.StatusInternalServerError)
 			return
 		}
+	}
 
-		// s.Delta is amount our balance increases by adding this voucher
-		// AKA the payment amount we received in the request for this file
-		if s.Delta.Cmp(big.NewInt(expectedPayment)) < 0 {
-			webError(w, fmt.Errorf("payment of %d required, the voucher only resulted in a payment of %d", expectedPayment, s.Delta.Uint64()), http.StatusPaymentRequired)
-			return
+	// Record the payment along with the number of bytes that were sent
+	if receipt != nil {
+		cw := &countingWriter{ResponseWriter: w}
+		defer func() { h.payments.recordPayment(r.Context(), receipt, cw.written) }()
+		w = cw
+	}
+
+	// For pay-as-you-go downloads, only send as many bytes as have been
+	// paid for
+	if session != nil {
+		w = &paidWriter{
+			ResponseWriter: w,
+			ctx:            r.Context(),
+			session:        session,
+			timeout:        h.payments.opts.PaymentTimeout,
 		}
 	}
 
+	if cr != nil {
+		h.serveCarRange(w, r, cr)
+		return
+	}
+
 	h.gwh.ServeHTTP(w, r)
 }
 
-// parseVoucher takes in an a collection of query params and parses out a voucher.
-func parseVoucher(params url.Values) (payments.Voucher, error) {
-	if !params.Has("channelId") {
-		return payments.Voucher{}, fmt.Errorf("a valid channel id must be provided")
+// quote calculates the payment required to serve the content at the given
+// url path in the given format
+func (h *gatewayHandler) quote(ctx context.Context, urlPath string, responseFormat string) (quote, error) {
+	root, err := h.resolveRoot(ctx, urlPath)
+	if err != nil {
+		return quote{}, err
+	}
+
+	pieces, err := h.piecesContaining(ctx, root)
+	if err != nil {
+		return quote{}, err
+	}
+
+	pricingFormat := pricingFormat(responseFormat)
+	size, err := responseSize(ctx, h.bstore, root, pricingFormat, h.payments.opts.QuoteMaxBlocks)
+	if errors.Is(err, errDagTooLarge) {
+		// The DAG is too large to walk, so use the size of the piece that
+		// contains the DAG instead
+		size, err = h.piecesSize(pieces)
+	}
+	if err != nil {
+		return quote{}, fmt.Errorf("getting size of %s response for %s: %w", pricingFormat, root, err)
+	}
+
+	return h.priceQuote(ctx, root, pieces, pricingFormat, size)
+}
+
+// priceQuote calculates the price of sending size bytes of the DAG with the
+// given root in the given pricing format
+func (h *gatewayHandler) priceQuote(ctx context.Context, root cid.Cid, pieces []cid.Cid, pricingFormat string, size uint64) (quote, error) {
+	price, err := h.payments.opts.Pricer.Price(ctx, pricing.Input{
+		PayloadCid: root,
+		PieceCids:  pieces,
+		Format:     pricingFormat,
+		Size:       size,
+	})
+	if err != nil {
+		return quote{}, err
+	}
+	return quote{price: price, size: size, root: root, pieces: pieces}, nil
+}
+
+// piecesContaining returns the pieces that contain the given block
+func (h *gatewayHandler) piecesContaining(ctx context.Context, c cid.Cid) ([]cid.Cid, error) {
+	pieces, err := h.api.PiecesContainingMultihash(ctx, c.Hash())
+	if err != nil && !isNotFoundError(err) {
+		return nil, fmt.Errorf("getting pieces containing %s: %w", c, err)
+	}
+	return pieces, nil
+}
+
+// piecesSize returns the size of the smallest of the given pieces
+func (h *gatewayHandler) piecesSize(pieces []cid.Cid) (uint64, error) {
+	var size uint64
+	for _, pieceCid := range pieces {
+		pieceInfo, err := h.api.GetPieceInfo(pieceCid)
+		if err != nil {
+			return 0, fmt.Errorf("getting piece info for piece %s: %w", pieceCid, err)
+		}
+		for _, di := range pieceInfo.Deals {
+			dealSize := uint64(di.Length.Unpadded())
+			if size == 0 || dealSize < size {
+				size = dealSize
+			}
+		}
 	}
-	if !params.Has("amount") {
-		return payments.Voucher{}, fmt.Errorf("a valid amount must be provided")
+	if size == 0 {
+		return 0, fmt.Errorf("no pieces with deals found: %w", ErrNotFound)
 	}
-	if !params.Has("signature") {
-		return payments.Voucher{}, fmt.Errorf("a valid signature must be provided")
+	return size, nil
+}
+
+// resolveRoot resolves a url path of the form /ipfs/<cid>/some/sub/path
+// to the CID of the root of the DAG that will be served
+func (h *gatewayHandler) resolveRoot(ctx context.Context, urlPath string) (cid.Cid, error) {
+	// Remove any base path before the /ipfs/ prefix
+	idx := strings.Index(urlPath, "/ipfs/")
+	if idx < 0 {
+		return cid.Undef, fmt.Errorf("path '%s' is not an ipfs path", urlPath)
 	}
-	rawChId := params.Get("channelId")
-	rawAmt := params.Get("amount")
-	amount := big.NewInt(0)
-	amount.SetString(rawAmt, 10)
-	rawSignature := params.Get("signature")
 
-	v := payments.Voucher{
-		ChannelId: types.Destination(common.HexToHash(rawChId)),
-		Amount:    amount,
-		Signature: crypto.SplitSignature(hexutil.MustDecode(rawSignature)),
+	ip, err := gateway.NewImmutablePath(ifacepath.New(urlPath[idx:]))
+	if err != nil {
+		return cid.Undef, fmt.Errorf("parsing path '%s': %w", urlPath, err)
+	}
+
+	md, err := h.gw.ResolvePath(ctx, ip)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("resolving path '%s': %w", urlPath, err)
+	}
+	return md.LastSegment.Cid(), nil
+}
+
+// pricingFormat converts a response media type to the name of the format
+// used when calculating the price of the response
+func pricingFormat(responseFormat string) string {
+	switch responseFormat {
+	case "application/vnd.ipld.raw":
+		return pricing.FormatRaw
+	case "application/vnd.ipld.car":
+		return pricing.FormatCar
+	case "application/x-tar":
+		return pricing.FormatTar
+	case "":
+		return pricing.FormatUnixFS
 	}
-	return v, nil
+	return responseFormat
 }
 
 func webError(w http.ResponseWriter, err error, code int) {
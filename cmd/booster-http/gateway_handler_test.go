@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/filecoin-project/boost-gfm/piecestore"
+	boostcar "github.com/filecoin-project/boost/car"
+	mocks_booster_http "github.com/filecoin-project/boost/cmd/booster-http/mocks"
+	"github.com/filecoin-project/boost/cmd/lib/pricing"
+	"github.com/golang/mock/gomock"
+	"github.com/ipfs/boxo/blockservice"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	format "github.com/ipfs/go-ipld-format"
+	"github.com/ipld/go-car"
+	"github.com/ipld/go-car/util"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayHandlerQuote(t *testing.T) {
+	ctx := context.Background()
+	bs, nodes := createTestDag(t)
+	root, leafA := nodes[0], nodes[1]
+
+	// The root of the DAG is in one piece, and file "a" is also in another
+	// piece that has a price override
+	pieceRoot := testPieceCid(t, "piece-root")
+	pieceA := testPieceCid(t, "piece-a")
+	ctrl := gomock.NewController(t)
+	api := mocks_booster_http.NewMockHttpServerApi(ctrl)
+	api.EXPECT().PiecesContainingMultihash(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, mh multihash.Multihash) ([]cid.Cid, error) {
+			if bytes.Equal(mh, leafA.Cid().Hash()) {
+				return []cid.Cid{pieceA}, nil
+			}
+			return []cid.Cid{pieceRoot}, nil
+		})
+	api.EXPECT().GetPieceInfo(pieceRoot).AnyTimes().Return(&piecestore.PieceInfo{
+		PieceCID: pieceRoot,
+		Deals:    []piecestore.DealInfo{{DealID: 1, Length: 2048}},
+	}, nil)
+
+	pricer, err := pricing.NewRulePricer(pricing.Config{
+		Default: pricing.Rule{PricePerByte: big.NewInt(1)},
+		Overrides: []pricing.Override{{
+			Rule:     pricing.Rule{MinPrice: big.NewInt(1000)},
+			PieceCid: pieceA.String(),
+		}},
+	})
+	require.NoError(t, err)
+
+	gw, err := gateway.NewBlocksBackend(blockservice.New(bs, offline.Exchange(bs)))
+	require.NoError(t, err)
+	newHandler := func(maxBlocks uint64) *gatewayHandler {
+		return &gatewayHandler{
+			gw:       gw,
+			bstore:   bs,
+			api:      api,
+			payments: &paymentManager{opts: NitroOptions{Pricer: pricer, QuoteMaxBlocks: maxBlocks}},
+		}
+	}
+
+	rootPath := "/ipfs/" + root.Cid().String()
+
+	carSize := testDagCarHeaderSize(t, root.Cid())
+	carSize += testDagCarBlocksSize(nodes)
+	var blocksSize uint64
+	for _, nd := range nodes {
+		blocksSize += uint64(len(nd.RawData()))
+	}
+
+	t.Run("car", func(t *testing.T) {
+		q, err := newHandler(0).quote(ctx, rootPath, "application/vnd.ipld.car")
+		require.NoError(t, err)
+		require.Equal(t, carSize, q.size)
+		require.Equal(t, new(big.Int).SetUint64(carSize), q.price)
+	})
+
+	t.Run("unixfs", func(t *testing.T) {
+		q, err := newHandler(0).quote(ctx, rootPath, "")
+		require.NoError(t, err)
+		require.Equal(t, blocksSize, q.size)
+		require.Equal(t, new(big.Int).SetUint64(blocksSize), q.price)
+	})
+
+	t.Run("raw sub path", func(t *testing.T) {
+		q, err := newHandler(0).quote(ctx, rootPath+"/b", "application/vnd.ipld.raw")
+		require.NoError(t, err)
+		require.EqualValues(t, 100, q.size)
+		require.Equal(t, big.NewInt(100), q.price)
+	})
+
+	t.Run("piece override", func(t *testing.T) {
+		q, err := newHandler(0).quote(ctx, rootPath+"/a", "application/vnd.ipld.raw")
+		require.NoError(t, err)
+		require.EqualValues(t, 5, q.size)
+		require.Equal(t, big.NewInt(1000), q.price)
+	})
+
+	t.Run("dag too large to walk", func(t *testing.T) {
+		// The DAG has more blocks than the limit, so it is priced by the
+		// size of the piece that contains it
+		q, err := newHandler(1).quote(ctx, rootPath, "application/vnd.ipld.car")
+		require.NoError(t, err)
+		require.EqualValues(t, 2032, q.size)
+		require.Equal(t, big.NewInt(2032), q.price)
+	})
+
+	t.Run("not an ipfs path", func(t *testing.T) {
+		_, err := newHandler(0).quote(ctx, "/foo/"+root.Cid().String(), "")
+		require.Error(t, err)
+	})
+}
+
+func TestPricingFormat(t *testing.T) {
+	require.Equal(t, pricing.FormatRaw, pricingFormat("application/vnd.ipld.raw"))
+	require.Equal(t, pricing.FormatCar, pricingFormat("application/vnd.ipld.car"))
+	require.Equal(t, pricing.FormatTar, pricingFormat("application/x-tar"))
+	require.Equal(t, pricing.FormatUnixFS, pricingFormat(""))
+	require.Equal(t, "application/json", pricingFormat("application/json"))
+}
+
+func testPieceCid(t *testing.T, seed string) cid.Cid {
+	mh, err := multihash.Sum([]byte(seed), multihash.SHA2_256, -1)
+	require.NoError(t, err)
+	return cid.NewCidV1(cid.FilCommitmentUnsealed, mh)
+}
+
+// createTestDag creates a unixfs directory with two files, "a" and "b".
+// It returns the root node followed by the files.
+func createTestDag(t *testing.T) (blockstore.Blockstore, []format.Node) {
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	leafA := merkledag.NewRawNode([]byte("hello"))
+	leafB := merkledag.NewRawNode(bytes.Repeat([]byte("b"), 100))
+	root := merkledag.NodeWithData(unixfs.FolderPBData())
+	require.NoError(t, root.AddNodeLink("a", leafA))
+	require.NoError(t, root.AddNodeLink("b", leafB))
+	nodes := []format.Node{root, leafA, leafB}
+	for _, nd := range nodes {
+		require.NoError(t, bs.Put(context.Background(), nd))
+	}
+	return bs, nodes
+}
+
+func testDagCarHeaderSize(t *testing.T, root cid.Cid) uint64 {
+	hdr := car.CarHeader{Roots: []cid.Cid{root}, Version: 1}
+	size, err := car.HeaderSize(&hdr)
+	require.NoError(t, err)
+	return size
+}
+
+func testDagCarBlocksSize(nodes []format.Node) uint64 {
+	var size uint64
+	for _, nd := range nodes {
+		size += util.LdSize(nd.Cid().Bytes(), nd.RawData())
+	}
+	return size
+}
+
+func TestGatewayCarRange(t *testing.T) {
+	ctx := context.Background()
+	bs, nodes := createTestDag(t)
+	root := nodes[0].Cid()
+
+	gw, err := gateway.NewBlocksBackend(blockservice.New(bs, offline.Exchange(bs)))
+	require.NoError(t, err)
+	ctrl := gomock.NewController(t)
+	api := mocks_booster_http.NewMockHttpServerApi(ctrl)
+	fmts := []string{"", "application/vnd.ipld.car"}
+	srv := httptest.NewServer(newGatewayHandler(gw, bs, api, fmts, nil))
+	defer srv.Close()
+
+	var fullCar bytes.Buffer
+	cow := boostcar.NewCarOffsetWriter(root, bs, boostcar.NewBlockInfoCache())
+	require.NoError(t, cow.Write(ctx, &fullCar, 0))
+	carSize := fullCar.Len()
+
+	getRange := func(path string, rangeHdr string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+path+"?format=car", nil)
+		require.NoError(t, err)
+		req.Header.Set("Range", rangeHdr)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	testCases := []struct {
+		name     string
+		rangeHdr string
+		start    int
+		end      int
+	}{{
+		name:     "range in the car header",
+		rangeHdr: "bytes=2-20",
+		start:    2,
+		end:      21,
+	}, {
+		name:     "range across blocks",
+		rangeHdr: fmt.Sprintf("bytes=20-%d", carSize-10),
+		start:    20,
+		end:      carSize - 9,
+	}, {
+		name:     "open ended range",
+		rangeHdr: "bytes=50-",
+		start:    50,
+		end:      carSize,
+	}, {
+		name:     "suffix range",
+		rangeHdr: "bytes=-30",
+		start:    carSize - 30,
+		end:      carSize,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := getRange("/ipfs/"+root.String(), tc.rangeHdr)
+			require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+			require.Equal(t, fmt.Sprintf("bytes %d-%d/%d", tc.start, tc.end-1, carSize), resp.Header.Get("Content-Range"))
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, fullCar.Bytes()[tc.start:tc.end], body)
+		})
+	}
+
+	t.Run("sub path", func(t *testing.T) {
+		// A range request for a sub path is served from a CAR with the
+		// resolved node as the root
+		leafB := nodes[2]
+		var subCar bytes.Buffer
+		cow := boostcar.NewCarOffsetWriter(leafB.Cid(), bs, boostcar.NewBlockInfoCache())
+		require.NoError(t, cow.Write(ctx, &subCar, 0))
+
+		resp := getRange("/ipfs/"+root.String()+"/b", "bytes=10-")
+		require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, subCar.Bytes()[10:], body)
+	})
+
+	t.Run("unsatisfiable range", func(t *testing.T) {
+		resp := getRange("/ipfs/"+root.String(), fmt.Sprintf("bytes=%d-", carSize))
+		require.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+	})
+}
+
+// countingBlockstore counts the number of blocks that are read
+type countingBlockstore struct {
+	blockstore.Blockstore
+	gets atomic.Int32
+}
+
+func (bs *countingBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	bs.gets.Add(1)
+	return bs.Blockstore.Get(ctx, c)
+}
+
+func TestGatewayCarRangeSize(t *testing.T) {
+	ctx := context.Background()
+	bs, nodes := createTestDag(t)
+	root := nodes[0].Cid()
+	rootPath := "/ipfs/" + root.String()
+
+	gw, err := gateway.NewBlocksBackend(blockservice.New(bs, offline.Exchange(bs)))
+	require.NoError(t, err)
+	cbs := &countingBlockstore{Blockstore: bs}
+	h := newGatewayHandler(gw, cbs, nil, nil, nil).(*gatewayHandler)
+
+	// Getting the size of the CAR walks the DAG
+	cr, err := h.carRange(ctx, rootPath)
+	require.NoError(t, err)
+	require.Equal(t, testDagCarHeaderSize(t, root)+testDagCarBlocksSize(nodes), cr.size)
+	require.EqualValues(t, len(nodes), cbs.gets.Load())
+
+	// The size is cached by root, so the DAG isn't walked again
+	cr, err = h.carRange(ctx, rootPath)
+	require.NoError(t, err)
+	require.Equal(t, testDagCarHeaderSize(t, root)+testDagCarBlocksSize(nodes), cr.size)
+	require.EqualValues(t, len(nodes), cbs.gets.Load())
+
+	// When payments are enabled the walk is limited to the maximum number
+	// of blocks for a quote, so a range of a larger DAG can't be served
+	h = newGatewayHandler(gw, cbs, nil, nil, &paymentManager{opts: NitroOptions{QuoteMaxBlocks: 1}}).(*gatewayHandler)
+	cr, err = h.carRange(ctx, rootPath)
+	require.NoError(t, err)
+	require.Nil(t, cr)
+
+	leafB := nodes[2]
+	cr, err = h.carRange(ctx, rootPath+"/b")
+	require.NoError(t, err)
+	require.Equal(t, testDagCarHeaderSize(t, leafB.Cid())+testDagCarBlocksSize([]format.Node{leafB}), cr.size)
+}
+
+func TestParseByteRange(t *testing.T) {
+	testCases := []struct {
+		hdr    string
+		offset uint64
+		length uint64
+		expErr bool
+	}{
+		{hdr: "bytes=0-9", offset: 0, length: 10},
+		{hdr: "bytes=10-", offset: 10, length: 90},
+		{hdr: "bytes=-10", offset: 90, length: 10},
+		{hdr: "bytes=-200", offset: 0, length: 100},
+		{hdr: "bytes=90-200", offset: 90, length: 10},
+		{hdr: "bytes=100-", expErr: true},
+		{hdr: "bytes=10-5", expErr: true},
+		{hdr: "bytes=0-1,5-6", expErr: true},
+		{hdr: "bytes=-0", expErr: true},
+		{hdr: "items=0-1", expErr: true},
+		{hdr: "bytes=a-b", expErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.hdr, func(t *testing.T) {
+			offset, length, err := parseByteRange(tc.hdr, 100)
+			if tc.expErr {
+				require.ErrorIs(t, err, errInvalidRange)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.offset, offset)
+			require.Equal(t, tc.length, length)
+		})
+	}
+}
@@ -4,17 +4,28 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net/http"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/filecoin-project/boost-gfm/piecestore"
 	mocks_booster_http "github.com/filecoin-project/boost/cmd/booster-http/mocks"
+	"github.com/filecoin-project/boost/cmd/lib/payment"
+	"github.com/filecoin-project/boost/cmd/lib/pricing"
+	"github.com/filecoin-project/boost/pkg/fakenitro"
 	"github.com/golang/mock/gomock"
 	"github.com/ipfs/go-cid"
+	"github.com/statechannels/go-nitro/crypto"
+	"github.com/statechannels/go-nitro/payments"
+	nrpc "github.com/statechannels/go-nitro/rpc"
+	"github.com/statechannels/go-nitro/rpc/serde"
+	"github.com/statechannels/go-nitro/types"
 	"github.com/stretchr/testify/require"
 )
 
@@ -133,3 +144,113 @@ func waitServerUp(t *testing.T, port int) {
 		return err == nil
 	}, time.Second, 100*time.Millisecond)
 }
+
+func TestHttpNitroPayment(t *testing.T) {
+	// Run a fake nitro node for the provider, with a payment channel from
+	// the client
+	clientKey, clientAddr := crypto.GeneratePrivateKeyAndAddress()
+	_, providerAddr := crypto.GeneratePrivateKeyAndAddress()
+	channelID := types.Destination{1}
+	nitroSrv := fakenitro.NewServer(providerAddr)
+	nitroSrv.AddChannel(fakenitro.Channel{
+		ID:    channelID,
+		Payer: clientAddr,
+		Payee: providerAddr,
+		Funds: big.NewInt(100_000),
+	})
+	require.NoError(t, nitroSrv.Start("127.0.0.1:0"))
+	defer nitroSrv.Stop() //nolint:errcheck
+
+	nitroClient, err := nrpc.NewHttpRpcClient(nitroSrv.Url())
+	require.NoError(t, err)
+	defer nitroClient.Close() //nolint:errcheck
+
+	// Create a booster-http server that charges one token per byte
+	bs, nodes := createTestDag(t)
+	root := nodes[0].Cid()
+	ctrl := gomock.NewController(t)
+	mockHttpServer := mocks_booster_http.NewMockHttpServerApi(ctrl)
+	mockHttpServer.EXPECT().PiecesContainingMultihash(gomock.Any(), gomock.Any()).AnyTimes().Return(nil, nil)
+	pricer, err := pricing.NewRulePricer(pricing.Config{Default: pricing.Rule{PricePerByte: big.NewInt(1)}})
+	require.NoError(t, err)
+	opts := &HttpServerOptions{
+		Blockstore:               bs,
+		SupportedResponseFormats: []string{"", "application/vnd.ipld.car", "application/vnd.ipld.raw"},
+	}
+	nitroOpts := &NitroOptions{
+		Enabled:      true,
+		Verifier:     payment.NewNitroVerifier(nitroClient),
+		Pricer:       pricer,
+		PayeeAddress: providerAddr.String(),
+	}
+	httpServer := NewHttpServer("", "0.0.0.0", 7777, mockHttpServer, opts, nitroOpts)
+	require.NoError(t, httpServer.Start(context.Background()))
+	defer httpServer.Stop() //nolint:errcheck
+	waitServerUp(t, 7777)
+
+	carUrl := fmt.Sprintf("http://localhost:7777/ipfs/%s?format=car", root)
+	price := new(big.Int).SetUint64(testDagCarHeaderSize(t, root) + testDagCarBlocksSize(nodes))
+
+	// Creates a voucher for the total amount paid on the channel, signed
+	// with the given key
+	voucher := func(amount *big.Int, key []byte) string {
+		v := payments.Voucher{ChannelId: channelID, Amount: amount}
+		require.NoError(t, v.Sign(key))
+		sig := append(append(append([]byte{}, v.Signature.R...), v.Signature.S...), v.Signature.V)
+		return fmt.Sprintf("channelId=%s&amount=%s&signature=%s", channelID, amount, hexutil.Encode(sig))
+	}
+	get := func(paymentParams string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, carUrl, nil)
+		require.NoError(t, err)
+		if paymentParams != "" {
+			req.Header.Set(PaymentHeader, paymentParams)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	// A request without a voucher gets a 402 with the price and the
+	// address to pay
+	resp := get("")
+	pr := decodePaymentRequired(t, resp)
+	require.Equal(t, price.String(), pr.Price)
+	require.Equal(t, providerAddr.String(), pr.Payee)
+
+	// A voucher that is not signed by the channel's payer is rejected
+	otherKey, _ := crypto.GeneratePrivateKeyAndAddress()
+	resp = get(voucher(price, otherKey))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "wrong signer")
+	paid, err := nitroSrv.Paid(channelID)
+	require.NoError(t, err)
+	require.Zero(t, paid.Sign())
+
+	// If the nitro node fails to process the voucher, the request fails
+	nitroSrv.InjectError(serde.ReceiveVoucherRequestMethod, errors.New("nitro node unavailable"))
+	resp = get(voucher(price, clientKey))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "nitro node unavailable")
+	nitroSrv.InjectError(serde.ReceiveVoucherRequestMethod, nil)
+
+	// A voucher signed by the payer that pays the full price is accepted
+	resp = get(voucher(price, clientKey))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.EqualValues(t, price.Uint64(), len(body))
+	paid, err = nitroSrv.Paid(channelID)
+	require.NoError(t, err)
+	require.Equal(t, price, paid)
+
+	// Sending the same voucher again doesn't pay for the content a second
+	// time
+	resp = get(voucher(price, clientKey))
+	pr = decodePaymentRequired(t, resp)
+	require.Contains(t, pr.Error, "only resulted in a payment of 0")
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/filecoin-project/boost/api"
+	"github.com/filecoin-project/boost/cmd/lib/payment"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/statechannels/go-nitro/crypto"
+	"github.com/statechannels/go-nitro/payments"
+	"github.com/statechannels/go-nitro/types"
+)
+
+// The HTTP headers that a client can use to send a payment voucher.
+// The value of the header has the same format as the voucher query params,
+// eg X-Payment: channelId=0x123&amount=5&signature=0x456
+// Authorization: Nitro channelId=0x123&amount=5&signature=0x456
+const (
+	PaymentHeader       = "X-Payment"
+	authorizationScheme = "Nitro"
+)
+
+// The headers used for pay-as-you-go downloads.
+// The client opts in to pay-as-you-go mode by setting X-Payment-Mode to
+// "incremental". The response includes a session ID in the
+// X-Payment-Session header, that the client uses to send further vouchers
+// to /payment/<session id> while the download is in progress, or to
+// resume an interrupted download with a Range request.
+const (
+	PaymentModeHeader        = "X-Payment-Mode"
+	PaymentSessionHeader     = "X-Payment-Session"
+	PaymentTrancheSizeHeader = "X-Payment-Tranche-Size"
+	PaymentTranchePrice      = "X-Payment-Tranche-Price"
+	paymentModeIncremental   = "incremental"
+)
+
+// A signature is made up of R (32 bytes), S (32 bytes) and V (1 byte)
+const signatureLength = 65
+
+// The default amount of time for which a price quote is honoured
+const defaultQuoteTTL = 5 * time.Minute
+
+// PaymentRequired is the body of a 402 Payment Required response.
+// It has the information the client needs to create a voucher and
+// retry the request.
+type PaymentRequired struct {
+	// The reason that payment is required (eg the voucher was too small)
+	Error string `json:"Error,omitempty"`
+	// The payment required to serve the request
+	Price string `json:"Price"`
+	// The nitro address of the provider that should be paid
+	Payee string `json:"Payee"`
+	// The address of the asset that is accepted as payment
+	// (the zero address is the chain's native token)
+	Asset string `json:"Asset"`
+	// The price will be honoured for requests that arrive before this time
+	QuoteExpiry time.Time `json:"QuoteExpiry"`
+	// The number of bytes sent per payment in pay-as-you-go mode
+	// (zero if pay-as-you-go mode is disabled)
+	TrancheSize uint64 `json:"TrancheSize,omitempty"`
+	// The payment required for each tranche in pay-as-you-go mode
+	TranchePrice string `json:"TranchePrice,omitempty"`
+}
+
+// hasVoucher returns true if the request includes a voucher in the headers
+// or in the query params
+func hasVoucher(r *http.Request) bool {
+	if r.Header.Get(PaymentHeader) != "" {
+		return true
+	}
+	if _, ok := authorizationVoucher(r); ok {
+		return true
+	}
+	return r.URL.Query().Has("channelId")
+}
+
+// voucherFromRequest parses a voucher from the X-Payment header, the
+// Authorization header or the query params (in that order of precedence)
+func voucherFromRequest(r *http.Request) (payments.Voucher, error) {
+	if hdr := r.Header.Get(PaymentHeader); hdr != "" {
+		params, err := url.ParseQuery(hdr)
+		if err != nil {
+			return payments.Voucher{}, fmt.Errorf("parsing %s header: %w", PaymentHeader, err)
+		}
+		return parseVoucher(params)
+	}
+
+	if hdr, ok := authorizationVoucher(r); ok {
+		params, err := url.ParseQuery(hdr)
+		if err != nil {
+			return payments.Voucher{}, fmt.Errorf("parsing Authorization header: %w", err)
+		}
+		return parseVoucher(params)
+	}
+
+	params, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		return payments.Voucher{}, fmt.Errorf("parsing query params: %w", err)
+	}
+	return parseVoucher(params)
+}
+
+// authorizationVoucher returns the voucher part of an Authorization header
+// that uses the Nitro scheme
+func authorizationVoucher(r *http.Request) (string, bool) {
+	scheme, voucher, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, authorizationScheme) {
+		return "", false
+	}
+	return strings.TrimSpace(voucher), true
+}
+
+// parseVoucher takes in an a collection of query params and parses out a voucher.
+func parseVoucher(params url.Values) (payments.Voucher, error) {
+	if !params.Has("channelId") {
+		return payments.Voucher{}, fmt.Errorf("a valid channel id must be provided")
+	}
+	if !params.Has("amount") {
+		return payments.Voucher{}, fmt.Errorf("a valid amount must be provided")
+	}
+	if !params.Has("signature") {
+		return payments.Voucher{}, fmt.Errorf("a valid signature must be provided")
+	}
+	rawChId := params.Get("channelId")
+	rawAmt := params.Get("amount")
+	amount, ok := new(big.Int).SetString(rawAmt, 10)
+	if !ok || amount.Sign() < 0 {
+		return payments.Voucher{}, fmt.Errorf("a valid amount must be provided")
+	}
+	rawSignature, err := hexutil.Decode(params.Get("signature"))
+	if err != nil {
+		return payments.Voucher{}, fmt.Errorf("a valid signature must be provided: %w", err)
+	}
+	if len(rawSignature) != signatureLength {
+		return payments.Voucher{}, fmt.Errorf("a valid signature must be provided: expected %d bytes but got %d", signatureLength, len(rawSignature))
+	}
+
+	v := payments.Voucher{
+		ChannelId: types.Destination(common.HexToHash(rawChId)),
+		Amount:    amount,
+		Signature: crypto.SplitSignature(rawSignature),
+	}
+	return v, nil
+}
+
+// writePaymentRequired writes a 402 Payment Required response with a
+// machine-readable body that tells the client how much to pay
+func writePaymentRequired(w http.ResponseWriter, scheme string, pr PaymentRequired) {
+	// TODO: This is a hack to allow CORS requests to the gateway for the boost integration demo.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("WWW-Authenticate", scheme)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPaymentRequired)
+	json.NewEncoder(w).Encode(pr) //nolint:errcheck
+}
+
+type quote struct {
+	// The price of the content
+	price *big.Int
+	// The size of the content in bytes
+	size   uint64
+	expiry time.Time
+	// The root of the content (undefined for pieces)
+	root cid.Cid
+	// The pieces that contain the content
+	pieces []cid.Cid
+}
+
+// tranchePrice is the price of sending trancheSize bytes of the content,
+// rounded up
+func (q quote) tranchePrice(trancheSize uint64) *big.Int {
+	if q.size == 0 || trancheSize >= q.size {
+		return q.price
+	}
+	size := new(big.Int).SetUint64(q.size)
+	price := new(big.Int).Mul(q.price, new(big.Int).SetUint64(trancheSize))
+	price.Add(price, size)
+	price.Sub(price, big.NewInt(1))
+	return price.Div(price, size)
+}
+
+// quoteCache remembers the price quoted for a request, so that the price
+// is honoured when the client retries the request with a voucher, even
+// if the pricing changes in the meantime
+type quoteCache struct {
+	ttl time.Duration
+
+	lk     sync.Mutex
+	quotes map[string]quote
+}
+
+func newQuoteCache(ttl time.Duration) *quoteCache {
+	if ttl == 0 {
+		ttl = defaultQuoteTTL
+	}
+	return &quoteCache{ttl: ttl, quotes: make(map[string]quote)}
+}
+
+// getOrCreate returns the unexpired quote for the given key, or calculates
+// a new quote with getQuote if there is no unexpired quote
+func (c *quoteCache) getOrCreate(ctx context.Context, key string, getQuote func(context.Context) (quote, error)) (quote, error) {
+	now := time.Now()
+
+	c.lk.Lock()
+	q, ok := c.quotes[key]
+	c.lk.Unlock()
+	if ok && now.Before(q.expiry) {
+		return q, nil
+	}
+
+	q, err := getQuote(ctx)
+	if err != nil {
+		return quote{}, err
+	}
+	q.expiry = now.Add(c.ttl)
+
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	// Clean up any expired quotes
+	for k, existing := range c.quotes {
+		if !now.Before(existing.expiry) {
+			delete(c.quotes, k)
+		}
+	}
+	c.quotes[key] = q
+	return q, nil
+}
+
+// PaymentRecorder records the payments that were received for retrievals
+// (eg the boost API)
+type PaymentRecorder interface {
+	BoostRetrievalPaymentRecord(ctx context.Context, payment api.RetrievalPayment) error
+}
+
+// paymentReceipt is a payment that was accepted for a request
+type paymentReceipt struct {
+	payment  payment.Payment
+	received payment.Received
+	quote    quote
+}
+
+// paymentManager checks that requests are paid for
+type paymentManager struct {
+	parser       PaymentParser
+	verifier     payment.Verifier
+	opts         NitroOptions
+	quotes       *quoteCache
+	quoteLimiter *quoteLimiter
+	sessions     *sessionStore
+}
+
+func newPaymentManager(opts NitroOptions) *paymentManager {
+	return &paymentManager{
+		parser:       opts.Parser,
+		verifier:     opts.Verifier,
+		opts:         opts,
+		quotes:       newQuoteCache(opts.QuoteTTL),
+		quoteLimiter: newQuoteLimiter(opts.QuoteRateLimit, opts.QuoteRateBurst),
+		sessions:     newSessionStore(opts.QuoteTTL),
+	}
+}
+
+// checkPayment checks that the request includes a payment for the content
+// identified by key. If not it writes an error response and
+// returns false.
+// If the request is for a pay-as-you-go download, checkPayment returns the
+// payment session that the download should be charged to.
+// If a payment was accepted, checkPayment returns a receipt that should be
+// recorded once the response has been sent.
+func (pm *paymentManager) checkPayment(w http.ResponseWriter, r *http.Request, key string, getQuote func(context.Context) (quote, error)) (*paymentSession, *paymentReceipt, bool) {
+	// Get the payment we expect to receive for the content
+	q, err := pm.quotes.getOrCreate(r.Context(), key, func(ctx context.Context) (quote, error) {
+		// Limit the rate at which each client can make the provider
+		// calculate new quotes
+		if !pm.quoteLimiter.allow(r) {
+			return quote{}, errTooManyQuotes
+		}
+		return getQuote(ctx)
+	})
+	if err != nil {
+		if errors.Is(err, errTooManyQuotes) {
+			webError(w, err, http.StatusTooManyRequests)
+			return nil, nil, false
+		}
+		if isNotFoundError(err) {
+			webError(w, err, http.StatusNotFound)
+			return nil, nil, false
+		}
+		if errors.Is(err, errInvalidRange) {
+			webError(w, err, http.StatusRequestedRangeNotSatisfiable)
+			return nil, nil, false
+		}
+		webError(w, fmt.Errorf("calculating price: %w", err), http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	// If the content is free there's no need to check for a voucher
+	if q.price.Sign() == 0 {
+		return nil, nil, true
+	}
+
+	paymentRequired := PaymentRequired{
+		Price:       q.price.String(),
+		Payee:       pm.opts.PayeeAddress,
+		Asset:       pm.opts.AssetAddress,
+		QuoteExpiry: q.expiry,
+	}
+	if pm.opts.TrancheSize > 0 {
+		paymentRequired.TrancheSize = pm.opts.TrancheSize
+		paymentRequired.TranchePrice = q.tranchePrice(pm.opts.TrancheSize).String()
+	}
+
+	// Check if the request is continuing an existing pay-as-you-go session
+	// (eg resuming an interrupted download)
+	if sessionID := r.Header.Get(PaymentSessionHeader); sessionID != "" {
+		session, err := pm.sessions.get(sessionID)
+		if err != nil {
+			webError(w, err, http.StatusBadRequest)
+			return nil, nil, false
+		}
+		if session.key != key {
+			webError(w, fmt.Errorf("payment session %s is for different content", sessionID), http.StatusBadRequest)
+			return nil, nil, false
+		}
+		var receipt *paymentReceipt
+		if pm.parser.HasPayment(r) {
+			receipt, err = pm.receiveSessionPayment(r, session)
+			if err != nil {
+				webError(w, err, paymentErrorStatus(err))
+				return nil, nil, false
+			}
+		}
+		pm.setSessionHeaders(w, session)
+		return session, receipt, true
+	}
+
+	// If the client didn't send a payment, tell the client how much to pay
+	if !pm.parser.HasPayment(r) {
+		paymentRequired.Error = "a payment is required"
+		writePaymentRequired(w, pm.parser.Scheme(), paymentRequired)
+		return nil, nil, false
+	}
+
+	p, err := pm.parser.ParsePayment(r)
+	if err != nil {
+		webError(w, err, http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	received, err := pm.verifier.Verify(r.Context(), p, q.price)
+	if err != nil {
+		webError(w, err, paymentErrorStatus(err))
+		return nil, nil, false
+	}
+	receipt := &paymentReceipt{payment: p, received: received, quote: q}
+
+	// In pay-as-you-go mode the first voucher must pay for the first tranche
+	incremental := pm.opts.TrancheSize > 0 && strings.EqualFold(r.Header.Get(PaymentModeHeader), paymentModeIncremental)
+	if incremental {
+		tranchePrice := q.tranchePrice(pm.opts.TrancheSize)
+		if received.Amount.Cmp(tranchePrice) < 0 {
+			paymentRequired.Error = fmt.Sprintf("payment of %s required for the first tranche, the voucher only resulted in a payment of %s", tranchePrice, received.Amount)
+			writePaymentRequired(w, pm.parser.Scheme(), paymentRequired)
+			return nil, nil, false
+		}
+
+		session := pm.sessions.create(key, p.Source, q)
+		session.addPayment(received.Amount)
+		pm.setSessionHeaders(w, session)
+		return session, receipt, true
+	}
+
+	// received.Amount is amount our balance increases by adding this
+	// payment AKA the payment amount we received in the request for this file
+	if received.Amount.Cmp(q.price) < 0 {
+		paymentRequired.Error = fmt.Sprintf("payment of %s required, the voucher only resulted in a payment of %s", q.price, received.Amount)
+		writePaymentRequired(w, pm.parser.Scheme(), paymentRequired)
+		return nil, nil, false
+	}
+
+	return nil, receipt, true
+}
+
+func (pm *paymentManager) setSessionHeaders(w http.ResponseWriter, session *paymentSession) {
+	w.Header().Set(PaymentSessionHeader, session.id)
+	w.Header().Set(PaymentTrancheSizeHeader, fmt.Sprintf("%d", pm.opts.TrancheSize))
+	w.Header().Set(PaymentTranchePrice, session.quote.tranchePrice(pm.opts.TrancheSize).String())
+	w.Header().Add("Access-Control-Expose-Headers", PaymentSessionHeader+", "+PaymentTrancheSizeHeader+", "+PaymentTranchePrice)
+}
+
+// receiveSessionPayment credits a pay-as-you-go session with the payment
+// in the request
+func (pm *paymentManager) receiveSessionPayment(r *http.Request, session *paymentSession) (*paymentReceipt, error) {
+	p, err := pm.parser.ParsePayment(r)
+	if err != nil {
+		return nil, err
+	}
+	if p.Source != session.source {
+		return nil, fmt.Errorf("payment is from %s but payment session %s is for payments from %s", p.Source, session.id, session.source)
+	}
+
+	received, err := pm.verifier.Verify(r.Context(), p, session.quote.tranchePrice(pm.opts.TrancheSize))
+	if err != nil {
+		return nil, err
+	}
+
+	session.addPayment(received.Amount)
+	return &paymentReceipt{payment: p, received: received, quote: session.quote}, nil
+}
+
+// recordPayment records a payment that was received for a request, along
+// with the number of bytes sent in the response to the request
+func (pm *paymentManager) recordPayment(ctx context.Context, receipt *paymentReceipt, bytesSent uint64) {
+	if receipt == nil || pm.opts.Recorder == nil {
+		return
+	}
+
+	var pieceCid *cid.Cid
+	if len(receipt.quote.pieces) > 0 {
+		pieceCid = &receipt.quote.pieces[0]
+	}
+	rp := api.RetrievalPayment{
+		ChannelID:  receipt.payment.Source,
+		Payer:      receipt.received.Payer,
+		Transport:  "http",
+		Amount:     abi.TokenAmount{Int: receipt.received.Amount},
+		Total:      abi.TokenAmount{Int: receipt.received.Total},
+		PayloadCID: receipt.quote.root,
+		PieceCID:   pieceCid,
+		BytesSent:  bytesSent,
+	}
+	if err := pm.opts.Recorder.BoostRetrievalPaymentRecord(ctx, rp); err != nil {
+		log.Warnw("recording retrieval payment", "channel", rp.ChannelID, "payer", rp.Payer, "err", err)
+	}
+}
+
+type sessionStatus struct {
+	Session   string `json:"Session"`
+	Received  string `json:"Received"`
+	Paid      string `json:"Paid"`
+	PaidBytes uint64 `json:"PaidBytes"`
+	SentBytes uint64 `json:"SentBytes"`
+}
+
+// handlePayment accepts a voucher for a pay-as-you-go session, so that a
+// paused download can continue
+func (pm *paymentManager) handlePayment(w http.ResponseWriter, r *http.Request, sessionID string) {
+	session, err := pm.sessions.get(sessionID)
+	if err != nil {
+		webError(w, err, http.StatusNotFound)
+		return
+	}
+
+	receipt, err := pm.receiveSessionPayment(r, session)
+	if err != nil {
+		webError(w, err, paymentErrorStatus(err))
+		return
+	}
+
+	// The voucher pays for bytes that are sent in the response to the
+	// download request, not in the response to this request
+	pm.recordPayment(r.Context(), receipt, 0)
+
+	paid, paidBytes, sentBytes := session.status()
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(sessionStatus{ //nolint:errcheck
+		Session:   session.id,
+		Received:  receipt.received.Amount.String(),
+		Paid:      paid.String(),
+		PaidBytes: paidBytes,
+		SentBytes: sentBytes,
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/filecoin-project/boost/cmd/lib/payment"
+)
+
+// PaymentParser parses the payments that clients send with HTTP requests.
+// The payments are verified by a payment.Verifier.
+type PaymentParser interface {
+	// Scheme is the HTTP authentication scheme that clients use to send
+	// payments (eg "Nitro"). It is sent to clients in the WWW-Authenticate
+	// header of a 402 Payment Required response.
+	Scheme() string
+	// HasPayment returns true if the request includes a payment
+	HasPayment(r *http.Request) bool
+	// ParsePayment parses the payment included with the request
+	ParsePayment(r *http.Request) (payment.Payment, error)
+}
+
+// paymentErrorStatus returns the HTTP status code for an error returned by
+// a payment.Verifier
+func paymentErrorStatus(err error) int {
+	if errors.Is(err, payment.ErrBackend) {
+		return http.StatusBadGateway
+	}
+	return http.StatusBadRequest
+}
+
+// nitroParser parses nitro payment vouchers from the X-Payment header, the
+// Authorization header or the query params
+type nitroParser struct{}
+
+var _ PaymentParser = (*nitroParser)(nil)
+
+// NewNitroParser creates a PaymentParser for nitro vouchers
+func NewNitroParser() PaymentParser {
+	return &nitroParser{}
+}
+
+func (p *nitroParser) Scheme() string {
+	return authorizationScheme
+}
+
+func (p *nitroParser) HasPayment(r *http.Request) bool {
+	return hasVoucher(r)
+}
+
+func (p *nitroParser) ParsePayment(r *http.Request) (payment.Payment, error) {
+	voucher, err := voucherFromRequest(r)
+	if err != nil {
+		return payment.Payment{}, fmt.Errorf("could not parse voucher: %w", err)
+	}
+	return payment.NitroPayment(voucher), nil
+}
+
+// bearerTokenParser parses an "Authorization: Bearer <token>" header, for
+// use with the allowlist payment verifier
+type bearerTokenParser struct{}
+
+var _ PaymentParser = (*bearerTokenParser)(nil)
+
+// NewBearerTokenParser creates a PaymentParser for bearer tokens
+func NewBearerTokenParser() PaymentParser {
+	return &bearerTokenParser{}
+}
+
+func (p *bearerTokenParser) Scheme() string {
+	return "Bearer"
+}
+
+func (p *bearerTokenParser) HasPayment(r *http.Request) bool {
+	_, ok := p.token(r)
+	return ok
+}
+
+func (p *bearerTokenParser) ParsePayment(r *http.Request) (payment.Payment, error) {
+	token, ok := p.token(r)
+	if !ok || token == "" {
+		return payment.Payment{}, errors.New("a bearer token must be provided")
+	}
+	return payment.TokenPayment(token), nil
+}
+
+func (p *bearerTokenParser) token(r *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, p.Scheme()) {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var errPaymentTimeout = errors.New("timed out waiting for payment")
+
+// paymentSession keeps track of the payments for a pay-as-you-go download.
+// Each voucher received on the session's channel credits the session with
+// the number of bytes that the payment covers. When the credit runs out
+// the download pauses until the next voucher arrives.
+type paymentSession struct {
+	id  string
+	key string
+	// The source of the payments (eg the nitro payment channel)
+	source string
+	quote  quote
+
+	lk         sync.Mutex
+	paid       *big.Int
+	sent       uint64
+	lastActive time.Time
+	// credited is closed (and replaced) each time a payment is received
+	credited chan struct{}
+}
+
+// paidBytes is the number of bytes of the content that have been paid for.
+// Once the full price has been paid there is no limit: the quoted size is
+// an estimate, and some responses (eg tar) are larger than the estimate.
+func (s *paymentSession) paidBytes() uint64 {
+	if s.paid.Cmp(s.quote.price) >= 0 {
+		return math.MaxUint64
+	}
+	paidBytes := new(big.Int).Mul(s.paid, new(big.Int).SetUint64(s.quote.size))
+	return paidBytes.Div(paidBytes, s.quote.price).Uint64()
+}
+
+func (s *paymentSession) addPayment(amount *big.Int) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	s.paid = new(big.Int).Add(s.paid, amount)
+	s.lastActive = time.Now()
+	close(s.credited)
+	s.credited = make(chan struct{})
+}
+
+func (s *paymentSession) status() (*big.Int, uint64, uint64) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	paidBytes := s.paidBytes()
+	if paidBytes == math.MaxUint64 {
+		// Report the quoted size for a session that has been paid in full
+		paidBytes = s.quote.size
+		if s.sent > paidBytes {
+			paidBytes = s.sent
+		}
+	}
+	return new(big.Int).Set(s.paid), paidBytes, s.sent
+}
+
+// reserve waits until there is credit to send bytes, and returns the number
+// of bytes (up to max) that can be sent.
+// If there is no credit, onPause is called before waiting for payment.
+func (s *paymentSession) reserve(ctx context.Context, max uint64, timeout time.Duration, onPause func()) (uint64, error) {
+	paused := false
+	for {
+		s.lk.Lock()
+		paidBytes := s.paidBytes()
+		if paidBytes > s.sent {
+			count := paidBytes - s.sent
+			if count > max {
+				count = max
+			}
+			s.sent += count
+			s.lastActive = time.Now()
+			s.lk.Unlock()
+			return count, nil
+		}
+		credited := s.credited
+		s.lk.Unlock()
+
+		if !paused {
+			paused = true
+			if onPause != nil {
+				onPause()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(timeout):
+			return 0, fmt.Errorf("%w: session %s has sent %d bytes, which is all the bytes that have been paid for",
+				errPaymentTimeout, s.id, paidBytes)
+		case <-credited:
+		}
+	}
+}
+
+// release returns reserved bytes that could not be sent to the session's
+// credit
+func (s *paymentSession) release(count uint64) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	s.sent -= count
+}
+
+// sessionStore keeps track of payment sessions that are in progress
+type sessionStore struct {
+	ttl time.Duration
+
+	lk       sync.Mutex
+	sessions map[string]*paymentSession
+}
+
+func newSessionStore(ttl time.Duration) *sessionStore {
+	if ttl == 0 {
+		ttl = defaultQuoteTTL
+	}
+	return &sessionStore{ttl: ttl, sessions: make(map[string]*paymentSession)}
+}
+
+func (ss *sessionStore) create(key string, source string, q quote) *paymentSession {
+	now := time.Now()
+	session := &paymentSession{
+		id:         uuid.New().String(),
+		key:        key,
+		source:     source,
+		quote:      q,
+		paid:       big.NewInt(0),
+		lastActive: now,
+		credited:   make(chan struct{}),
+	}
+
+	ss.lk.Lock()
+	defer ss.lk.Unlock()
+
+	// Clean up any sessions that have been inactive for longer than the ttl
+	for id, existing := range ss.sessions {
+		existing.lk.Lock()
+		expired := now.Sub(existing.lastActive) > ss.ttl
+		existing.lk.Unlock()
+		if expired {
+			delete(ss.sessions, id)
+		}
+	}
+
+	ss.sessions[session.id] = session
+	return session
+}
+
+func (ss *sessionStore) get(id string) (*paymentSession, error) {
+	ss.lk.Lock()
+	defer ss.lk.Unlock()
+
+	session, ok := ss.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("payment session %s not found", id)
+	}
+	return session, nil
+}
+
+// paidWriter is an http.ResponseWriter that only writes as many bytes as
+// have been paid for. When the credit runs out it flushes the data written
+// so far and pauses until the next payment arrives.
+type paidWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	session *paymentSession
+	timeout time.Duration
+}
+
+func (w *paidWriter) Write(bz []byte) (int, error) {
+	written := 0
+	for len(bz) > 0 {
+		count, err := w.session.reserve(w.ctx, uint64(len(bz)), w.timeout, w.Flush)
+		if err != nil {
+			return written, err
+		}
+
+		n, err := w.ResponseWriter.Write(bz[:count])
+		written += n
+		if err != nil {
+			w.session.release(count - uint64(n))
+			return written, err
+		}
+		bz = bz[count:]
+	}
+	return written, nil
+}
+
+// Flush implements http.Flusher by flushing the wrapped writer
+func (w *paidWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, for use by http.ResponseController
+func (w *paidWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// paidReader is an io.ReadSeeker that only reads as many bytes as have
+// been paid for. When the credit runs out it calls onPause and waits until
+// the next payment arrives.
+type paidReader struct {
+	io.ReadSeeker
+	ctx     context.Context
+	session *paymentSession
+	timeout time.Duration
+	onPause func()
+}
+
+func (r *paidReader) Read(bz []byte) (int, error) {
+	if len(bz) == 0 {
+		return 0, nil
+	}
+
+	count, err := r.session.reserve(r.ctx, uint64(len(bz)), r.timeout, r.onPause)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := r.ReadSeeker.Read(bz[:count])
+	if uint64(n) < count {
+		r.session.release(count - uint64(n))
+	}
+	return n, err
+}
+
+// countingWriter is an http.ResponseWriter that counts the number of bytes
+// written to the response
+type countingWriter struct {
+	http.ResponseWriter
+	written uint64
+}
+
+func (w *countingWriter) Write(bz []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(bz)
+	w.written += uint64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher by flushing the wrapped writer
+func (w *countingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, for use by http.ResponseController
+func (w *countingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
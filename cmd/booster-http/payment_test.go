@@ -0,0 +1,758 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/filecoin-project/boost/api"
+	mocks_booster_http "github.com/filecoin-project/boost/cmd/booster-http/mocks"
+	"github.com/filecoin-project/boost/cmd/lib/payment"
+	"github.com/filecoin-project/boost/cmd/lib/pricing"
+	"github.com/golang/mock/gomock"
+	"github.com/ipfs/boxo/blockservice"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/statechannels/go-nitro/payments"
+	"github.com/statechannels/go-nitro/types"
+	"github.com/stretchr/testify/require"
+)
+
+const testChannelId = "0x1234567890123456789012345678901234567890123456789012345678901234"
+
+var testSignature = "0x" + strings.Repeat("ab", signatureLength)
+
+func TestVoucherFromRequest(t *testing.T) {
+	voucherParams := fmt.Sprintf("channelId=%s&amount=10&signature=%s", testChannelId, testSignature)
+
+	testCases := []struct {
+		name   string
+		setup  func(r *http.Request)
+		query  string
+		hasV   bool
+		expErr bool
+	}{{
+		name:  "no voucher",
+		setup: func(r *http.Request) {},
+		hasV:  false,
+		// parsing fails because there's no channel id
+		expErr: true,
+	}, {
+		name:  "query params",
+		setup: func(r *http.Request) {},
+		query: voucherParams,
+		hasV:  true,
+	}, {
+		name: "payment header",
+		setup: func(r *http.Request) {
+			r.Header.Set(PaymentHeader, voucherParams)
+		},
+		hasV: true,
+	}, {
+		name: "authorization header",
+		setup: func(r *http.Request) {
+			r.Header.Set("Authorization", "Nitro "+voucherParams)
+		},
+		hasV: true,
+	}, {
+		name: "authorization header with another scheme",
+		setup: func(r *http.Request) {
+			r.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+		},
+		hasV:   false,
+		expErr: true,
+	}, {
+		name: "invalid amount",
+		setup: func(r *http.Request) {
+			r.Header.Set(PaymentHeader, fmt.Sprintf("channelId=%s&amount=abc&signature=%s", testChannelId, testSignature))
+		},
+		hasV:   true,
+		expErr: true,
+	}, {
+		name: "short signature",
+		setup: func(r *http.Request) {
+			r.Header.Set(PaymentHeader, fmt.Sprintf("channelId=%s&amount=10&signature=0xabcd", testChannelId))
+		},
+		hasV:   true,
+		expErr: true,
+	}, {
+		name: "signature is not hex",
+		setup: func(r *http.Request) {
+			r.Header.Set(PaymentHeader, fmt.Sprintf("channelId=%s&amount=10&signature=xyz", testChannelId))
+		},
+		hasV:   true,
+		expErr: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := http.NewRequest("GET", "http://localhost/ipfs/bafy?"+tc.query, nil)
+			require.NoError(t, err)
+			tc.setup(r)
+
+			require.Equal(t, tc.hasV, hasVoucher(r))
+			v, err := voucherFromRequest(r)
+			if tc.expErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, types.Destination(common.HexToHash(testChannelId)), v.ChannelId)
+			require.Equal(t, big.NewInt(10), v.Amount)
+		})
+	}
+}
+
+func TestQuoteCache(t *testing.T) {
+	ctx := context.Background()
+	qc := newQuoteCache(50 * time.Millisecond)
+
+	price := int64(10)
+	getPrice := func(context.Context) (quote, error) {
+		return quote{price: big.NewInt(price), size: 100}, nil
+	}
+
+	q, err := qc.getOrCreate(ctx, "a", getPrice)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(10), q.price)
+
+	// The quoted price should be honoured until it expires
+	price = 20
+	q, err = qc.getOrCreate(ctx, "a", getPrice)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(10), q.price)
+
+	// A different request should get the new price
+	q, err = qc.getOrCreate(ctx, "b", getPrice)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(20), q.price)
+
+	// After the quote expires the new price should apply
+	time.Sleep(60 * time.Millisecond)
+	q, err = qc.getOrCreate(ctx, "a", getPrice)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(20), q.price)
+}
+
+func TestTranchePrice(t *testing.T) {
+	q := quote{price: big.NewInt(100), size: 1000}
+	require.Equal(t, big.NewInt(10), q.tranchePrice(100))
+	// The tranche price is rounded up
+	require.Equal(t, big.NewInt(1), q.tranchePrice(1))
+	require.Equal(t, big.NewInt(11), q.tranchePrice(101))
+	// A tranche bigger than the content costs the same as the content
+	require.Equal(t, big.NewInt(100), q.tranchePrice(2000))
+}
+
+func TestPaidReader(t *testing.T) {
+	ctx := context.Background()
+	content := []byte(strings.Repeat("0123456789", 10))
+
+	// Create a session where 10 units pay for 10 bytes
+	ss := newSessionStore(time.Minute)
+	session := ss.create("key", testChannelId, quote{price: big.NewInt(100), size: uint64(len(content))})
+	session.addPayment(big.NewInt(10))
+
+	paused := make(chan struct{}, 10)
+	pr := &paidReader{
+		ReadSeeker: bytes.NewReader(content),
+		ctx:        ctx,
+		session:    session,
+		timeout:    time.Second,
+		onPause:    func() { paused <- struct{}{} },
+	}
+
+	// Only the bytes that have been paid for should be read
+	buff := make([]byte, len(content))
+	n, err := pr.Read(buff)
+	require.NoError(t, err)
+	require.Equal(t, 10, n)
+	require.Equal(t, content[:10], buff[:n])
+
+	// The next read should pause until another payment arrives
+	go func() {
+		<-paused
+		session.addPayment(big.NewInt(90))
+	}()
+	n, err = pr.Read(buff)
+	require.NoError(t, err)
+	require.Equal(t, 90, n)
+	require.Equal(t, content[10:], buff[:n])
+
+	paid, paidBytes, sentBytes := session.status()
+	require.Equal(t, big.NewInt(100), paid)
+	require.EqualValues(t, len(content), paidBytes)
+	require.EqualValues(t, len(content), sentBytes)
+
+	// The session can be found by id
+	found, err := ss.get(session.id)
+	require.NoError(t, err)
+	require.Equal(t, session, found)
+}
+
+func TestPaidReaderTimeout(t *testing.T) {
+	ss := newSessionStore(time.Minute)
+	session := ss.create("key", testChannelId, quote{price: big.NewInt(100), size: 100})
+	pr := &paidReader{
+		ReadSeeker: bytes.NewReader(make([]byte, 100)),
+		ctx:        context.Background(),
+		session:    session,
+		timeout:    10 * time.Millisecond,
+	}
+
+	_, err := pr.Read(make([]byte, 10))
+	require.ErrorIs(t, err, errPaymentTimeout)
+}
+
+func TestPaidWriterContentLargerThanQuote(t *testing.T) {
+	// The quoted size is smaller than the content (eg a tar response that
+	// is larger than the size of the blocks)
+	ss := newSessionStore(time.Minute)
+	session := ss.create("key", testChannelId, quote{price: big.NewInt(100), size: 10})
+	session.addPayment(big.NewInt(100))
+
+	rec := httptest.NewRecorder()
+	pw := &paidWriter{
+		ResponseWriter: rec,
+		ctx:            context.Background(),
+		session:        session,
+		timeout:        10 * time.Millisecond,
+	}
+
+	// Once the full price has been paid, all of the content should be
+	// written without waiting for more payment
+	content := bytes.Repeat([]byte("a"), 50)
+	n, err := pw.Write(content)
+	require.NoError(t, err)
+	require.Equal(t, len(content), n)
+	require.Equal(t, content, rec.Body.Bytes())
+
+	// The writer can be flushed and unwrapped
+	pw.Flush()
+	require.True(t, rec.Flushed)
+	require.Equal(t, rec, pw.Unwrap())
+
+	_, paidBytes, sentBytes := session.status()
+	require.EqualValues(t, 50, paidBytes)
+	require.EqualValues(t, 50, sentBytes)
+}
+
+func TestQuoteLimiter(t *testing.T) {
+	req := func(addr string) *http.Request {
+		r, err := http.NewRequest("GET", "http://localhost/ipfs/foo", nil)
+		require.NoError(t, err)
+		r.RemoteAddr = addr
+		return r
+	}
+
+	// Each client can request a burst of two quotes
+	l := newQuoteLimiter(0.001, 2)
+	require.True(t, l.allow(req("1.2.3.4:1000")))
+	require.True(t, l.allow(req("1.2.3.4:1001")))
+	require.False(t, l.allow(req("1.2.3.4:1002")))
+
+	// Another client is not affected
+	require.True(t, l.allow(req("5.6.7.8:1000")))
+
+	// Zero means no limit
+	l = newQuoteLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		require.True(t, l.allow(req("1.2.3.4:1000")))
+	}
+}
+
+// testReceiver accepts vouchers like a nitro node: each voucher must have a
+// higher amount than the last voucher on the channel, and the payment is
+// the difference
+type testReceiver struct {
+	lk     sync.Mutex
+	totals map[types.Destination]*big.Int
+}
+
+func newTestReceiver() *testReceiver {
+	return &testReceiver{totals: make(map[types.Destination]*big.Int)}
+}
+
+func (r *testReceiver) ReceiveVoucher(v payments.Voucher) (payments.ReceiveVoucherSummary, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	prev, ok := r.totals[v.ChannelId]
+	if !ok {
+		prev = big.NewInt(0)
+	}
+	if v.Amount.Cmp(prev) <= 0 {
+		return payments.ReceiveVoucherSummary{}, errors.New("voucher amount must be greater than the previous voucher amount")
+	}
+	r.totals[v.ChannelId] = new(big.Int).Set(v.Amount)
+	return payments.ReceiveVoucherSummary{
+		Total: new(big.Int).Set(v.Amount),
+		Delta: new(big.Int).Sub(v.Amount, prev),
+	}, nil
+}
+
+// testPayerKey is the key that testVoucherParams signs vouchers with
+var testPayerKey = "8f9c1b2a3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8"
+
+// testVoucherParams returns the params of a voucher for the test channel
+// that is signed by testPayerKey
+func testVoucherParams(amount *big.Int) string {
+	v := payments.Voucher{ChannelId: types.Destination(common.HexToHash(testChannelId)), Amount: amount}
+	if err := v.Sign(common.Hex2Bytes(testPayerKey)); err != nil {
+		panic(err)
+	}
+	sig := append(append(append([]byte{}, v.Signature.R...), v.Signature.S...), v.Signature.V)
+	return fmt.Sprintf("channelId=%s&amount=%s&signature=%s", testChannelId, amount, hexutil.Encode(sig))
+}
+
+type testPaymentServer struct {
+	*httptest.Server
+	root       cid.Cid
+	carSize    uint64
+	quoteCount func() int
+}
+
+// newTestPaymentServer serves a test DAG from the gateway handler, charging
+// one token per byte
+func newTestPaymentServer(t *testing.T, opts NitroOptions) *testPaymentServer {
+	bs, nodes := createTestDag(t)
+	root := nodes[0].Cid()
+
+	var lk sync.Mutex
+	quoteCount := 0
+	ctrl := gomock.NewController(t)
+	api := mocks_booster_http.NewMockHttpServerApi(ctrl)
+	api.EXPECT().PiecesContainingMultihash(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(context.Context, multihash.Multihash) ([]cid.Cid, error) {
+			lk.Lock()
+			defer lk.Unlock()
+			quoteCount++
+			return nil, nil
+		})
+
+	pricer, err := pricing.NewRulePricer(pricing.Config{Default: pricing.Rule{PricePerByte: big.NewInt(1)}})
+	require.NoError(t, err)
+	opts.Pricer = pricer
+	opts.PayeeAddress = "0xpayee"
+	opts.AssetAddress = common.Address{}.String()
+	if opts.PaymentTimeout == 0 {
+		opts.PaymentTimeout = time.Second
+	}
+	if opts.Parser == nil {
+		opts.Parser = NewNitroParser()
+	}
+	if opts.Verifier == nil {
+		opts.Verifier = payment.NewNitroVerifier(newTestReceiver())
+	}
+	pm := newPaymentManager(opts)
+
+	gw, err := gateway.NewBlocksBackend(blockservice.New(bs, offline.Exchange(bs)))
+	require.NoError(t, err)
+	fmts := []string{"", "application/vnd.ipld.car", "application/vnd.ipld.raw"}
+	mux := http.NewServeMux()
+	mux.Handle("/ipfs/", newGatewayHandler(gw, bs, api, fmts, pm))
+	mux.Handle("/payment/", &corsHandler{http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pm.handlePayment(w, r, strings.TrimPrefix(r.URL.Path, "/payment/"))
+	})})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return &testPaymentServer{
+		Server:  srv,
+		root:    root,
+		carSize: testDagCarHeaderSize(t, root) + testDagCarBlocksSize(nodes),
+		quoteCount: func() int {
+			lk.Lock()
+			defer lk.Unlock()
+			return quoteCount
+		},
+	}
+}
+
+func decodePaymentRequired(t *testing.T, resp *http.Response) PaymentRequired {
+	require.Equal(t, http.StatusPaymentRequired, resp.StatusCode)
+	require.Equal(t, authorizationScheme, resp.Header.Get("WWW-Authenticate"))
+	var pr PaymentRequired
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&pr))
+	return pr
+}
+
+func TestGatewayPayment(t *testing.T) {
+	srv := newTestPaymentServer(t, NitroOptions{})
+	carUrl := srv.URL + "/ipfs/" + srv.root.String() + "?format=car"
+	price := new(big.Int).SetUint64(srv.carSize)
+
+	doRequest := func(method string, url string, hdrs map[string]string) *http.Response {
+		req, err := http.NewRequest(method, url, nil)
+		require.NoError(t, err)
+		for k, v := range hdrs {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	// A CORS preflight request is answered without asking for payment (and
+	// without calculating a quote)
+	resp := doRequest(http.MethodOptions, carUrl, map[string]string{
+		"Origin":                         "http://example.com",
+		"Access-Control-Request-Method":  "GET",
+		"Access-Control-Request-Headers": "x-payment",
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, resp.Header.Get("Access-Control-Allow-Headers"), "Authorization")
+	require.Equal(t, 0, srv.quoteCount())
+
+	// A request without a voucher gets a 402 with the price
+	resp = doRequest(http.MethodGet, carUrl, nil)
+	pr := decodePaymentRequired(t, resp)
+	require.Equal(t, price.String(), pr.Price)
+	require.Equal(t, "0xpayee", pr.Payee)
+	require.Equal(t, common.Address{}.String(), pr.Asset)
+	require.NotEmpty(t, pr.Error)
+	require.True(t, pr.QuoteExpiry.After(time.Now()))
+	require.Equal(t, 1, srv.quoteCount())
+
+	// A voucher that pays too little gets a 402, and the quote is reused
+	underpaid := new(big.Int).Sub(price, big.NewInt(1))
+	resp = doRequest(http.MethodGet, carUrl, map[string]string{PaymentHeader: testVoucherParams(underpaid)})
+	pr = decodePaymentRequired(t, resp)
+	require.Equal(t, price.String(), pr.Price)
+	require.Contains(t, pr.Error, "only resulted in a payment of "+underpaid.String())
+	require.Equal(t, 1, srv.quoteCount())
+
+	// A voucher in the header that pays the full price is accepted, even
+	// though the query params contain an invalid voucher (the header takes
+	// precedence)
+	paid := new(big.Int).Add(underpaid, price)
+	resp = doRequest(http.MethodGet, carUrl+"&channelId=0x01&amount=1&signature=0x00",
+		map[string]string{PaymentHeader: testVoucherParams(paid)})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, resp.Header.Get("Content-Type"), "application/vnd.ipld.car")
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NotEmpty(t, body)
+	require.Equal(t, 1, srv.quoteCount())
+
+	// A voucher that doesn't increase the amount paid on the channel is
+	// rejected
+	resp = doRequest(http.MethodGet, carUrl, map[string]string{PaymentHeader: testVoucherParams(paid)})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// testRecorder keeps the payments that are recorded in memory
+type testRecorder struct {
+	lk       sync.Mutex
+	payments []api.RetrievalPayment
+}
+
+func (r *testRecorder) BoostRetrievalPaymentRecord(_ context.Context, payment api.RetrievalPayment) error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	r.payments = append(r.payments, payment)
+	return nil
+}
+
+func (r *testRecorder) recorded() []api.RetrievalPayment {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	return append([]api.RetrievalPayment{}, r.payments...)
+}
+
+func TestGatewayPaymentRecorded(t *testing.T) {
+	recorder := &testRecorder{}
+	srv := newTestPaymentServer(t, NitroOptions{TrancheSize: 40, Recorder: recorder})
+	rawUrl := srv.URL + "/ipfs/" + srv.root.String() + "/b?format=raw"
+
+	doRequest := func(method string, url string, hdrs map[string]string) *http.Response {
+		req, err := http.NewRequest(method, url, nil)
+		require.NoError(t, err)
+		for k, v := range hdrs {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	// A request without a voucher is not recorded
+	resp := doRequest(http.MethodGet, rawUrl, nil)
+	require.Equal(t, http.StatusPaymentRequired, resp.StatusCode)
+	require.Empty(t, recorder.recorded())
+
+	// A paid request is recorded with the number of bytes that were sent
+	resp = doRequest(http.MethodGet, rawUrl, map[string]string{PaymentHeader: testVoucherParams(big.NewInt(120))})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Len(t, body, 100)
+
+	// The payment is recorded after the response has been sent
+	require.Eventually(t, func() bool { return len(recorder.recorded()) == 1 }, time.Second, 10*time.Millisecond)
+	p := recorder.recorded()[0]
+	require.Equal(t, types.Destination(common.HexToHash(testChannelId)).String(), p.ChannelID)
+	key, err := crypto.HexToECDSA(testPayerKey)
+	require.NoError(t, err)
+	require.Equal(t, crypto.PubkeyToAddress(key.PublicKey).String(), p.Payer)
+	require.Equal(t, "http", p.Transport)
+	require.EqualValues(t, 120, p.Amount.Int64())
+	require.EqualValues(t, 120, p.Total.Int64())
+	require.Equal(t, merkledag.NewRawNode(bytes.Repeat([]byte("b"), 100)).Cid(), p.PayloadCID)
+	require.Nil(t, p.PieceCID)
+	require.EqualValues(t, 100, p.BytesSent)
+
+	// A pay-as-you-go download records the first tranche with the bytes
+	// sent in the download, and vouchers sent to the payment endpoint
+	// without any bytes
+	resp = doRequest(http.MethodGet, rawUrl, map[string]string{
+		PaymentModeHeader: paymentModeIncremental,
+		PaymentHeader:     testVoucherParams(big.NewInt(160)),
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	sessionID := resp.Header.Get(PaymentSessionHeader)
+	_, err = io.ReadFull(resp.Body, make([]byte, 40))
+	require.NoError(t, err)
+
+	payResp := doRequest(http.MethodPost, srv.URL+"/payment/"+sessionID, map[string]string{
+		PaymentHeader: testVoucherParams(big.NewInt(220)),
+	})
+	require.Equal(t, http.StatusOK, payResp.StatusCode)
+	require.Eventually(t, func() bool { return len(recorder.recorded()) == 2 }, time.Second, 10*time.Millisecond)
+	p = recorder.recorded()[1]
+	require.EqualValues(t, 60, p.Amount.Int64())
+	require.EqualValues(t, 220, p.Total.Int64())
+	require.Zero(t, p.BytesSent)
+
+	rest, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Len(t, rest, 60)
+	require.Eventually(t, func() bool { return len(recorder.recorded()) == 3 }, time.Second, 10*time.Millisecond)
+	p = recorder.recorded()[2]
+	require.EqualValues(t, 40, p.Amount.Int64())
+	require.EqualValues(t, 100, p.BytesSent)
+}
+
+func TestGatewayIncrementalPayment(t *testing.T) {
+	// Pay for the content in tranches of 40 bytes
+	srv := newTestPaymentServer(t, NitroOptions{TrancheSize: 40, PaymentTimeout: 5 * time.Second})
+	rawUrl := srv.URL + "/ipfs/" + srv.root.String() + "/b?format=raw"
+	content := bytes.Repeat([]byte("b"), 100)
+
+	doRequest := func(method string, url string, hdrs map[string]string) *http.Response {
+		req, err := http.NewRequest(method, url, nil)
+		require.NoError(t, err)
+		for k, v := range hdrs {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	// A request without a voucher is told the price of each tranche
+	resp := doRequest(http.MethodGet, rawUrl, map[string]string{PaymentModeHeader: paymentModeIncremental})
+	pr := decodePaymentRequired(t, resp)
+	require.Equal(t, "100", pr.Price)
+	require.EqualValues(t, 40, pr.TrancheSize)
+	require.Equal(t, "40", pr.TranchePrice)
+
+	// A voucher that doesn't pay for the first tranche is rejected
+	resp = doRequest(http.MethodGet, rawUrl, map[string]string{
+		PaymentModeHeader: paymentModeIncremental,
+		PaymentHeader:     testVoucherParams(big.NewInt(10)),
+	})
+	pr = decodePaymentRequired(t, resp)
+	require.Contains(t, pr.Error, "required for the first tranche")
+
+	// Pay for the first tranche: the response pauses after 40 bytes
+	resp = doRequest(http.MethodGet, rawUrl, map[string]string{
+		PaymentModeHeader: paymentModeIncremental,
+		PaymentHeader:     testVoucherParams(big.NewInt(50)),
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	sessionID := resp.Header.Get(PaymentSessionHeader)
+	require.NotEmpty(t, sessionID)
+	require.Equal(t, "40", resp.Header.Get(PaymentTrancheSizeHeader))
+	require.Equal(t, "40", resp.Header.Get(PaymentTranchePrice))
+
+	buff := make([]byte, len(content))
+	_, err := io.ReadFull(resp.Body, buff[:40])
+	require.NoError(t, err)
+
+	// Paying for the next tranche resumes the response
+	payResp := doRequest(http.MethodPost, srv.URL+"/payment/"+sessionID, map[string]string{
+		PaymentHeader: testVoucherParams(big.NewInt(80)),
+	})
+	require.Equal(t, http.StatusOK, payResp.StatusCode)
+	var status sessionStatus
+	require.NoError(t, json.NewDecoder(payResp.Body).Decode(&status))
+	require.Equal(t, sessionID, status.Session)
+	require.Equal(t, "30", status.Received)
+	require.Equal(t, "70", status.Paid)
+	require.EqualValues(t, 70, status.PaidBytes)
+
+	_, err = io.ReadFull(resp.Body, buff[40:70])
+	require.NoError(t, err)
+	require.Equal(t, content[:70], buff[:70])
+
+	// The download is interrupted
+	require.NoError(t, resp.Body.Close())
+
+	// Paying for an unknown session fails
+	payResp = doRequest(http.MethodPost, srv.URL+"/payment/unknown", map[string]string{
+		PaymentHeader: testVoucherParams(big.NewInt(90)),
+	})
+	require.Equal(t, http.StatusNotFound, payResp.StatusCode)
+
+	// Resume the download from where it was interrupted, paying the rest
+	// of the price
+	resp = doRequest(http.MethodGet, rawUrl, map[string]string{
+		"Range":              "bytes=70-",
+		PaymentSessionHeader: sessionID,
+		PaymentHeader:        testVoucherParams(big.NewInt(110)),
+	})
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	require.Equal(t, sessionID, resp.Header.Get(PaymentSessionHeader))
+	rest, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, content[70:], rest)
+
+	// A session can't be used to pay for different content
+	resp = doRequest(http.MethodGet, srv.URL+"/ipfs/"+srv.root.String()+"/a?format=raw", map[string]string{
+		PaymentSessionHeader: sessionID,
+	})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// unreachableReceiver behaves like the nitro RPC client when it can't send
+// a request to the nitro node
+type unreachableReceiver struct{}
+
+func (r *unreachableReceiver) ReceiveVoucher(payments.Voucher) (payments.ReceiveVoucherSummary, error) {
+	panic("connection refused")
+}
+
+func TestGatewayPaymentBackendFailure(t *testing.T) {
+	srv := newTestPaymentServer(t, NitroOptions{Verifier: payment.NewNitroVerifier(&unreachableReceiver{})})
+	carUrl := srv.URL + "/ipfs/" + srv.root.String() + "?format=car"
+
+	// If the nitro node can't be reached the request fails with a bad
+	// gateway error, rather than crashing the server
+	req, err := http.NewRequest(http.MethodGet, carUrl, nil)
+	require.NoError(t, err)
+	req.Header.Set(PaymentHeader, testVoucherParams(new(big.Int).SetUint64(srv.carSize)))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "connection refused")
+}
+
+func TestGatewayAllowlistPayment(t *testing.T) {
+	srv := newTestPaymentServer(t, NitroOptions{
+		Parser:   NewBearerTokenParser(),
+		Verifier: payment.NewAllowlistVerifier([]string{"secret"}),
+	})
+	carUrl := srv.URL + "/ipfs/" + srv.root.String() + "?format=car"
+
+	get := func(authorization string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, carUrl, nil)
+		require.NoError(t, err)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	// A request without a token gets a 402 that asks for a bearer token
+	resp := get("")
+	require.Equal(t, http.StatusPaymentRequired, resp.StatusCode)
+	require.Equal(t, "Bearer", resp.Header.Get("WWW-Authenticate"))
+
+	// A token that is not in the allowlist is rejected
+	resp = get("Bearer wrong")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// A token in the allowlist pays for the content
+	resp = get("Bearer secret")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.EqualValues(t, srv.carSize, len(body))
+}
+
+func TestGatewayCarRangePayment(t *testing.T) {
+	srv := newTestPaymentServer(t, NitroOptions{})
+	carUrl := srv.URL + "/ipfs/" + srv.root.String() + "?format=car"
+
+	get := func(rangeHdr string, paymentParams string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, carUrl, nil)
+		require.NoError(t, err)
+		req.Header.Set("Range", rangeHdr)
+		if paymentParams != "" {
+			req.Header.Set(PaymentHeader, paymentParams)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	// A range request is priced by the size of the range
+	rangeHdr := "bytes=10-59"
+	resp := get(rangeHdr, "")
+	pr := decodePaymentRequired(t, resp)
+	require.Equal(t, "50", pr.Price)
+
+	// Paying for the range gets the bytes in the range
+	resp = get(rangeHdr, testVoucherParams(big.NewInt(50)))
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Len(t, body, 50)
+
+	// The rest of the CAR is priced separately
+	resp = get("bytes=60-", "")
+	pr = decodePaymentRequired(t, resp)
+	require.Equal(t, fmt.Sprint(srv.carSize-60), pr.Price)
+
+	// A range that is outside the CAR can't be priced
+	resp = get(fmt.Sprintf("bytes=%d-", srv.carSize), "")
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+
+	// Pricing a range requires a new quote, so range requests are subject
+	// to the quote rate limit
+	srv = newTestPaymentServer(t, NitroOptions{QuoteRateLimit: 0.001, QuoteRateBurst: 1})
+	carUrl = srv.URL + "/ipfs/" + srv.root.String() + "?format=car"
+	resp = get("bytes=10-59", "")
+	decodePaymentRequired(t, resp)
+	resp = get("bytes=60-", "")
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+var errTooManyQuotes = errors.New("too many price quote requests, please try again later")
+
+// The amount of time after which the rate limiter for an idle client is
+// discarded
+const limiterIdleTimeout = 10 * time.Minute
+
+// quoteLimiter limits the rate at which each client can request price
+// quotes, because calculating a quote may require reading the whole DAG
+type quoteLimiter struct {
+	limit rate.Limit
+	burst int
+
+	lk        sync.Mutex
+	limiters  map[string]*clientLimiter
+	lastPrune time.Time
+}
+
+type clientLimiter struct {
+	*rate.Limiter
+	lastSeen time.Time
+}
+
+// newQuoteLimiter creates a limiter that allows each client to request
+// perSecond quotes per second, with the given burst.
+// If perSecond is zero there is no limit.
+func newQuoteLimiter(perSecond float64, burst int) *quoteLimiter {
+	if perSecond <= 0 {
+		return &quoteLimiter{limit: rate.Inf}
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &quoteLimiter{
+		limit:     rate.Limit(perSecond),
+		burst:     burst,
+		limiters:  make(map[string]*clientLimiter),
+		lastPrune: time.Now(),
+	}
+}
+
+// allow returns true if the client that sent the request may request
+// another quote
+func (l *quoteLimiter) allow(r *http.Request) bool {
+	if l.limit == rate.Inf {
+		return true
+	}
+
+	client := clientIP(r)
+	now := time.Now()
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	// Discard limiters for clients that have been idle for a while
+	if now.Sub(l.lastPrune) > limiterIdleTimeout {
+		for k, cl := range l.limiters {
+			if now.Sub(cl.lastSeen) > limiterIdleTimeout {
+				delete(l.limiters, k)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	cl, ok := l.limiters[client]
+	if !ok {
+		cl = &clientLimiter{Limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[client] = cl
+	}
+	cl.lastSeen = now
+	return cl.AllowN(now, 1)
+}
+
+// clientIP returns the IP address of the client that sent the request
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
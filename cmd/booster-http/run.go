@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/big"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
@@ -15,6 +16,8 @@ import (
 	cliutil "github.com/filecoin-project/boost/cli/util"
 	"github.com/filecoin-project/boost/cmd/lib"
 	"github.com/filecoin-project/boost/cmd/lib/filters"
+	"github.com/filecoin-project/boost/cmd/lib/payment"
+	"github.com/filecoin-project/boost/cmd/lib/pricing"
 	"github.com/filecoin-project/boost/cmd/lib/remoteblockstore"
 	"github.com/filecoin-project/boost/metrics"
 	"github.com/filecoin-project/boostd-data/shared/tracing"
@@ -25,6 +28,8 @@ import (
 	"github.com/filecoin-project/lotus/markets/dagstore"
 	"github.com/ipfs/go-cid"
 	"github.com/mitchellh/go-homedir"
+	"github.com/multiformats/go-multihash"
+	nrpc "github.com/statechannels/go-nitro/rpc"
 	"github.com/urfave/cli/v2"
 )
 
@@ -35,7 +40,7 @@ var runCmd = &cli.Command{
 	Flags: []cli.Flag{
 		&cli.BoolFlag{
 			Name:  "nitro-enabled",
-			Usage: "enables nitro micro payments",
+			Usage: "enables nitro micro payments (recording payments requires an admin token in --api-boost)",
 			Value: false,
 		},
 		&cli.StringFlag{
@@ -43,6 +48,72 @@ var runCmd = &cli.Command{
 			Usage: "the endpoint for the nitro server",
 			Value: "host.docker.internal:4007/api/v1",
 		},
+		&cli.Uint64Flag{
+			Name:  "nitro-price-per-byte",
+			Usage: "the price per byte of a nitro paid retrieval",
+			Value: 0,
+		},
+		&cli.Uint64Flag{
+			Name:  "nitro-min-price",
+			Usage: "the minimum price of a nitro paid retrieval, regardless of its size",
+			Value: 5,
+		},
+		&cli.StringFlag{
+			Name:  "nitro-pricing-config",
+			Usage: "path to a JSON file with the pricing rules for nitro paid retrievals, including per-CID and per-piece overrides (overrides --nitro-price-per-byte and --nitro-min-price)",
+		},
+		&cli.StringFlag{
+			Name:  "nitro-payee-address",
+			Usage: "the nitro address that clients should pay, returned to clients in a 402 Payment Required response (defaults to the address of the nitro node)",
+		},
+		&cli.StringFlag{
+			Name:  "nitro-asset-address",
+			Usage: "the address of the asset that is accepted as payment (the zero address is the chain's native token)",
+			Value: "0x0000000000000000000000000000000000000000",
+		},
+		&cli.DurationFlag{
+			Name:  "nitro-quote-ttl",
+			Usage: "the amount of time for which a price quoted to a client is honoured",
+			Value: defaultQuoteTTL,
+		},
+		&cli.Uint64Flag{
+			Name:  "nitro-tranche-size",
+			Usage: "enables pay-as-you-go downloads, where the client pays for each tranche of this many bytes as the download progresses (zero disables pay-as-you-go downloads)",
+			Value: 0,
+		},
+		&cli.DurationFlag{
+			Name:  "nitro-payment-timeout",
+			Usage: "how long a pay-as-you-go download waits for the next payment before failing",
+			Value: defaultPaymentTimeout,
+		},
+		&cli.Uint64Flag{
+			Name:  "nitro-quote-max-blocks",
+			Usage: "the maximum number of blocks to read when calculating the size of a DAG for a price quote; larger DAGs are priced by the size of the piece that contains them (zero means no limit)",
+			Value: 10_000,
+		},
+		&cli.Float64Flag{
+			Name:  "nitro-quote-rate-limit",
+			Usage: "the number of new price quotes per second that each client can request (zero means no limit)",
+			Value: 1,
+		},
+		&cli.IntFlag{
+			Name:  "nitro-quote-rate-burst",
+			Usage: "the maximum burst of new price quotes that each client can request",
+			Value: 10,
+		},
+		&cli.StringFlag{
+			Name:  "nitro-pricing-cmd",
+			Usage: "an external command to run to calculate the price of a nitro paid retrieval (overrides --nitro-pricing-config)",
+		},
+		&cli.StringFlag{
+			Name:  "payment-backend",
+			Usage: "the backend that verifies the payments for paid retrievals: 'nitro' (nitro vouchers) or 'allowlist' (requests with a bearer token from --payment-allowlist are paid in full)",
+			Value: "nitro",
+		},
+		&cli.StringSliceFlag{
+			Name:  "payment-allowlist",
+			Usage: "the bearer tokens that are accepted as payment when --payment-backend is 'allowlist'",
+		},
 
 		&cli.BoolFlag{
 			Name:  "pprof",
@@ -217,8 +288,58 @@ var runCmd = &cli.Command{
 		}
 
 		nitroOpts := &NitroOptions{
-			Enabled:  cctx.Bool("nitro-enabled"),
-			Endpoint: cctx.String("nitro-endpoint"),
+			Enabled:        cctx.Bool("nitro-enabled"),
+			PayeeAddress:   cctx.String("nitro-payee-address"),
+			AssetAddress:   cctx.String("nitro-asset-address"),
+			QuoteTTL:       cctx.Duration("nitro-quote-ttl"),
+			TrancheSize:    cctx.Uint64("nitro-tranche-size"),
+			PaymentTimeout: cctx.Duration("nitro-payment-timeout"),
+			QuoteMaxBlocks: cctx.Uint64("nitro-quote-max-blocks"),
+			QuoteRateLimit: cctx.Float64("nitro-quote-rate-limit"),
+			QuoteRateBurst: cctx.Int("nitro-quote-rate-burst"),
+		}
+		if nitroOpts.Enabled {
+			nitroOpts.Pricer, err = createPricer(cctx)
+			if err != nil {
+				return err
+			}
+
+			nitroOpts.Recorder = bapi
+
+			switch backend := cctx.String("payment-backend"); backend {
+			case "nitro":
+				nitroEndpoint := cctx.String("nitro-endpoint")
+				nitroClient, err := nrpc.NewHttpRpcClient(nitroEndpoint)
+				if err != nil {
+					return fmt.Errorf("connecting to nitro rpc server at %s: %w", nitroEndpoint, err)
+				}
+				defer func() {
+					if err := nitroClient.Close(); err != nil {
+						log.Warnf("closing nitro rpc client: %s", err)
+					}
+				}()
+				nitroOpts.Parser = NewNitroParser()
+				nitroOpts.Verifier = payment.NewNitroVerifier(nitroClient)
+
+				// If the payee address is not set, clients should pay the
+				// address of the nitro node
+				if nitroOpts.PayeeAddress == "" {
+					addr, err := nitroClient.Address()
+					if err != nil {
+						return fmt.Errorf("getting address of nitro node: %w", err)
+					}
+					nitroOpts.PayeeAddress = addr.String()
+				}
+			case "allowlist":
+				tokens := cctx.StringSlice("payment-allowlist")
+				if len(tokens) == 0 {
+					return errors.New("the allowlist payment backend requires at least one token in --payment-allowlist")
+				}
+				nitroOpts.Parser = NewBearerTokenParser()
+				nitroOpts.Verifier = payment.NewAllowlistVerifier(tokens)
+			default:
+				return fmt.Errorf("unknown payment backend '%s': must be 'nitro' or 'allowlist'", backend)
+			}
 		}
 
 		sapi := serverApi{ctx: ctx, bapi: bapi, sa: sa}
@@ -307,6 +428,33 @@ func ipfsGatewayMsg(cctx *cli.Context, ipfsBasePath string) string {
 	return "serving IPFS gateway at " + ipfsBasePath + " (serving " + strings.Join(fmts, ", ") + ")"
 }
 
+func createPricer(cctx *cli.Context) (pricing.Pricer, error) {
+	if cmd := cctx.String("nitro-pricing-cmd"); cmd != "" {
+		log.Infof("using external pricing command for nitro retrievals: %s", cmd)
+		return pricing.NewExternalPricer(cmd), nil
+	}
+
+	cfg := &pricing.Config{
+		Default: pricing.Rule{
+			PricePerByte: new(big.Int).SetUint64(cctx.Uint64("nitro-price-per-byte")),
+			MinPrice:     new(big.Int).SetUint64(cctx.Uint64("nitro-min-price")),
+		},
+	}
+	if cfgPath := cctx.String("nitro-pricing-config"); cfgPath != "" {
+		var err error
+		cfg, err = pricing.LoadConfig(cfgPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pricer, err := pricing.NewRulePricer(*cfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating nitro pricer: %w", err)
+	}
+	return pricer, nil
+}
+
 func createRepoDir(repoDir string) (string, error) {
 	repoDir, err := homedir.Expand(repoDir)
 	if err != nil {
@@ -338,6 +486,10 @@ func (s serverApi) UnsealSectorAt(ctx context.Context, sectorID abi.SectorNumber
 	return s.sa.UnsealSectorAt(ctx, sectorID, offset, length)
 }
 
+func (s serverApi) PiecesContainingMultihash(ctx context.Context, mh multihash.Multihash) ([]cid.Cid, error) {
+	return s.bapi.BoostDagstorePiecesContainingMultihash(ctx, mh)
+}
+
 func getBoostApi(ctx context.Context, ai string) (api.Boost, jsonrpc.ClientCloser, error) {
 	ai = strings.TrimPrefix(strings.TrimSpace(ai), "BOOST_API_INFO=")
 	info := cliutil.ParseApiInfo(ai)
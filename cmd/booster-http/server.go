@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net"
 	"net/http"
 	"strings"
@@ -15,6 +16,8 @@ import (
 	"github.com/fatih/color"
 	"github.com/filecoin-project/boost-gfm/piecestore"
 	"github.com/filecoin-project/boost-gfm/retrievalmarket"
+	"github.com/filecoin-project/boost/cmd/lib/payment"
+	"github.com/filecoin-project/boost/cmd/lib/pricing"
 	"github.com/filecoin-project/boost/metrics"
 	"github.com/filecoin-project/boostd-data/shared/tracing"
 	"github.com/filecoin-project/dagstore/mount"
@@ -26,7 +29,7 @@ import (
 	"github.com/ipfs/boxo/gateway"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
-	nrpc "github.com/statechannels/go-nitro/rpc"
+	"github.com/multiformats/go-multihash"
 	"go.opencensus.io/stats"
 )
 
@@ -55,13 +58,14 @@ type HttpServer struct {
 	cancel context.CancelFunc
 	server *http.Server
 
-	nitroRpcClient *nrpc.RpcClient
+	payments *paymentManager
 }
 
 type HttpServerApi interface {
 	GetPieceInfo(pieceCID cid.Cid) (*piecestore.PieceInfo, error)
 	IsUnsealed(ctx context.Context, sectorID abi.SectorNumber, offset abi.UnpaddedPieceSize, length abi.UnpaddedPieceSize) (bool, error)
 	UnsealSectorAt(ctx context.Context, sectorID abi.SectorNumber, pieceOffset abi.UnpaddedPieceSize, length abi.UnpaddedPieceSize) (mount.Reader, error)
+	PiecesContainingMultihash(ctx context.Context, mh multihash.Multihash) ([]cid.Cid, error)
 }
 
 type HttpServerOptions struct {
@@ -70,25 +74,67 @@ type HttpServerOptions struct {
 	SupportedResponseFormats []string
 }
 
+// defaultPricer charges a fixed price per request
+var defaultPricer = &pricing.FixedPricer{Amount: big.NewInt(5)}
+
+// The default amount of time to wait for the next payment for a paused
+// pay-as-you-go download
+const defaultPaymentTimeout = time.Minute
+
 type NitroOptions struct {
-	Enabled  bool
-	Endpoint string
+	Enabled bool
+	// Parses the payments sent by clients with requests (defaults to
+	// nitro vouchers)
+	Parser PaymentParser
+	// Verifies the payments sent by clients (eg a nitro verifier that
+	// redeems vouchers with the nitro RPC client)
+	Verifier payment.Verifier
+	// Records the payments received for retrievals (optional)
+	Recorder PaymentRecorder
+	// Calculates the payment required to serve a request
+	Pricer pricing.Pricer
+	// The nitro address that clients should pay
+	PayeeAddress string
+	// The address of the asset that is accepted as payment
+	AssetAddress string
+	// The amount of time for which a price quote is honoured
+	QuoteTTL time.Duration
+	// The number of bytes sent per payment for pay-as-you-go downloads.
+	// Zero disables pay-as-you-go downloads.
+	TrancheSize uint64
+	// How long to wait for the next payment before failing a paused
+	// pay-as-you-go download
+	PaymentTimeout time.Duration
+	// The maximum number of blocks to walk when calculating the size of a
+	// DAG for a quote. Larger DAGs are priced by the size of the piece that
+	// contains them. Zero means no limit.
+	QuoteMaxBlocks uint64
+	// The number of new quotes per second that each client can request.
+	// Zero means no limit.
+	QuoteRateLimit float64
+	// The maximum burst of new quotes that each client can request
+	QuoteRateBurst int
 }
 
 func NewHttpServer(path string, listenAddr string, port int, api HttpServerApi, opts *HttpServerOptions, nitroOpts *NitroOptions) *HttpServer {
 	if opts == nil {
 		opts = &HttpServerOptions{ServePieces: true}
 	}
-	var rpcClient *nrpc.RpcClient
-	var err error
+	var payments *paymentManager
 	if nitroOpts != nil && nitroOpts.Enabled {
-
-		rpcClient, err = nrpc.NewHttpRpcClient(nitroOpts.Endpoint)
-		if err != nil {
-			panic(err)
+		nOpts := *nitroOpts
+		if nOpts.Pricer == nil {
+			nOpts.Pricer = defaultPricer
 		}
+		if nOpts.PaymentTimeout == 0 {
+			nOpts.PaymentTimeout = defaultPaymentTimeout
+		}
+		if nOpts.Parser == nil {
+			nOpts.Parser = NewNitroParser()
+		}
+		payments = newPaymentManager(nOpts)
 	}
-	return &HttpServer{path: path, port: port, api: api, opts: *opts, idxPage: parseTemplate(*opts), nitroRpcClient: rpcClient}
+	return &HttpServer{path: path, port: port, api: api, opts: *opts, idxPage: parseTemplate(*opts), payments: payments}
 
 }
 
@@ -100,7 +146,15 @@ func (s *HttpServer) ipfsBasePath() string {
 	return s.path + "/ipfs/"
 }
 
+func (s *HttpServer) paymentBasePath() string {
+	return s.path + "/payment/"
+}
+
 func (s *HttpServer) Start(ctx context.Context) error {
+	if s.payments != nil && s.payments.verifier == nil {
+		return errors.New("nitro payments are enabled but there is no payment verifier")
+	}
+
 	s.ctx, s.cancel = context.WithCancel(ctx)
 	handler := http.NewServeMux()
 
@@ -114,7 +168,13 @@ func (s *HttpServer) Start(ctx context.Context) error {
 		if err != nil {
 			return fmt.Errorf("creating blocks gateway: %w", err)
 		}
-		handler.Handle(s.ipfsBasePath(), newGatewayHandler(gw, s.opts.SupportedResponseFormats, s.nitroRpcClient))
+		handler.Handle(s.ipfsBasePath(), newGatewayHandler(gw, s.opts.Blockstore, s.api, s.opts.SupportedResponseFormats, s.payments))
+	}
+
+	if s.payments != nil {
+		// Clients may send vouchers from the browser, so handle CORS
+		// preflight requests
+		handler.Handle(s.paymentBasePath(), &corsHandler{http.HandlerFunc(s.handlePayment)})
 	}
 
 	handler.HandleFunc("/", s.handleIndex)
@@ -199,6 +259,38 @@ func (s *HttpServer) handleByPieceCid(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if s.payments != nil {
+		session, receipt, ok := s.payments.checkPayment(w, r, r.URL.Path, func(ctx context.Context) (quote, error) {
+			return s.pieceQuote(ctx, pieceCid, content)
+		})
+		if !ok {
+			return
+		}
+
+		// Record the payment along with the number of bytes that were sent
+		if receipt != nil {
+			cw := &countingWriter{ResponseWriter: w}
+			defer func() { s.payments.recordPayment(ctx, receipt, cw.written) }()
+			w = cw
+		}
+
+		// For pay-as-you-go downloads, only send as many bytes as have
+		// been paid for
+		if session != nil {
+			content = &paidReader{
+				ReadSeeker: content,
+				ctx:        ctx,
+				session:    session,
+				timeout:    s.payments.opts.PaymentTimeout,
+				onPause: func() {
+					if f, ok := w.(http.Flusher); ok {
+						f.Flush()
+					}
+				},
+			}
+		}
+	}
+
 	// Set an Etag based on the piece cid
 	setEtag(w, pieceCid.String())
 
@@ -208,6 +300,37 @@ func (s *HttpServer) handleByPieceCid(w http.ResponseWriter, r *http.Request) {
 	stats.Record(ctx, metrics.HttpPieceByCidRequestDuration.M(float64(time.Since(startTime).Milliseconds())))
 }
 
+// pieceQuote calculates the payment required to serve the piece
+func (s *HttpServer) pieceQuote(ctx context.Context, pieceCid cid.Cid, content io.ReadSeeker) (quote, error) {
+	size, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return quote{}, fmt.Errorf("getting size of piece %s: %w", pieceCid, err)
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return quote{}, fmt.Errorf("seeking to start of piece %s: %w", pieceCid, err)
+	}
+
+	price, err := s.payments.opts.Pricer.Price(ctx, pricing.Input{
+		PieceCids: []cid.Cid{pieceCid},
+		Format:    pricing.FormatPiece,
+		Size:      uint64(size),
+	})
+	if err != nil {
+		return quote{}, err
+	}
+	return quote{price: price, size: uint64(size), pieces: []cid.Cid{pieceCid}}, nil
+}
+
+func (s *HttpServer) handlePayment(w http.ResponseWriter, r *http.Request) {
+	prefixLen := len(s.paymentBasePath())
+	if len(r.URL.Path) <= prefixLen {
+		webError(w, fmt.Errorf("path '%s' is missing payment session id", r.URL.Path), http.StatusBadRequest)
+		return
+	}
+
+	s.payments.handlePayment(w, r, r.URL.Path[prefixLen:])
+}
+
 func serveContent(w http.ResponseWriter, r *http.Request, content io.ReadSeeker) {
 	// Set the Content-Type header explicitly so that http.ServeContent doesn't
 	// try to do it implicitly
@@ -20,7 +20,9 @@ type corsHandler struct {
 func (h *corsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, DELETE, PUT")
-	w.Header().Set("Access-Control-Allow-Headers", "*")
+	// The Authorization header is not covered by the * wildcard, so it
+	// must be listed explicitly (clients may send vouchers in it)
+	w.Header().Set("Access-Control-Allow-Headers", "*, Authorization")
 	if r.Method == "OPTIONS" {
 		_, _ = w.Write([]byte("OK"))
 		return
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log"
+	"math/big"
 	"os"
 
 	"github.com/filecoin-project/boost/pkg/devnet"
@@ -18,6 +19,7 @@ func init() {
 
 func main() {
 	const initFlag = "initialize"
+	const fakeNitroFlag = "fake-nitro"
 	app := &cli.App{
 		Name:  "devnet",
 		Usage: "Run a local devnet",
@@ -28,6 +30,25 @@ func main() {
 				Value:   true,
 				Usage:   "Whether to initialize the devnet or attempt to use the existing state directories and config.",
 			},
+			&cli.BoolFlag{
+				Name:  fakeNitroFlag,
+				Usage: "Run fake nitro nodes for the client and provider, with a payment channel between them, so that paid retrievals can be tested without a nitro node.",
+			},
+			&cli.StringFlag{
+				Name:  "fake-nitro-client-addr",
+				Usage: "The listen address of the client's fake nitro node",
+				Value: "127.0.0.1:4005",
+			},
+			&cli.StringFlag{
+				Name:  "fake-nitro-provider-addr",
+				Usage: "The listen address of the provider's fake nitro node",
+				Value: "127.0.0.1:4007",
+			},
+			&cli.Uint64Flag{
+				Name:  "fake-nitro-funds",
+				Usage: "The amount the fake nitro payment channel is funded with",
+				Value: 1_000_000_000,
+			},
 		},
 		Action: func(cctx *cli.Context) error {
 
@@ -40,6 +61,17 @@ func main() {
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
 
+			if cctx.Bool(fakeNitroFlag) {
+				err := devnet.RunFakeNitro(ctx, devnet.FakeNitroOptions{
+					ClientListenAddr:   cctx.String("fake-nitro-client-addr"),
+					ProviderListenAddr: cctx.String("fake-nitro-provider-addr"),
+					Funds:              new(big.Int).SetUint64(cctx.Uint64("fake-nitro-funds")),
+				})
+				if err != nil {
+					return err
+				}
+			}
+
 			go devnet.Run(ctx, home, done, cctx.Bool(initFlag))
 
 			<-done
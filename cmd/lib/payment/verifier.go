@@ -0,0 +1,139 @@
+package payment
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/statechannels/go-nitro/payments"
+)
+
+// ErrBackend is returned when the backend that verifies payments fails
+// (eg the nitro node is unreachable), as opposed to the payment being
+// invalid
+var ErrBackend = errors.New("payment backend error")
+
+// Payment is a payment sent by a client with a retrieval request
+type Payment struct {
+	// Identifies where the payment comes from (eg the nitro payment
+	// channel). All the payments for a pay-as-you-go download must come
+	// from the same source.
+	Source string
+	// The backend specific payment (eg a nitro voucher)
+	Voucher interface{}
+}
+
+// NitroPayment creates a payment from a nitro voucher
+func NitroPayment(v payments.Voucher) Payment {
+	return Payment{Source: v.ChannelId.String(), Voucher: v}
+}
+
+// TokenPayment creates a payment from a bearer token. The source is a hash
+// of the token, so that the token itself isn't recorded.
+func TokenPayment(token string) Payment {
+	h := sha256.Sum256([]byte(token))
+	return Payment{Source: "token:" + hex.EncodeToString(h[:8]), Voucher: token}
+}
+
+// Received is the result of verifying a payment
+type Received struct {
+	// The amount received from this payment
+	Amount *big.Int
+	// The total amount received from the payment's source so far
+	Total *big.Int
+	// Identifies the payer (eg the address that signed a nitro voucher)
+	Payer string
+}
+
+// Verifier verifies payments with a payment backend (eg a nitro node).
+// It is independent of the transport that the payment was sent over.
+type Verifier interface {
+	// Verify checks that the payment is valid, and returns the amount that
+	// was received. The price is the amount owed for the retrieval, if it
+	// is known. If the backend fails the error wraps ErrBackend.
+	Verify(ctx context.Context, p Payment, price *big.Int) (Received, error)
+}
+
+// VoucherReceiver receives nitro payment vouchers (eg the nitro RPC client)
+type VoucherReceiver interface {
+	ReceiveVoucher(v payments.Voucher) (payments.ReceiveVoucherSummary, error)
+}
+
+// nitroVerifier verifies nitro payment vouchers
+type nitroVerifier struct {
+	receiver VoucherReceiver
+}
+
+var _ Verifier = (*nitroVerifier)(nil)
+
+// NewNitroVerifier creates a Verifier that redeems nitro vouchers with the
+// receiver (eg the nitro RPC client)
+func NewNitroVerifier(receiver VoucherReceiver) Verifier {
+	return &nitroVerifier{receiver: receiver}
+}
+
+func (v *nitroVerifier) Verify(_ context.Context, p Payment, _ *big.Int) (res Received, err error) {
+	voucher, ok := p.Voucher.(payments.Voucher)
+	if !ok {
+		return Received{}, fmt.Errorf("expected a nitro voucher but got %T", p.Voucher)
+	}
+
+	// The nitro RPC client panics if it can't send the request to the
+	// nitro node
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: sending voucher to nitro node: %v", ErrBackend, r)
+		}
+	}()
+
+	s, err := v.receiver.ReceiveVoucher(voucher)
+	if err != nil {
+		return Received{}, fmt.Errorf("error processing voucher: %w", err)
+	}
+
+	res = Received{Amount: s.Delta, Total: s.Total}
+	// The nitro node has checked that the voucher was signed by the payer
+	// of the channel
+	if signer, err := voucher.RecoverSigner(); err == nil {
+		res.Payer = signer.String()
+	}
+	if res.Amount == nil {
+		res.Amount = new(big.Int)
+	}
+	if res.Total == nil {
+		res.Total = new(big.Int)
+	}
+	return res, nil
+}
+
+// allowlistVerifier accepts payments with a bearer token that is in the
+// allowlist as paid in full, eg for clients that have paid out of band
+type allowlistVerifier struct {
+	tokens map[string]struct{}
+}
+
+var _ Verifier = (*allowlistVerifier)(nil)
+
+// NewAllowlistVerifier creates a Verifier that accepts token payments (see
+// TokenPayment) where the token is one of the given tokens
+func NewAllowlistVerifier(tokens []string) Verifier {
+	allowed := make(map[string]struct{}, len(tokens))
+	for _, t := range tokens {
+		allowed[t] = struct{}{}
+	}
+	return &allowlistVerifier{tokens: allowed}
+}
+
+func (v *allowlistVerifier) Verify(_ context.Context, p Payment, price *big.Int) (Received, error) {
+	token, _ := p.Voucher.(string)
+	if _, ok := v.tokens[token]; !ok {
+		return Received{}, errors.New("bearer token is not in the allowlist")
+	}
+	if price == nil {
+		return Received{}, errors.New("a bearer token can only pay for a retrieval with a known price")
+	}
+	return Received{Amount: new(big.Int).Set(price), Total: new(big.Int).Set(price), Payer: p.Source}, nil
+}
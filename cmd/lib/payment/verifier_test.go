@@ -0,0 +1,87 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/statechannels/go-nitro/payments"
+	"github.com/statechannels/go-nitro/types"
+	"github.com/stretchr/testify/require"
+)
+
+type testReceiver struct {
+	summary payments.ReceiveVoucherSummary
+	err     error
+	panic   bool
+}
+
+func (r *testReceiver) ReceiveVoucher(payments.Voucher) (payments.ReceiveVoucherSummary, error) {
+	if r.panic {
+		panic("connection refused")
+	}
+	return r.summary, r.err
+}
+
+func TestNitroVerifier(t *testing.T) {
+	ctx := context.Background()
+	var channelID types.Destination
+	channelID[0] = 1
+	p := NitroPayment(payments.Voucher{ChannelId: channelID, Amount: big.NewInt(10)})
+	require.Equal(t, channelID.String(), p.Source)
+
+	// The amount received is the difference from the previous voucher
+	v := NewNitroVerifier(&testReceiver{summary: payments.ReceiveVoucherSummary{Total: big.NewInt(10), Delta: big.NewInt(4)}})
+	res, err := v.Verify(ctx, p, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 4, res.Amount.Int64())
+	require.EqualValues(t, 10, res.Total.Int64())
+	require.Empty(t, res.Payer)
+
+	// The payer is the address that signed the voucher
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signed := payments.Voucher{ChannelId: channelID, Amount: big.NewInt(10)}
+	require.NoError(t, signed.Sign(crypto.FromECDSA(key)))
+	res, err = v.Verify(ctx, NitroPayment(signed), nil)
+	require.NoError(t, err)
+	require.Equal(t, crypto.PubkeyToAddress(key.PublicKey).String(), res.Payer)
+
+	// A voucher that is rejected by the nitro node is invalid
+	v = NewNitroVerifier(&testReceiver{err: errors.New("bad signature")})
+	_, err = v.Verify(ctx, p, nil)
+	require.ErrorContains(t, err, "bad signature")
+	require.NotErrorIs(t, err, ErrBackend)
+
+	// If the nitro node can't be reached it's a backend error
+	v = NewNitroVerifier(&testReceiver{panic: true})
+	_, err = v.Verify(ctx, p, nil)
+	require.ErrorIs(t, err, ErrBackend)
+
+	// Only nitro vouchers are accepted
+	_, err = v.Verify(ctx, TokenPayment("secret"), nil)
+	require.Error(t, err)
+}
+
+func TestAllowlistVerifier(t *testing.T) {
+	ctx := context.Background()
+	v := NewAllowlistVerifier([]string{"secret"})
+
+	// A token in the allowlist pays the price
+	p := TokenPayment("secret")
+	require.NotContains(t, p.Source, "secret")
+	res, err := v.Verify(ctx, p, big.NewInt(20))
+	require.NoError(t, err)
+	require.EqualValues(t, 20, res.Amount.Int64())
+	require.Equal(t, p.Source, res.Payer)
+
+	// A token that is not in the allowlist is rejected
+	_, err = v.Verify(ctx, TokenPayment("wrong"), big.NewInt(20))
+	require.Error(t, err)
+
+	// The price must be known
+	_, err = v.Verify(ctx, p, nil)
+	require.Error(t, err)
+}
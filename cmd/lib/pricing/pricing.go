@@ -0,0 +1,205 @@
+package pricing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"os/exec"
+
+	"github.com/ipfs/go-cid"
+)
+
+// The formats in which retrieved content can be served
+const (
+	FormatCar    = "car"
+	FormatRaw    = "raw"
+	FormatUnixFS = "unixfs"
+	FormatTar    = "tar"
+	FormatPiece  = "piece"
+)
+
+// Input is the information about a retrieval request that is used to
+// calculate its price
+type Input struct {
+	// The resolved root CID of the content being retrieved
+	PayloadCid cid.Cid
+	// The pieces that the content is stored in (may be empty if unknown)
+	PieceCids []cid.Cid
+	// The format the content will be served in (eg car, raw, unixfs, tar)
+	Format string
+	// The size of the response in bytes
+	Size uint64
+}
+
+// Pricer calculates the payment required to serve a retrieval request
+type Pricer interface {
+	Price(ctx context.Context, in Input) (*big.Int, error)
+}
+
+// FixedPricer charges the same price for every request
+type FixedPricer struct {
+	Amount *big.Int
+}
+
+var _ Pricer = (*FixedPricer)(nil)
+
+func (p *FixedPricer) Price(context.Context, Input) (*big.Int, error) {
+	return new(big.Int).Set(p.Amount), nil
+}
+
+// Rule is a set of prices that apply to a retrieval
+type Rule struct {
+	// The price per byte of the response
+	PricePerByte *big.Int `json:"PricePerByte"`
+	// The minimum price of a request, regardless of its size
+	MinPrice *big.Int `json:"MinPrice"`
+}
+
+// Override is a Rule that applies only to requests for a particular
+// payload CID or for content stored in a particular piece
+type Override struct {
+	Rule
+	PayloadCid string `json:"PayloadCid"`
+	PieceCid   string `json:"PieceCid"`
+}
+
+// Config is the pricing configuration
+type Config struct {
+	// The rule that applies when none of the overrides match
+	Default Rule `json:"Default"`
+	// Overrides by payload CID or piece CID.
+	// A matching payload CID takes precedence over a matching piece CID.
+	Overrides []Override `json:"Overrides"`
+}
+
+// LoadConfig reads a pricing configuration from a JSON file
+func LoadConfig(path string) (*Config, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading pricing config file %s: %w", path, err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(bz, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing pricing config file %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// RulePricer calculates prices from a set of configured rules
+type RulePricer struct {
+	dflt       Rule
+	byPayload  map[cid.Cid]Rule
+	byPieceCid map[cid.Cid]Rule
+}
+
+var _ Pricer = (*RulePricer)(nil)
+
+func NewRulePricer(cfg Config) (*RulePricer, error) {
+	p := &RulePricer{
+		dflt:       cfg.Default,
+		byPayload:  make(map[cid.Cid]Rule),
+		byPieceCid: make(map[cid.Cid]Rule),
+	}
+
+	for i, o := range cfg.Overrides {
+		if (o.PayloadCid == "") == (o.PieceCid == "") {
+			return nil, fmt.Errorf("pricing override %d must have exactly one of PayloadCid or PieceCid", i)
+		}
+		if o.PayloadCid != "" {
+			c, err := cid.Parse(o.PayloadCid)
+			if err != nil {
+				return nil, fmt.Errorf("parsing pricing override %d payload cid '%s': %w", i, o.PayloadCid, err)
+			}
+			p.byPayload[c] = o.Rule
+			continue
+		}
+		c, err := cid.Parse(o.PieceCid)
+		if err != nil {
+			return nil, fmt.Errorf("parsing pricing override %d piece cid '%s': %w", i, o.PieceCid, err)
+		}
+		p.byPieceCid[c] = o.Rule
+	}
+
+	return p, nil
+}
+
+// Price is the greater of the minimum price and the price per byte
+// multiplied by the size of the response
+func (p *RulePricer) Price(_ context.Context, in Input) (*big.Int, error) {
+	rule := p.rule(in)
+
+	price := big.NewInt(0)
+	if rule.PricePerByte != nil {
+		price.Mul(rule.PricePerByte, new(big.Int).SetUint64(in.Size))
+	}
+	if rule.MinPrice != nil && price.Cmp(rule.MinPrice) < 0 {
+		price.Set(rule.MinPrice)
+	}
+	return price, nil
+}
+
+func (p *RulePricer) rule(in Input) Rule {
+	if r, ok := p.byPayload[in.PayloadCid]; ok {
+		return r
+	}
+	for _, pieceCid := range in.PieceCids {
+		if r, ok := p.byPieceCid[pieceCid]; ok {
+			return r
+		}
+	}
+	return p.dflt
+}
+
+// ExternalPricer calculates prices by running an external command.
+// The pricing Input is written as JSON to the command's stdin, and the
+// command is expected to write the price to stdout as JSON:
+// { "Price": 1234 }
+type ExternalPricer struct {
+	cmd string
+}
+
+var _ Pricer = (*ExternalPricer)(nil)
+
+func NewExternalPricer(cmd string) *ExternalPricer {
+	return &ExternalPricer{cmd: cmd}
+}
+
+type externalPrice struct {
+	Price *big.Int `json:"Price"`
+}
+
+func (p *ExternalPricer) Price(ctx context.Context, in Input) (*big.Int, error) {
+	j, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	var errb bytes.Buffer
+
+	c := exec.CommandContext(ctx, "sh", "-c", p.cmd)
+	c.Stdin = bytes.NewReader(j)
+	c.Stdout = &out
+	c.Stderr = &errb
+
+	switch err := c.Run().(type) {
+	case nil:
+		bz := out.Bytes()
+		var resp externalPrice
+		if err := json.Unmarshal(bz, &resp); err != nil {
+			return nil, fmt.Errorf("failed to parse pricing output %s, err=%w", string(bz), err)
+		}
+		if resp.Price == nil || resp.Price.Sign() < 0 {
+			return nil, fmt.Errorf("pricing output %s does not contain a valid price", string(bz))
+		}
+		return resp.Price, nil
+	case *exec.ExitError:
+		return nil, fmt.Errorf("pricing func exited with error: %s", errb.String())
+	default:
+		return nil, fmt.Errorf("pricing func cmd run error: %w", err)
+	}
+}
@@ -0,0 +1,110 @@
+package pricing_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/boost/cmd/lib/pricing"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRulePricer(t *testing.T) {
+	payloadCid, err := cid.Parse("QmWATWQ7fVPP2EFGu71UkfnqhYXDYH566qy47CnJDgvs8u")
+	require.NoError(t, err)
+	otherPayloadCid, err := cid.Parse("bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi")
+	require.NoError(t, err)
+	pieceCid, err := cid.Parse("baga6ea4seaqjtovkwk4myyzj56eztkh5pzsk5upksan6f5outesy62bsvl4dsha")
+	require.NoError(t, err)
+
+	cfgJson := `{
+		"Default": { "PricePerByte": 2, "MinPrice": 100 },
+		"Overrides": [
+			{ "PayloadCid": "QmWATWQ7fVPP2EFGu71UkfnqhYXDYH566qy47CnJDgvs8u", "PricePerByte": 0, "MinPrice": 7 },
+			{ "PieceCid": "baga6ea4seaqjtovkwk4myyzj56eztkh5pzsk5upksan6f5outesy62bsvl4dsha", "PricePerByte": 3 }
+		]
+	}`
+	cfgPath := filepath.Join(t.TempDir(), "pricing.json")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(cfgJson), 0644))
+
+	cfg, err := pricing.LoadConfig(cfgPath)
+	require.NoError(t, err)
+	p, err := pricing.NewRulePricer(*cfg)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		input    pricing.Input
+		expected int64
+	}{{
+		name:     "default rule below min price",
+		input:    pricing.Input{PayloadCid: otherPayloadCid, Format: pricing.FormatCar, Size: 10},
+		expected: 100,
+	}, {
+		name:     "default rule above min price",
+		input:    pricing.Input{PayloadCid: otherPayloadCid, Format: pricing.FormatCar, Size: 1000},
+		expected: 2000,
+	}, {
+		name:     "payload cid override",
+		input:    pricing.Input{PayloadCid: payloadCid, PieceCids: []cid.Cid{pieceCid}, Format: pricing.FormatRaw, Size: 1000},
+		expected: 7,
+	}, {
+		name:     "piece cid override",
+		input:    pricing.Input{PayloadCid: otherPayloadCid, PieceCids: []cid.Cid{pieceCid}, Format: pricing.FormatRaw, Size: 1000},
+		expected: 3000,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			price, err := p.Price(context.Background(), tc.input)
+			require.NoError(t, err)
+			require.Equal(t, big.NewInt(tc.expected), price)
+		})
+	}
+}
+
+func TestRulePricerInvalidOverride(t *testing.T) {
+	_, err := pricing.NewRulePricer(pricing.Config{
+		Overrides: []pricing.Override{{PayloadCid: "", PieceCid: ""}},
+	})
+	require.Error(t, err)
+
+	_, err = pricing.NewRulePricer(pricing.Config{
+		Overrides: []pricing.Override{{PayloadCid: "not a cid"}},
+	})
+	require.Error(t, err)
+}
+
+func TestExternalPricer(t *testing.T) {
+	ctx := context.Background()
+	payloadCid, err := cid.Parse("QmWATWQ7fVPP2EFGu71UkfnqhYXDYH566qy47CnJDgvs8u")
+	require.NoError(t, err)
+	in := pricing.Input{PayloadCid: payloadCid, Format: pricing.FormatCar, Size: 10}
+
+	// The external command receives the pricing input on stdin
+	inputPath := filepath.Join(t.TempDir(), "input.json")
+	p := pricing.NewExternalPricer(`cat > ` + inputPath + ` && echo '{"Price": 42}'`)
+	price, err := p.Price(ctx, in)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(42), price)
+
+	bz, err := os.ReadFile(inputPath)
+	require.NoError(t, err)
+	var received pricing.Input
+	require.NoError(t, json.Unmarshal(bz, &received))
+	require.Equal(t, in, received)
+
+	// Errors from the command are returned
+	p = pricing.NewExternalPricer(`echo "no price for you" >&2 && exit 1`)
+	_, err = p.Price(ctx, in)
+	require.ErrorContains(t, err, "no price for you")
+
+	// Invalid output is rejected
+	p = pricing.NewExternalPricer(`echo '{}'`)
+	_, err = p.Price(ctx, in)
+	require.Error(t, err)
+}
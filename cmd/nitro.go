@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/filecoin-project/boostd-data/shared/cliutil"
+	"github.com/statechannels/go-nitro/channel/state/outcome"
+	"github.com/statechannels/go-nitro/node/query"
+	"github.com/statechannels/go-nitro/protocols"
+	nrpc "github.com/statechannels/go-nitro/rpc"
+	"github.com/statechannels/go-nitro/types"
+	"github.com/urfave/cli/v2"
+)
+
+// FlagNitroEndpoint is the flag for the endpoint of the nitro node's RPC
+// server
+func FlagNitroEndpoint(defaultEndpoint string) *cli.StringFlag {
+	return &cli.StringFlag{
+		Name:    "nitro-endpoint",
+		Usage:   "the endpoint of the nitro node's RPC server",
+		Value:   defaultEndpoint,
+		EnvVars: []string{"NITRO_ENDPOINT"},
+	}
+}
+
+// NitroClient connects to the RPC server of the nitro node at the endpoint
+// in the nitro-endpoint flag
+func NitroClient(cctx *cli.Context) (*nrpc.RpcClient, error) {
+	endpoint := cctx.String("nitro-endpoint")
+	client, err := nrpc.NewHttpRpcClient(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to nitro rpc server at %s: %w", endpoint, err)
+	}
+	return client, nil
+}
+
+// ParseNitroChannelID parses a hex encoded nitro channel id
+func ParseNitroChannelID(s string) (types.Destination, error) {
+	hex := strings.TrimPrefix(s, "0x")
+	if len(hex) != 2*len(types.Destination{}) {
+		return types.Destination{}, fmt.Errorf("invalid nitro channel id '%s': expected %d hex characters", s, 2*len(types.Destination{}))
+	}
+	return types.Destination(common.HexToHash(hex)), nil
+}
+
+// ParseNitroAmount parses a token amount for a nitro channel
+func ParseNitroAmount(s string) (*big.Int, error) {
+	amt, ok := new(big.Int).SetString(s, 10)
+	if !ok || amt.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount '%s'", s)
+	}
+	return amt, nil
+}
+
+// NitroOutcome creates the initial outcome of a two party nitro channel
+func NitroOutcome(me common.Address, myAmount *big.Int, them common.Address, theirAmount *big.Int) outcome.Exit {
+	return outcome.Exit{outcome.SingleAssetExit{
+		Asset: common.Address{},
+		Allocations: outcome.Allocations{
+			outcome.Allocation{
+				Destination: types.AddressToDestination(me),
+				Amount:      myAmount,
+			},
+			outcome.Allocation{
+				Destination: types.AddressToDestination(them),
+				Amount:      theirAmount,
+			},
+		},
+	}}
+}
+
+// ListNitroChannels returns all ledger channels, and the payment channels
+// funded by the ledger channels that pay the nitro node (inbound) or that
+// the nitro node pays from (outbound)
+func ListNitroChannels(client *nrpc.RpcClient, inbound bool, includeClosed bool) ([]query.LedgerChannelInfo, []query.PaymentChannelInfo, error) {
+	addr, err := client.Address()
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting nitro node address: %w", err)
+	}
+
+	ledgers, err := client.GetAllLedgerChannels()
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting ledger channels: %w", err)
+	}
+
+	var paychs []query.PaymentChannelInfo
+	for _, ledger := range ledgers {
+		chs, err := client.GetPaymentChannelsByLedger(ledger.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("getting payment channels for ledger channel %s: %w", ledger.ID, err)
+		}
+		for _, ch := range chs {
+			if inbound && ch.Balance.Payee != addr || !inbound && ch.Balance.Payer != addr {
+				continue
+			}
+			if !includeClosed && ch.Status == query.Complete {
+				continue
+			}
+			paychs = append(paychs, ch)
+		}
+	}
+	return ledgers, paychs, nil
+}
+
+// PrintNitroPaymentChannels prints a table of nitro payment channels
+func PrintNitroPaymentChannels(channels []query.PaymentChannelInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\tStatus\tPayer\tPayee\tPaid\tRemaining\n")
+	for _, ch := range channels {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			ch.ID, ch.Status, ch.Balance.Payer, ch.Balance.Payee,
+			ch.Balance.PaidSoFar.ToInt(), ch.Balance.RemainingFunds.ToInt())
+	}
+	w.Flush() //nolint:errcheck
+}
+
+// PrintNitroLedgerChannels prints a table of nitro ledger channels
+func PrintNitroLedgerChannels(channels []query.LedgerChannelInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\tStatus\tCounterparty\tMy Balance\tTheir Balance\n")
+	for _, ch := range channels {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			ch.ID, ch.Status, ch.Balance.Them,
+			ch.Balance.MyBalance.ToInt(), ch.Balance.TheirBalance.ToInt())
+	}
+	w.Flush() //nolint:errcheck
+}
+
+// WaitForNitroObjective waits for a nitro objective (eg closing a channel)
+// to complete if the wait flag is set
+func WaitForNitroObjective(cctx *cli.Context, client *nrpc.RpcClient, objID protocols.ObjectiveId, desc string) error {
+	fmt.Printf("Closing %s (objective %s)\n", desc, objID)
+	if !cctx.Bool("wait") {
+		return nil
+	}
+
+	ctx := cliutil.ReqContext(cctx)
+	select {
+	case <-client.ObjectiveCompleteChan(objID):
+		fmt.Printf("Closed %s\n", desc)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,336 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+)
+
+// ClientDealsDBName is the name of the database file in the boost client
+// repo that tracks the deals the client has proposed
+const ClientDealsDBName = "boost-client.db"
+
+//go:embed create_client_db.sql
+var createClientDBSQL string
+
+// CreateClientTables creates the tables in the boost client database
+func CreateClientTables(ctx context.Context, clientDB *sql.DB) error {
+	if _, err := clientDB.ExecContext(ctx, createClientDBSQL); err != nil {
+		return fmt.Errorf("failed to create tables in client DB: %w", err)
+	}
+	return nil
+}
+
+// ClientDeal is a deal that the boost client proposed to a storage provider
+type ClientDeal struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	ProviderAddress address.Address
+	ClientAddress   address.Address
+	PieceCID        cid.Cid
+	PieceSize       abi.PaddedPieceSize
+	PayloadCID      cid.Cid
+	IsOffline       bool
+	Verified        bool
+	StartEpoch      abi.ChainEpoch
+	EndEpoch        abi.ChainEpoch
+	// The deal proposal that was sent to the provider
+	Params types.DealParams
+	// The status of the deal: "Rejected", or the provider's checkpoint for
+	// the deal (eg "Accepted", "Published", "Complete")
+	Status string
+	// The reason the deal was rejected, or the deal error reported by the
+	// provider
+	Message       string
+	SealingStatus string
+	// Whether the deal data has been sealed into a sector
+	Sealed      bool
+	ChainDealID abi.DealID
+	PublishCID  *cid.Cid
+	// The number of times the proposal has been sent, including proposals
+	// that were sent to other providers
+	Attempt int
+	// The deal that this deal re-proposes after it was rejected
+	PreviousDealUUID *uuid.UUID
+	// The replication that the deal stores a copy of the data for, if any
+	ReplicationID *uuid.UUID
+}
+
+const (
+	// The provider rejected the deal proposal (or it could not be sent)
+	ClientDealStatusRejected = "Rejected"
+	// The provider has finished processing the deal: either the deal failed,
+	// or the deal was handed off to the sealer
+	ClientDealStatusComplete = "Complete"
+)
+
+// ClientDealStatus is an entry in the status history of a client deal
+type ClientDealStatus struct {
+	CreatedAt time.Time
+	Status    string
+	Message   string
+}
+
+const clientDealFields = "ID, CreatedAt, UpdatedAt, ProviderAddress, ClientAddress, PieceCID, PieceSize, PayloadCID, " +
+	"IsOffline, Verified, StartEpoch, EndEpoch, Params, Status, Message, SealingStatus, Sealed, ChainDealID, PublishCID, " +
+	"Attempt, PreviousDealUUID, ReplicationID"
+
+type ClientDealsDB struct {
+	db *sql.DB
+}
+
+func NewClientDealsDB(db *sql.DB) *ClientDealsDB {
+	return &ClientDealsDB{db: db}
+}
+
+// Insert adds the deal to the database, and records its status in the
+// deal's status history
+func (d *ClientDealsDB) Insert(ctx context.Context, deal *ClientDeal) error {
+	if deal.CreatedAt.IsZero() {
+		deal.CreatedAt = time.Now()
+	}
+	deal.UpdatedAt = deal.CreatedAt
+
+	var params bytes.Buffer
+	if err := deal.Params.MarshalCBOR(&params); err != nil {
+		return fmt.Errorf("marshalling deal params: %w", err)
+	}
+
+	var publishCid string
+	if deal.PublishCID != nil {
+		publishCid = deal.PublishCID.String()
+	}
+	var prev string
+	if deal.PreviousDealUUID != nil {
+		prev = deal.PreviousDealUUID.String()
+	}
+	var replicationID string
+	if deal.ReplicationID != nil {
+		replicationID = deal.ReplicationID.String()
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	qry := "INSERT INTO ClientDeals (" + clientDealFields + ") " +
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err = tx.ExecContext(ctx, qry,
+		deal.ID.String(), deal.CreatedAt, deal.UpdatedAt, deal.ProviderAddress.String(), deal.ClientAddress.String(),
+		deal.PieceCID.String(), deal.PieceSize, deal.PayloadCID.String(), deal.IsOffline, deal.Verified,
+		deal.StartEpoch, deal.EndEpoch, params.Bytes(), deal.Status, deal.Message, deal.SealingStatus, deal.Sealed,
+		deal.ChainDealID, publishCid, deal.Attempt, prev, replicationID)
+	if err != nil {
+		return fmt.Errorf("inserting client deal: %w", err)
+	}
+
+	if err := insertClientDealStatus(ctx, tx, deal); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Update updates the status of the deal. If the status or message changed,
+// the new status is added to the deal's status history.
+func (d *ClientDealsDB) Update(ctx context.Context, deal *ClientDeal) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var status, message string
+	row := tx.QueryRowContext(ctx, "SELECT Status, Message FROM ClientDeals WHERE ID = ?", deal.ID.String())
+	if err := row.Scan(&status, &message); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("getting client deal status: %w", err)
+	}
+
+	var publishCid string
+	if deal.PublishCID != nil {
+		publishCid = deal.PublishCID.String()
+	}
+
+	deal.UpdatedAt = time.Now()
+	qry := "UPDATE ClientDeals SET UpdatedAt = ?, Status = ?, Message = ?, SealingStatus = ?, Sealed = ?, ChainDealID = ?, PublishCID = ? WHERE ID = ?"
+	_, err = tx.ExecContext(ctx, qry, deal.UpdatedAt, deal.Status, deal.Message, deal.SealingStatus, deal.Sealed, deal.ChainDealID, publishCid, deal.ID.String())
+	if err != nil {
+		return fmt.Errorf("updating client deal: %w", err)
+	}
+
+	if status != deal.Status || message != deal.Message {
+		if err := insertClientDealStatus(ctx, tx, deal); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func insertClientDealStatus(ctx context.Context, tx *sql.Tx, deal *ClientDeal) error {
+	qry := "INSERT INTO ClientDealStatusHistory (DealUUID, CreatedAt, Status, Message) VALUES (?, ?, ?, ?)"
+	_, err := tx.ExecContext(ctx, qry, deal.ID.String(), deal.UpdatedAt, deal.Status, deal.Message)
+	if err != nil {
+		return fmt.Errorf("inserting client deal status: %w", err)
+	}
+	return nil
+}
+
+// ByID returns the deal with the given uuid, or ErrNotFound
+func (d *ClientDealsDB) ByID(ctx context.Context, id uuid.UUID) (*ClientDeal, error) {
+	qry := "SELECT " + clientDealFields + " FROM ClientDeals WHERE ID = ?"
+	deal, err := scanClientDeal(d.db.QueryRowContext(ctx, qry, id.String()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return deal, nil
+}
+
+// List returns the deals, newest first. If statuses is not empty, only
+// deals with one of the statuses are returned.
+func (d *ClientDealsDB) List(ctx context.Context, statuses ...string) ([]*ClientDeal, error) {
+	qry := "SELECT " + clientDealFields + " FROM ClientDeals"
+	args := make([]interface{}, 0, len(statuses))
+	if len(statuses) > 0 {
+		qry += " WHERE Status IN (?" + strings.Repeat(", ?", len(statuses)-1) + ")"
+		for _, s := range statuses {
+			args = append(args, s)
+		}
+	}
+	qry += " ORDER BY CreatedAt DESC"
+	return d.list(ctx, qry, args...)
+}
+
+// ListActive returns the deals that are still in progress, oldest first.
+// A deal is no longer in progress once it has been rejected, or it is
+// Complete and has either failed or been sealed.
+func (d *ClientDealsDB) ListActive(ctx context.Context) ([]*ClientDeal, error) {
+	qry := "SELECT " + clientDealFields + " FROM ClientDeals " +
+		"WHERE Status != ? AND NOT (Status = ? AND (Message != '' OR Sealed)) ORDER BY CreatedAt"
+	return d.list(ctx, qry, ClientDealStatusRejected, ClientDealStatusComplete)
+}
+
+// ListByReplication returns the deals for the replication, oldest first
+func (d *ClientDealsDB) ListByReplication(ctx context.Context, replicationID uuid.UUID) ([]*ClientDeal, error) {
+	qry := "SELECT " + clientDealFields + " FROM ClientDeals WHERE ReplicationID = ? ORDER BY CreatedAt"
+	return d.list(ctx, qry, replicationID.String())
+}
+
+func (d *ClientDealsDB) list(ctx context.Context, qry string, args ...interface{}) ([]*ClientDeal, error) {
+	rows, err := d.db.QueryContext(ctx, qry, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deals := make([]*ClientDeal, 0, 16)
+	for rows.Next() {
+		deal, err := scanClientDeal(rows)
+		if err != nil {
+			return nil, err
+		}
+		deals = append(deals, deal)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deals, nil
+}
+
+// History returns the status history of the deal, oldest first
+func (d *ClientDealsDB) History(ctx context.Context, id uuid.UUID) ([]ClientDealStatus, error) {
+	qry := "SELECT CreatedAt, Status, Message FROM ClientDealStatusHistory WHERE DealUUID = ? ORDER BY CreatedAt, rowid"
+	rows, err := d.db.QueryContext(ctx, qry, id.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]ClientDealStatus, 0, 8)
+	for rows.Next() {
+		var s ClientDealStatus
+		if err := rows.Scan(&s.CreatedAt, &s.Status, &s.Message); err != nil {
+			return nil, fmt.Errorf("getting client deal status: %w", err)
+		}
+		history = append(history, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func scanClientDeal(row Scannable) (*ClientDeal, error) {
+	var deal ClientDeal
+	var id, provider, client, pieceCid, payloadCid, publishCid, prev, replicationID string
+	var params []byte
+	err := row.Scan(&id, &deal.CreatedAt, &deal.UpdatedAt, &provider, &client, &pieceCid, &deal.PieceSize, &payloadCid,
+		&deal.IsOffline, &deal.Verified, &deal.StartEpoch, &deal.EndEpoch, &params, &deal.Status, &deal.Message,
+		&deal.SealingStatus, &deal.Sealed, &deal.ChainDealID, &publishCid, &deal.Attempt, &prev, &replicationID)
+	if err != nil {
+		return nil, fmt.Errorf("scanning client deal row: %w", err)
+	}
+
+	if deal.ID, err = uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("parsing client deal uuid '%s': %w", id, err)
+	}
+	if deal.ProviderAddress, err = address.NewFromString(provider); err != nil {
+		return nil, fmt.Errorf("parsing provider address '%s': %w", provider, err)
+	}
+	if deal.ClientAddress, err = address.NewFromString(client); err != nil {
+		return nil, fmt.Errorf("parsing client address '%s': %w", client, err)
+	}
+	if deal.PieceCID, err = cid.Parse(pieceCid); err != nil {
+		return nil, fmt.Errorf("parsing piece cid '%s': %w", pieceCid, err)
+	}
+	if deal.PayloadCID, err = cid.Parse(payloadCid); err != nil {
+		return nil, fmt.Errorf("parsing payload cid '%s': %w", payloadCid, err)
+	}
+	if publishCid != "" {
+		c, err := cid.Parse(publishCid)
+		if err != nil {
+			return nil, fmt.Errorf("parsing publish cid '%s': %w", publishCid, err)
+		}
+		deal.PublishCID = &c
+	}
+	if prev != "" {
+		u, err := uuid.Parse(prev)
+		if err != nil {
+			return nil, fmt.Errorf("parsing previous deal uuid '%s': %w", prev, err)
+		}
+		deal.PreviousDealUUID = &u
+	}
+	if replicationID != "" {
+		u, err := uuid.Parse(replicationID)
+		if err != nil {
+			return nil, fmt.Errorf("parsing replication id '%s': %w", replicationID, err)
+		}
+		deal.ReplicationID = &u
+	}
+	if err := deal.Params.UnmarshalCBOR(bytes.NewReader(params)); err != nil {
+		return nil, fmt.Errorf("unmarshalling deal params: %w", err)
+	}
+	return &deal, nil
+}
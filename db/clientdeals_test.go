@@ -0,0 +1,230 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientDealsDB(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateClientTables(ctx, sqldb))
+
+	db := NewClientDealsDB(sqldb)
+	deals, err := GenerateNDeals(2)
+	req.NoError(err)
+
+	clientDeals := make([]*ClientDeal, 0, len(deals))
+	for _, pd := range deals {
+		prop := pd.ClientDealProposal.Proposal
+		cd := &ClientDeal{
+			ID:              pd.DealUuid,
+			ProviderAddress: prop.Provider,
+			ClientAddress:   prop.Client,
+			PieceCID:        prop.PieceCID,
+			PieceSize:       prop.PieceSize,
+			PayloadCID:      pd.DealDataRoot,
+			IsOffline:       pd.IsOffline,
+			StartEpoch:      prop.StartEpoch,
+			EndEpoch:        prop.EndEpoch,
+			Params: types.DealParams{
+				DealUUID:           pd.DealUuid,
+				ClientDealProposal: pd.ClientDealProposal,
+				DealDataRoot:       pd.DealDataRoot,
+				IsOffline:          pd.IsOffline,
+				Transfer:           pd.Transfer,
+			},
+			Status:  dealcheckpoints.Accepted.String(),
+			Attempt: 1,
+		}
+		req.NoError(db.Insert(ctx, cd))
+		clientDeals = append(clientDeals, cd)
+	}
+
+	// The second deal re-proposes the first deal, which was rejected
+	rejected := clientDeals[0]
+	rejected.Status = ClientDealStatusRejected
+	rejected.Message = "no space"
+	req.NoError(db.Update(ctx, rejected))
+
+	stored, err := db.ByID(ctx, rejected.ID)
+	req.NoError(err)
+	req.Equal(ClientDealStatusRejected, stored.Status)
+	req.Equal("no space", stored.Message)
+	req.Equal(rejected.PieceCID, stored.PieceCID)
+	req.Equal(rejected.ProviderAddress, stored.ProviderAddress)
+	req.Equal(rejected.Params.ClientDealProposal, stored.Params.ClientDealProposal)
+	req.Equal(rejected.Params.Transfer, stored.Params.Transfer)
+	req.Nil(stored.PublishCID)
+
+	// Updating a deal without changing its status does not add to the history
+	active := clientDeals[1]
+	publishCid := testutil.GenerateCid()
+	active.Status = dealcheckpoints.Published.String()
+	active.ChainDealID = 10
+	active.PublishCID = &publishCid
+	req.NoError(db.Update(ctx, active))
+	active.SealingStatus = "Sealing"
+	req.NoError(db.Update(ctx, active))
+
+	stored, err = db.ByID(ctx, active.ID)
+	req.NoError(err)
+	req.EqualValues(10, stored.ChainDealID)
+	req.Equal(publishCid, *stored.PublishCID)
+	req.Equal("Sealing", stored.SealingStatus)
+
+	history, err := db.History(ctx, active.ID)
+	req.NoError(err)
+	req.Len(history, 2)
+	req.Equal(dealcheckpoints.Accepted.String(), history[0].Status)
+	req.Equal(dealcheckpoints.Published.String(), history[1].Status)
+
+	list, err := db.List(ctx)
+	req.NoError(err)
+	req.Len(list, 2)
+
+	list, err = db.List(ctx, ClientDealStatusRejected)
+	req.NoError(err)
+	req.Len(list, 1)
+	req.Equal(rejected.ID, list[0].ID)
+
+	list, err = db.ListActive(ctx)
+	req.NoError(err)
+	req.Len(list, 1)
+	req.Equal(active.ID, list[0].ID)
+
+	// A complete deal is active until it has been sealed
+	active.Status = ClientDealStatusComplete
+	req.NoError(db.Update(ctx, active))
+	list, err = db.ListActive(ctx)
+	req.NoError(err)
+	req.Len(list, 1)
+	active.Sealed = true
+	req.NoError(db.Update(ctx, active))
+	list, err = db.ListActive(ctx)
+	req.NoError(err)
+	req.Empty(list)
+
+	_, err = db.ByID(ctx, uuid.New())
+	req.ErrorIs(err, ErrNotFound)
+}
+
+func TestClientReplications(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateClientTables(ctx, sqldb))
+	db := NewClientDealsDB(sqldb)
+
+	client, err := address.NewIDAddress(1000)
+	req.NoError(err)
+	sp1, err := address.NewIDAddress(1001)
+	req.NoError(err)
+	sp2, err := address.NewIDAddress(1002)
+	req.NoError(err)
+
+	maxPrice := abi.NewTokenAmount(1000)
+	r := &ClientReplication{
+		ID:            uuid.New(),
+		Copies:        2,
+		Active:        true,
+		ClientAddress: client,
+		PieceCID:      testutil.GenerateCid(),
+		PieceSize:     2048,
+		PayloadCID:    testutil.GenerateCid(),
+		Verified:      true,
+		Transfer: types.Transfer{
+			Type:   "http",
+			Params: []byte(`{"URL":"http://files.org/file.car"}`),
+			Size:   1024,
+		},
+		StartEpochOffset: 5760,
+		Duration:         518400,
+		MaxPrice:         &maxPrice,
+		Providers:        []address.Address{sp1, sp2},
+		Transports:       []string{"http", "bitswap"},
+	}
+	req.NoError(db.InsertReplication(ctx, r))
+
+	stored, err := db.ReplicationByID(ctx, r.ID)
+	req.NoError(err)
+	req.Equal(r.PieceCID, stored.PieceCID)
+	req.Equal(r.Transfer, stored.Transfer)
+	req.Equal(r.Providers, stored.Providers)
+	req.Equal(r.Transports, stored.Transports)
+	req.True(maxPrice.Equals(*stored.MaxPrice))
+	req.EqualValues(518400, stored.Duration)
+
+	// A replication with no price limit
+	r2 := &ClientReplication{
+		ID:            uuid.New(),
+		Copies:        1,
+		Active:        true,
+		ClientAddress: client,
+		PieceCID:      testutil.GenerateCid(),
+		PieceSize:     2048,
+		PayloadCID:    testutil.GenerateCid(),
+		IsOffline:     true,
+		Providers:     []address.Address{sp1},
+	}
+	req.NoError(db.InsertReplication(ctx, r2))
+	stored, err = db.ReplicationByID(ctx, r2.ID)
+	req.NoError(err)
+	req.Nil(stored.MaxPrice)
+	req.Empty(stored.Transports)
+
+	req.NoError(db.SetReplicationActive(ctx, r2.ID, false))
+	all, err := db.Replications(ctx, false)
+	req.NoError(err)
+	req.Len(all, 2)
+	active, err := db.Replications(ctx, true)
+	req.NoError(err)
+	req.Len(active, 1)
+	req.Equal(r.ID, active[0].ID)
+
+	req.ErrorIs(db.SetReplicationActive(ctx, uuid.New(), false), ErrNotFound)
+	_, err = db.ReplicationByID(ctx, uuid.New())
+	req.ErrorIs(err, ErrNotFound)
+}
+
+func TestClientTransferTokens(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateClientTables(ctx, sqldb))
+	db := NewClientDealsDB(sqldb)
+
+	t1 := &ClientTransferToken{Token: "token1", DealUUID: uuid.New(), Path: "/data/1.car"}
+	req.NoError(db.InsertTransferToken(ctx, t1))
+	t2 := &ClientTransferToken{Token: "token2", DealUUID: uuid.New(), Path: "/data/2.car"}
+	req.NoError(db.InsertTransferToken(ctx, t2))
+
+	stored, err := db.TransferToken(ctx, t1.Token)
+	req.NoError(err)
+	req.Equal(t1.DealUUID, stored.DealUUID)
+	req.Equal(t1.Path, stored.Path)
+
+	tokens, err := db.TransferTokens(ctx)
+	req.NoError(err)
+	req.Len(tokens, 2)
+
+	req.NoError(db.DeleteTransferToken(ctx, t1.Token))
+	_, err = db.TransferToken(ctx, t1.Token)
+	req.ErrorIs(err, ErrNotFound)
+	tokens, err = db.TransferTokens(ctx)
+	req.NoError(err)
+	req.Len(tokens, 1)
+	req.Equal(t2.Token, tokens[0].Token)
+}
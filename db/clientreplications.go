@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+)
+
+// ClientReplication is a piece of data that the boost client stores
+// copies of with several storage providers
+type ClientReplication struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	// The number of copies of the data to store
+	Copies int
+	// Whether copies that fail or expire are replaced
+	Active        bool
+	ClientAddress address.Address
+	PieceCID      cid.Cid
+	PieceSize     abi.PaddedPieceSize
+	PayloadCID    cid.Cid
+	IsOffline     bool
+	Verified      bool
+	// How the providers get the data, for online deals
+	Transfer           types.Transfer
+	RemoveUnsealedCopy bool
+	SkipIPNIAnnounce   bool
+	// The number of epochs after the chain head at the time a deal is
+	// proposed that the deal should start
+	StartEpochOffset abi.ChainEpoch
+	// The duration of each deal in epochs
+	Duration abi.ChainEpoch
+	// The maximum storage price to pay in attoFIL per GiB per epoch, or nil
+	// to accept any price
+	MaxPrice *abi.TokenAmount
+	// The candidate providers to store copies with, in order of preference
+	Providers []address.Address
+	// The retrieval transports that providers must support (eg "http")
+	Transports []string
+}
+
+const clientReplicationFields = "ID, CreatedAt, Copies, Active, ClientAddress, PieceCID, PieceSize, PayloadCID, IsOffline, " +
+	"Verified, TransferType, TransferParams, TransferSize, RemoveUnsealedCopy, SkipIPNIAnnounce, StartEpochOffset, Duration, " +
+	"MaxPrice, Providers, Transports"
+
+// InsertReplication adds the replication to the database
+func (d *ClientDealsDB) InsertReplication(ctx context.Context, r *ClientReplication) error {
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now()
+	}
+
+	var maxPrice string
+	if r.MaxPrice != nil {
+		maxPrice = r.MaxPrice.String()
+	}
+	providers := make([]string, 0, len(r.Providers))
+	for _, p := range r.Providers {
+		providers = append(providers, p.String())
+	}
+
+	qry := "INSERT INTO ClientReplications (" + clientReplicationFields + ") " +
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err := d.db.ExecContext(ctx, qry, r.ID.String(), r.CreatedAt, r.Copies, r.Active, r.ClientAddress.String(),
+		r.PieceCID.String(), r.PieceSize, r.PayloadCID.String(), r.IsOffline, r.Verified, r.Transfer.Type, r.Transfer.Params,
+		r.Transfer.Size, r.RemoveUnsealedCopy, r.SkipIPNIAnnounce, r.StartEpochOffset, r.Duration, maxPrice,
+		strings.Join(providers, ","), strings.Join(r.Transports, ","))
+	if err != nil {
+		return fmt.Errorf("inserting client replication: %w", err)
+	}
+	return nil
+}
+
+// SetReplicationActive sets whether copies of the replication's data that
+// fail or expire are replaced. It returns ErrNotFound if there is no
+// replication with the given id.
+func (d *ClientDealsDB) SetReplicationActive(ctx context.Context, id uuid.UUID, active bool) error {
+	res, err := d.db.ExecContext(ctx, "UPDATE ClientReplications SET Active = ? WHERE ID = ?", active, id.String())
+	if err != nil {
+		return fmt.Errorf("updating client replication: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ReplicationByID returns the replication with the given id, or ErrNotFound
+func (d *ClientDealsDB) ReplicationByID(ctx context.Context, id uuid.UUID) (*ClientReplication, error) {
+	qry := "SELECT " + clientReplicationFields + " FROM ClientReplications WHERE ID = ?"
+	r, err := scanClientReplication(d.db.QueryRowContext(ctx, qry, id.String()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// Replications returns the replications, newest first. If activeOnly is
+// true, only active replications are returned.
+func (d *ClientDealsDB) Replications(ctx context.Context, activeOnly bool) ([]*ClientReplication, error) {
+	qry := "SELECT " + clientReplicationFields + " FROM ClientReplications"
+	if activeOnly {
+		qry += " WHERE Active"
+	}
+	qry += " ORDER BY CreatedAt DESC"
+	rows, err := d.db.QueryContext(ctx, qry)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	replications := make([]*ClientReplication, 0, 16)
+	for rows.Next() {
+		r, err := scanClientReplication(rows)
+		if err != nil {
+			return nil, err
+		}
+		replications = append(replications, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return replications, nil
+}
+
+func scanClientReplication(row Scannable) (*ClientReplication, error) {
+	var r ClientReplication
+	var id, client, pieceCid, payloadCid, maxPrice, providers, transports string
+	err := row.Scan(&id, &r.CreatedAt, &r.Copies, &r.Active, &client, &pieceCid, &r.PieceSize, &payloadCid, &r.IsOffline,
+		&r.Verified, &r.Transfer.Type, &r.Transfer.Params, &r.Transfer.Size, &r.RemoveUnsealedCopy, &r.SkipIPNIAnnounce,
+		&r.StartEpochOffset, &r.Duration, &maxPrice, &providers, &transports)
+	if err != nil {
+		return nil, fmt.Errorf("scanning client replication row: %w", err)
+	}
+
+	if r.ID, err = uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("parsing replication id '%s': %w", id, err)
+	}
+	if r.ClientAddress, err = address.NewFromString(client); err != nil {
+		return nil, fmt.Errorf("parsing client address '%s': %w", client, err)
+	}
+	if r.PieceCID, err = cid.Parse(pieceCid); err != nil {
+		return nil, fmt.Errorf("parsing piece cid '%s': %w", pieceCid, err)
+	}
+	if r.PayloadCID, err = cid.Parse(payloadCid); err != nil {
+		return nil, fmt.Errorf("parsing payload cid '%s': %w", payloadCid, err)
+	}
+	if maxPrice != "" {
+		price, err := big.FromString(maxPrice)
+		if err != nil {
+			return nil, fmt.Errorf("parsing max price '%s': %w", maxPrice, err)
+		}
+		r.MaxPrice = &price
+	}
+	if providers != "" {
+		for _, p := range strings.Split(providers, ",") {
+			addr, err := address.NewFromString(p)
+			if err != nil {
+				return nil, fmt.Errorf("parsing provider address '%s': %w", p, err)
+			}
+			r.Providers = append(r.Providers, addr)
+		}
+	}
+	if transports != "" {
+		r.Transports = strings.Split(transports, ",")
+	}
+	return &r, nil
+}
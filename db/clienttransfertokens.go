@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ClientTransferToken is an auth token that a storage provider uses to
+// download the data for a deal from the CAR file served by `boost serve`
+type ClientTransferToken struct {
+	Token     string
+	CreatedAt time.Time
+	// The deal that the token was created for
+	DealUUID uuid.UUID
+	// The path of the CAR file that is served for the token
+	Path string
+}
+
+const clientTransferTokenFields = "Token, CreatedAt, DealUUID, Path"
+
+// InsertTransferToken adds the transfer token to the database
+func (d *ClientDealsDB) InsertTransferToken(ctx context.Context, t *ClientTransferToken) error {
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now()
+	}
+
+	qry := "INSERT INTO ClientTransferTokens (" + clientTransferTokenFields + ") VALUES (?, ?, ?, ?)"
+	_, err := d.db.ExecContext(ctx, qry, t.Token, t.CreatedAt, t.DealUUID.String(), t.Path)
+	if err != nil {
+		return fmt.Errorf("inserting client transfer token: %w", err)
+	}
+	return nil
+}
+
+// TransferToken returns the transfer token, or ErrNotFound
+func (d *ClientDealsDB) TransferToken(ctx context.Context, token string) (*ClientTransferToken, error) {
+	qry := "SELECT " + clientTransferTokenFields + " FROM ClientTransferTokens WHERE Token = ?"
+	t, err := scanClientTransferToken(d.db.QueryRowContext(ctx, qry, token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// TransferTokens returns all transfer tokens, oldest first
+func (d *ClientDealsDB) TransferTokens(ctx context.Context) ([]*ClientTransferToken, error) {
+	qry := "SELECT " + clientTransferTokenFields + " FROM ClientTransferTokens ORDER BY CreatedAt"
+	rows, err := d.db.QueryContext(ctx, qry)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]*ClientTransferToken, 0, 16)
+	for rows.Next() {
+		t, err := scanClientTransferToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// DeleteTransferToken removes the transfer token from the database
+func (d *ClientDealsDB) DeleteTransferToken(ctx context.Context, token string) error {
+	_, err := d.db.ExecContext(ctx, "DELETE FROM ClientTransferTokens WHERE Token = ?", token)
+	if err != nil {
+		return fmt.Errorf("deleting client transfer token: %w", err)
+	}
+	return nil
+}
+
+func scanClientTransferToken(row Scannable) (*ClientTransferToken, error) {
+	var t ClientTransferToken
+	var dealUuid string
+	err := row.Scan(&t.Token, &t.CreatedAt, &dealUuid, &t.Path)
+	if err != nil {
+		return nil, fmt.Errorf("scanning client transfer token row: %w", err)
+	}
+
+	if t.DealUUID, err = uuid.Parse(dealUuid); err != nil {
+		return nil, fmt.Errorf("parsing deal uuid '%s': %w", dealUuid, err)
+	}
+	return &t, nil
+}
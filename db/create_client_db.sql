@@ -0,0 +1,67 @@
+CREATE TABLE IF NOT EXISTS ClientDeals (
+    ID TEXT PRIMARY KEY,
+    CreatedAt DateTime,
+    UpdatedAt DateTime,
+    ProviderAddress TEXT,
+    ClientAddress TEXT,
+    PieceCID TEXT,
+    PieceSize INT,
+    PayloadCID TEXT,
+    IsOffline BOOLEAN,
+    Verified BOOLEAN,
+    StartEpoch INT,
+    EndEpoch INT,
+    Params BLOB,
+    Status TEXT,
+    Message TEXT,
+    SealingStatus TEXT,
+    Sealed BOOLEAN,
+    ChainDealID INT,
+    PublishCID TEXT,
+    Attempt INT,
+    PreviousDealUUID TEXT,
+    ReplicationID TEXT
+);
+
+CREATE INDEX IF NOT EXISTS index_client_deals_status on ClientDeals(Status);
+
+CREATE INDEX IF NOT EXISTS index_client_deals_replication_id on ClientDeals(ReplicationID);
+
+CREATE TABLE IF NOT EXISTS ClientDealStatusHistory (
+    DealUUID TEXT,
+    CreatedAt DateTime,
+    Status TEXT,
+    Message TEXT
+);
+
+CREATE INDEX IF NOT EXISTS index_client_deal_status_history_deal_uuid on ClientDealStatusHistory(DealUUID);
+
+CREATE TABLE IF NOT EXISTS ClientReplications (
+    ID TEXT PRIMARY KEY,
+    CreatedAt DateTime,
+    Copies INT,
+    Active BOOLEAN,
+    ClientAddress TEXT,
+    PieceCID TEXT,
+    PieceSize INT,
+    PayloadCID TEXT,
+    IsOffline BOOLEAN,
+    Verified BOOLEAN,
+    TransferType TEXT,
+    TransferParams BLOB,
+    TransferSize INT,
+    RemoveUnsealedCopy BOOLEAN,
+    SkipIPNIAnnounce BOOLEAN,
+    StartEpochOffset INT,
+    Duration INT,
+    MaxPrice TEXT,
+    Providers TEXT,
+    Transports TEXT
+);
+
+CREATE TABLE IF NOT EXISTS ClientTransferTokens (
+    Token TEXT PRIMARY KEY,
+    CreatedAt DateTime,
+    DealUUID TEXT,
+    Path TEXT
+);
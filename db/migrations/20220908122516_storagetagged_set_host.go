@@ -2,8 +2,13 @@ package migrations
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
-	"github.com/filecoin-project/boost/storagemarket/types"
+	"net/url"
+
+	"github.com/filecoin-project/boost/transport/httptransport/util"
+	"github.com/filecoin-project/boost/transport/types"
+	"github.com/ipni/go-libipni/maurl"
 	"github.com/pressly/goose/v3"
 )
 
@@ -33,11 +38,7 @@ func UpSetStorageTaggedTransferHost(tx *sql.Tx) error {
 
 		dealErrPrefix := fmt.Sprintf(errPrefix+"deal %s: ", id)
 
-		xfer := types.Transfer{
-			Type:   xferType,
-			Params: params,
-		}
-		host, err := xfer.Host()
+		host, err := transferHost(xferType, params)
 		if err != nil {
 			log.Warnw(dealErrPrefix+"ignoring - couldn't parse transfer params %s: '%s': %s", xferType, params, err)
 			continue
@@ -51,6 +52,38 @@ func UpSetStorageTaggedTransferHost(tx *sql.Tx) error {
 	return rows.Err()
 }
 
+// transferHost gets the host from the transfer params. When this migration
+// was written the only transfer types were "http" and "libp2p".
+func transferHost(xferType string, params []byte) (string, error) {
+	if xferType != "http" && xferType != "libp2p" {
+		return "", fmt.Errorf("cannot parse params for unrecognized transfer type '%s'", xferType)
+	}
+
+	tInfo := &types.HttpRequest{}
+	if err := json.Unmarshal(params, tInfo); err != nil {
+		return "", fmt.Errorf("failed to de-serialize transport params bytes '%s': %w", string(params), err)
+	}
+
+	u, err := util.ParseUrl(tInfo.URL)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse url '%s': %w", tInfo.URL, err)
+	}
+
+	if u.Scheme == util.Libp2pScheme {
+		mahttp, err := maurl.ToURL(u.Multiaddr)
+		if err != nil {
+			return "", err
+		}
+		return mahttp.Host, nil
+	}
+
+	httpUrl, err := url.Parse(u.Url)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse url '%s' from '%s': %w", u.Url, tInfo.URL, err)
+	}
+	return httpUrl.Host, nil
+}
+
 func DownSetStorageTaggedTransferHost(tx *sql.Tx) error {
 	// This code is executed when the migration is rolled back.
 	// Do nothing because sqlite doesn't support removing a column.
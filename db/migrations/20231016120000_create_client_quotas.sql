@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS ClientQuotas (
+    Kind TEXT,
+    ID TEXT,
+    MaxBytesInFlight INT,
+    MaxDealsPerHour INT,
+    MaxConcurrentTransfers INT,
+    UpdatedAt DateTime,
+    PRIMARY KEY(Kind, ID)
+);
+
+CREATE INDEX IF NOT EXISTS index_deals_client_address on Deals(ClientAddress);
+CREATE INDEX IF NOT EXISTS index_deals_client_peer_id on Deals(ClientPeerID);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS index_deals_client_peer_id;
+DROP INDEX IF EXISTS index_deals_client_address;
+DROP TABLE IF EXISTS ClientQuotas;
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS WebhookDeliveries (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    URL TEXT,
+    Event TEXT,
+    DealUUID TEXT,
+    Payload BLOB,
+    Attempts INT,
+    NextAttemptAt DateTime,
+    LastError TEXT,
+    CreatedAt DateTime
+);
+
+CREATE INDEX IF NOT EXISTS index_webhook_deliveries_next_attempt_at on WebhookDeliveries(NextAttemptAt);
+
+CREATE TABLE IF NOT EXISTS WebhookDeadLetters (
+    ID INTEGER PRIMARY KEY,
+    URL TEXT,
+    Event TEXT,
+    DealUUID TEXT,
+    Payload BLOB,
+    Attempts INT,
+    LastError TEXT,
+    CreatedAt DateTime,
+    FailedAt DateTime
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS WebhookDeadLetters;
+DROP INDEX IF EXISTS index_webhook_deliveries_next_attempt_at;
+DROP TABLE IF EXISTS WebhookDeliveries;
+-- +goose StatementEnd
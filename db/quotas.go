@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+)
+
+// QuotaKind is the kind of identifier that a quota applies to
+type QuotaKind string
+
+const (
+	// QuotaKindClient is a quota on a client wallet address
+	QuotaKindClient QuotaKind = "client"
+	// QuotaKindPeer is a quota on a client libp2p peer ID
+	QuotaKindPeer QuotaKind = "peer"
+)
+
+// QuotaDefaultID is the ID of the quota that applies to every client
+// (or peer) that doesn't have its own quota
+const QuotaDefaultID = "*"
+
+func ParseQuotaKind(kind string) (QuotaKind, error) {
+	switch QuotaKind(kind) {
+	case QuotaKindClient, QuotaKindPeer:
+		return QuotaKind(kind), nil
+	default:
+		return "", fmt.Errorf("unrecognized quota kind '%s': must be '%s' or '%s'", kind, QuotaKindClient, QuotaKindPeer)
+	}
+}
+
+// Quota limits the storage deals that are accepted from a client.
+// A limit of zero means there is no limit.
+type Quota struct {
+	Kind QuotaKind
+	// The client address or peer ID, or QuotaDefaultID
+	ID string
+	// The maximum number of bytes of deal data that may be in the staging
+	// area (being downloaded or waiting to be added to a sector)
+	MaxBytesInFlight uint64
+	// The maximum number of deals that may be accepted in an hour
+	MaxDealsPerHour uint64
+	// The maximum number of data transfers that may be in progress at once
+	MaxConcurrentTransfers uint64
+	UpdatedAt              time.Time
+}
+
+// QuotaUsage is the amount of each quota used by a client
+type QuotaUsage struct {
+	BytesInFlight       uint64
+	DealsLastHour       uint64
+	ConcurrentTransfers uint64
+}
+
+type QuotasDB struct {
+	db *sql.DB
+}
+
+func NewQuotasDB(db *sql.DB) *QuotasDB {
+	return &QuotasDB{db: db}
+}
+
+// Set creates or replaces the quota
+func (q *QuotasDB) Set(ctx context.Context, quota Quota) error {
+	if quota.UpdatedAt.IsZero() {
+		quota.UpdatedAt = time.Now()
+	}
+	qry := "INSERT OR REPLACE INTO ClientQuotas (Kind, ID, MaxBytesInFlight, MaxDealsPerHour, MaxConcurrentTransfers, UpdatedAt) "
+	qry += "VALUES (?, ?, ?, ?, ?, ?)"
+	values := []interface{}{
+		string(quota.Kind),
+		quota.ID,
+		quota.MaxBytesInFlight,
+		quota.MaxDealsPerHour,
+		quota.MaxConcurrentTransfers,
+		quota.UpdatedAt,
+	}
+	_, err := q.db.ExecContext(ctx, qry, values...)
+	return err
+}
+
+// Get returns the quota for the given kind and ID, or ErrNotFound
+func (q *QuotasDB) Get(ctx context.Context, kind QuotaKind, id string) (*Quota, error) {
+	qry := "SELECT Kind, ID, MaxBytesInFlight, MaxDealsPerHour, MaxConcurrentTransfers, UpdatedAt FROM ClientQuotas WHERE Kind = ? AND ID = ?"
+	row := q.db.QueryRowContext(ctx, qry, string(kind), id)
+	quota, err := scanQuota(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("getting %s quota for %s: %w", kind, id, err)
+	}
+	return quota, nil
+}
+
+// GetEffective returns the quota for the given kind and ID, or the default
+// quota for the kind if there is no quota for the ID. Returns ErrNotFound
+// if neither exists.
+func (q *QuotasDB) GetEffective(ctx context.Context, kind QuotaKind, id string) (*Quota, error) {
+	quota, err := q.Get(ctx, kind, id)
+	if err == nil || err != ErrNotFound {
+		return quota, err
+	}
+	return q.Get(ctx, kind, QuotaDefaultID)
+}
+
+func (q *QuotasDB) List(ctx context.Context) ([]Quota, error) {
+	qry := "SELECT Kind, ID, MaxBytesInFlight, MaxDealsPerHour, MaxConcurrentTransfers, UpdatedAt FROM ClientQuotas ORDER BY Kind, ID"
+	rows, err := q.db.QueryContext(ctx, qry)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quotas := make([]Quota, 0, 16)
+	for rows.Next() {
+		quota, err := scanQuota(rows)
+		if err != nil {
+			return nil, fmt.Errorf("getting quota: %w", err)
+		}
+		quotas = append(quotas, *quota)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
+
+// Delete removes the quota for the given kind and ID, or returns ErrNotFound
+func (q *QuotasDB) Delete(ctx context.Context, kind QuotaKind, id string) error {
+	res, err := q.db.ExecContext(ctx, "DELETE FROM ClientQuotas WHERE Kind = ? AND ID = ?", string(kind), id)
+	if err != nil {
+		return err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanQuota(row Scannable) (*Quota, error) {
+	var quota Quota
+	var kind string
+	err := row.Scan(&kind, &quota.ID, &quota.MaxBytesInFlight, &quota.MaxDealsPerHour, &quota.MaxConcurrentTransfers, &quota.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	quota.Kind = QuotaKind(kind)
+	return &quota, nil
+}
+
+// Usage returns the amount of each quota used by the client with the given
+// address or peer ID
+func (q *QuotasDB) Usage(ctx context.Context, kind QuotaKind, id string) (*QuotaUsage, error) {
+	col := "ClientAddress"
+	if kind == QuotaKindPeer {
+		col = "ClientPeerID"
+	}
+
+	// Deal data is in the staging area from when the deal is accepted until
+	// the piece has been added to a sector. Failed deals are moved to the
+	// Complete checkpoint.
+	inFlight := []interface{}{
+		dealcheckpoints.Accepted.String(),
+		dealcheckpoints.Transferred.String(),
+		dealcheckpoints.Published.String(),
+		dealcheckpoints.PublishConfirmed.String(),
+	}
+	qry := "SELECT " +
+		"COALESCE(SUM(CASE WHEN NOT IsOffline AND Checkpoint IN (?, ?, ?, ?) THEN TransferSize ELSE 0 END), 0), " +
+		"COALESCE(SUM(CASE WHEN CreatedAt >= ? THEN 1 ELSE 0 END), 0), " +
+		"COALESCE(SUM(CASE WHEN NOT IsOffline AND Checkpoint = ? THEN 1 ELSE 0 END), 0) " +
+		"FROM Deals WHERE " + col + " = ?"
+	args := append(inFlight, time.Now().Add(-time.Hour), dealcheckpoints.Accepted.String(), id)
+
+	var usage QuotaUsage
+	err := q.db.QueryRowContext(ctx, qry, args...).Scan(&usage.BytesInFlight, &usage.DealsLastHour, &usage.ConcurrentTransfers)
+	if err != nil {
+		return nil, fmt.Errorf("getting %s quota usage for %s: %w", kind, id, err)
+	}
+	return &usage, nil
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotasDB(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+
+	qdb := NewQuotasDB(sqldb)
+
+	_, err := qdb.Get(ctx, QuotaKindClient, "f01000")
+	req.True(errors.Is(err, ErrNotFound))
+
+	dflt := Quota{Kind: QuotaKindClient, ID: QuotaDefaultID, MaxDealsPerHour: 10}
+	req.NoError(qdb.Set(ctx, dflt))
+	client := Quota{Kind: QuotaKindClient, ID: "f01000", MaxBytesInFlight: 1024, MaxConcurrentTransfers: 2}
+	req.NoError(qdb.Set(ctx, client))
+
+	// The client's own quota takes precedence over the default
+	q, err := qdb.GetEffective(ctx, QuotaKindClient, "f01000")
+	req.NoError(err)
+	req.Equal("f01000", q.ID)
+	req.EqualValues(1024, q.MaxBytesInFlight)
+
+	q, err = qdb.GetEffective(ctx, QuotaKindClient, "f01001")
+	req.NoError(err)
+	req.Equal(QuotaDefaultID, q.ID)
+	req.EqualValues(10, q.MaxDealsPerHour)
+
+	_, err = qdb.GetEffective(ctx, QuotaKindPeer, "peer")
+	req.True(errors.Is(err, ErrNotFound))
+
+	// Setting a quota again replaces it
+	client.MaxBytesInFlight = 2048
+	req.NoError(qdb.Set(ctx, client))
+	quotas, err := qdb.List(ctx)
+	req.NoError(err)
+	req.Len(quotas, 2)
+	req.Equal(QuotaDefaultID, quotas[0].ID)
+	req.Equal("f01000", quotas[1].ID)
+	req.EqualValues(2048, quotas[1].MaxBytesInFlight)
+
+	req.NoError(qdb.Delete(ctx, QuotaKindClient, QuotaDefaultID))
+	req.True(errors.Is(qdb.Delete(ctx, QuotaKindClient, QuotaDefaultID), ErrNotFound))
+	quotas, err = qdb.List(ctx)
+	req.NoError(err)
+	req.Len(quotas, 1)
+}
+
+func TestQuotasDBUsage(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+
+	deals, err := GenerateNDeals(4)
+	req.NoError(err)
+
+	// All the deals are from the same client
+	client := deals[0].ClientDealProposal.Proposal.Client
+	peerID := deals[0].ClientPeerID
+	for i := range deals {
+		deals[i].ClientDealProposal.Proposal.Client = client
+		deals[i].ClientPeerID = peerID
+		deals[i].IsOffline = false
+		deals[i].Transfer.Size = 100
+	}
+
+	// A deal that is transferring data
+	deals[0].Checkpoint = dealcheckpoints.Accepted
+	// A deal whose data is waiting to be added to a sector
+	deals[1].Checkpoint = dealcheckpoints.Published
+	// A deal that was created more than an hour ago and whose data has been
+	// added to a sector
+	deals[2].Checkpoint = dealcheckpoints.AddedPiece
+	deals[2].CreatedAt = time.Now().Add(-2 * time.Hour)
+	// An offline deal
+	deals[3].IsOffline = true
+	deals[3].Checkpoint = dealcheckpoints.Accepted
+
+	dealsDB := NewDealsDB(sqldb)
+	for _, deal := range deals {
+		deal := deal
+		req.NoError(dealsDB.Insert(ctx, &deal))
+	}
+
+	qdb := NewQuotasDB(sqldb)
+	expected := &QuotaUsage{BytesInFlight: 200, DealsLastHour: 3, ConcurrentTransfers: 1}
+
+	usage, err := qdb.Usage(ctx, QuotaKindClient, client.String())
+	req.NoError(err)
+	req.Equal(expected, usage)
+
+	usage, err = qdb.Usage(ctx, QuotaKindPeer, peerID.String())
+	req.NoError(err)
+	req.Equal(expected, usage)
+
+	usage, err = qdb.Usage(ctx, QuotaKindClient, "f099")
+	req.NoError(err)
+	req.Equal(&QuotaUsage{}, usage)
+}
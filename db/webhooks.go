@@ -0,0 +1,171 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// WebhookDelivery is a webhook request that is waiting to be delivered
+type WebhookDelivery struct {
+	ID int64
+	// The URL of the webhook target
+	URL string
+	// The name of the event (eg "Published" or "Failed")
+	Event    string
+	DealUUID string
+	// The JSON encoded request body
+	Payload []byte
+	// The number of delivery attempts that have been made
+	Attempts int
+	// The time at which the next delivery attempt should be made
+	NextAttemptAt time.Time
+	// The error from the last delivery attempt
+	LastError string
+	CreatedAt time.Time
+	// The time at which delivery was abandoned (dead letters only)
+	FailedAt time.Time
+}
+
+type WebhooksDB struct {
+	db *sql.DB
+}
+
+func NewWebhooksDB(db *sql.DB) *WebhooksDB {
+	return &WebhooksDB{db: db}
+}
+
+// Enqueue adds a delivery to the queue, and sets its ID
+func (w *WebhooksDB) Enqueue(ctx context.Context, d *WebhookDelivery) error {
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = time.Now()
+	}
+	if d.NextAttemptAt.IsZero() {
+		d.NextAttemptAt = d.CreatedAt
+	}
+	qry := "INSERT INTO WebhookDeliveries (URL, Event, DealUUID, Payload, Attempts, NextAttemptAt, LastError, CreatedAt) "
+	qry += "VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	res, err := w.db.ExecContext(ctx, qry, d.URL, d.Event, d.DealUUID, d.Payload, d.Attempts, d.NextAttemptAt, d.LastError, d.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("inserting webhook delivery: %w", err)
+	}
+	d.ID, err = res.LastInsertId()
+	return err
+}
+
+// Due returns up to limit deliveries that should be attempted at or before
+// the given time, oldest first
+func (w *WebhooksDB) Due(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	qry := "SELECT ID, URL, Event, DealUUID, Payload, Attempts, NextAttemptAt, LastError, CreatedAt " +
+		"FROM WebhookDeliveries WHERE NextAttemptAt <= ? ORDER BY NextAttemptAt, ID LIMIT ?"
+	rows, err := w.db.QueryContext(ctx, qry, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]WebhookDelivery, 0, limit)
+	for rows.Next() {
+		var d WebhookDelivery
+		err := rows.Scan(&d.ID, &d.URL, &d.Event, &d.DealUUID, &d.Payload, &d.Attempts, &d.NextAttemptAt, &d.LastError, &d.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("getting webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Delivered removes a successfully delivered request from the queue
+func (w *WebhooksDB) Delivered(ctx context.Context, id int64) error {
+	_, err := w.db.ExecContext(ctx, "DELETE FROM WebhookDeliveries WHERE ID = ?", id)
+	return err
+}
+
+// Retry records a failed delivery attempt, and schedules the next attempt
+func (w *WebhooksDB) Retry(ctx context.Context, id int64, attempts int, lastErr string, nextAttemptAt time.Time) error {
+	qry := "UPDATE WebhookDeliveries SET Attempts = ?, LastError = ?, NextAttemptAt = ? WHERE ID = ?"
+	_, err := w.db.ExecContext(ctx, qry, attempts, lastErr, nextAttemptAt, id)
+	return err
+}
+
+// DeadLetter moves a delivery that can't be delivered from the queue to the
+// dead letter table
+func (w *WebhooksDB) DeadLetter(ctx context.Context, id int64, attempts int, lastErr string) error {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	qry := "INSERT INTO WebhookDeadLetters (ID, URL, Event, DealUUID, Payload, Attempts, LastError, CreatedAt, FailedAt) " +
+		"SELECT ID, URL, Event, DealUUID, Payload, ?, ?, CreatedAt, ? FROM WebhookDeliveries WHERE ID = ?"
+	if _, err := tx.ExecContext(ctx, qry, attempts, lastErr, time.Now(), id); err != nil {
+		return fmt.Errorf("inserting webhook dead letter: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM WebhookDeliveries WHERE ID = ?", id); err != nil {
+		return fmt.Errorf("deleting webhook delivery: %w", err)
+	}
+	return tx.Commit()
+}
+
+// DeadLetters returns the deliveries that were abandoned, newest first
+func (w *WebhooksDB) DeadLetters(ctx context.Context, limit int) ([]WebhookDelivery, error) {
+	qry := "SELECT ID, URL, Event, DealUUID, Payload, Attempts, LastError, CreatedAt, FailedAt " +
+		"FROM WebhookDeadLetters ORDER BY FailedAt DESC, ID DESC LIMIT ?"
+	rows, err := w.db.QueryContext(ctx, qry, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]WebhookDelivery, 0, 16)
+	for rows.Next() {
+		var d WebhookDelivery
+		err := rows.Scan(&d.ID, &d.URL, &d.Event, &d.DealUUID, &d.Payload, &d.Attempts, &d.LastError, &d.CreatedAt, &d.FailedAt)
+		if err != nil {
+			return nil, fmt.Errorf("getting webhook dead letter: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Redeliver moves a dead letter back to the delivery queue, or returns
+// ErrNotFound
+func (w *WebhooksDB) Redeliver(ctx context.Context, id int64) error {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	qry := "INSERT INTO WebhookDeliveries (ID, URL, Event, DealUUID, Payload, Attempts, NextAttemptAt, LastError, CreatedAt) " +
+		"SELECT ID, URL, Event, DealUUID, Payload, 0, ?, LastError, CreatedAt FROM WebhookDeadLetters WHERE ID = ?"
+	res, err := tx.ExecContext(ctx, qry, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("inserting webhook delivery: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM WebhookDeadLetters WHERE ID = ?", id); err != nil {
+		return fmt.Errorf("deleting webhook dead letter: %w", err)
+	}
+	return tx.Commit()
+}
@@ -78,11 +78,19 @@ type TagFundsResp struct {
 
 var ErrInsufficientFunds = errors.New("insufficient funds")
 
-// TagFunds tags funds for deal collateral and for the publish storage
-// deals message, so those funds cannot be used for other deals.
-// It returns ErrInsufficientFunds if there are not enough funds available
-// in the respective wallets to cover either of these operations.
-func (m *FundManager) TagFunds(ctx context.Context, dealUuid uuid.UUID, proposal market.DealProposal) (*TagFundsResp, error) {
+// Snapshot is a point-in-time view of the provider's balances and of the
+// funds tagged for deals. It's used to tag funds for a batch of deals without
+// fetching the balances from the chain for each deal.
+// Note that the snapshot is only accurate as long as funds are not tagged or
+// untagged for other deals while it's in use.
+type Snapshot struct {
+	marketBal storagemarket.Balance
+	pubMsgBal abi.TokenAmount
+	tagged    *db.TotalTagged
+}
+
+// Snapshot gets the current balances and the total funds tagged for deals
+func (m *FundManager) Snapshot(ctx context.Context) (*Snapshot, error) {
 	marketBal, err := m.BalanceMarket(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("getting market balance: %w", err)
@@ -93,13 +101,34 @@ func (m *FundManager) TagFunds(ctx context.Context, dealUuid uuid.UUID, proposal
 		return nil, fmt.Errorf("getting publish deals message wallet balance: %w", err)
 	}
 
-	// Check that the provider has enough funds in escrow to cover the
-	// collateral requirement for the deal
 	tagged, err := m.totalTagged(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("getting total tagged: %w", err)
 	}
 
+	return &Snapshot{marketBal: marketBal, pubMsgBal: pubMsgBal, tagged: tagged}, nil
+}
+
+// TagFunds tags funds for deal collateral and for the publish storage
+// deals message, so those funds cannot be used for other deals.
+// It returns ErrInsufficientFunds if there are not enough funds available
+// in the respective wallets to cover either of these operations.
+func (m *FundManager) TagFunds(ctx context.Context, dealUuid uuid.UUID, proposal market.DealProposal) (*TagFundsResp, error) {
+	snap, err := m.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.TagFundsFromSnapshot(ctx, snap, dealUuid, proposal)
+}
+
+// TagFundsFromSnapshot is like TagFunds, but it checks the available funds
+// against the snapshot instead of fetching the current balances. The funds
+// tagged for the deal are added to the snapshot.
+func (m *FundManager) TagFundsFromSnapshot(ctx context.Context, snap *Snapshot, dealUuid uuid.UUID, proposal market.DealProposal) (*TagFundsResp, error) {
+	marketBal, pubMsgBal, tagged := snap.marketBal, snap.pubMsgBal, snap.tagged
+
+	// Check that the provider has enough funds in escrow to cover the
+	// collateral requirement for the deal
 	dealCollateralTag := abi.NewTokenAmount(0)
 	pubMsgTag := abi.NewTokenAmount(0)
 	availForDealCollat := big.Sub(marketBal.Available, tagged.Collateral)
@@ -123,18 +152,22 @@ func (m *FundManager) TagFunds(ctx context.Context, dealUuid uuid.UUID, proposal
 		}
 
 		// Provider has enough funds to make deal, so persist tagged funds
-		err = m.persistTagged(ctx, dealUuid, dealCollateralTag, pubMsgTag)
+		err := m.persistTagged(ctx, dealUuid, dealCollateralTag, pubMsgTag)
 		if err != nil {
 			return nil, fmt.Errorf("saving total tagged: %w", err)
 		}
 	}
 
+	totalCollateral := big.Add(tagged.Collateral, dealCollateralTag)
+	totalPubMsg := big.Add(tagged.PubMsg, pubMsgTag)
+	snap.tagged = &db.TotalTagged{Collateral: totalCollateral, PubMsg: totalPubMsg}
+
 	return &TagFundsResp{
 		Collateral:     dealCollateralTag,
 		PublishMessage: pubMsgTag,
 
-		TotalPublishMessage: big.Add(tagged.PubMsg, pubMsgTag),
-		TotalCollateral:     big.Add(tagged.Collateral, dealCollateralTag),
+		TotalPublishMessage: totalPubMsg,
+		TotalCollateral:     totalCollateral,
 
 		AvailablePublishMessage: big.Sub(availForPubMsg, pubMsgTag),
 		AvailableCollateral:     big.Sub(availForDealCollat, dealCollateralTag),
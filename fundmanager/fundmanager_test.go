@@ -167,6 +167,59 @@ func TestFundManagerDisabled(t *testing.T) {
 	req.EqualValues(0, total.PubMsg.Int64())
 }
 
+func TestFundManagerSnapshot(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := db.CreateTestTmpDB(t)
+	require.NoError(t, db.CreateAllBoostTables(ctx, sqldb, sqldb))
+
+	fm := &FundManager{
+		api: &mockApi{},
+		db:  db.NewFundsDB(sqldb),
+		cfg: Config{
+			Enabled:      true,
+			StorageMiner: address.TestAddress,
+			PubMsgWallet: address.TestAddress2,
+			PubMsgBalMin: abi.NewTokenAmount(10),
+		},
+	}
+
+	deals, err := db.GenerateDeals()
+	req.NoError(err)
+
+	// The available collateral is 30 - 20 = 10
+	snap, err := fm.Snapshot(ctx)
+	req.NoError(err)
+
+	// Tag funds for a deal with collateral 6
+	prop := deals[0].ClientDealProposal.Proposal
+	prop.ProviderCollateral = abi.NewTokenAmount(6)
+	rsp, err := fm.TagFundsFromSnapshot(ctx, snap, deals[0].DealUuid, prop)
+	req.NoError(err)
+	req.EqualValues(6, rsp.TotalCollateral.Int64())
+	req.EqualValues(4, rsp.AvailableCollateral.Int64())
+
+	// The snapshot takes the first deal into account, so there are not
+	// enough funds for a second deal with collateral 6
+	prop2 := deals[1].ClientDealProposal.Proposal
+	prop2.ProviderCollateral = abi.NewTokenAmount(6)
+	_, err = fm.TagFundsFromSnapshot(ctx, snap, deals[1].DealUuid, prop2)
+	req.ErrorIs(err, ErrInsufficientFunds)
+
+	// A deal with collateral 4 fits
+	prop2.ProviderCollateral = abi.NewTokenAmount(4)
+	rsp, err = fm.TagFundsFromSnapshot(ctx, snap, deals[1].DealUuid, prop2)
+	req.NoError(err)
+	req.EqualValues(10, rsp.TotalCollateral.Int64())
+	req.EqualValues(20, rsp.TotalPublishMessage.Int64())
+
+	total, err := fm.TotalTagged(ctx)
+	req.NoError(err)
+	req.EqualValues(10, total.Collateral.Int64())
+	req.EqualValues(20, total.PubMsg.Int64())
+}
+
 type mockApi struct {
 }
 
@@ -25,7 +25,6 @@ import (
 	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
 	"github.com/filecoin-project/boost/storagemarket/types"
 	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
-	"github.com/filecoin-project/boost/transport"
 	"github.com/filecoin-project/dagstore"
 	"github.com/filecoin-project/lotus/api/v1api"
 	"github.com/filecoin-project/lotus/build"
@@ -482,7 +481,7 @@ func (dr *dealResolver) Transfer() dealTransfer {
 	params := "{}"
 	if !dr.IsOffline {
 		var err error
-		params, err = transport.TransferParamsAsJson(transfer)
+		params, err = dr.provider.Transports.ParamsAsJson(transfer)
 		if err != nil {
 			params = fmt.Sprintf(`{"url": "could not extract url from params: %s"}`, err)
 		}
@@ -0,0 +1,91 @@
+package gql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/filecoin-project/boost/db"
+	gqltypes "github.com/filecoin-project/boost/gql/types"
+	"github.com/filecoin-project/boost/storagemarket"
+	"github.com/graph-gophers/graphql-go"
+)
+
+type clientQuotaResolver struct {
+	storagemarket.QuotaInfo
+}
+
+func (q *clientQuotaResolver) Kind() string {
+	return string(q.QuotaInfo.Kind)
+}
+
+func (q *clientQuotaResolver) ID() string {
+	return q.QuotaInfo.ID
+}
+
+func (q *clientQuotaResolver) MaxBytesInFlight() gqltypes.Uint64 {
+	return gqltypes.Uint64(q.QuotaInfo.MaxBytesInFlight)
+}
+
+func (q *clientQuotaResolver) MaxDealsPerHour() gqltypes.Uint64 {
+	return gqltypes.Uint64(q.QuotaInfo.MaxDealsPerHour)
+}
+
+func (q *clientQuotaResolver) MaxConcurrentTransfers() gqltypes.Uint64 {
+	return gqltypes.Uint64(q.QuotaInfo.MaxConcurrentTransfers)
+}
+
+func (q *clientQuotaResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: q.QuotaInfo.UpdatedAt}
+}
+
+func (q *clientQuotaResolver) Usage() *clientQuotaUsageResolver {
+	if q.QuotaInfo.Usage == nil {
+		return nil
+	}
+	return &clientQuotaUsageResolver{QuotaUsage: *q.QuotaInfo.Usage}
+}
+
+type clientQuotaUsageResolver struct {
+	db.QuotaUsage
+}
+
+func (u *clientQuotaUsageResolver) BytesInFlight() gqltypes.Uint64 {
+	return gqltypes.Uint64(u.QuotaUsage.BytesInFlight)
+}
+
+func (u *clientQuotaUsageResolver) DealsLastHour() gqltypes.Uint64 {
+	return gqltypes.Uint64(u.QuotaUsage.DealsLastHour)
+}
+
+func (u *clientQuotaUsageResolver) ConcurrentTransfers() gqltypes.Uint64 {
+	return gqltypes.Uint64(u.QuotaUsage.ConcurrentTransfers)
+}
+
+// query: clientQuotas: [ClientQuota!]!
+func (r *resolver) ClientQuotas(ctx context.Context) ([]*clientQuotaResolver, error) {
+	infos, err := r.provider.Quotas(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*clientQuotaResolver, 0, len(infos))
+	for _, info := range infos {
+		resolvers = append(resolvers, &clientQuotaResolver{QuotaInfo: info})
+	}
+	return resolvers, nil
+}
+
+// query: clientQuota(kind, id): ClientQuota
+func (r *resolver) ClientQuota(ctx context.Context, args struct {
+	Kind string
+	ID   string
+}) (*clientQuotaResolver, error) {
+	info, err := r.provider.Quota(ctx, db.QuotaKind(args.Kind), args.ID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &clientQuotaResolver{QuotaInfo: *info}, nil
+}
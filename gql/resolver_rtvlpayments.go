@@ -0,0 +1,139 @@
+package gql
+
+import (
+	"context"
+
+	gqltypes "github.com/filecoin-project/boost/gql/types"
+	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
+	"github.com/graph-gophers/graphql-go"
+)
+
+type retrievalPaymentResolver struct {
+	rtvllog.RetrievalPayment
+}
+
+func (r *retrievalPaymentResolver) RowID() gqltypes.Uint64 {
+	return gqltypes.Uint64(r.RetrievalPayment.RowID)
+}
+
+func (r *retrievalPaymentResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.RetrievalPayment.CreatedAt}
+}
+
+func (r *retrievalPaymentResolver) Amount() gqltypes.BigInt {
+	return gqltypes.BigInt{Int: r.RetrievalPayment.Amount}
+}
+
+func (r *retrievalPaymentResolver) Total() gqltypes.BigInt {
+	return gqltypes.BigInt{Int: r.RetrievalPayment.Total}
+}
+
+func (r *retrievalPaymentResolver) PayloadCID() string {
+	if !r.RetrievalPayment.PayloadCID.Defined() {
+		return ""
+	}
+	return r.RetrievalPayment.PayloadCID.String()
+}
+
+func (r *retrievalPaymentResolver) PieceCID() string {
+	if r.RetrievalPayment.PieceCID == nil {
+		return ""
+	}
+	return r.RetrievalPayment.PieceCID.String()
+}
+
+func (r *retrievalPaymentResolver) BytesSent() gqltypes.Uint64 {
+	return gqltypes.Uint64(r.RetrievalPayment.BytesSent)
+}
+
+type retrievalPaymentListResolver struct {
+	TotalCount int32
+	Payments   []*retrievalPaymentResolver
+	More       bool
+}
+
+func (r *resolver) RetrievalPayments(ctx context.Context, args retrievalStatesArgs) (*retrievalPaymentListResolver, error) {
+	offset := 0
+	if args.Offset.Set && args.Offset.Value != nil && *args.Offset.Value > 0 {
+		offset = int(*args.Offset.Value)
+	}
+
+	limit := 10
+	if args.Limit.Set && args.Limit.Value != nil && *args.Limit.Value > 0 {
+		limit = int(*args.Limit.Value)
+	}
+
+	// Fetch one extra row so that we can check if there are more rows
+	// beyond the limit
+	var cursor *uint64
+	if args.Cursor != nil {
+		cursorptr := uint64(*args.Cursor)
+		cursor = &cursorptr
+	}
+	rows, err := r.retDB.ListPayments(ctx, cursor, offset, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	more := len(rows) > limit
+	if more {
+		// Truncate list to limit
+		rows = rows[:limit]
+	}
+
+	// Get the total row count
+	count, err := r.retDB.PaymentsCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*retrievalPaymentResolver, 0, len(rows))
+	for _, row := range rows {
+		resolvers = append(resolvers, &retrievalPaymentResolver{RetrievalPayment: row})
+	}
+
+	return &retrievalPaymentListResolver{
+		TotalCount: int32(count),
+		Payments:   resolvers,
+		More:       more,
+	}, nil
+}
+
+type retrievalPaymentTotalResolver struct {
+	rtvllog.PaymentTotal
+}
+
+func (r *retrievalPaymentTotalResolver) Amount() gqltypes.BigInt {
+	return gqltypes.BigInt{Int: r.PaymentTotal.Amount}
+}
+
+func (r *retrievalPaymentTotalResolver) BytesSent() gqltypes.Uint64 {
+	return gqltypes.Uint64(r.PaymentTotal.BytesSent)
+}
+
+func (r *retrievalPaymentTotalResolver) Count() int32 {
+	return int32(r.PaymentTotal.Count)
+}
+
+func (r *resolver) RetrievalPaymentsByPayer(ctx context.Context) ([]*retrievalPaymentTotalResolver, error) {
+	totals, err := r.retDB.PaymentTotalsByPayer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return paymentTotalResolvers(totals), nil
+}
+
+func (r *resolver) RetrievalPaymentsByPiece(ctx context.Context) ([]*retrievalPaymentTotalResolver, error) {
+	totals, err := r.retDB.PaymentTotalsByPiece(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return paymentTotalResolvers(totals), nil
+}
+
+func paymentTotalResolvers(totals []rtvllog.PaymentTotal) []*retrievalPaymentTotalResolver {
+	resolvers := make([]*retrievalPaymentTotalResolver, 0, len(totals))
+	for _, total := range totals {
+		resolvers = append(resolvers, &retrievalPaymentTotalResolver{PaymentTotal: total})
+	}
+	return resolvers
+}
@@ -24,6 +24,8 @@ func (r *resolver) Transfers(_ context.Context) []*transferPoint {
 type transferStats struct {
 	HttpMaxConcurrentDownloads int32
 	Stats                      []*hostTransferStats
+	GlobalRateLimit            gqltypes.Uint64
+	GlobalBytesPerSecond       gqltypes.Uint64
 }
 
 type hostTransferStats struct {
@@ -32,6 +34,8 @@ type hostTransferStats struct {
 	Started         int32
 	Stalled         int32
 	TransferSamples []*transferPoint
+	RateLimit       gqltypes.Uint64
+	BytesPerSecond  gqltypes.Uint64
 }
 
 // query: transferStats: TransferStats
@@ -46,12 +50,121 @@ func (r *resolver) TransferStats(_ context.Context) *transferStats {
 			Started:         int32(s.Started),
 			Stalled:         int32(s.Stalled),
 			TransferSamples: r.getTransferSamples(transfersByDeal, s.DealUuids),
+			RateLimit:       gqltypes.Uint64(s.RateLimit),
+			BytesPerSecond:  gqltypes.Uint64(s.BytesPerSecond),
 		})
 	}
-	return &transferStats{
+	xferStats := &transferStats{
 		HttpMaxConcurrentDownloads: int32(r.cfg.Dealmaking.HttpTransferMaxConcurrentDownloads),
 		Stats:                      gqlStats,
 	}
+	for _, l := range r.provider.TransferRateLimits() {
+		if l.Kind == storagemarket.RateLimitGlobal {
+			xferStats.GlobalRateLimit = gqltypes.Uint64(l.BytesPerSecond)
+			xferStats.GlobalBytesPerSecond = gqltypes.Uint64(l.Rate)
+		}
+	}
+	return xferStats
+}
+
+type transferRateLimit struct {
+	Kind           string
+	ID             string
+	BytesPerSecond gqltypes.Uint64
+	Explicit       bool
+	Transfers      int32
+	Rate           gqltypes.Uint64
+}
+
+// query: transferRateLimits: [TransferRateLimit!]!
+func (r *resolver) TransferRateLimits(_ context.Context) []*transferRateLimit {
+	limits := r.provider.TransferRateLimits()
+	gqlLimits := make([]*transferRateLimit, 0, len(limits))
+	for _, l := range limits {
+		gqlLimits = append(gqlLimits, &transferRateLimit{
+			Kind:           l.Kind,
+			ID:             l.ID,
+			BytesPerSecond: gqltypes.Uint64(l.BytesPerSecond),
+			Explicit:       l.Explicit,
+			Transfers:      int32(l.Transfers),
+			Rate:           gqltypes.Uint64(l.Rate),
+		})
+	}
+	return gqlLimits
+}
+
+// mutation: transferRateLimitSet(kind, id, bytesPerSecond): Boolean
+func (r *resolver) TransferRateLimitSet(_ context.Context, args struct {
+	Kind           string
+	ID             string
+	BytesPerSecond gqltypes.Uint64
+}) (bool, error) {
+	err := r.provider.SetTransferRateLimit(args.Kind, args.ID, uint64(args.BytesPerSecond))
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// mutation: transferRateLimitRemove(kind, id): Boolean
+func (r *resolver) TransferRateLimitRemove(_ context.Context, args struct {
+	Kind string
+	ID   string
+}) (bool, error) {
+	err := r.provider.RemoveTransferRateLimit(args.Kind, args.ID)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+type queuedTransfer struct {
+	DealID             graphql.ID
+	Host               string
+	Size               gqltypes.Uint64
+	StartEpoch         gqltypes.Uint64
+	CreatedAt          graphql.Time
+	Priority           int32
+	PriorityOverridden bool
+	Urgent             bool
+}
+
+// query: transferQueue: [QueuedTransfer]
+func (r *resolver) TransferQueue(_ context.Context) []*queuedTransfer {
+	queue := r.provider.TransferQueue()
+	gqlQueue := make([]*queuedTransfer, 0, len(queue))
+	for _, q := range queue {
+		gqlQueue = append(gqlQueue, &queuedTransfer{
+			DealID:             graphql.ID(q.DealUuid.String()),
+			Host:               q.Host,
+			Size:               gqltypes.Uint64(q.Size),
+			StartEpoch:         gqltypes.Uint64(q.StartEpoch),
+			CreatedAt:          graphql.Time{Time: q.CreatedAt},
+			Priority:           int32(q.Priority),
+			PriorityOverridden: q.PriorityOverridden,
+			Urgent:             q.Urgent,
+		})
+	}
+	return gqlQueue
+}
+
+// mutation: transferSetPriority(id, priority): ID
+func (r *resolver) TransferSetPriority(_ context.Context, args struct {
+	ID       graphql.ID
+	Priority *int32
+}) (graphql.ID, error) {
+	dealUuid, err := toUuid(args.ID)
+	if err != nil {
+		return args.ID, err
+	}
+
+	var priority *int
+	if args.Priority != nil {
+		p := int(*args.Priority)
+		priority = &p
+	}
+	err = r.provider.SetTransferPriority(dealUuid, priority)
+	return args.ID, err
 }
 
 func (r *resolver) getTransferSamples(deals map[uuid.UUID][]storagemarket.TransferPoint, filter []uuid.UUID) []*transferPoint {
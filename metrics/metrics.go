@@ -45,6 +45,10 @@ var (
 	TaskType, _       = tag.NewKey("task_type")
 	WorkerHostname, _ = tag.NewKey("worker_hostname")
 	StorageID, _      = tag.NewKey("storage_id")
+
+	// transfer
+	RateLimitKind, _ = tag.NewKey("rate_limit_kind")
+	RateLimitID, _   = tag.NewKey("rate_limit_id")
 )
 
 // Measures
@@ -177,6 +181,10 @@ var (
 	GraphsyncRequestBytesSentPaidCount          = stats.Int64("graphsync/request_bytes_sent_paid_count", "Counter of Graphsync paid bytes sent", stats.UnitBytes)
 	GraphsyncRequestBytesSentUnpaidCount        = stats.Int64("graphsync/request_bytes_sent_unpaid_count", "Counter of Graphsync unpaid bytes sent", stats.UnitBytes)
 	GraphsyncRequestNetworkErrorCount           = stats.Int64("graphsync/request_network_error_count", "Counter of Graphsync network errors", stats.UnitDimensionless)
+
+	// transfer
+	TransferRate      = stats.Int64("transfer/rate_bytes_per_second", "Average bytes per second received by inbound storage deal transfers", stats.UnitBytes)
+	TransferRateLimit = stats.Int64("transfer/rate_limit_bytes_per_second", "Limit on bytes per second received by inbound storage deal transfers (0 means no limit)", stats.UnitBytes)
 )
 
 var (
@@ -406,6 +414,16 @@ var (
 		Measure:     GraphsyncRequestNetworkErrorCount,
 		Aggregation: view.Count(),
 	}
+	TransferRateView = &view.View{
+		Measure:     TransferRate,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{RateLimitKind, RateLimitID},
+	}
+	TransferRateLimitView = &view.View{
+		Measure:     TransferRateLimit,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{RateLimitKind, RateLimitID},
+	}
 
 	InfoView = &view.View{
 		Name:        "info",
@@ -732,6 +750,8 @@ var DefaultViews = func() []*view.View {
 		GraphsyncRequestPaidBytesSentCountView,
 		GraphsyncRequestUnpaidBytesSentCountView,
 		GraphsyncRequestNetworkErrorCountView,
+		TransferRateView,
+		TransferRateLimitView,
 		lotusmetrics.DagStorePRBytesDiscardedView,
 		lotusmetrics.DagStorePRBytesRequestedView,
 		lotusmetrics.DagStorePRDiscardCountView,
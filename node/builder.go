@@ -163,6 +163,7 @@ const (
 	HandleContractDealsKey
 	HandleProposalLogCleanerKey
 	HandleOnlineBackupMgrKey
+	HandleDealWebhooksKey
 
 	// daemon
 	ExtractApiKey
@@ -466,6 +467,19 @@ func ConfigBoost(cfg *config.Boost) Option {
 		return Error(fmt.Errorf("Detected custom DAG store path %s. The DAG store must be at $BOOST_PATH/dagstore", cfg.DAGStore.RootDir))
 	}
 
+	// The user deal filter is the rules file and / or the filter command
+	var userDealFilters []dealfilter.StorageDealFilter
+	if cfg.Dealmaking.FilterRules != "" {
+		rulesFilter, err := dealfilter.NewRulesFilter(cfg.Dealmaking.FilterRules)
+		if err != nil {
+			return Error(fmt.Errorf("failed to load Dealmaking.FilterRules: %w", err))
+		}
+		userDealFilters = append(userDealFilters, rulesFilter.Filter)
+	}
+	if cfg.Dealmaking.Filter != "" {
+		userDealFilters = append(userDealFilters, dealfilter.CliStorageDealFilter(cfg.Dealmaking.Filter))
+	}
+
 	legacyFees := cfg.LotusFees.Legacy()
 
 	return Options(
@@ -529,7 +543,7 @@ func ConfigBoost(cfg *config.Boost) Option {
 		Override(new(server.AskGetter), From(new(*modules.ProxyAskGetter))),
 		Override(new(*modules.LinkSystemProv), modules.NewLinkSystemProvider),
 		Override(new(server.LinkSystemProvider), From(new(*modules.LinkSystemProv))),
-		Override(new(*server.GraphsyncUnpaidRetrieval), modules.RetrievalGraphsync(cfg.LotusDealmaking.SimultaneousTransfersForStorage, cfg.LotusDealmaking.SimultaneousTransfersForStoragePerClient, cfg.LotusDealmaking.SimultaneousTransfersForRetrieval)),
+		Override(new(*server.GraphsyncUnpaidRetrieval), modules.RetrievalGraphsync(cfg.LotusDealmaking.SimultaneousTransfersForStorage, cfg.LotusDealmaking.SimultaneousTransfersForStoragePerClient, cfg.LotusDealmaking.SimultaneousTransfersForRetrieval, cfg.Nitro)),
 		Override(new(dtypes.StagingGraphsync), From(new(*server.GraphsyncUnpaidRetrieval))),
 		Override(new(dtypes.ProviderPieceStore), modules.NewProviderPieceStore),
 
@@ -580,12 +594,13 @@ func ConfigBoost(cfg *config.Boost) Option {
 		Override(HandleBoostDealsKey, modules.HandleBoostLibp2pDeals),
 		Override(HandleContractDealsKey, modules.HandleContractDeals(&cfg.ContractDeals)),
 		Override(HandleProposalLogCleanerKey, modules.HandleProposalLogCleaner(time.Duration(cfg.Dealmaking.DealProposalLogDuration))),
+		Override(HandleDealWebhooksKey, modules.HandleDealWebhooks(&cfg.Webhooks)),
 		Override(HandleSetLinkSystem, modules.SetLinkSystem),
 
 		// Boost storage deal filter
 		Override(new(dtypes.StorageDealFilter), modules.BasicDealFilter(cfg.Dealmaking, nil)),
-		If(cfg.Dealmaking.Filter != "",
-			Override(new(dtypes.StorageDealFilter), modules.BasicDealFilter(cfg.Dealmaking, dtypes.StorageDealFilter(dealfilter.CliStorageDealFilter(cfg.Dealmaking.Filter)))),
+		If(len(userDealFilters) > 0,
+			Override(new(dtypes.StorageDealFilter), modules.BasicDealFilter(cfg.Dealmaking, dtypes.StorageDealFilter(dealfilter.ChainStorageDealFilters(userDealFilters...)))),
 		),
 
 		// Lotus markets storage deal filter
@@ -85,6 +85,17 @@ func DefaultBoost() *Boost {
 			From:               "0x0000000000000000000000000000000000000000",
 		},
 
+		Nitro: NitroConfig{
+			Enabled:  false,
+			Endpoint: "127.0.0.1:4007/api/v1",
+		},
+
+		Webhooks: WebhooksConfig{
+			MaxAttempts:    10,
+			InitialBackoff: Duration(10 * time.Second),
+			MaxBackoff:     Duration(time.Hour),
+		},
+
 		Dealmaking: DealmakingConfig{
 			ConsiderOnlineStorageDeals:     true,
 			ConsiderOfflineStorageDeals:    true,
@@ -128,9 +139,21 @@ func DefaultBoost() *Boost {
 			HttpTransferMaxConcurrentDownloads: 20,
 			HttpTransferStallTimeout:           Duration(5 * time.Minute),
 			HttpTransferStallCheckPeriod:       Duration(30 * time.Second),
+			HttpTransferMaxConnections:         1,
 			DealLogDurationDays:                30,
 			SealingPipelineCacheTimeout:        Duration(30 * time.Second),
 			FundsTaggingEnabled:                true,
+
+			TransferPriority: TransferPriorityConfig{
+				// Deals that start within a day are urgent
+				UrgentStartEpochWindow: 2880,
+			},
+			StartEpochMonitor: StartEpochMonitorConfig{
+				CheckPeriod:       Duration(time.Minute),
+				WarnMargin:        Duration(time.Hour),
+				AtRiskPriority:    100,
+				FailHopelessDeals: true,
+			},
 		},
 
 		LotusDealmaking: lotus_config.DealmakingConfig{
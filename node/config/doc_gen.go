@@ -79,6 +79,18 @@ your node if metadata log is disabled`,
 
 			Comment: ``,
 		},
+		{
+			Name: "Nitro",
+			Type: "NitroConfig",
+
+			Comment: ``,
+		},
+		{
+			Name: "Webhooks",
+			Type: "WebhooksConfig",
+
+			Comment: ``,
+		},
 		{
 			Name: "LotusDealmaking",
 			Type: "lotus_config.DealmakingConfig",
@@ -273,6 +285,14 @@ logs db is leveraged for pruning stalled retrievals.`,
 
 			Comment: `A command used for fine-grained evaluation of storage deals
 see https://boost.filecoin.io/configuration/deal-filters for more details`,
+		},
+		{
+			Name: "FilterRules",
+			Type: "string",
+
+			Comment: `The path to a TOML file with rules used to evaluate storage deals
+in-process. The file is reloaded when it changes. If Filter is also
+set, a deal must be accepted by both the rules and the Filter command.`,
 		},
 		{
 			Name: "RetrievalFilter",
@@ -354,6 +374,48 @@ more downloads are allowed to start.`,
 			Comment: `The time that can elapse before a download is considered stalled (and
 another concurrent download is allowed to start).`,
 		},
+		{
+			Name: "HttpTransferMaxConnections",
+			Type: "int",
+
+			Comment: `The maximum number of connections used to download a single storage
+deal's data. If this is more than one, or the deal has mirror URLs,
+the data is split into segments that are downloaded in parallel (from
+the deal URL and each of its mirrors).`,
+		},
+		{
+			Name: "S3CredentialsPath",
+			Type: "string",
+
+			Comment: `The path to a JSON file with the credentials that are used to download
+storage deal data from S3 buckets, keyed by name, eg
+{"dataset-a": {"AccessKeyID": "...", "SecretAccessKey": "...",
+"Clients": ["f1..."], "Prefixes": ["https://minio.example.com/dataset-a/"]}}
+Deals with the "s3" transfer type refer to the credentials by name.
+Each credential may only be used by deals from the listed clients, for
+objects whose URL starts with one of the listed prefixes.
+The "s3" transfer type is only supported if this path is set.`,
+		},
+		{
+			Name: "TransferPriority",
+			Type: "TransferPriorityConfig",
+
+			Comment: `The order in which queued storage deal downloads are started`,
+		},
+		{
+			Name: "TransferRateLimit",
+			Type: "TransferRateLimitConfig",
+
+			Comment: `Limits on the bandwidth of storage deal downloads. The limits can be
+changed at runtime with 'boostd transfer-limit', but runtime changes
+are lost on restart.`,
+		},
+		{
+			Name: "StartEpochMonitor",
+			Type: "StartEpochMonitorConfig",
+
+			Comment: `Checks whether in-flight deals can still be sealed by their start epoch`,
+		},
 		{
 			Name: "BitswapPeerID",
 			Type: "string",
@@ -646,6 +708,53 @@ see https://boost.filecoin.io/configuration/deal-filters for more details`,
 message in lotus mpool`,
 		},
 	},
+	"NitroConfig": []DocField{
+		{
+			Name: "Enabled",
+			Type: "bool",
+
+			Comment: `Whether to accept graphsync retrievals that are paid for with nitro
+payment channels`,
+		},
+		{
+			Name: "Endpoint",
+			Type: "string",
+
+			Comment: `The endpoint of the nitro RPC server used to receive payment vouchers`,
+		},
+	},
+	"StartEpochMonitorConfig": []DocField{
+		{
+			Name: "CheckPeriod",
+			Type: "Duration",
+
+			Comment: `How often to check whether in-flight deals can still be sealed by
+their start epoch, based on ExpectedSealDuration and the measured
+transfer rate. Set to 0 to disable.`,
+		},
+		{
+			Name: "WarnMargin",
+			Type: "Duration",
+
+			Comment: `A deal is at risk if it is expected to be sealed less than this long
+before its start epoch. A warning is added to the deal log and its
+download is moved up the transfer queue.`,
+		},
+		{
+			Name: "AtRiskPriority",
+			Type: "int",
+
+			Comment: `The transfer priority given to deals that are at risk`,
+		},
+		{
+			Name: "FailHopelessDeals",
+			Type: "bool",
+
+			Comment: `Whether to fail deals that are not expected to be sealed before their
+start epoch, so that their funds and staging space are released.
+Deals that have already been published are not failed.`,
+		},
+	},
 	"StorageConfig": []DocField{
 		{
 			Name: "ParallelFetchLimit",
@@ -690,6 +799,58 @@ and it is not triggered, retrieval quality for users will be impacted.`,
 			Comment: ``,
 		},
 	},
+	"TransferPriorityConfig": []DocField{
+		{
+			Name: "UrgentStartEpochWindow",
+			Type: "int64",
+
+			Comment: `Deals with a start epoch within this many epochs of the current epoch
+are urgent: their downloads are started before any others, in order
+of start epoch. Set to 0 to disable.`,
+		},
+		{
+			Name: "VerifiedPriority",
+			Type: "int",
+
+			Comment: `The priority added to verified deals`,
+		},
+		{
+			Name: "ClientPriority",
+			Type: "map[string]int",
+
+			Comment: `The priority of deals from each client, keyed by client address`,
+		},
+		{
+			Name: "PreferHigherPrice",
+			Type: "bool",
+
+			Comment: `Whether to start downloads for deals with a higher price per byte
+first, if they have the same priority`,
+		},
+	},
+	"TransferRateLimitConfig": []DocField{
+		{
+			Name: "Global",
+			Type: "uint64",
+
+			Comment: `The maximum bytes per second received by all storage deal downloads
+together. Set to 0 for no limit.`,
+		},
+		{
+			Name: "PerHost",
+			Type: "uint64",
+
+			Comment: `The maximum bytes per second received by the storage deal downloads
+from each host. Set to 0 for no limit.`,
+		},
+		{
+			Name: "PerClient",
+			Type: "uint64",
+
+			Comment: `The maximum bytes per second received by the storage deal downloads
+for deals from each client address. Set to 0 for no limit.`,
+		},
+	},
 	"WalletsConfig": []DocField{
 		{
 			Name: "Miner",
@@ -717,6 +878,63 @@ Must be a control or worker address of the miner.`,
 			Comment: `Deprecated: Renamed to DealCollateral`,
 		},
 	},
+	"WebhookTarget": []DocField{
+		{
+			Name: "URL",
+			Type: "string",
+
+			Comment: `The URL that events are POSTed to`,
+		},
+		{
+			Name: "Secret",
+			Type: "string",
+
+			Comment: `The secret used to sign the request body with HMAC-SHA256. The
+signature is sent in the X-Boost-Signature header.`,
+		},
+		{
+			Name: "Events",
+			Type: "[]string",
+
+			Comment: `The events to send: checkpoint names (eg "Published", "AddedPiece")
+or "Failed". If empty, all events are sent.`,
+		},
+		{
+			Name: "Clients",
+			Type: "[]string",
+
+			Comment: `Only send events for deals from these client addresses. If empty,
+events for deals from all clients are sent.`,
+		},
+	},
+	"WebhooksConfig": []DocField{
+		{
+			Name: "Targets",
+			Type: "[]WebhookTarget",
+
+			Comment: `The URLs that deal checkpoint events are sent to`,
+		},
+		{
+			Name: "MaxAttempts",
+			Type: "int",
+
+			Comment: `The maximum number of attempts to deliver an event before it is moved
+to the WebhookDeadLetters table`,
+		},
+		{
+			Name: "InitialBackoff",
+			Type: "Duration",
+
+			Comment: `The delay before retrying a failed delivery. The delay doubles with
+each subsequent retry, up to MaxBackoff.`,
+		},
+		{
+			Name: "MaxBackoff",
+			Type: "Duration",
+
+			Comment: ``,
+		},
+	},
 	"lotus_config.API": []DocField{
 		{
 			Name: "ListenAddress",
@@ -45,6 +45,8 @@ type Boost struct {
 	Monitoring         MonitoringConfig
 	Tracing            TracingConfig
 	ContractDeals      ContractDealsConfig
+	Nitro              NitroConfig
+	Webhooks           WebhooksConfig
 
 	// Lotus configs
 	LotusDealmaking lotus_config.DealmakingConfig
@@ -188,6 +190,10 @@ type DealmakingConfig struct {
 	// A command used for fine-grained evaluation of storage deals
 	// see https://boost.filecoin.io/configuration/deal-filters for more details
 	Filter string
+	// The path to a TOML file with rules used to evaluate storage deals
+	// in-process. The file is reloaded when it changes. If Filter is also
+	// set, a deal must be accepted by both the rules and the Filter command.
+	FilterRules string
 	// A command used for fine-grained evaluation of retrieval deals
 	// see https://boost.filecoin.io/configuration/deal-filters for more details
 	RetrievalFilter string
@@ -226,6 +232,28 @@ type DealmakingConfig struct {
 	// The time that can elapse before a download is considered stalled (and
 	// another concurrent download is allowed to start).
 	HttpTransferStallTimeout Duration
+	// The maximum number of connections used to download a single storage
+	// deal's data. If this is more than one, or the deal has mirror URLs,
+	// the data is split into segments that are downloaded in parallel (from
+	// the deal URL and each of its mirrors).
+	HttpTransferMaxConnections int
+	// The path to a JSON file with the credentials that are used to download
+	// storage deal data from S3 buckets, keyed by name, eg
+	// {"dataset-a": {"AccessKeyID": "...", "SecretAccessKey": "...",
+	//   "Clients": ["f1..."], "Prefixes": ["https://minio.example.com/dataset-a/"]}}
+	// Deals with the "s3" transfer type refer to the credentials by name.
+	// Each credential may only be used by deals from the listed clients, for
+	// objects whose URL starts with one of the listed prefixes.
+	// The "s3" transfer type is only supported if this path is set.
+	S3CredentialsPath string
+	// The order in which queued storage deal downloads are started
+	TransferPriority TransferPriorityConfig
+	// Limits on the bandwidth of storage deal downloads. The limits can be
+	// changed at runtime with 'boostd transfer-limit', but runtime changes
+	// are lost on restart.
+	TransferRateLimit TransferRateLimitConfig
+	// Checks whether in-flight deals can still be sealed by their start epoch
+	StartEpochMonitor StartEpochMonitorConfig
 
 	// The libp2p peer id used by booster-bitswap.
 	// Run 'booster-bitswap init' to get the peer id.
@@ -269,6 +297,49 @@ type DealmakingConfig struct {
 	FundsTaggingEnabled bool
 }
 
+type TransferPriorityConfig struct {
+	// Deals with a start epoch within this many epochs of the current epoch
+	// are urgent: their downloads are started before any others, in order
+	// of start epoch. Set to 0 to disable.
+	UrgentStartEpochWindow int64
+	// The priority added to verified deals
+	VerifiedPriority int
+	// The priority of deals from each client, keyed by client address
+	ClientPriority map[string]int
+	// Whether to start downloads for deals with a higher price per byte
+	// first, if they have the same priority
+	PreferHigherPrice bool
+}
+
+type TransferRateLimitConfig struct {
+	// The maximum bytes per second received by all storage deal downloads
+	// together. Set to 0 for no limit.
+	Global uint64
+	// The maximum bytes per second received by the storage deal downloads
+	// from each host. Set to 0 for no limit.
+	PerHost uint64
+	// The maximum bytes per second received by the storage deal downloads
+	// for deals from each client address. Set to 0 for no limit.
+	PerClient uint64
+}
+
+type StartEpochMonitorConfig struct {
+	// How often to check whether in-flight deals can still be sealed by
+	// their start epoch, based on ExpectedSealDuration and the measured
+	// transfer rate. Set to 0 to disable.
+	CheckPeriod Duration
+	// A deal is at risk if it is expected to be sealed less than this long
+	// before its start epoch. A warning is added to the deal log and its
+	// download is moved up the transfer queue.
+	WarnMargin Duration
+	// The transfer priority given to deals that are at risk
+	AtRiskPriority int
+	// Whether to fail deals that are not expected to be sealed before their
+	// start epoch, so that their funds and staging space are released.
+	// Deals that have already been published are not failed.
+	FailHopelessDeals bool
+}
+
 type ContractDealsConfig struct {
 	// Whether to enable chain monitoring in order to accept contract deals
 	Enabled bool
@@ -280,6 +351,41 @@ type ContractDealsConfig struct {
 	From string
 }
 
+type NitroConfig struct {
+	// Whether to accept graphsync retrievals that are paid for with nitro
+	// payment channels
+	Enabled bool
+
+	// The endpoint of the nitro RPC server used to receive payment vouchers
+	Endpoint string
+}
+
+type WebhooksConfig struct {
+	// The URLs that deal checkpoint events are sent to
+	Targets []WebhookTarget
+	// The maximum number of attempts to deliver an event before it is moved
+	// to the WebhookDeadLetters table
+	MaxAttempts int
+	// The delay before retrying a failed delivery. The delay doubles with
+	// each subsequent retry, up to MaxBackoff.
+	InitialBackoff Duration
+	MaxBackoff     Duration
+}
+
+type WebhookTarget struct {
+	// The URL that events are POSTed to
+	URL string
+	// The secret used to sign the request body with HMAC-SHA256. The
+	// signature is sent in the X-Boost-Signature header.
+	Secret string
+	// The events to send: checkpoint names (eg "Published", "AddedPiece")
+	// or "Failed". If empty, all events are sent.
+	Events []string
+	// Only send events for deals from these client addresses. If empty,
+	// events for deals from all clients are sent.
+	Clients []string
+}
+
 type IndexProviderConfig struct {
 	// Enable set whether to enable indexing announcement to the network and expose endpoints that
 	// allow indexer nodes to process announcements. Enabled by default.
@@ -15,10 +15,12 @@ import (
 	"github.com/filecoin-project/boost-gfm/retrievalmarket"
 	gfm_storagemarket "github.com/filecoin-project/boost-gfm/storagemarket"
 	"github.com/filecoin-project/boost/api"
+	"github.com/filecoin-project/boost/db"
 	"github.com/filecoin-project/boost/gql"
 	"github.com/filecoin-project/boost/indexprovider"
 	"github.com/filecoin-project/boost/markets/storageadapter"
 	"github.com/filecoin-project/boost/node/modules/dtypes"
+	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
 	retmarket "github.com/filecoin-project/boost/retrievalmarket/server"
 	"github.com/filecoin-project/boost/storagemarket"
 	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
@@ -74,6 +76,9 @@ type BoostAPI struct {
 	// Graphsync Unpaid Retrieval
 	GraphsyncUnpaidRetrieval *retmarket.GraphsyncUnpaidRetrieval
 
+	// Retrieval logs and payments
+	RetrievalLogDB *rtvllog.RetrievalLogDB
+
 	// Sealing Pipeline API
 	Sps sealingpipeline.API
 
@@ -504,6 +509,104 @@ func (sm *BoostAPI) BoostMakeDeal(ctx context.Context, params types.DealParams)
 	return sm.StorageProvider.ExecuteDeal(ctx, &params, "json-rpc-deal")
 }
 
+func (sm *BoostAPI) BoostRetrievalPaymentRecord(ctx context.Context, payment api.RetrievalPayment) error {
+	err := sm.RetrievalLogDB.InsertPayment(ctx, &rtvllog.RetrievalPayment{
+		ChannelID:  payment.ChannelID,
+		Payer:      payment.Payer,
+		Transport:  payment.Transport,
+		Amount:     payment.Amount,
+		Total:      payment.Total,
+		PayloadCID: payment.PayloadCID,
+		PieceCID:   payment.PieceCID,
+		BytesSent:  payment.BytesSent,
+	})
+	if err != nil {
+		return fmt.Errorf("recording retrieval payment on channel %s: %w", payment.ChannelID, err)
+	}
+	return nil
+}
+
+func (sm *BoostAPI) BoostQuotaList(ctx context.Context) ([]api.ClientQuota, error) {
+	infos, err := sm.StorageProvider.Quotas(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	quotas := make([]api.ClientQuota, 0, len(infos))
+	for _, info := range infos {
+		quotas = append(quotas, toApiClientQuota(info))
+	}
+	return quotas, nil
+}
+
+func (sm *BoostAPI) BoostQuotaGet(ctx context.Context, kind string, id string) (*api.ClientQuota, error) {
+	info, err := sm.StorageProvider.Quota(ctx, db.QuotaKind(kind), id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, fmt.Errorf("there is no %s quota for %s", kind, id)
+		}
+		return nil, err
+	}
+	quota := toApiClientQuota(*info)
+	return &quota, nil
+}
+
+func (sm *BoostAPI) BoostQuotaSet(ctx context.Context, quota api.ClientQuota) error {
+	return sm.StorageProvider.SetQuota(ctx, db.Quota{
+		Kind:                   db.QuotaKind(quota.Kind),
+		ID:                     quota.ID,
+		MaxBytesInFlight:       quota.MaxBytesInFlight,
+		MaxDealsPerHour:        quota.MaxDealsPerHour,
+		MaxConcurrentTransfers: quota.MaxConcurrentTransfers,
+	})
+}
+
+func (sm *BoostAPI) BoostQuotaRemove(ctx context.Context, kind string, id string) error {
+	return sm.StorageProvider.RemoveQuota(ctx, db.QuotaKind(kind), id)
+}
+
+func toApiClientQuota(info storagemarket.QuotaInfo) api.ClientQuota {
+	quota := api.ClientQuota{
+		Kind:                   string(info.Kind),
+		ID:                     info.ID,
+		MaxBytesInFlight:       info.MaxBytesInFlight,
+		MaxDealsPerHour:        info.MaxDealsPerHour,
+		MaxConcurrentTransfers: info.MaxConcurrentTransfers,
+	}
+	if info.Usage != nil {
+		quota.Usage = &api.ClientQuotaUsage{
+			BytesInFlight:       info.Usage.BytesInFlight,
+			DealsLastHour:       info.Usage.DealsLastHour,
+			ConcurrentTransfers: info.Usage.ConcurrentTransfers,
+		}
+	}
+	return quota
+}
+
+func (sm *BoostAPI) BoostTransferRateLimitList(ctx context.Context) ([]api.TransferRateLimit, error) {
+	limits := sm.StorageProvider.TransferRateLimits()
+	apiLimits := make([]api.TransferRateLimit, 0, len(limits))
+	for _, l := range limits {
+		apiLimits = append(apiLimits, api.TransferRateLimit{
+			Kind:           l.Kind,
+			ID:             l.ID,
+			BytesPerSecond: l.BytesPerSecond,
+			Explicit:       l.Explicit,
+			Transfers:      l.Transfers,
+			Rate:           l.Rate,
+		})
+	}
+	return apiLimits, nil
+}
+
+func (sm *BoostAPI) BoostTransferRateLimitSet(ctx context.Context, limit api.TransferRateLimit) error {
+	return sm.StorageProvider.SetTransferRateLimit(limit.Kind, limit.ID, limit.BytesPerSecond)
+}
+
+func (sm *BoostAPI) BoostTransferRateLimitRemove(ctx context.Context, kind string, id string) error {
+	return sm.StorageProvider.RemoveTransferRateLimit(kind, id)
+}
+
 func (sm *BoostAPI) BlockstoreGet(ctx context.Context, c cid.Cid) ([]byte, error) {
 	blk, err := sm.IndexBackedBlockstore.Get(ctx, c)
 	if err != nil {
@@ -2,6 +2,7 @@ package modules
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
@@ -22,6 +23,7 @@ import (
 	provider "github.com/ipni/index-provider"
 	"github.com/ipni/index-provider/engine"
 	"github.com/libp2p/go-libp2p/core/host"
+	nrpc "github.com/statechannels/go-nitro/rpc"
 	"go.opencensus.io/stats"
 	"go.uber.org/fx"
 )
@@ -74,7 +76,7 @@ func SetLinkSystem(proxy *LinkSystemProv, prov provider.Interface) {
 }
 
 // RetrievalGraphsync creates a graphsync instance used to serve retrievals.
-func RetrievalGraphsync(parallelTransfersForStorage uint64, parallelTransfersForStoragePerPeer uint64, parallelTransfersForRetrieval uint64) func(mctx lotus_helpers.MetricsCtx, lc fx.Lifecycle, ibs dtypes.IndexBackedBlockstore, h host.Host, net dtypes.ProviderTransferNetwork, dealDecider dtypes.RetrievalDealFilter, dagStore stores.DAGStoreWrapper, pstore dtypes.ProviderPieceStore, sa retrievalmarket.SectorAccessor, askGetter server.AskGetter, ls server.LinkSystemProvider) (*server.GraphsyncUnpaidRetrieval, error) {
+func RetrievalGraphsync(parallelTransfersForStorage uint64, parallelTransfersForStoragePerPeer uint64, parallelTransfersForRetrieval uint64, nitroCfg config.NitroConfig) func(mctx lotus_helpers.MetricsCtx, lc fx.Lifecycle, ibs dtypes.IndexBackedBlockstore, h host.Host, net dtypes.ProviderTransferNetwork, dealDecider dtypes.RetrievalDealFilter, dagStore stores.DAGStoreWrapper, pstore dtypes.ProviderPieceStore, sa retrievalmarket.SectorAccessor, askGetter server.AskGetter, ls server.LinkSystemProvider) (*server.GraphsyncUnpaidRetrieval, error) {
 	return func(mctx lotus_helpers.MetricsCtx, lc fx.Lifecycle, ibs dtypes.IndexBackedBlockstore, h host.Host, net dtypes.ProviderTransferNetwork, dealDecider dtypes.RetrievalDealFilter, dagStore stores.DAGStoreWrapper, pstore dtypes.ProviderPieceStore, sa retrievalmarket.SectorAccessor, askGetter server.AskGetter, ls server.LinkSystemProvider) (*server.GraphsyncUnpaidRetrieval, error) {
 		// Create a Graphsync instance
 		mkgs := Graphsync(parallelTransfersForStorage, parallelTransfersForStoragePerPeer, parallelTransfersForRetrieval)
@@ -88,6 +90,19 @@ func RetrievalGraphsync(parallelTransfersForStorage uint64, parallelTransfersFor
 			SectorAccessor: sa,
 			AskStore:       askGetter,
 		}
+
+		// If nitro payments are enabled, connect to the nitro node that
+		// receives payment vouchers for paid retrievals
+		var nitroClient *nrpc.RpcClient
+		if nitroCfg.Enabled {
+			var err error
+			nitroClient, err = nrpc.NewHttpRpcClient(nitroCfg.Endpoint)
+			if err != nil {
+				return nil, fmt.Errorf("connecting to nitro rpc server at %s: %w", nitroCfg.Endpoint, err)
+			}
+			vdeps.PaymentReceiver = nitroClient
+		}
+
 		gsupr, err := server.NewGraphsyncUnpaidRetrieval(h.ID(), gs, net, vdeps, ls)
 
 		// Set up a context that is cancelled when the boostd process exits
@@ -98,6 +113,11 @@ func RetrievalGraphsync(parallelTransfersForStorage uint64, parallelTransfersFor
 			},
 			OnStop: func(_ context.Context) error {
 				cancel()
+				if nitroClient != nil {
+					if err := nitroClient.Close(); err != nil {
+						return fmt.Errorf("closing nitro rpc client: %w", err)
+					}
+				}
 				return nil
 			},
 		})
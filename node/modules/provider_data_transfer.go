@@ -7,6 +7,7 @@ import (
 
 	marketevents "github.com/filecoin-project/boost/markets/loggers"
 	"github.com/filecoin-project/boost/node/modules/dtypes"
+	"github.com/filecoin-project/boost/retrievalmarket/server"
 	dtimpl "github.com/filecoin-project/go-data-transfer/impl"
 	lotus_dtypes "github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/repo"
@@ -16,10 +17,12 @@ import (
 )
 
 // NewProviderDataTransfer returns a data transfer manager
-func NewProviderDataTransfer(lc fx.Lifecycle, net dtypes.ProviderTransferNetwork, transport dtypes.ProviderTransport, ds lotus_dtypes.MetadataDS, r repo.LockedRepo) (dtypes.ProviderDataTransfer, error) {
+func NewProviderDataTransfer(lc fx.Lifecycle, net dtypes.ProviderTransferNetwork, transport dtypes.ProviderTransport, gsupr *server.GraphsyncUnpaidRetrieval, ds lotus_dtypes.MetadataDS, r repo.LockedRepo) (dtypes.ProviderDataTransfer, error) {
 	dtDs := namespace.Wrap(ds, datastore.NewKey("/datatransfer/provider/transfers"))
 
-	dt, err := dtimpl.NewDataTransfer(dtDs, net, transport)
+	// Payment vouchers for retrievals paid with nitro are handled by
+	// GraphsyncUnpaidRetrieval rather than the data transfer manager
+	dt, err := dtimpl.NewDataTransfer(dtDs, gsupr.WrapNetwork(net), transport)
 	if err != nil {
 		return nil, err
 	}
@@ -181,7 +181,10 @@ func NewProtocolProxy(cfg *config.Boost) func(h host.Host) (*protocolproxy.Proto
 			if err != nil {
 				return nil, err
 			}
-			peerConfig[bsPeerID] = bitswap.Protocols
+			// Also forward the protocol that clients use to pay for paid
+			// bitswap retrievals
+			protos := append([]protocol.ID{}, bitswap.Protocols...)
+			peerConfig[bsPeerID] = append(protos, lp2pimpl.BitswapPaymentProtocolID)
 		}
 		return protocolproxy.NewProtocolProxy(h, peerConfig)
 	}
@@ -41,7 +41,9 @@ import (
 	"github.com/filecoin-project/boost/storagemarket/lp2pimpl"
 	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
 	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/transport"
 	"github.com/filecoin-project/boost/transport/httptransport"
+	"github.com/filecoin-project/boost/transport/s3transport"
 	"github.com/filecoin-project/boostd-data/shared/tracing"
 	"github.com/filecoin-project/dagstore"
 	"github.com/filecoin-project/dagstore/indexbs"
@@ -598,13 +600,36 @@ func NewLegacyStorageProvider(cfg *config.Boost) func(minerAddress lotus_dtypes.
 	}
 }
 
-func NewStorageMarketProvider(provAddr address.Address, cfg *config.Boost) func(lc fx.Lifecycle, h host.Host, a v1api.FullNode, sqldb *sql.DB, dealsDB *db.DealsDB, fundMgr *fundmanager.FundManager, storageMgr *storagemanager.StorageManager, dp *storageadapter.DealPublisher, secb *sectorblocks.SectorBlocks, commpc types.CommpCalculator, sps sealingpipeline.API, df dtypes.StorageDealFilter, logsSqlDB *LogSqlDB, logsDB *db.LogsDB, dagst *mdagstore.Wrapper, ps dtypes.ProviderPieceStore, ip *indexprovider.Wrapper, lp gfm_storagemarket.StorageProvider, cdm *storagemarket.ChainDealManager) (*storagemarket.Provider, error) {
+func transferPriorityConfig(cfg config.TransferPriorityConfig) (storagemarket.TransferPriorityConfig, error) {
+	clientPriority := make(map[address.Address]int, len(cfg.ClientPriority))
+	for addrStr, priority := range cfg.ClientPriority {
+		addr, err := address.NewFromString(addrStr)
+		if err != nil {
+			return storagemarket.TransferPriorityConfig{}, fmt.Errorf("parsing TransferPriority.ClientPriority address '%s': %w", addrStr, err)
+		}
+		clientPriority[addr] = priority
+	}
+
+	return storagemarket.TransferPriorityConfig{
+		UrgentStartEpochWindow: abi.ChainEpoch(cfg.UrgentStartEpochWindow),
+		VerifiedPriority:       cfg.VerifiedPriority,
+		ClientPriority:         clientPriority,
+		PreferHigherPrice:      cfg.PreferHigherPrice,
+	}, nil
+}
+
+func NewStorageMarketProvider(provAddr address.Address, cfg *config.Boost) func(lc fx.Lifecycle, h host.Host, a v1api.FullNode, sqldb *sql.DB, dealsDB *db.DealsDB, fundMgr *fundmanager.FundManager, storageMgr *storagemanager.StorageManager, dp *storageadapter.DealPublisher, secb *sectorblocks.SectorBlocks, commpc types.CommpCalculator, sps sealingpipeline.API, df dtypes.StorageDealFilter, logsSqlDB *LogSqlDB, logsDB *db.LogsDB, dagst *mdagstore.Wrapper, ps dtypes.ProviderPieceStore, ip *indexprovider.Wrapper, lp gfm_storagemarket.StorageProvider, cdm *storagemarket.ChainDealManager, getSealDuration dtypes.GetExpectedSealDurationFunc) (*storagemarket.Provider, error) {
 	return func(lc fx.Lifecycle, h host.Host, a v1api.FullNode, sqldb *sql.DB, dealsDB *db.DealsDB,
 		fundMgr *fundmanager.FundManager, storageMgr *storagemanager.StorageManager, dp *storageadapter.DealPublisher, secb *sectorblocks.SectorBlocks,
 		commpc types.CommpCalculator, sps sealingpipeline.API,
 		df dtypes.StorageDealFilter, logsSqlDB *LogSqlDB, logsDB *db.LogsDB,
 		dagst *mdagstore.Wrapper, ps dtypes.ProviderPieceStore, ip *indexprovider.Wrapper,
-		lp gfm_storagemarket.StorageProvider, cdm *storagemarket.ChainDealManager) (*storagemarket.Provider, error) {
+		lp gfm_storagemarket.StorageProvider, cdm *storagemarket.ChainDealManager, getSealDuration dtypes.GetExpectedSealDurationFunc) (*storagemarket.Provider, error) {
+
+		xferPriority, err := transferPriorityConfig(cfg.Dealmaking.TransferPriority)
+		if err != nil {
+			return nil, err
+		}
 
 		prvCfg := storagemarket.Config{
 			MaxTransferDuration:     time.Duration(cfg.Dealmaking.MaxTransferDuration),
@@ -614,13 +639,33 @@ func NewStorageMarketProvider(provAddr address.Address, cfg *config.Boost) func(
 				MaxConcurrent:    cfg.Dealmaking.HttpTransferMaxConcurrentDownloads,
 				StallCheckPeriod: time.Duration(cfg.Dealmaking.HttpTransferStallCheckPeriod),
 				StallTimeout:     time.Duration(cfg.Dealmaking.HttpTransferStallTimeout),
+				Priority:         xferPriority,
+			},
+			TransferRateLimit: storagemarket.TransferRateLimitConfig{
+				Global:    cfg.Dealmaking.TransferRateLimit.Global,
+				PerHost:   cfg.Dealmaking.TransferRateLimit.PerHost,
+				PerClient: cfg.Dealmaking.TransferRateLimit.PerClient,
 			},
 			DealLogDurationDays:         cfg.Dealmaking.DealLogDurationDays,
 			StorageFilter:               cfg.Dealmaking.Filter,
+			StorageFilterRules:          cfg.Dealmaking.FilterRules,
 			SealingPipelineCacheTimeout: time.Duration(cfg.Dealmaking.SealingPipelineCacheTimeout),
+			DeadlineMonitor: storagemarket.DeadlineMonitorConfig{
+				CheckPeriod:          time.Duration(cfg.Dealmaking.StartEpochMonitor.CheckPeriod),
+				WarnMargin:           time.Duration(cfg.Dealmaking.StartEpochMonitor.WarnMargin),
+				AtRiskPriority:       cfg.Dealmaking.StartEpochMonitor.AtRiskPriority,
+				FailHopeless:         cfg.Dealmaking.StartEpochMonitor.FailHopelessDeals,
+				ExpectedSealDuration: getSealDuration,
+			},
 		}
 		dl := logs.NewDealLogger(logsDB)
-		tspt := httptransport.New(h, dl)
+		httpTspt := httptransport.New(h, dl, httptransport.ParallelDownloadOpt(cfg.Dealmaking.HttpTransferMaxConnections, 0))
+		tspt := transport.NewRegistry()
+		tspt.Register("http", httpTspt)
+		tspt.Register("libp2p", httpTspt)
+		if cfg.Dealmaking.S3CredentialsPath != "" {
+			tspt.Register("s3", s3transport.New(s3transport.CredentialsFile(cfg.Dealmaking.S3CredentialsPath), dl))
+		}
 		prov, err := storagemarket.NewProvider(prvCfg, sqldb, dealsDB, fundMgr, storageMgr, a, dp, provAddr, secb, commpc,
 			sps, cdm, df, logsSqlDB.db, logsDB, dagst, ps, ip, lp, &signatureVerifier{a}, dl, tspt)
 		if err != nil {
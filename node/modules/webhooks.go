@@ -0,0 +1,63 @@
+package modules
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/node/config"
+	"github.com/filecoin-project/boost/storagemarket"
+	"github.com/filecoin-project/boost/storagemarket/webhooks"
+	"go.uber.org/fx"
+)
+
+// HandleDealWebhooks sends deal checkpoint events to the webhook targets in
+// the config
+func HandleDealWebhooks(cfg *config.WebhooksConfig) func(lc fx.Lifecycle, sqldb *sql.DB, prov *storagemarket.Provider) error {
+	return func(lc fx.Lifecycle, sqldb *sql.DB, prov *storagemarket.Provider) error {
+		if len(cfg.Targets) == 0 {
+			return nil
+		}
+
+		targets := make([]webhooks.Target, 0, len(cfg.Targets))
+		for _, t := range cfg.Targets {
+			targets = append(targets, webhooks.Target{
+				URL:     t.URL,
+				Secret:  t.Secret,
+				Events:  t.Events,
+				Clients: t.Clients,
+			})
+		}
+		dispatcher, err := webhooks.New(webhooks.Config{
+			Targets:        targets,
+			MaxAttempts:    cfg.MaxAttempts,
+			InitialBackoff: time.Duration(cfg.InitialBackoff),
+			MaxBackoff:     time.Duration(cfg.MaxBackoff),
+		}, db.NewWebhooksDB(sqldb))
+		if err != nil {
+			return fmt.Errorf("creating deal webhooks: %w", err)
+		}
+
+		// Subscribe before the provider starts so that no events are missed
+		sub, err := prov.SubscribeDealCheckpoints()
+		if err != nil {
+			return fmt.Errorf("subscribing to deal checkpoints: %w", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		lc.Append(fx.Hook{
+			OnStart: func(_ context.Context) error {
+				log.Infow("starting deal webhooks", "targets", len(targets))
+				go dispatcher.Run(ctx, sub)
+				return nil
+			},
+			OnStop: func(_ context.Context) error {
+				cancel()
+				return nil
+			},
+		})
+		return nil
+	}
+}
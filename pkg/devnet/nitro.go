@@ -0,0 +1,75 @@
+package devnet
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	"github.com/filecoin-project/boost/pkg/fakenitro"
+	"github.com/statechannels/go-nitro/crypto"
+	"github.com/statechannels/go-nitro/types"
+)
+
+// FakeNitroOptions configures the fake nitro nodes that are run alongside
+// the devnet
+type FakeNitroOptions struct {
+	// The listen address of the client's nitro node RPC server
+	ClientListenAddr string
+	// The listen address of the provider's nitro node RPC server
+	ProviderListenAddr string
+	// The amount the payment channel between the client and provider is
+	// funded with
+	Funds *big.Int
+}
+
+// RunFakeNitro runs a fake nitro node for the client and another for the
+// provider, with a payment channel between them, so that paid retrievals
+// can be tested without a nitro node or a chain. The nodes are stopped
+// when the context is cancelled.
+func RunFakeNitro(ctx context.Context, opts FakeNitroOptions) error {
+	payerKey, payer := crypto.GeneratePrivateKeyAndAddress()
+	_, payee := crypto.GeneratePrivateKeyAndAddress()
+
+	var channelID types.Destination
+	if _, err := rand.Read(channelID[:]); err != nil {
+		return fmt.Errorf("generating channel id: %w", err)
+	}
+	ch := fakenitro.Channel{
+		ID:       channelID,
+		Payer:    payer,
+		Payee:    payee,
+		Funds:    opts.Funds,
+		PayerKey: payerKey,
+	}
+
+	client := fakenitro.NewServer(payer)
+	client.AddChannel(ch)
+	if err := client.Start(opts.ClientListenAddr); err != nil {
+		return fmt.Errorf("starting client fake nitro node: %w", err)
+	}
+
+	provider := fakenitro.NewServer(payee)
+	provider.AddChannel(ch)
+	if err := provider.Start(opts.ProviderListenAddr); err != nil {
+		_ = client.Stop()
+		return fmt.Errorf("starting provider fake nitro node: %w", err)
+	}
+
+	log.Infow("running fake nitro nodes",
+		"clientEndpoint", client.Url(), "clientAddress", payer,
+		"providerEndpoint", provider.Url(), "providerAddress", payee,
+		"channel", channelID, "funds", opts.Funds)
+
+	go func() {
+		<-ctx.Done()
+		if err := client.Stop(); err != nil {
+			log.Warnw("stopping client fake nitro node", "err", err)
+		}
+		if err := provider.Stop(); err != nil {
+			log.Warnw("stopping provider fake nitro node", "err", err)
+		}
+	}()
+
+	return nil
+}
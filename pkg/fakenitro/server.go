@@ -0,0 +1,350 @@
+// Package fakenitro is an in-process stand-in for a go-nitro RPC server.
+// It speaks the same JSON-RPC protocol as a nitro node, so it can be used
+// with the go-nitro RPC client to test paid retrievals without running a
+// nitro node and a chain.
+package fakenitro
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/websocket"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/statechannels/go-nitro/node/query"
+	"github.com/statechannels/go-nitro/payments"
+	"github.com/statechannels/go-nitro/rpc/serde"
+	"github.com/statechannels/go-nitro/types"
+)
+
+var log = logging.Logger("fakenitro")
+
+const apiPath = "/api/v1"
+
+// Version is the version reported by the server
+const Version = "fakenitro"
+
+// Channel is a payment channel that the server knows about
+type Channel struct {
+	ID types.Destination
+	// The address that signs vouchers on the channel
+	Payer types.Address
+	// The address that receives payments on the channel
+	Payee types.Address
+	// The address of the asset the channel is funded with
+	AssetAddress types.Address
+	// The amount the channel was funded with
+	Funds *big.Int
+	// The private key of the payer. If set, the server can create vouchers
+	// on the channel (ie it acts as the payer's node).
+	PayerKey []byte
+}
+
+type channelState struct {
+	Channel
+	// The amount of the largest voucher received (or created) on the channel
+	paid *big.Int
+}
+
+// Server is a fake nitro RPC server that checks voucher signatures against
+// the channels it has been configured with and keeps track of the amount
+// paid on each channel
+type Server struct {
+	address types.Address
+
+	lk       sync.Mutex
+	channels map[types.Destination]*channelState
+	errs     map[serde.RequestMethod]error
+
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewServer creates a fake nitro server for the node with the given address
+func NewServer(address types.Address) *Server {
+	return &Server{
+		address:  address,
+		channels: make(map[types.Destination]*channelState),
+		errs:     make(map[serde.RequestMethod]error),
+	}
+}
+
+// Address returns the address of the fake nitro node
+func (s *Server) Address() types.Address {
+	return s.address
+}
+
+// AddChannel adds a payment channel that the server will accept vouchers on
+func (s *Server) AddChannel(ch Channel) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	funds := new(big.Int)
+	if ch.Funds != nil {
+		funds.Set(ch.Funds)
+	}
+	ch.Funds = funds
+	s.channels[ch.ID] = &channelState{Channel: ch, paid: new(big.Int)}
+}
+
+// Paid returns the total amount paid so far on the channel
+func (s *Server) Paid(id types.Destination) (*big.Int, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	ch, ok := s.channels[id]
+	if !ok {
+		return nil, fmt.Errorf("channel %s not found", id)
+	}
+	return new(big.Int).Set(ch.paid), nil
+}
+
+// InjectError causes all subsequent requests for the given method to fail
+// with err. Passing a nil error clears the injected error.
+func (s *Server) InjectError(method serde.RequestMethod, err error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if err == nil {
+		delete(s.errs, method)
+		return
+	}
+	s.errs[method] = err
+}
+
+// Start listens for RPC requests on the given address (eg "127.0.0.1:0")
+func (s *Server) Start(listenAddr string) error {
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", listenAddr, err)
+	}
+	s.listener = listener
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(apiPath, s.handleRequest)
+	mux.HandleFunc(apiPath+"/subscribe", s.handleSubscribe)
+	s.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorw("fake nitro server stopped", "err", err)
+		}
+	}()
+
+	log.Infow("fake nitro server started", "url", s.Url(), "address", s.address)
+	return nil
+}
+
+// Url returns the url that should be passed to the nitro RPC client
+// (eg nrpc.NewHttpRpcClient)
+func (s *Server) Url() string {
+	return s.listener.Addr().String() + apiPath
+}
+
+// Stop stops the server
+func (s *Server) Stop() error {
+	// Use Close rather than Shutdown so that subscriptions don't block
+	// the server from stopping
+	return s.server.Close()
+}
+
+func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req serde.JsonRpcGeneralRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		writeResponse(w, serde.NewJsonRpcErrorResponse(0, serde.RequestUnmarshalError))
+		return
+	}
+
+	method := serde.RequestMethod(req.Method)
+	log.Debugw("request", "method", method, "id", req.Id)
+
+	switch method {
+	case serde.GetAddressMethod:
+		processRequest(s, w, data, method, func(serde.NoPayloadRequest) (string, error) {
+			return s.address.Hex(), nil
+		})
+	case serde.VersionMethod:
+		processRequest(s, w, data, method, func(serde.NoPayloadRequest) (string, error) {
+			return Version, nil
+		})
+	case serde.ReceiveVoucherRequestMethod:
+		processRequest(s, w, data, method, s.receiveVoucher)
+	case serde.CreateVoucherRequestMethod:
+		processRequest(s, w, data, method, func(req serde.PaymentRequest) (payments.Voucher, error) {
+			return s.createVoucher(req.Channel, new(big.Int).SetUint64(req.Amount))
+		})
+	case serde.GetPaymentChannelRequestMethod:
+		processRequest(s, w, data, method, func(req serde.GetPaymentChannelRequest) (query.PaymentChannelInfo, error) {
+			return s.paymentChannelInfo(req.Id)
+		})
+	default:
+		writeResponse(w, serde.NewJsonRpcErrorResponse(req.Id, serde.MethodNotFoundError))
+	}
+}
+
+// processRequest decodes the request params, calls the handler and writes
+// the result (or error) as a JSON-RPC response
+func processRequest[T serde.RequestPayload, U serde.ResponsePayload](s *Server, w http.ResponseWriter, data []byte, method serde.RequestMethod, handle func(T) (U, error)) {
+	var req serde.JsonRpcSpecificRequest[T]
+	if err := json.Unmarshal(data, &req); err != nil {
+		writeResponse(w, serde.NewJsonRpcErrorResponse(req.Id, serde.ParamsUnmarshalError))
+		return
+	}
+
+	s.lk.Lock()
+	err := s.errs[method]
+	s.lk.Unlock()
+
+	var res U
+	if err == nil {
+		res, err = handle(req.Params)
+	}
+	if err != nil {
+		log.Debugw("request failed", "method", method, "id", req.Id, "err", err)
+		rpcErr := serde.InternalServerError
+		rpcErr.Message = err.Error()
+		writeResponse(w, serde.NewJsonRpcErrorResponse(req.Id, rpcErr))
+		return
+	}
+
+	writeResponse(w, serde.NewJsonRpcResponse(req.Id, res))
+}
+
+func writeResponse(w http.ResponseWriter, res interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Warnw("writing response", "err", err)
+	}
+}
+
+// receiveVoucher checks that the voucher is signed by the channel's payer
+// and returns the total amount paid on the channel and the amount paid by
+// the voucher, in the same way as a nitro node
+func (s *Server) receiveVoucher(v payments.Voucher) (payments.ReceiveVoucherSummary, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	ch, ok := s.channels[v.ChannelId]
+	if !ok {
+		return payments.ReceiveVoucherSummary{}, fmt.Errorf("channel not registered: %s", v.ChannelId)
+	}
+	if ch.Payee != s.address {
+		return payments.ReceiveVoucherSummary{}, fmt.Errorf("can only receive vouchers if we're the payee")
+	}
+	if v.Amount == nil {
+		return payments.ReceiveVoucherSummary{}, fmt.Errorf("voucher has no amount")
+	}
+	if v.Amount.Cmp(ch.Funds) > 0 {
+		return payments.ReceiveVoucherSummary{}, fmt.Errorf("channel has insufficient funds")
+	}
+
+	// A voucher that doesn't increase the amount paid doesn't result in a
+	// payment
+	if v.Amount.Cmp(ch.paid) <= 0 {
+		return payments.ReceiveVoucherSummary{Total: new(big.Int).Set(ch.paid), Delta: new(big.Int)}, nil
+	}
+
+	signer, err := v.RecoverSigner()
+	if err != nil {
+		return payments.ReceiveVoucherSummary{}, fmt.Errorf("recovering voucher signer: %w", err)
+	}
+	if signer != ch.Payer {
+		return payments.ReceiveVoucherSummary{}, fmt.Errorf("wrong signer: %s, expected %s", signer, ch.Payer)
+	}
+
+	delta := new(big.Int).Sub(v.Amount, ch.paid)
+	ch.paid = new(big.Int).Set(v.Amount)
+	return payments.ReceiveVoucherSummary{Total: new(big.Int).Set(ch.paid), Delta: delta}, nil
+}
+
+// createVoucher signs a voucher that pays amount more than the previous
+// voucher on the channel
+func (s *Server) createVoucher(id types.Destination, amount *big.Int) (payments.Voucher, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	ch, ok := s.channels[id]
+	if !ok {
+		return payments.Voucher{}, fmt.Errorf("channel not registered: %s", id)
+	}
+	if len(ch.PayerKey) == 0 || ch.Payer != s.address {
+		return payments.Voucher{}, fmt.Errorf("can only sign vouchers if we're the payer")
+	}
+
+	total := new(big.Int).Add(ch.paid, amount)
+	if total.Cmp(ch.Funds) > 0 {
+		return payments.Voucher{}, fmt.Errorf("unable to pay amount: insufficient funds")
+	}
+
+	v := payments.Voucher{ChannelId: id, Amount: total}
+	if err := v.Sign(ch.PayerKey); err != nil {
+		return payments.Voucher{}, fmt.Errorf("signing voucher: %w", err)
+	}
+	ch.paid = new(big.Int).Set(total)
+	return v, nil
+}
+
+func (s *Server) paymentChannelInfo(id types.Destination) (query.PaymentChannelInfo, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	ch, ok := s.channels[id]
+	if !ok {
+		return query.PaymentChannelInfo{}, fmt.Errorf("channel %s not found", id)
+	}
+	return query.PaymentChannelInfo{
+		ID:     ch.ID,
+		Status: query.Open,
+		Balance: query.PaymentChannelBalance{
+			AssetAddress:   ch.AssetAddress,
+			Payee:          ch.Payee,
+			Payer:          ch.Payer,
+			PaidSoFar:      (*hexutil.Big)(new(big.Int).Set(ch.paid)),
+			RemainingFunds: (*hexutil.Big)(new(big.Int).Sub(ch.Funds, ch.paid)),
+		},
+	}, nil
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// handleSubscribe accepts the websocket connection that the nitro RPC client
+// opens to listen for notifications. The fake server doesn't run
+// objectives, so it never sends any notifications.
+func (s *Server) handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Warnw("upgrading subscription to websocket", "err", err)
+		return
+	}
+	defer conn.Close()
+
+	// Read until the client closes the connection
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
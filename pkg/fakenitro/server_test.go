@@ -0,0 +1,108 @@
+package fakenitro
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/statechannels/go-nitro/crypto"
+	"github.com/statechannels/go-nitro/payments"
+	nrpc "github.com/statechannels/go-nitro/rpc"
+	"github.com/statechannels/go-nitro/rpc/serde"
+	"github.com/statechannels/go-nitro/types"
+	"github.com/stretchr/testify/require"
+)
+
+func startServer(t *testing.T, address types.Address, ch Channel) (*Server, *nrpc.RpcClient) {
+	srv := NewServer(address)
+	srv.AddChannel(ch)
+	require.NoError(t, srv.Start("127.0.0.1:0"))
+	t.Cleanup(func() { _ = srv.Stop() })
+
+	client, err := nrpc.NewHttpRpcClient(srv.Url())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return srv, client
+}
+
+func TestFakeNitro(t *testing.T) {
+	payerKey, payer := crypto.GeneratePrivateKeyAndAddress()
+	_, payee := crypto.GeneratePrivateKeyAndAddress()
+	ch := Channel{
+		ID:       types.Destination{1},
+		Payer:    payer,
+		Payee:    payee,
+		Funds:    big.NewInt(100),
+		PayerKey: payerKey,
+	}
+
+	payerSrv, payerClient := startServer(t, payer, ch)
+	payeeSrv, payeeClient := startServer(t, payee, ch)
+
+	addr, err := payeeClient.Address()
+	require.NoError(t, err)
+	require.Equal(t, payee, addr)
+
+	// The payer's node creates vouchers for the total amount paid
+	v, err := payerClient.CreateVoucher(ch.ID, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 10, v.Amount.Int64())
+	v, err = payerClient.CreateVoucher(ch.ID, 15)
+	require.NoError(t, err)
+	require.EqualValues(t, 25, v.Amount.Int64())
+	paid, err := payerSrv.Paid(ch.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 25, paid.Int64())
+
+	// The payee's node can't create vouchers on the channel
+	_, err = payeeClient.CreateVoucher(ch.ID, 10)
+	require.Error(t, err)
+
+	// The payee's node receives the voucher
+	s, err := payeeClient.ReceiveVoucher(v)
+	require.NoError(t, err)
+	require.EqualValues(t, 25, s.Total.Int64())
+	require.EqualValues(t, 25, s.Delta.Int64())
+
+	// Receiving the same voucher again doesn't result in a payment
+	s, err = payeeClient.ReceiveVoucher(v)
+	require.NoError(t, err)
+	require.EqualValues(t, 25, s.Total.Int64())
+	require.Zero(t, s.Delta.Sign())
+
+	info, err := payeeClient.GetPaymentChannel(ch.ID)
+	require.NoError(t, err)
+	require.Equal(t, payer, info.Balance.Payer)
+	require.EqualValues(t, 25, info.Balance.PaidSoFar.ToInt().Int64())
+	require.EqualValues(t, 75, info.Balance.RemainingFunds.ToInt().Int64())
+
+	// A voucher signed by someone other than the payer is rejected
+	otherKey, _ := crypto.GeneratePrivateKeyAndAddress()
+	forged := payments.Voucher{ChannelId: ch.ID, Amount: big.NewInt(50)}
+	require.NoError(t, forged.Sign(otherKey))
+	_, err = payeeClient.ReceiveVoucher(forged)
+	require.ErrorContains(t, err, "wrong signer")
+
+	// A voucher for more than the channel's funds is rejected
+	_, err = payerClient.CreateVoucher(ch.ID, 100)
+	require.Error(t, err)
+
+	// A voucher on an unknown channel is rejected
+	unknown := payments.Voucher{ChannelId: types.Destination{2}, Amount: big.NewInt(10)}
+	require.NoError(t, unknown.Sign(payerKey))
+	_, err = payeeClient.ReceiveVoucher(unknown)
+	require.Error(t, err)
+
+	// Injected errors are returned until they are cleared
+	payeeSrv.InjectError(serde.ReceiveVoucherRequestMethod, errors.New("injected"))
+	v, err = payerClient.CreateVoucher(ch.ID, 5)
+	require.NoError(t, err)
+	_, err = payeeClient.ReceiveVoucher(v)
+	require.ErrorContains(t, err, "injected")
+
+	payeeSrv.InjectError(serde.ReceiveVoucherRequestMethod, nil)
+	s, err = payeeClient.ReceiveVoucher(v)
+	require.NoError(t, err)
+	require.EqualValues(t, 30, s.Total.Int64())
+	require.EqualValues(t, 5, s.Delta.Int64())
+}
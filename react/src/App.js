@@ -21,6 +21,7 @@ import {ProposalLogsPage} from "./ProposalLogs";
 import {InspectPage} from "./Inspect";
 import {RetrievalLogsPage} from "./RetrievalLogs";
 import {RetrievalLogDetail} from "./RetrievalLogDetail";
+import {RetrievalPaymentsPage} from "./RetrievalPayments";
 import {MonitoringAlert} from "./MonitoringAlert";
 
 function App(props) {
@@ -46,6 +47,8 @@ function App(props) {
                                         <Route path="/retrieval-logs" element={<RetrievalLogsPage />} />
                                         <Route path="/retrieval-logs/from/:cursor/page/:pageNum" element={<RetrievalLogsPage />} />
                                         <Route path="/retrieval-logs/:peerID/:transferID" element={<RetrievalLogDetail />} />
+                                        <Route path="/retrieval-payments" element={<RetrievalPaymentsPage />} />
+                                        <Route path="/retrieval-payments/from/:cursor/page/:pageNum" element={<RetrievalPaymentsPage />} />
                                         <Route path="/storage-space" element={<StorageSpacePage />} />
                                         <Route path="/sealing-pipeline" element={<SealingPipelinePage />} />
                                         <Route path="/funds" element={<FundsPage />} />
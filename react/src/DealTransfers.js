@@ -1,11 +1,11 @@
-import React from "react";
+import React, {useState} from "react";
 import {Chart} from "react-google-charts";
-import {useQuery} from "@apollo/react-hooks";
-import {TransfersQuery, TransferStatsQuery} from "./gql";
+import {useMutation, useQuery} from "@apollo/react-hooks";
+import {TransferQueueQuery, TransferSetPriorityMutation, TransfersQuery, TransferStatsQuery} from "./gql";
 import moment from "moment"
 import {PageContainer} from "./Components";
 import {Link} from "react-router-dom";
-import {toFixed} from "./util";
+import {humanFileSize, toFixed} from "./util";
 import arrowLeftRightImg from './bootstrap-icons/icons/arrow-left-right.svg'
 import './DealTransfers.css'
 
@@ -21,6 +21,7 @@ function DealTransfersContent(props) {
     return <div>
         <DealTransfersChart />
         <TransferStats />
+        <TransferQueue />
     </div>
 }
 
@@ -133,6 +134,82 @@ function TransferStats(props) {
     </div>
 }
 
+function TransferQueue(props) {
+    const {loading, error, data} = useQuery(TransferQueueQuery, {
+        pollInterval: 2000,
+        fetchPolicy: 'network-only',
+    })
+
+    if (loading) {
+        return <div>Loading...</div>
+    }
+    if (error) {
+        return <div>Error: {error.message}</div>
+    }
+
+    const queue = data.transferQueue
+    return <div className="transfer-stats transfer-queue">
+        <h3>Transfer Queue</h3>
+        {queue.length === 0 ? <div>No queued transfers</div> : (
+            <table>
+                <tbody>
+                <tr>
+                    <th>Deal</th>
+                    <th>Host</th>
+                    <th>Size</th>
+                    <th>Start Epoch</th>
+                    <th>Queued</th>
+                    <th>Priority</th>
+                </tr>
+                { queue.map(xfer => <TransferQueueRow key={xfer.DealID} xfer={xfer} />) }
+                </tbody>
+            </table>
+        )}
+    </div>
+}
+
+function TransferQueueRow(props) {
+    const xfer = props.xfer
+    const [priority, setPriority] = useState('')
+    const [setTransferPriority] = useMutation(TransferSetPriorityMutation, {
+        refetchQueries: [{ query: TransferQueueQuery }],
+    })
+
+    function updatePriority(value) {
+        setTransferPriority({ variables: { id: xfer.DealID, priority: value } })
+        setPriority('')
+    }
+
+    function onKeyDown(e) {
+        if (e.key !== 'Enter' || priority === '') {
+            return
+        }
+        const value = parseInt(priority, 10)
+        if (!isNaN(value)) {
+            updatePriority(value)
+        }
+    }
+
+    return <tr className={xfer.Urgent ? 'urgent' : ''}>
+        <td><Link to={'/deals/'+xfer.DealID}>{xfer.DealID.substring(0, 8)}…</Link></td>
+        <td>{xfer.Host}</td>
+        <td>{humanFileSize(Number(xfer.Size))}</td>
+        <td>{xfer.StartEpoch + ''}{xfer.Urgent ? ' (urgent)' : ''}</td>
+        <td>{moment(xfer.CreatedAt).fromNow()}</td>
+        <td className="priority">
+            <input type="number"
+                   placeholder={xfer.Priority + ''}
+                   value={priority}
+                   onChange={e => setPriority(e.target.value)}
+                   onKeyDown={onKeyDown} />
+            {xfer.PriorityOverridden ? (
+                <span className="reset" title="Reset to the priority calculated from the deal"
+                      onClick={() => updatePriority(null)}>reset</span>
+            ) : null}
+        </td>
+    </tr>
+}
+
 function getTransferRate(samples) {
     var dataRate = 0
     if (samples && samples.length) {
@@ -11,6 +11,7 @@ import {SettingsMenuItem} from "./Settings";
 import {InspectMenuItem} from "./Inspect";
 import {ProposalLogsMenuItem} from "./ProposalLogs";
 import {RetrievalLogsMenuItem} from "./RetrievalLogs";
+import {RetrievalPaymentsMenuItem} from "./RetrievalPayments";
 
 export function Menu(props) {
     function scrollToTop() {
@@ -22,6 +23,7 @@ export function Menu(props) {
             <StorageDealsMenuItem />
             <ProposalLogsMenuItem />
             <RetrievalLogsMenuItem />
+            <RetrievalPaymentsMenuItem />
             <StorageSpaceMenuItem />
             <SealingPipelineMenuItem />
             <FundsMenuItem />
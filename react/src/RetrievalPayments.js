@@ -0,0 +1,255 @@
+/* global BigInt */
+import {useQuery} from "@apollo/react-hooks";
+import {
+    RetrievalPaymentsListQuery, RetrievalPaymentTotalsQuery,
+} from "./gql";
+import moment from "moment";
+import React, {useState} from "react";
+import {PageContainer, ShortCID} from "./Components";
+import {Link, useNavigate, useParams} from "react-router-dom";
+import {dateFormat} from "./util-date";
+import {TimestampFormat} from "./timestamp";
+import './RetrievalPayments.css'
+import {Pagination} from "./Pagination";
+import {humanFIL, humanFileSize} from "./util";
+import receiptImg from "./bootstrap-icons/icons/receipt.svg";
+
+const basePath = '/retrieval-payments'
+
+export function RetrievalPaymentsPage(props) {
+    return <PageContainer pageType="retrieval-payments" title="Retrieval Payments">
+        <RetrievalPaymentTotals />
+        <RetrievalPaymentsContent />
+    </PageContainer>
+}
+
+function RetrievalPaymentTotals(props) {
+    const {loading, error, data} = useQuery(RetrievalPaymentTotalsQuery, {
+        pollInterval: 5000,
+        fetchPolicy: 'network-only',
+    })
+
+    if (error) return <div>Error: {error.message + " - check connection to Boost server"}</div>
+    if (loading) return <div>Loading...</div>
+
+    return <div className="retrieval-payment-totals">
+        <PaymentTotalsTable title="By Payer" keyName="Payer" totals={data.retrievalPaymentsByPayer} />
+        <PaymentTotalsTable title="By Piece" keyName="Piece CID" totals={data.retrievalPaymentsByPiece} isPiece={true} />
+    </div>
+}
+
+function PaymentTotalsTable(props) {
+    return <div className="payment-totals">
+        <h3>{props.title}</h3>
+        <table>
+            <tbody>
+            <tr>
+                <th>{props.keyName}</th>
+                <th>Payments</th>
+                <th>Amount</th>
+                <th>Sent</th>
+            </tr>
+            {props.totals.map(total => (
+                <tr key={total.Key}>
+                    <td className="key">
+                        {props.isPiece && total.Key ? (
+                            <Link to={'/inspect/'+total.Key}>
+                                <ShortCID cid={total.Key} />
+                            </Link>
+                        ) : (total.Key || '-')}
+                    </td>
+                    <td className="count">{total.Count}</td>
+                    <td className="amount">{humanFIL(total.Amount)}</td>
+                    <td className="sent">{humanFileSize(total.BytesSent)}</td>
+                </tr>
+            ))}
+            </tbody>
+        </table>
+    </div>
+}
+
+function RetrievalPaymentsContent(props) {
+    const navigate = useNavigate()
+    const params = useParams()
+    const pageNum = (params.pageNum && parseInt(params.pageNum)) || 1
+
+    const [timestampFormat, setTimestampFormat] = useState(TimestampFormat.load)
+    const saveTimestampFormat = (val) => {
+        TimestampFormat.save(val)
+        setTimestampFormat(val)
+    }
+
+    var [rowsPerPage, setRowsPerPage] = useState(RowsPerPage.load)
+    const onRowsPerPageChange = (e) => {
+        const val = parseInt(e.target.value)
+        RowsPerPage.save(val)
+        setRowsPerPage(val)
+        navigate(basePath)
+        scrollTop()
+    }
+
+    // Fetch payments on this page
+    const listOffset = (pageNum-1) * rowsPerPage
+    var queryCursor = null
+    if (pageNum > 1 && params.cursor) {
+        try {
+            queryCursor = BigInt(params.cursor)
+        } catch {}
+    }
+    const {loading, error, data} = useQuery(RetrievalPaymentsListQuery, {
+        pollInterval: 1000,
+        variables: {
+            cursor: queryCursor,
+            offset: listOffset,
+            limit: rowsPerPage,
+        },
+        fetchPolicy: 'network-only',
+    })
+
+    if (error) return <div>Error: {error.message + " - check connection to Boost server"}</div>
+    if (loading) return <div>Loading...</div>
+
+    var res = data.retrievalPayments
+    var payments = res.payments
+    if (pageNum === 1) {
+        payments.sort((a, b) => Number(b.RowID - a.RowID))
+        payments = payments.slice(0, rowsPerPage)
+    }
+    const totalCount = res.totalCount
+
+    var cursor = params.cursor
+    if (pageNum === 1 && payments.length) {
+        cursor = Number(payments[0].RowID)
+    }
+
+    var toggleTimestampFormat = () => saveTimestampFormat(!timestampFormat)
+
+    const paginationParams = {
+        basePath, cursor, pageNum, totalCount, rowsPerPage,
+        moreRows: res.more,
+        onRowsPerPageChange: onRowsPerPageChange,
+        onLinkClick: scrollTop,
+    }
+
+    return <div className="retrieval-payments">
+        <table>
+            <tbody>
+            <tr>
+                <th onClick={toggleTimestampFormat} className="start">Received</th>
+                <th>Payer</th>
+                <th>Channel</th>
+                <th>Transport</th>
+                <th>Payload CID</th>
+                <th>Piece CID</th>
+                <th>Amount</th>
+                <th>Sent</th>
+            </tr>
+
+            {payments.map(row => (
+                <TableRow
+                    key={row.RowID}
+                    row={row}
+                    timestampFormat={timestampFormat}
+                    toggleTimestampFormat={toggleTimestampFormat}
+                />
+            ))}
+            </tbody>
+        </table>
+
+        <Pagination {...paginationParams} />
+    </div>
+}
+
+function TableRow(props) {
+    var row = props.row
+    var start = moment(row.CreatedAt).format(dateFormat)
+    if (props.timestampFormat !== TimestampFormat.DateTime) {
+        start = '1m'
+        if (new Date().getTime() - row.CreatedAt.getTime() > 60 * 1000) {
+            start = moment(row.CreatedAt).fromNow()
+        }
+    }
+
+    return (
+        <tr>
+            <td className="start" onClick={props.toggleTimestampFormat}>
+                {start}
+            </td>
+            <td className="payer">
+                {row.Payer}
+            </td>
+            <td className="channel-id" title={row.ChannelID}>
+                <ShortCID cid={row.ChannelID} />
+            </td>
+            <td className="transport">
+                {row.Transport}
+            </td>
+            <td className="payload-cid">
+                {row.PayloadCID ? (
+                    <Link to={'/inspect/'+row.PayloadCID}>
+                        <ShortCID cid={row.PayloadCID} />
+                    </Link>
+                ) : '-'}
+            </td>
+            <td className="piece-cid">
+                {row.PieceCID ? (
+                    <Link to={'/inspect/'+row.PieceCID}>
+                        <ShortCID cid={row.PieceCID} />
+                    </Link>
+                ) : '-'}
+            </td>
+            <td className="amount" title={'Total paid on channel: ' + humanFIL(row.Total)}>
+                {humanFIL(row.Amount)}
+            </td>
+            <td className="sent">
+                {humanFileSize(row.BytesSent)}
+            </td>
+        </tr>
+    )
+}
+
+export function RetrievalPaymentsMenuItem(props) {
+    const {data} = useQuery(RetrievalPaymentsListQuery, {
+        pollInterval: 5000,
+        fetchPolicy: 'network-only',
+        variables: {
+            limit: 1,
+        }
+    })
+
+    var count = 0
+    if (data && data.retrievalPayments) {
+        count = data.retrievalPayments.totalCount
+    }
+
+    return (
+        <div className="menu-item" >
+            <img className="icon" alt="" src={receiptImg} />
+            <Link key="retrieval-payments" to={basePath}>
+                <h3>Retrieval Payments</h3>
+                <div className="menu-desc">
+                    <b>{count}</b> payments
+                </div>
+            </Link>
+        </div>
+    )
+}
+
+function scrollTop() {
+    window.scrollTo({ top: 0, behavior: "smooth" })
+}
+
+const RowsPerPage = {
+    Default: 10,
+
+    settingsKey: "settings.retrieval-payments.per-page",
+
+    load: () => {
+        const saved = localStorage.getItem(RowsPerPage.settingsKey)
+        return JSON.parse(saved) || RowsPerPage.Default
+    },
+
+    save: (val) => {
+        localStorage.setItem(RowsPerPage.settingsKey, JSON.stringify(val));
+    }
+}
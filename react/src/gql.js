@@ -294,6 +294,44 @@ const RetrievalLogsCountQuery = gql`
     }
 `;
 
+const RetrievalPaymentsListQuery = gql`
+    query AppRetrievalPaymentsListQuery($cursor: Uint64, $offset: Int, $limit: Int) {
+        retrievalPayments(cursor: $cursor, offset: $offset, limit: $limit) {
+            payments {
+                RowID
+                CreatedAt
+                ChannelID
+                Payer
+                Transport
+                Amount
+                Total
+                PayloadCID
+                PieceCID
+                BytesSent
+            }
+            totalCount
+            more
+        }
+    }
+`;
+
+const RetrievalPaymentTotalsQuery = gql`
+    query AppRetrievalPaymentTotalsQuery {
+        retrievalPaymentsByPayer {
+            Key
+            Amount
+            BytesSent
+            Count
+        }
+        retrievalPaymentsByPiece {
+            Key
+            Amount
+            BytesSent
+            Count
+        }
+    }
+`;
+
 
 const DealCancelMutation = gql`
     mutation AppDealCancelMutation($id: ID!) {
@@ -564,6 +602,27 @@ const TransferStatsQuery = gql`
     }
 `;
 
+const TransferQueueQuery = gql`
+    query AppTransferQueueQuery {
+        transferQueue {
+            DealID
+            Host
+            Size
+            StartEpoch
+            CreatedAt
+            Priority
+            PriorityOverridden
+            Urgent
+        }
+    }
+`;
+
+const TransferSetPriorityMutation = gql`
+    mutation AppTransferSetPriorityMutation($id: ID!, $priority: Int) {
+        transferSetPriority(id: $id, priority: $priority)
+    }
+`;
+
 const FundsLogsQuery = gql`
     query AppFundsLogsQuery($cursor: BigInt, $offset: Int, $limit: Int) {
         fundsLogs(cursor: $cursor, offset: $offset, limit: $limit) {
@@ -681,6 +740,8 @@ export {
     RetrievalLogQuery,
     RetrievalLogsListQuery,
     RetrievalLogsCountQuery,
+    RetrievalPaymentsListQuery,
+    RetrievalPaymentTotalsQuery,
     PiecesWithRootPayloadCidQuery,
     PiecesWithPayloadCidQuery,
     PieceStatusQuery,
@@ -694,6 +755,8 @@ export {
     StorageAskUpdate,
     TransfersQuery,
     TransferStatsQuery,
+    TransferQueueQuery,
+    TransferSetPriorityMutation,
     MpoolQuery,
     MpoolAlertsQuery,
     SealingPipelineQuery,
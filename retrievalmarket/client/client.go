@@ -14,6 +14,7 @@ import (
 	gsimpl "github.com/filecoin-project/boost-graphsync/impl"
 	gsnet "github.com/filecoin-project/boost-graphsync/network"
 	"github.com/filecoin-project/boost-graphsync/storeutil"
+	boosttypes "github.com/filecoin-project/boost/retrievalmarket/types"
 	"github.com/filecoin-project/go-address"
 	cborutil "github.com/filecoin-project/go-cbor-util"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
@@ -22,6 +23,7 @@ import (
 	dtnet "github.com/filecoin-project/go-data-transfer/network"
 	gst "github.com/filecoin-project/go-data-transfer/transport/graphsync"
 	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/chain/wallet"
@@ -36,6 +38,8 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/multiformats/go-multiaddr"
+	"github.com/statechannels/go-nitro/payments"
+	nitrotypes "github.com/statechannels/go-nitro/types"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
@@ -148,6 +152,16 @@ func NewClientWithConfig(cfg *Config) (*Client, error) {
 		return nil, err
 	}
 
+	err = mgr.RegisterVoucherType(&boosttypes.NitroDealProposal{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = mgr.RegisterVoucherType(&boosttypes.NitroPaymentVoucher{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	err = mgr.RegisterVoucherResultType(&retrievalmarket.DealResponse{})
 	if err != nil {
 		return nil, err
@@ -157,6 +171,9 @@ func NewClientWithConfig(cfg *Config) (*Client, error) {
 		if err := mgr.RegisterTransportConfigurer(&retrievalmarket.DealProposal{}, cfg.RetrievalConfigurer); err != nil {
 			return nil, err
 		}
+		if err := mgr.RegisterTransportConfigurer(&boosttypes.NitroDealProposal{}, cfg.RetrievalConfigurer); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := mgr.Start(context.Background()); err != nil {
@@ -355,7 +372,43 @@ func (c *Client) RetrieveContentWithProgressCallback(
 	if err != nil {
 		return nil, err
 	}
-	return c.retrieveContentFromPeerWithProgressCallback(ctx, minerPeer.ID, minerOwnerWallet, proposal, progressCallback, nil)
+	return c.retrieveContentFromPeerWithProgressCallback(ctx, minerPeer.ID, minerOwnerWallet, proposal, progressCallback, nil, nil)
+}
+
+// NitroPayer creates nitro payment vouchers (eg the nitro RPC client)
+type NitroPayer interface {
+	CreateVoucher(chId nitrotypes.Destination, amount uint64) (payments.Voucher, error)
+}
+
+// nitroPayment pays for a retrieval from a nitro payment channel
+type nitroPayment struct {
+	payer     NitroPayer
+	channelID nitrotypes.Destination
+}
+
+// RetrieveContentWithNitro retrieves content from the miner, paying for the
+// data with vouchers from the given nitro payment channel
+func (c *Client) RetrieveContentWithNitro(
+	ctx context.Context,
+	miner address.Address,
+	proposal *retrievalmarket.DealProposal,
+	payer NitroPayer,
+	channelID nitrotypes.Destination,
+	progressCallback func(bytesReceived uint64),
+) (*RetrievalStats, error) {
+
+	log.Infof("Starting nitro paid retrieval with miner: %s", miner)
+
+	minerPeer, err := c.MinerPeer(ctx, miner)
+	if err != nil {
+		return nil, err
+	}
+	minerOwnerWallet, err := c.minerOwner(ctx, miner)
+	if err != nil {
+		return nil, err
+	}
+	nitro := &nitroPayment{payer: payer, channelID: channelID}
+	return c.retrieveContentFromPeerWithProgressCallback(ctx, minerPeer.ID, minerOwnerWallet, proposal, progressCallback, nil, nitro)
 }
 
 func (c *Client) retrieveContentFromPeerWithProgressCallback(
@@ -365,6 +418,7 @@ func (c *Client) retrieveContentFromPeerWithProgressCallback(
 	proposal *retrievalmarket.DealProposal,
 	progressCallback func(bytesReceived uint64),
 	gracefulShutdownRequested <-chan struct{},
+	nitro *nitroPayment,
 ) (*RetrievalStats, error) {
 	if progressCallback == nil {
 		progressCallback = func(bytesReceived uint64) {}
@@ -384,10 +438,18 @@ func (c *Client) retrieveContentFromPeerWithProgressCallback(
 	var chanidLk sync.Mutex
 
 	pchRequired := !proposal.PricePerByte.IsZero() || !proposal.UnsealPrice.IsZero()
-	if pchRequired {
+	if pchRequired && nitro == nil {
 		return nil, errors.New("payment channel required, boost doesn't support these retrievals")
 	}
 
+	// For retrievals paid with nitro, the proposal includes the nitro
+	// payment channel that the client will pay from
+	var voucher datatransfer.Voucher = proposal
+	if nitro != nil {
+		voucher = &boosttypes.NitroDealProposal{Proposal: *proposal, ChannelID: nitro.channelID[:]}
+	}
+	var paymentLk sync.Mutex
+
 	// Set up incoming events handler
 
 	// The next nonce (incrementing unique ID starting from 0) for the next voucher
@@ -454,7 +516,23 @@ func (c *Client) retrieveContentFromPeerWithProgressCallback(
 
 				// Respond with a payment voucher when funds are requested
 				case retrievalmarket.DealStatusFundsNeeded, retrievalmarket.DealStatusFundsNeededLastPayment:
-					if pchRequired {
+					if nitro != nil {
+						if resType.PaymentOwed.IsZero() {
+							finish(fmt.Errorf("payment rejected: %s", resType.Message))
+							return
+						}
+						owed := resType.PaymentOwed
+						go func() {
+							if err := c.sendNitroPayment(ctx, chanidCopy, nitro, owed); err != nil {
+								finish(err)
+								return
+							}
+							paymentLk.Lock()
+							totalPayment = big.Add(totalPayment, owed)
+							nonce++
+							paymentLk.Unlock()
+						}()
+					} else if pchRequired {
 						finish(errors.New("payment channel required"))
 						return
 					} else {
@@ -536,7 +614,7 @@ func (c *Client) retrieveContentFromPeerWithProgressCallback(
 	defer unsubscribe()
 
 	// Submit the retrieval deal proposal to the miner
-	newchid, err := c.dataTransfer.OpenPullDataChannel(ctx, peerID, proposal, proposal.PayloadCID, selectorparse.CommonSelector_ExploreAllRecursively)
+	newchid, err := c.dataTransfer.OpenPullDataChannel(ctx, peerID, voucher, proposal.PayloadCID, selectorparse.CommonSelector_ExploreAllRecursively)
 	if err != nil {
 		// We could fail before a successful proposal
 		// publish event failure
@@ -591,6 +669,8 @@ awaitfinished:
 	duration := time.Since(startTime)
 	speed := uint64(float64(state.Received()) / duration.Seconds())
 
+	paymentLk.Lock()
+	defer paymentLk.Unlock()
 	return &RetrievalStats{
 		Peer:         state.OtherPeer(),
 		Size:         state.Received(),
@@ -602,6 +682,25 @@ awaitfinished:
 	}, nil
 }
 
+// sendNitroPayment creates a voucher for the amount owed and sends it to
+// the provider
+func (c *Client) sendNitroPayment(ctx context.Context, chid datatransfer.ChannelID, nitro *nitroPayment, owed abi.TokenAmount) error {
+	if !owed.IsUint64() {
+		return fmt.Errorf("payment of %s is too large for a nitro voucher", owed)
+	}
+
+	v, err := nitro.payer.CreateVoucher(nitro.channelID, owed.Uint64())
+	if err != nil {
+		return fmt.Errorf("creating nitro voucher for %s on channel %s: %w", owed, nitro.channelID, err)
+	}
+
+	log.Debugw("sending nitro payment", "channel", nitro.channelID, "amount", owed, "total", v.Amount)
+	if err := c.dataTransfer.SendVoucher(ctx, chid, boosttypes.NewNitroPaymentVoucher(v)); err != nil {
+		return fmt.Errorf("sending nitro payment voucher: %w", err)
+	}
+	return nil
+}
+
 func RetrievalProposalForAsk(ask *retrievalmarket.QueryResponse, c cid.Cid, optionalSelector ipld.Node) (*retrievalmarket.DealProposal, error) {
 	if optionalSelector == nil {
 		optionalSelector = selectorparse.CommonSelector_ExploreAllRecursively
@@ -0,0 +1,154 @@
+package lp2pimpl
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/boost-gfm/shared"
+	"github.com/filecoin-project/boost/retrievalmarket/types"
+	"github.com/filecoin-project/go-state-types/abi"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/statechannels/go-nitro/payments"
+)
+
+var bplog = logging.Logger("boost:lp2p:bitswap:payment")
+
+// BitswapPaymentProtocolID is the protocol for paying for bitswap retrievals
+// with nitro vouchers. The client sends a NitroPaymentVoucher and the
+// provider responds with a BitswapPaymentResponse.
+const BitswapPaymentProtocolID = protocol.ID("/boost/bitswap/nitro-payment/1.0.0")
+
+// BitswapPaymentHandler redeems a voucher sent by a peer and credits the
+// peer's balance with the payment
+type BitswapPaymentHandler interface {
+	// ReceivePayment returns the peer's balance after the payment
+	ReceivePayment(p peer.ID, v payments.Voucher) (abi.TokenAmount, error)
+}
+
+// BitswapPaymentListener listens for payment vouchers over libp2p
+type BitswapPaymentListener struct {
+	host    host.Host
+	handler BitswapPaymentHandler
+}
+
+func NewBitswapPaymentListener(h host.Host, handler BitswapPaymentHandler) *BitswapPaymentListener {
+	return &BitswapPaymentListener{
+		host:    h,
+		handler: handler,
+	}
+}
+
+func (l *BitswapPaymentListener) Start() {
+	l.host.SetStreamHandler(BitswapPaymentProtocolID, l.handleNewPaymentStream)
+}
+
+func (l *BitswapPaymentListener) Stop() {
+	l.host.RemoveStreamHandler(BitswapPaymentProtocolID)
+}
+
+// Called when the client opens a libp2p stream
+func (l *BitswapPaymentListener) handleNewPaymentStream(s network.Stream) {
+	defer s.Close()
+
+	p := s.Conn().RemotePeer()
+	bplog.Debugw("payment", "peer", p)
+
+	// Set a deadline on reading from the stream so it doesn't hang
+	_ = s.SetReadDeadline(time.Now().Add(streamReadDeadline))
+	var pv types.NitroPaymentVoucher
+	err := pv.UnmarshalCBOR(bufio.NewReader(s))
+	_ = s.SetReadDeadline(time.Time{}) // Clear read deadline so conn doesn't get closed
+	if err != nil {
+		bplog.Infow("error reading payment voucher", "peer", p, "err", err)
+		return
+	}
+
+	resp := l.receivePayment(p, &pv)
+
+	// Set a deadline on writing to the stream so it doesn't hang
+	_ = s.SetWriteDeadline(time.Now().Add(streamWriteDeadline))
+	defer s.SetWriteDeadline(time.Time{}) // nolint
+
+	// Write the response to the client
+	if err := resp.MarshalCBOR(s); err != nil {
+		bplog.Infow("error writing payment response", "peer", p, "err", err)
+	}
+}
+
+func (l *BitswapPaymentListener) receivePayment(p peer.ID, pv *types.NitroPaymentVoucher) *types.BitswapPaymentResponse {
+	v, err := pv.NitroVoucher()
+	if err != nil {
+		return &types.BitswapPaymentResponse{
+			Balance: abi.NewTokenAmount(0),
+			Message: fmt.Sprintf("invalid payment voucher: %s", err),
+		}
+	}
+
+	balance, err := l.handler.ReceivePayment(p, v)
+	if err != nil {
+		bplog.Infow("rejected payment voucher", "peer", p, "err", err)
+		return &types.BitswapPaymentResponse{
+			Balance: abi.NewTokenAmount(0),
+			Message: err.Error(),
+		}
+	}
+
+	bplog.Debugw("accepted payment voucher", "peer", p, "balance", balance)
+	return &types.BitswapPaymentResponse{
+		Accepted: true,
+		Balance:  balance,
+	}
+}
+
+// BitswapPaymentClient sends payment vouchers for bitswap retrievals over
+// libp2p
+type BitswapPaymentClient struct {
+	retryStream *shared.RetryStream
+}
+
+func NewBitswapPaymentClient(h host.Host) *BitswapPaymentClient {
+	return &BitswapPaymentClient{
+		retryStream: shared.NewRetryStream(h),
+	}
+}
+
+// SendPayment sends a payment voucher over a libp2p stream to the peer
+func (c *BitswapPaymentClient) SendPayment(ctx context.Context, id peer.ID, v payments.Voucher) (*types.BitswapPaymentResponse, error) {
+	clog.Debugw("bitswap payment", "peer", id, "amount", v.Amount)
+
+	// Create a libp2p stream to the provider
+	s, err := c.retryStream.OpenStream(ctx, id, []protocol.ID{BitswapPaymentProtocolID})
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.Close() // nolint
+
+	// Set a deadline on writing to the stream so it doesn't hang
+	_ = s.SetWriteDeadline(time.Now().Add(streamWriteDeadline))
+	err = types.NewNitroPaymentVoucher(v).MarshalCBOR(s)
+	_ = s.SetWriteDeadline(time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("sending payment voucher: %w", err)
+	}
+
+	// Set a deadline on reading from the stream so it doesn't hang
+	_ = s.SetReadDeadline(time.Now().Add(streamReadDeadline))
+	defer s.SetReadDeadline(time.Time{}) // nolint
+
+	// Read the response from the stream
+	var resp types.BitswapPaymentResponse
+	if err := resp.UnmarshalCBOR(s); err != nil {
+		return nil, fmt.Errorf("reading payment response: %w", err)
+	}
+
+	clog.Debugw("bitswap payment response", "peer", id, "accepted", resp.Accepted, "balance", resp.Balance)
+
+	return &resp, nil
+}
@@ -43,3 +43,19 @@ CREATE TABLE IF NOT EXISTS RetrievalMarketEvents (
 
 CREATE INDEX IF NOT EXISTS index_retrieval_market_evts_created_at on RetrievalMarketEvents(CreatedAt);
 CREATE INDEX IF NOT EXISTS index_retrieval_market_evts_peer_deal_id on RetrievalMarketEvents(PeerID, DealID);
+
+CREATE TABLE IF NOT EXISTS RetrievalPayments (
+    CreatedAt DateTime,
+    ChannelID TEXT,
+    Payer TEXT,
+    Transport TEXT,
+    Amount TEXT,
+    Total TEXT,
+    PayloadCID TEXT,
+    PieceCID TEXT,
+    BytesSent INT
+);
+
+CREATE INDEX IF NOT EXISTS index_retrieval_payments_created_at on RetrievalPayments(CreatedAt);
+CREATE INDEX IF NOT EXISTS index_retrieval_payments_payer on RetrievalPayments(Payer);
+CREATE INDEX IF NOT EXISTS index_retrieval_payments_piece_cid on RetrievalPayments(PieceCID);
@@ -0,0 +1,274 @@
+package rtvllog
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+)
+
+// RetrievalPayment is a nitro payment voucher that was accepted as payment
+// for a retrieval
+type RetrievalPayment struct {
+	RowID     uint64
+	CreatedAt time.Time
+	// The nitro payment channel that the payment was made on
+	ChannelID string
+	// The address of the payer (eg the signer of the nitro voucher)
+	Payer string
+	// The transport the content was retrieved over (eg "http")
+	Transport string
+	// The amount the voucher paid
+	Amount abi.TokenAmount
+	// The total amount paid on the channel, including this voucher
+	Total abi.TokenAmount
+	// The root CID of the content that was paid for
+	PayloadCID cid.Cid
+	// The piece containing the content (if known)
+	PieceCID *cid.Cid
+	// The number of bytes sent in the response that the voucher paid for
+	BytesSent uint64
+}
+
+func (d *RetrievalLogDB) InsertPayment(ctx context.Context, p *RetrievalPayment) error {
+	qry := "INSERT INTO RetrievalPayments (" +
+		"CreatedAt, " +
+		"ChannelID, " +
+		"Payer, " +
+		"Transport, " +
+		"Amount, " +
+		"Total, " +
+		"PayloadCID, " +
+		"PieceCID, " +
+		"BytesSent" +
+		") "
+	qry += "VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+	payloadCid := ""
+	if p.PayloadCID.Defined() {
+		payloadCid = p.PayloadCID.String()
+	}
+	pieceCid := ""
+	if p.PieceCID != nil {
+		pieceCid = p.PieceCID.String()
+	}
+	total := big.Zero()
+	if p.Total.Int != nil {
+		total = p.Total
+	}
+
+	createdAt := p.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	_, err := d.db.ExecContext(ctx, qry,
+		createdAt,
+		p.ChannelID,
+		p.Payer,
+		p.Transport,
+		p.Amount.String(),
+		total.String(),
+		payloadCid,
+		pieceCid,
+		p.BytesSent)
+	return err
+}
+
+func (d *RetrievalLogDB) ListPayments(ctx context.Context, cursor *uint64, offset int, limit int) ([]RetrievalPayment, error) {
+	where := ""
+	whereArgs := []interface{}{}
+	if cursor != nil {
+		where += "RowID <= ?"
+		whereArgs = append(whereArgs, *cursor)
+	}
+	return d.listPayments(ctx, offset, limit, where, whereArgs...)
+}
+
+func (d *RetrievalLogDB) listPayments(ctx context.Context, offset int, limit int, where string, whereArgs ...interface{}) ([]RetrievalPayment, error) {
+	qry := "SELECT " +
+		"RowID, " +
+		"CreatedAt, " +
+		"ChannelID, " +
+		"Payer, " +
+		"Transport, " +
+		"Amount, " +
+		"Total, " +
+		"PayloadCID, " +
+		"PieceCID, " +
+		"BytesSent " +
+		"FROM RetrievalPayments"
+
+	if where != "" {
+		qry += " WHERE " + where
+	}
+	qry += " ORDER BY RowID desc"
+
+	args := append([]interface{}{}, whereArgs...)
+	if limit > 0 {
+		qry += " LIMIT ?"
+		args = append(args, limit)
+
+		if offset > 0 {
+			qry += " OFFSET ?"
+			args = append(args, offset)
+		}
+	}
+
+	rows, err := d.db.QueryContext(ctx, qry, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := make([]RetrievalPayment, 0, 16)
+	for rows.Next() {
+		var amount sql.NullString
+		var total sql.NullString
+		var payloadCid sql.NullString
+		var pieceCid sql.NullString
+
+		var p RetrievalPayment
+		err := rows.Scan(
+			&p.RowID,
+			&p.CreatedAt,
+			&p.ChannelID,
+			&p.Payer,
+			&p.Transport,
+			&amount,
+			&total,
+			&payloadCid,
+			&pieceCid,
+			&p.BytesSent,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		p.Amount, err = parseTokenAmount(amount.String)
+		if err != nil {
+			return nil, fmt.Errorf("parsing payment amount '%s': %w", amount.String, err)
+		}
+		p.Total, err = parseTokenAmount(total.String)
+		if err != nil {
+			return nil, fmt.Errorf("parsing payment total '%s': %w", total.String, err)
+		}
+
+		p.PayloadCID, err = cid.Parse(payloadCid.String)
+		if err != nil {
+			p.PayloadCID = cid.Undef
+		}
+
+		if pieceCid.Valid && pieceCid.String != "" {
+			c, err := cid.Parse(pieceCid.String)
+			if err != nil {
+				return nil, fmt.Errorf("parsing piece cid '%s': %w", pieceCid.String, err)
+			}
+			p.PieceCID = &c
+		}
+
+		payments = append(payments, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return payments, nil
+}
+
+func (d *RetrievalLogDB) PaymentsCount(ctx context.Context) (int, error) {
+	var count int
+	qry := "SELECT count(*) FROM RetrievalPayments"
+	row := d.db.QueryRowContext(ctx, qry)
+	err := row.Scan(&count)
+	return count, err
+}
+
+// PaymentTotal is the sum of the payments grouped by a key (eg payer)
+type PaymentTotal struct {
+	Key       string
+	Amount    abi.TokenAmount
+	BytesSent uint64
+	Count     int
+}
+
+// PaymentTotalsByPayer returns the total amount paid by each payer,
+// ordered by amount (highest first)
+func (d *RetrievalLogDB) PaymentTotalsByPayer(ctx context.Context) ([]PaymentTotal, error) {
+	return d.paymentTotals(ctx, "Payer")
+}
+
+// PaymentTotalsByPiece returns the total amount paid for content in each
+// piece, ordered by amount (highest first)
+func (d *RetrievalLogDB) PaymentTotalsByPiece(ctx context.Context) ([]PaymentTotal, error) {
+	return d.paymentTotals(ctx, "PieceCID")
+}
+
+// Amounts are stored as strings because they may overflow an int64. So that
+// they can be summed in the query, each amount is split into chunks of
+// amountChunkDigits decimal digits, the chunks are summed separately, and the
+// sums of the chunks are combined into the total.
+const amountChunkDigits = 9
+
+var amountChunkSums = fmt.Sprintf(
+	"SUM(CAST(substr(Amount, 1, length(Amount)-%[2]d) AS INTEGER)), "+
+		"SUM(CAST(substr(Amount, -%[2]d, %[1]d) AS INTEGER)), "+
+		"SUM(CAST(substr(Amount, -%[1]d, %[1]d) AS INTEGER))",
+	amountChunkDigits, 2*amountChunkDigits)
+
+func (d *RetrievalLogDB) paymentTotals(ctx context.Context, groupBy string) ([]PaymentTotal, error) {
+	qry := "SELECT " + groupBy + ", " + amountChunkSums + ", " +
+		"COALESCE(SUM(BytesSent), 0), " +
+		"count(*) " +
+		"FROM RetrievalPayments GROUP BY " + groupBy
+	rows, err := d.db.QueryContext(ctx, qry)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chunkBase := big.NewInt(1)
+	for i := 0; i < amountChunkDigits; i++ {
+		chunkBase = big.Mul(chunkBase, big.NewInt(10))
+	}
+
+	res := make([]PaymentTotal, 0, 16)
+	for rows.Next() {
+		var key sql.NullString
+		var chunks [3]int64
+		var total PaymentTotal
+		if err := rows.Scan(&key, &chunks[0], &chunks[1], &chunks[2], &total.BytesSent, &total.Count); err != nil {
+			return nil, err
+		}
+
+		total.Key = key.String
+		total.Amount = big.Zero()
+		for _, chunk := range chunks {
+			total.Amount = big.Add(big.Mul(total.Amount, chunkBase), big.NewInt(chunk))
+		}
+		res = append(res, total)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if cmp := big.Cmp(res[i].Amount, res[j].Amount); cmp != 0 {
+			return cmp > 0
+		}
+		return res[i].Key < res[j].Key
+	})
+	return res, nil
+}
+
+func parseTokenAmount(s string) (abi.TokenAmount, error) {
+	if s == "" {
+		return big.Zero(), nil
+	}
+	return big.FromString(s)
+}
@@ -0,0 +1,118 @@
+package rtvllog
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetrievalPayments(t *testing.T) {
+	ctx := context.Background()
+
+	sqldb := db.CreateTestTmpDB(t)
+	require.NoError(t, CreateTables(ctx, sqldb))
+	ldb := NewRetrievalLogDB(sqldb)
+
+	payload := testCid(t, "payload")
+	pieceA := testCid(t, "piece-a")
+	pieceB := testCid(t, "piece-b")
+
+	payments := []RetrievalPayment{{
+		ChannelID:  "0x01",
+		Payer:      "0xaa",
+		Transport:  "http",
+		Amount:     abi.NewTokenAmount(100),
+		Total:      abi.NewTokenAmount(100),
+		PayloadCID: payload,
+		PieceCID:   &pieceA,
+		BytesSent:  100,
+	}, {
+		ChannelID:  "0x01",
+		Payer:      "0xaa",
+		Transport:  "http",
+		Amount:     abi.NewTokenAmount(50),
+		Total:      abi.NewTokenAmount(150),
+		PayloadCID: payload,
+		PieceCID:   &pieceB,
+		BytesSent:  50,
+	}, {
+		ChannelID:  "0x02",
+		Payer:      "0xbb",
+		Transport:  "http",
+		Amount:     abi.NewTokenAmount(300),
+		Total:      abi.NewTokenAmount(300),
+		PayloadCID: payload,
+		PieceCID:   &pieceA,
+	}}
+	for i := range payments {
+		require.NoError(t, ldb.InsertPayment(ctx, &payments[i]))
+	}
+
+	count, err := ldb.PaymentsCount(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+
+	// Payments are listed newest first
+	list, err := ldb.ListPayments(ctx, nil, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	require.Equal(t, "0x02", list[0].ChannelID)
+	require.Equal(t, abi.NewTokenAmount(300), list[0].Amount)
+	require.Equal(t, payload, list[0].PayloadCID)
+	require.Equal(t, pieceA, *list[0].PieceCID)
+	require.Equal(t, abi.NewTokenAmount(150), list[1].Total)
+	require.EqualValues(t, 50, list[1].BytesSent)
+
+	// Paginate with a cursor
+	cursor := list[1].RowID
+	list, err = ldb.ListPayments(ctx, &cursor, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, abi.NewTokenAmount(100), list[0].Amount)
+
+	byPayer, err := ldb.PaymentTotalsByPayer(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []PaymentTotal{
+		{Key: "0xbb", Amount: abi.NewTokenAmount(300), BytesSent: 0, Count: 1},
+		{Key: "0xaa", Amount: abi.NewTokenAmount(150), BytesSent: 150, Count: 2},
+	}, byPayer)
+
+	// Amounts that overflow an int64 are summed exactly
+	large, err := big.FromString("123456789012345678901234567")
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, ldb.InsertPayment(ctx, &RetrievalPayment{
+			ChannelID: "0x03",
+			Payer:     "0xcc",
+			Transport: "http",
+			Amount:    large,
+			Total:     large,
+		}))
+	}
+	byPayer, err = ldb.PaymentTotalsByPayer(ctx)
+	require.NoError(t, err)
+	require.Len(t, byPayer, 3)
+	require.Equal(t, "0xcc", byPayer[0].Key)
+	require.Equal(t, big.Mul(large, big.NewInt(2)), byPayer[0].Amount)
+	require.Equal(t, 2, byPayer[0].Count)
+
+	byPiece, err := ldb.PaymentTotalsByPiece(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []PaymentTotal{
+		{Key: "", Amount: big.Mul(large, big.NewInt(2)), BytesSent: 0, Count: 2},
+		{Key: pieceA.String(), Amount: abi.NewTokenAmount(400), BytesSent: 100, Count: 2},
+		{Key: pieceB.String(), Amount: abi.NewTokenAmount(50), BytesSent: 50, Count: 1},
+	}, byPiece)
+}
+
+func testCid(t *testing.T, seed string) cid.Cid {
+	mh, err := multihash.Sum([]byte(seed), multihash.SHA2_256, -1)
+	require.NoError(t, err)
+	return cid.NewCidV1(cid.Raw, mh)
+}
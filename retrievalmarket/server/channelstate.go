@@ -18,12 +18,15 @@ type RetrievalType string
 
 const RetrievalTypeDeal RetrievalType = "Deal"
 const RetrievalTypeLegs RetrievalType = "Legs"
+const RetrievalTypeNitro RetrievalType = "Nitro"
 
 type retrievalState struct {
 	retType RetrievalType
 	cs      *channelState
 	mkts    *retrievalmarket.ProviderDealState
 	gsReq   graphsync.RequestID
+	// payment is set for retrievals that are paid for with nitro
+	payment *nitroPayment
 }
 
 func (r retrievalState) ChannelState() channelState                           { return *r.cs }
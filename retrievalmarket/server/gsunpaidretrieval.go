@@ -86,6 +86,9 @@ type ValidationDeps struct {
 	PieceStore     piecestore.PieceStore
 	SectorAccessor retrievalmarket.SectorAccessor
 	AskStore       AskGetter
+	// PaymentReceiver is used to redeem payment vouchers for retrievals
+	// paid with nitro. If it is nil, nitro paid retrievals are not accepted.
+	PaymentReceiver PaymentReceiver
 }
 
 func NewGraphsyncUnpaidRetrieval(peerID peer.ID, gs graphsync.GraphExchange, dtnet network.DataTransferNetwork, vdeps ValidationDeps, ls LinkSystemProvider) (*GraphsyncUnpaidRetrieval, error) {
@@ -102,6 +105,14 @@ func NewGraphsyncUnpaidRetrieval(peerID peer.ID, gs graphsync.GraphExchange, dtn
 	if err != nil {
 		return nil, err
 	}
+	err = typeRegistry.Register(&types.NitroDealProposal{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = typeRegistry.Register(&types.NitroPaymentVoucher{}, nil)
+	if err != nil {
+		return nil, err
+	}
 
 	return &GraphsyncUnpaidRetrieval{
 		GraphExchange:    gs,
@@ -289,26 +300,44 @@ func (g *GraphsyncUnpaidRetrieval) interceptRetrieval(p peer.ID, request graphsy
 			PayloadCID: request.Root(),
 			Params:     params,
 		}
-		return g.handleRetrievalDeal(p, msg, proposal, request, RetrievalTypeLegs)
+		return g.handleRetrievalDeal(p, msg, proposal, request, RetrievalTypeLegs, nil)
 	case *retrievalmarket.DealProposal:
 		// This is a retrieval deal
 		proposal := *v
-		return g.handleRetrievalDeal(p, msg, proposal, request, RetrievalTypeDeal)
+		return g.handleRetrievalDeal(p, msg, proposal, request, RetrievalTypeDeal, nil)
 	case *migrations.DealProposal0:
 		// This is a retrieval deal with an older format
 		proposal := migrations.MigrateDealProposal0To1(*v)
-		return g.handleRetrievalDeal(p, msg, proposal, request, RetrievalTypeDeal)
+		return g.handleRetrievalDeal(p, msg, proposal, request, RetrievalTypeDeal, nil)
+	case *types.NitroDealProposal:
+		// This is a retrieval deal paid for with a nitro payment channel.
+		// If nitro payments are not enabled, pass it through to the legacy
+		// code (which will reject it).
+		if g.validator.PaymentReceiver == nil {
+			return false, nil
+		}
+		return g.handleRetrievalDeal(p, msg, v.Proposal, request, RetrievalTypeNitro, newNitroPayment(v))
 	}
 
 	return false, nil
 }
 
-func (g *GraphsyncUnpaidRetrieval) handleRetrievalDeal(peerID peer.ID, msg datatransfer.Message, proposal retrievalmarket.DealProposal, request graphsync.RequestData, retType RetrievalType) (bool, error) {
-	// If it's a paid retrieval, do not intercept it
-	if !proposal.UnsealPrice.IsZero() || !proposal.PricePerByte.IsZero() {
+func (g *GraphsyncUnpaidRetrieval) handleRetrievalDeal(peerID peer.ID, msg datatransfer.Message, proposal retrievalmarket.DealProposal, request graphsync.RequestData, retType RetrievalType, payment *nitroPayment) (bool, error) {
+	// If it's a paid retrieval that is not paid with nitro, do not intercept it
+	isPaid := !proposal.UnsealPrice.IsZero() || !proposal.PricePerByte.IsZero()
+	if isPaid && payment == nil {
 		return false, nil
 	}
 
+	// If a nitro paid retrieval is restarted, keep the payments that the
+	// client has already made
+	if payment != nil && msg.IsRestart() {
+		if existing, ok := g.isActiveUnpaidRetrieval(reqId{p: peerID, id: msg.TransferID()}); ok && existing.payment != nil {
+			existing.payment.restart()
+			payment = existing.payment
+		}
+	}
+
 	// It's for an unpaid retrieval. Initialize the channel state.
 	selBytes, err := encoding.Encode(request.Selector())
 	if err != nil {
@@ -337,6 +366,7 @@ func (g *GraphsyncUnpaidRetrieval) handleRetrievalDeal(peerID peer.ID, msg datat
 		cs:      cs,
 		mkts:    mktsState,
 		gsReq:   request.ID(),
+		payment: payment,
 	}
 
 	// Record the data transfer ID so that we can intercept future
@@ -368,9 +398,12 @@ func (g *GraphsyncUnpaidRetrieval) RegisterIncomingRequestHook(hook graphsync.On
 		stats.Record(g.ctx, metrics.GraphsyncRequestStartedUnpaidCount.M(1))
 
 		var dtOpenMsg string
-		if state.retType == RetrievalTypeLegs {
+		switch state.retType {
+		case RetrievalTypeLegs:
 			dtOpenMsg = "request from network indexer"
-		} else {
+		case RetrievalTypeNitro:
+			dtOpenMsg = "retrieval paid with nitro"
+		default:
 			dtOpenMsg = "unpaid retrieval"
 		}
 		if msg.IsRestart() {
@@ -400,7 +433,9 @@ func (g *GraphsyncUnpaidRetrieval) RegisterIncomingRequestHook(hook graphsync.On
 				res, validateErr = g.validator.validatePullRequest(msg.IsRestart(), p, voucher, request.Root(), request.Selector())
 			}
 			isAccepted := validateErr == nil
-			const isPaused = false // There are no payments required, so never pause
+			// Retrievals paid with nitro are paused by the outgoing block
+			// hook when the client's credit runs out, so never pause here
+			const isPaused = false
 			resultType := datatransfer.EmptyTypeIdentifier
 			if res != nil {
 				resultType = res.Type()
@@ -464,6 +499,16 @@ func (g *GraphsyncUnpaidRetrieval) RegisterOutgoingBlockHook(hook graphsync.OnOu
 		if g.outgoingBlockHook != nil {
 			g.outgoingBlockHook(state)
 		}
+
+		// If the retrieval is paid with nitro, pause the response when the
+		// client has used up its credit, and ask for the next payment
+		if state.payment != nil {
+			pause, owed := state.payment.queueBlock(block.BlockSizeOnWire())
+			if pause {
+				hookActions.PauseResponse()
+				g.requestPayment(p, state, owed)
+			}
+		}
 	})
 }
 
@@ -509,7 +554,7 @@ func (g *GraphsyncUnpaidRetrieval) RegisterCompletedResponseListener(listener gr
 		// Include a markets protocol Completed message in the response
 		var voucherResult encoding.Encodable
 		var voucherType datatransfer.TypeIdentifier
-		if state.retType == RetrievalTypeDeal {
+		if state.retType != RetrievalTypeLegs {
 			dealResponse := &retrievalmarket.DealResponse{
 				ID:     state.mkts.DealProposal.ID,
 				Status: retrievalmarket.DealStatusCompleted,
@@ -597,6 +642,9 @@ func (g *GraphsyncUnpaidRetrieval) RegisterBlockSentListener(listener graphsync.
 
 		// Fire block sent event
 		state.cs.sent += block.BlockSizeOnWire()
+		if state.payment != nil {
+			state.payment.blockSent(block.BlockSizeOnWire())
+		}
 		g.publishDTEvent(datatransfer.DataSent, "", state.cs)
 		state.mkts.TotalSent += block.BlockSizeOnWire()
 
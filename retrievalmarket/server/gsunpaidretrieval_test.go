@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -21,10 +23,14 @@ import (
 	graphsyncimpl "github.com/filecoin-project/boost-graphsync/impl"
 	"github.com/filecoin-project/boost-graphsync/network"
 	"github.com/filecoin-project/boost-graphsync/storeutil"
+	"github.com/filecoin-project/boost/retrievalmarket/types"
 	boosttu "github.com/filecoin-project/boost/testutil"
 	"github.com/filecoin-project/go-address"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
+	"github.com/filecoin-project/go-data-transfer/encoding"
 	dtimpl "github.com/filecoin-project/go-data-transfer/impl"
+	"github.com/filecoin-project/go-data-transfer/message"
+	dtnetwork "github.com/filecoin-project/go-data-transfer/network"
 	"github.com/filecoin-project/go-data-transfer/testutil"
 	dtgstransport "github.com/filecoin-project/go-data-transfer/transport/graphsync"
 	"github.com/filecoin-project/go-state-types/abi"
@@ -37,6 +43,10 @@ import (
 	basicnode "github.com/ipld/go-ipld-prime/node/basic"
 	"github.com/ipld/go-ipld-prime/traversal/selector"
 	"github.com/ipld/go-ipld-prime/traversal/selector/builder"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/statechannels/go-nitro/crypto"
+	"github.com/statechannels/go-nitro/payments"
+	nitrotypes "github.com/statechannels/go-nitro/types"
 	"github.com/stretchr/testify/require"
 )
 
@@ -403,3 +413,259 @@ func waitFor(ctx context.Context, t *testing.T, resChan chan error) error {
 	}
 	return err
 }
+
+// testPaymentReceiver accepts nitro vouchers like a nitro node: the payment
+// is the difference between the voucher amount and the amount of the
+// previous voucher on the channel
+type testPaymentReceiver struct {
+	lk       sync.Mutex
+	totals   map[nitrotypes.Destination]*big.Int
+	vouchers []payments.Voucher
+}
+
+func newTestPaymentReceiver() *testPaymentReceiver {
+	return &testPaymentReceiver{totals: make(map[nitrotypes.Destination]*big.Int)}
+}
+
+func (r *testPaymentReceiver) ReceiveVoucher(v payments.Voucher) (payments.ReceiveVoucherSummary, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	prev, ok := r.totals[v.ChannelId]
+	if !ok {
+		prev = big.NewInt(0)
+	}
+	if v.Amount.Cmp(prev) <= 0 {
+		return payments.ReceiveVoucherSummary{}, errors.New("voucher amount must be greater than the previous voucher amount")
+	}
+	r.totals[v.ChannelId] = v.Amount
+	r.vouchers = append(r.vouchers, v)
+	return payments.ReceiveVoucherSummary{
+		Total: v.Amount,
+		Delta: new(big.Int).Sub(v.Amount, prev),
+	}, nil
+}
+
+func testNitroProposal(channelID []byte, pricePerByte int64, paymentInterval uint64) *types.NitroDealProposal {
+	return &types.NitroDealProposal{
+		Proposal: retrievalmarket.DealProposal{
+			ID: retrievalmarket.DealID(1),
+			Params: retrievalmarket.Params{
+				PricePerByte:    abi.NewTokenAmount(pricePerByte),
+				PaymentInterval: paymentInterval,
+			},
+		},
+		ChannelID: channelID,
+	}
+}
+
+func TestAcceptNitroPayment(t *testing.T) {
+	channelID := bytes.Repeat([]byte{1}, 32)
+	ask := &retrievalmarket.Ask{
+		UnsealPrice:  abi.NewTokenAmount(0),
+		PricePerByte: abi.NewTokenAmount(2),
+	}
+
+	testCases := []struct {
+		name        string
+		receiver    PaymentReceiver
+		proposal    *types.NitroDealProposal
+		expectedErr string
+	}{{
+		name:     "accepted",
+		receiver: newTestPaymentReceiver(),
+		proposal: testNitroProposal(channelID, 2, 1024),
+	}, {
+		name:     "price above ask",
+		receiver: newTestPaymentReceiver(),
+		proposal: testNitroProposal(channelID, 3, 1024),
+	}, {
+		name:        "nitro disabled",
+		proposal:    testNitroProposal(channelID, 2, 1024),
+		expectedErr: "not enabled",
+	}, {
+		name:        "bad channel id",
+		receiver:    newTestPaymentReceiver(),
+		proposal:    testNitroProposal(channelID[:10], 2, 1024),
+		expectedErr: "invalid payment channel",
+	}, {
+		name:        "price below ask",
+		receiver:    newTestPaymentReceiver(),
+		proposal:    testNitroProposal(channelID, 1, 1024),
+		expectedErr: "less than the ask price",
+	}, {
+		name:        "zero payment interval",
+		receiver:    newTestPaymentReceiver(),
+		proposal:    testNitroProposal(channelID, 2, 0),
+		expectedErr: "payment interval must be greater than zero",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rv := newRequestValidator(ValidationDeps{PaymentReceiver: tc.receiver})
+			err := rv.acceptNitroPayment(tc.proposal, ask)
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+// testPaymentGraphsync records the requests that are unpaused
+type testPaymentGraphsync struct {
+	graphsync.GraphExchange
+	unpaused chan graphsync.RequestID
+}
+
+func (gs *testPaymentGraphsync) Unpause(_ context.Context, id graphsync.RequestID, _ ...graphsync.ExtensionData) error {
+	gs.unpaused <- id
+	return nil
+}
+
+// testPaymentNetwork records the messages sent to the client, and the
+// receiver that handles incoming messages
+type testPaymentNetwork struct {
+	dtnetwork.DataTransferNetwork
+	receiver dtnetwork.Receiver
+	sent     chan datatransfer.Message
+}
+
+func (n *testPaymentNetwork) SetDelegate(r dtnetwork.Receiver) { n.receiver = r }
+func (n *testPaymentNetwork) Protect(peer.ID, string)          {}
+func (n *testPaymentNetwork) Unprotect(peer.ID, string) bool   { return true }
+func (n *testPaymentNetwork) SendMessage(_ context.Context, _ peer.ID, msg datatransfer.Message) error {
+	n.sent <- msg
+	return nil
+}
+
+// testDelegateReceiver records the requests that are passed through to the
+// data transfer manager
+type testDelegateReceiver struct {
+	dtnetwork.Receiver
+	received chan datatransfer.Request
+}
+
+func (r *testDelegateReceiver) ReceiveRequest(_ context.Context, _ peer.ID, req datatransfer.Request) {
+	r.received <- req
+}
+
+func TestNitroPaymentPauseResume(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	clientPeer := testutil.GeneratePeers(1)[0]
+	channelID := bytes.Repeat([]byte{1}, 32)
+	nitroChannelID, err := types.NitroChannelID(channelID)
+	require.NoError(t, err)
+
+	receiver := newTestPaymentReceiver()
+	gs := &testPaymentGraphsync{unpaused: make(chan graphsync.RequestID, 1)}
+	dtnet := &testPaymentNetwork{sent: make(chan datatransfer.Message, 1)}
+	gsupr, err := NewGraphsyncUnpaidRetrieval(testutil.GeneratePeers(1)[0], gs, dtnet, ValidationDeps{PaymentReceiver: receiver}, nil)
+	require.NoError(t, err)
+	require.NoError(t, gsupr.Start(ctx))
+
+	// The data transfer manager's receiver is wrapped so that payment
+	// vouchers are handled by the graphsync unpaid retrieval
+	delegate := &testDelegateReceiver{received: make(chan datatransfer.Request, 1)}
+	gsupr.WrapNetwork(dtnet).SetDelegate(delegate)
+
+	// Track a nitro paid retrieval that costs 2 per byte, with a payment
+	// interval of 100 bytes
+	proposal := testNitroProposal(channelID, 2, 100)
+	transferID := datatransfer.TransferID(1)
+	state := &retrievalState{
+		retType: RetrievalTypeNitro,
+		cs:      &channelState{transferID: transferID, recipient: clientPeer, status: datatransfer.Ongoing},
+		mkts:    &retrievalmarket.ProviderDealState{DealProposal: proposal.Proposal},
+		gsReq:   graphsync.NewRequestID(),
+		payment: newNitroPayment(proposal),
+	}
+	gsupr.trackTransfer(clientPeer, transferID, state)
+
+	decoder, err := encoding.NewDecoder(&retrievalmarket.DealResponse{})
+	require.NoError(t, err)
+	receiveResponse := func() (datatransfer.Response, *retrievalmarket.DealResponse) {
+		var msg datatransfer.Message
+		select {
+		case <-ctx.Done():
+			require.Fail(t, "timed out waiting for response")
+		case msg = <-dtnet.sent:
+		}
+		resp, ok := msg.(datatransfer.Response)
+		require.True(t, ok)
+		require.True(t, resp.IsVoucherResult())
+		res, err := resp.VoucherResult(decoder)
+		require.NoError(t, err)
+		return resp, res.(*retrievalmarket.DealResponse)
+	}
+
+	sendVoucher := func(amount int64) {
+		sig := append(append(bytes.Repeat([]byte{2}, 32), bytes.Repeat([]byte{3}, 32)...), 27)
+		pv := types.NewNitroPaymentVoucher(payments.Voucher{
+			ChannelId: nitroChannelID,
+			Amount:    big.NewInt(amount),
+			Signature: crypto.SplitSignature(sig),
+		})
+		req, err := message.VoucherRequest(transferID, pv.Type(), pv)
+		require.NoError(t, err)
+		dtnet.receiver.ReceiveRequest(ctx, clientPeer, req)
+	}
+
+	// Queue 100 bytes: the client has not paid for anything yet, so the
+	// response pauses and the client is asked to pay for the queued bytes
+	// plus the next payment interval
+	pause, owed := state.payment.queueBlock(100)
+	require.True(t, pause)
+	gsupr.requestPayment(clientPeer, state, owed)
+	resp, dealResp := receiveResponse()
+	require.True(t, resp.IsPaused())
+	require.Equal(t, retrievalmarket.DealStatusFundsNeeded, dealResp.Status)
+	require.Equal(t, abi.NewTokenAmount(400), dealResp.PaymentOwed)
+	require.Equal(t, datatransfer.ResponderPaused, state.cs.status)
+
+	// A voucher that doesn't cover the queued bytes is accepted, but the
+	// response stays paused
+	sendVoucher(100)
+	resp, _ = receiveResponse()
+	require.True(t, resp.Accepted())
+	require.Len(t, gs.unpaused, 0)
+	require.Equal(t, abi.NewTokenAmount(100), state.payment.totalReceived())
+
+	// A voucher that doesn't increase the amount paid is rejected
+	sendVoucher(100)
+	resp, dealResp = receiveResponse()
+	require.False(t, resp.Accepted())
+	require.Equal(t, retrievalmarket.DealStatusFundsNeeded, dealResp.Status)
+	require.Len(t, gs.unpaused, 0)
+
+	// A voucher that covers the queued bytes resumes the response
+	sendVoucher(400)
+	resp, dealResp = receiveResponse()
+	require.True(t, resp.Accepted())
+	require.False(t, resp.IsPaused())
+	require.Equal(t, retrievalmarket.DealStatusOngoing, dealResp.Status)
+	select {
+	case <-ctx.Done():
+		require.Fail(t, "timed out waiting for unpause")
+	case id := <-gs.unpaused:
+		require.Equal(t, state.gsReq, id)
+	}
+	require.Equal(t, datatransfer.Ongoing, state.cs.status)
+	require.Equal(t, abi.NewTokenAmount(400), state.payment.totalReceived())
+	require.Len(t, receiver.vouchers, 2)
+
+	// Vouchers for transfers that are not nitro paid retrievals are passed
+	// through to the data transfer manager
+	req, err := message.VoucherRequest(datatransfer.TransferID(2), proposal.Type(), proposal)
+	require.NoError(t, err)
+	dtnet.receiver.ReceiveRequest(ctx, clientPeer, req)
+	select {
+	case <-ctx.Done():
+		require.Fail(t, "timed out waiting for request to be passed through")
+	case passed := <-delegate.received:
+		require.Equal(t, datatransfer.TransferID(2), passed.TransferID())
+	}
+}
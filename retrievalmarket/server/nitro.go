@@ -0,0 +1,264 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/filecoin-project/boost-gfm/retrievalmarket"
+	"github.com/filecoin-project/boost/retrievalmarket/types"
+	datatransfer "github.com/filecoin-project/go-data-transfer"
+	"github.com/filecoin-project/go-data-transfer/message"
+	"github.com/filecoin-project/go-data-transfer/network"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/statechannels/go-nitro/payments"
+)
+
+// PaymentReceiver receives nitro payment vouchers (eg the nitro RPC client)
+type PaymentReceiver interface {
+	ReceiveVoucher(v payments.Voucher) (payments.ReceiveVoucherSummary, error)
+}
+
+// nitroPayment keeps track of the payments made for a retrieval that is
+// paid for with a nitro payment channel.
+// The client pays in advance for the data. When the data that has been
+// queued for sending exceeds the data that has been paid for, the response
+// is paused until the client sends a voucher for the next payment interval.
+type nitroPayment struct {
+	channelID       []byte
+	pricePerByte    abi.TokenAmount
+	paymentInterval uint64
+
+	lk       sync.Mutex
+	received abi.TokenAmount
+	queued   uint64
+	// The number of bytes sent over the wire, across all restarts of the
+	// transfer
+	sent   uint64
+	paused bool
+}
+
+func newNitroPayment(proposal *types.NitroDealProposal) *nitroPayment {
+	return &nitroPayment{
+		channelID:       proposal.ChannelID,
+		pricePerByte:    proposal.Proposal.PricePerByte,
+		paymentInterval: proposal.Proposal.PaymentInterval,
+		received:        abi.NewTokenAmount(0),
+	}
+}
+
+// paidBytes is the number of bytes that the client has paid for
+func (p *nitroPayment) paidBytes() uint64 {
+	return big.Div(p.received, p.pricePerByte).Uint64()
+}
+
+// queueBlock records that a block has been queued for sending.
+// If the block uses up the client's credit, it returns true and the amount
+// that the client needs to pay before the response can continue.
+func (p *nitroPayment) queueBlock(size uint64) (bool, abi.TokenAmount) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	p.queued += size
+	if p.pricePerByte.IsZero() {
+		return false, abi.NewTokenAmount(0)
+	}
+
+	paidBytes := p.paidBytes()
+	if p.queued < paidBytes {
+		return false, abi.NewTokenAmount(0)
+	}
+
+	// Ask the client to pay for the data that has been queued but not paid
+	// for, plus the next payment interval
+	owedBytes := p.queued - paidBytes + p.paymentInterval
+	p.paused = true
+	return true, big.Mul(p.pricePerByte, abi.NewTokenAmount(int64(owedBytes)))
+}
+
+// addPayment credits the client with a payment. It returns true if the
+// response was paused waiting for payment, and can now be resumed.
+func (p *nitroPayment) addPayment(amount abi.TokenAmount) bool {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	p.received = big.Add(p.received, amount)
+	if p.paused && p.paidBytes() > p.queued {
+		p.paused = false
+		return true
+	}
+	return false
+}
+
+// blockSent records that a block has been sent over the wire
+func (p *nitroPayment) blockSent(size uint64) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	p.sent += size
+}
+
+// restart is called when the transfer is restarted. Blocks that were queued
+// but not sent will be queued again.
+func (p *nitroPayment) restart() {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	p.queued = p.sent
+	p.paused = false
+}
+
+func (p *nitroPayment) totalReceived() abi.TokenAmount {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	return p.received
+}
+
+// WrapNetwork returns a data transfer network that passes payment vouchers
+// for retrievals paid with nitro to GraphsyncUnpaidRetrieval, and all other
+// messages through to the receiver set with SetDelegate (ie the data
+// transfer manager).
+func (g *GraphsyncUnpaidRetrieval) WrapNetwork(net network.DataTransferNetwork) network.DataTransferNetwork {
+	return &paymentNetwork{DataTransferNetwork: net, g: g}
+}
+
+type paymentNetwork struct {
+	network.DataTransferNetwork
+	g *GraphsyncUnpaidRetrieval
+}
+
+func (n *paymentNetwork) SetDelegate(r network.Receiver) {
+	n.DataTransferNetwork.SetDelegate(&paymentReceiver{Receiver: r, g: n.g})
+}
+
+type paymentReceiver struct {
+	network.Receiver
+	g *GraphsyncUnpaidRetrieval
+}
+
+func (r *paymentReceiver) ReceiveRequest(ctx context.Context, sender peer.ID, incoming datatransfer.Request) {
+	// Voucher messages that are sent after the transfer has started are
+	// payments
+	if incoming.IsVoucher() && !incoming.IsNew() && !incoming.IsRestart() {
+		state, ok := r.g.isActiveUnpaidRetrieval(reqId{p: sender, id: incoming.TransferID()})
+		if ok && state.payment != nil {
+			r.g.receivePayment(ctx, sender, incoming, state)
+			return
+		}
+	}
+
+	r.Receiver.ReceiveRequest(ctx, sender, incoming)
+}
+
+// requestPayment is called when the client has used up its credit and the
+// response has been paused. It asks the client for the next payment.
+func (g *GraphsyncUnpaidRetrieval) requestPayment(p peer.ID, state *retrievalState, owed abi.TokenAmount) {
+	state.cs.status = datatransfer.ResponderPaused
+	g.publishDTEvent(datatransfer.PauseResponder, "waiting for payment", state.cs)
+	state.mkts.Status = retrievalmarket.DealStatusFundsNeeded
+	state.mkts.FundsReceived = state.payment.totalReceived()
+	g.publishMktsEvent(retrievalmarket.ProviderEventPaymentRequested, *state.mkts)
+
+	resp := &retrievalmarket.DealResponse{
+		ID:          state.mkts.DealProposal.ID,
+		Status:      retrievalmarket.DealStatusFundsNeeded,
+		PaymentOwed: owed,
+	}
+	const isAccepted = true
+	const isPaused = true
+	respMsg, err := message.VoucherResultResponse(state.cs.transferID, isAccepted, isPaused, resp.Type(), resp)
+	if err != nil {
+		g.failTransfer(state, fmt.Errorf("creating payment request message: %w", err))
+		return
+	}
+	if err := g.dtnet.SendMessage(g.ctx, p, respMsg); err != nil {
+		g.failTransfer(state, fmt.Errorf("sending payment request to %s: %w", p, err))
+		return
+	}
+
+	log.Debugw("requested payment", "transfer id", state.cs.transferID, "peer", p, "owed", owed)
+}
+
+// receivePayment processes a payment voucher from the client, and resumes
+// the response if it was waiting for payment
+func (g *GraphsyncUnpaidRetrieval) receivePayment(ctx context.Context, p peer.ID, req datatransfer.Request, state *retrievalState) {
+	resp := &retrievalmarket.DealResponse{
+		ID:     state.mkts.DealProposal.ID,
+		Status: retrievalmarket.DealStatusOngoing,
+	}
+
+	amount, err := g.redeemVoucher(req, state)
+	if err != nil {
+		log.Infow("rejected payment voucher", "transfer id", state.cs.transferID, "peer", p, "err", err)
+		resp.Status = retrievalmarket.DealStatusFundsNeeded
+		resp.Message = err.Error()
+		g.sendPaymentResponse(ctx, p, state, resp, false, true)
+		return
+	}
+
+	resumed := state.payment.addPayment(amount)
+
+	g.publishDTEvent(datatransfer.NewVoucher, "", state.cs)
+	state.mkts.FundsReceived = state.payment.totalReceived()
+	g.publishMktsEvent(retrievalmarket.ProviderEventPaymentReceived, *state.mkts)
+
+	if !resumed {
+		g.sendPaymentResponse(ctx, p, state, resp, true, false)
+		return
+	}
+
+	// The client has paid for more data, so continue sending
+	if err := g.GraphExchange.Unpause(ctx, state.gsReq); err != nil {
+		g.failTransfer(state, fmt.Errorf("resuming graphsync response after payment: %w", err))
+		return
+	}
+	state.cs.status = datatransfer.Ongoing
+	g.publishDTEvent(datatransfer.ResumeResponder, "", state.cs)
+	state.mkts.Status = retrievalmarket.DealStatusOngoing
+	g.sendPaymentResponse(ctx, p, state, resp, true, false)
+}
+
+// redeemVoucher checks the payment voucher and passes it to the nitro node.
+// It returns the amount of the payment.
+func (g *GraphsyncUnpaidRetrieval) redeemVoucher(req datatransfer.Request, state *retrievalState) (abi.TokenAmount, error) {
+	voucher, err := g.decodeVoucher(req, g.decoder)
+	if err != nil {
+		return abi.TokenAmount{}, fmt.Errorf("decoding payment voucher: %w", err)
+	}
+	pv, ok := voucher.(*types.NitroPaymentVoucher)
+	if !ok {
+		return abi.TokenAmount{}, fmt.Errorf("expected voucher of type %s but got %s", (&types.NitroPaymentVoucher{}).Type(), voucher.Type())
+	}
+
+	nv, err := pv.NitroVoucher()
+	if err != nil {
+		return abi.TokenAmount{}, fmt.Errorf("invalid payment voucher: %w", err)
+	}
+	channelID, err := types.NitroChannelID(state.payment.channelID)
+	if err != nil {
+		return abi.TokenAmount{}, err
+	}
+	if nv.ChannelId != channelID {
+		return abi.TokenAmount{}, fmt.Errorf("voucher is for channel %s but retrieval is paid from channel %s", nv.ChannelId, channelID)
+	}
+
+	summary, err := g.validator.PaymentReceiver.ReceiveVoucher(nv)
+	if err != nil {
+		return abi.TokenAmount{}, fmt.Errorf("processing payment voucher: %w", err)
+	}
+	return abi.TokenAmount{Int: summary.Delta}, nil
+}
+
+func (g *GraphsyncUnpaidRetrieval) sendPaymentResponse(ctx context.Context, p peer.ID, state *retrievalState, resp *retrievalmarket.DealResponse, isAccepted bool, isPaused bool) {
+	respMsg, err := message.VoucherResultResponse(state.cs.transferID, isAccepted, isPaused, resp.Type(), resp)
+	if err != nil {
+		log.Errorw("creating payment response message", "transfer id", state.cs.transferID, "err", err)
+		return
+	}
+	if err := g.dtnet.SendMessage(ctx, p, respMsg); err != nil {
+		log.Infow("sending payment response", "transfer id", state.cs.transferID, "peer", p, "err", err)
+	}
+}
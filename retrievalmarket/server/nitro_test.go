@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/filecoin-project/boost-gfm/retrievalmarket"
+	"github.com/filecoin-project/boost/retrievalmarket/types"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/statechannels/go-nitro/crypto"
+	"github.com/statechannels/go-nitro/payments"
+	nitrotypes "github.com/statechannels/go-nitro/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNitroPayment(t *testing.T) {
+	channelID := bytes.Repeat([]byte{1}, 32)
+	payment := newNitroPayment(&types.NitroDealProposal{
+		Proposal: retrievalmarket.DealProposal{
+			Params: retrievalmarket.Params{
+				PricePerByte:    abi.NewTokenAmount(2),
+				PaymentInterval: 100,
+			},
+		},
+		ChannelID: channelID,
+	})
+
+	// Pay for 150 bytes
+	resume := payment.addPayment(abi.NewTokenAmount(300))
+	require.False(t, resume)
+
+	// Queue 100 bytes: still in credit
+	pause, _ := payment.queueBlock(100)
+	require.False(t, pause)
+
+	// Queue another 100 bytes: the client has used up its credit, and owes
+	// for 50 bytes plus the next payment interval
+	pause, owed := payment.queueBlock(100)
+	require.True(t, pause)
+	require.Equal(t, abi.NewTokenAmount(2*(50+100)), owed)
+
+	// A payment that doesn't cover the queued bytes doesn't resume the
+	// response
+	resume = payment.addPayment(abi.NewTokenAmount(50))
+	require.False(t, resume)
+
+	// A payment that covers the queued bytes resumes the response
+	resume = payment.addPayment(abi.NewTokenAmount(250))
+	require.True(t, resume)
+	require.Equal(t, abi.NewTokenAmount(600), payment.totalReceived())
+
+	// After a restart, blocks that were queued but not sent are queued
+	// again. The sent count is carried across restarts.
+	payment.blockSent(100)
+	payment.restart()
+	payment.blockSent(50)
+	payment.restart()
+	pause, _ = payment.queueBlock(100)
+	require.False(t, pause)
+	pause, _ = payment.queueBlock(100)
+	require.True(t, pause)
+}
+
+func TestNitroPaymentFree(t *testing.T) {
+	payment := newNitroPayment(&types.NitroDealProposal{
+		Proposal: retrievalmarket.DealProposal{
+			Params: retrievalmarket.Params{
+				PricePerByte: abi.NewTokenAmount(0),
+			},
+		},
+		ChannelID: bytes.Repeat([]byte{1}, 32),
+	})
+
+	// If the price is zero the response never pauses
+	pause, _ := payment.queueBlock(1024)
+	require.False(t, pause)
+}
+
+func TestNitroPaymentVoucher(t *testing.T) {
+	var channelID nitrotypes.Destination
+	channelID[0] = 1
+	sig := append(append(bytes.Repeat([]byte{2}, 32), bytes.Repeat([]byte{3}, 32)...), 27)
+	v := payments.Voucher{
+		ChannelId: channelID,
+		Amount:    big.NewInt(100),
+		Signature: crypto.SplitSignature(sig),
+	}
+
+	// Round trip the voucher through CBOR encoding
+	pv := types.NewNitroPaymentVoucher(v)
+	var buf bytes.Buffer
+	require.NoError(t, pv.MarshalCBOR(&buf))
+	var decoded types.NitroPaymentVoucher
+	require.NoError(t, decoded.UnmarshalCBOR(&buf))
+
+	nv, err := decoded.NitroVoucher()
+	require.NoError(t, err)
+	require.Equal(t, v.ChannelId, nv.ChannelId)
+	require.Zero(t, v.Amount.Cmp(nv.Amount))
+	require.Equal(t, v.Signature, nv.Signature)
+
+	// A voucher with an invalid signature should fail
+	decoded.Signature = decoded.Signature[1:]
+	_, err = decoded.NitroVoucher()
+	require.Error(t, err)
+}
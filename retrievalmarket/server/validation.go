@@ -8,6 +8,7 @@ import (
 	"github.com/filecoin-project/boost-gfm/piecestore"
 	"github.com/filecoin-project/boost-gfm/retrievalmarket"
 	"github.com/filecoin-project/boost-gfm/retrievalmarket/migrations"
+	"github.com/filecoin-project/boost/retrievalmarket/types"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/hannahhoward/go-pubsub"
 	"github.com/ipfs/go-cid"
@@ -39,18 +40,23 @@ func newRequestValidator(vdeps ValidationDeps) *requestValidator {
 // request to pull data or a new request created when the data transfer is
 // restarted (eg after a connection failure).
 func (rv *requestValidator) validatePullRequest(isRestart bool, receiver peer.ID, voucher datatransfer.Voucher, baseCid cid.Cid, selector ipld.Node) (datatransfer.VoucherResult, error) {
-	proposal, ok := voucher.(*retrievalmarket.DealProposal)
+	var proposal *retrievalmarket.DealProposal
 	var legacyProtocol bool
-	if !ok {
-		legacyProposal, ok := voucher.(*migrations.DealProposal0)
-		if !ok {
-			return nil, errors.New("wrong voucher type")
-		}
-		newProposal := migrations.MigrateDealProposal0To1(*legacyProposal)
+	var nitroProposal *types.NitroDealProposal
+	switch v := voucher.(type) {
+	case *retrievalmarket.DealProposal:
+		proposal = v
+	case *migrations.DealProposal0:
+		newProposal := migrations.MigrateDealProposal0To1(*v)
 		proposal = &newProposal
 		legacyProtocol = true
+	case *types.NitroDealProposal:
+		nitroProposal = v
+		proposal = &v.Proposal
+	default:
+		return nil, errors.New("wrong voucher type")
 	}
-	response, err := rv.validatePull(receiver, proposal, legacyProtocol, baseCid, selector)
+	response, err := rv.validatePull(receiver, proposal, nitroProposal, legacyProtocol, baseCid, selector)
 	_ = rv.psub.Publish(retrievalmarket.ProviderValidationEvent{
 		IsRestart: isRestart,
 		Receiver:  receiver,
@@ -72,14 +78,14 @@ func (rv *requestValidator) validatePullRequest(isRestart bool, receiver peer.ID
 	return &response, err
 }
 
-func (rv *requestValidator) validatePull(receiver peer.ID, proposal *retrievalmarket.DealProposal, legacyProtocol bool, baseCid cid.Cid, selector ipld.Node) (retrievalmarket.DealResponse, error) {
+func (rv *requestValidator) validatePull(receiver peer.ID, proposal *retrievalmarket.DealProposal, nitroProposal *types.NitroDealProposal, legacyProtocol bool, baseCid cid.Cid, selector ipld.Node) (retrievalmarket.DealResponse, error) {
 	response := retrievalmarket.DealResponse{
 		ID:     proposal.ID,
 		Status: retrievalmarket.DealStatusAccepted,
 	}
 
 	// Decide whether to accept the deal
-	err := rv.acceptDeal(receiver, proposal, legacyProtocol, baseCid, selector)
+	err := rv.acceptDeal(receiver, proposal, nitroProposal, legacyProtocol, baseCid, selector)
 	if err != nil {
 		response.Status = retrievalmarket.DealStatusRejected
 		response.Message = err.Error()
@@ -89,7 +95,7 @@ func (rv *requestValidator) validatePull(receiver peer.ID, proposal *retrievalma
 	return response, nil
 }
 
-func (rv *requestValidator) acceptDeal(receiver peer.ID, proposal *retrievalmarket.DealProposal, legacyProtocol bool, baseCid cid.Cid, selector ipld.Node) error {
+func (rv *requestValidator) acceptDeal(receiver peer.ID, proposal *retrievalmarket.DealProposal, nitroProposal *types.NitroDealProposal, legacyProtocol bool, baseCid cid.Cid, selector ipld.Node) error {
 	// Check the proposal CID matches
 	if proposal.PayloadCID != baseCid {
 		return errors.New("incorrect CID for this proposal")
@@ -127,15 +133,18 @@ func (rv *requestValidator) acceptDeal(receiver peer.ID, proposal *retrievalmark
 		return fmt.Errorf("retrieval ask price is not configured")
 	}
 
-	// Check if the price per byte is non-zero.
 	// Note that we don't check the unseal price, because we only serve
 	// unsealed copies, so the unseal price is irrelevant.
-	if !ask.PricePerByte.IsZero() {
+	if nitroProposal != nil {
+		// Check the retrieval is paid for with a valid nitro channel, at a
+		// price that is at least the ask price
+		if err := rv.acceptNitroPayment(nitroProposal, ask); err != nil {
+			return err
+		}
+	} else if !ask.PricePerByte.IsZero() {
+		// Check if the price per byte is non-zero
 		return fmt.Errorf("request for unpaid retrieval but ask price is non-zero: %d per byte", ask.PricePerByte)
 	}
-	if err != nil {
-		return err
-	}
 
 	// Check the deal filter
 	if rv.DealDecider != nil {
@@ -157,6 +166,23 @@ func (rv *requestValidator) acceptDeal(receiver peer.ID, proposal *retrievalmark
 	return nil
 }
 
+func (rv *requestValidator) acceptNitroPayment(nitroProposal *types.NitroDealProposal, ask *retrievalmarket.Ask) error {
+	if rv.PaymentReceiver == nil {
+		return errors.New("retrievals paid with nitro are not enabled")
+	}
+	if _, err := types.NitroChannelID(nitroProposal.ChannelID); err != nil {
+		return fmt.Errorf("invalid payment channel: %w", err)
+	}
+	pricePerByte := nitroProposal.Proposal.PricePerByte
+	if pricePerByte.LessThan(ask.PricePerByte) {
+		return fmt.Errorf("proposed price of %d per byte is less than the ask price of %d per byte", pricePerByte, ask.PricePerByte)
+	}
+	if !pricePerByte.IsZero() && nitroProposal.Proposal.PaymentInterval == 0 {
+		return errors.New("payment interval must be greater than zero")
+	}
+	return nil
+}
+
 // Get the best piece containing the payload cid (first unsealed piece)
 func (rv *requestValidator) getPiece(payloadCid cid.Cid, pieceCID *cid.Cid) (piecestore.PieceInfo, bool, error) {
 	inPieceCid := cid.Undef
@@ -0,0 +1,19 @@
+package types
+
+import (
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+//go:generate cbor-gen-for --map-encoding BitswapPaymentResponse
+
+// BitswapPaymentResponse is the response to a NitroPaymentVoucher sent by a
+// client to pay for bitswap retrievals
+type BitswapPaymentResponse struct {
+	// Whether the voucher was accepted
+	Accepted bool
+	// The client's balance after the voucher was redeemed, that will be
+	// debited as blocks are sent to the client
+	Balance abi.TokenAmount
+	// The reason the voucher was rejected (if it was rejected)
+	Message string
+}
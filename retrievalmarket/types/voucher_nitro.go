@@ -0,0 +1,92 @@
+package types
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/filecoin-project/boost-gfm/retrievalmarket"
+	datatransfer "github.com/filecoin-project/go-data-transfer"
+	"github.com/filecoin-project/go-state-types/abi"
+	nitrocrypto "github.com/statechannels/go-nitro/crypto"
+	"github.com/statechannels/go-nitro/payments"
+	nitrotypes "github.com/statechannels/go-nitro/types"
+)
+
+//go:generate cbor-gen-for --map-encoding NitroDealProposal NitroPaymentVoucher
+
+// The length of a nitro voucher signature (R, S and V)
+const nitroSignatureLength = 65
+
+// NitroDealProposal is the voucher sent by a client to open a retrieval that
+// is paid for with a nitro payment channel
+type NitroDealProposal struct {
+	// The retrieval deal proposal. The PricePerByte is the price that the
+	// client agrees to pay for the data.
+	Proposal retrievalmarket.DealProposal
+	// The id of the nitro payment channel that the client will pay from
+	ChannelID []byte
+}
+
+func (p *NitroDealProposal) Type() datatransfer.TypeIdentifier {
+	return "NitroDealProposal/1"
+}
+
+// NitroPaymentVoucher is the voucher sent by a client to pay for a retrieval
+// with a nitro payment channel
+type NitroPaymentVoucher struct {
+	// The id of the nitro payment channel
+	ChannelID []byte
+	// The total amount that has been paid on the channel
+	Amount abi.TokenAmount
+	// The signature of the voucher (R, S and V)
+	Signature []byte
+}
+
+func (v *NitroPaymentVoucher) Type() datatransfer.TypeIdentifier {
+	return "NitroPaymentVoucher/1"
+}
+
+// NewNitroPaymentVoucher converts a nitro payments.Voucher to a voucher that
+// can be sent over data transfer
+func NewNitroPaymentVoucher(v payments.Voucher) *NitroPaymentVoucher {
+	channelID := v.ChannelId
+	sig := make([]byte, 0, nitroSignatureLength)
+	sig = append(sig, v.Signature.R...)
+	sig = append(sig, v.Signature.S...)
+	sig = append(sig, v.Signature.V)
+	return &NitroPaymentVoucher{
+		ChannelID: channelID[:],
+		Amount:    abi.TokenAmount{Int: new(big.Int).Set(v.Amount)},
+		Signature: sig,
+	}
+}
+
+// NitroVoucher converts the voucher to a nitro payments.Voucher
+func (v *NitroPaymentVoucher) NitroVoucher() (payments.Voucher, error) {
+	channelID, err := NitroChannelID(v.ChannelID)
+	if err != nil {
+		return payments.Voucher{}, err
+	}
+	if v.Amount.Int == nil || v.Amount.Sign() <= 0 {
+		return payments.Voucher{}, fmt.Errorf("voucher amount must be greater than zero")
+	}
+	if len(v.Signature) != nitroSignatureLength {
+		return payments.Voucher{}, fmt.Errorf("voucher signature must be %d bytes but is %d bytes", nitroSignatureLength, len(v.Signature))
+	}
+
+	return payments.Voucher{
+		ChannelId: channelID,
+		Amount:    new(big.Int).Set(v.Amount.Int),
+		Signature: nitrocrypto.SplitSignature(v.Signature),
+	}, nil
+}
+
+// NitroChannelID converts bytes to a nitro channel id
+func NitroChannelID(bz []byte) (nitrotypes.Destination, error) {
+	var channelID nitrotypes.Destination
+	if len(bz) != len(channelID) {
+		return channelID, fmt.Errorf("nitro channel id must be %d bytes but is %d bytes", len(channelID), len(bz))
+	}
+	copy(channelID[:], bz)
+	return channelID, nil
+}
@@ -73,18 +73,45 @@ func (m *StorageManager) Free(ctx context.Context) (uint64, error) {
 // ErrNoSpaceLeft indicates that there is insufficient storage to accept a deal
 var ErrNoSpaceLeft = errors.New("no space left")
 
+// Snapshot is a view of the storage tagged for deals in the staging area.
+// It's used to tag storage for a batch of deals without querying the totals
+// from the database for each deal. The totals are read the first time they
+// are needed, and are updated as storage is tagged from the snapshot.
+// Note that the snapshot is only accurate as long as storage is not tagged or
+// untagged for other deals while it's in use.
+type Snapshot struct {
+	tagged        *uint64
+	taggedForHost map[string]uint64
+}
+
+// Snapshot returns a new storage snapshot
+func (m *StorageManager) Snapshot() *Snapshot {
+	return &Snapshot{taggedForHost: make(map[string]uint64)}
+}
+
 // Tags storage space for the deal.
 // If there is not enough space left, returns ErrNoSpaceLeft.
 func (m *StorageManager) Tag(ctx context.Context, dealUuid uuid.UUID, size uint64, host string) error {
+	return m.TagFromSnapshot(ctx, m.Snapshot(), dealUuid, size, host)
+}
+
+// TagFromSnapshot is like Tag, but it checks the available space against
+// the snapshot. The storage tagged for the deal is added to the snapshot.
+func (m *StorageManager) TagFromSnapshot(ctx context.Context, snap *Snapshot, dealUuid uuid.UUID, size uint64, host string) error {
 	// Get the total tagged storage, so that we know how much is available.
 	log.Debugw("tagging", "id", dealUuid, "size", size, "host", host, "maxbytes", m.Cfg.MaxStagingDealsBytes)
 
 	if m.Cfg.MaxStagingDealsBytes != 0 {
 		if m.Cfg.MaxStagingDealsPercentPerHost != 0 {
 			// Get the total amount tagged for download from the host
-			tagged, err := m.TotalTaggedForHost(ctx, host)
-			if err != nil {
-				return fmt.Errorf("getting total tagged for host: %w", err)
+			tagged, ok := snap.taggedForHost[host]
+			if !ok {
+				var err error
+				tagged, err = m.TotalTaggedForHost(ctx, host)
+				if err != nil {
+					return fmt.Errorf("getting total tagged for host: %w", err)
+				}
+				snap.taggedForHost[host] = tagged
 			}
 
 			// Check the amount tagged + the size of the proposed deal against the limit
@@ -98,10 +125,14 @@ func (m *StorageManager) Tag(ctx context.Context, dealUuid uuid.UUID, size uint6
 		}
 
 		// Get the total amount tagged for download from all hosts
-		tagged, err := m.TotalTagged(ctx)
-		if err != nil {
-			return fmt.Errorf("getting total tagged: %w", err)
+		if snap.tagged == nil {
+			tagged, err := m.TotalTagged(ctx)
+			if err != nil {
+				return fmt.Errorf("getting total tagged: %w", err)
+			}
+			snap.tagged = &tagged
 		}
+		tagged := *snap.tagged
 
 		// Check the amount tagged + the size of the proposed deal against the limit
 		if tagged+size >= m.Cfg.MaxStagingDealsBytes {
@@ -116,10 +147,15 @@ func (m *StorageManager) Tag(ctx context.Context, dealUuid uuid.UUID, size uint6
 		return fmt.Errorf("saving total tagged storage: %w", err)
 	}
 
+	if snap.tagged != nil {
+		*snap.tagged += size
+	}
+	if tagged, ok := snap.taggedForHost[host]; ok {
+		snap.taggedForHost[host] = tagged + size
+	}
 	return nil
 }
 
-// Untag
 func (m *StorageManager) Untag(ctx context.Context, dealUuid uuid.UUID) error {
 	size, err := m.db.Untag(ctx, dealUuid)
 	if err != nil {
@@ -0,0 +1,98 @@
+package storagemarket
+
+import (
+	"sync"
+
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/libp2p/go-libp2p/core/event"
+)
+
+// The number of checkpoint events buffered for each subscriber
+const checkpointSubBufferSize = 256
+
+// DealCheckpointEvent is fired when a deal moves to a new checkpoint, or
+// when a deal fails
+type DealCheckpointEvent struct {
+	Deal types.ProviderDealState
+	// Whether the deal failed (in which case the deal is at the Complete
+	// checkpoint and Deal.Err is set)
+	Failed bool
+}
+
+// checkpointPS keeps track of deal checkpoint events for all deals, and
+// delivers them to each subscriber.
+// Events are emitted from the deal making loop, so delivery never blocks:
+// if a subscriber's buffer is full, the event is dropped for that
+// subscriber, so that a slow subscriber can't stall deal making.
+type checkpointPS struct {
+	lk   sync.Mutex
+	subs map[*checkpointSub]struct{}
+}
+
+func newCheckpointPubsub() *checkpointPS {
+	return &checkpointPS{subs: make(map[*checkpointSub]struct{})}
+}
+
+func (m *checkpointPS) subscribe() (event.Subscription, error) {
+	sub := &checkpointSub{
+		ps:  m,
+		out: make(chan interface{}, checkpointSubBufferSize),
+	}
+
+	m.lk.Lock()
+	m.subs[sub] = struct{}{}
+	m.lk.Unlock()
+
+	return sub, nil
+}
+
+func (m *checkpointPS) emit(evt DealCheckpointEvent) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	for sub := range m.subs {
+		select {
+		case sub.out <- evt:
+		default:
+			// Log the first dropped event, and then every hundredth, so
+			// that a stalled subscriber doesn't flood the log
+			sub.dropped++
+			if sub.dropped%100 == 1 {
+				log.Warnw("dropped deal checkpoint event: subscriber is not keeping up",
+					"id", evt.Deal.DealUuid, "checkpoint", evt.Deal.Checkpoint.String(), "dropped", sub.dropped)
+			}
+		}
+	}
+}
+
+// checkpointSub is a subscription to deal checkpoint events
+type checkpointSub struct {
+	ps        *checkpointPS
+	out       chan interface{}
+	closeOnce sync.Once
+	// The number of events dropped because the buffer was full
+	// (guarded by ps.lk)
+	dropped uint64
+}
+
+var _ event.Subscription = (*checkpointSub)(nil)
+
+func (s *checkpointSub) Out() <-chan interface{} {
+	return s.out
+}
+
+func (s *checkpointSub) Name() string {
+	return "deal-checkpoints"
+}
+
+// Close unsubscribes and closes the Out channel
+func (s *checkpointSub) Close() error {
+	s.closeOnce.Do(func() {
+		s.ps.lk.Lock()
+		defer s.ps.lk.Unlock()
+
+		delete(s.ps.subs, s)
+		close(s.out)
+	})
+	return nil
+}
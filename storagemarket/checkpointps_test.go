@@ -0,0 +1,52 @@
+package storagemarket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointPubsubSlowSubscriber(t *testing.T) {
+	ps := newCheckpointPubsub()
+	slow, err := ps.subscribe()
+	require.NoError(t, err)
+	defer slow.Close() // nolint
+	fast, err := ps.subscribe()
+	require.NoError(t, err)
+
+	// Emit more events than the slow subscriber's buffer can hold. The fast
+	// subscriber reads each event as it is emitted.
+	done := make(chan int)
+	go func() {
+		received := 0
+		for i := 0; i < checkpointSubBufferSize*2; i++ {
+			ps.emit(DealCheckpointEvent{Deal: types.ProviderDealState{DealUuid: uuid.New()}})
+			<-fast.Out()
+			received++
+		}
+		done <- received
+	}()
+
+	// Emitting should not block on the slow subscriber
+	select {
+	case received := <-done:
+		require.Equal(t, checkpointSubBufferSize*2, received)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "emitting checkpoint events blocked")
+	}
+
+	// The slow subscriber gets the events that fit in its buffer, and the
+	// rest are dropped
+	require.Len(t, slow.Out(), checkpointSubBufferSize)
+
+	// Closing a subscription closes its channel, and unsubscribes it
+	require.NoError(t, fast.Close())
+	_, ok := <-fast.Out()
+	require.False(t, ok)
+	require.NoError(t, fast.Close())
+	ps.emit(DealCheckpointEvent{})
+	require.Len(t, ps.subs, 1)
+}
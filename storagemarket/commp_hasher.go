@@ -0,0 +1,162 @@
+package storagemarket
+
+import (
+	"fmt"
+	"math/bits"
+
+	commp "github.com/filecoin-project/go-fil-commp-hashhash"
+	sha256simd "github.com/minio/sha256-simd"
+)
+
+// stackedNulPadding is the root of a merkle tree of zeros at each layer
+var stackedNulPadding [commp.MaxLayers][]byte
+
+func init() {
+	stackedNulPadding[0] = make([]byte, 32)
+	for i := uint(1); i < commp.MaxLayers; i++ {
+		stackedNulPadding[i] = hash254(stackedNulPadding[i-1], stackedNulPadding[i-1])
+	}
+}
+
+// commpHasher calculates commp over a stream of bytes, in the same way as
+// the calculator in go-fil-commp-hashhash. The calculator in
+// go-fil-commp-hashhash keeps its state in goroutines, whereas all the state
+// of commpHasher is in its exported fields, so that it can be saved and the
+// calculation continued after a restart.
+type commpHasher struct {
+	// The number of bytes written to the hasher
+	Consumed uint64
+	// Bytes that have been written but not yet hashed, because there are
+	// fewer than 127 of them
+	Carry []byte
+	// The node at each layer of the merkle tree that is waiting for its
+	// sibling (nil if there is no waiting node)
+	Layers [][]byte
+}
+
+func (h *commpHasher) Write(p []byte) (int, error) {
+	n := len(p)
+	if n == 0 {
+		return 0, nil
+	}
+	if h.Consumed+uint64(n) > commp.MaxPiecePayload {
+		return 0, fmt.Errorf("writing %d bytes to the commp hasher would overflow the maximum piece payload size %d",
+			n, commp.MaxPiecePayload)
+	}
+	h.Consumed += uint64(n)
+
+	if len(h.Carry) > 0 {
+		if len(h.Carry)+len(p) < 127 {
+			h.Carry = append(h.Carry, p...)
+			return n, nil
+		}
+
+		fill := 127 - len(h.Carry)
+		h.Carry = append(h.Carry, p[:fill]...)
+		p = p[fill:]
+		h.digest127(h.Carry)
+		h.Carry = h.Carry[:0]
+	}
+
+	for len(p) >= 127 {
+		h.digest127(p)
+		p = p[127:]
+	}
+
+	if len(p) > 0 {
+		h.Carry = append(h.Carry[:0], p...)
+	}
+	return n, nil
+}
+
+// Digest returns the commp and padded piece size of the bytes written so
+// far. It does not modify the state of the hasher.
+func (h *commpHasher) Digest() ([]byte, uint64, error) {
+	if h.Consumed == 0 {
+		return nil, 0, fmt.Errorf("commp is not defined for empty input")
+	}
+
+	fin := &commpHasher{Layers: make([][]byte, len(h.Layers))}
+	copy(fin.Layers, h.Layers)
+
+	// Pad the remaining bytes with zeros to make up a full chunk
+	if len(h.Carry) > 0 {
+		carry := make([]byte, 127)
+		copy(carry, h.Carry)
+		fin.digest127(carry)
+	}
+
+	// Pair each waiting node with a tree of zeros, working up to the top
+	// layer. Note that the number of layers may grow as the nodes are added.
+	for i := 0; i < len(fin.Layers)-1; i++ {
+		if fin.Layers[i] != nil {
+			node := hash254(fin.Layers[i], stackedNulPadding[i])
+			fin.Layers[i] = nil
+			fin.push(i+1, node)
+		}
+	}
+
+	paddedPieceSize := (h.Consumed + 126) / 127 * 128
+	if bits.OnesCount64(paddedPieceSize) != 1 {
+		paddedPieceSize = 1 << uint(64-bits.LeadingZeros64(paddedPieceSize))
+	}
+	return fin.Layers[len(fin.Layers)-1], paddedPieceSize, nil
+}
+
+// digest127 expands 127 bytes into four 32 byte leaves (fr32 padding) and
+// adds them to the tree
+func (h *commpHasher) digest127(input []byte) {
+	var expander [128]byte
+
+	copy(expander[:], input[:32])
+	expander[31] &= 0x3F
+
+	inputPlus1, expanderPlus1 := input[1:], expander[1:]
+	for i := 31; i < 63; i++ {
+		expanderPlus1[i] = inputPlus1[i]<<2 | input[i]>>6
+	}
+	expander[63] &= 0x3F
+
+	for i := 63; i < 95; i++ {
+		expanderPlus1[i] = inputPlus1[i]<<4 | input[i]>>4
+	}
+	expander[95] &= 0x3F
+
+	for i := 95; i < 126; i++ {
+		expanderPlus1[i] = inputPlus1[i]<<6 | input[i]>>2
+	}
+	expander[127] = input[126] >> 2
+
+	for i := 0; i < 128; i += 32 {
+		leaf := make([]byte, 32)
+		copy(leaf, expander[i:i+32])
+		h.push(0, leaf)
+	}
+}
+
+// push adds a node to the given layer. If there is a node waiting for its
+// sibling at that layer, the two are hashed together and the result is
+// pushed to the next layer up.
+func (h *commpHasher) push(layer int, node []byte) {
+	for {
+		if layer == len(h.Layers) {
+			h.Layers = append(h.Layers, nil)
+		}
+		if h.Layers[layer] == nil {
+			h.Layers[layer] = node
+			return
+		}
+		node = hash254(h.Layers[layer], node)
+		h.Layers[layer] = nil
+		layer++
+	}
+}
+
+func hash254(left []byte, right []byte) []byte {
+	sh := sha256simd.New()
+	sh.Write(left)  //nolint:errcheck
+	sh.Write(right) //nolint:errcheck
+	d := sh.Sum(make([]byte, 0, 32))
+	d[31] &= 0x3F
+	return d
+}
@@ -0,0 +1,60 @@
+package storagemarket
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	commp "github.com/filecoin-project/go-fil-commp-hashhash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommpHasher(t *testing.T) {
+	sizes := []int{65, 126, 127, 128, 254, 127 * 8, 127*8 + 1, 4064, 100000, 1 << 20, 3<<20 + 17}
+	for i := 0; i < 10; i++ {
+		sizes = append(sizes, rand.Intn(2<<20)+65)
+	}
+
+	for _, size := range sizes {
+		data := make([]byte, size)
+		_, _ = rand.Read(data)
+
+		// Write the data in chunks of random size, and save and restore the
+		// hasher state every few chunks
+		h := &commpHasher{}
+		for offset := 0; offset < size; {
+			end := offset + rand.Intn(5000) + 1
+			if end > size {
+				end = size
+			}
+			_, err := h.Write(data[offset:end])
+			require.NoError(t, err)
+			offset = end
+
+			if rand.Intn(10) == 0 {
+				bz, err := json.Marshal(h)
+				require.NoError(t, err)
+				h = &commpHasher{}
+				require.NoError(t, json.Unmarshal(bz, h))
+			}
+		}
+		commP, paddedSize, err := h.Digest()
+		require.NoError(t, err)
+
+		// The result should be the same as the go-fil-commp-hashhash
+		// calculator
+		calc := &commp.Calc{}
+		_, err = calc.Write(data)
+		require.NoError(t, err)
+		expCommP, expPaddedSize, err := calc.Digest()
+		require.NoError(t, err)
+
+		require.Equal(t, expCommP, commP, "size %d", size)
+		require.Equal(t, expPaddedSize, paddedSize, "size %d", size)
+	}
+}
+
+func TestCommpHasherEmpty(t *testing.T) {
+	_, _, err := (&commpHasher{}).Digest()
+	require.Error(t, err)
+}
@@ -35,6 +35,12 @@ func (p *Provider) validateDealProposal(deal types.ProviderDealState) *validatio
 		}
 	}
 
+	return p.validateDealProposalAt(deal, head)
+}
+
+// validateDealProposalAt validates a proposed deal against the provider
+// criteria, using the chain state at the given tipset
+func (p *Provider) validateDealProposalAt(deal types.ProviderDealState, head *ctypes.TipSet) *validationError {
 	tok := head.Key().Bytes()
 	curEpoch := head.Height()
 
@@ -18,13 +18,25 @@ import (
 var ErrCommpMismatch = fmt.Errorf("commp mismatch")
 
 // Verify that the commp provided in the deal proposal matches commp calculated
-// over the downloaded file
-func (p *Provider) verifyCommP(deal *types.ProviderDealState) *dealMakingError {
+// over the downloaded file. If commp was already calculated while the file was
+// downloaded, inline is the calculated piece info, otherwise it is nil.
+func (p *Provider) verifyCommP(deal *types.ProviderDealState, inline *abi.PieceInfo) *dealMakingError {
 	p.dealLogger.Infow(deal.DealUuid, "checking commP")
-	pieceCid, err := p.generatePieceCommitment(deal.InboundFilePath, deal.ClientDealProposal.Proposal.PieceSize)
-	if err != nil {
-		err.error = fmt.Errorf("failed to generate CommP: %w", err.error)
-		return err
+
+	var pieceCid cid.Cid
+	if inline != nil {
+		var err *dealMakingError
+		pieceCid, err = padPieceCommitment(inline, deal.ClientDealProposal.Proposal.PieceSize)
+		if err != nil {
+			return err
+		}
+	} else {
+		var err *dealMakingError
+		pieceCid, err = p.generatePieceCommitment(deal.InboundFilePath, deal.ClientDealProposal.Proposal.PieceSize)
+		if err != nil {
+			err.error = fmt.Errorf("failed to generate CommP: %w", err.error)
+			return err
+		}
 	}
 
 	clientPieceCid := deal.ClientDealProposal.Proposal.PieceCID
@@ -73,6 +85,11 @@ func (p *Provider) generatePieceCommitment(filepath string, pieceSize abi.Padded
 		}
 	}
 
+	return padPieceCommitment(pi, pieceSize)
+}
+
+// padPieceCommitment pads commp as necessary to match the piece size
+func padPieceCommitment(pi *abi.PieceInfo, pieceSize abi.PaddedPieceSize) (cid.Cid, *dealMakingError) {
 	// if the data does not fill the whole piece
 	if pi.Size < pieceSize {
 		// pad the data so that it fills the piece
@@ -88,7 +105,8 @@ func (p *Provider) generatePieceCommitment(filepath string, pieceSize abi.Padded
 				error: fmt.Errorf("failed to pad commp: %w", err),
 			}
 		}
-		pi.PieceCID, _ = commcid.DataCommitmentV1ToCID(rawPaddedCommp)
+		pieceCid, _ := commcid.DataCommitmentV1ToCID(rawPaddedCommp)
+		return pieceCid, nil
 	}
 
 	return pi.PieceCID, nil
@@ -0,0 +1,399 @@
+package storagemarket
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/filecoin-project/boost/storagemarket/logs"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	commcid "github.com/filecoin-project/go-fil-commcid"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	car "github.com/ipld/go-car"
+	carv2 "github.com/ipld/go-car/v2"
+)
+
+// The interval at which the state of an inline commp calculation is saved
+const inlineCommpSaveInterval = 10 * time.Second
+
+// The size of the chunks in which the downloaded file is read
+const inlineCommpReadSize = 1 << 20
+
+// errCarHeaderIncomplete is returned when there are not yet enough bytes
+// at the start of the file to read the CAR header
+var errCarHeaderIncomplete = errors.New("CAR header incomplete")
+
+// inlineCommpStatePath is the path of the file that keeps the state of the
+// inline commp calculation, so that it can be continued when a transfer is
+// resumed after a restart
+func inlineCommpStatePath(inboundFilePath string) string {
+	return inboundFilePath + ".commp"
+}
+
+// inlineCommpState is the progress of an inline commp calculation
+type inlineCommpState struct {
+	// Whether the CAR header has been verified
+	HeaderVerified bool
+	// The offset of the CARv1 data in the file
+	DataOffset int64
+	// The size of the CARv1 data, or -1 if the data runs to the end of the
+	// file (a CARv1 file)
+	DataSize int64
+	// The offset in the file of the next byte to be hashed
+	Offset int64
+	Hasher commpHasher
+}
+
+// inlineCommp verifies deal data while it is being downloaded. As soon as
+// the start of the file has arrived it checks that the file has a valid CAR
+// header with the deal data root as one of its roots, so that a transfer of
+// the wrong data fails early. It then calculates commp over the data as it
+// arrives, so that the file doesn't need to be read again once the download
+// is complete.
+type inlineCommp struct {
+	dl           *logs.DealLogger
+	dealUuid     uuid.UUID
+	path         string
+	dealDataRoot cid.Cid
+	// Whether to calculate commp, or only to verify the CAR header
+	hash bool
+
+	file     *os.File
+	buf      []byte
+	state    inlineCommpState
+	lastSave time.Time
+
+	// The number of contiguous bytes at the start of the file that have
+	// been downloaded
+	contiguous int64
+	wake       chan struct{}
+	done       chan struct{}
+	stopped    chan struct{}
+	err        error
+}
+
+func newInlineCommp(dl *logs.DealLogger, deal *types.ProviderDealState, hash bool) *inlineCommp {
+	c := &inlineCommp{
+		dl:           dl,
+		dealUuid:     deal.DealUuid,
+		path:         deal.InboundFilePath,
+		dealDataRoot: deal.DealDataRoot,
+		hash:         hash,
+		lastSave:     time.Now(),
+		wake:         make(chan struct{}, 1),
+		done:         make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+	c.load()
+	return c
+}
+
+// load reads the state saved by a previous transfer of the deal data, if
+// the state is still valid
+func (c *inlineCommp) load() {
+	statePath := inlineCommpStatePath(c.path)
+	bz, err := os.ReadFile(statePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			c.dl.Warnw(c.dealUuid, "reading inline commp state", "path", statePath, "err", err)
+		}
+		return
+	}
+
+	var st inlineCommpState
+	if err := json.Unmarshal(bz, &st); err != nil {
+		c.dl.Warnw(c.dealUuid, "discarding invalid inline commp state", "path", statePath, "err", err)
+		return
+	}
+
+	// If the file doesn't have all the bytes that were hashed (eg because
+	// it was deleted), the state is stale
+	fi, err := os.Stat(c.path)
+	if err != nil || fi.Size() < st.Offset {
+		c.dl.Infow(c.dealUuid, "discarding stale inline commp state", "path", statePath)
+		return
+	}
+
+	c.state = st
+	c.dl.Infow(c.dealUuid, "resuming inline commp", "offset", st.Offset)
+}
+
+func (c *inlineCommp) save() error {
+	bz, err := json.Marshal(c.state)
+	if err != nil {
+		return fmt.Errorf("marshalling inline commp state: %w", err)
+	}
+
+	// Write to a temp file and rename it so that the state file is never
+	// partially written
+	statePath := inlineCommpStatePath(c.path)
+	tmp := statePath + ".tmp"
+	if err := os.WriteFile(tmp, bz, 0644); err != nil {
+		return fmt.Errorf("writing inline commp state: %w", err)
+	}
+	if err := os.Rename(tmp, statePath); err != nil {
+		return fmt.Errorf("renaming inline commp state file: %w", err)
+	}
+	c.lastSave = time.Now()
+	return nil
+}
+
+// run verifies the data as it is downloaded, until stop is called. If the
+// data is invalid, run calls fail and exits.
+func (c *inlineCommp) run(fail func()) {
+	defer close(c.stopped)
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.wake:
+		}
+
+		if err := c.update(atomic.LoadInt64(&c.contiguous), c.done); err != nil {
+			c.err = err
+			fail()
+			return
+		}
+	}
+}
+
+// notify is called with the number of contiguous bytes at the start of the
+// file that have been downloaded
+func (c *inlineCommp) notify(contiguous int64) {
+	atomic.StoreInt64(&c.contiguous, contiguous)
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// stop waits for run to exit and saves the state, so that the calculation
+// can be continued if the transfer is resumed. It returns an error if the
+// data is invalid.
+func (c *inlineCommp) stop() error {
+	close(c.done)
+	<-c.stopped
+
+	if c.file != nil {
+		_ = c.file.Close()
+		c.file = nil
+	}
+	if c.err != nil {
+		return c.err
+	}
+	if err := c.save(); err != nil {
+		c.dl.Warnw(c.dealUuid, "saving inline commp state", "err", err)
+	}
+	return nil
+}
+
+// finish verifies the rest of the file once the transfer is complete. If
+// commp is being calculated it returns the piece info, otherwise it
+// returns nil.
+func (c *inlineCommp) finish() (*abi.PieceInfo, error) {
+	fi, err := os.Stat(c.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get size of downloaded file: %w", err)
+	}
+	fileSize := fi.Size()
+
+	if err := c.update(fileSize, nil); err != nil {
+		return nil, err
+	}
+	if !c.state.HeaderVerified {
+		return nil, fmt.Errorf("reading CAR header from %d byte file: %w", fileSize, io.ErrUnexpectedEOF)
+	}
+	if !c.hash {
+		return nil, nil
+	}
+
+	dataSize := c.state.DataSize
+	if dataSize < 0 {
+		dataSize = fileSize - c.state.DataOffset
+	}
+	if written := c.state.Offset - c.state.DataOffset; written != dataSize {
+		return nil, fmt.Errorf("number of bytes written to CommP hasher %d not equal to the CARv1 payload size %d", written, dataSize)
+	}
+
+	commP, paddedSize, err := c.state.Hasher.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate CommP: %w", err)
+	}
+	pieceCid, err := commcid.DataCommitmentV1ToCID(commP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert CommP to cid: %w", err)
+	}
+	return &abi.PieceInfo{
+		Size:     abi.PaddedPieceSize(paddedSize),
+		PieceCID: pieceCid,
+	}, nil
+}
+
+// remove closes the downloaded file and removes the saved state
+func (c *inlineCommp) remove() {
+	if c.file != nil {
+		_ = c.file.Close()
+		c.file = nil
+	}
+	_ = os.Remove(inlineCommpStatePath(c.path))
+}
+
+// update verifies the header and hashes the data in the first contiguous
+// bytes of the file. It returns early (without an error) if interrupt is
+// closed.
+func (c *inlineCommp) update(contiguous int64, interrupt <-chan struct{}) error {
+	if contiguous < c.state.Offset {
+		// The transport has gone back to an earlier point in the file (eg
+		// because it discarded a partial download) so start again
+		c.dl.Infow(c.dealUuid, "restarting inline commp from the start of the file",
+			"offset", c.state.Offset, "contiguous bytes", contiguous)
+		c.state = inlineCommpState{}
+	}
+	if contiguous == 0 {
+		return nil
+	}
+
+	if c.file == nil {
+		f, err := os.Open(c.path)
+		if err != nil {
+			return fmt.Errorf("failed to open downloaded file: %w", err)
+		}
+		c.file = f
+	}
+
+	if !c.state.HeaderVerified {
+		err := c.verifyHeader(contiguous)
+		if errors.Is(err, errCarHeaderIncomplete) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if !c.hash {
+		return nil
+	}
+
+	end := contiguous
+	if c.state.DataSize >= 0 && end > c.state.DataOffset+c.state.DataSize {
+		end = c.state.DataOffset + c.state.DataSize
+	}
+	if c.buf == nil && c.state.Offset < end {
+		c.buf = make([]byte, inlineCommpReadSize)
+	}
+	for c.state.Offset < end {
+		select {
+		case <-interrupt:
+			return nil
+		default:
+		}
+
+		n := int64(len(c.buf))
+		if c.state.Offset+n > end {
+			n = end - c.state.Offset
+		}
+		if _, err := c.file.ReadAt(c.buf[:n], c.state.Offset); err != nil {
+			return fmt.Errorf("reading downloaded file at offset %d: %w", c.state.Offset, err)
+		}
+		if _, err := c.state.Hasher.Write(c.buf[:n]); err != nil {
+			return err
+		}
+		c.state.Offset += n
+
+		if time.Since(c.lastSave) > inlineCommpSaveInterval {
+			if err := c.save(); err != nil {
+				c.dl.Warnw(c.dealUuid, "saving inline commp state", "err", err)
+			}
+		}
+	}
+	return nil
+}
+
+// verifyHeader reads the CAR header from the first contiguous bytes of the
+// file, and checks that the deal data root is one of the CAR's roots
+func (c *inlineCommp) verifyHeader(contiguous int64) error {
+	dataOffset, dataSize, roots, err := readCarHeader(c.file, contiguous)
+	if err != nil {
+		return err
+	}
+
+	if c.dealDataRoot.Defined() {
+		var found bool
+		for _, root := range roots {
+			if root.Equals(c.dealDataRoot) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("deal data root %s is not one of the CAR file roots %s", c.dealDataRoot, roots)
+		}
+	}
+
+	c.dl.Infow(c.dealUuid, "verified CAR header of downloaded file", "data offset", dataOffset)
+	c.state = inlineCommpState{
+		HeaderVerified: true,
+		DataOffset:     dataOffset,
+		DataSize:       dataSize,
+		Offset:         dataOffset,
+	}
+	return nil
+}
+
+// readCarHeader reads the header of a CARv1 or CARv2 file from the first
+// size bytes of the reader. It returns the offset and size of the CARv1
+// data (the size is -1 for a CARv1 file), and the CAR roots. If more
+// bytes are needed to read the header it returns errCarHeaderIncomplete.
+func readCarHeader(r io.ReaderAt, size int64) (int64, int64, []cid.Cid, error) {
+	readV1Header := func(offset int64) (*car.CarHeader, error) {
+		if offset >= size {
+			return nil, errCarHeaderIncomplete
+		}
+		hdr, err := car.ReadHeader(bufio.NewReader(io.NewSectionReader(r, offset, size-offset)))
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, errCarHeaderIncomplete
+			}
+			return nil, fmt.Errorf("invalid CAR header: %w", err)
+		}
+		return hdr, nil
+	}
+
+	hdr, err := readV1Header(0)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	switch hdr.Version {
+	case 1:
+		return 0, -1, hdr.Roots, nil
+	case 2:
+		var v2hdr carv2.Header
+		if _, err := v2hdr.ReadFrom(io.NewSectionReader(r, carv2.PragmaSize, size-carv2.PragmaSize)); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return 0, 0, nil, errCarHeaderIncomplete
+			}
+			return 0, 0, nil, fmt.Errorf("invalid CARv2 header: %w", err)
+		}
+
+		v1hdr, err := readV1Header(int64(v2hdr.DataOffset))
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		if v1hdr.Version != 1 {
+			return 0, 0, nil, fmt.Errorf("invalid CARv2 data payload: expected CARv1 header but got version %d", v1hdr.Version)
+		}
+		return int64(v2hdr.DataOffset), int64(v2hdr.DataSize), v1hdr.Roots, nil
+	default:
+		return 0, 0, nil, fmt.Errorf("unsupported CAR version %d", hdr.Version)
+	}
+}
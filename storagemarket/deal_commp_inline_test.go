@@ -0,0 +1,213 @@
+package storagemarket
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/storagemarket/logs"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	carv2 "github.com/ipld/go-car/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInlineCommp(t *testing.T) {
+	for _, carVersion := range []CarVersion{CarVersion1, CarVersion2} {
+		carVersion := carVersion
+		t.Run(fmt.Sprintf("car version %d", carVersion), func(t *testing.T) {
+			dl := newTestDealLogger(t)
+			root, carPath := createTestCar(t, carVersion)
+			carBytes, err := os.ReadFile(carPath)
+			require.NoError(t, err)
+
+			deal := newInlineCommpTestDeal(t, root)
+
+			// Download the first half of the file
+			ic := newInlineCommp(dl, deal, true)
+			go ic.run(func() {})
+			half := len(carBytes) / 2
+			writeInChunks(t, ic, deal.InboundFilePath, carBytes, 0, half)
+			require.NoError(t, ic.stop())
+
+			// The state was saved so that the calculation can be resumed
+			_, err = os.Stat(inlineCommpStatePath(deal.InboundFilePath))
+			require.NoError(t, err)
+
+			// Resume the download
+			ic = newInlineCommp(dl, deal, true)
+			go ic.run(func() {})
+			writeInChunks(t, ic, deal.InboundFilePath, carBytes, half, len(carBytes))
+			require.NoError(t, ic.stop())
+
+			// The inline commp should be the same as commp calculated over
+			// the whole file
+			pi, err := ic.finish()
+			require.NoError(t, err)
+			ic.remove()
+			_, err = os.Stat(inlineCommpStatePath(deal.InboundFilePath))
+			require.ErrorIs(t, err, os.ErrNotExist)
+
+			expected, err := GenerateCommP(carPath)
+			require.NoError(t, err)
+			pieceCid, derr := padPieceCommitment(pi, expected.Size)
+			require.Nil(t, derr)
+			require.Equal(t, expected.PieceCID, pieceCid)
+		})
+	}
+}
+
+func TestInlineCommpHeaderOnly(t *testing.T) {
+	dl := newTestDealLogger(t)
+	root, carPath := createTestCar(t, CarVersion2)
+	carBytes, err := os.ReadFile(carPath)
+	require.NoError(t, err)
+
+	// When commp is not calculated inline only the header is verified
+	deal := newInlineCommpTestDeal(t, root)
+	ic := newInlineCommp(dl, deal, false)
+	go ic.run(func() {})
+	writeInChunks(t, ic, deal.InboundFilePath, carBytes, 0, len(carBytes))
+	require.NoError(t, ic.stop())
+
+	pi, err := ic.finish()
+	require.NoError(t, err)
+	require.Nil(t, pi)
+	ic.remove()
+}
+
+func TestInlineCommpInvalidData(t *testing.T) {
+	_, carPath := createTestCar(t, CarVersion2)
+	carBytes, err := os.ReadFile(carPath)
+	require.NoError(t, err)
+
+	tcs := []struct {
+		name         string
+		data         []byte
+		dealDataRoot cid.Cid
+		expectedErr  string
+	}{{
+		name:         "deal data root is not a CAR root",
+		data:         carBytes,
+		dealDataRoot: testutil.GenerateCid(),
+		expectedErr:  "is not one of the CAR file roots",
+	}, {
+		name:        "not a CAR file",
+		data:        bytes.Repeat([]byte{1}, 1024),
+		expectedErr: "invalid CAR header",
+	}}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			deal := newInlineCommpTestDeal(t, tc.dealDataRoot)
+			ic := newInlineCommp(newTestDealLogger(t), deal, true)
+
+			// Expect the transfer to be stopped as soon as the invalid data
+			// arrives
+			failed := make(chan struct{})
+			go ic.run(func() { close(failed) })
+			writeInChunks(t, ic, deal.InboundFilePath, tc.data, 0, 512)
+			select {
+			case <-failed:
+			case <-time.After(5 * time.Second):
+				require.Fail(t, "timed out waiting for inline commp to fail")
+			}
+
+			err := ic.stop()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.expectedErr)
+			ic.remove()
+		})
+	}
+}
+
+func TestInlineCommpEmptyFile(t *testing.T) {
+	deal := newInlineCommpTestDeal(t, cid.Undef)
+	ic := newInlineCommp(newTestDealLogger(t), deal, true)
+	go ic.run(func() {})
+	require.NoError(t, ic.stop())
+
+	_, err := ic.finish()
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	ic.remove()
+}
+
+func TestReadCarHeader(t *testing.T) {
+	root, carPath := createTestCar(t, CarVersion2)
+	carBytes, err := os.ReadFile(carPath)
+	require.NoError(t, err)
+	rd, err := carv2.OpenReader(carPath)
+	require.NoError(t, err)
+	defer rd.Close() //nolint:errcheck
+
+	// Reading a partial header should return errCarHeaderIncomplete
+	for _, size := range []int64{1, carv2.PragmaSize, carv2.PragmaSize + carv2.HeaderSize, int64(rd.Header.DataOffset) + 1} {
+		_, _, _, err := readCarHeader(bytes.NewReader(carBytes), size)
+		require.ErrorIs(t, err, errCarHeaderIncomplete)
+	}
+
+	dataOffset, dataSize, roots, err := readCarHeader(bytes.NewReader(carBytes), int64(len(carBytes)))
+	require.NoError(t, err)
+	require.EqualValues(t, rd.Header.DataOffset, dataOffset)
+	require.EqualValues(t, rd.Header.DataSize, dataSize)
+	require.Equal(t, []cid.Cid{root}, roots)
+}
+
+func newTestDealLogger(t *testing.T) *logs.DealLogger {
+	sqldb := db.CreateTestTmpDB(t)
+	require.NoError(t, db.CreateAllBoostTables(context.Background(), sqldb, sqldb))
+	return logs.NewDealLogger(db.NewLogsDB(sqldb))
+}
+
+func newInlineCommpTestDeal(t *testing.T, root cid.Cid) *types.ProviderDealState {
+	inboundPath := filepath.Join(t.TempDir(), "inbound.car")
+	require.NoError(t, os.WriteFile(inboundPath, nil, 0644))
+	return &types.ProviderDealState{
+		DealUuid:        uuid.New(),
+		DealDataRoot:    root,
+		InboundFilePath: inboundPath,
+	}
+}
+
+func createTestCar(t *testing.T, carVersion CarVersion) (cid.Cid, string) {
+	dir := t.TempDir()
+	randomFilepath, err := testutil.CreateRandomFile(dir, 1, 2000000)
+	require.NoError(t, err)
+	root, carPath, err := testutil.CreateDenseCARv2(dir, randomFilepath)
+	require.NoError(t, err)
+	if carVersion == CarVersion2 {
+		return root, carPath
+	}
+
+	carv1Path := filepath.Join(dir, "v1.car")
+	require.NoError(t, carv2.ExtractV1File(carPath, carv1Path))
+	return root, carv1Path
+}
+
+// writeInChunks writes the bytes of data from start to end to the file,
+// notifying the inline commp calculation after each chunk is written
+func writeInChunks(t *testing.T, ic *inlineCommp, path string, data []byte, start int, end int) {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	defer f.Close() //nolint:errcheck
+
+	const chunkSize = 64 * 1024
+	for offset := start; offset < end; offset += chunkSize {
+		chunkEnd := offset + chunkSize
+		if chunkEnd > end {
+			chunkEnd = end
+		}
+		_, err := f.WriteAt(data[offset:chunkEnd], int64(offset))
+		require.NoError(t, err)
+		ic.notify(int64(chunkEnd))
+	}
+}
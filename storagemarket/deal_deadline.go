@@ -0,0 +1,228 @@
+package storagemarket
+
+import (
+	"fmt"
+	"time"
+
+	smtypes "github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/go-state-types/abi"
+	lbuild "github.com/filecoin-project/lotus/build"
+	"github.com/google/uuid"
+)
+
+// DeadlineMonitorConfig configures the monitor that checks whether in-flight
+// deals can still be sealed by their start epoch
+type DeadlineMonitorConfig struct {
+	// How often to check in-flight deals. Zero disables the monitor.
+	CheckPeriod time.Duration
+	// A deal is at risk if the time left until its start epoch is less than
+	// the estimated time to complete the deal plus this margin
+	WarnMargin time.Duration
+	// The transfer priority given to queued deals that are at risk
+	AtRiskPriority int
+	// Whether to fail deals that can no longer be sealed by their start
+	// epoch, so that their funds and staging space are released
+	FailHopeless bool
+	// Gets the expected time it takes to seal a deal once it has been
+	// handed off to the sealer
+	ExpectedSealDuration func() (time.Duration, error)
+}
+
+type deadlineStatus int
+
+const (
+	// The deal is expected to be sealed before its start epoch
+	deadlineOK deadlineStatus = iota
+	// The deal is expected to be sealed less than WarnMargin before its
+	// start epoch
+	deadlineAtRisk
+	// The deal is not expected to be sealed before its start epoch
+	deadlineHopeless
+)
+
+// deadlineEstimate is an estimate of whether a deal can be sealed before
+// its start epoch
+type deadlineEstimate struct {
+	// The time left until the deal's start epoch
+	timeLeft time.Duration
+	// The estimated time to finish transferring the deal data. Zero if the
+	// transfer is complete or the transfer rate is not yet known.
+	transferTime time.Duration
+	// The expected time to seal the deal
+	sealDuration time.Duration
+}
+
+// estimateDeadline estimates whether the deal can be sealed before its start
+// epoch, given the number of bytes received so far and the current transfer
+// rate in bytes per second
+func estimateDeadline(deal *smtypes.ProviderDealState, epoch abi.ChainEpoch, sealDuration time.Duration, received uint64, rate float64) deadlineEstimate {
+	epochsLeft := deal.ClientDealProposal.Proposal.StartEpoch - epoch
+	est := deadlineEstimate{
+		timeLeft:     time.Duration(epochsLeft) * time.Duration(lbuild.BlockDelaySecs) * time.Second,
+		sealDuration: sealDuration,
+	}
+
+	// Offline deals have no transfer, and there is nothing left to transfer
+	// for online deals that have reached the Transferred checkpoint
+	if deal.IsOffline || deal.Checkpoint >= dealcheckpoints.Transferred {
+		return est
+	}
+	if rate > 0 && deal.Transfer.Size > received {
+		remaining := float64(deal.Transfer.Size - received)
+		est.transferTime = time.Duration(remaining / rate * float64(time.Second))
+	}
+	return est
+}
+
+func (e deadlineEstimate) status(warnMargin time.Duration) deadlineStatus {
+	required := e.transferTime + e.sealDuration
+	if e.timeLeft < required {
+		return deadlineHopeless
+	}
+	if e.timeLeft < required+warnMargin {
+		return deadlineAtRisk
+	}
+	return deadlineOK
+}
+
+// transferRate calculates the average transfer rate in bytes per second from
+// transfer samples. It returns zero if the rate can't be calculated.
+func transferRate(points []TransferPoint) float64 {
+	if len(points) < 2 {
+		return 0
+	}
+	first, last := points[0], points[len(points)-1]
+	elapsed := last.At.Sub(first.At).Seconds()
+	if elapsed <= 0 || last.Bytes <= first.Bytes {
+		return 0
+	}
+	return float64(last.Bytes-first.Bytes) / elapsed
+}
+
+// runDeadlineMonitor periodically checks whether in-flight deals can still be
+// sealed by their start epoch. Deals that are at risk are prioritized in the
+// transfer queue, and deals that can't make it are failed.
+func (p *Provider) runDeadlineMonitor() {
+	cfg := p.config.DeadlineMonitor
+	ticker := time.NewTicker(cfg.CheckPeriod)
+	defer ticker.Stop()
+
+	// The deals that a warning has already been logged for
+	warned := make(map[uuid.UUID]struct{})
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.checkDeadlines(warned); err != nil {
+				log.Warnw("checking deal start epoch deadlines", "err", err)
+			}
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *Provider) checkDeadlines(warned map[uuid.UUID]struct{}) error {
+	cfg := p.config.DeadlineMonitor
+	sealDuration, err := cfg.ExpectedSealDuration()
+	if err != nil {
+		return fmt.Errorf("getting expected seal duration: %w", err)
+	}
+
+	head, err := p.fullnodeApi.ChainHead(p.ctx)
+	if err != nil {
+		return fmt.Errorf("getting chain head: %w", err)
+	}
+
+	deals, err := p.dealsDB.ListActive(p.ctx)
+	if err != nil {
+		return fmt.Errorf("listing active deals: %w", err)
+	}
+
+	active := make(map[uuid.UUID]struct{}, len(deals))
+	for _, deal := range deals {
+		// Once a deal has been handed off to the sealer, it's up to the
+		// sealer to seal it in time
+		if deal.Checkpoint >= dealcheckpoints.AddedPiece {
+			continue
+		}
+		active[deal.DealUuid] = struct{}{}
+
+		received := p.transfers.getBytes(deal.DealUuid)
+		rate := transferRate(p.transfers.transfer(deal.DealUuid))
+		est := estimateDeadline(deal, head.Height(), sealDuration, received, rate)
+		switch est.status(cfg.WarnMargin) {
+		case deadlineAtRisk:
+			p.deadlineAtRisk(deal, est, warned)
+		case deadlineHopeless:
+			p.deadlineHopeless(deal, est, warned)
+		}
+	}
+
+	// Forget about deals that are no longer in flight
+	for dealUuid := range warned {
+		if _, ok := active[dealUuid]; !ok {
+			delete(warned, dealUuid)
+		}
+	}
+	return nil
+}
+
+func (p *Provider) deadlineAtRisk(deal *smtypes.ProviderDealState, est deadlineEstimate, warned map[uuid.UUID]struct{}) {
+	// If the deal is waiting in the transfer queue, move it up the queue
+	raised := p.xferLimiter.raisePriority(deal.DealUuid, p.config.DeadlineMonitor.AtRiskPriority)
+
+	if _, ok := warned[deal.DealUuid]; ok && !raised {
+		return
+	}
+	warned[deal.DealUuid] = struct{}{}
+	p.dealLogger.Warnw(deal.DealUuid, "deal is at risk of not being sealed before its start epoch",
+		"start epoch", deal.ClientDealProposal.Proposal.StartEpoch, "time left", est.timeLeft.String(),
+		"estimated transfer time", est.transferTime.String(), "expected seal duration", est.sealDuration.String(),
+		"raised transfer priority", raised)
+}
+
+func (p *Provider) deadlineHopeless(deal *smtypes.ProviderDealState, est deadlineEstimate, warned map[uuid.UUID]struct{}) {
+	err := fmt.Errorf("deal cannot be sealed before its start epoch %d: time left %s, estimated transfer time %s, expected seal duration %s",
+		deal.ClientDealProposal.Proposal.StartEpoch, est.timeLeft, est.transferTime, est.sealDuration)
+
+	// Once a deal has been published the collateral is locked on chain, so
+	// the deal is allowed to continue in case it can still make it
+	if !p.config.DeadlineMonitor.FailHopeless || deal.Checkpoint >= dealcheckpoints.Published {
+		if _, ok := warned[deal.DealUuid]; !ok {
+			warned[deal.DealUuid] = struct{}{}
+			p.dealLogger.Warnw(deal.DealUuid, "deal is not expected to be sealed before its start epoch", "err", err)
+		}
+		return
+	}
+
+	dh := p.getDealHandler(deal.DealUuid)
+	if dh == nil {
+		return
+	}
+
+	// If the deal is running and hasn't yet finished transferring data,
+	// stop the transfer. The deal will fail with the expiry error.
+	if dh.isRunning() {
+		if !deal.IsOffline && deal.Checkpoint < dealcheckpoints.Transferred {
+			p.dealLogger.Infow(deal.DealUuid, "stopping transfer for deal that cannot be sealed before its start epoch")
+			dh.expire(err)
+		}
+		return
+	}
+
+	// The deal is not running (eg it's paused, or it's an offline deal
+	// waiting for data to be imported): prevent it from being restarted
+	// while it is failed
+	if !dh.setRunning(true) {
+		return
+	}
+	defer dh.setRunning(false)
+
+	// Make sure the deal hasn't progressed since it was read from the DB
+	current, dberr := p.dealsDB.ByID(p.ctx, deal.DealUuid)
+	if dberr != nil || current.Checkpoint != deal.Checkpoint {
+		return
+	}
+	p.failDeal(dh.Publisher, current, err, false)
+}
@@ -0,0 +1,134 @@
+package storagemarket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	smtypes "github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/go-state-types/abi"
+	lbuild "github.com/filecoin-project/lotus/build"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadlineEstimate(t *testing.T) {
+	epochDuration := time.Duration(lbuild.BlockDelaySecs) * time.Second
+	sealDuration := time.Hour
+	warnMargin := 30 * time.Minute
+
+	genDeal := func(startEpochIn time.Duration) *smtypes.ProviderDealState {
+		dl := generateDeal()
+		dl.ClientDealProposal.Proposal.StartEpoch = 1000 + abi.ChainEpoch(startEpochIn/epochDuration)
+		dl.Transfer.Size = 3600 * 1024
+		dl.Checkpoint = dealcheckpoints.Accepted
+		return dl
+	}
+
+	tcs := []struct {
+		name         string
+		startEpochIn time.Duration
+		received     uint64
+		rate         float64
+		offline      bool
+		checkpoint   dealcheckpoints.Checkpoint
+		expected     deadlineStatus
+	}{{
+		name:         "plenty of time",
+		startEpochIn: 24 * time.Hour,
+		rate:         1024,
+		expected:     deadlineOK,
+	}, {
+		name:         "transfer rate unknown",
+		startEpochIn: 2 * time.Hour,
+		expected:     deadlineOK,
+	}, {
+		name:         "slow transfer puts deal at risk",
+		startEpochIn: 2*time.Hour + 20*time.Minute,
+		rate:         1024,
+		expected:     deadlineAtRisk,
+	}, {
+		name:         "received bytes are taken into account",
+		startEpochIn: 2*time.Hour + 20*time.Minute,
+		received:     1800 * 1024,
+		rate:         1024,
+		expected:     deadlineOK,
+	}, {
+		name:         "transfer too slow to make start epoch",
+		startEpochIn: 90 * time.Minute,
+		rate:         1024,
+		expected:     deadlineHopeless,
+	}, {
+		name:         "transfer time ignored once transferred",
+		startEpochIn: 90 * time.Minute,
+		rate:         1024,
+		checkpoint:   dealcheckpoints.Transferred,
+		expected:     deadlineOK,
+	}, {
+		name:         "transfer time ignored for offline deals",
+		startEpochIn: 90 * time.Minute,
+		rate:         1024,
+		offline:      true,
+		expected:     deadlineOK,
+	}, {
+		name:         "not enough time to seal",
+		startEpochIn: 30 * time.Minute,
+		offline:      true,
+		expected:     deadlineHopeless,
+	}}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			dl := genDeal(tc.startEpochIn)
+			dl.IsOffline = tc.offline
+			if tc.checkpoint != 0 {
+				dl.Checkpoint = tc.checkpoint
+			}
+			est := estimateDeadline(dl, 1000, sealDuration, tc.received, tc.rate)
+			require.Equal(t, tc.expected, est.status(warnMargin))
+		})
+	}
+}
+
+func TestTransferRate(t *testing.T) {
+	now := time.Now()
+	require.Zero(t, transferRate(nil))
+	require.Zero(t, transferRate([]TransferPoint{{At: now, Bytes: 100}}))
+
+	rate := transferRate([]TransferPoint{
+		{At: now, Bytes: 100},
+		{At: now.Add(time.Second), Bytes: 150},
+		{At: now.Add(2 * time.Second), Bytes: 300},
+	})
+	require.Equal(t, float64(100), rate)
+}
+
+func TestTransferLimiterRaisePriority(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tl, err := newTransferLimiter(TransferLimiterConfig{
+		MaxConcurrent:    1,
+		StallCheckPeriod: time.Millisecond,
+		StallTimeout:     30 * time.Second,
+	}, httpTransferHost)
+	require.NoError(t, err)
+
+	deal := generateDeal()
+	go func() {
+		_ = tl.waitInQueue(ctx, deal)
+	}()
+	require.Eventually(t, func() bool { return tl.transfersCount() == 1 }, time.Second, time.Millisecond)
+
+	// The priority is raised if it's lower than the requested priority
+	require.True(t, tl.raisePriority(deal.DealUuid, 10))
+	require.Equal(t, 10, tl.queue()[0].Priority)
+
+	// The priority is not lowered
+	require.False(t, tl.raisePriority(deal.DealUuid, 5))
+	require.Equal(t, 10, tl.queue()[0].Priority)
+
+	// The priority of a started transfer can't be changed
+	tl.check(time.Now())
+	require.False(t, tl.raisePriority(deal.DealUuid, 20))
+}
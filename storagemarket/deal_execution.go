@@ -174,7 +174,7 @@ func (p *Provider) execDealUptoAddPiece(ctx context.Context, deal *types.Provide
 		p.dealLogger.Infow(deal.DealUuid, "deal data-transfer can no longer be cancelled")
 	} else if deal.Checkpoint < dealcheckpoints.Transferred {
 		// verify CommP matches for an offline deal
-		if err := p.verifyCommP(deal); err != nil {
+		if err := p.verifyCommP(deal, nil); err != nil {
 			err.error = fmt.Errorf("error when matching commP for imported data for offline deal: %w", err)
 			return err
 		}
@@ -289,6 +289,15 @@ func (p *Provider) transferAndVerify(dh *dealHandler, pub event.Emitter, deal *s
 	// Wait for a spot in the transfer queue
 	err := p.xferLimiter.waitInQueue(ctx, deal)
 	if err != nil {
+		// If the deal can no longer be sealed before its start epoch,
+		// it's non-recoverable
+		if xerr := dh.expired(); xerr != nil {
+			return &dealMakingError{
+				retry: types.DealRetryFatal,
+				error: xerr,
+			}
+		}
+
 		// If the transfer failed because the user cancelled the
 		// transfer, it's non-recoverable
 		if dh.TransferCancelledByUser() {
@@ -318,13 +327,30 @@ func (p *Provider) transferAndVerify(dh *dealHandler, pub event.Emitter, deal *s
 	tctx, cancel := context.WithDeadline(ctx, transferStart.Add(p.config.MaxTransferDuration))
 	defer cancel()
 
+	// Verify the CAR header and calculate commp as the data arrives. If
+	// commp is calculated remotely, only the CAR header is verified inline.
+	// If the data is invalid, the transfer is stopped.
+	ic := newInlineCommp(p.dealLogger, deal, !p.config.RemoteCommp)
+	go ic.run(cancel)
+
+	// Limit the bandwidth of the transfer, globally and by host and client.
+	// The host was already parsed when the transfer was queued.
+	host, _ := p.Transports.Host(deal.Transfer)
+	rl := p.bwLimiter.transferLimiter(host, deal.ClientDealProposal.Proposal.Client.String())
+	defer rl.release()
+
 	st := time.Now()
-	handler, err := p.Transport.Execute(tctx, deal.Transfer.Params, &transporttypes.TransportDealInfo{
-		OutputFile: deal.InboundFilePath,
-		DealUuid:   deal.DealUuid,
-		DealSize:   int64(deal.Transfer.Size),
+	handler, err := p.Transports.Execute(tctx, deal.Transfer.Params, &transporttypes.TransportDealInfo{
+		OutputFile:   deal.InboundFilePath,
+		DealUuid:     deal.DealUuid,
+		DealSize:     int64(deal.Transfer.Size),
+		TransferType: deal.Transfer.Type,
+		Client:       deal.ClientDealProposal.Proposal.Client,
+		RateLimiter:  rl,
 	})
 	if err != nil {
+		_ = ic.stop()
+		ic.remove()
 		return &dealMakingError{
 			retry: smtypes.DealRetryFatal,
 			error: fmt.Errorf("transferAndVerify failed to start data transfer: %w", err),
@@ -332,7 +358,22 @@ func (p *Provider) transferAndVerify(dh *dealHandler, pub event.Emitter, deal *s
 	}
 
 	// wait for data-transfer to finish
-	if err := p.waitForTransferFinish(tctx, handler, pub, deal); err != nil {
+	err = p.waitForTransferFinish(tctx, handler, pub, deal, ic)
+	if icErr := ic.stop(); icErr != nil {
+		ic.remove()
+		return &dealMakingError{
+			retry: types.DealRetryFatal,
+			error: fmt.Errorf("invalid deal data: data transfer stopped after %d bytes: %w", deal.NBytesReceived, icErr),
+		}
+	}
+	if err != nil {
+		if xerr := dh.expired(); xerr != nil {
+			return &dealMakingError{
+				retry: types.DealRetryFatal,
+				error: fmt.Errorf("data transfer stopped after %d bytes: %w", deal.NBytesReceived, xerr),
+			}
+		}
+
 		// If the transfer failed because the user cancelled the
 		// transfer, it's non-recoverable
 		if dh.TransferCancelledByUser() {
@@ -366,8 +407,18 @@ func (p *Provider) transferAndVerify(dh *dealHandler, pub event.Emitter, deal *s
 	p.dealLogger.Infow(deal.DealUuid, "deal data-transfer completed successfully", "bytes received", deal.NBytesReceived, "time taken",
 		time.Since(st).String())
 
+	// Finish verifying the downloaded data
+	pi, icErr := ic.finish()
+	ic.remove()
+	if icErr != nil {
+		return &dealMakingError{
+			retry: types.DealRetryFatal,
+			error: fmt.Errorf("failed to verify CommP: %w", icErr),
+		}
+	}
+
 	// Verify CommP matches
-	if err := p.verifyCommP(deal); err != nil {
+	if err := p.verifyCommP(deal, pi); err != nil {
 		err.error = fmt.Errorf("failed to verify CommP: %w", err.error)
 		return err
 	}
@@ -378,7 +429,7 @@ func (p *Provider) transferAndVerify(dh *dealHandler, pub event.Emitter, deal *s
 
 const OneGib = 1024 * 1024 * 1024
 
-func (p *Provider) waitForTransferFinish(ctx context.Context, handler transport.Handler, pub event.Emitter, deal *types.ProviderDealState) error {
+func (p *Provider) waitForTransferFinish(ctx context.Context, handler transport.Handler, pub event.Emitter, deal *types.ProviderDealState, ic *inlineCommp) error {
 	defer handler.Close()
 	defer p.transfers.complete(deal.DealUuid)
 
@@ -412,6 +463,7 @@ func (p *Provider) waitForTransferFinish(ctx context.Context, handler transport.
 				return evt.Error
 			}
 			deal.NBytesReceived = evt.NBytesReceived
+			ic.notify(evt.NBytesContiguous)
 			p.transfers.setBytes(deal.DealUuid, uint64(evt.NBytesReceived))
 			p.xferLimiter.setBytes(deal.DealUuid, uint64(evt.NBytesReceived))
 			p.fireEventDealUpdate(pub, deal)
@@ -800,6 +852,7 @@ func (p *Provider) failDeal(pub event.Emitter, deal *smtypes.ProviderDealState,
 	}
 
 	p.saveDealToDB(pub, deal)
+	p.fireEventDealCheckpoint(deal, true)
 	p.cleanupDeal(deal)
 }
 
@@ -828,6 +881,7 @@ func (p *Provider) cleanupDeal(deal *types.ProviderDealState) {
 	// remove the temp file created for inbound deal data if it is not an offline deal
 	if !deal.IsOffline {
 		_ = os.Remove(deal.InboundFilePath)
+		_ = os.Remove(inlineCommpStatePath(deal.InboundFilePath))
 	}
 
 	if deal.Checkpoint == dealcheckpoints.Complete {
@@ -874,6 +928,10 @@ func (p *Provider) fireEventDealUpdate(pub event.Emitter, deal *types.ProviderDe
 	}
 }
 
+func (p *Provider) fireEventDealCheckpoint(deal *types.ProviderDealState, failed bool) {
+	p.checkpointPS.emit(DealCheckpointEvent{Deal: *deal, Failed: failed})
+}
+
 func (p *Provider) updateCheckpoint(pub event.Emitter, deal *types.ProviderDealState, ckpt dealcheckpoints.Checkpoint) *dealMakingError {
 	prev := deal.Checkpoint
 	deal.Checkpoint = ckpt
@@ -887,6 +945,7 @@ func (p *Provider) updateCheckpoint(pub event.Emitter, deal *types.ProviderDealS
 	}
 	p.dealLogger.Infow(deal.DealUuid, "updated deal checkpoint in DB", "old checkpoint", prev.String(), "new checkpoint", ckpt.String())
 	p.fireEventDealUpdate(pub, deal)
+	p.fireEventDealCheckpoint(deal, false)
 
 	return nil
 }
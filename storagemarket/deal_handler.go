@@ -75,6 +75,10 @@ type dealHandler struct {
 	transferFinished bool
 	transferErr      error
 
+	// Set if the deal was stopped because it can no longer be sealed
+	// before its start epoch
+	expiredErr atomic.Error
+
 	activeSubsLk sync.RWMutex
 	activeSubs   map[*updatesSubscription]struct{}
 
@@ -180,6 +184,19 @@ func (dh *dealHandler) cancelTransfer() error {
 	}
 }
 
+// expire cancels the transfer because the deal can no longer be sealed before
+// its start epoch. The deal fails with the given error.
+func (dh *dealHandler) expire(err error) {
+	dh.expiredErr.Store(err)
+	dh.transferCancel()
+}
+
+// expired returns the error the deal was expired with, or nil if the deal
+// has not been expired
+func (dh *dealHandler) expired() error {
+	return dh.expiredErr.Load()
+}
+
 // setCancelTransferResponse idempotently sets the return value of calls to cancelTransfer
 func (dh *dealHandler) setCancelTransferResponse(err error) {
 	dh.tdOnce.Do(func() {
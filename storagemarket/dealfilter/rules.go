@@ -0,0 +1,434 @@
+package dealfilter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/dustin/go-humanize"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("dealfilter")
+
+const (
+	ActionAccept = "accept"
+	ActionReject = "reject"
+)
+
+// RulesFile is the format of a deal filter rules file, eg:
+//
+//	DefaultAction = "accept"
+//
+//	[[Rule]]
+//	Name = "no-large-unverified-deals"
+//	Action = "reject"
+//	Reason = "unverified deals must be smaller than 8GiB"
+//	Verified = false
+//	MinPieceSize = "8GiB"
+//
+// The rules are evaluated in order, and the action of the first rule that
+// matches the deal is applied. If no rule matches, the default action is
+// applied.
+type RulesFile struct {
+	// The action to take if no rule matches: "accept" (the default) or "reject"
+	DefaultAction string
+	Rule          []Rule
+}
+
+// Rule matches a deal if all of the rule's conditions are met.
+// Conditions that are not set always match.
+type Rule struct {
+	// The name of the rule, recorded in the proposal log when the rule
+	// rejects a deal
+	Name string
+	// The action to take when the rule matches: "accept" or "reject"
+	Action string
+	// The reason sent to the client when the rule rejects a deal
+	Reason string
+
+	// The deal's client is one of these addresses
+	Clients []string
+	// The deal's piece size is at least / at most this size (eg "32GiB")
+	MinPieceSize string
+	MaxPieceSize string
+	// The deal's storage price per epoch in attoFIL is at least / at most
+	// this amount
+	MinPricePerEpoch string
+	MaxPricePerEpoch string
+	// The deal is / is not a verified deal
+	Verified *bool
+	// The host that the deal data will be transferred from is one of these
+	// hosts, including the port if the transfer url has one (eg
+	// "example.com:8080"). Offline deals don't have a transfer host.
+	TransferHosts []string
+	// The deal's label matches this regular expression
+	Label string
+
+	// The number of sectors in the sealing pipeline in each state is at
+	// least the given number (eg { PreCommit1 = 10 })
+	SectorsInStateAtLeast map[string]int
+	// The funds available in escrow for deal collateral are below this
+	// amount in attoFIL
+	EscrowAvailableBelow string
+	// The balance of the deal collateral wallet is below this amount in
+	// attoFIL
+	CollateralBalanceBelow string
+	// The free space in the staging area is below this size (eg "1TiB")
+	StorageFreeBelow string
+}
+
+// rule is a Rule with its conditions parsed
+type rule struct {
+	name   string
+	accept bool
+	reason string
+
+	clients                map[address.Address]struct{}
+	minPieceSize           *uint64
+	maxPieceSize           *uint64
+	minPrice               *big.Int
+	maxPrice               *big.Int
+	verified               *bool
+	transferHosts          map[string]struct{}
+	label                  *regexp.Regexp
+	sectorsInStateAtLeast  map[api.SectorState]int
+	escrowAvailableBelow   *big.Int
+	collateralBalanceBelow *big.Int
+	storageFreeBelow       *uint64
+}
+
+type ruleSet struct {
+	defaultAccept bool
+	rules         []*rule
+}
+
+// parseRules parses a deal filter rules file in TOML format
+func parseRules(data string) (*ruleSet, error) {
+	var f RulesFile
+	md, err := toml.Decode(data, &f)
+	if err != nil {
+		return nil, err
+	}
+	// Reject unknown keys so that a typo in a condition doesn't silently
+	// cause a rule to match every deal
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, 0, len(undecoded))
+		for _, k := range undecoded {
+			keys = append(keys, k.String())
+		}
+		return nil, fmt.Errorf("unknown keys: %s", strings.Join(keys, ", "))
+	}
+
+	rs := &ruleSet{}
+	rs.defaultAccept, err = parseAction(f.DefaultAction, true)
+	if err != nil {
+		return nil, fmt.Errorf("DefaultAction: %w", err)
+	}
+
+	names := make(map[string]struct{}, len(f.Rule))
+	for i, r := range f.Rule {
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d: rule must have a Name", i+1)
+		}
+		if _, ok := names[r.Name]; ok {
+			return nil, fmt.Errorf("rule %d: duplicate rule name '%s'", i+1, r.Name)
+		}
+		names[r.Name] = struct{}{}
+
+		parsed, err := parseRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("rule '%s': %w", r.Name, err)
+		}
+		rs.rules = append(rs.rules, parsed)
+	}
+
+	return rs, nil
+}
+
+func parseAction(action string, dflt bool) (bool, error) {
+	switch action {
+	case "":
+		return dflt, nil
+	case ActionAccept:
+		return true, nil
+	case ActionReject:
+		return false, nil
+	default:
+		return false, fmt.Errorf("action must be '%s' or '%s' but got '%s'", ActionAccept, ActionReject, action)
+	}
+}
+
+func parseRule(r Rule) (*rule, error) {
+	if r.Action == "" {
+		return nil, errors.New("rule must have an Action")
+	}
+	accept, err := parseAction(r.Action, false)
+	if err != nil {
+		return nil, err
+	}
+
+	pr := &rule{name: r.Name, accept: accept, reason: r.Reason, verified: r.Verified}
+
+	if len(r.Clients) > 0 {
+		pr.clients = make(map[address.Address]struct{}, len(r.Clients))
+		for _, c := range r.Clients {
+			addr, err := address.NewFromString(c)
+			if err != nil {
+				return nil, fmt.Errorf("parsing client address '%s': %w", c, err)
+			}
+			pr.clients[addr] = struct{}{}
+		}
+	}
+
+	if len(r.TransferHosts) > 0 {
+		pr.transferHosts = make(map[string]struct{}, len(r.TransferHosts))
+		for _, h := range r.TransferHosts {
+			pr.transferHosts[strings.ToLower(h)] = struct{}{}
+		}
+	}
+
+	if r.Label != "" {
+		pr.label, err = regexp.Compile(r.Label)
+		if err != nil {
+			return nil, fmt.Errorf("parsing Label regular expression: %w", err)
+		}
+	}
+
+	if len(r.SectorsInStateAtLeast) > 0 {
+		pr.sectorsInStateAtLeast = make(map[api.SectorState]int, len(r.SectorsInStateAtLeast))
+		for state, count := range r.SectorsInStateAtLeast {
+			pr.sectorsInStateAtLeast[api.SectorState(state)] = count
+		}
+	}
+
+	sizes := []struct {
+		name string
+		val  string
+		dst  **uint64
+	}{
+		{"MinPieceSize", r.MinPieceSize, &pr.minPieceSize},
+		{"MaxPieceSize", r.MaxPieceSize, &pr.maxPieceSize},
+		{"StorageFreeBelow", r.StorageFreeBelow, &pr.storageFreeBelow},
+	}
+	for _, s := range sizes {
+		if s.val == "" {
+			continue
+		}
+		sz, err := humanize.ParseBytes(s.val)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", s.name, err)
+		}
+		*s.dst = &sz
+	}
+
+	amounts := []struct {
+		name string
+		val  string
+		dst  **big.Int
+	}{
+		{"MinPricePerEpoch", r.MinPricePerEpoch, &pr.minPrice},
+		{"MaxPricePerEpoch", r.MaxPricePerEpoch, &pr.maxPrice},
+		{"EscrowAvailableBelow", r.EscrowAvailableBelow, &pr.escrowAvailableBelow},
+		{"CollateralBalanceBelow", r.CollateralBalanceBelow, &pr.collateralBalanceBelow},
+	}
+	for _, a := range amounts {
+		if a.val == "" {
+			continue
+		}
+		amt, err := big.FromString(a.val)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s as attoFIL: %w", a.name, err)
+		}
+		*a.dst = &amt
+	}
+
+	return pr, nil
+}
+
+// matches returns true if the deal meets all of the rule's conditions
+func (r *rule) matches(params DealFilterParams) bool {
+	deal := params.DealParams
+	prop := deal.ClientDealProposal.Proposal
+
+	if r.clients != nil {
+		if _, ok := r.clients[prop.Client]; !ok {
+			return false
+		}
+	}
+	if r.minPieceSize != nil && uint64(prop.PieceSize) < *r.minPieceSize {
+		return false
+	}
+	if r.maxPieceSize != nil && uint64(prop.PieceSize) > *r.maxPieceSize {
+		return false
+	}
+	if r.minPrice != nil && lessThan(prop.StoragePricePerEpoch, *r.minPrice) {
+		return false
+	}
+	if r.maxPrice != nil && lessThan(*r.maxPrice, prop.StoragePricePerEpoch) {
+		return false
+	}
+	if r.verified != nil && prop.VerifiedDeal != *r.verified {
+		return false
+	}
+	if r.transferHosts != nil {
+		if deal.IsOffline {
+			return false
+		}
+		if _, ok := r.transferHosts[strings.ToLower(params.TransferHost)]; !ok {
+			return false
+		}
+	}
+	if r.label != nil && !r.label.MatchString(labelString(params)) {
+		return false
+	}
+	for state, count := range r.sectorsInStateAtLeast {
+		if params.SealingPipelineState.SectorStates[state] < count {
+			return false
+		}
+	}
+	if r.escrowAvailableBelow != nil && !lessThan(params.FundsState.Escrow.Available, *r.escrowAvailableBelow) {
+		return false
+	}
+	if r.collateralBalanceBelow != nil && !lessThan(params.FundsState.Collateral.Balance, *r.collateralBalanceBelow) {
+		return false
+	}
+	if r.storageFreeBelow != nil && params.StorageState.Free >= *r.storageFreeBelow {
+		return false
+	}
+	return true
+}
+
+// lessThan compares two amounts, treating an unset amount as zero
+func lessThan(a, b big.Int) bool {
+	if a.Nil() {
+		a = big.Zero()
+	}
+	if b.Nil() {
+		b = big.Zero()
+	}
+	return a.LessThan(b)
+}
+
+func labelString(params DealFilterParams) string {
+	label := params.DealParams.ClientDealProposal.Proposal.Label
+	if label.IsString() {
+		s, _ := label.ToString()
+		return s
+	}
+	bz, _ := label.ToBytes()
+	return string(bz)
+}
+
+// evaluate applies the first rule that matches the deal, or the default
+// action if no rule matches
+func (rs *ruleSet) evaluate(params DealFilterParams) (bool, string) {
+	for _, r := range rs.rules {
+		if !r.matches(params) {
+			continue
+		}
+		if r.accept {
+			return true, ""
+		}
+		reason := fmt.Sprintf("deal rejected by filter rule '%s'", r.name)
+		if r.reason != "" {
+			reason += ": " + r.reason
+		}
+		return false, reason
+	}
+
+	if rs.defaultAccept {
+		return true, ""
+	}
+	return false, "deal rejected by filter: no rule accepted the deal"
+}
+
+// RulesFilter is a storage deal filter that evaluates the rules in a rules
+// file. The rules file is reloaded when it changes.
+type RulesFilter struct {
+	path string
+
+	lk      sync.Mutex
+	modTime time.Time
+	rules   *ruleSet
+}
+
+// NewRulesFilter loads the rules file at the given path
+func NewRulesFilter(path string) (*RulesFilter, error) {
+	f := &RulesFilter{path: path}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading deal filter rules file: %w", err)
+	}
+	if err := f.load(fi.ModTime()); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RulesFilter) load(modTime time.Time) error {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("reading deal filter rules file: %w", err)
+	}
+	rules, err := parseRules(string(data))
+	if err != nil {
+		return fmt.Errorf("parsing deal filter rules file %s: %w", f.path, err)
+	}
+
+	f.rules = rules
+	f.modTime = modTime
+	log.Infow("loaded deal filter rules", "path", f.path, "rules", len(rules.rules))
+	return nil
+}
+
+// reloadIfChanged reloads the rules file if it has been modified since it
+// was last loaded. If the file can't be loaded, the previous rules are kept.
+func (f *RulesFilter) reloadIfChanged() {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		log.Warnw("checking deal filter rules file for changes", "path", f.path, "err", err)
+		return
+	}
+	if fi.ModTime().Equal(f.modTime) {
+		return
+	}
+	if err := f.load(fi.ModTime()); err != nil {
+		log.Errorw("failed to reload deal filter rules, keeping previous rules", "err", err)
+		// Don't try to reload the file again until it changes
+		f.modTime = fi.ModTime()
+	}
+}
+
+// Filter evaluates the rules against the deal
+func (f *RulesFilter) Filter(_ context.Context, params DealFilterParams) (bool, string, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	f.reloadIfChanged()
+	accept, reason := f.rules.evaluate(params)
+	return accept, reason, nil
+}
+
+// ChainStorageDealFilters runs each filter in order, and rejects the deal
+// if any of the filters rejects it
+func ChainStorageDealFilters(filters ...StorageDealFilter) StorageDealFilter {
+	return func(ctx context.Context, params DealFilterParams) (bool, string, error) {
+		for _, filter := range filters {
+			accept, reason, err := filter(ctx, params)
+			if err != nil || !accept {
+				return accept, reason, err
+			}
+		}
+		return true, "", nil
+	}
+}
@@ -0,0 +1,309 @@
+package dealfilter
+
+import (
+	"context"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/storagemarket/funds"
+	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
+	"github.com/filecoin-project/boost/storagemarket/storagespace"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/stretchr/testify/require"
+)
+
+func dealParams(t *testing.T, client string, size abi.PaddedPieceSize, verified bool, label string, url string) DealFilterParams {
+	addr, err := address.NewFromString(client)
+	require.NoError(t, err)
+	lbl, err := market.NewLabelFromString(label)
+	require.NoError(t, err)
+
+	params := DealFilterParams{
+		DealParams: types.DealParams{
+			IsOffline: url == "",
+			ClientDealProposal: market.ClientDealProposal{
+				Proposal: market.DealProposal{
+					Client:               addr,
+					PieceSize:            size,
+					VerifiedDeal:         verified,
+					Label:                lbl,
+					StoragePricePerEpoch: abi.NewTokenAmount(100),
+				},
+			},
+		},
+		SealingPipelineState: sealingpipeline.Status{
+			SectorStates: map[api.SectorState]int{api.SectorState("PreCommit1"): 5},
+		},
+		FundsState: funds.Status{
+			Escrow:     funds.SMAEscrow{Available: abi.NewTokenAmount(1000)},
+			Collateral: funds.CollatWallet{Balance: abi.NewTokenAmount(1000)},
+		},
+		StorageState: storagespace.Status{Free: 1 << 30},
+	}
+	if url != "" {
+		params.DealParams.Transfer = types.Transfer{Type: "http"}
+		u, err := neturl.Parse(url)
+		require.NoError(t, err)
+		params.TransferHost = u.Host
+	}
+	return params
+}
+
+func TestRules(t *testing.T) {
+	testCases := []struct {
+		name   string
+		rules  string
+		params DealFilterParams
+		accept bool
+		reason string
+	}{{
+		name:   "no rules accepts by default",
+		rules:  ``,
+		params: dealParams(t, "f01000", 1<<20, false, "", ""),
+		accept: true,
+	}, {
+		name:   "default reject",
+		rules:  `DefaultAction = "reject"`,
+		params: dealParams(t, "f01000", 1<<20, false, "", ""),
+		accept: false,
+		reason: "deal rejected by filter: no rule accepted the deal",
+	}, {
+		name: "client match",
+		rules: `
+[[Rule]]
+Name = "blocked-client"
+Action = "reject"
+Reason = "client is blocked"
+Clients = ["f01000", "f01001"]
+`,
+		params: dealParams(t, "f01001", 1<<20, false, "", ""),
+		accept: false,
+		reason: "deal rejected by filter rule 'blocked-client': client is blocked",
+	}, {
+		name: "client doesn't match",
+		rules: `
+[[Rule]]
+Name = "blocked-client"
+Action = "reject"
+Clients = ["f01000"]
+`,
+		params: dealParams(t, "f01002", 1<<20, false, "", ""),
+		accept: true,
+	}, {
+		name: "first matching rule wins",
+		rules: `
+[[Rule]]
+Name = "allow-client"
+Action = "accept"
+Clients = ["f01000"]
+
+[[Rule]]
+Name = "no-unverified"
+Action = "reject"
+Verified = false
+`,
+		params: dealParams(t, "f01000", 1<<20, false, "", ""),
+		accept: true,
+	}, {
+		name: "verified and piece size",
+		rules: `
+[[Rule]]
+Name = "no-large-unverified"
+Action = "reject"
+Verified = false
+MinPieceSize = "1GiB"
+`,
+		params: dealParams(t, "f01000", 2<<30, false, "", ""),
+		accept: false,
+		reason: "deal rejected by filter rule 'no-large-unverified'",
+	}, {
+		name: "verified deal doesn't match unverified rule",
+		rules: `
+[[Rule]]
+Name = "no-large-unverified"
+Action = "reject"
+Verified = false
+MinPieceSize = "1GiB"
+`,
+		params: dealParams(t, "f01000", 2<<30, true, "", ""),
+		accept: true,
+	}, {
+		name: "price",
+		rules: `
+[[Rule]]
+Name = "cheap-deals"
+Action = "reject"
+MaxPricePerEpoch = "99"
+`,
+		params: dealParams(t, "f01000", 1<<20, false, "", ""),
+		accept: true,
+	}, {
+		name: "transfer host",
+		rules: `
+[[Rule]]
+Name = "trusted-host"
+Action = "accept"
+TransferHosts = ["data.example.com"]
+
+[[Rule]]
+Name = "online-deals"
+Action = "reject"
+Reason = "untrusted host"
+`,
+		params: dealParams(t, "f01000", 1<<20, false, "", "http://evil.example.com/data"),
+		accept: false,
+		reason: "deal rejected by filter rule 'online-deals': untrusted host",
+	}, {
+		name: "trusted transfer host",
+		rules: `
+[[Rule]]
+Name = "trusted-host"
+Action = "accept"
+TransferHosts = ["data.example.com"]
+
+[[Rule]]
+Name = "online-deals"
+Action = "reject"
+Reason = "untrusted host"
+`,
+		params: dealParams(t, "f01000", 1<<20, false, "", "http://data.example.com/data"),
+		accept: true,
+	}, {
+		name: "label",
+		rules: `
+DefaultAction = "reject"
+
+[[Rule]]
+Name = "dataset"
+Action = "accept"
+Label = "^bafy"
+`,
+		params: dealParams(t, "f01000", 1<<20, false, "bafyabc", ""),
+		accept: true,
+	}, {
+		name: "sealing pipeline",
+		rules: `
+[[Rule]]
+Name = "pipeline-full"
+Action = "reject"
+SectorsInStateAtLeast = { PreCommit1 = 5 }
+`,
+		params: dealParams(t, "f01000", 1<<20, false, "", ""),
+		accept: false,
+		reason: "deal rejected by filter rule 'pipeline-full'",
+	}, {
+		name: "funds and storage",
+		rules: `
+[[Rule]]
+Name = "low-escrow"
+Action = "reject"
+EscrowAvailableBelow = "1000"
+
+[[Rule]]
+Name = "low-storage"
+Action = "reject"
+StorageFreeBelow = "2GiB"
+`,
+		params: dealParams(t, "f01000", 1<<20, false, "", ""),
+		accept: false,
+		reason: "deal rejected by filter rule 'low-storage'",
+	}}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			rs, err := parseRules(tc.rules)
+			require.NoError(t, err)
+			accept, reason := rs.evaluate(tc.params)
+			require.Equal(t, tc.accept, accept)
+			require.Equal(t, tc.reason, reason)
+		})
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		rules string
+		err   string
+	}{{
+		name:  "unknown key",
+		rules: "[[Rule]]\nName = \"r\"\nAction = \"reject\"\nClient = [\"f01000\"]",
+		err:   "unknown keys: Rule.Client",
+	}, {
+		name:  "missing name",
+		rules: "[[Rule]]\nAction = \"reject\"",
+		err:   "rule must have a Name",
+	}, {
+		name:  "duplicate name",
+		rules: "[[Rule]]\nName = \"r\"\nAction = \"reject\"\n[[Rule]]\nName = \"r\"\nAction = \"accept\"",
+		err:   "duplicate rule name",
+	}, {
+		name:  "bad action",
+		rules: "[[Rule]]\nName = \"r\"\nAction = \"drop\"",
+		err:   "action must be",
+	}, {
+		name:  "bad size",
+		rules: "[[Rule]]\nName = \"r\"\nAction = \"reject\"\nMinPieceSize = \"big\"",
+		err:   "parsing MinPieceSize",
+	}, {
+		name:  "bad address",
+		rules: "[[Rule]]\nName = \"r\"\nAction = \"reject\"\nClients = [\"xyz\"]",
+		err:   "parsing client address",
+	}}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseRules(tc.rules)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestRulesFilterReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "rules.toml")
+	writeRules := func(rules string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(path, []byte(rules), 0644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	now := time.Now()
+	writeRules(`DefaultAction = "accept"`, now)
+	f, err := NewRulesFilter(path)
+	require.NoError(t, err)
+
+	params := dealParams(t, "f01000", 1<<20, false, "", "")
+	accept, _, err := f.Filter(ctx, params)
+	require.NoError(t, err)
+	require.True(t, accept)
+
+	// The rules are reloaded when the file changes
+	writeRules("[[Rule]]\nName = \"reject-all\"\nAction = \"reject\"", now.Add(time.Second))
+	accept, reason, err := f.Filter(ctx, params)
+	require.NoError(t, err)
+	require.False(t, accept)
+	require.Equal(t, "deal rejected by filter rule 'reject-all'", reason)
+
+	// If the new rules are invalid the previous rules are kept
+	writeRules("[[Rule]]\nName = \"bad\"", now.Add(2*time.Second))
+	accept, reason, err = f.Filter(ctx, params)
+	require.NoError(t, err)
+	require.False(t, accept)
+	require.Equal(t, "deal rejected by filter rule 'reject-all'", reason)
+
+	// Chained filters reject the deal if any filter rejects it
+	acceptAll := func(context.Context, DealFilterParams) (bool, string, error) { return true, "", nil }
+	accept, reason, err = ChainStorageDealFilters(acceptAll, f.Filter)(ctx, params)
+	require.NoError(t, err)
+	require.False(t, accept)
+	require.Equal(t, "deal rejected by filter rule 'reject-all'", reason)
+}
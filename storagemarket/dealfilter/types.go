@@ -9,7 +9,11 @@ import (
 
 // DealFilterParams is the struct that gets passed to the Storage Deal Filter
 type DealFilterParams struct {
-	DealParams           types.DealParams
+	DealParams types.DealParams
+	// The host that the deal data is downloaded from (empty for offline
+	// deals). The transfer params are not passed to the filter because they
+	// may contain sensitive information.
+	TransferHost         string
 	SealingPipelineState sealingpipeline.Status
 	FundsState           funds.Status
 	StorageState         storagespace.Status
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"sync"
 	"time"
 
 	"github.com/filecoin-project/boost-gfm/shared"
@@ -12,6 +14,7 @@ import (
 	"github.com/filecoin-project/boost/storagemarket"
 	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
 	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
 	"github.com/filecoin-project/go-address"
 	cborutil "github.com/filecoin-project/go-cbor-util"
 	"github.com/filecoin-project/lotus/api/v1api"
@@ -31,7 +34,22 @@ var propLog = logging.Logger("boost-prop")
 
 const DealProtocolv120ID = "/fil/storage/mk/1.2.0"
 const DealProtocolv121ID = "/fil/storage/mk/1.2.1"
+const DealBatchProtocolv100ID = "/fil/storage/mk/batch/1.0.0"
 const DealStatusV12ProtocolID = "/fil/storage/status/1.2.0"
+const DealStatusSubscribeV10ProtocolID = "/fil/storage/status/subscribe/1.0.0"
+const TransportsV10ProtocolID = "/fil/storage/transports/1.0.0"
+
+// The maximum number of deals that a client can subscribe to in one request
+const maxDealStatusSubscriptions = 1024
+
+// The maximum number of deal status subscription streams that a peer can
+// have open at once
+const maxDealStatusStreamsPerPeer = 8
+
+// How often the provider checks for changes to the status of subscribed
+// deals that are not signalled by a checkpoint event (eg transfer progress,
+// or a checkpoint event that was dropped because the subscriber fell behind)
+var dealStatusRefreshInterval = 30 * time.Second
 
 // The time limit to read a message from the client when the client opens a stream
 const providerReadDeadline = 10 * time.Second
@@ -44,6 +62,11 @@ const providerWriteDeadline = 10 * time.Second
 // send a response.
 const clientReadDeadline = 60 * time.Second
 
+// The time limit to wait for the provider to send a response to a batch of
+// deal proposals. The provider processes each deal in the batch before it
+// sends the response, so this is longer than the deadline for a single deal.
+const clientBatchReadDeadline = 10 * time.Minute
+
 // The time limit to write a message to the provider
 const clientWriteDeadline = 10 * time.Second
 
@@ -100,6 +123,48 @@ func (c *DealClient) SendDealProposal(ctx context.Context, id peer.ID, params ty
 	return &resp, nil
 }
 
+// SendDealBatchProposal sends a batch of deal proposals over a single libp2p
+// stream to the peer. The response has a DealResponse for each deal, in the
+// same order as the proposals.
+func (c *DealClient) SendDealBatchProposal(ctx context.Context, id peer.ID, deals []types.DealParams) (*types.BatchDealResponse, error) {
+	log.Debugw("send deal batch proposal", "count", len(deals), "provider-peer", id)
+
+	// Create a libp2p stream to the provider
+	s, err := c.retryStream.OpenStream(ctx, id, []protocol.ID{DealBatchProtocolv100ID})
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.Close() // nolint
+
+	// Set a deadline on writing to the stream so it doesn't hang
+	_ = s.SetWriteDeadline(time.Now().Add(clientWriteDeadline))
+	defer s.SetWriteDeadline(time.Time{}) // nolint
+
+	// Write the batch of deal proposals to the stream
+	if err = cborutil.WriteCborRPC(s, &types.BatchDealParams{Deals: deals}); err != nil {
+		return nil, fmt.Errorf("sending deal batch proposal: %w", err)
+	}
+
+	// Set a deadline on reading from the stream so it doesn't hang
+	_ = s.SetReadDeadline(time.Now().Add(clientBatchReadDeadline))
+	defer s.SetReadDeadline(time.Time{}) // nolint
+
+	// Read the response from the stream
+	var resp types.BatchDealResponse
+	if err := resp.UnmarshalCBOR(s); err != nil {
+		return nil, fmt.Errorf("reading batch proposal response: %w", err)
+	}
+
+	if len(resp.Responses) != len(deals) {
+		return nil, fmt.Errorf("batch proposal response has %d responses for %d deals", len(resp.Responses), len(deals))
+	}
+
+	log.Debugw("received deal batch proposal response", "count", len(deals))
+
+	return &resp, nil
+}
+
 func (c *DealClient) SendDealStatusRequest(ctx context.Context, id peer.ID, dealUUID uuid.UUID) (*types.DealStatusResponse, error) {
 	log.Debugw("send deal status req", "deal-uuid", dealUUID, "id", id)
 
@@ -146,6 +211,110 @@ func (c *DealClient) SendDealStatusRequest(ctx context.Context, id peer.ID, deal
 	return &resp, nil
 }
 
+// SubscribeDealStatus subscribes to updates to the status of the deals.
+// The provider sends the current status of each deal, followed by a new
+// status each time a deal changes, until every deal has reached a terminal
+// state. The returned channel is closed when the provider closes the stream
+// or the context is cancelled.
+func (c *DealClient) SubscribeDealStatus(ctx context.Context, id peer.ID, dealUUIDs []uuid.UUID) (<-chan types.DealStatusResponse, error) {
+	log.Debugw("send deal status subscribe req", "count", len(dealUUIDs), "id", id)
+
+	req := types.DealStatusSubscribeRequest{Deals: make([]types.DealStatusRequest, 0, len(dealUUIDs))}
+	for _, dealUUID := range dealUUIDs {
+		uuidBytes, err := dealUUID.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("getting uuid bytes: %w", err)
+		}
+
+		sig, err := c.walletApi.WalletSign(ctx, c.addr, uuidBytes)
+		if err != nil {
+			return nil, fmt.Errorf("signing uuid bytes: %w", err)
+		}
+		req.Deals = append(req.Deals, types.DealStatusRequest{DealUUID: dealUUID, Signature: *sig})
+	}
+
+	// Create a libp2p stream to the provider
+	s, err := c.retryStream.OpenStream(ctx, id, []protocol.ID{DealStatusSubscribeV10ProtocolID})
+	if err != nil {
+		return nil, err
+	}
+
+	// Write the deal status subscribe request to the stream
+	_ = s.SetWriteDeadline(time.Now().Add(clientWriteDeadline))
+	err = cborutil.WriteCborRPC(s, &req)
+	_ = s.SetWriteDeadline(time.Time{})
+	if err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("sending deal status subscribe req: %w", err)
+	}
+
+	// Close the stream when the context is cancelled, so that reading
+	// from the stream is interrupted
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = s.Reset()
+		case <-done:
+		}
+	}()
+
+	updates := make(chan types.DealStatusResponse)
+	go func() {
+		defer close(updates)
+		defer close(done)
+		defer s.Close() // nolint
+
+		for {
+			var resp types.DealStatusResponse
+			if err := resp.UnmarshalCBOR(s); err != nil {
+				if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+					log.Warnw("reading deal status update", "id", id, "err", err)
+				}
+				return
+			}
+
+			log.Debugw("received deal status update", "id", resp.DealUUID)
+
+			select {
+			case updates <- resp:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return updates, nil
+}
+
+// SendTransportsQuery asks the provider which transfer types it supports for
+// downloading deal data (eg "http", "libp2p", "s3")
+func (c *DealClient) SendTransportsQuery(ctx context.Context, id peer.ID) (*types.TransportsResponse, error) {
+	log.Debugw("send transports query", "id", id)
+
+	// Create a libp2p stream to the provider
+	s, err := c.retryStream.OpenStream(ctx, id, []protocol.ID{TransportsV10ProtocolID})
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.Close() // nolint
+
+	// Set a deadline on reading from the stream so it doesn't hang
+	_ = s.SetReadDeadline(time.Now().Add(clientReadDeadline))
+	defer s.SetReadDeadline(time.Time{}) // nolint
+
+	// Read the response from the stream
+	var resp types.TransportsResponse
+	if err := resp.UnmarshalCBOR(s); err != nil {
+		return nil, fmt.Errorf("reading transports response: %w", err)
+	}
+
+	log.Debugw("received transports response", "id", id, "transfer types", resp.TransferTypes)
+
+	return &resp, nil
+}
+
 func NewDealClient(h host.Host, addr address.Address, walletApi api.Wallet, options ...DealClientOption) *DealClient {
 	c := &DealClient{
 		addr:        addr,
@@ -166,15 +335,20 @@ type DealProvider struct {
 	fullNode v1api.FullNode
 	plDB     *db.ProposalLogsDB
 	spApi    sealingpipeline.API
+
+	// The number of open deal status subscription streams for each peer
+	statusStreamsLk sync.Mutex
+	statusStreams   map[peer.ID]int
 }
 
 func NewDealProvider(h host.Host, prov *storagemarket.Provider, fullNodeApi v1api.FullNode, plDB *db.ProposalLogsDB, spApi sealingpipeline.API) *DealProvider {
 	p := &DealProvider{
-		host:     h,
-		prov:     prov,
-		fullNode: fullNodeApi,
-		plDB:     plDB,
-		spApi:    spApi,
+		host:          h,
+		prov:          prov,
+		fullNode:      fullNodeApi,
+		plDB:          plDB,
+		spApi:         spApi,
+		statusStreams: make(map[peer.ID]int),
 	}
 	return p
 }
@@ -194,14 +368,21 @@ func (p *DealProvider) Start(ctx context.Context) {
 	// - RemoveUnsealedCopy=false:  keep unsealed copy of deal data
 	p.host.SetStreamHandler(DealProtocolv121ID, p.handleNewDealStream)
 	p.host.SetStreamHandler(DealProtocolv120ID, p.handleNewDealStream)
+	p.host.SetStreamHandler(DealBatchProtocolv100ID, p.handleNewDealBatchStream)
 
 	p.host.SetStreamHandler(DealStatusV12ProtocolID, p.handleNewDealStatusStream)
+	p.host.SetStreamHandler(DealStatusSubscribeV10ProtocolID, p.handleNewDealStatusSubscribeStream)
+
+	p.host.SetStreamHandler(TransportsV10ProtocolID, p.handleNewTransportsStream)
 }
 
 func (p *DealProvider) Stop() {
 	p.host.RemoveStreamHandler(DealProtocolv121ID)
 	p.host.RemoveStreamHandler(DealProtocolv120ID)
+	p.host.RemoveStreamHandler(DealBatchProtocolv100ID)
 	p.host.RemoveStreamHandler(DealStatusV12ProtocolID)
+	p.host.RemoveStreamHandler(DealStatusSubscribeV10ProtocolID)
+	p.host.RemoveStreamHandler(TransportsV10ProtocolID)
 }
 
 // Called when the client opens a libp2p stream with a new deal proposal
@@ -245,11 +426,88 @@ func (p *DealProvider) handleNewDealStream(s network.Stream) {
 	}
 
 	// Log the response
+	p.logProposalResponse(s.Conn().RemotePeer(), proposal, res, time.Since(startExec))
+
+	// Set a deadline on writing to the stream so it doesn't hang
+	_ = s.SetWriteDeadline(time.Now().Add(providerWriteDeadline))
+	defer s.SetWriteDeadline(time.Time{}) // nolint
+
+	// Write the response to the client
+	err = cborutil.WriteCborRPC(s, &types.DealResponse{Accepted: res.Accepted, Message: res.Reason})
+	if err != nil {
+		reqLog.Warnw("writing deal response", "err", err)
+	}
+}
+
+// Called when the client opens a libp2p stream with a batch of deal proposals
+func (p *DealProvider) handleNewDealBatchStream(s network.Stream) {
+	start := time.Now()
+	reqLogUuid := uuid.New()
+	reqLog := log.With("reqlog-uuid", reqLogUuid.String(), "client-peer", s.Conn().RemotePeer())
+	reqLog.Debugw("new deal batch proposal request")
+
+	defer func() {
+		err := s.Close()
+		if err != nil {
+			reqLog.Infow("closing stream", "err", err)
+		}
+		reqLog.Debugw("handled deal batch proposal request", "duration", time.Since(start).String())
+	}()
+
+	// Set a deadline on reading from the stream so it doesn't hang
+	_ = s.SetReadDeadline(time.Now().Add(providerReadDeadline))
+
+	// Read the batch of deal proposals from the stream
+	var batch types.BatchDealParams
+	err := batch.UnmarshalCBOR(s)
+	_ = s.SetReadDeadline(time.Time{}) // Clear read deadline so conn doesn't get closed
+	if err != nil {
+		reqLog.Warnw("reading storage deal batch proposal from stream", "err", err)
+		return
+	}
+
+	reqLog = reqLog.With("count", len(batch.Deals))
+	reqLog.Infow("received deal batch proposal")
+
+	// Start executing the deals.
+	// Note: This method just waits for the deals to be accepted, it doesn't
+	// wait for deal execution to complete.
+	startExec := time.Now()
+	ress, err := p.prov.ExecuteDealBatch(context.Background(), batch.Deals, s.Conn().RemotePeer())
+	reqLog.Debugw("processed deal batch proposal accept")
+	if err != nil {
+		reqLog.Warnw("deal batch proposal failed", "err", err)
+		ress = make([]*api.ProviderDealRejectionInfo, len(batch.Deals))
+		for i := range ress {
+			ress[i] = &api.ProviderDealRejectionInfo{Reason: "server error: processing deal batch"}
+		}
+	}
+
+	resp := types.BatchDealResponse{Responses: make([]types.DealResponse, 0, len(ress))}
+	for i, res := range ress {
+		p.logProposalResponse(s.Conn().RemotePeer(), batch.Deals[i], res, time.Since(startExec))
+		resp.Responses = append(resp.Responses, types.DealResponse{Accepted: res.Accepted, Message: res.Reason})
+	}
+
+	// Set a deadline on writing to the stream so it doesn't hang
+	_ = s.SetWriteDeadline(time.Now().Add(providerWriteDeadline))
+	defer s.SetWriteDeadline(time.Time{}) // nolint
+
+	// Write the responses to the client
+	err = cborutil.WriteCborRPC(s, &resp)
+	if err != nil {
+		reqLog.Warnw("writing deal batch response", "err", err)
+	}
+}
+
+// logProposalResponse logs the response to a deal proposal, and saves it to
+// the proposal logs database
+func (p *DealProvider) logProposalResponse(clientPeer peer.ID, proposal types.DealParams, res *api.ProviderDealRejectionInfo, duration time.Duration) {
 	propLog.Infow("send deal proposal response",
 		"id", proposal.DealUUID,
 		"accepted", res.Accepted,
 		"msg", res.Reason,
-		"peer id", s.Conn().RemotePeer(),
+		"peer id", clientPeer,
 		"client address", proposal.ClientDealProposal.Proposal.Client,
 		"provider address", proposal.ClientDealProposal.Proposal.Provider,
 		"piece cid", proposal.ClientDealProposal.Proposal.PieceCID.String(),
@@ -259,19 +517,9 @@ func (p *DealProvider) handleNewDealStream(s network.Stream) {
 		"start epoch", proposal.ClientDealProposal.Proposal.StartEpoch,
 		"end epoch", proposal.ClientDealProposal.Proposal.EndEpoch,
 		"price per epoch", proposal.ClientDealProposal.Proposal.StoragePricePerEpoch,
-		"duration", time.Since(startExec).String(),
+		"duration", duration.String(),
 	)
 	_ = p.plDB.InsertLog(p.ctx, proposal, res.Accepted, res.Reason) //nolint:errcheck
-
-	// Set a deadline on writing to the stream so it doesn't hang
-	_ = s.SetWriteDeadline(time.Now().Add(providerWriteDeadline))
-	defer s.SetWriteDeadline(time.Time{}) // nolint
-
-	// Write the response to the client
-	err = cborutil.WriteCborRPC(s, &types.DealResponse{Accepted: res.Accepted, Message: res.Reason})
-	if err != nil {
-		reqLog.Warnw("writing deal response", "err", err)
-	}
 }
 
 func (p *DealProvider) handleNewDealStatusStream(s network.Stream) {
@@ -312,26 +560,234 @@ func (p *DealProvider) handleNewDealStatusStream(s network.Stream) {
 	}
 }
 
+// Called when the client opens a libp2p stream to query which transfer types
+// the provider supports
+func (p *DealProvider) handleNewTransportsStream(s network.Stream) {
+	defer s.Close() // nolint
+
+	log.Debugw("transports query", "client-peer", s.Conn().RemotePeer())
+
+	resp := types.TransportsResponse{TransferTypes: p.prov.Transports.Types()}
+
+	// Set a deadline on writing to the stream so it doesn't hang
+	_ = s.SetWriteDeadline(time.Now().Add(providerWriteDeadline))
+	defer s.SetWriteDeadline(time.Time{}) // nolint
+
+	if err := cborutil.WriteCborRPC(s, &resp); err != nil {
+		log.Infow("failed to write transports response", "client-peer", s.Conn().RemotePeer(), "err", err)
+	}
+}
+
+// Called when the client opens a libp2p stream to subscribe to updates to
+// the status of one or more deals
+func (p *DealProvider) handleNewDealStatusSubscribeStream(s network.Stream) {
+	start := time.Now()
+	reqLogUuid := uuid.New()
+	reqLog := log.With("reqlog-uuid", reqLogUuid.String(), "client-peer", s.Conn().RemotePeer())
+	reqLog.Debugw("new deal status subscribe request")
+
+	defer func() {
+		err := s.Close()
+		if err != nil {
+			reqLog.Infow("closing stream", "err", err)
+		}
+		reqLog.Debugw("handled deal status subscribe request", "duration", time.Since(start).String())
+	}()
+
+	// Read the deal status subscribe request from the stream
+	_ = s.SetReadDeadline(time.Now().Add(providerReadDeadline))
+	var req types.DealStatusSubscribeRequest
+	err := req.UnmarshalCBOR(s)
+	_ = s.SetReadDeadline(time.Time{}) // Clear read deadline so conn doesn't get closed
+	if err != nil {
+		reqLog.Warnw("reading deal status subscribe request from stream", "err", err)
+		return
+	}
+	reqLog = reqLog.With("count", len(req.Deals))
+	reqLog.Debugw("received deal status subscribe request")
+
+	write := func(resp types.DealStatusResponse) bool {
+		_ = s.SetWriteDeadline(time.Now().Add(providerWriteDeadline))
+		defer s.SetWriteDeadline(time.Time{}) // nolint
+
+		if err := cborutil.WriteCborRPC(s, &resp); err != nil {
+			reqLog.Debugw("failed to write deal status update", "id", resp.DealUUID, "err", err)
+			return false
+		}
+		return true
+	}
+
+	if len(req.Deals) > maxDealStatusSubscriptions {
+		write(types.DealStatusResponse{Error: fmt.Sprintf("cannot subscribe to more than %d deals in one request", maxDealStatusSubscriptions)})
+		return
+	}
+
+	// Each subscription polls the status of its deals, so limit the number
+	// of subscriptions that a peer can have open at once
+	remote := s.Conn().RemotePeer()
+	if !p.addStatusStream(remote) {
+		write(types.DealStatusResponse{Error: fmt.Sprintf("cannot have more than %d deal status subscriptions open at once", maxDealStatusStreamsPerPeer)})
+		return
+	}
+	defer p.removeStatusStream(remote)
+
+	// The client doesn't send anything after the request, so reading from
+	// the stream returns when the client closes the stream
+	ctx, cancel := context.WithCancel(p.ctx)
+	defer cancel()
+	go func() {
+		_, _ = io.Copy(io.Discard, s)
+		cancel()
+	}()
+
+	// Subscribe to checkpoint events before getting the current status of
+	// the deals, so that no updates are missed
+	sub, err := p.prov.SubscribeDealCheckpoints()
+	if err != nil {
+		reqLog.Errorw("subscribing to deal checkpoint events", "err", err)
+		return
+	}
+	defer sub.Close() // nolint
+
+	// Send the current status of each deal, and keep watching the deals
+	// that haven't yet reached a terminal state
+	watching := make(map[uuid.UUID]types.DealStatusResponse, len(req.Deals))
+	for _, dreq := range req.Deals {
+		pds, errResp := p.getAuthorizedDeal(dreq, reqLog)
+		if errResp != nil {
+			if !write(*errResp) {
+				return
+			}
+			continue
+		}
+
+		resp := p.dealStatus(pds, reqLog)
+		if !write(resp) {
+			return
+		}
+		if pds.Checkpoint < dealcheckpoints.Complete {
+			watching[pds.DealUuid] = resp
+		}
+	}
+
+	// update sends the status of the deal if it has changed since it was
+	// last sent
+	update := func(pds *types.ProviderDealState) bool {
+		prev, ok := watching[pds.DealUuid]
+		if !ok {
+			return true
+		}
+
+		resp := p.dealStatus(pds, reqLog)
+		if pds.Checkpoint >= dealcheckpoints.Complete {
+			delete(watching, pds.DealUuid)
+		} else if !dealStatusChanged(prev, resp) {
+			return true
+		} else {
+			watching[pds.DealUuid] = resp
+		}
+		return write(resp)
+	}
+
+	ticker := time.NewTicker(dealStatusRefreshInterval)
+	defer ticker.Stop()
+
+	for len(watching) > 0 {
+		select {
+		case evt, ok := <-sub.Out():
+			if !ok {
+				return
+			}
+			deal := evt.(storagemarket.DealCheckpointEvent).Deal
+			if !update(&deal) {
+				return
+			}
+		case <-ticker.C:
+			for dealUuid := range watching {
+				pds, err := p.prov.Deal(ctx, dealUuid)
+				if err != nil {
+					reqLog.Warnw("getting deal status", "id", dealUuid, "err", err)
+					continue
+				}
+				if !update(pds) {
+					return
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// addStatusStream records a new deal status subscription stream from the
+// peer. It returns false if the peer already has the maximum number of
+// streams open.
+func (p *DealProvider) addStatusStream(remote peer.ID) bool {
+	p.statusStreamsLk.Lock()
+	defer p.statusStreamsLk.Unlock()
+
+	if p.statusStreams[remote] >= maxDealStatusStreamsPerPeer {
+		return false
+	}
+	p.statusStreams[remote]++
+	return true
+}
+
+func (p *DealProvider) removeStatusStream(remote peer.ID) {
+	p.statusStreamsLk.Lock()
+	defer p.statusStreamsLk.Unlock()
+
+	p.statusStreams[remote]--
+	if p.statusStreams[remote] <= 0 {
+		delete(p.statusStreams, remote)
+	}
+}
+
+// dealStatusChanged returns true if there is a change in the deal status that
+// the client should be told about
+func dealStatusChanged(prev, next types.DealStatusResponse) bool {
+	if prev.Error != next.Error || prev.NBytesReceived != next.NBytesReceived {
+		return true
+	}
+	if prev.DealStatus == nil || next.DealStatus == nil {
+		return prev.DealStatus != next.DealStatus
+	}
+	return prev.DealStatus.Status != next.DealStatus.Status ||
+		prev.DealStatus.Error != next.DealStatus.Error ||
+		prev.DealStatus.SealingStatus != next.DealStatus.SealingStatus ||
+		prev.DealStatus.ChainDealID != next.DealStatus.ChainDealID
+}
+
 func (p *DealProvider) getDealStatus(req types.DealStatusRequest, reqLog *zap.SugaredLogger) types.DealStatusResponse {
-	errResp := func(err string) types.DealStatusResponse {
-		return types.DealStatusResponse{DealUUID: req.DealUUID, Error: err}
+	pds, errResp := p.getAuthorizedDeal(req, reqLog)
+	if errResp != nil {
+		return *errResp
+	}
+	return p.dealStatus(pds, reqLog)
+}
+
+// getAuthorizedDeal gets the deal, and checks that the request was signed by
+// the deal's client. If not, it returns a response with the error.
+func (p *DealProvider) getAuthorizedDeal(req types.DealStatusRequest, reqLog *zap.SugaredLogger) (*types.ProviderDealState, *types.DealStatusResponse) {
+	errResp := func(err string) *types.DealStatusResponse {
+		return &types.DealStatusResponse{DealUUID: req.DealUUID, Error: err}
 	}
 
 	pds, err := p.prov.Deal(p.ctx, req.DealUUID)
 	if err != nil && errors.Is(err, storagemarket.ErrDealNotFound) {
-		return errResp(fmt.Sprintf("no storage deal found with deal UUID %s", req.DealUUID))
+		return nil, errResp(fmt.Sprintf("no storage deal found with deal UUID %s", req.DealUUID))
 	}
 
 	if err != nil {
 		reqLog.Errorw("failed to fetch deal status", "err", err)
-		return errResp("failed to fetch deal status")
+		return nil, errResp("failed to fetch deal status")
 	}
 
 	// verify request signature
 	uuidBytes, err := req.DealUUID.MarshalBinary()
 	if err != nil {
 		reqLog.Errorw("failed to serialize request deal UUID", "err", err)
-		return errResp("failed to serialize request deal UUID")
+		return nil, errResp("failed to serialize request deal UUID")
 	}
 
 	clientAddr := pds.ClientDealProposal.Proposal.Client
@@ -339,13 +795,21 @@ func (p *DealProvider) getDealStatus(req types.DealStatusRequest, reqLog *zap.Su
 	if err != nil {
 		reqLog.Errorw("failed to get account key for client addr", "client", clientAddr.String(), "err", err)
 		msg := fmt.Sprintf("failed to get account key for client addr %s", clientAddr.String())
-		return errResp(msg)
+		return nil, errResp(msg)
 	}
 
 	err = sigs.Verify(&req.Signature, addr, uuidBytes)
 	if err != nil {
 		reqLog.Warnw("signature verification failed", "err", err)
-		return errResp("signature verification failed")
+		return nil, errResp("signature verification failed")
+	}
+
+	return pds, nil
+}
+
+func (p *DealProvider) dealStatus(pds *types.ProviderDealState, reqLog *zap.SugaredLogger) types.DealStatusResponse {
+	errResp := func(err string) types.DealStatusResponse {
+		return types.DealStatusResponse{DealUUID: pds.DealUuid, Error: err}
 	}
 
 	signedPropCid, err := pds.SignedProposalCid()
@@ -354,7 +818,7 @@ func (p *DealProvider) getDealStatus(req types.DealStatusRequest, reqLog *zap.Su
 		return errResp("getting signed proposal cid")
 	}
 
-	bts := p.prov.NBytesReceived(req.DealUUID)
+	bts := p.prov.NBytesReceived(pds.DealUuid)
 
 	si, err := p.spApi.SectorsStatus(p.ctx, pds.SectorID, false)
 	if err != nil {
@@ -371,7 +835,7 @@ func (p *DealProvider) getDealStatus(req types.DealStatusRequest, reqLog *zap.Su
 	}
 
 	return types.DealStatusResponse{
-		DealUUID: req.DealUUID,
+		DealUUID: pds.DealUuid,
 		DealStatus: &types.DealStatus{
 			Error:             pds.Err,
 			Status:            pds.Checkpoint.String(),
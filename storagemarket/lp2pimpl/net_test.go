@@ -66,3 +66,45 @@ func TestDealParamsMissingFields(t *testing.T) {
 	require.False(t, dpv121.SkipIPNIAnnounce)
 	require.False(t, dpv121.RemoveUnsealedCopy)
 }
+
+func TestDealStatusSubscribeRequestRoundTrip(t *testing.T) {
+	req := types.DealStatusSubscribeRequest{Deals: []types.DealStatusRequest{{
+		DealUUID:  uuid.New(),
+		Signature: crypto.Signature{Type: crypto.SigTypeSecp256k1, Data: []byte("sig1")},
+	}, {
+		DealUUID:  uuid.New(),
+		Signature: crypto.Signature{Type: crypto.SigTypeBLS, Data: []byte("sig2")},
+	}}}
+
+	var buff bytes.Buffer
+	require.NoError(t, req.MarshalCBOR(&buff))
+
+	var decoded types.DealStatusSubscribeRequest
+	require.NoError(t, decoded.UnmarshalCBOR(&buff))
+	require.Equal(t, req, decoded)
+}
+
+func TestTransportsResponseRoundTrip(t *testing.T) {
+	resp := types.TransportsResponse{TransferTypes: []string{"http", "libp2p", "s3"}}
+
+	var buff bytes.Buffer
+	require.NoError(t, resp.MarshalCBOR(&buff))
+
+	var decoded types.TransportsResponse
+	require.NoError(t, decoded.UnmarshalCBOR(&buff))
+	require.Equal(t, resp, decoded)
+}
+
+func TestDealStatusChanged(t *testing.T) {
+	status := func(checkpoint string, received uint64) types.DealStatusResponse {
+		return types.DealStatusResponse{
+			DealStatus:     &types.DealStatus{Status: checkpoint, SealingStatus: "PreCommit1"},
+			NBytesReceived: received,
+		}
+	}
+
+	require.False(t, dealStatusChanged(status("Accepted", 10), status("Accepted", 10)))
+	require.True(t, dealStatusChanged(status("Accepted", 10), status("Accepted", 20)))
+	require.True(t, dealStatusChanged(status("Accepted", 10), status("Transferred", 10)))
+	require.True(t, dealStatusChanged(status("Accepted", 10), types.DealStatusResponse{Error: "failed"}))
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/filecoin-project/boostd-data/shared/tracing"
 	"github.com/filecoin-project/dagstore"
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
 	lapi "github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/api/v1api"
 	ctypes "github.com/filecoin-project/lotus/chain/types"
@@ -73,11 +74,17 @@ type Config struct {
 	// The number of commp processes that can run in parallel
 	MaxConcurrentLocalCommp uint64
 	TransferLimiter         TransferLimiterConfig
+	// Limits on the bandwidth of inbound transfers
+	TransferRateLimit TransferRateLimitConfig
 	// Cleanup deal logs from DB older than this many number of days
 	DealLogDurationDays int
 	// Cache timeout for Sealing Pipeline status
 	SealingPipelineCacheTimeout time.Duration
 	StorageFilter               string
+	// The path to the deal filter rules file
+	StorageFilterRules string
+	// Checks whether in-flight deals can still be sealed by their start epoch
+	DeadlineMonitor DeadlineMonitorConfig
 }
 
 var log = logging.Logger("boost-provider")
@@ -92,10 +99,12 @@ type Provider struct {
 	closeSync sync.Once
 	runWG     sync.WaitGroup
 
-	newDealPS *newDealPS
+	newDealPS    *newDealPS
+	checkpointPS *checkpointPS
 
 	// channels used to pass messages to run loop
 	acceptDealChan       chan acceptDealReq
+	acceptBatchChan      chan acceptBatchReq
 	finishedDealChan     chan finishedDealReq
 	publishedDealChan    chan publishDealReq
 	updateRetryStateChan chan updateRetryStateReq
@@ -111,11 +120,14 @@ type Provider struct {
 	// Database API
 	db        *sql.DB
 	dealsDB   *db.DealsDB
+	quotasDB  *db.QuotasDB
 	logsSqlDB *sql.DB
 	logsDB    *db.LogsDB
 
-	Transport      transport.Transport
+	// Transports has a plugin for each supported transfer type
+	Transports     *transport.Registry
 	xferLimiter    *transferLimiter
+	bwLimiter      *bandwidthLimiter
 	fundManager    *fundmanager.FundManager
 	storageManager *storagemanager.StorageManager
 	dealPublisher  types.DealPublisher
@@ -146,17 +158,29 @@ func NewProvider(cfg Config, sqldb *sql.DB, dealsDB *db.DealsDB, fundMgr *fundma
 	fullnodeApi v1api.FullNode, dp types.DealPublisher, addr address.Address, pa types.PieceAdder, commpCalc smtypes.CommpCalculator,
 	sps sealingpipeline.API, cm types.ChainDealManager, df dtypes.StorageDealFilter, logsSqlDB *sql.DB, logsDB *db.LogsDB,
 	dagst DagstoreShardRegistry, ps piecestore.PieceStore, ip types.IndexProvider, askGetter types.AskGetter,
-	sigVerifier types.SignatureVerifier, dl *logs.DealLogger, tspt transport.Transport) (*Provider, error) {
+	sigVerifier types.SignatureVerifier, dl *logs.DealLogger, tspt *transport.Registry) (*Provider, error) {
 
-	xferLimiter, err := newTransferLimiter(cfg.TransferLimiter)
+	xferLimiter, err := newTransferLimiter(cfg.TransferLimiter, tspt.Host)
 	if err != nil {
 		return nil, err
 	}
+	if fullnodeApi != nil {
+		// The chain head is used to prioritize deals that are close to
+		// their start epoch
+		xferLimiter.chainHead = func(ctx context.Context) (abi.ChainEpoch, error) {
+			head, err := fullnodeApi.ChainHead(ctx)
+			if err != nil {
+				return 0, err
+			}
+			return head.Height(), nil
+		}
+	}
 
 	newDealPS, err := newDealPubsub()
 	if err != nil {
 		return nil, err
 	}
+	checkpointPS := newCheckpointPubsub()
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Make sure that max concurrent local commp is at least 1
@@ -169,26 +193,30 @@ func NewProvider(cfg Config, sqldb *sql.DB, dealsDB *db.DealsDB, fundMgr *fundma
 	}
 
 	return &Provider{
-		ctx:       ctx,
-		cancel:    cancel,
-		config:    cfg,
-		Address:   addr,
-		newDealPS: newDealPS,
-		db:        sqldb,
-		dealsDB:   dealsDB,
-		logsSqlDB: logsSqlDB,
-		sps:       sps,
-		spsCache:  SealingPipelineCache{},
-		df:        df,
+		ctx:          ctx,
+		cancel:       cancel,
+		config:       cfg,
+		Address:      addr,
+		newDealPS:    newDealPS,
+		checkpointPS: checkpointPS,
+		db:           sqldb,
+		dealsDB:      dealsDB,
+		quotasDB:     db.NewQuotasDB(sqldb),
+		logsSqlDB:    logsSqlDB,
+		sps:          sps,
+		spsCache:     SealingPipelineCache{},
+		df:           df,
 
 		acceptDealChan:       make(chan acceptDealReq),
+		acceptBatchChan:      make(chan acceptBatchReq),
 		finishedDealChan:     make(chan finishedDealReq),
 		publishedDealChan:    make(chan publishDealReq),
 		updateRetryStateChan: make(chan updateRetryStateReq),
 		storageSpaceChan:     make(chan storageSpaceDealReq),
 
-		Transport:      tspt,
+		Transports:     tspt,
 		xferLimiter:    xferLimiter,
+		bwLimiter:      newBandwidthLimiter(cfg.TransferRateLimit),
 		fundManager:    fundMgr,
 		storageManager: storageMgr,
 
@@ -292,7 +320,87 @@ func (p *Provider) ExecuteDeal(ctx context.Context, dp *types.DealParams, client
 
 	p.dealLogger.Infow(dp.DealUUID, "executing deal proposal received from network", "peer", clientPeer)
 
-	ds := types.ProviderDealState{
+	ds := dealStateFromParams(dp, clientPeer)
+
+	// Validate the deal proposal
+	if err := p.validateDealProposal(ds); err != nil {
+		return p.validationRejection(dp.DealUUID, err), nil
+	}
+
+	return p.executeDeal(ctx, ds)
+}
+
+// ExecuteDealBatch is called when the Storage Provider receives a batch of
+// deal proposals from the network. The deals in the batch are validated
+// against the same chain head, and share the funds and storage snapshots
+// during acceptance. It returns a response for each deal, in the same order
+// as the proposals.
+func (p *Provider) ExecuteDealBatch(ctx context.Context, dps []types.DealParams, clientPeer peer.ID) ([]*api.ProviderDealRejectionInfo, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Provider.ExecuteLibp2pDealBatch")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("batchSize", len(dps)))
+
+	ris := make([]*api.ProviderDealRejectionInfo, len(dps))
+	head, err := p.fullnodeApi.ChainHead(p.ctx)
+	if err != nil {
+		log.Warnw("getting chain head for deal batch", "peer", clientPeer, "batch size", len(dps), "err", err)
+		for i := range ris {
+			ris[i] = &api.ProviderDealRejectionInfo{Reason: "failed validation: server error: getting chain head"}
+		}
+		return ris, nil
+	}
+
+	// Validate each deal proposal in the batch
+	var valid []*types.ProviderDealState
+	var validIdx []int
+	for i := range dps {
+		dp := &dps[i]
+		p.dealLogger.Infow(dp.DealUUID, "executing deal proposal received from network in batch", "peer", clientPeer, "batch size", len(dps))
+
+		ds := dealStateFromParams(dp, clientPeer)
+		if err := p.validateDealProposalAt(ds, head); err != nil {
+			ris[i] = p.validationRejection(dp.DealUUID, err)
+			continue
+		}
+		valid = append(valid, &ds)
+		validIdx = append(validIdx, i)
+	}
+
+	if len(valid) == 0 {
+		return ris, nil
+	}
+
+	// Send the valid deals to the main provider loop for acceptance
+	resps, err := p.checkForDealBatchAcceptance(ctx, valid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send deal batch for acceptance: %w", err)
+	}
+
+	for j, resp := range resps {
+		ds := valid[j]
+		if resp.err != nil {
+			p.dealLogger.LogError(ds.DealUuid, "failed to accept deal", resp.err)
+			ris[validIdx[j]] = &api.ProviderDealRejectionInfo{Reason: "server error: accepting deal"}
+			continue
+		}
+
+		ris[validIdx[j]] = resp.ri
+		switch {
+		case !resp.ri.Accepted:
+			p.dealLogger.Infow(ds.DealUuid, "deal rejected by provider", "reason", resp.ri.Reason)
+		case ds.IsOffline:
+			p.dealLogger.Infow(ds.DealUuid, "offline deal accepted, waiting for data import")
+		default:
+			p.dealLogger.Infow(ds.DealUuid, "deal accepted and scheduled for execution")
+		}
+	}
+
+	return ris, nil
+}
+
+func dealStateFromParams(dp *types.DealParams, clientPeer peer.ID) types.ProviderDealState {
+	return types.ProviderDealState{
 		DealUuid:           dp.DealUUID,
 		ClientDealProposal: dp.ClientDealProposal,
 		ClientPeerID:       clientPeer,
@@ -304,24 +412,21 @@ func (p *Provider) ExecuteDeal(ctx context.Context, dp *types.DealParams, client
 		FastRetrieval:      !dp.RemoveUnsealedCopy,
 		AnnounceToIPNI:     !dp.SkipIPNIAnnounce,
 	}
+}
 
-	// Validate the deal proposal
-	if err := p.validateDealProposal(ds); err != nil {
-		// Send the client a reason for the rejection that doesn't reveal the
-		// internal error message
-		reason := err.reason
-		if reason == "" {
-			reason = err.Error()
-		}
-
-		// Log the internal error message
-		p.dealLogger.Infow(dp.DealUUID, "deal proposal failed validation", "err", err.Error(), "reason", reason)
-		return &api.ProviderDealRejectionInfo{
-			Reason: fmt.Sprintf("failed validation: %s", reason),
-		}, nil
+// validationRejection logs the validation error, and returns a rejection
+// with a reason that doesn't reveal the internal error message
+func (p *Provider) validationRejection(dealUuid uuid.UUID, err *validationError) *api.ProviderDealRejectionInfo {
+	reason := err.reason
+	if reason == "" {
+		reason = err.Error()
 	}
 
-	return p.executeDeal(ctx, ds)
+	// Log the internal error message
+	p.dealLogger.Infow(dealUuid, "deal proposal failed validation", "err", err.Error(), "reason", reason)
+	return &api.ProviderDealRejectionInfo{
+		Reason: fmt.Sprintf("failed validation: %s", reason),
+	}
 }
 
 // executeDeal sends the deal to the main provider run loop for execution
@@ -386,6 +491,25 @@ func (p *Provider) checkForDealAcceptance(ctx context.Context, ds *types.Provide
 	return resp, nil
 }
 
+func (p *Provider) checkForDealBatchAcceptance(ctx context.Context, deals []*types.ProviderDealState) ([]acceptDealResp, error) {
+	_, span := tracing.Tracer.Start(ctx, "Provider.checkForDealBatchAcceptance")
+	defer span.End()
+
+	respChan := make(chan []acceptDealResp, 1)
+	select {
+	case p.acceptBatchChan <- acceptBatchReq{rsp: respChan, deals: deals}:
+	case <-p.ctx.Done():
+		return nil, p.ctx.Err()
+	}
+
+	select {
+	case resps := <-respChan:
+		return resps, nil
+	case <-p.ctx.Done():
+		return nil, p.ctx.Err()
+	}
+}
+
 func (p *Provider) Start() error {
 	log.Infow("storage provider: starting")
 
@@ -498,6 +622,15 @@ func (p *Provider) Start() error {
 	// Start the transfer limiter
 	go p.xferLimiter.run(p.ctx)
 
+	// Start sampling the rates of transfers for the bandwidth limits
+	go p.bwLimiter.run(p.ctx)
+
+	// Start monitoring in-flight deals for deals that won't be sealed by
+	// their start epoch
+	if p.config.DeadlineMonitor.CheckPeriod > 0 && p.config.DeadlineMonitor.ExpectedSealDuration != nil {
+		go p.runDeadlineMonitor()
+	}
+
 	// Start hourly deal log cleanup
 	if p.config.DealLogDurationDays > 0 {
 		go p.dealLogger.LogCleanup(p.ctx, p.config.DealLogDurationDays)
@@ -511,6 +644,7 @@ func (p *Provider) cleanupDealOnRestart(deal *types.ProviderDealState) {
 	// remove the temp file created for inbound deal data if it is not an offline deal
 	if !deal.IsOffline {
 		_ = os.Remove(deal.InboundFilePath)
+		_ = os.Remove(inlineCommpStatePath(deal.InboundFilePath))
 	}
 
 	// untag storage space
@@ -552,6 +686,13 @@ func (p *Provider) SubscribeNewDeals() (event.Subscription, error) {
 	return p.newDealPS.subscribe()
 }
 
+// SubscribeDealCheckpoints subscribes to checkpoint events for all deals:
+// an event is fired each time a deal moves to a new checkpoint, or fails.
+// Events are dropped for a subscriber that doesn't keep up with them.
+func (p *Provider) SubscribeDealCheckpoints() (event.Subscription, error) {
+	return p.checkpointPS.subscribe()
+}
+
 // SubscribeDealUpdates subscribes to updates to a deal
 func (p *Provider) SubscribeDealUpdates(dealUuid uuid.UUID) (event.Subscription, error) {
 	dh := p.getDealHandler(dealUuid)
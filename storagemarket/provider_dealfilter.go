@@ -23,16 +23,32 @@ func (p *Provider) getDealFilterParams(deal *types.ProviderDealState) (*dealfilt
 		SkipIPNIAnnounce:   !deal.AnnounceToIPNI,
 	}
 
+	// Get the transfer host before the transfer params are cleared, so that
+	// deal filters can check where the data is downloaded from
+	var transferHost string
+	if !deal.IsOffline {
+		host, err := p.Transports.Host(deal.Transfer)
+		if err != nil {
+			return nil, &acceptError{
+				error:         fmt.Errorf("storage deal filter: failed to get transfer host: %w", err),
+				reason:        fmt.Sprintf("server error: storage deal filter: get transfer host: %s", err),
+				isSevereError: false,
+			}
+		}
+		transferHost = host
+	}
+
 	// Clear transfer params in case it contains sensitive information
 	// (eg Authorization header)
 	params.Transfer.Params = []byte{}
 
-	// If no external deal filter is set then return empty value for SealingPipelineState,
-	// FundsState and StorageState to shorten the execution. This also avoids the expensive
-	// p.sealingPipelineStatus() call
-	if p.config.StorageFilter == "" {
+	// If no external deal filter or deal filter rules are set then return empty value for
+	// SealingPipelineState, FundsState and StorageState to shorten the execution. This also
+	// avoids the expensive p.sealingPipelineStatus() call
+	if p.config.StorageFilter == "" && p.config.StorageFilterRules == "" {
 		return &dealfilter.DealFilterParams{
 			DealParams:           params,
+			TransferHost:         transferHost,
 			SealingPipelineState: sealingpipeline.Status{},
 			FundsState:           funds.Status{},
 			StorageState:         storagespace.Status{},
@@ -71,6 +87,7 @@ func (p *Provider) getDealFilterParams(deal *types.ProviderDealState) (*dealfilt
 
 	return &dealfilter.DealFilterParams{
 		DealParams:           params,
+		TransferHost:         transferHost,
 		SealingPipelineState: sealingStatus,
 		FundsState:           *fundsStatus,
 		StorageState:         *storageStatus,
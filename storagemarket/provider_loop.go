@@ -28,6 +28,11 @@ type acceptDealResp struct {
 	err error
 }
 
+type acceptBatchReq struct {
+	rsp   chan []acceptDealResp
+	deals []*types.ProviderDealState
+}
+
 type finishedDealReq struct {
 	deal *types.ProviderDealState
 	done chan struct{}
@@ -59,6 +64,36 @@ func (p *Provider) logFunds(id uuid.UUID, trsp *fundmanager.TagFundsResp) {
 		"total available for collateral", trsp.AvailableCollateral)
 }
 
+// acceptSnapshot holds the funds and storage snapshots used to check that
+// there are enough resources for a deal. The deals in a batch share the same
+// snapshot, so that the balances are only fetched once for the batch.
+type acceptSnapshot struct {
+	funds   *fundmanager.Snapshot
+	storage *storagemanager.Snapshot
+}
+
+func (p *Provider) newAcceptSnapshot() *acceptSnapshot {
+	return &acceptSnapshot{storage: p.storageManager.Snapshot()}
+}
+
+// reset discards the snapshots, so that they are fetched again the next time
+// they are needed (eg after tags are removed because a deal was rejected)
+func (s *acceptSnapshot) reset(p *Provider) {
+	s.funds = nil
+	s.storage = p.storageManager.Snapshot()
+}
+
+func (p *Provider) tagFunds(snap *acceptSnapshot, deal *types.ProviderDealState) (*fundmanager.TagFundsResp, error) {
+	if snap.funds == nil {
+		funds, err := p.fundManager.Snapshot(p.ctx)
+		if err != nil {
+			return nil, err
+		}
+		snap.funds = funds
+	}
+	return p.fundManager.TagFundsFromSnapshot(p.ctx, snap.funds, deal.DealUuid, deal.ClientDealProposal.Proposal)
+}
+
 // acceptError is used to distinguish between a regular error and a severe error
 type acceptError struct {
 	error
@@ -98,8 +133,18 @@ func (p *Provider) runDealFilters(deal *types.ProviderDealState) *acceptError {
 	return nil
 }
 
-func (p *Provider) processDealProposal(deal *types.ProviderDealState) *acceptError {
-	host, err := deal.Transfer.Host()
+func (p *Provider) processDealProposal(deal *types.ProviderDealState, snap *acceptSnapshot) *acceptError {
+	// Check that the transfer type is supported and that the transfer
+	// params are valid
+	if err := p.Transports.ValidateParams(deal.Transfer, deal.ClientDealProposal.Proposal.Client); err != nil {
+		return &acceptError{
+			error:         fmt.Errorf("invalid transfer params: %w", err),
+			reason:        fmt.Sprintf("invalid transfer params: %s", err),
+			isSevereError: false,
+		}
+	}
+
+	host, err := p.Transports.Host(deal.Transfer)
 	if err != nil {
 		return &acceptError{
 			error:         fmt.Errorf("failed to get deal transfer host: %w", err),
@@ -123,6 +168,11 @@ func (p *Provider) processDealProposal(deal *types.ProviderDealState) *acceptErr
 		return aerr
 	}
 
+	// Check that the deal would not exceed the client's quotas
+	if aerr := p.checkQuotas(deal); aerr != nil {
+		return aerr
+	}
+
 	cleanup := func() {
 		collat, pub, errf := p.fundManager.UntagFunds(p.ctx, deal.DealUuid)
 		if errf != nil && !errors.Is(errf, db.ErrNotFound) {
@@ -142,11 +192,14 @@ func (p *Provider) processDealProposal(deal *types.ProviderDealState) *acceptErr
 		if deal.InboundFilePath != "" {
 			_ = os.Remove(deal.InboundFilePath)
 		}
+
+		// The snapshots include the tags that were just removed
+		snap.reset(p)
 	}
 
 	// tag the funds required for escrow and sending the publish deal message
 	// so that they are not used for other deals
-	trsp, err := p.fundManager.TagFunds(p.ctx, deal.DealUuid, deal.ClientDealProposal.Proposal)
+	trsp, err := p.tagFunds(snap, deal)
 	if err != nil {
 		cleanup()
 
@@ -165,7 +218,7 @@ func (p *Provider) processDealProposal(deal *types.ProviderDealState) *acceptErr
 	p.logFunds(deal.DealUuid, trsp)
 
 	// tag the storage required for the deal in the staging area
-	err = p.storageManager.Tag(p.ctx, deal.DealUuid, deal.Transfer.Size, host)
+	err = p.storageManager.TagFromSnapshot(p.ctx, snap.storage, deal.DealUuid, deal.Transfer.Size, host)
 	if err != nil {
 		cleanup()
 
@@ -212,6 +265,7 @@ func (p *Provider) processDealProposal(deal *types.ProviderDealState) *acceptErr
 	}
 
 	p.dealLogger.Infow(deal.DealUuid, "inserted deal into deals DB")
+	p.fireEventDealCheckpoint(deal, false)
 
 	return nil
 }
@@ -234,6 +288,11 @@ func (p *Provider) processOfflineDealProposal(ds *smtypes.ProviderDealState, dh
 		return aerr
 	}
 
+	// Check that the deal would not exceed the client's quotas
+	if aerr := p.checkQuotas(ds); aerr != nil {
+		return aerr
+	}
+
 	// Save deal to DB
 	ds.CreatedAt = time.Now()
 	ds.Checkpoint = dealcheckpoints.Accepted
@@ -250,6 +309,7 @@ func (p *Provider) processOfflineDealProposal(ds *smtypes.ProviderDealState, dh
 	p.fireEventDealNew(ds)
 	// publish an event with the current state of the deal
 	p.fireEventDealUpdate(dh.Publisher, ds)
+	p.fireEventDealCheckpoint(ds, false)
 
 	return nil
 }
@@ -364,81 +424,18 @@ func (p *Provider) run() {
 		//   when the data is imported
 		// - accept a request to import data for an offline deal
 		case dealReq := <-p.acceptDealChan:
-			deal := dealReq.deal
-			p.dealLogger.Infow(deal.DealUuid, "processing deal acceptance request")
-
-			sendErrorResp := func(aerr *acceptError) {
-				// If the error is a severe error (eg can't connect to database)
-				if aerr.isSevereError {
-					// Send a rejection message to the client with a reason for rejection
-					resp := acceptDealResp{ri: &api.ProviderDealRejectionInfo{Accepted: false, Reason: aerr.reason}}
-					// Log an error with more details for the provider
-					p.dealLogger.LogError(deal.DealUuid, "error while processing deal acceptance request", aerr)
-					dealReq.rsp <- resp
-					return
-				}
-
-				// The error is not a severe error, so don't log an error, just
-				// send a message to the client with a rejection reason
-				p.dealLogger.Infow(deal.DealUuid, "deal acceptance request rejected", "reason", aerr.reason, "error", aerr.error)
-				dealReq.rsp <- acceptDealResp{ri: &api.ProviderDealRejectionInfo{Accepted: false, Reason: aerr.reason}, err: nil}
+			dealReq.rsp <- p.acceptDeal(dealReq.deal, dealReq.isImport, p.newAcceptSnapshot())
+
+		// Process a request to accept a batch of deal proposals. The deals
+		// share the funds and storage snapshots, so that the balances are
+		// only fetched once for the whole batch.
+		case batchReq := <-p.acceptBatchChan:
+			snap := p.newAcceptSnapshot()
+			resps := make([]acceptDealResp, 0, len(batchReq.deals))
+			for _, deal := range batchReq.deals {
+				resps = append(resps, p.acceptDeal(deal, false, snap))
 			}
-
-			if deal.IsOffline && !dealReq.isImport {
-				// When the client proposes an offline deal, save the deal
-				// to the database but don't execute the deal. The deal
-				// will be executed when the Storage Provider imports the
-				// deal data.
-				dh, err := p.mkAndInsertDealHandler(deal.DealUuid)
-				if err != nil {
-					sendErrorResp(&acceptError{error: err, isSevereError: true, reason: "server error: creating deal handler"})
-					continue
-				}
-
-				aerr := p.processOfflineDealProposal(dealReq.deal, dh)
-				if aerr != nil {
-					dh.close()
-					p.delDealHandler(deal.DealUuid)
-					sendErrorResp(aerr)
-					continue
-				}
-
-				// The deal proposal was successful. Send an Accept response to the client.
-				dealReq.rsp <- acceptDealResp{ri: &api.ProviderDealRejectionInfo{Accepted: true}}
-				// Don't execute the deal now, wait for data import.
-				continue
-			}
-
-			var aerr *acceptError
-			if deal.IsOffline {
-				// The Storage Provider is importing offline deal data, so tag
-				// funds for the deal and execute it
-				aerr = p.processImportOfflineDealData(dealReq.deal)
-			} else {
-				// Process a regular deal proposal
-				aerr = p.processDealProposal(dealReq.deal)
-			}
-			if aerr != nil {
-				sendErrorResp(aerr)
-				continue
-			}
-
-			// set up deal handler so that clients can subscribe to deal update events
-			dh, err := p.mkAndInsertDealHandler(deal.DealUuid)
-			if err != nil {
-				sendErrorResp(&acceptError{error: err, isSevereError: true, reason: "server error: starting deal thread"})
-				continue
-			}
-
-			// start executing the deal
-			_, err = p.startDealThread(dh, deal)
-			if err != nil {
-				sendErrorResp(&acceptError{error: err, isSevereError: true, reason: "server error: starting deal thread"})
-				continue
-			}
-
-			// send an accept response
-			dealReq.rsp <- acceptDealResp{&api.ProviderDealRejectionInfo{Accepted: true}, nil}
+			batchReq.rsp <- resps
 
 		case storageSpaceDealReq := <-p.storageSpaceChan:
 			deal := storageSpaceDealReq.deal
@@ -525,6 +522,78 @@ func (p *Provider) run() {
 	}
 }
 
+// acceptDeal runs a deal through the acceptance checks, reserves the
+// resources it requires and starts executing it (unless it's an offline deal
+// that is waiting for data to be imported)
+func (p *Provider) acceptDeal(deal *types.ProviderDealState, isImport bool, snap *acceptSnapshot) acceptDealResp {
+	p.dealLogger.Infow(deal.DealUuid, "processing deal acceptance request")
+
+	errorResp := func(aerr *acceptError) acceptDealResp {
+		// If the error is a severe error (eg can't connect to database)
+		if aerr.isSevereError {
+			// Log an error with more details for the provider
+			p.dealLogger.LogError(deal.DealUuid, "error while processing deal acceptance request", aerr)
+			// Send a rejection message to the client with a reason for rejection
+			return acceptDealResp{ri: &api.ProviderDealRejectionInfo{Accepted: false, Reason: aerr.reason}}
+		}
+
+		// The error is not a severe error, so don't log an error, just
+		// send a message to the client with a rejection reason
+		p.dealLogger.Infow(deal.DealUuid, "deal acceptance request rejected", "reason", aerr.reason, "error", aerr.error)
+		return acceptDealResp{ri: &api.ProviderDealRejectionInfo{Accepted: false, Reason: aerr.reason}, err: nil}
+	}
+
+	if deal.IsOffline && !isImport {
+		// When the client proposes an offline deal, save the deal
+		// to the database but don't execute the deal. The deal
+		// will be executed when the Storage Provider imports the
+		// deal data.
+		dh, err := p.mkAndInsertDealHandler(deal.DealUuid)
+		if err != nil {
+			return errorResp(&acceptError{error: err, isSevereError: true, reason: "server error: creating deal handler"})
+		}
+
+		aerr := p.processOfflineDealProposal(deal, dh)
+		if aerr != nil {
+			dh.close()
+			p.delDealHandler(deal.DealUuid)
+			return errorResp(aerr)
+		}
+
+		// The deal proposal was successful. Send an Accept response to the client.
+		// Don't execute the deal now, wait for data import.
+		return acceptDealResp{ri: &api.ProviderDealRejectionInfo{Accepted: true}}
+	}
+
+	var aerr *acceptError
+	if deal.IsOffline {
+		// The Storage Provider is importing offline deal data, so tag
+		// funds for the deal and execute it
+		aerr = p.processImportOfflineDealData(deal)
+	} else {
+		// Process a regular deal proposal
+		aerr = p.processDealProposal(deal, snap)
+	}
+	if aerr != nil {
+		return errorResp(aerr)
+	}
+
+	// set up deal handler so that clients can subscribe to deal update events
+	dh, err := p.mkAndInsertDealHandler(deal.DealUuid)
+	if err != nil {
+		return errorResp(&acceptError{error: err, isSevereError: true, reason: "server error: starting deal thread"})
+	}
+
+	// start executing the deal
+	_, err = p.startDealThread(dh, deal)
+	if err != nil {
+		return errorResp(&acceptError{error: err, isSevereError: true, reason: "server error: starting deal thread"})
+	}
+
+	// send an accept response
+	return acceptDealResp{&api.ProviderDealRejectionInfo{Accepted: true}, nil}
+}
+
 // startDealThread sets up a deal handler and wait group monitoring for a deal, then
 // executes the deal in a new go routine
 func (p *Provider) startDealThread(dh *dealHandler, deal *types.ProviderDealState) (bool, error) {
@@ -568,6 +637,7 @@ func (p *Provider) failPausedDeal(dh *dealHandler, deal *smtypes.ProviderDealSta
 	deal.Err = "user manually terminated the deal"
 	p.dealLogger.LogError(deal.DealUuid, deal.Err, err)
 	p.saveDealToDB(dh.Publisher, deal)
+	p.fireEventDealCheckpoint(deal, true)
 
 	// Call cleanupDeal in a go-routine because it sends a message to the provider
 	// run loop (and failPausedDeal is called from the same run loop so otherwise
@@ -0,0 +1,182 @@
+package storagemarket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/go-address"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// QuotaInfo is a client quota and the amount of the quota that the client
+// is currently using
+type QuotaInfo struct {
+	db.Quota
+	// Usage is nil for the default quota, because it applies to each client
+	// separately
+	Usage *db.QuotaUsage
+}
+
+// Quotas returns all the client quotas, with their usage
+func (p *Provider) Quotas(ctx context.Context) ([]QuotaInfo, error) {
+	quotas, err := p.quotasDB.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing quotas: %w", err)
+	}
+
+	infos := make([]QuotaInfo, 0, len(quotas))
+	for _, q := range quotas {
+		info := QuotaInfo{Quota: q}
+		if q.ID != db.QuotaDefaultID {
+			info.Usage, err = p.quotasDB.Usage(ctx, q.Kind, q.ID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Quota returns the quota that applies to the client with the given address
+// or peer ID (which may be the default quota), with the client's usage.
+// Returns db.ErrNotFound if there is no quota that applies to the client.
+func (p *Provider) Quota(ctx context.Context, kind db.QuotaKind, id string) (*QuotaInfo, error) {
+	id, err := normalizeQuotaID(kind, id)
+	if err != nil {
+		return nil, err
+	}
+	if id == db.QuotaDefaultID {
+		return nil, fmt.Errorf("the %s quota applies to each %s separately, so it has no usage", db.QuotaDefaultID, kind)
+	}
+
+	q, err := p.quotasDB.GetEffective(ctx, kind, id)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := p.quotasDB.Usage(ctx, kind, id)
+	if err != nil {
+		return nil, err
+	}
+	return &QuotaInfo{Quota: *q, Usage: usage}, nil
+}
+
+// SetQuota creates or replaces a client quota
+func (p *Provider) SetQuota(ctx context.Context, q db.Quota) error {
+	id, err := normalizeQuotaID(q.Kind, q.ID)
+	if err != nil {
+		return err
+	}
+	q.ID = id
+	if err := p.quotasDB.Set(ctx, q); err != nil {
+		return fmt.Errorf("setting %s quota for %s: %w", q.Kind, q.ID, err)
+	}
+	log.Infow("set quota", "kind", q.Kind, "id", q.ID, "maxBytesInFlight", q.MaxBytesInFlight,
+		"maxDealsPerHour", q.MaxDealsPerHour, "maxConcurrentTransfers", q.MaxConcurrentTransfers)
+	return nil
+}
+
+// RemoveQuota removes a client quota
+func (p *Provider) RemoveQuota(ctx context.Context, kind db.QuotaKind, id string) error {
+	id, err := normalizeQuotaID(kind, id)
+	if err != nil {
+		return err
+	}
+	if err := p.quotasDB.Delete(ctx, kind, id); err != nil {
+		return fmt.Errorf("removing %s quota for %s: %w", kind, id, err)
+	}
+	log.Infow("removed quota", "kind", kind, "id", id)
+	return nil
+}
+
+// normalizeQuotaID parses the client address or peer ID, so that it is in
+// the same format as the address or peer ID stored with each deal
+func normalizeQuotaID(kind db.QuotaKind, id string) (string, error) {
+	if _, err := db.ParseQuotaKind(string(kind)); err != nil {
+		return "", err
+	}
+	if id == "" {
+		return "", errors.New("quota must have an ID")
+	}
+	if id == db.QuotaDefaultID {
+		return id, nil
+	}
+
+	if kind == db.QuotaKindPeer {
+		pid, err := peer.Decode(id)
+		if err != nil {
+			return "", fmt.Errorf("parsing peer ID '%s': %w", id, err)
+		}
+		return pid.String(), nil
+	}
+	addr, err := address.NewFromString(id)
+	if err != nil {
+		return "", fmt.Errorf("parsing client address '%s': %w", id, err)
+	}
+	return addr.String(), nil
+}
+
+// checkQuotas checks that accepting the deal would not exceed the quotas of
+// the client address or peer that made the deal
+func (p *Provider) checkQuotas(deal *types.ProviderDealState) *acceptError {
+	type subject struct {
+		kind db.QuotaKind
+		id   string
+	}
+	subjects := []subject{{db.QuotaKindClient, deal.ClientDealProposal.Proposal.Client.String()}}
+	// Deals made over JSON-RPC don't have a client peer ID
+	if deal.ClientPeerID != "" {
+		subjects = append(subjects, subject{db.QuotaKindPeer, deal.ClientPeerID.String()})
+	}
+
+	for _, c := range subjects {
+		info, err := p.Quota(p.ctx, c.kind, c.id)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				continue
+			}
+			return &acceptError{
+				error:         fmt.Errorf("failed to check %s quota for %s: %w", c.kind, c.id, err),
+				reason:        "server error: checking client quota",
+				isSevereError: true,
+			}
+		}
+
+		if reason := quotaExceeded(info, deal); reason != "" {
+			reason = fmt.Sprintf("%s %s has exceeded its quota: %s", c.kind, c.id, reason)
+			return &acceptError{
+				error:         errors.New(reason),
+				reason:        reason,
+				isSevereError: false,
+			}
+		}
+	}
+
+	return nil
+}
+
+// quotaExceeded returns the reason the deal would exceed the quota, or the
+// empty string if it would not
+func quotaExceeded(info *QuotaInfo, deal *types.ProviderDealState) string {
+	q, usage := info.Quota, info.Usage
+	if q.MaxDealsPerHour > 0 && usage.DealsLastHour+1 > q.MaxDealsPerHour {
+		return fmt.Sprintf("already made %d deals in the last hour (max %d)", usage.DealsLastHour, q.MaxDealsPerHour)
+	}
+
+	// Offline deal data is imported by the provider, so it doesn't count
+	// towards data transfer quotas
+	if deal.IsOffline {
+		return ""
+	}
+	if q.MaxConcurrentTransfers > 0 && usage.ConcurrentTransfers+1 > q.MaxConcurrentTransfers {
+		return fmt.Sprintf("already has %d transfers in progress (max %d)", usage.ConcurrentTransfers, q.MaxConcurrentTransfers)
+	}
+	if q.MaxBytesInFlight > 0 && usage.BytesInFlight+deal.Transfer.Size > q.MaxBytesInFlight {
+		return fmt.Sprintf("deal data of size %d on top of %d bytes in flight would exceed max %d bytes",
+			deal.Transfer.Size, usage.BytesInFlight, q.MaxBytesInFlight)
+	}
+	return ""
+}
@@ -278,6 +278,48 @@ func TestDealRejectedForDuplicateUuid(t *testing.T) {
 	})
 }
 
+func TestDealRejectedForQuota(t *testing.T) {
+	ctx := context.Background()
+	harness := NewHarness(t)
+	// start the provider test harness
+	harness.Start(t, ctx)
+	defer harness.Stop()
+
+	// Allow the client one concurrent transfer
+	err := harness.Provider.SetQuota(ctx, db.Quota{
+		Kind:                   db.QuotaKindClient,
+		ID:                     harness.ClientAddr.String(),
+		MaxConcurrentTransfers: 1,
+	})
+	require.NoError(t, err)
+
+	td := harness.newDealBuilder(t, 1).withNoOpMinerStub().withBlockingHttpServer().build()
+	require.NoError(t, td.executeAndSubscribe())
+
+	info, err := harness.Provider.Quota(ctx, db.QuotaKindClient, harness.ClientAddr.String())
+	require.NoError(t, err)
+	require.EqualValues(t, 1, info.Usage.ConcurrentTransfers)
+	require.EqualValues(t, 1, info.Usage.DealsLastHour)
+
+	// The second deal should be rejected because the first deal's transfer
+	// is still in progress
+	td2 := harness.newDealBuilder(t, 2).withNoOpMinerStub().withBlockingHttpServer().build()
+	pi, err := td2.ph.Provider.ExecuteDeal(ctx, td2.params, "")
+	require.NoError(t, err)
+	require.False(t, pi.Accepted)
+	require.Contains(t, pi.Reason, "has exceeded its quota")
+
+	// After removing the quota the deal is accepted
+	require.NoError(t, harness.Provider.RemoveQuota(ctx, db.QuotaKindClient, harness.ClientAddr.String()))
+	require.NoError(t, td2.executeAndSubscribe())
+
+	// cancel all transfers so all deals finish and db files can be deleted
+	for _, d := range []*testDeal{td, td2} {
+		require.NoError(t, harness.Provider.CancelDealDataTransfer(d.params.DealUUID))
+		d.assertEventuallyDealCleanedup(t, ctx)
+	}
+}
+
 func TestDealRejectedForInsufficientProviderFunds(t *testing.T) {
 	ctx := context.Background()
 	// setup the provider test harness with configured publish fee per deal
@@ -319,6 +361,38 @@ func TestDealRejectedForInsufficientProviderStorageSpace(t *testing.T) {
 	require.Contains(t, pi.Reason, "no space left")
 }
 
+func TestDealBatch(t *testing.T) {
+	ctx := context.Background()
+	// setup the provider test harness with only enough storage
+	// space for 1.5 deals
+	fileSize := 2000
+	harness := NewHarness(t, withMaxStagingDealsBytes(uint64(fileSize*3)/2))
+	// start the provider test harness
+	harness.Start(t, ctx)
+	defer harness.Stop()
+
+	td1 := harness.newDealBuilder(t, 1, withNormalFileSize(fileSize)).withNoOpMinerStub().withBlockingHttpServer().build()
+	td2 := harness.newDealBuilder(t, 2, withUndefinedPieceCid()).withNoOpMinerStub().withBlockingHttpServer().build()
+	td3 := harness.newDealBuilder(t, 3, withNormalFileSize(fileSize)).withNoOpMinerStub().withBlockingHttpServer().build()
+	batch := []types.DealParams{*td1.params, *td2.params, *td3.params}
+
+	ris, err := harness.Provider.ExecuteDealBatch(ctx, batch, peer.ID(""))
+	require.NoError(t, err)
+	require.Len(t, ris, 3)
+
+	// The first deal is accepted
+	require.True(t, ris[0].Accepted)
+	// The second deal fails validation
+	require.False(t, ris[1].Accepted)
+	require.Contains(t, ris[1].Reason, "failed validation")
+	// The third deal is rejected because the storage tagged for the first
+	// deal in the batch leaves no space for it
+	require.False(t, ris[2].Accepted)
+	require.Contains(t, ris[2].Reason, "no space left")
+
+	harness.AssertStorageManagerState(t, ctx, td1.params.Transfer.Size)
+}
+
 func TestDealRejectedForInsufficientProviderStorageSpacePerHost(t *testing.T) {
 	ctx := context.Background()
 	// Set up the harness such that
@@ -1162,6 +1236,53 @@ func TestDealFilter(t *testing.T) {
 	require.EqualValues(t, 10000000000, dealFilterParams.StorageState.TotalAvailable)
 }
 
+func TestDealFilterRules(t *testing.T) {
+	ctx := context.Background()
+
+	writeRules := func(rules string) string {
+		path := filepath.Join(t.TempDir(), "rules.toml")
+		require.NoError(t, os.WriteFile(path, []byte(rules), 0644))
+		return path
+	}
+
+	// The provider has about 10GB of free staging space, so a rule that
+	// rejects deals when there is less than 1GiB free should not match
+	path := writeRules(`
+[[Rule]]
+Name = "low-storage"
+Action = "reject"
+Reason = "not enough free storage"
+StorageFreeBelow = "1GiB"
+`)
+	harness := NewHarness(t, withDealFilterRules(t, path))
+	harness.Start(t, ctx)
+	defer harness.Stop()
+
+	td := harness.newDealBuilder(t, 1).withAllMinerCallsNonBlocking().withNormalHttpServer().build()
+	require.NoError(t, td.executeAndSubscribe())
+	td.waitForAndAssert(t, ctx, dealcheckpoints.IndexedAndAnnounced)
+
+	// The sealing pipeline has one sector in PreCommit1, so a rule that
+	// rejects deals when there is at least one sector in PreCommit1 should
+	// match
+	path = writeRules(`
+[[Rule]]
+Name = "busy-pipeline"
+Action = "reject"
+Reason = "sealing pipeline is busy"
+SectorsInStateAtLeast = { PreCommit1 = 1 }
+`)
+	harness2 := NewHarness(t, withDealFilterRules(t, path))
+	harness2.Start(t, ctx)
+	defer harness2.Stop()
+
+	td2 := harness2.newDealBuilder(t, 2).withNoOpMinerStub().withNormalHttpServer().build()
+	pi, err := harness2.Provider.ExecuteDeal(ctx, td2.params, "")
+	require.NoError(t, err)
+	require.False(t, pi.Accepted)
+	require.Contains(t, pi.Reason, "sealing pipeline is busy")
+}
+
 func TestFinalSealingState(t *testing.T) {
 	ctx := context.Background()
 	harness := NewHarness(t)
@@ -1368,7 +1489,7 @@ type ProviderHarness struct {
 	BlockingServer      *testutil.BlockingHttpTestServer
 	DisconnectingServer *httptest.Server
 
-	Transport transport.Transport
+	Transports *transport.Registry
 
 	SqlDB    *sql.DB
 	DAGStore *shared_testutil.MockDagStoreWrapper
@@ -1399,6 +1520,9 @@ type providerConfig struct {
 	localCommp  bool
 	dealFilter  dealfilter.StorageDealFilter
 	chainHeadFn ChainHeadFn
+
+	storageFilter      string
+	storageFilterRules string
 }
 
 type harnessOpt func(pc *providerConfig)
@@ -1442,6 +1566,17 @@ func withMaxStagingDealsPercentPerHost(max uint64) harnessOpt {
 	}
 }
 
+// mockTransportPlugin executes transfers with a mock transport, and uses the
+// http transport to interpret transfer params
+type mockTransportPlugin struct {
+	transport.Plugin
+	mock transport.Transport
+}
+
+func (p *mockTransportPlugin) Execute(ctx context.Context, transportInfo []byte, dealInfo *tspttypes.TransportDealInfo) (transport.Handler, error) {
+	return p.mock.Execute(ctx, transportInfo, dealInfo)
+}
+
 func withTransportBuilder(bldr func(controller *gomock.Controller) transport.Transport) harnessOpt {
 	return func(pc *providerConfig) {
 		pc.transport = bldr(pc.mockCtrl)
@@ -1476,6 +1611,18 @@ func withDealFilter(filter dealfilter.StorageDealFilter) harnessOpt {
 	}
 }
 
+// withDealFilterRules configures the provider to filter deals with the rules
+// file at the given path, instead of an external deal filter
+func withDealFilterRules(t *testing.T, path string) harnessOpt {
+	return func(pc *providerConfig) {
+		rf, err := dealfilter.NewRulesFilter(path)
+		require.NoError(t, err)
+		pc.dealFilter = rf.Filter
+		pc.storageFilter = ""
+		pc.storageFilterRules = path
+	}
+}
+
 func withChainHeadFunction(fn ChainHeadFn) harnessOpt {
 	return func(pc *providerConfig) {
 		pc.chainHeadFn = fn
@@ -1499,6 +1646,8 @@ func NewHarness(t *testing.T, opts ...harnessOpt) *ProviderHarness {
 		verifiedPrice: abi.NewTokenAmount(0),
 		minPieceSize:  abi.PaddedPieceSize(0),
 		maxPieceSize:  abi.PaddedPieceSize(10737418240), //10Gib default
+
+		storageFilter: "1",
 	}
 
 	sealingpipelineStatus := map[lapi.SectorState]int{
@@ -1552,10 +1701,14 @@ func NewHarness(t *testing.T, opts ...harnessOpt) *ProviderHarness {
 	dl := logs.NewDealLogger(logsDB)
 
 	// Create http transport
-	tspt := pc.transport
-	if tspt == nil {
-		tspt = httptransport.New(h, dl, pc.httpOpts...)
+	httpTspt := httptransport.New(h, dl, pc.httpOpts...)
+	var plugin transport.Plugin = httpTspt
+	if pc.transport != nil {
+		plugin = &mockTransportPlugin{Plugin: httpTspt, mock: pc.transport}
 	}
+	tspt := transport.NewRegistry()
+	tspt.Register("http", plugin)
+	tspt.Register("libp2p", plugin)
 
 	// publish wallet
 	pw, err := address.NewIDAddress(1)
@@ -1574,7 +1727,7 @@ func NewHarness(t *testing.T, opts ...harnessOpt) *ProviderHarness {
 		NormalServer:                 normalServer,
 		BlockingServer:               blockingServer,
 		DisconnectingServer:          disconnServer,
-		Transport:                    tspt,
+		Transports:                   tspt,
 		MockSealingPipelineAPI:       sps,
 		DealsDB:                      dealsDB,
 		FundsDB:                      db.NewFundsDB(sqldb),
@@ -1633,7 +1786,8 @@ func NewHarness(t *testing.T, opts ...harnessOpt) *ProviderHarness {
 			StallTimeout:     time.Hour,
 		},
 		SealingPipelineCacheTimeout: time.Second,
-		StorageFilter:               "1",
+		StorageFilter:               pc.storageFilter,
+		StorageFilterRules:          pc.storageFilterRules,
 	}
 	prov, err := NewProvider(prvCfg, sqldb, dealsDB, fm, sm, fn, minerStub, minerAddr, minerStub, minerStub, sps, minerStub, df, sqldb,
 		logsDB, dagStore, ps, minerStub, askStore, &mockSignatureVerifier{true, nil}, dl, tspt)
@@ -1700,7 +1854,7 @@ func (h *ProviderHarness) shutdownAndCreateNewProvider(t *testing.T, opts ...har
 	prov, err := NewProvider(h.Provider.config, h.Provider.db, h.Provider.dealsDB, h.Provider.fundManager,
 		h.Provider.storageManager, h.Provider.fullnodeApi, h.MinerStub, h.MinerAddr, h.MinerStub, h.MinerStub, h.MockSealingPipelineAPI, h.MinerStub,
 		df, h.Provider.logsSqlDB, h.Provider.logsDB, h.Provider.dagst, h.Provider.ps, h.MinerStub, h.Provider.askGetter,
-		h.Provider.sigVerifier, h.Provider.dealLogger, h.Provider.Transport)
+		h.Provider.sigVerifier, h.Provider.dealLogger, h.Provider.Transports)
 
 	require.NoError(t, err)
 	h.Provider = prov
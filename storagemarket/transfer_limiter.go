@@ -8,6 +8,10 @@ import (
 	"time"
 
 	smtypes "github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
 	"github.com/google/uuid"
 )
 
@@ -17,6 +21,9 @@ type transfer struct {
 	host      string
 	updatedAt time.Time
 	bytes     uint64
+	// The priority set by the user, overriding the priority calculated from
+	// the deal
+	priority *int
 }
 
 func (t *transfer) isStarted() bool {
@@ -35,6 +42,24 @@ type TransferLimiterConfig struct {
 	StallCheckPeriod time.Duration
 	// The time that can elapse before a download is considered stalled
 	StallTimeout time.Duration
+	// The order in which queued transfers are started
+	Priority TransferPriorityConfig
+}
+
+// TransferPriorityConfig determines the order in which queued transfers are
+// started
+type TransferPriorityConfig struct {
+	// Deals with a start epoch within this many epochs of the current epoch
+	// are urgent: they are started before any other deals, in order of start
+	// epoch. Zero disables urgency.
+	UrgentStartEpochWindow abi.ChainEpoch
+	// The priority added to verified deals
+	VerifiedPriority int
+	// The priority of deals from each client
+	ClientPriority map[address.Address]int
+	// Start deals with a higher price per byte first, if they have the
+	// same priority
+	PreferHigherPrice bool
 }
 
 // transferLimiter maintains a queue of transfers with a soft upper limit on
@@ -44,8 +69,13 @@ type TransferLimiterConfig struct {
 // a couple of mitigations:
 //
 // The queue is ordered such that we
-//   - start transferring data for the oldest deal first
-//   - prefer to start transfers with peers that don't have any ongoing transfer
+//   - start transferring data for urgent deals (deals that are close to their
+//     start epoch) first, in order of start epoch
+//   - then start transferring data for the deals with the highest priority
+//     (see TransferPriorityConfig), which may be overridden by the user
+//   - then start transferring data for the oldest deal first
+//   - prefer to start transfers with peers that don't have any ongoing
+//     transfer, over transfers with the same priority
 //   - once the soft limit is reached, don't allow any new transfers with peers
 //     that have existing stalled transfers
 //
@@ -69,11 +99,19 @@ type TransferLimiterConfig struct {
 type transferLimiter struct {
 	cfg TransferLimiterConfig
 
+	// Gets the current chain epoch, used to determine which deals are urgent.
+	// If nil, no deals are urgent.
+	chainHead func(ctx context.Context) (abi.ChainEpoch, error)
+	// Gets the host that a transfer downloads data from
+	transferHost func(smtypes.Transfer) (string, error)
+
 	lk    sync.RWMutex
 	xfers map[uuid.UUID]*transfer
+	// The chain epoch at the last check
+	epoch abi.ChainEpoch
 }
 
-func newTransferLimiter(cfg TransferLimiterConfig) (*transferLimiter, error) {
+func newTransferLimiter(cfg TransferLimiterConfig, transferHost func(smtypes.Transfer) (string, error)) (*transferLimiter, error) {
 	if cfg.MaxConcurrent == 0 {
 		return nil, fmt.Errorf("maximum active concurrent transfers must be > 0")
 	}
@@ -85,8 +123,9 @@ func newTransferLimiter(cfg TransferLimiterConfig) (*transferLimiter, error) {
 	}
 
 	return &transferLimiter{
-		cfg:   cfg,
-		xfers: make(map[uuid.UUID]*transfer),
+		cfg:          cfg,
+		transferHost: transferHost,
+		xfers:        make(map[uuid.UUID]*transfer),
 	}, nil
 }
 
@@ -100,6 +139,7 @@ func (tl *transferLimiter) run(ctx context.Context) {
 	for {
 		select {
 		case t := <-ticker.C:
+			tl.updateEpoch(ctx)
 			tl.check(t)
 
 		case <-ctx.Done():
@@ -108,6 +148,95 @@ func (tl *transferLimiter) run(ctx context.Context) {
 	}
 }
 
+// updateEpoch gets the current chain epoch
+func (tl *transferLimiter) updateEpoch(ctx context.Context) {
+	if tl.chainHead == nil || tl.cfg.Priority.UrgentStartEpochWindow == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tl.cfg.StallCheckPeriod)
+	defer cancel()
+	epoch, err := tl.chainHead(ctx)
+	if err != nil {
+		log.Warnw("getting chain head to prioritize transfers", "err", err)
+		return
+	}
+
+	tl.lk.Lock()
+	tl.epoch = epoch
+	tl.lk.Unlock()
+}
+
+// transferRank is used to order the transfer queue
+type transferRank struct {
+	xfer     *transfer
+	urgent   bool
+	priority int
+}
+
+func (tl *transferLimiter) rank(xfer *transfer, epoch abi.ChainEpoch) transferRank {
+	r := transferRank{xfer: xfer}
+	prop := xfer.deal.ClientDealProposal.Proposal
+
+	window := tl.cfg.Priority.UrgentStartEpochWindow
+	r.urgent = window > 0 && epoch > 0 && prop.StartEpoch-epoch <= window
+
+	if xfer.priority != nil {
+		r.priority = *xfer.priority
+		return r
+	}
+	r.priority = tl.cfg.Priority.ClientPriority[prop.Client]
+	if prop.VerifiedDeal {
+		r.priority += tl.cfg.Priority.VerifiedPriority
+	}
+	return r
+}
+
+// sameTier returns true if neither transfer should be preferred over the
+// other on the basis of urgency or priority
+func (r transferRank) sameTier(o transferRank) bool {
+	return !r.urgent && !o.urgent && r.priority == o.priority
+}
+
+// before returns true if the transfer should be started before the other
+func (tl *transferLimiter) before(r transferRank, o transferRank) bool {
+	rp, op := r.xfer.deal.ClientDealProposal.Proposal, o.xfer.deal.ClientDealProposal.Proposal
+	if r.urgent != o.urgent {
+		return r.urgent
+	}
+	if r.urgent && rp.StartEpoch != op.StartEpoch {
+		return rp.StartEpoch < op.StartEpoch
+	}
+	if r.priority != o.priority {
+		return r.priority > o.priority
+	}
+	if tl.cfg.Priority.PreferHigherPrice && hasPrice(rp) && hasPrice(op) {
+		// Compare price per byte: r.price / r.size > o.price / o.size
+		rPrice := big.Mul(rp.StoragePricePerEpoch, big.NewIntUnsigned(uint64(op.PieceSize)))
+		oPrice := big.Mul(op.StoragePricePerEpoch, big.NewIntUnsigned(uint64(rp.PieceSize)))
+		if cmp := big.Cmp(rPrice, oPrice); cmp != 0 {
+			return cmp > 0
+		}
+	}
+	return r.xfer.deal.CreatedAt.Before(o.xfer.deal.CreatedAt)
+}
+
+func hasPrice(prop market.DealProposal) bool {
+	return !prop.StoragePricePerEpoch.Nil() && prop.PieceSize > 0
+}
+
+// sortQueue sorts the transfers in the order they should be started
+func (tl *transferLimiter) sortQueue(xfers []*transfer, epoch abi.ChainEpoch) []transferRank {
+	ranks := make([]transferRank, 0, len(xfers))
+	for _, xfer := range xfers {
+		ranks = append(ranks, tl.rank(xfer, epoch))
+	}
+	sort.SliceStable(ranks, func(i, j int) bool {
+		return tl.before(ranks[i], ranks[j])
+	})
+	return ranks
+}
+
 func (tl *transferLimiter) check(now time.Time) {
 	// Take a copy of the transfers map.
 	// We do this to avoid lock contention with the SetBytes message which
@@ -119,6 +248,7 @@ func (tl *transferLimiter) check(now time.Time) {
 		cp := *xfer
 		xfers[id] = &cp
 	}
+	epoch := tl.epoch
 	tl.lk.Unlock()
 
 	// Count how many transfers are active (not stalled)
@@ -151,18 +281,17 @@ func (tl *transferLimiter) check(now time.Time) {
 		return
 	}
 
-	// Sort unstarted transfers by creation date (oldest first)
-	sort.Slice(unstartedXfers, func(i, j int) bool {
-		return unstartedXfers[i].deal.CreatedAt.Before(unstartedXfers[j].deal.CreatedAt)
-	})
+	// Sort unstarted transfers by urgency, priority and creation date
+	queue := tl.sortQueue(unstartedXfers, epoch)
 
 	// Gets the next transfer that should be started
 	nextTransfer := func() *transfer {
-		var next *transfer
+		var next *transferRank
 
-		// Iterate over unstarted transfers from oldest to newest
+		// Iterate over unstarted transfers in queue order
 		startedCount := tl.startedCount(xfers)
-		for _, xfer := range unstartedXfers {
+		for i := range queue {
+			xfer := queue[i].xfer
 			// Skip transfers that have already been started.
 			// Note: A previous call to nextTransfer may have started the
 			// transfer.
@@ -178,9 +307,15 @@ func (tl *transferLimiter) check(now time.Time) {
 				continue
 			}
 
-			// Default to choosing the oldest unstarted transfer
+			// Default to choosing the first unstarted transfer in the queue
 			if next == nil {
-				next = xfer
+				next = &queue[i]
+			}
+
+			// Only prefer a transfer to a new peer over transfers with
+			// the same urgency and priority
+			if !next.sameTier(queue[i]) {
+				break
 			}
 
 			// If there are no transfers with the peer that sent the storage deal,
@@ -193,7 +328,10 @@ func (tl *transferLimiter) check(now time.Time) {
 			}
 		}
 
-		return next
+		if next == nil {
+			return nil
+		}
+		return next.xfer
 	}
 
 	// Start new transfers until we reach the limit
@@ -233,7 +371,7 @@ func (tl *transferLimiter) transfersCount() int {
 
 // Wait for the next open spot in the transfer queue
 func (tl *transferLimiter) waitInQueue(ctx context.Context, deal *smtypes.ProviderDealState) error {
-	host, err := deal.Transfer.Host()
+	host, err := tl.transferHost(deal.Transfer)
 	if err != nil {
 		return fmt.Errorf("getting host from Transfer params for deal %s: %w", deal.DealUuid, err)
 	}
@@ -302,6 +440,12 @@ type HostTransferStats struct {
 	Started   int
 	Stalled   int
 	DealUuids []uuid.UUID
+	// The limit on bytes per second received from the host (zero means no
+	// limit)
+	RateLimit uint64
+	// The average bytes per second received from the host over the last few
+	// seconds
+	BytesPerSecond uint64
 }
 
 func (tl *transferLimiter) stats() []*HostTransferStats {
@@ -340,3 +484,84 @@ func (tl *transferLimiter) stats() []*HostTransferStats {
 	})
 	return statsArr
 }
+
+// QueuedTransfer is a transfer that is waiting in the queue to be started
+type QueuedTransfer struct {
+	DealUuid   uuid.UUID
+	Host       string
+	Size       uint64
+	StartEpoch abi.ChainEpoch
+	CreatedAt  time.Time
+	// The priority of the transfer
+	Priority int
+	// Whether the priority was set by the user
+	PriorityOverridden bool
+	// Whether the deal is close to its start epoch
+	Urgent bool
+}
+
+// queue returns the transfers that have not yet started, in the order in
+// which they will be started
+func (tl *transferLimiter) queue() []*QueuedTransfer {
+	tl.lk.RLock()
+	unstartedXfers := make([]*transfer, 0, len(tl.xfers))
+	for _, xfer := range tl.xfers {
+		if !xfer.isStarted() {
+			cp := *xfer
+			unstartedXfers = append(unstartedXfers, &cp)
+		}
+	}
+	epoch := tl.epoch
+	tl.lk.RUnlock()
+
+	queue := tl.sortQueue(unstartedXfers, epoch)
+	queued := make([]*QueuedTransfer, 0, len(queue))
+	for _, r := range queue {
+		prop := r.xfer.deal.ClientDealProposal.Proposal
+		queued = append(queued, &QueuedTransfer{
+			DealUuid:           r.xfer.deal.DealUuid,
+			Host:               r.xfer.host,
+			Size:               r.xfer.deal.Transfer.Size,
+			StartEpoch:         prop.StartEpoch,
+			CreatedAt:          r.xfer.deal.CreatedAt,
+			Priority:           r.priority,
+			PriorityOverridden: r.xfer.priority != nil,
+			Urgent:             r.urgent,
+		})
+	}
+	return queued
+}
+
+// setPriority overrides the priority of a transfer that is in the queue.
+// If priority is nil, the priority is calculated from the deal.
+func (tl *transferLimiter) setPriority(dealUuid uuid.UUID, priority *int) error {
+	tl.lk.Lock()
+	defer tl.lk.Unlock()
+
+	xfer, ok := tl.xfers[dealUuid]
+	if !ok {
+		return fmt.Errorf("no transfer for deal %s in the transfer queue", dealUuid)
+	}
+	if xfer.isStarted() {
+		return fmt.Errorf("transfer for deal %s has already started", dealUuid)
+	}
+	xfer.priority = priority
+	return nil
+}
+
+// raisePriority raises the priority of a transfer that is in the queue to at
+// least the given priority. It returns true if the priority was changed.
+func (tl *transferLimiter) raisePriority(dealUuid uuid.UUID, priority int) bool {
+	tl.lk.Lock()
+	defer tl.lk.Unlock()
+
+	xfer, ok := tl.xfers[dealUuid]
+	if !ok || xfer.isStarted() {
+		return false
+	}
+	if tl.rank(xfer, tl.epoch).priority >= priority {
+		return false
+	}
+	xfer.priority = &priority
+	return true
+}
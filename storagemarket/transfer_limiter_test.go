@@ -5,12 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"golang.org/x/exp/rand"
+	"net/url"
 	"testing"
 	"time"
 
 	smtypes "github.com/filecoin-project/boost/storagemarket/types"
 	"github.com/filecoin-project/boost/testutil"
 	"github.com/filecoin-project/boost/transport/types"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 )
@@ -37,6 +40,19 @@ func generateDealWithHost(host string) *smtypes.ProviderDealState {
 	}
 }
 
+// httpTransferHost gets the host from the URL in http transfer params
+func httpTransferHost(xfer smtypes.Transfer) (string, error) {
+	var req types.HttpRequest
+	if err := json.Unmarshal(xfer.Params, &req); err != nil {
+		return "", err
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return "", err
+	}
+	return u.Host, nil
+}
+
 func TestTransferLimiterBasic(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -46,7 +62,7 @@ func TestTransferLimiterBasic(t *testing.T) {
 		MaxConcurrent:    1,
 		StallCheckPeriod: time.Millisecond,
 		StallTimeout:     30 * time.Second,
-	})
+	}, httpTransferHost)
 	require.NoError(t, err)
 
 	go tl.run(ctx)
@@ -70,7 +86,7 @@ func TestTransferLimiterQueueSize(t *testing.T) {
 		MaxConcurrent:    1,
 		StallCheckPeriod: time.Millisecond,
 		StallTimeout:     30 * time.Second,
-	})
+	}, httpTransferHost)
 	require.NoError(t, err)
 
 	// Generate two deals and add them to the transfer queue
@@ -124,7 +140,7 @@ func TestTransferLimiterStalledTransfer(t *testing.T) {
 		StallCheckPeriod: time.Millisecond,
 		StallTimeout:     time.Second,
 	}
-	tl, err := newTransferLimiter(cfg)
+	tl, err := newTransferLimiter(cfg, httpTransferHost)
 	require.NoError(t, err)
 
 	// Generate two deals and add them to the transfer queue
@@ -190,7 +206,7 @@ func TestTransferLimiterPriorityOldestFirst(t *testing.T) {
 		StallCheckPeriod: time.Millisecond,
 		StallTimeout:     30 * time.Second,
 	}
-	tl, err := newTransferLimiter(cfg)
+	tl, err := newTransferLimiter(cfg, httpTransferHost)
 	require.NoError(t, err)
 
 	// Generate deals and add them to the transfer queue in the reverse order
@@ -236,6 +252,99 @@ func TestTransferLimiterPriorityOldestFirst(t *testing.T) {
 	}
 }
 
+// Verifies that urgent deals are started first, then deals are started in
+// order of priority, price and age, and that the priority can be overridden
+func TestTransferLimiterPriorityUrgentThenPriority(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	preferredClient, err := address.NewIDAddress(1234)
+	require.NoError(t, err)
+
+	tl, err := newTransferLimiter(TransferLimiterConfig{
+		MaxConcurrent:    1,
+		StallCheckPeriod: time.Millisecond,
+		StallTimeout:     30 * time.Second,
+		Priority: TransferPriorityConfig{
+			UrgentStartEpochWindow: 100,
+			VerifiedPriority:       10,
+			ClientPriority:         map[address.Address]int{preferredClient: 5},
+			PreferHigherPrice:      true,
+		},
+	}, httpTransferHost)
+	require.NoError(t, err)
+	tl.epoch = 1000
+
+	genDeal := func(startEpoch abi.ChainEpoch, price int64, age time.Duration) *smtypes.ProviderDealState {
+		dl := generateDeal()
+		dl.CreatedAt = time.Now().Add(-age)
+		dl.ClientDealProposal.Proposal.StartEpoch = startEpoch
+		dl.ClientDealProposal.Proposal.PieceSize = 1024
+		dl.ClientDealProposal.Proposal.StoragePricePerEpoch = abi.NewTokenAmount(price)
+		return dl
+	}
+
+	cheapOld := genDeal(5000, 1, 5*time.Minute)
+	pricey := genDeal(5000, 2, time.Minute)
+	verified := genDeal(5000, 1, time.Minute)
+	verified.ClientDealProposal.Proposal.VerifiedDeal = true
+	preferred := genDeal(5000, 1, time.Minute)
+	preferred.ClientDealProposal.Proposal.Client = preferredClient
+	urgentLater := genDeal(1080, 1, time.Minute)
+	urgentSooner := genDeal(1050, 1, time.Minute)
+
+	deals := []*smtypes.ProviderDealState{cheapOld, pricey, verified, preferred, urgentLater, urgentSooner}
+	started := make(chan *smtypes.ProviderDealState, len(deals))
+	for _, dl := range deals {
+		dl := dl
+		go func() {
+			err := tl.waitInQueue(ctx, dl)
+			require.NoError(t, err)
+			started <- dl
+		}()
+	}
+
+	// Wait for all the deals to be added to the transfer queue
+	require.Eventually(t, func() bool { return tl.transfersCount() == len(deals) }, time.Second, time.Millisecond)
+
+	queueOrder := func() []uuid.UUID {
+		var ids []uuid.UUID
+		for _, q := range tl.queue() {
+			ids = append(ids, q.DealUuid)
+		}
+		return ids
+	}
+
+	// Expect urgent deals first (soonest start epoch first), then verified,
+	// then the preferred client, then the higher price, then the oldest
+	expected := []uuid.UUID{urgentSooner.DealUuid, urgentLater.DealUuid, verified.DealUuid,
+		preferred.DealUuid, pricey.DealUuid, cheapOld.DealUuid}
+	require.Equal(t, expected, queueOrder())
+
+	// Override the priority of the cheap deal so that it comes before all
+	// non-urgent deals
+	priority := 20
+	require.NoError(t, tl.setPriority(cheapOld.DealUuid, &priority))
+	expected = []uuid.UUID{urgentSooner.DealUuid, urgentLater.DealUuid, cheapOld.DealUuid,
+		verified.DealUuid, preferred.DealUuid, pricey.DealUuid}
+	require.Equal(t, expected, queueOrder())
+	require.True(t, tl.queue()[2].PriorityOverridden)
+
+	// Expect the deals to be started in queue order
+	for _, id := range expected {
+		go tl.check(time.Now())
+
+		dl := <-started
+		require.Equal(t, id, dl.DealUuid)
+
+		// Make space in the queue for the next deal to be started
+		tl.complete(dl.DealUuid)
+	}
+
+	// Setting the priority of a transfer that is not in the queue fails
+	require.Error(t, tl.setPriority(cheapOld.DealUuid, nil))
+}
+
 // Verifies that the prioritization favours transfers to peers that don't
 // already have an ongoing transfer.
 // eg there is
@@ -252,7 +361,7 @@ func TestTransferLimiterPriorityNoExistingTransferToPeerFirst(t *testing.T) {
 		StallCheckPeriod: time.Millisecond,
 		StallTimeout:     30 * time.Second,
 	}
-	tl, err := newTransferLimiter(cfg)
+	tl, err := newTransferLimiter(cfg, httpTransferHost)
 	require.NoError(t, err)
 
 	// Generate three deals, where the first two have the same peer
@@ -315,7 +424,7 @@ func TestTransferLimiterStalledTransferHardLimited(t *testing.T) {
 		StallCheckPeriod: time.Millisecond,
 		StallTimeout:     time.Second,
 	}
-	tl, err := newTransferLimiter(cfg)
+	tl, err := newTransferLimiter(cfg, httpTransferHost)
 	require.NoError(t, err)
 
 	// Generate a deal and add to the transfer queue
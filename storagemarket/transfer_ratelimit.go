@@ -0,0 +1,494 @@
+package storagemarket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/filecoin-project/boost/metrics"
+	"github.com/filecoin-project/go-address"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"golang.org/x/time/rate"
+)
+
+// The kinds of rate limit on inbound transfer bandwidth
+const (
+	// RateLimitGlobal limits the bandwidth of all inbound transfers together
+	RateLimitGlobal = "global"
+	// RateLimitHost limits the bandwidth of all transfers from a host
+	RateLimitHost = "host"
+	// RateLimitClient limits the bandwidth of all transfers for deals from
+	// a client address
+	RateLimitClient = "client"
+)
+
+// RateLimitDefaultID is the ID of the host or client rate limit that applies
+// to each host or client that doesn't have its own limit
+const RateLimitDefaultID = "*"
+
+// The number of seconds over which the achieved rate is averaged
+const rateSampleWindow = 5
+
+// TransferRateLimitConfig has the initial limits on inbound transfer
+// bandwidth, in bytes per second. Zero means no limit.
+type TransferRateLimitConfig struct {
+	// The limit on all inbound transfers together
+	Global uint64
+	// The limit on transfers from each host
+	PerHost uint64
+	// The limit on transfers for deals from each client address
+	PerClient uint64
+}
+
+// TransferRateLimit is a limit on inbound transfer bandwidth, and the rate
+// achieved by the transfers that it applies to
+type TransferRateLimit struct {
+	Kind string
+	// The host or client address (empty for the global limit)
+	ID string
+	// The maximum bytes per second (zero means no limit)
+	BytesPerSecond uint64
+	// Whether the limit is set for this ID, as opposed to being inherited
+	// from the default limit for the kind
+	Explicit bool
+	// The number of active transfers that the limit applies to
+	Transfers int
+	// The average bytes per second received over the last few seconds by
+	// the transfers that the limit applies to
+	Rate uint64
+}
+
+type rateLimitKey struct {
+	kind string
+	id   string
+}
+
+// bandwidthBucket is a token bucket shared by the transfers from a host or
+// client (or by all transfers, for the global bucket)
+type bandwidthBucket struct {
+	// Protects the limit and burst of lim against being changed while
+	// tokens are reserved
+	lk  sync.RWMutex
+	lim *rate.Limiter
+	// Whether there is no limit. The limiter is never set to rate.Inf,
+	// because it doesn't keep track of its tokens while the limit is
+	// infinite.
+	unlimited bool
+	// The number of active transfers using the bucket
+	refs int
+	// The number of bytes received since the last sample
+	received atomic.Uint64
+	// The bytes received in each of the last few seconds
+	samples []uint64
+}
+
+func newBandwidthBucket(bytesPerSecond uint64) *bandwidthBucket {
+	b := &bandwidthBucket{lim: rate.NewLimiter(0, 0)}
+	b.setLimit(bytesPerSecond)
+	return b
+}
+
+// setLimit changes the rate of the bucket. The burst is one second's worth of
+// bytes, so that a transfer can't get far ahead of its limit.
+func (b *bandwidthBucket) setLimit(bytesPerSecond uint64) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	if bytesPerSecond == 0 {
+		b.unlimited = true
+		return
+	}
+	b.lim.SetLimit(rate.Limit(bytesPerSecond))
+	b.lim.SetBurst(int(bytesPerSecond))
+	b.unlimited = false
+}
+
+// waitN blocks until the bucket has enough tokens for n bytes. If n is
+// larger than the burst, it waits for tokens one burst at a time.
+func (b *bandwidthBucket) waitN(ctx context.Context, n int) error {
+	for n > 0 {
+		chunk, r := b.reserve(n)
+		if r == nil {
+			return nil
+		}
+		if delay := r.Delay(); delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				r.Cancel()
+				return ctx.Err()
+			}
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+// reserve reserves tokens for up to n bytes, returning the number of bytes
+// reserved. The reservation is made under the lock, so the number of bytes
+// can't exceed the burst even if the limit is changed concurrently.
+// Returns a nil reservation if there is no limit.
+func (b *bandwidthBucket) reserve(n int) (int, *rate.Reservation) {
+	b.lk.RLock()
+	defer b.lk.RUnlock()
+
+	if b.unlimited {
+		return n, nil
+	}
+	if burst := b.lim.Burst(); n > burst {
+		n = burst
+	}
+	return n, b.lim.ReserveN(time.Now(), n)
+}
+
+// sample records the bytes received since the last sample
+func (b *bandwidthBucket) sample() {
+	b.samples = append(b.samples, b.received.Swap(0))
+	if len(b.samples) > rateSampleWindow {
+		b.samples = b.samples[1:]
+	}
+}
+
+// rate is the average bytes per second over the samples
+func (b *bandwidthBucket) rate() uint64 {
+	if len(b.samples) == 0 {
+		return 0
+	}
+	var total uint64
+	for _, s := range b.samples {
+		total += s
+	}
+	return total / uint64(len(b.samples))
+}
+
+// bandwidthLimiter limits the bandwidth of inbound transfers, globally and
+// for each host and client address.
+//
+// Each limit is a token bucket that is shared by all the transfers the
+// limit applies to: a transfer waits for tokens from the global bucket, the
+// bucket for its host and the bucket for its client before it writes the
+// bytes it has read. The buckets for a host or client only exist while there
+// are active transfers from that host or client.
+type bandwidthLimiter struct {
+	lk sync.Mutex
+	// The limit for each kind and ID (the global limit has an empty ID)
+	limits map[rateLimitKey]uint64
+	global *bandwidthBucket
+	// The buckets for hosts and clients with active transfers
+	buckets map[rateLimitKey]*bandwidthBucket
+}
+
+func newBandwidthLimiter(cfg TransferRateLimitConfig) *bandwidthLimiter {
+	bl := &bandwidthLimiter{
+		limits:  make(map[rateLimitKey]uint64),
+		buckets: make(map[rateLimitKey]*bandwidthBucket),
+	}
+	for k, bps := range map[rateLimitKey]uint64{
+		{RateLimitGlobal, ""}:                 cfg.Global,
+		{RateLimitHost, RateLimitDefaultID}:   cfg.PerHost,
+		{RateLimitClient, RateLimitDefaultID}: cfg.PerClient,
+	} {
+		if bps > 0 {
+			bl.limits[k] = bps
+		}
+	}
+	bl.global = newBandwidthBucket(bl.limits[rateLimitKey{RateLimitGlobal, ""}])
+	return bl
+}
+
+// run samples the rate of each bucket every second, and records the global
+// and per-host rates in the metrics
+func (bl *bandwidthLimiter) run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			bl.sample(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (bl *bandwidthLimiter) sample(ctx context.Context) {
+	bl.lk.Lock()
+	defer bl.lk.Unlock()
+
+	bl.global.sample()
+	recordRateMetrics(ctx, rateLimitKey{RateLimitGlobal, ""}, bl.limits[rateLimitKey{RateLimitGlobal, ""}], bl.global.rate())
+	for k, b := range bl.buckets {
+		b.sample()
+		// Only record metrics for hosts, because there may be many more
+		// client addresses than hosts
+		if k.kind == RateLimitHost {
+			bps, _ := bl.limitFor(k)
+			recordRateMetrics(ctx, k, bps, b.rate())
+		}
+	}
+}
+
+func recordRateMetrics(ctx context.Context, k rateLimitKey, bytesPerSecond uint64, bytesReceived uint64) {
+	ctx, _ = tag.New(ctx, tag.Upsert(metrics.RateLimitKind, k.kind), tag.Upsert(metrics.RateLimitID, k.id))
+	stats.Record(ctx, metrics.TransferRateLimit.M(int64(bytesPerSecond)), metrics.TransferRate.M(int64(bytesReceived)))
+}
+
+// limitFor returns the limit that applies to the host or client, and
+// whether the limit is set for that ID explicitly.
+// Must be called with the lock held.
+func (bl *bandwidthLimiter) limitFor(k rateLimitKey) (uint64, bool) {
+	if bps, ok := bl.limits[k]; ok {
+		return bps, true
+	}
+	return bl.limits[rateLimitKey{k.kind, RateLimitDefaultID}], false
+}
+
+// transferLimiter returns a rate limiter for a transfer from the given host,
+// for a deal from the given client. release must be called when the
+// transfer ends.
+func (bl *bandwidthLimiter) transferLimiter(host string, client string) *transferRateLimiter {
+	bl.lk.Lock()
+	defer bl.lk.Unlock()
+
+	trl := &transferRateLimiter{bl: bl, buckets: []*bandwidthBucket{bl.global}}
+	for _, k := range []rateLimitKey{{RateLimitHost, host}, {RateLimitClient, client}} {
+		b, ok := bl.buckets[k]
+		if !ok {
+			bps, _ := bl.limitFor(k)
+			b = newBandwidthBucket(bps)
+			bl.buckets[k] = b
+		}
+		b.refs++
+		trl.keys = append(trl.keys, k)
+		trl.buckets = append(trl.buckets, b)
+	}
+	return trl
+}
+
+func (bl *bandwidthLimiter) release(keys []rateLimitKey) {
+	bl.lk.Lock()
+	defer bl.lk.Unlock()
+
+	for _, k := range keys {
+		b, ok := bl.buckets[k]
+		if !ok {
+			continue
+		}
+		b.refs--
+		if b.refs <= 0 {
+			delete(bl.buckets, k)
+		}
+	}
+}
+
+// list returns the limits that have been set, followed by the limits of the
+// hosts and clients with active transfers that don't have their own limit
+func (bl *bandwidthLimiter) list() []TransferRateLimit {
+	bl.lk.Lock()
+	defer bl.lk.Unlock()
+
+	limits := make([]TransferRateLimit, 0, len(bl.limits)+len(bl.buckets))
+	add := func(k rateLimitKey) {
+		bps, explicit := bl.limitFor(k)
+		l := TransferRateLimit{Kind: k.kind, ID: k.id, BytesPerSecond: bps, Explicit: explicit}
+		switch {
+		case k.kind == RateLimitGlobal:
+			// The global limit applies to all transfers
+			l.Rate = bl.global.rate()
+			for bk, b := range bl.buckets {
+				if bk.kind == RateLimitHost {
+					l.Transfers += b.refs
+				}
+			}
+		case bl.buckets[k] != nil:
+			l.Transfers = bl.buckets[k].refs
+			l.Rate = bl.buckets[k].rate()
+		}
+		limits = append(limits, l)
+	}
+
+	if _, ok := bl.limits[rateLimitKey{RateLimitGlobal, ""}]; !ok {
+		// Always include the global limit, so that the global rate is shown
+		add(rateLimitKey{RateLimitGlobal, ""})
+	}
+	for k := range bl.limits {
+		add(k)
+	}
+	for k := range bl.buckets {
+		if _, ok := bl.limits[k]; !ok {
+			add(k)
+		}
+	}
+
+	// Sort by kind (global first), then by ID
+	kindOrder := map[string]int{RateLimitGlobal: 0, RateLimitHost: 1, RateLimitClient: 2}
+	sort.Slice(limits, func(i, j int) bool {
+		if limits[i].Kind != limits[j].Kind {
+			return kindOrder[limits[i].Kind] < kindOrder[limits[j].Kind]
+		}
+		return limits[i].ID < limits[j].ID
+	})
+	return limits
+}
+
+// hostStats returns the limit and achieved rate of each host with active
+// transfers
+func (bl *bandwidthLimiter) hostStats() map[string]TransferRateLimit {
+	bl.lk.Lock()
+	defer bl.lk.Unlock()
+
+	stats := make(map[string]TransferRateLimit, len(bl.buckets))
+	for k, b := range bl.buckets {
+		if k.kind != RateLimitHost {
+			continue
+		}
+		bps, explicit := bl.limitFor(k)
+		stats[k.id] = TransferRateLimit{
+			Kind:           k.kind,
+			ID:             k.id,
+			BytesPerSecond: bps,
+			Explicit:       explicit,
+			Transfers:      b.refs,
+			Rate:           b.rate(),
+		}
+	}
+	return stats
+}
+
+// set sets the limit for the kind and ID, and applies it to the active
+// transfers that it affects
+func (bl *bandwidthLimiter) set(k rateLimitKey, bytesPerSecond uint64) {
+	bl.lk.Lock()
+	defer bl.lk.Unlock()
+
+	bl.limits[k] = bytesPerSecond
+	bl.apply(k)
+}
+
+// remove removes the limit for the kind and ID. Transfers that it applied to
+// are subject to the default limit for the kind (or no limit, if the default
+// or global limit is removed).
+func (bl *bandwidthLimiter) remove(k rateLimitKey) bool {
+	bl.lk.Lock()
+	defer bl.lk.Unlock()
+
+	if _, ok := bl.limits[k]; !ok {
+		return false
+	}
+	delete(bl.limits, k)
+	bl.apply(k)
+	return true
+}
+
+// apply updates the buckets affected by a change to the limit for the key.
+// Must be called with the lock held.
+func (bl *bandwidthLimiter) apply(k rateLimitKey) {
+	if k.kind == RateLimitGlobal {
+		bl.global.setLimit(bl.limits[k])
+		return
+	}
+	for bk, b := range bl.buckets {
+		if bk.kind != k.kind {
+			continue
+		}
+		// A change to the default limit affects each host or client that
+		// doesn't have its own limit
+		if bk.id == k.id || k.id == RateLimitDefaultID {
+			bps, _ := bl.limitFor(bk)
+			b.setLimit(bps)
+		}
+	}
+}
+
+// transferRateLimiter limits the bandwidth of a single transfer, by waiting
+// for tokens from each of the buckets that apply to the transfer
+type transferRateLimiter struct {
+	bl      *bandwidthLimiter
+	keys    []rateLimitKey
+	buckets []*bandwidthBucket
+}
+
+func (l *transferRateLimiter) WaitN(ctx context.Context, n int) error {
+	for _, b := range l.buckets {
+		if err := b.waitN(ctx, n); err != nil {
+			return fmt.Errorf("waiting for transfer bandwidth: %w", err)
+		}
+	}
+	for _, b := range l.buckets {
+		b.received.Add(uint64(n))
+	}
+	return nil
+}
+
+func (l *transferRateLimiter) release() {
+	l.bl.release(l.keys)
+}
+
+// TransferRateLimits returns the limits on inbound transfer bandwidth, with
+// the rate achieved by the transfers that each limit applies to
+func (p *Provider) TransferRateLimits() []TransferRateLimit {
+	return p.bwLimiter.list()
+}
+
+// SetTransferRateLimit sets the limit on inbound transfer bandwidth for the
+// kind and ID, in bytes per second (zero means no limit). The limit applies
+// immediately to active transfers. Limits set at runtime are not persisted:
+// the limits in the config apply after a restart.
+func (p *Provider) SetTransferRateLimit(kind string, id string, bytesPerSecond uint64) error {
+	k, err := normalizeRateLimitKey(kind, id)
+	if err != nil {
+		return err
+	}
+	p.bwLimiter.set(k, bytesPerSecond)
+	log.Infow("set transfer rate limit", "kind", k.kind, "id", k.id, "bytesPerSecond", bytesPerSecond)
+	return nil
+}
+
+// RemoveTransferRateLimit removes the limit on inbound transfer bandwidth
+// for the kind and ID
+func (p *Provider) RemoveTransferRateLimit(kind string, id string) error {
+	k, err := normalizeRateLimitKey(kind, id)
+	if err != nil {
+		return err
+	}
+	if !p.bwLimiter.remove(k) {
+		return fmt.Errorf("there is no %s transfer rate limit for '%s'", k.kind, k.id)
+	}
+	log.Infow("removed transfer rate limit", "kind", k.kind, "id", k.id)
+	return nil
+}
+
+// normalizeRateLimitKey checks the kind of rate limit, and parses client
+// addresses so that they are in the same format as the address stored with
+// each deal
+func normalizeRateLimitKey(kind string, id string) (rateLimitKey, error) {
+	switch kind {
+	case RateLimitGlobal:
+		// There is only one global limit, so it doesn't have an ID
+		return rateLimitKey{kind: kind}, nil
+	case RateLimitHost, RateLimitClient:
+	default:
+		return rateLimitKey{}, fmt.Errorf("unrecognized transfer rate limit kind '%s': must be one of %s, %s or %s",
+			kind, RateLimitGlobal, RateLimitHost, RateLimitClient)
+	}
+
+	if id == "" {
+		return rateLimitKey{}, errors.New("host and client transfer rate limits must have an ID")
+	}
+	if kind == RateLimitClient && id != RateLimitDefaultID {
+		addr, err := address.NewFromString(id)
+		if err != nil {
+			return rateLimitKey{}, fmt.Errorf("parsing client address '%s': %w", id, err)
+		}
+		id = addr.String()
+	}
+	return rateLimitKey{kind: kind, id: id}, nil
+}
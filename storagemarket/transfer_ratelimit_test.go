@@ -0,0 +1,146 @@
+package storagemarket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestBandwidthLimiterWait(t *testing.T) {
+	ctx := context.Background()
+	bl := newBandwidthLimiter(TransferRateLimitConfig{Global: 1024 * 1024})
+
+	// The bucket starts empty, so receiving 1.5MiB at 1MiB/s should take at
+	// least a second
+	trl := bl.transferLimiter("foo.com", "f01234")
+	defer trl.release()
+	start := time.Now()
+	for i := 0; i < 24; i++ {
+		require.NoError(t, trl.WaitN(ctx, 64*1024))
+	}
+	require.GreaterOrEqual(t, time.Since(start), time.Second)
+
+	// Lowering the limit should apply to the active transfer. Waiting for
+	// more bytes than the burst waits for one burst at a time, until the
+	// context is cancelled.
+	bl.set(rateLimitKey{RateLimitGlobal, ""}, 1024)
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	err := trl.WaitN(ctx, 4096)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Removing the limit should allow transfers to proceed immediately
+	require.True(t, bl.remove(rateLimitKey{RateLimitGlobal, ""}))
+	require.NoError(t, trl.WaitN(context.Background(), 10*1024*1024))
+}
+
+func TestBandwidthLimiterChangeLimitDuringTransfer(t *testing.T) {
+	bl := newBandwidthLimiter(TransferRateLimitConfig{Global: 64 * 1024 * 1024})
+	trl := bl.transferLimiter("foo.com", "f01234")
+	defer trl.release()
+
+	// Keep changing the limit (and therefore the burst) while the transfer
+	// waits for more bytes than the lower burst
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	go func() {
+		for i := 0; ctx.Err() == nil; i++ {
+			bps := uint64(64 * 1024 * 1024)
+			if i%2 == 0 {
+				bps = 256 * 1024
+			}
+			bl.set(rateLimitKey{RateLimitGlobal, ""}, bps)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	for ctx.Err() == nil {
+		err := trl.WaitN(ctx, 1024*1024)
+		if ctx.Err() != nil {
+			break
+		}
+		require.NoError(t, err)
+	}
+}
+
+func TestBandwidthLimiterLimits(t *testing.T) {
+	bl := newBandwidthLimiter(TransferRateLimitConfig{PerHost: 1000, PerClient: 2000})
+
+	// Each host gets its own bucket with the default limit
+	trl1 := bl.transferLimiter("foo.com", "f01234")
+	trl2 := bl.transferLimiter("bar.com", "f01234")
+	fooBucket := bl.buckets[rateLimitKey{RateLimitHost, "foo.com"}]
+	barBucket := bl.buckets[rateLimitKey{RateLimitHost, "bar.com"}]
+	clientBucket := bl.buckets[rateLimitKey{RateLimitClient, "f01234"}]
+	require.Equal(t, rate.Limit(1000), fooBucket.lim.Limit())
+	require.Equal(t, rate.Limit(1000), barBucket.lim.Limit())
+	require.Equal(t, rate.Limit(2000), clientBucket.lim.Limit())
+	require.Equal(t, 2, clientBucket.refs)
+
+	// Setting a limit for a host applies to that host's active transfers
+	bl.set(rateLimitKey{RateLimitHost, "foo.com"}, 5000)
+	require.Equal(t, rate.Limit(5000), fooBucket.lim.Limit())
+	require.Equal(t, rate.Limit(1000), barBucket.lim.Limit())
+
+	// Changing the default limit only applies to hosts without their own
+	// limit
+	bl.set(rateLimitKey{RateLimitHost, RateLimitDefaultID}, 3000)
+	require.Equal(t, rate.Limit(5000), fooBucket.lim.Limit())
+	require.Equal(t, rate.Limit(3000), barBucket.lim.Limit())
+
+	// Removing the host's limit reverts it to the default limit
+	require.True(t, bl.remove(rateLimitKey{RateLimitHost, "foo.com"}))
+	require.False(t, bl.remove(rateLimitKey{RateLimitHost, "foo.com"}))
+	require.Equal(t, rate.Limit(3000), fooBucket.lim.Limit())
+
+	limits := bl.list()
+	require.Equal(t, []TransferRateLimit{
+		{Kind: RateLimitGlobal, Transfers: 2},
+		{Kind: RateLimitHost, ID: RateLimitDefaultID, BytesPerSecond: 3000, Explicit: true},
+		{Kind: RateLimitHost, ID: "bar.com", BytesPerSecond: 3000, Transfers: 1},
+		{Kind: RateLimitHost, ID: "foo.com", BytesPerSecond: 3000, Transfers: 1},
+		{Kind: RateLimitClient, ID: RateLimitDefaultID, BytesPerSecond: 2000, Explicit: true},
+		{Kind: RateLimitClient, ID: "f01234", BytesPerSecond: 2000, Transfers: 2},
+	}, limits)
+
+	// The rate is averaged over the samples
+	require.NoError(t, trl1.WaitN(context.Background(), 500))
+	bl.sample(context.Background())
+	require.NoError(t, trl2.WaitN(context.Background(), 100))
+	bl.sample(context.Background())
+	stats := bl.hostStats()
+	require.EqualValues(t, 250, stats["foo.com"].Rate)
+	require.EqualValues(t, 50, stats["bar.com"].Rate)
+	require.EqualValues(t, 300, bl.global.rate())
+
+	// The buckets are removed when there are no more transfers that use them
+	trl1.release()
+	require.Nil(t, bl.buckets[rateLimitKey{RateLimitHost, "foo.com"}])
+	require.NotNil(t, bl.buckets[rateLimitKey{RateLimitClient, "f01234"}])
+	trl2.release()
+	require.Empty(t, bl.buckets)
+}
+
+func TestNormalizeRateLimitKey(t *testing.T) {
+	k, err := normalizeRateLimitKey(RateLimitGlobal, "ignored")
+	require.NoError(t, err)
+	require.Equal(t, rateLimitKey{kind: RateLimitGlobal}, k)
+
+	k, err = normalizeRateLimitKey(RateLimitHost, "foo.com:443")
+	require.NoError(t, err)
+	require.Equal(t, rateLimitKey{kind: RateLimitHost, id: "foo.com:443"}, k)
+
+	k, err = normalizeRateLimitKey(RateLimitClient, RateLimitDefaultID)
+	require.NoError(t, err)
+	require.Equal(t, rateLimitKey{kind: RateLimitClient, id: RateLimitDefaultID}, k)
+
+	_, err = normalizeRateLimitKey(RateLimitClient, "not an address")
+	require.Error(t, err)
+	_, err = normalizeRateLimitKey(RateLimitHost, "")
+	require.Error(t, err)
+	_, err = normalizeRateLimitKey("peer", "foo")
+	require.Error(t, err)
+}
@@ -194,5 +194,25 @@ func (p *Provider) IsTransferStalled(dealUuid uuid.UUID) bool {
 }
 
 func (p *Provider) TransferStats() []*HostTransferStats {
-	return p.xferLimiter.stats()
+	stats := p.xferLimiter.stats()
+	rates := p.bwLimiter.hostStats()
+	for _, s := range stats {
+		if r, ok := rates[s.Host]; ok {
+			s.RateLimit = r.BytesPerSecond
+			s.BytesPerSecond = r.Rate
+		}
+	}
+	return stats
+}
+
+// TransferQueue returns the transfers that are waiting to start, in the
+// order in which they will be started
+func (p *Provider) TransferQueue() []*QueuedTransfer {
+	return p.xferLimiter.queue()
+}
+
+// SetTransferPriority overrides the priority of a transfer that is waiting
+// to start. If priority is nil the priority is calculated from the deal.
+func (p *Provider) SetTransferPriority(dealUuid uuid.UUID, priority *int) error {
+	return p.xferLimiter.setPriority(dealUuid, priority)
 }
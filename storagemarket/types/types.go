@@ -2,14 +2,9 @@ package types
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"io"
-	"net/url"
 
 	"github.com/filecoin-project/boost-gfm/storagemarket"
-	"github.com/filecoin-project/boost/transport/httptransport/util"
-	"github.com/filecoin-project/boost/transport/types"
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/builtin/v9/market"
@@ -18,10 +13,9 @@ import (
 	"github.com/filecoin-project/lotus/storage/sealer/storiface"
 	"github.com/google/uuid"
 	"github.com/ipfs/go-cid"
-	"github.com/ipni/go-libipni/maurl"
 )
 
-//go:generate cbor-gen-for --map-encoding StorageAsk DealParamsV120 DealParams Transfer DealResponse DealStatusRequest DealStatusResponse DealStatus
+//go:generate cbor-gen-for --map-encoding StorageAsk DealParamsV120 DealParams Transfer DealResponse DealStatusRequest DealStatusResponse DealStatus BatchDealParams BatchDealResponse DealStatusSubscribeRequest TransportsResponse
 //go:generate go run github.com/golang/mock/mockgen -destination=mock_types/mocks.go -package=mock_types . PieceAdder,CommpCalculator,DealPublisher,ChainDealManager,IndexProvider
 
 // StorageAsk defines the parameters by which a miner will choose to accept or
@@ -45,6 +39,20 @@ type DealStatusRequest struct {
 	Signature crypto.Signature
 }
 
+// DealStatusSubscribeRequest is sent to subscribe to updates to the state of
+// one or more deals. Each deal status request is signed by the client of
+// the deal, in the same way as a single DealStatusRequest.
+type DealStatusSubscribeRequest struct {
+	Deals []DealStatusRequest
+}
+
+// TransportsResponse is sent in response to a query asking which transfer
+// types (eg "http", "libp2p", "s3") a storage provider supports for
+// downloading deal data
+type TransportsResponse struct {
+	TransferTypes []string
+}
+
 // DealStatusResponse is the current state of a deal
 type DealStatusResponse struct {
 	DealUUID uuid.UUID
@@ -98,42 +106,6 @@ type Transfer struct {
 	Size uint64
 }
 
-func (t *Transfer) Host() (string, error) {
-	if t.Type != "http" && t.Type != "libp2p" {
-		return "", fmt.Errorf("cannot parse params for unrecognized transfer type '%s'", t.Type)
-	}
-
-	// de-serialize transport opaque token
-	tInfo := &types.HttpRequest{}
-	if err := json.Unmarshal(t.Params, tInfo); err != nil {
-		return "", fmt.Errorf("failed to de-serialize transport params bytes '%s': %w", string(t.Params), err)
-	}
-
-	// Parse http / multiaddr url
-	u, err := util.ParseUrl(tInfo.URL)
-	if err != nil {
-		return "", fmt.Errorf("cannot parse url '%s': %w", tInfo.URL, err)
-	}
-
-	// If the url is in libp2p format
-	if u.Scheme == util.Libp2pScheme {
-		// Get the host from the multiaddr
-		mahttp, err := maurl.ToURL(u.Multiaddr)
-		if err != nil {
-			return "", err
-		}
-		return mahttp.Host, nil
-	}
-
-	// Otherwise parse as an http url
-	httpUrl, err := url.Parse(u.Url)
-	if err != nil {
-		return "", fmt.Errorf("cannot parse url '%s' from '%s': %w", u.Url, tInfo.URL, err)
-	}
-
-	return httpUrl.Host, nil
-}
-
 type DealResponse struct {
 	Accepted bool
 	// Message is the reason the deal proposal was rejected. It is empty if
@@ -141,6 +113,17 @@ type DealResponse struct {
 	Message string
 }
 
+// BatchDealParams is a batch of deal proposals sent in a single request
+type BatchDealParams struct {
+	Deals []DealParams
+}
+
+// BatchDealResponse has a response for each of the deal proposals in a
+// batch, in the same order as the proposals
+type BatchDealResponse struct {
+	Responses []DealResponse
+}
+
 type PieceAdder interface {
 	AddPiece(ctx context.Context, size abi.UnpaddedPieceSize, r io.Reader, d api.PieceDealInfo) (abi.SectorNumber, abi.PaddedPieceSize, error)
 }
@@ -0,0 +1,386 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/storagemarket"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/go-address"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p/core/event"
+)
+
+var log = logging.Logger("webhooks")
+
+// EventFailed is the name of the event that is fired when a deal fails.
+// The other events are named after the checkpoint the deal moved to
+// (eg "Published").
+const EventFailed = "Failed"
+
+const (
+	// The header with the name of the event
+	HeaderEvent = "X-Boost-Event"
+	// The header with the ID of the delivery. The ID is the same for each
+	// attempt to deliver the same event.
+	HeaderDelivery = "X-Boost-Delivery"
+	// The header with the HMAC-SHA256 signature of the request body, keyed
+	// with the target's secret, in the format "sha256=<hex>"
+	HeaderSignature = "X-Boost-Signature"
+)
+
+// Target is a URL that deal events are sent to
+type Target struct {
+	URL string
+	// The secret used to sign requests. If empty, requests are not signed.
+	Secret string
+	// The events to send (checkpoint names or "Failed"). If empty, all
+	// events are sent.
+	Events []string
+	// Only send events for deals from these client addresses. If empty,
+	// events for deals from all clients are sent.
+	Clients []string
+}
+
+type Config struct {
+	Targets []Target
+	// The maximum number of attempts to deliver an event before it is moved
+	// to the dead letter table
+	MaxAttempts int
+	// The delay before the first retry. The delay doubles with each
+	// subsequent retry, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// The timeout for each request to a target
+	Timeout time.Duration
+	// How often to check for deliveries that are due to be retried
+	PollPeriod time.Duration
+}
+
+// Payload is the JSON body of a webhook request
+type Payload struct {
+	Event        string    `json:"event"`
+	DealUuid     string    `json:"dealUuid"`
+	Checkpoint   string    `json:"checkpoint"`
+	Client       string    `json:"client"`
+	ClientPeerID string    `json:"clientPeerId"`
+	Provider     string    `json:"provider"`
+	PieceCid     string    `json:"pieceCid"`
+	PieceSize    uint64    `json:"pieceSize"`
+	Verified     bool      `json:"verified"`
+	IsOffline    bool      `json:"isOffline"`
+	StartEpoch   int64     `json:"startEpoch"`
+	ChainDealID  uint64    `json:"chainDealId,omitempty"`
+	PublishCid   string    `json:"publishCid,omitempty"`
+	SectorID     uint64    `json:"sectorId,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+type target struct {
+	Target
+	events  map[string]struct{}
+	clients map[address.Address]struct{}
+}
+
+func (t *target) matches(event string, client address.Address) bool {
+	if len(t.events) > 0 {
+		if _, ok := t.events[event]; !ok {
+			return false
+		}
+	}
+	if len(t.clients) > 0 {
+		if _, ok := t.clients[client]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Dispatcher sends deal checkpoint events to webhook targets. Events are
+// persisted in the database before they are sent, so that deliveries are
+// retried with backoff (including across restarts). Events that can't be
+// delivered after MaxAttempts are moved to the dead letter table.
+type Dispatcher struct {
+	cfg     Config
+	db      *db.WebhooksDB
+	client  *http.Client
+	targets map[string]*target
+
+	// Signals that there are new deliveries
+	wake chan struct{}
+}
+
+func New(cfg Config, wdb *db.WebhooksDB) (*Dispatcher, error) {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.PollPeriod <= 0 {
+		cfg.PollPeriod = 5 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+
+	targets := make(map[string]*target, len(cfg.Targets))
+	for _, t := range cfg.Targets {
+		if _, err := url.ParseRequestURI(t.URL); err != nil {
+			return nil, fmt.Errorf("parsing webhook target url '%s': %w", t.URL, err)
+		}
+		if _, ok := targets[t.URL]; ok {
+			return nil, fmt.Errorf("duplicate webhook target url '%s'", t.URL)
+		}
+
+		tgt := &target{
+			Target:  t,
+			events:  make(map[string]struct{}, len(t.Events)),
+			clients: make(map[address.Address]struct{}, len(t.Clients)),
+		}
+		for _, evt := range t.Events {
+			if evt != EventFailed {
+				if _, err := dealcheckpoints.FromString(evt); err != nil {
+					return nil, fmt.Errorf("webhook target '%s': unrecognized event '%s'", t.URL, evt)
+				}
+			}
+			tgt.events[evt] = struct{}{}
+		}
+		for _, c := range t.Clients {
+			addr, err := address.NewFromString(c)
+			if err != nil {
+				return nil, fmt.Errorf("webhook target '%s': parsing client address '%s': %w", t.URL, c, err)
+			}
+			tgt.clients[addr] = struct{}{}
+		}
+		targets[t.URL] = tgt
+	}
+
+	return &Dispatcher{
+		cfg:     cfg,
+		db:      wdb,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		targets: targets,
+		wake:    make(chan struct{}, 1),
+	}, nil
+}
+
+// Run reads deal checkpoint events from the subscription and sends them to
+// the webhook targets until the context is cancelled
+func (d *Dispatcher) Run(ctx context.Context, sub event.Subscription) {
+	defer sub.Close()
+
+	go d.deliverLoop(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt, ok := <-sub.Out():
+			if !ok {
+				return
+			}
+			if err := d.enqueue(ctx, evt.(storagemarket.DealCheckpointEvent)); err != nil {
+				log.Errorw("queueing webhook deliveries", "err", err)
+			}
+		}
+	}
+}
+
+// enqueue persists a delivery of the event for each target that matches it
+func (d *Dispatcher) enqueue(ctx context.Context, evt storagemarket.DealCheckpointEvent) error {
+	deal := evt.Deal
+	prop := deal.ClientDealProposal.Proposal
+	name := deal.Checkpoint.String()
+	if evt.Failed {
+		name = EventFailed
+	}
+
+	var payload []byte
+	queued := false
+	for _, t := range d.targets {
+		if !t.matches(name, prop.Client) {
+			continue
+		}
+
+		if payload == nil {
+			p := Payload{
+				Event:        name,
+				DealUuid:     deal.DealUuid.String(),
+				Checkpoint:   deal.Checkpoint.String(),
+				Client:       prop.Client.String(),
+				ClientPeerID: deal.ClientPeerID.String(),
+				Provider:     prop.Provider.String(),
+				PieceCid:     prop.PieceCID.String(),
+				PieceSize:    uint64(prop.PieceSize),
+				Verified:     prop.VerifiedDeal,
+				IsOffline:    deal.IsOffline,
+				StartEpoch:   int64(prop.StartEpoch),
+				ChainDealID:  uint64(deal.ChainDealID),
+				SectorID:     uint64(deal.SectorID),
+				Error:        deal.Err,
+				Timestamp:    time.Now(),
+			}
+			if deal.PublishCID != nil {
+				p.PublishCid = deal.PublishCID.String()
+			}
+			var err error
+			payload, err = json.Marshal(p)
+			if err != nil {
+				return fmt.Errorf("marshalling webhook payload: %w", err)
+			}
+		}
+
+		err := d.db.Enqueue(ctx, &db.WebhookDelivery{
+			URL:      t.URL,
+			Event:    name,
+			DealUUID: deal.DealUuid.String(),
+			Payload:  payload,
+		})
+		if err != nil {
+			return err
+		}
+		queued = true
+	}
+
+	if queued {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) deliverLoop(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollPeriod)
+	defer ticker.Stop()
+
+	for {
+		d.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// deliverDue attempts each delivery that is due
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	const batchSize = 64
+	for ctx.Err() == nil {
+		due, err := d.db.Due(ctx, time.Now(), batchSize)
+		if err != nil {
+			log.Errorw("getting webhook deliveries", "err", err)
+			return
+		}
+
+		for _, dl := range due {
+			d.attempt(ctx, dl)
+		}
+
+		if len(due) < batchSize {
+			return
+		}
+	}
+}
+
+// attempt tries to deliver the request, and then either removes it from
+// the queue, schedules a retry, or moves it to the dead letter table
+func (d *Dispatcher) attempt(ctx context.Context, dl db.WebhookDelivery) {
+	attempts := dl.Attempts + 1
+
+	var err error
+	t, ok := d.targets[dl.URL]
+	if ok {
+		err = d.send(ctx, t, dl)
+	} else {
+		// The target was removed from the config
+		attempts = d.cfg.MaxAttempts
+		err = fmt.Errorf("webhook target is no longer configured")
+	}
+	if ctx.Err() != nil {
+		// Shutting down: try again on restart
+		return
+	}
+
+	if err == nil {
+		if err := d.db.Delivered(ctx, dl.ID); err != nil {
+			log.Errorw("removing delivered webhook", "id", dl.ID, "err", err)
+		}
+		return
+	}
+
+	if attempts >= d.cfg.MaxAttempts {
+		log.Warnw("giving up on webhook delivery", "id", dl.ID, "url", dl.URL, "event", dl.Event,
+			"deal", dl.DealUUID, "attempts", attempts, "err", err)
+		if err := d.db.DeadLetter(ctx, dl.ID, attempts, err.Error()); err != nil {
+			log.Errorw("moving webhook delivery to dead letters", "id", dl.ID, "err", err)
+		}
+		return
+	}
+
+	next := time.Now().Add(d.backoff(attempts))
+	log.Debugw("webhook delivery failed", "id", dl.ID, "url", dl.URL, "attempts", attempts, "next attempt", next, "err", err)
+	if err := d.db.Retry(ctx, dl.ID, attempts, err.Error(), next); err != nil {
+		log.Errorw("scheduling webhook delivery retry", "id", dl.ID, "err", err)
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, t *target, dl db.WebhookDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(dl.Payload))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, dl.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(dl.ID, 10))
+	if t.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(t.Secret, dl.Payload))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// backoff returns the delay before the next attempt, after the given number
+// of attempts
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.InitialBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if d.cfg.MaxBackoff > 0 && delay >= d.cfg.MaxBackoff {
+			return d.cfg.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// Sign returns the signature of the body in the format "sha256=<hex>", where
+// hex is the hex encoded HMAC-SHA256 of the body keyed with the secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
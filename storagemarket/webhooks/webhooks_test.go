@@ -0,0 +1,177 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/filecoin-project/boost/storagemarket"
+	smtypes "github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/go-address"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+type testServer struct {
+	*httptest.Server
+	lk       sync.Mutex
+	status   int
+	requests []received
+}
+
+func newTestServer(t *testing.T) *testServer {
+	ts := &testServer{status: http.StatusOK}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts.lk.Lock()
+		defer ts.lk.Unlock()
+		ts.requests = append(ts.requests, received{header: r.Header, body: body})
+		w.WriteHeader(ts.status)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func (ts *testServer) received() []received {
+	ts.lk.Lock()
+	defer ts.lk.Unlock()
+	return append([]received{}, ts.requests...)
+}
+
+func newWebhooksDB(t *testing.T) *db.WebhooksDB {
+	ctx := context.Background()
+	sqldb := db.CreateTestTmpDB(t)
+	require.NoError(t, db.CreateAllBoostTables(ctx, sqldb, sqldb))
+	require.NoError(t, migrations.Migrate(sqldb))
+	return db.NewWebhooksDB(sqldb)
+}
+
+func checkpointEvent(t *testing.T, client string, cp dealcheckpoints.Checkpoint, failed bool) storagemarket.DealCheckpointEvent {
+	addr, err := address.NewFromString(client)
+	require.NoError(t, err)
+
+	var deal smtypes.ProviderDealState
+	deal.DealUuid = uuid.New()
+	deal.ClientDealProposal.Proposal.Client = addr
+	deal.Checkpoint = cp
+	if failed {
+		deal.Err = "deal failed"
+	}
+	return storagemarket.DealCheckpointEvent{Deal: deal, Failed: failed}
+}
+
+func TestWebhookFiltersAndSignature(t *testing.T) {
+	ctx := context.Background()
+	published := newTestServer(t)
+	client := newTestServer(t)
+
+	d, err := New(Config{
+		Targets: []Target{{
+			URL:    published.URL,
+			Secret: "s3cret",
+			Events: []string{dealcheckpoints.Published.String(), EventFailed},
+		}, {
+			URL:     client.URL,
+			Clients: []string{"f01234"},
+		}},
+		MaxAttempts: 3,
+	}, newWebhooksDB(t))
+	require.NoError(t, err)
+
+	require.NoError(t, d.enqueue(ctx, checkpointEvent(t, "f01000", dealcheckpoints.Transferred, false)))
+	require.NoError(t, d.enqueue(ctx, checkpointEvent(t, "f01000", dealcheckpoints.Published, false)))
+	require.NoError(t, d.enqueue(ctx, checkpointEvent(t, "f01234", dealcheckpoints.Complete, true)))
+	d.deliverDue(ctx)
+
+	// The published target receives the Published and Failed events,
+	// signed with its secret
+	reqs := published.received()
+	require.Len(t, reqs, 2)
+	for _, r := range reqs {
+		require.Equal(t, Sign("s3cret", r.body), r.header.Get(HeaderSignature))
+	}
+	var p Payload
+	require.NoError(t, json.Unmarshal(reqs[0].body, &p))
+	require.Equal(t, dealcheckpoints.Published.String(), p.Event)
+	require.Equal(t, "f01000", p.Client)
+	require.NoError(t, json.Unmarshal(reqs[1].body, &p))
+	require.Equal(t, EventFailed, p.Event)
+	require.Equal(t, "deal failed", p.Error)
+
+	// The client target receives only the event for the client's deal,
+	// without a signature
+	reqs = client.received()
+	require.Len(t, reqs, 1)
+	require.Equal(t, EventFailed, reqs[0].header.Get(HeaderEvent))
+	require.Empty(t, reqs[0].header.Get(HeaderSignature))
+}
+
+func TestWebhookRetryAndDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	srv := newTestServer(t)
+	srv.status = http.StatusInternalServerError
+
+	wdb := newWebhooksDB(t)
+	d, err := New(Config{
+		Targets:     []Target{{URL: srv.URL}},
+		MaxAttempts: 2,
+	}, wdb)
+	require.NoError(t, err)
+
+	require.NoError(t, d.enqueue(ctx, checkpointEvent(t, "f01000", dealcheckpoints.Accepted, false)))
+
+	// The first attempt fails and a retry is scheduled
+	d.deliverDue(ctx)
+	require.Len(t, srv.received(), 1)
+	dead, err := wdb.DeadLetters(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, dead)
+
+	// The second attempt fails and the delivery is moved to the dead letters
+	d.deliverDue(ctx)
+	require.Len(t, srv.received(), 2)
+	dead, err = wdb.DeadLetters(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	require.Equal(t, 2, dead[0].Attempts)
+	require.Contains(t, dead[0].LastError, "500")
+
+	// No more attempts are made
+	d.deliverDue(ctx)
+	require.Len(t, srv.received(), 2)
+
+	// Redelivering the dead letter succeeds once the target is working
+	srv.lk.Lock()
+	srv.status = http.StatusOK
+	srv.lk.Unlock()
+	require.NoError(t, wdb.Redeliver(ctx, dead[0].ID))
+	d.deliverDue(ctx)
+	reqs := srv.received()
+	require.Len(t, reqs, 3)
+	require.Equal(t, reqs[0].body, reqs[2].body)
+
+	dead, err = wdb.DeadLetters(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, dead)
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	d := &Dispatcher{cfg: Config{InitialBackoff: 1, MaxBackoff: 5}}
+	require.EqualValues(t, 1, d.backoff(1))
+	require.EqualValues(t, 2, d.backoff(2))
+	require.EqualValues(t, 4, d.backoff(3))
+	require.EqualValues(t, 5, d.backoff(4))
+	require.EqualValues(t, 5, d.backoff(10))
+}
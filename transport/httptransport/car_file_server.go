@@ -0,0 +1,149 @@
+package httptransport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/filecoin-project/boost/transport/types"
+	gostream "github.com/libp2p/go-libp2p-gostream"
+	"github.com/libp2p/go-libp2p/core/host"
+)
+
+// CarFileTokens looks up the CAR file to serve for an auth token
+type CarFileTokens interface {
+	// CarFilePath returns the path of the CAR file for the auth token, or
+	// ErrTokenNotFound if the token is not recognized
+	CarFilePath(ctx context.Context, authToken string) (string, error)
+}
+
+// CarFileServer serves CAR files from the local filesystem over HTTP and
+// over HTTP on libp2p. As with the Libp2pCarServer, each request must have
+// an auth token as the password in a basic auth Authorization header. The
+// token determines which CAR file is served.
+type CarFileServer struct {
+	h      host.Host
+	tokens CarFileTokens
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	servers []*http.Server
+}
+
+func NewCarFileServer(h host.Host, tokens CarFileTokens) *CarFileServer {
+	return &CarFileServer{
+		h:      h,
+		tokens: tokens,
+	}
+}
+
+// Start serves CAR files over HTTP on the listener, if it is not nil, and
+// over libp2p on the host, if the server has a host
+func (s *CarFileServer) Start(ctx context.Context, listener net.Listener) error {
+	s.ctx, s.cancel = context.WithCancel(ctx)
+
+	var listeners []net.Listener
+	if listener != nil {
+		listeners = append(listeners, listener)
+	}
+	if s.h != nil {
+		p2pListener, err := gostream.Listen(s.h, types.DataTransferProtocol)
+		if err != nil {
+			return fmt.Errorf("starting gostream listener: %w", err)
+		}
+		listeners = append(listeners, p2pListener)
+	}
+	if len(listeners) == 0 {
+		return errors.New("car file server has no http listener or libp2p host")
+	}
+
+	for _, l := range listeners {
+		handler := http.NewServeMux()
+		handler.HandleFunc("/", s.handler)
+		srv := &http.Server{
+			Handler: handler,
+			BaseContext: func(listener net.Listener) context.Context {
+				return s.ctx
+			},
+		}
+		s.servers = append(s.servers, srv)
+		go srv.Serve(l) //nolint:errcheck
+	}
+
+	return nil
+}
+
+func (s *CarFileServer) Stop() error {
+	s.cancel()
+
+	var err error
+	for _, srv := range s.servers {
+		if serr := srv.Close(); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// handler is called by the http library to handle an incoming HTTP request
+func (s *CarFileServer) handler(w http.ResponseWriter, r *http.Request) {
+	path, herr := s.checkAuth(r)
+	if herr != nil {
+		log.Infow("car file request failed", "code", herr.code, "err", herr.error, "remote-addr", r.RemoteAddr)
+		w.WriteHeader(herr.code)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Warnw("car file request failed: opening car file", "path", path, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		log.Warnw("car file request failed: getting car file info", "path", path, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	log.Infow("serving car file", "path", path, "method", r.Method, "range", r.Header.Get("Range"),
+		"remote-addr", r.RemoteAddr)
+
+	// Set the Content-Type header explicitly so that http.ServeContent doesn't
+	// try to do it implicitly. ServeContent handles range requests, so that
+	// the downloader can resume an interrupted transfer.
+	w.Header().Set("Content-Type", "application/vnd.ipld.car")
+	http.ServeContent(w, r, "", fi.ModTime(), f)
+}
+
+func (s *CarFileServer) checkAuth(r *http.Request) (string, *httpError) {
+	// Get auth token from Authorization header
+	_, authToken, ok := r.BasicAuth()
+	if !ok {
+		return "", &httpError{
+			error: errors.New("rejected request with no Authorization header"),
+			code:  http.StatusUnauthorized,
+		}
+	}
+
+	path, err := s.tokens.CarFilePath(r.Context(), authToken)
+	if errors.Is(err, ErrTokenNotFound) {
+		return "", &httpError{
+			error: errors.New("rejected unrecognized auth token"),
+			code:  http.StatusUnauthorized,
+		}
+	} else if err != nil {
+		return "", &httpError{
+			error: fmt.Errorf("getting car file for auth token: %w", err),
+			code:  http.StatusInternalServerError,
+		}
+	}
+
+	return path, nil
+}
@@ -0,0 +1,79 @@
+package httptransport
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/boost/transport/types"
+	"github.com/stretchr/testify/require"
+)
+
+type mockCarFileTokens map[string]string
+
+func (m mockCarFileTokens) CarFilePath(ctx context.Context, authToken string) (string, error) {
+	path, ok := m[authToken]
+	if !ok {
+		return "", ErrTokenNotFound
+	}
+	return path, nil
+}
+
+// TestCarFileServer verifies that a CAR file can be downloaded over HTTP and
+// over libp2p with a valid auth token, and not with an unrecognized token
+func TestCarFileServer(t *testing.T) {
+	ctx := context.Background()
+
+	rawSize := 2 * 1024 * 1024
+	st := newServerTest(t, rawSize)
+	carSize := len(st.carBytes)
+	carPath := filepath.Join(t.TempDir(), "data.car")
+	require.NoError(t, os.WriteFile(carPath, st.carBytes, 0644))
+
+	authToken, err := GenerateAuthToken()
+	require.NoError(t, err)
+	tokens := mockCarFileTokens{authToken: carPath}
+
+	clientHost, srvHost := setupLibp2pHosts(t)
+	defer srvHost.Close()
+	defer clientHost.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := NewCarFileServer(srvHost, tokens)
+	require.NoError(t, srv.Start(ctx, listener))
+	defer srv.Stop() //nolint:errcheck
+
+	newHttpRequest := func(token string) types.HttpRequest {
+		return types.HttpRequest{
+			URL: "http://" + listener.Addr().String(),
+			Headers: map[string]string{
+				"Authorization": BasicAuthHeader("", token),
+			},
+		}
+	}
+
+	// Download the CAR file over HTTP and over libp2p
+	for _, req := range []types.HttpRequest{newHttpRequest(authToken), newLibp2pHttpRequest(srvHost, authToken)} {
+		of := getTempFilePath(t)
+		th := executeTransfer(t, ctx, New(clientHost, newDealLogger(t, ctx)), carSize, req, of)
+
+		evts := waitForTransferComplete(th)
+		require.NotEmpty(t, evts)
+		require.NoError(t, evts[len(evts)-1].Error)
+		require.EqualValues(t, carSize, evts[len(evts)-1].NBytesReceived)
+		assertFileContents(t, of, st.carBytes)
+	}
+
+	// A download with an unrecognized token should fail
+	badToken, err := GenerateAuthToken()
+	require.NoError(t, err)
+	of := getTempFilePath(t)
+	th := executeTransfer(t, ctx, New(clientHost, newDealLogger(t, ctx)), carSize, newHttpRequest(badToken), of)
+	evts := waitForTransferComplete(th)
+	require.NotEmpty(t, evts)
+	require.Error(t, evts[len(evts)-1].Error)
+}
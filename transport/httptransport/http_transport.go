@@ -30,6 +30,9 @@ const (
 	maxBackOff           = 10 * time.Minute
 	factor               = 1.5
 	maxReconnectAttempts = 15
+
+	// The size of the segments that a parallel download is split into
+	defaultSegmentSize = 64 * 1024 * 1024
 )
 
 type httpError struct {
@@ -50,6 +53,20 @@ func BackOffRetryOpt(minBackoff, maxBackoff time.Duration, factor, maxReconnectA
 	}
 }
 
+// ParallelDownloadOpt sets the maximum number of connections used to
+// download a single deal's data, and the size of the segments the data is
+// split into. When maxConnections is more than one, or the deal has mirror
+// URLs, segments are downloaded in parallel. If segmentSize is zero the
+// default segment size is used.
+func ParallelDownloadOpt(maxConnections int, segmentSize int64) Option {
+	return func(h *httpTransport) {
+		h.maxConnections = maxConnections
+		if segmentSize > 0 {
+			h.segmentSize = segmentSize
+		}
+	}
+}
+
 type httpTransport struct {
 	libp2pHost   host.Host
 	libp2pClient *http.Client
@@ -59,6 +76,9 @@ type httpTransport struct {
 	backOffFactor        float64
 	maxReconnectAttempts float64
 
+	maxConnections int
+	segmentSize    int64
+
 	dl *logs.DealLogger
 }
 
@@ -69,6 +89,8 @@ func New(host host.Host, dealLogger *logs.DealLogger, opts ...Option) *httpTrans
 		maxBackoffWait:       maxBackOff,
 		backOffFactor:        factor,
 		maxReconnectAttempts: maxReconnectAttempts,
+		maxConnections:       1,
+		segmentSize:          defaultSegmentSize,
 		dl:                   dealLogger.Subsystem("http-transport"),
 	}
 	for _, o := range opts {
@@ -100,13 +122,22 @@ func (h *httpTransport) Execute(ctx context.Context, transportInfo []byte, dealI
 		return nil, errors.New("deal url is empty")
 	}
 
-	// parse request URL
+	// parse request URL and any mirror URLs
 	u, err := util.ParseUrl(tInfo.URL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse request url: %w", err)
 	}
 	tInfo.URL = u.Url
 
+	urls := []*util.TransportUrl{u}
+	for _, m := range tInfo.Mirrors {
+		mu, err := util.ParseUrl(m)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse mirror url: %w", err)
+		}
+		urls = append(urls, mu)
+	}
+
 	// check that the outputFile exists
 	fi, err := os.Stat(dealInfo.OutputFile)
 	if err != nil {
@@ -120,6 +151,13 @@ func (h *httpTransport) Execute(ctx context.Context, transportInfo []byte, dealI
 	}
 	h.dl.Infow(duuid, "existing file size", "file size", fileSize, "deal size", dealInfo.DealSize)
 
+	// The data is downloaded in segments if there are mirrors, if more than
+	// one connection is allowed, or if a previous download was in segments
+	// (so that it can be resumed)
+	_, err = os.Stat(segmentStatePath(dealInfo.OutputFile))
+	hasSegmentState := err == nil
+	parallel := len(urls) > 1 || h.maxConnections > 1 || hasSegmentState
+
 	// construct the transfer instance that will act as the transfer handler
 	tctx, cancel := context.WithCancel(ctx)
 	t := &transfer{
@@ -135,6 +173,8 @@ func (h *httpTransport) Execute(ctx context.Context, transportInfo []byte, dealI
 			Jitter: true,
 		},
 		maxReconnectAttempts: h.maxReconnectAttempts,
+		maxConnections:       h.maxConnections,
+		segmentSize:          h.segmentSize,
 		dl:                   h.dl,
 	}
 
@@ -148,37 +188,52 @@ func (h *httpTransport) Execute(ctx context.Context, transportInfo []byte, dealI
 		}
 	}
 
-	// If this is a libp2p URL
-	if u.Scheme == util.Libp2pScheme {
-		h.dl.Infow(duuid, "libp2p-http url", "url", tInfo.URL, "peer id", u.PeerID, "multiaddr", u.Multiaddr)
+	for _, u := range urls {
+		src := &downloadSource{url: u.Url, headers: tInfo.Headers}
 
-		// Use the libp2p client
-		t.client = h.libp2pClient
+		// Don't send credentials to a mirror on a different host
+		if !sameOrigin(urls[0], u) {
+			src.headers = withoutCredentials(tInfo.Headers)
+		}
 
-		// Add the peer's address to the peerstore so we can dial it
-		addrTtl := time.Hour
-		if deadline, ok := ctx.Deadline(); ok {
-			addrTtl = time.Until(deadline)
+		// If this is a libp2p URL
+		if u.Scheme == util.Libp2pScheme {
+			h.dl.Infow(duuid, "libp2p-http url", "url", u.Url, "peer id", u.PeerID, "multiaddr", u.Multiaddr)
+
+			// Use the libp2p client
+			src.client = h.libp2pClient
+
+			// Add the peer's address to the peerstore so we can dial it
+			addrTtl := time.Hour
+			if deadline, ok := ctx.Deadline(); ok {
+				addrTtl = time.Until(deadline)
+			}
+			h.libp2pHost.Peerstore().AddAddr(u.PeerID, u.Multiaddr, addrTtl)
+
+			// Protect the connection for the lifetime of the data transfer
+			tag := uuid.New().String()
+			peerID := u.PeerID
+			h.libp2pHost.ConnManager().Protect(peerID, tag)
+			cleanupFns = append(cleanupFns, func() {
+				h.libp2pHost.ConnManager().Unprotect(peerID, tag)
+			})
+		} else {
+			src.client = http.DefaultClient
+			h.dl.Infow(duuid, "http url", "url", u.Url)
 		}
-		h.libp2pHost.Peerstore().AddAddr(u.PeerID, u.Multiaddr, addrTtl)
-
-		// Protect the connection for the lifetime of the data transfer
-		tag := uuid.New().String()
-		h.libp2pHost.ConnManager().Protect(u.PeerID, tag)
-		cleanupFns = append(cleanupFns, func() {
-			h.libp2pHost.ConnManager().Unprotect(u.PeerID, tag)
-		})
-	} else {
-		t.client = http.DefaultClient
-		h.dl.Infow(duuid, "http url", "url", tInfo.URL)
+
+		t.sources = append(t.sources, src)
 	}
+	t.client = t.sources[0].client
 
 	// is the transfer already complete ? we check this by comparing the number of bytes
-	// in the output file with the deal size.
-	if fileSize == dealInfo.DealSize {
+	// in the output file with the deal size. For a download in segments the
+	// file may have holes in it, so this check is made against the segment
+	// state when the transfer is executed.
+	if fileSize == dealInfo.DealSize && !hasSegmentState {
 		defer cleanup()
 
-		t.emitEvent(types.TransportEvent{NBytesReceived: fileSize})
+		t.emitEvent(types.TransportEvent{NBytesReceived: fileSize, NBytesContiguous: fileSize})
 		h.dl.Infow(duuid, "file size is already equal to deal size, returning")
 		return t, nil
 	}
@@ -189,7 +244,17 @@ func (h *httpTransport) Execute(ctx context.Context, transportInfo []byte, dealI
 		defer t.wg.Done()
 		defer cleanup()
 
-		if err := t.execute(tctx); err != nil {
+		var err error
+		if parallel {
+			err = t.executeParallel(tctx)
+			if errors.Is(err, errRangeNotSupported) {
+				h.dl.Infow(duuid, "falling back to sequential http transfer", "err", err)
+				err = t.executeSequentialFallback(tctx)
+			}
+		} else {
+			err = t.execute(tctx)
+		}
+		if err != nil {
 			t.emitEvent(types.TransportEvent{Error: err})
 		}
 	}()
@@ -216,6 +281,21 @@ type transfer struct {
 
 	client *http.Client
 	dl     *logs.DealLogger
+
+	// The URL and any mirror URLs, for downloads in segments
+	sources        []*downloadSource
+	maxConnections int
+	segmentSize    int64
+	// Serializes events fired by parallel segment downloads
+	eventLk sync.Mutex
+}
+
+// downloadSource is a URL that the deal data can be downloaded from, and the
+// client and headers to download it with
+type downloadSource struct {
+	url     string
+	client  *http.Client
+	headers map[string]string
 }
 
 func (t *transfer) execute(ctx context.Context) error {
@@ -357,6 +437,11 @@ func (t *transfer) doHttp(ctx context.Context, req *http.Request, dst io.Writer,
 
 		// if we read more than zero bytes, write whatever read.
 		if nr > 0 {
+			if lim := t.dealInfo.RateLimiter; lim != nil {
+				if err := lim.WaitN(ctx, nr); err != nil {
+					return &httpError{error: err}
+				}
+			}
 			nw, writeErr := dst.Write(buf[0:nr])
 
 			// if the number of read and written bytes don't match -> something has gone wrong, abort the http req.
@@ -370,7 +455,7 @@ func (t *transfer) doHttp(ctx context.Context, req *http.Request, dst io.Writer,
 			t.nBytesReceived = t.nBytesReceived + int64(nw)
 
 			// emit event updating the number of bytes received
-			t.emitEvent(types.TransportEvent{NBytesReceived: t.nBytesReceived})
+			t.emitEvent(types.TransportEvent{NBytesReceived: t.nBytesReceived, NBytesContiguous: t.nBytesReceived})
 		}
 		// the http stream we're reading from has sent us an EOF, nothing to do here.
 		if readErr == io.EOF {
@@ -0,0 +1,540 @@
+package httptransport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/filecoin-project/boost/transport/httptransport/util"
+	"github.com/filecoin-project/boost/transport/types"
+	"github.com/jpillora/backoff"
+)
+
+// The interval at which the progress of each segment of a parallel download
+// is saved to the segment state file
+const segmentStateSaveInterval = 5 * time.Second
+
+// errRangeNotSupported is returned when a server responds to a request for a
+// byte range that doesn't start at zero with the whole file
+var errRangeNotSupported = errors.New("server does not support http range requests")
+
+// segmentStatePath is the path of the file that keeps the progress of each
+// segment of a parallel download, so that the download can be resumed after
+// a restart
+func segmentStatePath(outputFile string) string {
+	return outputFile + ".segments"
+}
+
+// segment is a byte range of the deal data
+type segment struct {
+	// The offset of the first byte of the segment
+	Start int64
+	// The offset after the last byte of the segment
+	End int64
+	// The number of bytes of the segment that have been written to the
+	// output file
+	Received int64
+}
+
+func (s *segment) complete() bool {
+	return s.Start+s.Received == s.End
+}
+
+// segmentState is the progress of a parallel download
+type segmentState struct {
+	lk       sync.Mutex
+	DealSize int64
+	Segments []*segment
+}
+
+// newSegmentState splits the deal data into segments. The first received
+// bytes are marked as already received, so that a download that was
+// started from a single URL can be continued in parallel.
+func newSegmentState(dealSize int64, segmentSize int64, received int64) *segmentState {
+	if segmentSize <= 0 {
+		segmentSize = defaultSegmentSize
+	}
+	st := &segmentState{DealSize: dealSize}
+	for start := int64(0); start < dealSize; start += segmentSize {
+		end := start + segmentSize
+		if end > dealSize {
+			end = dealSize
+		}
+		seg := &segment{Start: start, End: end}
+		if received > start {
+			seg.Received = received - start
+			if seg.Received > end-start {
+				seg.Received = end - start
+			}
+		}
+		st.Segments = append(st.Segments, seg)
+	}
+	return st
+}
+
+// loadSegmentState reads the segment state from the state file. It returns
+// nil if there is no valid state for the output file.
+func loadSegmentState(path string, dealSize int64, fileSize int64) (*segmentState, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading segment state: %w", err)
+	}
+
+	var st segmentState
+	if err := json.Unmarshal(bz, &st); err != nil {
+		return nil, nil
+	}
+	if st.DealSize != dealSize {
+		return nil, nil
+	}
+	// If the output file doesn't have all the bytes the state says were
+	// received (eg because the output file was deleted), the state is stale
+	for _, seg := range st.Segments {
+		if seg.Received > 0 && seg.Start+seg.Received > fileSize {
+			return nil, nil
+		}
+	}
+	return &st, nil
+}
+
+func (st *segmentState) save(path string) error {
+	st.lk.Lock()
+	bz, err := json.Marshal(st)
+	st.lk.Unlock()
+	if err != nil {
+		return fmt.Errorf("marshalling segment state: %w", err)
+	}
+
+	// Write to a temp file and rename it so that the state file is never
+	// partially written
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, bz, 0644); err != nil {
+		return fmt.Errorf("writing segment state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("renaming segment state file: %w", err)
+	}
+	return nil
+}
+
+// received returns the total number of bytes received
+func (st *segmentState) received() int64 {
+	st.lk.Lock()
+	defer st.lk.Unlock()
+
+	var total int64
+	for _, seg := range st.Segments {
+		total += seg.Received
+	}
+	return total
+}
+
+// contiguous returns the number of bytes from the start of the deal data up
+// to the first byte that has not been received
+func (st *segmentState) contiguous() int64 {
+	st.lk.Lock()
+	defer st.lk.Unlock()
+
+	var total int64
+	for _, seg := range st.Segments {
+		total += seg.Received
+		if !seg.complete() {
+			break
+		}
+	}
+	return total
+}
+
+// executeParallel downloads the deal data in segments, which are fetched in
+// parallel from the URL and any mirror URLs. Each segment is written at its
+// offset in the output file, and the progress of each segment is saved so
+// that the download can be resumed after a restart.
+func (t *transfer) executeParallel(ctx context.Context) error {
+	duuid := t.dealInfo.DealUuid
+	statePath := segmentStatePath(t.dealInfo.OutputFile)
+
+	fi, err := os.Stat(t.dealInfo.OutputFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat output file: %w", err)
+	}
+	st, err := loadSegmentState(statePath, t.dealInfo.DealSize, fi.Size())
+	if err != nil {
+		return err
+	}
+	if st == nil {
+		// If a previous download in segments can't be resumed, the file may
+		// have holes in it, so start again from the beginning
+		received := fi.Size()
+		if _, serr := os.Stat(statePath); serr == nil {
+			t.dl.Infow(duuid, "discarding stale segment state", "path", statePath)
+			if err := os.Truncate(t.dealInfo.OutputFile, 0); err != nil {
+				return fmt.Errorf("failed to truncate output file: %w", err)
+			}
+			received = 0
+		}
+		st = newSegmentState(t.dealInfo.DealSize, t.segmentSize, received)
+		if err := st.save(statePath); err != nil {
+			return err
+		}
+	}
+
+	queue := make(chan *segment, len(st.Segments))
+	var pending int64
+	for _, seg := range st.Segments {
+		if !seg.complete() {
+			queue <- seg
+			pending++
+		}
+	}
+
+	t.nBytesReceived = st.received()
+	t.dl.Infow(duuid, "starting parallel http transfer", "segments", len(st.Segments), "pending segments", pending,
+		"sources", len(t.sources), "received", t.nBytesReceived, "deal size", t.dealInfo.DealSize)
+	t.emitEvent(types.TransportEvent{NBytesReceived: t.nBytesReceived, NBytesContiguous: st.contiguous()})
+
+	if pending > 0 {
+		if err := t.downloadSegments(ctx, st, statePath, queue, pending); err != nil {
+			// The download can only be resumed if it was paused by a
+			// shutdown. Otherwise the deal fails and the state is removed.
+			if !errors.Is(err, context.Canceled) {
+				_ = os.Remove(statePath)
+			}
+			return err
+		}
+	}
+
+	if t.nBytesReceived != t.dealInfo.DealSize {
+		return fmt.Errorf("mismatch in dealSize vs received bytes, dealSize=%d, received=%d", t.dealInfo.DealSize, t.nBytesReceived)
+	}
+	fi, err = os.Stat(t.dealInfo.OutputFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat output file: %w", err)
+	}
+	if fi.Size() != t.dealInfo.DealSize {
+		return fmt.Errorf("mismtach in output file size vs received bytes, fileSize=%d, receivedBytes=%d", fi.Size(), t.nBytesReceived)
+	}
+	if err := os.Remove(statePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing segment state: %w", err)
+	}
+
+	t.dl.Infow(duuid, "parallel http transfer finished successfully", "nBytesReceived", t.nBytesReceived)
+	return nil
+}
+
+// downloadSegments starts a worker for each connection. Each worker
+// downloads segments from its source until all segments are complete. If a
+// worker's source fails, its segment is put back on the queue for the other
+// workers, and the download only fails if all workers fail.
+func (t *transfer) downloadSegments(ctx context.Context, st *segmentState, statePath string, queue chan *segment, pending int64) error {
+	duuid := t.dealInfo.DealUuid
+
+	of, err := os.OpenFile(t.dealInfo.OutputFile, os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	defer of.Close()
+
+	workers := t.maxConnections
+	if workers < len(t.sources) {
+		workers = len(t.sources)
+	}
+	if int64(workers) > pending {
+		workers = int(pending)
+	}
+
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Save the segment state periodically while the download is in progress
+	saveDone := make(chan struct{})
+	go func() {
+		defer close(saveDone)
+		ticker := time.NewTicker(segmentStateSaveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-wctx.Done():
+				return
+			case <-ticker.C:
+				if err := st.save(statePath); err != nil {
+					t.dl.Warnw(duuid, "saving segment state", "err", err)
+				}
+			}
+		}
+	}()
+
+	done := make(chan struct{})
+	live := int32(workers)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		src := t.sources[i%len(t.sources)]
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			errs[i] = t.segmentWorker(wctx, src, of, st, queue, done, &pending)
+			if errs[i] != nil {
+				t.dl.Infow(duuid, "parallel http transfer connection stopped", "url", src.url, "err", errs[i])
+				// If all the workers have failed, stop the download
+				if atomic.AddInt32(&live, -1) == 0 {
+					cancel()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	cancel()
+	<-saveDone
+
+	if err := st.save(statePath); err != nil {
+		t.dl.Warnw(duuid, "saving segment state", "err", err)
+	}
+
+	if atomic.LoadInt64(&pending) == 0 {
+		return nil
+	}
+	if ctx.Err() != nil {
+		t.dl.LogError(duuid, "terminating parallel http transfer: context cancelled or deadline exceeded", ctx.Err())
+		return fmt.Errorf("transfer context canceled err: %w", ctx.Err())
+	}
+	// If a source doesn't support range requests the caller can fall back
+	// to a sequential download, so return that error in preference to others
+	for _, err := range errs {
+		if errors.Is(err, errRangeNotSupported) {
+			return fmt.Errorf("could not finish parallel transfer: %w", err)
+		}
+	}
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return fmt.Errorf("could not finish parallel transfer: %w", err)
+		}
+	}
+	return errors.New("could not finish parallel transfer")
+}
+
+// executeSequentialFallback downloads the deal data sequentially from the
+// primary URL, after a parallel download failed because a server doesn't
+// support range requests. The output file may have holes in it, so the
+// download starts again from the beginning.
+func (t *transfer) executeSequentialFallback(ctx context.Context) error {
+	if err := os.Remove(segmentStatePath(t.dealInfo.OutputFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing segment state: %w", err)
+	}
+	if err := os.Truncate(t.dealInfo.OutputFile, 0); err != nil {
+		return fmt.Errorf("failed to truncate output file: %w", err)
+	}
+
+	t.client = t.sources[0].client
+	t.nBytesReceived = 0
+	t.emitEvent(types.TransportEvent{NBytesReceived: 0, NBytesContiguous: 0})
+	return t.execute(ctx)
+}
+
+// credentialHeaders are not sent to a mirror on a different host to the
+// primary URL (the same headers that net/http strips on a redirect to
+// another host, and Proxy-Authorization)
+var credentialHeaders = map[string]struct{}{
+	"Authorization":       {},
+	"Proxy-Authorization": {},
+	"Www-Authenticate":    {},
+	"Cookie":              {},
+	"Cookie2":             {},
+}
+
+func withoutCredentials(headers map[string]string) map[string]string {
+	filtered := make(map[string]string, len(headers))
+	for name, val := range headers {
+		if _, ok := credentialHeaders[http.CanonicalHeaderKey(name)]; !ok {
+			filtered[name] = val
+		}
+	}
+	return filtered
+}
+
+// sameOrigin returns true if both URLs have the same scheme and host, or
+// are libp2p URLs for the same peer
+func sameOrigin(a *util.TransportUrl, b *util.TransportUrl) bool {
+	if a.Scheme != b.Scheme {
+		return false
+	}
+	if a.Scheme == util.Libp2pScheme {
+		return a.PeerID == b.PeerID
+	}
+	au, err := url.Parse(a.Url)
+	if err != nil {
+		return false
+	}
+	bu, err := url.Parse(b.Url)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(au.Host, bu.Host)
+}
+
+// segmentWorker downloads segments from the queue until all segments are
+// complete, or the source fails
+func (t *transfer) segmentWorker(ctx context.Context, src *downloadSource, of *os.File, st *segmentState, queue chan *segment, done chan struct{}, pending *int64) error {
+	duuid := t.dealInfo.DealUuid
+	bo := &backoff.Backoff{
+		Min:    t.backoff.Min,
+		Max:    t.backoff.Max,
+		Factor: t.backoff.Factor,
+		Jitter: true,
+	}
+
+	for {
+		var seg *segment
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-done:
+			return nil
+		case seg = <-queue:
+		}
+
+		st.lk.Lock()
+		before := seg.Received
+		st.lk.Unlock()
+
+		reqErr := t.downloadSegment(ctx, src, of, st, seg)
+		if reqErr == nil {
+			if atomic.AddInt64(pending, -1) == 0 {
+				close(done)
+			}
+			continue
+		}
+
+		// Put the segment back for this or another worker to download
+		queue <- seg
+
+		t.dl.Infow(duuid, "http segment request error", "url", src.url, "http code", reqErr.code, "err", reqErr.Error())
+
+		// A 4xx error means there is a problem with the request (eg 401
+		// Unauthorized), so don't retry with this source
+		if reqErr.code/100 == 4 {
+			return fmt.Errorf("received %d response from server: %w", reqErr.code, reqErr.error)
+		}
+		if errors.Is(reqErr.error, errRangeNotSupported) {
+			return reqErr.error
+		}
+		if errors.Is(reqErr.error, context.Canceled) || errors.Is(reqErr.error, context.DeadlineExceeded) {
+			return reqErr.error
+		}
+
+		// If some data was transferred, reset the back-off count to zero
+		st.lk.Lock()
+		progressed := seg.Received > before
+		st.lk.Unlock()
+		if progressed {
+			bo.Reset()
+		}
+
+		nAttempts := bo.Attempt() + 1
+		if nAttempts >= t.maxReconnectAttempts {
+			return fmt.Errorf("exhausted %.0f attempts, lastErr: %w", t.maxReconnectAttempts, reqErr.error)
+		}
+		duration := bo.Duration()
+		t.dl.Infow(duuid, "backing off before retrying http segment request", "url", src.url,
+			"backoff time", duration.String(), "attempts", nAttempts)
+		bt := time.NewTimer(duration)
+		select {
+		case <-bt.C:
+		case <-ctx.Done():
+			bt.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// downloadSegment requests the rest of the segment from the source and
+// writes it at the segment's offset in the output file
+func (t *transfer) downloadSegment(ctx context.Context, src *downloadSource, of *os.File, st *segmentState, seg *segment) *httpError {
+	st.lk.Lock()
+	offset := seg.Start + seg.Received
+	st.lk.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", src.url, nil)
+	if err != nil {
+		return &httpError{error: fmt.Errorf("failed to create http req: %w", err)}
+	}
+	for name, val := range src.headers {
+		req.Header.Set(name, val)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, seg.End-1))
+
+	resp, err := src.client.Do(req)
+	if err != nil {
+		return &httpError{error: fmt.Errorf("failed to send http req: %w", err)}
+	}
+	defer resp.Body.Close() // nolint
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// The server ignored the range and sent the whole file, which can
+		// only be used if the range starts at the beginning of the file
+		if offset != 0 {
+			return &httpError{error: errRangeNotSupported}
+		}
+	default:
+		return &httpError{
+			error: fmt.Errorf("http req failed: code: %d, status: %s", resp.StatusCode, resp.Status),
+			code:  resp.StatusCode,
+		}
+	}
+
+	buf := make([]byte, readBufferSize)
+	limitR := io.LimitReader(resp.Body, seg.End-offset)
+	for {
+		if ctx.Err() != nil {
+			return &httpError{error: ctx.Err()}
+		}
+		nr, readErr := limitR.Read(buf)
+
+		if nr > 0 {
+			if lim := t.dealInfo.RateLimiter; lim != nil {
+				if err := lim.WaitN(ctx, nr); err != nil {
+					return &httpError{error: err}
+				}
+			}
+			nw, writeErr := of.WriteAt(buf[0:nr], offset)
+			if writeErr != nil {
+				return &httpError{error: fmt.Errorf("failed to write to output file: %w", writeErr)}
+			}
+			offset += int64(nw)
+
+			st.lk.Lock()
+			seg.Received += int64(nw)
+			st.lk.Unlock()
+
+			// emit event updating the total number of bytes received
+			t.eventLk.Lock()
+			t.nBytesReceived += int64(nw)
+			t.emitEvent(types.TransportEvent{NBytesReceived: t.nBytesReceived, NBytesContiguous: st.contiguous()})
+			t.eventLk.Unlock()
+		}
+		if readErr == io.EOF {
+			if offset != seg.End {
+				return &httpError{error: fmt.Errorf("http server sent EOF after %d of %d bytes of segment",
+					offset-seg.Start, seg.End-seg.Start)}
+			}
+			return nil
+		}
+		if readErr != nil {
+			return &httpError{error: fmt.Errorf("error reading from http response stream: %w", readErr)}
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package httptransport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/transport/types"
+	"github.com/stretchr/testify/require"
+)
+
+// newRangeServer serves the CAR file with support for range requests. The
+// allow function is called with the range of each request, and if it
+// returns false the server responds with the given status code.
+func newRangeServer(t *testing.T, carBytes []byte, requests *int32, allow func(rng string) (bool, int)) *httptest.Server {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if allow != nil {
+			if ok, code := allow(r.Header.Get("Range")); !ok {
+				w.WriteHeader(code)
+				return
+			}
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(carBytes))
+	}))
+	t.Cleanup(svr.Close)
+	return svr
+}
+
+func TestParallelTransferMirrors(t *testing.T) {
+	ctx := context.Background()
+	st := newServerTest(t, 2*1024*1024)
+	carSize := len(st.carBytes)
+
+	var reqs1, reqs2, reqsDead int32
+	svr1 := newRangeServer(t, st.carBytes, &reqs1, nil)
+	svr2 := newRangeServer(t, st.carBytes, &reqs2, nil)
+	// A mirror that rejects every request should not stop the download
+	// from the other mirrors
+	dead := newRangeServer(t, st.carBytes, &reqsDead, func(string) (bool, int) {
+		return false, http.StatusUnauthorized
+	})
+
+	req := types.HttpRequest{URL: svr1.URL, Mirrors: []string{svr2.URL, dead.URL}}
+	of := getTempFilePath(t)
+	ht := New(nil, newDealLogger(t, ctx),
+		BackOffRetryOpt(50*time.Millisecond, 100*time.Millisecond, 2, 1000),
+		ParallelDownloadOpt(1, 256*1024))
+	th := executeTransfer(t, ctx, ht, carSize, req, of)
+
+	evts := waitForTransferComplete(th)
+	require.NotEmpty(t, evts)
+	require.NoError(t, evts[len(evts)-1].Error)
+	require.EqualValues(t, carSize, evts[len(evts)-1].NBytesReceived)
+	assertFileContents(t, of, st.carBytes)
+
+	// Segments were downloaded from each mirror, and the state file was
+	// removed once the download completed
+	require.NotZero(t, atomic.LoadInt32(&reqs1))
+	require.NotZero(t, atomic.LoadInt32(&reqs2))
+	require.NotZero(t, atomic.LoadInt32(&reqsDead))
+	_, err := os.Stat(segmentStatePath(of))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestParallelTransferResume(t *testing.T) {
+	ctx := context.Background()
+	st := newServerTest(t, 2*1024*1024)
+	carSize := len(st.carBytes)
+	segmentSize := int64(256 * 1024)
+
+	// Only serve the first half of the segments, so that the download can't
+	// complete
+	half := int64(carSize) / 2
+	var reqs int32
+	svr := newRangeServer(t, st.carBytes, &reqs, func(rng string) (bool, int) {
+		var start, end int64
+		_, err := fmt.Sscanf(rng, "bytes=%d-%d", &start, &end)
+		require.NoError(t, err)
+		return start < half, http.StatusServiceUnavailable
+	})
+
+	newTransport := func() *httpTransport {
+		return New(nil, newDealLogger(t, ctx),
+			BackOffRetryOpt(50*time.Millisecond, 100*time.Millisecond, 2, 1000),
+			ParallelDownloadOpt(4, segmentSize))
+	}
+
+	// Stop the transfer once the first half of the segments have been
+	// downloaded
+	req := types.HttpRequest{URL: svr.URL}
+	of := getTempFilePath(t)
+	tctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	th := executeTransfer(t, tctx, newTransport(), carSize, req, of)
+	var received int64
+	for evt := range th.Sub() {
+		// The last event is the cancellation error
+		if evt.Error != nil {
+			continue
+		}
+		received = evt.NBytesReceived
+		if received >= half {
+			cancel()
+		}
+	}
+	th.Close()
+	require.GreaterOrEqual(t, received, half)
+	require.Less(t, received, int64(carSize))
+
+	// The progress of each segment was saved
+	_, err := os.Stat(segmentStatePath(of))
+	require.NoError(t, err)
+
+	// Resume the transfer with a server that serves all the segments
+	var reqs2 int32
+	svr2 := newRangeServer(t, st.carBytes, &reqs2, nil)
+	th = executeTransfer(t, ctx, newTransport(), carSize, types.HttpRequest{URL: svr2.URL}, of)
+	evts := waitForTransferComplete(th)
+	require.NotEmpty(t, evts)
+	require.GreaterOrEqual(t, evts[0].NBytesReceived, half)
+	require.NoError(t, evts[len(evts)-1].Error)
+	require.EqualValues(t, carSize, evts[len(evts)-1].NBytesReceived)
+	assertFileContents(t, of, st.carBytes)
+
+	// Only the segments that were not complete were downloaded again
+	segments := (int64(carSize) + segmentSize - 1) / segmentSize
+	require.Less(t, int64(atomic.LoadInt32(&reqs2)), segments)
+}
+
+func TestParallelTransferRangeNotSupported(t *testing.T) {
+	ctx := context.Background()
+	st := newServerTest(t, 2*1024*1024)
+	carSize := len(st.carBytes)
+
+	// A server that ignores the range header and always sends the whole file
+	var reqs int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&reqs, 1)
+		_, _ = w.Write(st.carBytes)
+	}))
+	t.Cleanup(svr.Close)
+
+	// The parallel download should fall back to a sequential download
+	req := types.HttpRequest{URL: svr.URL}
+	of := getTempFilePath(t)
+	ht := New(nil, newDealLogger(t, ctx),
+		BackOffRetryOpt(50*time.Millisecond, 100*time.Millisecond, 2, 1000),
+		ParallelDownloadOpt(4, 256*1024))
+	th := executeTransfer(t, ctx, ht, carSize, req, of)
+
+	evts := waitForTransferComplete(th)
+	require.NotEmpty(t, evts)
+	require.NoError(t, evts[len(evts)-1].Error)
+	require.EqualValues(t, carSize, evts[len(evts)-1].NBytesReceived)
+	assertFileContents(t, of, st.carBytes)
+
+	_, err := os.Stat(segmentStatePath(of))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestParallelTransferMirrorCredentials(t *testing.T) {
+	ctx := context.Background()
+	st := newServerTest(t, 2*1024*1024)
+	carSize := len(st.carBytes)
+
+	// Record the headers of each request
+	type reqHeaders struct {
+		auth   atomic.Value
+		cookie atomic.Value
+		other  atomic.Value
+	}
+	newServer := func(hdrs *reqHeaders) *httptest.Server {
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hdrs.auth.Store(r.Header.Get("Authorization"))
+			hdrs.cookie.Store(r.Header.Get("Cookie"))
+			hdrs.other.Store(r.Header.Get("X-Other"))
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(st.carBytes))
+		}))
+		t.Cleanup(svr.Close)
+		return svr
+	}
+	var primaryHdrs, mirrorHdrs reqHeaders
+	primary := newServer(&primaryHdrs)
+	mirror := newServer(&mirrorHdrs)
+
+	req := types.HttpRequest{
+		URL:     primary.URL,
+		Mirrors: []string{mirror.URL},
+		Headers: map[string]string{"authorization": "secret", "Cookie": "session", "X-Other": "val"},
+	}
+	of := getTempFilePath(t)
+	ht := New(nil, newDealLogger(t, ctx),
+		BackOffRetryOpt(50*time.Millisecond, 100*time.Millisecond, 2, 1000),
+		ParallelDownloadOpt(1, 256*1024))
+	th := executeTransfer(t, ctx, ht, carSize, req, of)
+
+	evts := waitForTransferComplete(th)
+	require.NotEmpty(t, evts)
+	require.NoError(t, evts[len(evts)-1].Error)
+	assertFileContents(t, of, st.carBytes)
+
+	// The credentials are only sent to the primary URL's host (the test
+	// servers listen on different ports)
+	require.Equal(t, "secret", primaryHdrs.auth.Load())
+	require.Equal(t, "session", primaryHdrs.cookie.Load())
+	require.Equal(t, "val", primaryHdrs.other.Load())
+	require.Equal(t, "", mirrorHdrs.auth.Load())
+	require.Equal(t, "", mirrorHdrs.cookie.Load())
+	require.Equal(t, "val", mirrorHdrs.other.Load())
+}
+
+func TestSegmentStateContiguous(t *testing.T) {
+	st := newSegmentState(100, 30, 45)
+	require.EqualValues(t, 45, st.received())
+	require.EqualValues(t, 45, st.contiguous())
+
+	// Receiving bytes after a gap doesn't change the number of contiguous
+	// bytes
+	st.Segments[2].Received = 10
+	require.EqualValues(t, 55, st.received())
+	require.EqualValues(t, 45, st.contiguous())
+
+	// Filling the gap makes all the bytes up to the next gap contiguous
+	st.Segments[1].Received = 30
+	require.EqualValues(t, 70, st.received())
+	require.EqualValues(t, 70, st.contiguous())
+}
@@ -0,0 +1,95 @@
+package httptransport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/filecoin-project/boost/transport"
+	"github.com/filecoin-project/boost/transport/httptransport/util"
+	"github.com/filecoin-project/boost/transport/types"
+	"github.com/filecoin-project/go-address"
+	"github.com/ipni/go-libipni/maurl"
+)
+
+var _ transport.Plugin = (*httpTransport)(nil)
+
+func parseParams(params []byte) (*types.HttpRequest, error) {
+	// de-serialize transport opaque token
+	tInfo := &types.HttpRequest{}
+	if err := json.Unmarshal(params, tInfo); err != nil {
+		return nil, fmt.Errorf("failed to de-serialize transport params bytes '%s': %w", string(params), err)
+	}
+	return tInfo, nil
+}
+
+// ValidateParams checks that the deal URL and any mirror URLs can be parsed
+func (h *httpTransport) ValidateParams(params []byte, _ address.Address) error {
+	tInfo, err := parseParams(params)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range append([]string{tInfo.URL}, tInfo.Mirrors...) {
+		if _, err := util.ParseUrl(u); err != nil {
+			return fmt.Errorf("cannot parse url '%s': %w", u, err)
+		}
+	}
+	return nil
+}
+
+// Host returns the host of the deal URL. If the URL is a libp2p URL, the
+// host is the IP address and port of the multiaddr.
+func (h *httpTransport) Host(params []byte) (string, error) {
+	tInfo, err := parseParams(params)
+	if err != nil {
+		return "", err
+	}
+
+	// Parse http / multiaddr url
+	u, err := util.ParseUrl(tInfo.URL)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse url '%s': %w", tInfo.URL, err)
+	}
+
+	// If the url is in libp2p format
+	if u.Scheme == util.Libp2pScheme {
+		// Get the host from the multiaddr
+		mahttp, err := maurl.ToURL(u.Multiaddr)
+		if err != nil {
+			return "", err
+		}
+		return mahttp.Host, nil
+	}
+
+	// Otherwise parse as an http url
+	httpUrl, err := url.Parse(u.Url)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse url '%s' from '%s': %w", u.Url, tInfo.URL, err)
+	}
+
+	return httpUrl.Host, nil
+}
+
+// RedactParams returns the deal URL and any mirror URLs
+func (h *httpTransport) RedactParams(params []byte) (string, error) {
+	tInfo, err := parseParams(params)
+	if err != nil {
+		return "", err
+	}
+
+	// Just extract the URL, not the headers, because the headers may contain
+	// sensitive information that we don't want to end up in a log file
+	// somewhere (eg Authorization header)
+	redacted := map[string]interface{}{
+		"URL": tInfo.URL,
+	}
+	if len(tInfo.Mirrors) > 0 {
+		redacted["Mirrors"] = tInfo.Mirrors
+	}
+	bz, err := json.Marshal(redacted)
+	if err != nil {
+		return "", fmt.Errorf("marshalling transfer params json: %w", err)
+	}
+	return string(bz), nil
+}
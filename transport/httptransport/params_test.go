@@ -0,0 +1,72 @@
+package httptransport
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/filecoin-project/boost/transport/types"
+	"github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHost(t *testing.T) {
+	testCases := []struct {
+		name     string
+		url      string
+		expected string
+	}{{
+		name:     "http",
+		url:      "http://foo.bar:1234",
+		expected: "foo.bar:1234",
+	}, {
+		name:     "libp2p http",
+		url:      "libp2p:///ip4/1.2.3.4/tcp/5678/p2p/Qma9T5YraSnpRDZqRR4krcSJabThc8nwZuJV3LercPHufi",
+		expected: "1.2.3.4:5678",
+	}, {
+		name:     "libp2p quic",
+		url:      "libp2p:///ip4/1.2.3.4/udp/5678/quic/p2p/Qma9T5YraSnpRDZqRR4krcSJabThc8nwZuJV3LercPHufi",
+		expected: "1.2.3.4:5678",
+	}}
+
+	ht := &httpTransport{}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := types.HttpRequest{URL: tc.url}
+			res, err := json.Marshal(req)
+			require.NoError(t, err)
+
+			require.NoError(t, ht.ValidateParams(res, address.Undef))
+			h, err := ht.Host(res)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expected, h)
+		})
+	}
+}
+
+func TestValidateParams(t *testing.T) {
+	ht := &httpTransport{}
+	require.Error(t, ht.ValidateParams([]byte("not json"), address.Undef))
+
+	bz, err := json.Marshal(types.HttpRequest{})
+	require.NoError(t, err)
+	require.Error(t, ht.ValidateParams(bz, address.Undef))
+
+	bz, err = json.Marshal(types.HttpRequest{URL: "http://foo.bar", Mirrors: []string{"foo.bar/data.car"}})
+	require.NoError(t, err)
+	require.Error(t, ht.ValidateParams(bz, address.Undef))
+}
+
+func TestRedactParams(t *testing.T) {
+	bz, err := json.Marshal(types.HttpRequest{
+		URL:     "http://foo.bar/data.car",
+		Headers: map[string]string{"Authorization": "secret"},
+		Mirrors: []string{"http://mirror.foo.bar/data.car"},
+	})
+	require.NoError(t, err)
+
+	ht := &httpTransport{}
+	redacted, err := ht.RedactParams(bz)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"URL":"http://foo.bar/data.car","Mirrors":["http://mirror.foo.bar/data.car"]}`, redacted)
+}
@@ -2,11 +2,9 @@ package transport
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 
-	smtypes "github.com/filecoin-project/boost/storagemarket/types"
 	"github.com/filecoin-project/boost/transport/types"
+	"github.com/filecoin-project/go-address"
 )
 
 //go:generate go run github.com/golang/mock/mockgen -destination=mocks/mock_transport.go -package=mocks . Transport,Handler
@@ -20,25 +18,20 @@ type Handler interface {
 	Close()
 }
 
-func TransferParamsAsJson(transfer smtypes.Transfer) (string, error) {
-	if transfer.Type != "http" && transfer.Type != "libp2p" {
-		return "", fmt.Errorf("cannot parse params for unrecognized transfer type '%s'", transfer.Type)
-	}
-
-	// de-serialize transport opaque token
-	tInfo := &types.HttpRequest{}
-	if err := json.Unmarshal(transfer.Params, tInfo); err != nil {
-		return "", fmt.Errorf("failed to de-serialize transport params bytes '%s': %w", string(transfer.Params), err)
-	}
-
-	// Just extract the URL, not the headers, because the headers may contain
-	// sensitive information that we don't want to end up in a log file
-	// somewhere (eg Authorization header)
-	bz, err := json.Marshal(map[string]string{
-		"URL": tInfo.URL,
-	})
-	if err != nil {
-		return "", fmt.Errorf("marshalling transfer params json: %w", err)
-	}
-	return string(bz), nil
+// Plugin is a Transport that also knows how to interpret the transfer
+// parameters for the transfer types it is registered for
+type Plugin interface {
+	Transport
+
+	// ValidateParams checks that the transfer parameters are well-formed,
+	// and that the deal's client is allowed to use them
+	ValidateParams(params []byte, client address.Address) error
+	// Host returns the host that the data is downloaded from. It is used to
+	// limit the number of concurrent transfers from each host, and by
+	// deal filter rules.
+	Host(params []byte) (string, error)
+	// RedactParams returns the transfer parameters as JSON, without any
+	// sensitive information (eg an Authorization header), so that they can
+	// be logged and displayed
+	RedactParams(params []byte) (string, error)
 }
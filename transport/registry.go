@@ -0,0 +1,86 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	smtypes "github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/transport/types"
+	"github.com/filecoin-project/go-address"
+)
+
+// Registry has the Plugin for each transfer type that the provider supports
+// (eg "http", "libp2p", "s3"). New transfer types can be supported by
+// registering a Plugin for the type.
+// Plugins must be registered before the registry is used.
+type Registry struct {
+	plugins map[string]Plugin
+}
+
+var _ Transport = (*Registry)(nil)
+
+func NewRegistry() *Registry {
+	return &Registry{plugins: make(map[string]Plugin)}
+}
+
+// Register sets the plugin for the transfer type
+func (r *Registry) Register(transferType string, p Plugin) {
+	r.plugins[transferType] = p
+}
+
+// Types returns the registered transfer types, in alphabetical order
+func (r *Registry) Types() []string {
+	names := make([]string, 0, len(r.plugins))
+	for t := range r.plugins {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *Registry) plugin(transferType string) (Plugin, error) {
+	p, ok := r.plugins[transferType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported transfer type '%s'", transferType)
+	}
+	return p, nil
+}
+
+// ValidateParams checks that the transfer type is supported, that the
+// transfer parameters are well-formed and that the client is allowed to use
+// them
+func (r *Registry) ValidateParams(transfer smtypes.Transfer, client address.Address) error {
+	p, err := r.plugin(transfer.Type)
+	if err != nil {
+		return err
+	}
+	return p.ValidateParams(transfer.Params, client)
+}
+
+// Host returns the host that the transfer downloads data from
+func (r *Registry) Host(transfer smtypes.Transfer) (string, error) {
+	p, err := r.plugin(transfer.Type)
+	if err != nil {
+		return "", err
+	}
+	return p.Host(transfer.Params)
+}
+
+// ParamsAsJson returns the transfer parameters as JSON, without any
+// sensitive information
+func (r *Registry) ParamsAsJson(transfer smtypes.Transfer) (string, error) {
+	p, err := r.plugin(transfer.Type)
+	if err != nil {
+		return "", err
+	}
+	return p.RedactParams(transfer.Params)
+}
+
+func (r *Registry) Execute(ctx context.Context, transportInfo []byte, dealInfo *types.TransportDealInfo) (Handler, error) {
+	p, err := r.plugin(dealInfo.TransferType)
+	if err != nil {
+		return nil, err
+	}
+	return p.Execute(ctx, transportInfo, dealInfo)
+}
@@ -0,0 +1,119 @@
+package s3transport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+)
+
+// ErrCredentialsNotFound is returned when there are no credentials with the
+// name that a transfer refers to
+var ErrCredentialsNotFound = errors.New("s3 credentials not found")
+
+// ErrCredentialsNotAllowed is returned when a transfer refers to credentials
+// that may not be used by the deal's client, or for the transfer's object
+var ErrCredentialsNotAllowed = errors.New("s3 credentials not allowed")
+
+// Credentials are the keys used to sign requests to S3, and the deals that
+// they may be used for
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string `json:",omitempty"`
+
+	// The addresses of the clients whose deals may use the credentials
+	Clients []string
+	// The objects that may be downloaded with the credentials, as prefixes
+	// of the object URL, eg "https://minio.example.com:9000/dataset-a/" or
+	// "https://dataset-a.s3.us-east-1.amazonaws.com/". The scheme and host
+	// must match exactly, and the path of the object URL must start with
+	// the path of the prefix.
+	Prefixes []string
+}
+
+// allow checks that the credentials may be used by the client to download
+// the object at the given URL. Credentials that are not bound to any
+// clients or prefixes can't be used at all.
+func (c *Credentials) allow(client address.Address, objectUrl *url.URL) error {
+	clientAllowed := false
+	for _, s := range c.Clients {
+		addr, err := address.NewFromString(s)
+		if err != nil {
+			return fmt.Errorf("parsing s3 credentials client address '%s': %w", s, err)
+		}
+		if addr == client {
+			clientAllowed = true
+			break
+		}
+	}
+	if !clientAllowed {
+		return fmt.Errorf("%w: client %s is not allowed to use the credentials", ErrCredentialsNotAllowed, client)
+	}
+
+	// Don't allow dot segments, which some servers would resolve to an
+	// object outside the prefix
+	for _, seg := range strings.Split(objectUrl.Path, "/") {
+		if seg == "." || seg == ".." {
+			return fmt.Errorf("%w: object path %s has a dot segment", ErrCredentialsNotAllowed, objectUrl.Path)
+		}
+	}
+	for _, s := range c.Prefixes {
+		prefix, err := url.Parse(s)
+		if err != nil {
+			return fmt.Errorf("parsing s3 credentials prefix '%s': %w", s, err)
+		}
+		if prefix.Scheme == objectUrl.Scheme &&
+			strings.EqualFold(prefix.Host, objectUrl.Host) &&
+			strings.HasPrefix(objectUrl.Path, prefix.Path) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: the credentials may not be used for %s", ErrCredentialsNotAllowed, objectUrl)
+}
+
+// CredentialStore looks up S3 credentials by name
+type CredentialStore interface {
+	Credentials(name string) (*Credentials, error)
+}
+
+// CredentialsFile is the path to a JSON file with named S3 credentials, eg
+//
+//	{"dataset-a": {
+//	  "AccessKeyID": "...",
+//	  "SecretAccessKey": "...",
+//	  "Clients": ["f1..."],
+//	  "Prefixes": ["https://minio.example.com:9000/dataset-a/"]
+//	}}
+//
+// The file is read each time credentials are looked up, so that changes to
+// the file take effect without a restart.
+type CredentialsFile string
+
+var _ CredentialStore = CredentialsFile("")
+
+func (f CredentialsFile) Credentials(name string) (*Credentials, error) {
+	if f == "" {
+		return nil, fmt.Errorf("%w: no s3 credentials file is configured", ErrCredentialsNotFound)
+	}
+
+	bz, err := os.ReadFile(string(f))
+	if err != nil {
+		return nil, fmt.Errorf("reading s3 credentials file: %w", err)
+	}
+
+	var creds map[string]*Credentials
+	if err := json.Unmarshal(bz, &creds); err != nil {
+		return nil, fmt.Errorf("parsing s3 credentials file %s: %w", f, err)
+	}
+
+	c, ok := creds[name]
+	if !ok || c == nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrCredentialsNotFound, name)
+	}
+	return c, nil
+}
@@ -0,0 +1,63 @@
+package s3transport
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/filecoin-project/boost/transport"
+	"github.com/filecoin-project/boost/transport/types"
+	"github.com/filecoin-project/go-address"
+)
+
+var _ transport.Plugin = (*s3Transport)(nil)
+
+func parseParams(params []byte) (*types.S3Request, error) {
+	req := &types.S3Request{}
+	if err := json.Unmarshal(params, req); err != nil {
+		return nil, fmt.Errorf("failed to de-serialize transport params bytes '%s': %w", string(params), err)
+	}
+	return req, nil
+}
+
+// ValidateParams checks that the request has a bucket and a key, that
+// the endpoint (if any) is an http or https URL, and that the client is
+// allowed to use the credentials (if any) to download the object
+func (s *s3Transport) ValidateParams(params []byte, client address.Address) error {
+	req, err := parseParams(params)
+	if err != nil {
+		return err
+	}
+	objectUrl, err := ObjectURL(req)
+	if err != nil {
+		return err
+	}
+	_, err = s.credentials(req, client, objectUrl)
+	return err
+}
+
+// Host returns the host of the S3 endpoint
+func (s *s3Transport) Host(params []byte) (string, error) {
+	req, err := parseParams(params)
+	if err != nil {
+		return "", err
+	}
+	u, err := ObjectURL(req)
+	if err != nil {
+		return "", err
+	}
+	return u.Host, nil
+}
+
+// RedactParams returns the S3 request. The Credentials field is only the
+// name of the provider's credentials, so it's safe to include it.
+func (s *s3Transport) RedactParams(params []byte) (string, error) {
+	req, err := parseParams(params)
+	if err != nil {
+		return "", err
+	}
+	bz, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("marshalling transfer params json: %w", err)
+	}
+	return string(bz), nil
+}
@@ -0,0 +1,409 @@
+package s3transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/storagemarket/logs"
+	"github.com/filecoin-project/boost/transport"
+	"github.com/filecoin-project/boost/transport/types"
+	"github.com/filecoin-project/go-address"
+	"github.com/jpillora/backoff"
+)
+
+const (
+	// 1 Mib
+	readBufferSize = 1048576
+
+	minBackOff           = 5 * time.Second
+	maxBackOff           = 10 * time.Minute
+	factor               = 1.5
+	maxReconnectAttempts = 15
+
+	defaultRegion = "us-east-1"
+)
+
+var _ transport.Transport = (*s3Transport)(nil)
+
+type Option func(*s3Transport)
+
+func BackOffRetryOpt(minBackoff, maxBackoff time.Duration, factor, maxReconnectAttempts float64) Option {
+	return func(s *s3Transport) {
+		s.minBackOffWait = minBackoff
+		s.maxBackoffWait = maxBackoff
+		s.backOffFactor = factor
+		s.maxReconnectAttempts = maxReconnectAttempts
+	}
+}
+
+// s3Transport downloads deal data from S3-compatible object storage. The
+// credentials for the bucket are configured on the provider, and the deal's
+// transfer parameters refer to them by name, so that clients don't need to
+// create pre-signed URLs (which can expire in the middle of a transfer).
+// Each request is signed when it is sent, and interrupted downloads are
+// resumed with a ranged GET.
+type s3Transport struct {
+	creds  CredentialStore
+	client *http.Client
+
+	minBackOffWait       time.Duration
+	maxBackoffWait       time.Duration
+	backOffFactor        float64
+	maxReconnectAttempts float64
+
+	dl *logs.DealLogger
+}
+
+func New(creds CredentialStore, dealLogger *logs.DealLogger, opts ...Option) *s3Transport {
+	s := &s3Transport{
+		creds: creds,
+		// Don't follow redirects, so that the signed headers (including
+		// the session token) are never sent to another host
+		client: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		minBackOffWait:       minBackOff,
+		maxBackoffWait:       maxBackOff,
+		backOffFactor:        factor,
+		maxReconnectAttempts: maxReconnectAttempts,
+		dl:                   dealLogger.Subsystem("s3-transport"),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+func (s *s3Transport) Execute(ctx context.Context, transportInfo []byte, dealInfo *types.TransportDealInfo) (transport.Handler, error) {
+	duuid := dealInfo.DealUuid
+
+	// de-serialize transport opaque token
+	req := &types.S3Request{}
+	if err := json.Unmarshal(transportInfo, req); err != nil {
+		return nil, fmt.Errorf("failed to de-serialize transport info bytes, bytes:%s, err:%w", string(transportInfo), err)
+	}
+
+	objectUrl, err := ObjectURL(req)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := s.credentials(req, dealInfo.Client, objectUrl)
+	if err != nil {
+		return nil, err
+	}
+	region := req.Region
+	if region == "" {
+		region = defaultRegion
+	}
+
+	// check that the outputFile exists
+	fi, err := os.Stat(dealInfo.OutputFile)
+	if err != nil {
+		return nil, fmt.Errorf("output file state error: %w", err)
+	}
+
+	// do we have more bytes than required already ?
+	fileSize := fi.Size()
+	if fileSize > dealInfo.DealSize {
+		return nil, fmt.Errorf("deal size=%d but file size=%d", dealInfo.DealSize, fileSize)
+	}
+	s.dl.Infow(duuid, "execute s3 transfer", "url", objectUrl.String(), "credentials", req.Credentials,
+		"file size", fileSize, "deal size", dealInfo.DealSize)
+
+	tctx, cancel := context.WithCancel(ctx)
+	t := &transfer{
+		cancel:         cancel,
+		url:            objectUrl,
+		region:         region,
+		creds:          creds,
+		client:         s.client,
+		dealInfo:       dealInfo,
+		eventCh:        make(chan types.TransportEvent, 256),
+		nBytesReceived: fileSize,
+		backoff: &backoff.Backoff{
+			Min:    s.minBackOffWait,
+			Max:    s.maxBackoffWait,
+			Factor: s.backOffFactor,
+			Jitter: true,
+		},
+		maxReconnectAttempts: s.maxReconnectAttempts,
+		dl:                   s.dl,
+	}
+
+	// is the transfer already complete ?
+	if fileSize == dealInfo.DealSize {
+		defer cancel()
+		defer t.closeEventChannel(tctx)
+
+		t.emitEvent(types.TransportEvent{NBytesReceived: fileSize, NBytesContiguous: fileSize})
+		s.dl.Infow(duuid, "file size is already equal to deal size, returning")
+		return t, nil
+	}
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		defer cancel()
+		defer t.closeEventChannel(tctx)
+
+		if err := t.execute(tctx); err != nil {
+			t.emitEvent(types.TransportEvent{Error: err})
+		}
+	}()
+
+	s.dl.Infow(duuid, "started async s3 transfer")
+	return t, nil
+}
+
+// credentials looks up the credentials that the request refers to, and checks
+// that the client may use them to download the object. Returns nil if the
+// request doesn't refer to any credentials.
+func (s *s3Transport) credentials(req *types.S3Request, client address.Address, objectUrl *url.URL) (*Credentials, error) {
+	if req.Credentials == "" {
+		return nil, nil
+	}
+	creds, err := s.creds.Credentials(req.Credentials)
+	if err != nil {
+		return nil, fmt.Errorf("getting s3 credentials: %w", err)
+	}
+	if err := creds.allow(client, objectUrl); err != nil {
+		return nil, fmt.Errorf("s3 credentials '%s': %w", req.Credentials, err)
+	}
+	return creds, nil
+}
+
+// ObjectURL returns the URL of the object in the S3 request
+func ObjectURL(req *types.S3Request) (*url.URL, error) {
+	if req.Bucket == "" || req.Key == "" {
+		return nil, errors.New("s3 transfer must have a bucket and a key")
+	}
+
+	key := strings.TrimPrefix(req.Key, "/")
+	if req.Endpoint == "" {
+		region := req.Region
+		if region == "" {
+			region = defaultRegion
+		}
+		return objectURL(fmt.Sprintf("https://%s.s3.%s.amazonaws.com", req.Bucket, region), "/"+key)
+	}
+	return objectURL(req.Endpoint, "/"+req.Bucket+"/"+key)
+}
+
+func objectURL(endpoint string, path string) (*url.URL, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing s3 endpoint '%s': %w", endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("s3 endpoint '%s' must be an http or https url", endpoint)
+	}
+
+	// Set the raw path so that the key is encoded in the same way when it
+	// is sent as when the request is signed
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawPath = uriEncode(u.Path, true)
+	return u, nil
+}
+
+type transfer struct {
+	closeOnce sync.Once
+	cancel    context.CancelFunc
+
+	eventCh chan types.TransportEvent
+	lastEvt *types.TransportEvent
+
+	url    *url.URL
+	region string
+	// creds are nil for unsigned requests
+	creds  *Credentials
+	client *http.Client
+
+	dealInfo *types.TransportDealInfo
+	wg       sync.WaitGroup
+
+	nBytesReceived int64
+
+	backoff              *backoff.Backoff
+	maxReconnectAttempts float64
+
+	dl *logs.DealLogger
+}
+
+func (t *transfer) execute(ctx context.Context) error {
+	duuid := t.dealInfo.DealUuid
+
+	of, err := os.OpenFile(t.dealInfo.OutputFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	defer of.Close()
+
+	for {
+		before := t.nBytesReceived
+		reqErr := t.doRequest(ctx, of)
+		if reqErr == nil {
+			break
+		}
+
+		t.dl.Infow(duuid, "s3 request error", "http code", reqErr.code, "err", reqErr.Error())
+
+		// A 4xx error means there is a problem with the request (eg 403
+		// Forbidden because of bad credentials), so don't retry. Redirects
+		// are not followed, so a 3xx response won't succeed on retry either.
+		if reqErr.code/100 == 4 || reqErr.code/100 == 3 {
+			msg := fmt.Sprintf("terminating s3 request: received %d response from server", reqErr.code)
+			t.dl.LogError(duuid, msg, reqErr)
+			return reqErr.error
+		}
+
+		err := reqErr.error
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			t.dl.LogError(duuid, "terminating s3 transfer: context cancelled or deadline exceeded", err)
+			return fmt.Errorf("transfer context canceled err: %w", err)
+		}
+
+		// If some data was transferred, reset the back-off count to zero
+		if t.nBytesReceived > before {
+			t.backoff.Reset()
+		}
+
+		nAttempts := t.backoff.Attempt() + 1
+		if nAttempts >= t.maxReconnectAttempts {
+			t.dl.Errorw(duuid, "terminating s3 transfer: exhausted max attempts", "err", err.Error(), "maxAttempts", t.maxReconnectAttempts)
+			return fmt.Errorf("could not finish transfer even after %.0f attempts, lastErr: %w", t.maxReconnectAttempts, err)
+		}
+		duration := t.backoff.Duration()
+		t.dl.Infow(duuid, "backing off before retrying s3 request", "backoff time", duration.String(), "attempts", nAttempts)
+		bt := time.NewTimer(duration)
+		select {
+		case <-bt.C:
+		case <-ctx.Done():
+			bt.Stop()
+			return fmt.Errorf("transfer canceled after %.0f attempts to finish transfer, lastErr=%s, contextErr=%w", t.backoff.Attempt(), err, ctx.Err())
+		}
+	}
+
+	if t.nBytesReceived != t.dealInfo.DealSize {
+		return fmt.Errorf("mismatch in dealSize vs received bytes, dealSize=%d, received=%d", t.dealInfo.DealSize, t.nBytesReceived)
+	}
+
+	t.dl.Infow(duuid, "s3 transfer finished successfully", "nBytesReceived", t.nBytesReceived)
+	return nil
+}
+
+type httpError struct {
+	error
+	code int
+}
+
+// doRequest sends a signed GET for the rest of the object, starting from
+// the number of bytes already received, and appends the response to the
+// output file
+func (t *transfer) doRequest(ctx context.Context, dst io.Writer) *httpError {
+	req, err := http.NewRequestWithContext(ctx, "GET", t.url.String(), nil)
+	if err != nil {
+		return &httpError{error: fmt.Errorf("failed to create s3 request: %w", err)}
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", t.nBytesReceived, t.dealInfo.DealSize-1))
+	if t.creds != nil {
+		signRequest(req, t.creds, t.region, time.Now())
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return &httpError{error: fmt.Errorf("failed to send s3 request: %w", err)}
+	}
+	defer resp.Body.Close() // nolint
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// The server ignored the range and sent the whole object
+		if t.nBytesReceived != 0 {
+			return &httpError{error: errors.New("s3 server does not support range requests")}
+		}
+	default:
+		return &httpError{
+			error: fmt.Errorf("s3 request failed: code: %d, status: %s", resp.StatusCode, resp.Status),
+			code:  resp.StatusCode,
+		}
+	}
+
+	buf := make([]byte, readBufferSize)
+	limitR := io.LimitReader(resp.Body, t.dealInfo.DealSize-t.nBytesReceived)
+	for {
+		if ctx.Err() != nil {
+			return &httpError{error: ctx.Err()}
+		}
+		nr, readErr := limitR.Read(buf)
+		if nr > 0 {
+			if lim := t.dealInfo.RateLimiter; lim != nil {
+				if err := lim.WaitN(ctx, nr); err != nil {
+					return &httpError{error: err}
+				}
+			}
+			nw, writeErr := dst.Write(buf[0:nr])
+			if writeErr != nil {
+				return &httpError{error: fmt.Errorf("failed to write to output file: %w", writeErr)}
+			}
+			t.nBytesReceived += int64(nw)
+			t.emitEvent(types.TransportEvent{NBytesReceived: t.nBytesReceived, NBytesContiguous: t.nBytesReceived})
+		}
+		if readErr == io.EOF {
+			if t.nBytesReceived != t.dealInfo.DealSize {
+				return &httpError{error: fmt.Errorf("s3 server sent EOF after %d of %d bytes", t.nBytesReceived, t.dealInfo.DealSize)}
+			}
+			return nil
+		}
+		if readErr != nil {
+			return &httpError{error: fmt.Errorf("error reading from s3 response stream: %w", readErr)}
+		}
+	}
+}
+
+// Close shuts down the transfer for the given deal. It is the caller's responsibility to call Close after it no longer needs the transfer.
+func (t *transfer) Close() {
+	t.closeOnce.Do(func() {
+		t.cancel()
+		t.wg.Wait()
+	})
+}
+
+func (t *transfer) Sub() chan types.TransportEvent {
+	return t.eventCh
+}
+
+func (t *transfer) emitEvent(evt types.TransportEvent) {
+	t.lastEvt = nil
+	select {
+	case t.eventCh <- evt:
+	default:
+		// If the channel is full, save the event so that it is sent before
+		// the channel is closed. A newer event supersedes an older one.
+		t.lastEvt = &evt
+	}
+}
+
+func (t *transfer) closeEventChannel(ctx context.Context) {
+	// If there was an event that wasn't sent because the channel was full,
+	// ensure that it gets sent before close
+	if t.lastEvt != nil {
+		select {
+		case <-ctx.Done():
+		case t.eventCh <- *t.lastEvt:
+		}
+	}
+	close(t.eventCh)
+}
@@ -0,0 +1,355 @@
+package s3transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/storagemarket/logs"
+	"github.com/filecoin-project/boost/transport"
+	"github.com/filecoin-project/boost/transport/types"
+	"github.com/filecoin-project/go-address"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+var testCreds = &Credentials{
+	AccessKeyID:     "AKIDTEST",
+	SecretAccessKey: "secret",
+}
+
+var testClient, _ = address.NewIDAddress(1234)
+
+type mockCredentialStore map[string]*Credentials
+
+func (m mockCredentialStore) Credentials(name string) (*Credentials, error) {
+	c, ok := m[name]
+	if !ok {
+		return nil, ErrCredentialsNotFound
+	}
+	return c, nil
+}
+
+// s3StandIn is an in-process stand-in for an S3 server. It serves a single
+// object, checks the signature of each request, and can be set to close the
+// connection after a number of bytes to simulate a dropped connection.
+type s3StandIn struct {
+	t        *testing.T
+	svr      *httptest.Server
+	path     string
+	data     []byte
+	requests int32
+	// the number of bytes to send before dropping the connection, or zero
+	// to send the whole response
+	dropAfter int64
+}
+
+func newS3StandIn(t *testing.T, bucket, key string, data []byte) *s3StandIn {
+	s := &s3StandIn{t: t, path: "/" + bucket + "/" + key, data: data}
+	s.svr = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.svr.Close)
+	return s
+}
+
+func (s *s3StandIn) handle(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&s.requests, 1)
+
+	// Check the signature by signing a copy of the request with the same
+	// date
+	now, err := time.Parse(amzDateFormat, r.Header.Get("x-amz-date"))
+	if err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	check, err := http.NewRequest(r.Method, "http://"+r.Host+r.URL.EscapedPath(), nil)
+	require.NoError(s.t, err)
+	check.Header.Set("Range", r.Header.Get("Range"))
+	signRequest(check, testCreds, "us-east-1", now)
+	if check.Header.Get("Authorization") != r.Header.Get("Authorization") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if r.URL.Path != s.path {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if s.dropAfter == 0 {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(s.data))
+		return
+	}
+
+	// Send part of the response and then close the connection
+	var start, end int64
+	_, err = fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+	require.NoError(s.t, err)
+	sendEnd := start + s.dropAfter
+	if sendEnd > int64(len(s.data)) {
+		sendEnd = int64(len(s.data))
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(end+1-start, 10))
+	w.WriteHeader(http.StatusPartialContent)
+	_, _ = w.Write(s.data[start:sendEnd])
+	if sendEnd <= end {
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// creds returns the test credentials, bound to the test client and to the
+// bucket on the stand-in server
+func (s *s3StandIn) creds(bucket string) *Credentials {
+	return &Credentials{
+		AccessKeyID:     testCreds.AccessKeyID,
+		SecretAccessKey: testCreds.SecretAccessKey,
+		Clients:         []string{testClient.String()},
+		Prefixes:        []string{s.svr.URL + "/" + bucket + "/"},
+	}
+}
+
+func (s *s3StandIn) request(bucket, key string) types.S3Request {
+	return types.S3Request{
+		Endpoint:    s.svr.URL,
+		Bucket:      bucket,
+		Key:         key,
+		Credentials: "test",
+	}
+}
+
+func TestS3Transfer(t *testing.T) {
+	ctx := context.Background()
+	data := randBytes(t, 1024*1024)
+	standIn := newS3StandIn(t, "deals", "dir/data file.car", data)
+
+	s := newTestTransport(t, ctx, standIn.creds("deals"))
+	of := getTempFilePath(t)
+	th := executeTransfer(t, ctx, s, len(data), standIn.request("deals", "dir/data file.car"), of)
+	evts := waitForTransferComplete(th)
+	require.NotEmpty(t, evts)
+	require.NoError(t, evts[len(evts)-1].Error)
+	require.EqualValues(t, len(data), evts[len(evts)-1].NBytesReceived)
+	assertFileContents(t, of, data)
+}
+
+func TestS3TransferResumption(t *testing.T) {
+	ctx := context.Background()
+	data := randBytes(t, 1024*1024)
+	standIn := newS3StandIn(t, "deals", "data.car", data)
+	standIn.dropAfter = 100 * 1024
+
+	// Start with part of the file already downloaded
+	of := getTempFilePath(t)
+	require.NoError(t, os.WriteFile(of, data[:50*1024], 0644))
+
+	s := newTestTransport(t, ctx, standIn.creds("deals"))
+	th := executeTransfer(t, ctx, s, len(data), standIn.request("deals", "data.car"), of)
+	evts := waitForTransferComplete(th)
+	require.NotEmpty(t, evts)
+	require.NoError(t, evts[len(evts)-1].Error)
+	require.EqualValues(t, len(data), evts[len(evts)-1].NBytesReceived)
+	assertFileContents(t, of, data)
+
+	// Each request resumed from where the last one left off
+	require.EqualValues(t, (len(data)-50*1024+100*1024-1)/(100*1024), atomic.LoadInt32(&standIn.requests))
+}
+
+func TestS3TransferErrors(t *testing.T) {
+	ctx := context.Background()
+	data := randBytes(t, 1024)
+	standIn := newS3StandIn(t, "deals", "data.car", data)
+	s := newTestTransport(t, ctx, standIn.creds("deals"))
+
+	// A missing object is not retried
+	th := executeTransfer(t, ctx, s, len(data), standIn.request("deals", "missing.car"), getTempFilePath(t))
+	evts := waitForTransferComplete(th)
+	require.NotEmpty(t, evts)
+	require.ErrorContains(t, evts[len(evts)-1].Error, "404")
+	require.EqualValues(t, 1, atomic.LoadInt32(&standIn.requests))
+
+	// Bad credentials are rejected
+	badCreds := standIn.creds("deals")
+	badCreds.SecretAccessKey = "wrong"
+	s.creds = mockCredentialStore{"test": badCreds}
+	th = executeTransfer(t, ctx, s, len(data), standIn.request("deals", "data.car"), getTempFilePath(t))
+	evts = waitForTransferComplete(th)
+	require.NotEmpty(t, evts)
+	require.ErrorContains(t, evts[len(evts)-1].Error, "403")
+
+	// Unknown credentials fail before the transfer starts
+	req := standIn.request("deals", "data.car")
+	req.Credentials = "unknown"
+	bz, err := json.Marshal(req)
+	require.NoError(t, err)
+	_, err = s.Execute(ctx, bz, &types.TransportDealInfo{OutputFile: getTempFilePath(t), DealSize: int64(len(data)), Client: testClient})
+	require.ErrorIs(t, err, ErrCredentialsNotFound)
+}
+
+func TestS3CredentialsBinding(t *testing.T) {
+	ctx := context.Background()
+	data := randBytes(t, 1024)
+	standIn := newS3StandIn(t, "deals", "data.car", data)
+	s := newTestTransport(t, ctx, standIn.creds("deals"))
+	otherClient, err := address.NewIDAddress(5678)
+	require.NoError(t, err)
+
+	validate := func(req types.S3Request, client address.Address) error {
+		bz, err := json.Marshal(req)
+		require.NoError(t, err)
+		return s.ValidateParams(bz, client)
+	}
+
+	// The credentials can be used by the client for objects in the bucket
+	require.NoError(t, validate(standIn.request("deals", "dir/data.car"), testClient))
+
+	// Requests without credentials are not signed, so any client can
+	// make them
+	anon := standIn.request("private", "data.car")
+	anon.Credentials = ""
+	require.NoError(t, validate(anon, otherClient))
+
+	// The credentials can't be used by another client
+	require.ErrorIs(t, validate(standIn.request("deals", "data.car"), otherClient), ErrCredentialsNotAllowed)
+
+	// The credentials can't be used for another bucket
+	require.ErrorIs(t, validate(standIn.request("private", "data.car"), testClient), ErrCredentialsNotAllowed)
+	require.ErrorIs(t, validate(standIn.request("deals-private", "data.car"), testClient), ErrCredentialsNotAllowed)
+	require.ErrorIs(t, validate(standIn.request("deals", "../private/data.car"), testClient), ErrCredentialsNotAllowed)
+
+	// The credentials can't be sent to another host
+	req := standIn.request("deals", "data.car")
+	req.Endpoint = "http://example.com"
+	require.ErrorIs(t, validate(req, testClient), ErrCredentialsNotAllowed)
+
+	// The binding is also checked when the transfer is executed
+	bz, err := json.Marshal(standIn.request("deals", "data.car"))
+	require.NoError(t, err)
+	_, err = s.Execute(ctx, bz, &types.TransportDealInfo{OutputFile: getTempFilePath(t), DealSize: int64(len(data)), Client: otherClient})
+	require.ErrorIs(t, err, ErrCredentialsNotAllowed)
+	require.EqualValues(t, 0, atomic.LoadInt32(&standIn.requests))
+}
+
+func TestObjectURL(t *testing.T) {
+	u, err := ObjectURL(&types.S3Request{Bucket: "deals", Key: "data.car"})
+	require.NoError(t, err)
+	require.Equal(t, "https://deals.s3.us-east-1.amazonaws.com/data.car", u.String())
+
+	u, err = ObjectURL(&types.S3Request{Endpoint: "http://localhost:9000/", Bucket: "deals", Key: "/a b.car"})
+	require.NoError(t, err)
+	require.Equal(t, "http://localhost:9000/deals/a%20b.car", u.String())
+
+	_, err = ObjectURL(&types.S3Request{Bucket: "deals"})
+	require.Error(t, err)
+	_, err = ObjectURL(&types.S3Request{Endpoint: "localhost:9000", Bucket: "deals", Key: "data.car"})
+	require.Error(t, err)
+}
+
+func TestCredentialsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s3-credentials.json")
+	bz, err := json.Marshal(map[string]*Credentials{"test": testCreds})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, bz, 0600))
+
+	creds, err := CredentialsFile(path).Credentials("test")
+	require.NoError(t, err)
+	require.Equal(t, testCreds, creds)
+
+	_, err = CredentialsFile(path).Credentials("other")
+	require.ErrorIs(t, err, ErrCredentialsNotFound)
+	_, err = CredentialsFile("").Credentials("test")
+	require.ErrorIs(t, err, ErrCredentialsNotFound)
+}
+
+func newTestTransport(t *testing.T, ctx context.Context, creds *Credentials) *s3Transport {
+	tmp := db.CreateTestTmpDB(t)
+	require.NoError(t, db.CreateAllBoostTables(ctx, tmp, tmp))
+	dl := logs.NewDealLogger(db.NewLogsDB(tmp))
+	return New(mockCredentialStore{"test": creds}, dl,
+		BackOffRetryOpt(10*time.Millisecond, 20*time.Millisecond, 2, 1000))
+}
+
+func executeTransfer(t *testing.T, ctx context.Context, s *s3Transport, size int, req types.S3Request, of string) transport.Handler {
+	bz, err := json.Marshal(req)
+	require.NoError(t, err)
+
+	th, err := s.Execute(ctx, bz, &types.TransportDealInfo{
+		OutputFile:   of,
+		DealUuid:     uuid.New(),
+		DealSize:     int64(size),
+		TransferType: "s3",
+		Client:       testClient,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, th)
+	return th
+}
+
+func waitForTransferComplete(th transport.Handler) []types.TransportEvent {
+	var evts []types.TransportEvent
+	for evt := range th.Sub() {
+		evts = append(evts, evt)
+	}
+	th.Close()
+	return evts
+}
+
+func assertFileContents(t *testing.T, file string, expected []byte) {
+	bz, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, expected, bz)
+}
+
+func getTempFilePath(t *testing.T) string {
+	f, err := os.CreateTemp(t.TempDir(), "")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	return f.Name()
+}
+
+func randBytes(t *testing.T, n int) []byte {
+	bz := make([]byte, n)
+	_, err := rand.Read(bz)
+	require.NoError(t, err)
+	return bz
+}
+
+func TestHost(t *testing.T) {
+	testCases := []struct {
+		name     string
+		req      types.S3Request
+		expected string
+	}{{
+		name:     "endpoint",
+		req:      types.S3Request{Endpoint: "http://minio.local:9000", Bucket: "data", Key: "a.car"},
+		expected: "minio.local:9000",
+	}, {
+		name:     "aws",
+		req:      types.S3Request{Region: "eu-west-1", Bucket: "data", Key: "a.car"},
+		expected: "data.s3.eu-west-1.amazonaws.com",
+	}}
+
+	s := &s3Transport{}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := json.Marshal(tc.req)
+			require.NoError(t, err)
+
+			require.NoError(t, s.ValidateParams(res, address.Undef))
+			h, err := s.Host(res)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expected, h)
+		})
+	}
+}
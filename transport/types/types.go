@@ -1,6 +1,9 @@
 package types
 
 import (
+	"context"
+
+	"github.com/filecoin-project/go-address"
 	"github.com/google/uuid"
 	"github.com/ipfs/go-cid"
 )
@@ -19,6 +22,32 @@ type HttpRequest struct {
 	// Headers are the HTTP headers that are sent as part of the request,
 	// eg "Authorization"
 	Headers map[string]string
+	// Mirrors are additional URLs, in the same format as URL, that serve
+	// the same data. When there are mirrors the data is downloaded in
+	// segments, which are fetched in parallel from the URL and the mirrors.
+	// The same Headers are sent to each mirror, except for credentials
+	// (eg "Authorization" and "Cookie"), which are only sent to mirrors with
+	// the same scheme and host as URL.
+	Mirrors []string `json:",omitempty"`
+}
+
+// S3Request has parameters for a transfer from S3-compatible object storage
+type S3Request struct {
+	// Endpoint is the URL of the S3-compatible service, eg
+	// "https://minio.example.com:9000". Objects are addressed with
+	// path-style URLs: <endpoint>/<bucket>/<key>.
+	// If Endpoint is empty, the AWS S3 endpoint for the region is used,
+	// with virtual-hosted-style URLs.
+	Endpoint string
+	// Region is the region of the bucket (defaults to "us-east-1")
+	Region string
+	Bucket string
+	Key    string
+	// Credentials is the name of the credentials that the storage provider
+	// has configured for accessing the bucket. The credentials themselves
+	// are never sent in the deal proposal. If Credentials is empty, the
+	// object is downloaded with unsigned (anonymous) requests.
+	Credentials string
 }
 
 // TransportDealInfo has parameters for a transfer to be executed
@@ -26,12 +55,31 @@ type TransportDealInfo struct {
 	OutputFile string
 	DealUuid   uuid.UUID
 	DealSize   int64
+	// TransferType is the deal's transfer type (eg "http"), which
+	// determines the transport that executes the transfer
+	TransferType string
+	// Client is the address of the deal's client
+	Client address.Address
+	// RateLimiter limits the bandwidth of the transfer. If nil there is no
+	// limit.
+	RateLimiter RateLimiter
+}
+
+// RateLimiter limits the rate at which a transfer receives data
+type RateLimiter interface {
+	// WaitN blocks until the transfer may write n more bytes that it has
+	// received, or the context is cancelled
+	WaitN(ctx context.Context, n int) error
 }
 
 // TransportEvent is fired as a transfer progresses
 type TransportEvent struct {
 	NBytesReceived int64
-	Error          error
+	// NBytesContiguous is the number of bytes from the start of the output
+	// file up to the first byte that has not yet been written. It's the same
+	// as NBytesReceived for transports that write the file sequentially.
+	NBytesContiguous int64
+	Error            error
 }
 
 // TransferStatus describes the status of a transfer (started, completed etc)